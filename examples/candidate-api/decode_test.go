@@ -0,0 +1,127 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/xyz-company/examples/internal/httputil"
+)
+
+// decodeSeeds are the inputs every decode fuzz target starts from:
+// well-formed bodies and the malformed ones seen in the wild
+func decodeSeeds(valid ...string) [][]byte {
+	seeds := [][]byte{
+		// Truncated
+		[]byte(``),
+		[]byte(`{`),
+		[]byte(`{"name":"Alice","email":`),
+		[]byte(`{"skills":["Go",`),
+		// Deeply nested
+		[]byte(strings.Repeat(`[`, 10000) + strings.Repeat(`]`, 10000)),
+		[]byte(strings.Repeat(`{"a":`, 5000) + `1` + strings.Repeat(`}`, 5000)),
+		[]byte(`{"skills":` + strings.Repeat(`[`, 1000) + strings.Repeat(`]`, 1000) + `}`),
+		// Not UTF-8
+		[]byte("{\"name\":\"\xff\xfe\"}"),
+		[]byte("{\"name\":\"\xc3\x28\",\"skills\":[\"\xed\xa0\x80\"]}"),
+		[]byte("\xef\xbb\xbf{}"),
+		[]byte("\x00\x01\x02"),
+		// Valid JSON the decoder must still refuse
+		[]byte(`{} {}`),
+		[]byte(`{"title":"Engineer"}`),
+		[]byte(`{"skills":"Go"}`),
+		[]byte(`{"id":"1"}`),
+		[]byte(`{"createdAt":"2020-01-01T00:00:00Z"}`),
+		[]byte(`{"name":1e999}`),
+		[]byte(`[]`),
+		// Valid JSON the field limits must refuse
+		[]byte(`{"name":"` + strings.Repeat("n", httputil.TextLimit.Hard+1) + `"}`),
+		[]byte(`{"skills":[` + strings.Repeat(`"Go",`, httputil.MaxSkills) + `"Go"]}`),
+	}
+	for _, v := range valid {
+		seeds = append(seeds, []byte(v))
+	}
+	return seeds
+}
+
+// checkDecodeError fails unless err is a clean 4xx DecodeError with a message
+func checkDecodeError(t *testing.T, body []byte, err error) {
+	t.Helper()
+	var de *httputil.DecodeError
+	if !errors.As(err, &de) {
+		t.Fatalf("decoding %q returned %T %v, want a *httputil.DecodeError", body, err, err)
+	}
+	if de.Status < 400 || de.Status > 499 {
+		t.Fatalf("decoding %q returned status %d, want a 4xx", body, de.Status)
+	}
+	if de.Message == "" {
+		t.Fatalf("decoding %q returned an error without a message", body)
+	}
+}
+
+func FuzzDecodeCandidate(f *testing.F) {
+	for _, seed := range decodeSeeds(
+		`{"name":"Alice Johnson","email":"alice@example.com","skills":["Go","Kubernetes","AWS"]}`,
+		`{"name":"Zoë","skills":null}`,
+		`null`,
+	) {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, body []byte) {
+		var input CandidateInput
+		err := httputil.DecodeJSONBody(bytes.NewReader(body), &input)
+		if err != nil {
+			checkDecodeError(t, body, err)
+			return
+		}
+		if !json.Valid(body) {
+			t.Fatalf("decoded invalid JSON %q without an error", body)
+		}
+		candidate := input.candidate()
+		if _, err := applyCandidateLimits(&candidate); err != nil {
+			return
+		}
+		if n := utf8.RuneCountInString(candidate.Name); n > httputil.TextLimit.Soft {
+			t.Fatalf("name of %d characters passed the limits for %q", n, body)
+		}
+		if n := utf8.RuneCountInString(candidate.Email); n > httputil.TextLimit.Soft {
+			t.Fatalf("email of %d characters passed the limits for %q", n, body)
+		}
+		if len(candidate.Skills) > httputil.MaxSkills {
+			t.Fatalf("%d skills passed the limits for %q", len(candidate.Skills), body)
+		}
+	})
+}
+
+func FuzzCreateCandidate(f *testing.F) {
+	for _, seed := range decodeSeeds(
+		`{"name":"Alice Johnson","email":"alice@example.com","skills":["Go","Kubernetes","AWS"]}`,
+	) {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, body []byte) {
+		saved := candidates
+		defer func() { candidates = saved }()
+
+		w := httptest.NewRecorder()
+		candidatesHandler(w, httptest.NewRequest(http.MethodPost, "/api/v1/candidates", bytes.NewReader(body)))
+
+		switch w.Code {
+		case http.StatusCreated, http.StatusBadRequest, http.StatusRequestEntityTooLarge, http.StatusUnprocessableEntity:
+		default:
+			t.Fatalf("POST %q returned %d, want 201, 400, 413 or 422", body, w.Code)
+		}
+		var resp httputil.Response
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("POST %q replied with a body that is not JSON: %v", body, err)
+		}
+		if w.Code != http.StatusCreated && resp.Message == "" {
+			t.Fatalf("POST %q returned %d without a message", body, w.Code)
+		}
+	})
+}
@@ -1,4 +0,0 @@
-module github.com/xyz-company/candidate-api
-
-go 1.21
-
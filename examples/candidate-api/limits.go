@@ -0,0 +1,21 @@
+// Field length limits
+// Candidate fields are checked against the shared httputil limits
+
+package main
+
+import "github.com/xyz-company/examples/internal/httputil"
+
+// applyCandidateLimits enforces field limits on a candidate, returning any truncation warnings
+func applyCandidateLimits(c *Candidate) ([]string, error) {
+	var warnings []string
+	if err := httputil.TextLimit.Apply("name", &c.Name, &warnings); err != nil {
+		return nil, err
+	}
+	if err := httputil.TextLimit.Apply("email", &c.Email, &warnings); err != nil {
+		return nil, err
+	}
+	if err := httputil.ApplySkillLimits(c.Skills, &warnings); err != nil {
+		return nil, err
+	}
+	return warnings, nil
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/xyz-company/examples/internal/httputil"
+)
+
+// withCandidates replaces the candidate store for the duration of the test
+func withCandidates(t *testing.T, cs ...Candidate) {
+	t.Helper()
+	saved := candidates
+	candidates = cs
+	t.Cleanup(func() { candidates = saved })
+}
+
+func TestApplyCandidateLimitsBoundaries(t *testing.T) {
+	text := httputil.TextLimit
+	tests := []struct {
+		name      string
+		candidate Candidate
+		warnings  int
+		wantErr   string
+	}{
+		{name: "within every limit", candidate: Candidate{Name: "Alice", Email: "alice@example.com", Skills: []string{"Go"}}},
+		{name: "name at the soft limit", candidate: Candidate{Name: strings.Repeat("n", text.Soft)}},
+		{name: "name over the soft limit", candidate: Candidate{Name: strings.Repeat("n", text.Soft+1)}, warnings: 1},
+		{name: "name over the hard limit", candidate: Candidate{Name: strings.Repeat("n", text.Hard+1)}, wantErr: "name is 1001 characters, maximum is 1000"},
+		{name: "email over the soft limit", candidate: Candidate{Email: strings.Repeat("e", text.Soft+1)}, warnings: 1},
+		{name: "email over the hard limit", candidate: Candidate{Email: strings.Repeat("e", text.Hard+1)}, wantErr: "email is"},
+		{name: "skill over the soft limit", candidate: Candidate{Skills: []string{strings.Repeat("s", text.Soft+1)}}, warnings: 1},
+		{name: "too many skills", candidate: Candidate{Skills: make([]string, httputil.MaxSkills+1)}, wantErr: "skills has 51 entries"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := tt.candidate
+			warnings, err := applyCandidateLimits(&c)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("applyCandidateLimits() = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(warnings) != tt.warnings {
+				t.Fatalf("warnings = %q, want %d", warnings, tt.warnings)
+			}
+		})
+	}
+}
+
+func TestCreateCandidateLimits(t *testing.T) {
+	withCandidates(t)
+
+	body, _ := json.Marshal(CandidateInput{Name: strings.Repeat("n", httputil.TextLimit.Soft+1)})
+	w := httptest.NewRecorder()
+	candidatesHandler(w, httptest.NewRequest(http.MethodPost, "/api/v1/candidates", strings.NewReader(string(body))))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("soft limit: status = %d: %s", w.Code, w.Body)
+	}
+	var resp httputil.Response
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Warnings) != 1 || len(candidates) != 1 || len(candidates[0].Name) != httputil.TextLimit.Soft {
+		t.Fatalf("soft limit: warnings %q with %d stored, want one truncated candidate", resp.Warnings, len(candidates))
+	}
+
+	body, _ = json.Marshal(CandidateInput{Name: strings.Repeat("n", httputil.TextLimit.Hard+1)})
+	w = httptest.NewRecorder()
+	candidatesHandler(w, httptest.NewRequest(http.MethodPost, "/api/v1/candidates", strings.NewReader(string(body))))
+	if w.Code != http.StatusUnprocessableEntity || len(candidates) != 1 {
+		t.Fatalf("hard limit: status = %d with %d stored, want %d and nothing new", w.Code, len(candidates), http.StatusUnprocessableEntity)
+	}
+}
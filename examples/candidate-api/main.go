@@ -4,12 +4,13 @@
 package main
 
 import (
-	"encoding/json"
 	"fmt"
 	"log"
 	"net/http"
 	"os"
 	"time"
+
+	"github.com/xyz-company/examples/internal/httputil"
 )
 
 // Candidate represents a job candidate
@@ -21,19 +22,24 @@ type Candidate struct {
 	CreatedAt time.Time `json:"createdAt"`
 }
 
-// Response is a generic API response
-type Response struct {
-	Status  string      `json:"status"`
-	Data    interface{} `json:"data,omitempty"`
-	Message string      `json:"message,omitempty"`
+// CandidateInput holds the Candidate fields clients may set on create. ID and
+// CreatedAt are server-controlled; httputil.DecodeJSON rejects them as unknown fields.
+type CandidateInput struct {
+	Name   string   `json:"name"`
+	Email  string   `json:"email"`
+	Skills []string `json:"skills"`
 }
 
-var candidates = []Candidate{
-	{ID: "1", Name: "Alice Johnson", Email: "alice@example.com", Skills: []string{"Go", "Kubernetes", "AWS"}, CreatedAt: time.Now()},
-	{ID: "2", Name: "Bob Smith", Email: "bob@example.com", Skills: []string{"Python", "ML", "TensorFlow"}, CreatedAt: time.Now()},
-	{ID: "3", Name: "Carol Williams", Email: "carol@example.com", Skills: []string{"Java", "Spring", "PostgreSQL"}, CreatedAt: time.Now()},
+// candidate maps the input to a new Candidate
+func (in CandidateInput) candidate() Candidate {
+	return Candidate{Name: in.Name, Email: in.Email, Skills: in.Skills}
 }
 
+var candidates []Candidate
+
+// startup tracks initialization; API requests are refused until it completes
+var startup = httputil.NewStartupTracker("store")
+
 func main() {
 	port := os.Getenv("PORT")
 	if port == "" {
@@ -41,42 +47,40 @@ func main() {
 	}
 
 	// Routes
-	http.HandleFunc("/", homeHandler)
-	http.HandleFunc("/health", healthHandler)
-	http.HandleFunc("/ready", readyHandler)
+	http.HandleFunc("/", httputil.HomeHandler("Candidate API v1.0.0 - XYZ Platform"))
+	http.HandleFunc("/health", httputil.HealthHandler)
+	http.HandleFunc("/ready", httputil.ReadyHandler(startup.Started))
+	http.HandleFunc("/startupz", startup.Handler)
 	http.HandleFunc("/api/v1/candidates", candidatesHandler)
 	http.HandleFunc("/api/v1/candidates/", candidateByIDHandler)
 
-	log.Printf("Starting Candidate API on port %s", port)
-	log.Fatal(http.ListenAndServe(":"+port, logRequest(http.DefaultServeMux)))
-}
+	handler := httputil.LogRequests(http.DefaultServeMux)
+	if capture := httputil.NewRequestCaptureFromEnv(); capture != nil {
+		http.HandleFunc("/debug/requests", capture.Handler)
+		handler = capture.Middleware(handler)
+	}
+	handler = startup.Gate(handler)
 
-func logRequest(handler http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		log.Printf("%s %s %s", r.RemoteAddr, r.Method, r.URL)
-		handler.ServeHTTP(w, r)
-	})
-}
+	go initialize()
 
-func homeHandler(w http.ResponseWriter, r *http.Request) {
-	if r.URL.Path != "/" {
-		http.NotFound(w, r)
-		return
-	}
-	json.NewEncoder(w).Encode(Response{
-		Status:  "ok",
-		Message: "Candidate API v1.0.0 - XYZ Platform",
-	})
+	log.Printf("Starting Candidate API on port %s", port)
+	log.Fatal(http.ListenAndServe(":"+port, handler))
 }
 
-func healthHandler(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(Response{Status: "healthy"})
+// initialize runs the startup phases in order
+func initialize() {
+	candidates = seedCandidates()
+	startup.Complete("store")
+	log.Printf("Candidate API started")
 }
 
-func readyHandler(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(Response{Status: "ready"})
+// seedCandidates returns the sample candidates the in-memory store starts with
+func seedCandidates() []Candidate {
+	return []Candidate{
+		{ID: "1", Name: "Alice Johnson", Email: "alice@example.com", Skills: []string{"Go", "Kubernetes", "AWS"}, CreatedAt: time.Now()},
+		{ID: "2", Name: "Bob Smith", Email: "bob@example.com", Skills: []string{"Python", "ML", "TensorFlow"}, CreatedAt: time.Now()},
+		{ID: "3", Name: "Carol Williams", Email: "carol@example.com", Skills: []string{"Java", "Spring", "PostgreSQL"}, CreatedAt: time.Now()},
+	}
 }
 
 func candidatesHandler(w http.ResponseWriter, r *http.Request) {
@@ -84,18 +88,37 @@ func candidatesHandler(w http.ResponseWriter, r *http.Request) {
 
 	switch r.Method {
 	case http.MethodGet:
-		json.NewEncoder(w).Encode(Response{Status: "ok", Data: candidates})
+		switch r.URL.Query().Get("format") {
+		case "", "json":
+			page, err := httputil.ParsePage(r.URL.Query())
+			if err != nil {
+				httputil.WriteError(w, http.StatusBadRequest, err.Error())
+				return
+			}
+			httputil.WritePage(w, candidates, page)
+		case "ndjson":
+			// The bulk export: streamed, so neither paged nor capped (see
+			// writeNDJSON)
+			writeNDJSON(w, candidates)
+		default:
+			httputil.WriteError(w, http.StatusBadRequest, "format must be json or ndjson")
+		}
 	case http.MethodPost:
-		var newCandidate Candidate
-		if err := json.NewDecoder(r.Body).Decode(&newCandidate); err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+		var input CandidateInput
+		if err := httputil.DecodeJSON(w, r, &input); err != nil {
+			httputil.WriteDecodeError(w, err)
+			return
+		}
+		newCandidate := input.candidate()
+		warnings, err := applyCandidateLimits(&newCandidate)
+		if err != nil {
+			httputil.WriteError(w, http.StatusUnprocessableEntity, err.Error())
 			return
 		}
 		newCandidate.ID = fmt.Sprintf("%d", len(candidates)+1)
 		newCandidate.CreatedAt = time.Now()
 		candidates = append(candidates, newCandidate)
-		w.WriteHeader(http.StatusCreated)
-		json.NewEncoder(w).Encode(Response{Status: "created", Data: newCandidate})
+		httputil.WriteJSON(w, http.StatusCreated, httputil.Response{Status: "created", Data: newCandidate, Warnings: warnings})
 	default:
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 	}
@@ -107,12 +130,10 @@ func candidateByIDHandler(w http.ResponseWriter, r *http.Request) {
 
 	for _, c := range candidates {
 		if c.ID == id {
-			json.NewEncoder(w).Encode(Response{Status: "ok", Data: c})
+			httputil.WriteJSON(w, http.StatusOK, httputil.Response{Status: "ok", Data: c})
 			return
 		}
 	}
 
-	w.WriteHeader(http.StatusNotFound)
-	json.NewEncoder(w).Encode(Response{Status: "error", Message: "Candidate not found"})
+	httputil.WriteError(w, http.StatusNotFound, "Candidate not found")
 }
-
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/xyz-company/examples/internal/httputil"
+)
+
+func TestInitializeStartsTheAPI(t *testing.T) {
+	withCandidates(t)
+	saved := startup
+	startup = httputil.NewStartupTracker("store")
+	t.Cleanup(func() { startup = saved })
+
+	if startup.Started() {
+		t.Fatal("started before initialize")
+	}
+	initialize()
+	if !startup.Started() {
+		t.Fatalf("still waiting for %s after initialize", startup.Pending())
+	}
+	if w := listCandidates(""); w.Code != http.StatusOK || len(candidates) == 0 {
+		t.Fatalf("after initialize: status %d with %d candidates", w.Code, len(candidates))
+	}
+}
+
+// createCandidate posts body to the candidates endpoint
+func createCandidate(body string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	candidatesHandler(w, httptest.NewRequest(http.MethodPost, "/api/v1/candidates", strings.NewReader(body)))
+	return w
+}
+
+func TestCreateCandidateRejectsServerControlledFields(t *testing.T) {
+	withCandidates(t, manyCandidates(1)...)
+	for _, tt := range []struct{ body, field string }{
+		{`{"name":"Alice","id":"1"}`, `"id"`},
+		{`{"name":"Alice","createdAt":"2020-01-01T00:00:00Z"}`, `"createdAt"`},
+		{`{"name":"Alice","role":"admin"}`, `"role"`},
+	} {
+		w := createCandidate(tt.body)
+		var resp httputil.Response
+		json.Unmarshal(w.Body.Bytes(), &resp)
+		if want := "Unknown field " + tt.field; w.Code != http.StatusBadRequest || resp.Message != want {
+			t.Errorf("%s: %d %q, want a 400 with %q", tt.body, w.Code, resp.Message, want)
+		}
+	}
+	if len(candidates) != 1 {
+		t.Fatalf("rejected candidates stored: %+v", candidates)
+	}
+}
+
+func TestCreateCandidateAssignsServerControlledFields(t *testing.T) {
+	withCandidates(t, manyCandidates(1)...)
+	before := time.Now()
+	w := createCandidate(`{"name":"Alice","email":"alice@example.com","skills":["Go"]}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("status = %d: %s", w.Code, w.Body)
+	}
+	var resp struct {
+		Data Candidate `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	c := resp.Data
+	if c.ID != "2" || c.CreatedAt.Before(before) {
+		t.Fatalf("created candidate ID %q at %s, want ID 2 assigned now", c.ID, c.CreatedAt)
+	}
+	if c.Name != "Alice" || c.Email != "alice@example.com" || len(c.Skills) != 1 {
+		t.Fatalf("created candidate = %+v, want the client fields kept", c)
+	}
+}
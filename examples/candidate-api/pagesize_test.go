@@ -0,0 +1,93 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/xyz-company/examples/internal/httputil"
+)
+
+func TestListCandidatesTruncated(t *testing.T) {
+	saved := httputil.MaxPageSize
+	httputil.MaxPageSize = 3
+	t.Cleanup(func() { httputil.MaxPageSize = saved })
+
+	tests := []struct {
+		candidates int
+		want       int
+		truncated  bool
+	}{
+		{candidates: 2, want: 2},
+		{candidates: 3, want: 3},
+		{candidates: 4, want: 3, truncated: true},
+	}
+	for _, tt := range tests {
+		withCandidates(t, manyCandidates(tt.candidates)...)
+		w := listCandidates("")
+		if w.Code != http.StatusOK {
+			t.Fatalf("status = %d: %s", w.Code, w.Body)
+		}
+		var resp struct {
+			Data      []Candidate `json:"data"`
+			Truncated bool        `json:"truncated"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatal(err)
+		}
+		header := w.Header().Get("X-Result-Truncated") == "true"
+		if len(resp.Data) != tt.want || resp.Truncated != tt.truncated || header != tt.truncated {
+			t.Errorf("%d candidates: %d listed, truncated %v, header %v, want %d, truncated %v",
+				tt.candidates, len(resp.Data), resp.Truncated, header, tt.want, tt.truncated)
+		}
+	}
+}
+
+func TestListCandidatesPaged(t *testing.T) {
+	saved := httputil.MaxPageSize
+	httputil.MaxPageSize = 3
+	t.Cleanup(func() { httputil.MaxPageSize = saved })
+	withCandidates(t, manyCandidates(5)...)
+
+	tests := []struct {
+		query      string
+		first      string
+		n          int
+		nextOffset int
+	}{
+		{query: "?limit=2", first: "1", n: 2, nextOffset: 2},
+		{query: "?offset=2", first: "3", n: 3},
+		{query: "?format=json&offset=1&limit=10", first: "2", n: 3, nextOffset: 4},
+		{query: "?offset=5"},
+	}
+	for _, tt := range tests {
+		w := listCandidates(tt.query)
+		var resp struct {
+			Data       []Candidate `json:"data"`
+			Truncated  bool        `json:"truncated"`
+			NextOffset int         `json:"nextOffset"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatal(err)
+		}
+		if len(resp.Data) != tt.n || resp.NextOffset != tt.nextOffset || resp.Truncated != (tt.nextOffset > 0) {
+			t.Errorf("%s: %d listed, nextOffset %d, truncated %v, want %d, nextOffset %d",
+				tt.query, len(resp.Data), resp.NextOffset, resp.Truncated, tt.n, tt.nextOffset)
+		}
+		if tt.n > 0 && resp.Data[0].ID != tt.first {
+			t.Errorf("%s: first candidate %s, want %s", tt.query, resp.Data[0].ID, tt.first)
+		}
+	}
+
+	for _, query := range []string{"?offset=-1", "?limit=0"} {
+		if w := listCandidates(query); w.Code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want 400", query, w.Code)
+		}
+	}
+
+	// The stream ignores the page parameters
+	if w := listCandidates("?format=ndjson&limit=1"); strings.Count(w.Body.String(), "\n") != 5 {
+		t.Errorf("ndjson with limit=1 streamed %q, want every candidate", w.Body)
+	}
+}
@@ -0,0 +1,36 @@
+// Streaming list responses
+// GET /api/v1/candidates?format=ndjson writes one candidate per line as it
+// goes, so clients can process large lists incrementally
+
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+)
+
+// writeNDJSON writes each candidate as its own JSON line, flushing after
+// every line. Errors past the first byte can only be logged; the status has
+// already been sent.
+//
+// The stream is exempt from MAX_PAGE_SIZE and ignores offset and limit. The
+// ceiling bounds JSON responses, which are encoded as one document; a stream
+// holds one line at a time however long the list, and clients read it as it
+// arrives, so it carries every candidate in one request.
+func writeNDJSON(w http.ResponseWriter, list []Candidate) {
+	w.Header().Set("Content-Type", "application/x-ndjson")
+
+	rc := http.NewResponseController(w)
+	enc := json.NewEncoder(w)
+	for _, c := range list {
+		if err := enc.Encode(c); err != nil {
+			log.Printf("ndjson: stopped after write error: %v", err)
+			return
+		}
+		if err := rc.Flush(); err != nil && err != http.ErrNotSupported {
+			log.Printf("ndjson: stopped after flush error: %v", err)
+			return
+		}
+	}
+}
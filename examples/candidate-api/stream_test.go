@@ -0,0 +1,92 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/xyz-company/examples/internal/httputil"
+)
+
+// manyCandidates returns n candidates with IDs 1 to n
+func manyCandidates(n int) []Candidate {
+	cs := make([]Candidate, n)
+	for i := range cs {
+		id := strconv.Itoa(i + 1)
+		cs[i] = Candidate{ID: id, Name: "Candidate " + id, Skills: []string{"Go"}}
+	}
+	return cs
+}
+
+// listCandidates calls the list endpoint with query
+func listCandidates(query string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	candidatesHandler(w, httptest.NewRequest(http.MethodGet, "/api/v1/candidates"+query, nil))
+	return w
+}
+
+func TestListCandidatesNDJSON(t *testing.T) {
+	// More than a page, which the stream isn't limited to
+	n := httputil.MaxPageSize + 50
+	withCandidates(t, manyCandidates(n)...)
+
+	w := listCandidates("?format=ndjson")
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", w.Code, w.Body)
+	}
+	if got := w.Header().Get("Content-Type"); got != "application/x-ndjson" {
+		t.Fatalf("Content-Type = %q, want application/x-ndjson", got)
+	}
+	if !w.Flushed {
+		t.Fatal("stream never flushed")
+	}
+
+	lines := 0
+	scanner := bufio.NewScanner(w.Body)
+	for scanner.Scan() {
+		var c Candidate
+		if err := json.Unmarshal(scanner.Bytes(), &c); err != nil {
+			t.Fatalf("line %d is not a candidate: %v: %s", lines+1, err, scanner.Bytes())
+		}
+		if want := strconv.Itoa(lines + 1); c.ID != want {
+			t.Fatalf("line %d has candidate %s, want %s", lines+1, c.ID, want)
+		}
+		lines++
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if lines != n {
+		t.Fatalf("streamed %d lines, want %d", lines, n)
+	}
+	if w.Header().Get("X-Result-Truncated") != "" {
+		t.Fatal("stream marked truncated")
+	}
+}
+
+func TestListCandidatesNDJSONEmpty(t *testing.T) {
+	withCandidates(t)
+	w := listCandidates("?format=ndjson")
+	if w.Code != http.StatusOK || w.Body.Len() != 0 {
+		t.Fatalf("status = %d, body %q, want an empty 200", w.Code, w.Body)
+	}
+}
+
+func TestListCandidatesFormat(t *testing.T) {
+	withCandidates(t, manyCandidates(3)...)
+	for _, query := range []string{"", "?format=json"} {
+		w := listCandidates(query)
+		var resp struct {
+			Data []Candidate `json:"data"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil || len(resp.Data) != 3 {
+			t.Fatalf("%q: status %d, body %s, want the 3 candidates as JSON", query, w.Code, w.Body)
+		}
+	}
+	if w := listCandidates("?format=csv"); w.Code != http.StatusBadRequest {
+		t.Fatalf("format=csv: status = %d, want %d", w.Code, http.StatusBadRequest)
+	}
+}
@@ -0,0 +1,3 @@
+module github.com/xyz-company/examples
+
+go 1.21
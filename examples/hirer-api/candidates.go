@@ -0,0 +1,169 @@
+// Candidate API integration
+// The Candidate API's location and response shape are configurable so the
+// match logic can run against third-party candidate sources
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/xyz-company/examples/internal/httputil"
+)
+
+const defaultCandidateAPIURL = "http://candidate-api.candidate.svc.cluster.local/api/v1/candidates"
+
+// CandidateFieldMapping names the JSON keys holding each candidate field in
+// a Candidate API response
+type CandidateFieldMapping struct {
+	// Items is the key of the candidate list in the response; empty when
+	// the response is a bare JSON array
+	Items  string
+	ID     string
+	Name   string
+	Email  string
+	Skills string
+}
+
+// defaultCandidateFieldMapping matches examples/candidate-api
+var defaultCandidateFieldMapping = CandidateFieldMapping{
+	Items:  "data",
+	ID:     "id",
+	Name:   "name",
+	Email:  "email",
+	Skills: "skills",
+}
+
+// candidateFields is loaded from CANDIDATE_FIELD_MAP at startup
+var candidateFields = defaultCandidateFieldMapping
+
+var (
+	// downstreamSlots bounds in-flight Candidate API calls (CANDIDATE_API_MAX_INFLIGHT)
+	downstreamSlots = make(chan struct{}, httputil.EnvInt("CANDIDATE_API_MAX_INFLIGHT", 20))
+	// downstreamRetryAfter is the Retry-After, in seconds, sent when no slot is free
+	downstreamRetryAfter = httputil.EnvInt("CANDIDATE_API_RETRY_AFTER", 1)
+)
+
+// acquireDownstreamSlot takes a Candidate API call slot without waiting. The
+// returned release func must be called once the call has finished.
+func acquireDownstreamSlot() (release func(), ok bool) {
+	select {
+	case downstreamSlots <- struct{}{}:
+		return func() { <-downstreamSlots }, true
+	default:
+		return nil, false
+	}
+}
+
+// candidateAPIEndpoint returns CANDIDATE_API_URL with its path replaced by
+// CANDIDATE_API_PATH when set
+func candidateAPIEndpoint() (string, error) {
+	endpoint := os.Getenv("CANDIDATE_API_URL")
+	if endpoint == "" {
+		endpoint = defaultCandidateAPIURL
+	}
+
+	path := os.Getenv("CANDIDATE_API_PATH")
+	if path == "" {
+		return endpoint, nil
+	}
+
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return "", fmt.Errorf("invalid CANDIDATE_API_URL: %v", err)
+	}
+	u.Path = "/" + strings.TrimPrefix(path, "/")
+	return u.String(), nil
+}
+
+// parseCandidateFieldMapping overrides defaultCandidateFieldMapping with a
+// comma-separated list of field=key pairs, e.g. "items=results,id=candidateId,skills=tags".
+// An empty items key means the response is a bare array.
+func parseCandidateFieldMapping(spec string) (CandidateFieldMapping, error) {
+	mapping := defaultCandidateFieldMapping
+	if strings.TrimSpace(spec) == "" {
+		return mapping, nil
+	}
+
+	for _, pair := range strings.Split(spec, ",") {
+		field, key, ok := strings.Cut(pair, "=")
+		if !ok {
+			return mapping, fmt.Errorf("invalid field mapping %q, expected field=key", pair)
+		}
+		field, key = strings.TrimSpace(field), strings.TrimSpace(key)
+		if key == "" && field != "items" {
+			return mapping, fmt.Errorf("empty key for field %q", field)
+		}
+
+		switch field {
+		case "items":
+			mapping.Items = key
+		case "id":
+			mapping.ID = key
+		case "name":
+			mapping.Name = key
+		case "email":
+			mapping.Email = key
+		case "skills":
+			mapping.Skills = key
+		default:
+			return mapping, fmt.Errorf("unknown candidate field %q", field)
+		}
+	}
+	return mapping, nil
+}
+
+// decodeCandidates extracts candidates from a Candidate API response using mapping
+func decodeCandidates(body []byte, mapping CandidateFieldMapping) ([]Candidate, error) {
+	var records []map[string]interface{}
+	if mapping.Items == "" {
+		if err := json.Unmarshal(body, &records); err != nil {
+			return nil, err
+		}
+	} else {
+		var envelope map[string]json.RawMessage
+		if err := json.Unmarshal(body, &envelope); err != nil {
+			return nil, err
+		}
+		items, ok := envelope[mapping.Items]
+		if !ok {
+			return nil, fmt.Errorf("response has no %q field", mapping.Items)
+		}
+		if err := json.Unmarshal(items, &records); err != nil {
+			return nil, err
+		}
+	}
+
+	candidates := make([]Candidate, 0, len(records))
+	for _, record := range records {
+		c := Candidate{
+			ID:    stringField(record[mapping.ID]),
+			Name:  stringField(record[mapping.Name]),
+			Email: stringField(record[mapping.Email]),
+		}
+		if skills, ok := record[mapping.Skills].([]interface{}); ok {
+			for _, s := range skills {
+				if skill, ok := s.(string); ok {
+					c.Skills = append(c.Skills, skill)
+				}
+			}
+		}
+		candidates = append(candidates, c)
+	}
+	return candidates, nil
+}
+
+// stringField renders JSON strings and numbers (e.g. numeric IDs) as strings
+func stringField(v interface{}) string {
+	switch t := v.(type) {
+	case string:
+		return t
+	case float64:
+		return fmt.Sprintf("%v", t)
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,315 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+// thirdPartyBody is a candidate source with its own schema: candidates
+// under "results", numeric IDs and skills as "tags"
+const thirdPartyBody = `{"results":[
+	{"candidateId":7,"fullName":"Alice","contact":"alice@example.com","tags":["golang","k8s"]},
+	{"candidateId":"x-9","fullName":"Bob","tags":["Python",3,"AWS"]},
+	{"fullName":"Carol"}
+]}`
+
+const thirdPartyMapping = "items=results,id=candidateId,name=fullName,email=contact,skills=tags"
+
+func TestParseCandidateFieldMapping(t *testing.T) {
+	tests := []struct {
+		spec    string
+		want    CandidateFieldMapping
+		wantErr bool
+	}{
+		{spec: "", want: defaultCandidateFieldMapping},
+		{spec: "  ", want: defaultCandidateFieldMapping},
+		{
+			spec: thirdPartyMapping,
+			want: CandidateFieldMapping{Items: "results", ID: "candidateId", Name: "fullName", Email: "contact", Skills: "tags"},
+		},
+		{
+			spec: " id = uid , skills = tags ",
+			want: CandidateFieldMapping{Items: "data", ID: "uid", Name: "name", Email: "email", Skills: "tags"},
+		},
+		{
+			spec: "items=",
+			want: CandidateFieldMapping{Items: "", ID: "id", Name: "name", Email: "email", Skills: "skills"},
+		},
+		{spec: "id", wantErr: true},
+		{spec: "id=", wantErr: true},
+		{spec: "phone=tel", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			got, err := parseCandidateFieldMapping(tt.spec)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseCandidateFieldMapping(%q) error = %v, wantErr %v", tt.spec, err, tt.wantErr)
+			}
+			if !tt.wantErr && got != tt.want {
+				t.Fatalf("parseCandidateFieldMapping(%q) = %+v, want %+v", tt.spec, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecodeCandidatesRemapped(t *testing.T) {
+	mapping, err := parseCandidateFieldMapping(thirdPartyMapping)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := decodeCandidates([]byte(thirdPartyBody), mapping)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Candidate{
+		{ID: "7", Name: "Alice", Email: "alice@example.com", Skills: []string{"golang", "k8s"}},
+		{ID: "x-9", Name: "Bob", Skills: []string{"Python", "AWS"}},
+		{Name: "Carol"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("decodeCandidates() = %+v, want %+v", got, want)
+	}
+}
+
+func TestDecodeCandidatesBareArray(t *testing.T) {
+	mapping, err := parseCandidateFieldMapping("items=,id=uid")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := decodeCandidates([]byte(`[{"uid":"1","name":"Alice","skills":["Go"]}]`), mapping)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []Candidate{{ID: "1", Name: "Alice", Skills: []string{"Go"}}}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("decodeCandidates() = %+v, want %+v", got, want)
+	}
+}
+
+func TestDecodeCandidatesRejectsWrongShape(t *testing.T) {
+	for _, body := range []string{`{"data":[]`, `{"items":[]}`, `{"data":{"id":"1"}}`, `[{"id":"1"}]`} {
+		if _, err := decodeCandidates([]byte(body), defaultCandidateFieldMapping); err == nil {
+			t.Errorf("decodeCandidates(%s) succeeded, want an error", body)
+		}
+	}
+}
+
+func TestCandidateAPIEndpoint(t *testing.T) {
+	tests := []struct {
+		url, path string
+		want      string
+		wantErr   bool
+	}{
+		{want: defaultCandidateAPIURL},
+		{url: "http://candidates.example.com/api/v1/candidates", want: "http://candidates.example.com/api/v1/candidates"},
+		{url: "http://candidates.example.com/api/v1/candidates", path: "/v2/people", want: "http://candidates.example.com/v2/people"},
+		{url: "http://candidates.example.com", path: "v2/people", want: "http://candidates.example.com/v2/people"},
+		{path: "/v2/people", want: "http://candidate-api.candidate.svc.cluster.local/v2/people"},
+		{url: "http://[::1", path: "/v2/people", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.url+"|"+tt.path, func(t *testing.T) {
+			t.Setenv("CANDIDATE_API_URL", tt.url)
+			t.Setenv("CANDIDATE_API_PATH", tt.path)
+			got, err := candidateAPIEndpoint()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("candidateAPIEndpoint() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Fatalf("candidateAPIEndpoint() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMatchRemappedCandidateAPI(t *testing.T) {
+	withJobs(t, Job{ID: "1", Skills: []string{"Go", "Kubernetes"}})
+	mapping, err := parseCandidateFieldMapping(thirdPartyMapping)
+	if err != nil {
+		t.Fatal(err)
+	}
+	saved := candidateFields
+	candidateFields = mapping
+	t.Cleanup(func() { candidateFields = saved })
+
+	var path string
+	candidateAPI(t, func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		w.Write([]byte(thirdPartyBody))
+	})
+	t.Setenv("CANDIDATE_API_PATH", "/v2/people")
+
+	w, matches := getMatches(t, "jobId=1")
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", w.Code, w.Body)
+	}
+	if path != "/v2/people" {
+		t.Errorf("Candidate API called on %s, want /v2/people", path)
+	}
+	if len(matches) != 1 || matches[0].ID != "7" || matches[0].Name != "Alice" || matches[0].Score != 1 {
+		t.Fatalf("matches = %+v, want Alice (7) scoring 1", matches)
+	}
+}
+
+func TestMatchRejectsUnexpectedSchema(t *testing.T) {
+	withJobs(t, Job{ID: "1", Skills: []string{"Go"}})
+	candidateAPI(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(thirdPartyBody))
+	})
+
+	if w, _ := getMatches(t, "jobId=1"); w.Code != http.StatusBadGateway {
+		t.Fatalf("status = %d, want %d for a schema the mapping doesn't fit", w.Code, http.StatusBadGateway)
+	}
+}
+
+// withDownstreamSlots replaces the Candidate API call slots with n for the
+// duration of the test
+func withDownstreamSlots(t *testing.T, n int) {
+	t.Helper()
+	saved := downstreamSlots
+	downstreamSlots = make(chan struct{}, n)
+	t.Cleanup(func() { downstreamSlots = saved })
+}
+
+// blockingCandidateAPI is a Candidate API holding every request until
+// release is closed, tracking how many it holds at once
+type blockingCandidateAPI struct {
+	release chan struct{}
+	arrived chan struct{}
+
+	mu       sync.Mutex
+	inFlight int
+	peak     int
+}
+
+func newBlockingCandidateAPI(t *testing.T) *blockingCandidateAPI {
+	api := &blockingCandidateAPI{release: make(chan struct{}), arrived: make(chan struct{}, 100)}
+	candidateAPI(t, func(w http.ResponseWriter, r *http.Request) {
+		api.mu.Lock()
+		api.inFlight++
+		if api.inFlight > api.peak {
+			api.peak = api.inFlight
+		}
+		api.mu.Unlock()
+		api.arrived <- struct{}{}
+
+		<-api.release
+		api.mu.Lock()
+		api.inFlight--
+		api.mu.Unlock()
+		serveCandidates(testCandidates)(w, r)
+	})
+	return api
+}
+
+// waitArrivals waits for n requests to reach the Candidate API
+func (api *blockingCandidateAPI) waitArrivals(t *testing.T, n int) {
+	t.Helper()
+	for i := 0; i < n; i++ {
+		select {
+		case <-api.arrived:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("%d of %d requests reached the Candidate API", i, n)
+		}
+	}
+}
+
+func TestDownstreamSlotsShedLoad(t *testing.T) {
+	withJobs(t, testJob)
+	withDownstreamSlots(t, 2)
+	api := newBlockingCandidateAPI(t)
+
+	// Distinct limits, so the requests aren't coalesced into one call
+	var wg sync.WaitGroup
+	codes := make([]int, 2)
+	for i := range codes {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			w, _ := getMatches(t, fmt.Sprintf("jobId=1&limit=%d", i+1))
+			codes[i] = w.Code
+		}(i)
+	}
+	api.waitArrivals(t, 2)
+
+	// Both slots are taken: further requests are refused without waiting
+	for i := 0; i < 5; i++ {
+		w, _ := getMatches(t, fmt.Sprintf("jobId=1&limit=%d", i+10))
+		if w.Code != http.StatusServiceUnavailable {
+			t.Fatalf("request over the cap: status = %d, want %d", w.Code, http.StatusServiceUnavailable)
+		}
+		if got := w.Header().Get("Retry-After"); got != strconv.Itoa(downstreamRetryAfter) {
+			t.Fatalf("Retry-After = %q, want %d", got, downstreamRetryAfter)
+		}
+	}
+
+	close(api.release)
+	wg.Wait()
+	for i, code := range codes {
+		if code != http.StatusOK {
+			t.Errorf("request %d holding a slot: status = %d, want %d", i, code, http.StatusOK)
+		}
+	}
+	if api.peak != 2 {
+		t.Errorf("peak Candidate API concurrency = %d, want 2", api.peak)
+	}
+
+	// The slots are free again
+	if w, _ := getMatches(t, "jobId=1&limit=99"); w.Code != http.StatusOK {
+		t.Fatalf("after release: status = %d, want %d", w.Code, http.StatusOK)
+	}
+}
+
+func TestDownstreamSlotsUnderConcurrentLoad(t *testing.T) {
+	withJobs(t, testJob)
+	withDownstreamSlots(t, 3)
+	api := newBlockingCandidateAPI(t)
+	close(api.release)
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	codes := map[int]int{}
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			w, _ := getMatches(t, fmt.Sprintf("jobId=1&limit=%d", i+1))
+			mu.Lock()
+			codes[w.Code]++
+			mu.Unlock()
+		}(i)
+	}
+	wg.Wait()
+
+	if api.peak > 3 {
+		t.Fatalf("peak Candidate API concurrency = %d, want at most 3", api.peak)
+	}
+	if codes[http.StatusOK]+codes[http.StatusServiceUnavailable] != 50 || codes[http.StatusOK] == 0 {
+		t.Fatalf("status counts = %v, want only 200s and 503s", codes)
+	}
+	if len(downstreamSlots) != 0 {
+		t.Fatalf("%d slots still held after every request finished", len(downstreamSlots))
+	}
+}
+
+func TestDownstreamSlotReleasedOnError(t *testing.T) {
+	withJobs(t, testJob)
+	withDownstreamSlots(t, 1)
+	server := httptest.NewServer(http.NotFoundHandler())
+	server.Close()
+	t.Setenv("CANDIDATE_API_URL", server.URL)
+
+	for i := 0; i < 3; i++ {
+		if w, _ := getMatches(t, "jobId=1"); w.Code != http.StatusServiceUnavailable || w.Header().Get("Retry-After") != "" {
+			t.Fatalf("unreachable Candidate API: status = %d, Retry-After %q", w.Code, w.Header().Get("Retry-After"))
+		}
+	}
+	if len(downstreamSlots) != 0 {
+		t.Fatal("slot still held after a failed call")
+	}
+}
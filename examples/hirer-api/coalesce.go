@@ -0,0 +1,64 @@
+// Match request coalescing
+// Concurrent identical match requests share one Candidate API call and one
+// ranking, so a refresh storm on a dashboard costs a single downstream fetch
+
+package main
+
+import (
+	"log"
+	"net/http"
+	"runtime/debug"
+	"sync"
+)
+
+// flightGroup runs one call per key at a time; callers arriving while it is
+// in flight wait for and share its result. Nothing is kept once the call
+// returns, so errors (and results) are never served to later requests.
+type flightGroup struct {
+	mu    sync.Mutex
+	calls map[string]*flightCall
+}
+
+type flightCall struct {
+	done   chan struct{}
+	result matchResult
+	err    error
+}
+
+// matchFlights coalesces matchCandidatesHandler computations
+var matchFlights = &flightGroup{}
+
+// Do runs fn for key unless a call for key is already in flight, in which
+// case it waits for that call. shared reports whether the result came from
+// another caller's call. A panic in fn is returned as an error to every
+// caller, so none of them mistakes its zero result for success.
+func (g *flightGroup) Do(key string, fn func() (matchResult, error)) (result matchResult, err error, shared bool) {
+	g.mu.Lock()
+	if g.calls == nil {
+		g.calls = map[string]*flightCall{}
+	}
+	if call, ok := g.calls[key]; ok {
+		g.mu.Unlock()
+		<-call.done
+		return call.result, call.err, true
+	}
+	call := &flightCall{done: make(chan struct{})}
+	g.calls[key] = call
+	g.mu.Unlock()
+
+	defer func() {
+		if p := recover(); p != nil {
+			log.Printf("match computation panicked: %v\n%s", p, debug.Stack())
+			// The panic value is for the log, not the clients
+			call.result, call.err = matchResult{}, &matchError{Status: http.StatusInternalServerError, Message: "Internal server error"}
+			result, err = call.result, call.err
+		}
+		g.mu.Lock()
+		delete(g.calls, key)
+		g.mu.Unlock()
+		close(call.done)
+	}()
+
+	call.result, call.err = fn()
+	return call.result, call.err, false
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestConcurrentIdenticalMatchesShareOneCall(t *testing.T) {
+	withJobs(t, testJob)
+	api := newBlockingCandidateAPI(t)
+
+	const n = 20
+	var wg sync.WaitGroup
+	bodies := make([]string, n)
+	codes := make([]int, n)
+	request := func(i int) {
+		defer wg.Done()
+		w, _ := getMatches(t, "jobId=1&limit=3")
+		codes[i], bodies[i] = w.Code, w.Body.String()
+	}
+	wg.Add(1)
+	go request(0)
+	api.waitArrivals(t, 1)
+
+	// The rest arrive while the first call is held in the Candidate API; give
+	// them time to join it before it returns
+	for i := 1; i < n; i++ {
+		wg.Add(1)
+		go request(i)
+	}
+	time.Sleep(100 * time.Millisecond)
+	close(api.release)
+	wg.Wait()
+
+	if api.peak != 1 || len(api.arrived) != 0 {
+		t.Fatalf("%d Candidate API calls, want 1", 1+len(api.arrived))
+	}
+	for i := range codes {
+		if codes[i] != http.StatusOK || bodies[i] != bodies[0] {
+			t.Fatalf("request %d: status %d, body %s, want the shared 200 %s", i, codes[i], bodies[i], bodies[0])
+		}
+	}
+}
+
+func TestMatchErrorsAreNotCached(t *testing.T) {
+	withJobs(t, testJob)
+	fail := true
+	calls := candidateAPI(t, func(w http.ResponseWriter, r *http.Request) {
+		if fail {
+			http.Error(w, "overloaded", http.StatusInternalServerError)
+			return
+		}
+		serveCandidates(testCandidates)(w, r)
+	})
+
+	if w, _ := getMatches(t, "jobId=1"); w.Code == http.StatusOK {
+		t.Fatal("failed Candidate API call answered 200")
+	}
+	fail = false
+	if w, _ := getMatches(t, "jobId=1"); w.Code != http.StatusOK {
+		t.Fatalf("status after the Candidate API recovered = %d, want %d", w.Code, http.StatusOK)
+	}
+	if got := calls(); got != 2 {
+		t.Fatalf("%d Candidate API calls, want 2", got)
+	}
+}
+
+func TestFlightGroupKeysAreIndependent(t *testing.T) {
+	g := &flightGroup{}
+	release := make(chan struct{})
+	started := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		g.Do("a", func() (matchResult, error) {
+			close(started)
+			<-release
+			return matchResult{Body: []byte("a")}, nil
+		})
+	}()
+	<-started
+
+	// b runs while a is held
+	result, err, shared := g.Do("b", func() (matchResult, error) {
+		return matchResult{Body: []byte("b")}, nil
+	})
+	if err != nil || string(result.Body) != "b" || shared {
+		t.Fatalf("Do(b) = %q, %v, shared %v, want its own result", result.Body, err, shared)
+	}
+	close(release)
+	<-done
+
+	// Nothing is kept once a call returns
+	result, _, shared = g.Do("a", func() (matchResult, error) {
+		return matchResult{Body: []byte("a again")}, nil
+	})
+	if string(result.Body) != "a again" || shared {
+		t.Fatalf("Do(a) after the first call returned = %q, shared %v, want a new call", result.Body, shared)
+	}
+}
+
+func TestFlightGroupPanicIsAnError(t *testing.T) {
+	g := &flightGroup{}
+	release := make(chan struct{})
+	started := make(chan struct{})
+
+	var wg sync.WaitGroup
+	errs := make([]error, 2)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		_, errs[0], _ = g.Do("a", func() (matchResult, error) {
+			close(started)
+			<-release
+			panic("boom")
+		})
+	}()
+	<-started
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		_, errs[1], _ = g.Do("a", func() (matchResult, error) {
+			t.Error("second caller ran its own call while the first was in flight")
+			return matchResult{}, nil
+		})
+	}()
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	for i, err := range errs {
+		var me *matchError
+		if !errors.As(err, &me) || me.Status != http.StatusInternalServerError || me.Message != "Internal server error" {
+			t.Errorf("caller %d got %v, want an internal server error", i, err)
+		}
+	}
+
+	// The group is usable again
+	if _, err, _ := g.Do("a", func() (matchResult, error) { return matchResult{}, nil }); err != nil {
+		t.Fatalf("Do(a) after the panic = %v", err)
+	}
+}
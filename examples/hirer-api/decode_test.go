@@ -0,0 +1,127 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/xyz-company/examples/internal/httputil"
+)
+
+// decodeSeeds are the inputs every decode fuzz target starts from:
+// well-formed bodies and the malformed ones seen in the wild
+func decodeSeeds(valid ...string) [][]byte {
+	seeds := [][]byte{
+		// Truncated
+		[]byte(``),
+		[]byte(`{`),
+		[]byte(`{"title":"Go`),
+		[]byte(`{"skills":["Go",`),
+		// Deeply nested
+		[]byte(strings.Repeat(`[`, 10000) + strings.Repeat(`]`, 10000)),
+		[]byte(strings.Repeat(`{"a":`, 5000) + `1` + strings.Repeat(`}`, 5000)),
+		[]byte(`{"skills":` + strings.Repeat(`[`, 1000) + strings.Repeat(`]`, 1000) + `}`),
+		// Not UTF-8
+		[]byte("{\"title\":\"\xff\xfe\"}"),
+		[]byte("{\"company\":\"\xc3\x28\",\"skills\":[\"\xed\xa0\x80\"]}"),
+		[]byte("\xef\xbb\xbf{}"),
+		[]byte("\x00\x01\x02"),
+		// Valid JSON the decoder must still refuse
+		[]byte(`{} {}`),
+		[]byte(`{"name":"Alice"}`),
+		[]byte(`{"skills":"Go"}`),
+		[]byte(`{"id":"1"}`),
+		[]byte(`{"createdAt":"2020-01-01T00:00:00Z"}`),
+		[]byte(`{"title":1e999}`),
+		[]byte(`[]`),
+		// Valid JSON the field limits must refuse
+		[]byte(`{"title":"` + strings.Repeat("t", httputil.TextLimit.Hard+1) + `"}`),
+		[]byte(`{"skills":[` + strings.Repeat(`"Go",`, httputil.MaxSkills) + `"Go"]}`),
+	}
+	for _, v := range valid {
+		seeds = append(seeds, []byte(v))
+	}
+	return seeds
+}
+
+// checkDecodeError fails unless err is a clean 4xx DecodeError with a message
+func checkDecodeError(t *testing.T, body []byte, err error) {
+	t.Helper()
+	var de *httputil.DecodeError
+	if !errors.As(err, &de) {
+		t.Fatalf("decoding %q returned %T %v, want a *httputil.DecodeError", body, err, err)
+	}
+	if de.Status < 400 || de.Status > 499 {
+		t.Fatalf("decoding %q returned status %d, want a 4xx", body, de.Status)
+	}
+	if de.Message == "" {
+		t.Fatalf("decoding %q returned an error without a message", body)
+	}
+}
+
+func FuzzDecodeJob(f *testing.F) {
+	for _, seed := range decodeSeeds(
+		`{"title":"Senior Backend Engineer","company":"TechCorp","description":"Building scalable systems","skills":["Go","Kubernetes"]}`,
+		`{"title":"","skills":[]}`,
+		`null`,
+	) {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, body []byte) {
+		var input JobInput
+		err := httputil.DecodeJSONBody(bytes.NewReader(body), &input)
+		if err != nil {
+			checkDecodeError(t, body, err)
+			return
+		}
+		if !json.Valid(body) {
+			t.Fatalf("decoded invalid JSON %q without an error", body)
+		}
+		job := input.job()
+		if _, err := applyJobLimits(&job); err != nil {
+			return
+		}
+		if n := utf8.RuneCountInString(job.Title); n > httputil.TextLimit.Soft {
+			t.Fatalf("title of %d characters passed the limits for %q", n, body)
+		}
+		if n := utf8.RuneCountInString(job.Description); n > descriptionLimit.Soft {
+			t.Fatalf("description of %d characters passed the limits for %q", n, body)
+		}
+		if len(job.Skills) > httputil.MaxSkills {
+			t.Fatalf("%d skills passed the limits for %q", len(job.Skills), body)
+		}
+	})
+}
+
+func FuzzCreateJob(f *testing.F) {
+	for _, seed := range decodeSeeds(
+		`{"title":"Senior Backend Engineer","company":"TechCorp","description":"Building scalable systems","skills":["Go","Kubernetes"]}`,
+	) {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, body []byte) {
+		saved := jobs
+		defer func() { jobs = saved }()
+
+		w := httptest.NewRecorder()
+		jobsHandler(w, httptest.NewRequest(http.MethodPost, "/api/v1/jobs", bytes.NewReader(body)))
+
+		switch w.Code {
+		case http.StatusCreated, http.StatusBadRequest, http.StatusRequestEntityTooLarge, http.StatusUnprocessableEntity:
+		default:
+			t.Fatalf("POST %q returned %d, want 201, 400, 413 or 422", body, w.Code)
+		}
+		var resp httputil.Response
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("POST %q replied with a body that is not JSON: %v", body, err)
+		}
+		if w.Code != http.StatusCreated && resp.Message == "" {
+			t.Fatalf("POST %q returned %d without a message", body, w.Code)
+		}
+	})
+}
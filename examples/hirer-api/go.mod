@@ -1,4 +0,0 @@
-module github.com/xyz-company/hirer-api
-
-go 1.21
-
@@ -0,0 +1,73 @@
+// Company integrations
+// Mirrors the tenant AllowedIntegrations allowlist per company so the UI can
+// show which cross-domain features a job's company has enabled
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/xyz-company/examples/internal/httputil"
+)
+
+// JobIntegrations is the integration allowlist for a job's company
+type JobIntegrations struct {
+	JobID        string   `json:"jobId"`
+	Company      string   `json:"company"`
+	Integrations []string `json:"integrations"`
+}
+
+// companyIntegrations maps company names to their allowed integrations,
+// loaded at startup by loadCompanyIntegrations
+var companyIntegrations = map[string][]string{}
+
+// loadCompanyIntegrations reads a JSON object of company -> integrations from
+// the file named by COMPANY_INTEGRATIONS_FILE, or inline from
+// COMPANY_INTEGRATIONS. Neither being set yields an empty mapping.
+func loadCompanyIntegrations() (map[string][]string, error) {
+	raw := []byte(os.Getenv("COMPANY_INTEGRATIONS"))
+	if path := os.Getenv("COMPANY_INTEGRATIONS_FILE"); path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		raw = data
+	}
+
+	mapping := map[string][]string{}
+	if len(raw) == 0 {
+		return mapping, nil
+	}
+	if err := json.Unmarshal(raw, &mapping); err != nil {
+		return nil, fmt.Errorf("expected a JSON object of company to integration list: %v", err)
+	}
+	return mapping, nil
+}
+
+// jobIntegrationsHandler serves GET /api/v1/jobs/{id}/integrations
+func jobIntegrationsHandler(w http.ResponseWriter, r *http.Request, id string) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	for _, j := range jobs {
+		if j.ID == id {
+			integrations := companyIntegrations[j.Company]
+			if integrations == nil {
+				integrations = []string{}
+			}
+			httputil.WriteJSON(w, http.StatusOK, httputil.Response{Status: "ok", Data: JobIntegrations{
+				JobID:        j.ID,
+				Company:      j.Company,
+				Integrations: integrations,
+			}})
+			return
+		}
+	}
+
+	httputil.WriteError(w, http.StatusNotFound, "Job not found")
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// withCompanyIntegrations replaces the company integrations for the
+// duration of the test
+func withCompanyIntegrations(t *testing.T, mapping map[string][]string) {
+	t.Helper()
+	saved := companyIntegrations
+	companyIntegrations = mapping
+	t.Cleanup(func() { companyIntegrations = saved })
+}
+
+// getIntegrations serves GET /api/v1/jobs/{id}/integrations
+func getIntegrations(t *testing.T, id string) (*httptest.ResponseRecorder, JobIntegrations) {
+	t.Helper()
+	w := httptest.NewRecorder()
+	jobByIDHandler(w, httptest.NewRequest(http.MethodGet, "/api/v1/jobs/"+id+"/integrations", nil))
+	var resp struct {
+		Data JobIntegrations `json:"data"`
+	}
+	if w.Code == http.StatusOK {
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("decoding %s: %v", w.Body, err)
+		}
+	}
+	return w, resp.Data
+}
+
+func TestJobIntegrations(t *testing.T) {
+	withJobs(t,
+		Job{ID: "1", Company: "TechCorp"},
+		Job{ID: "2", Company: "AIStartup"},
+		Job{ID: "3", Company: "CloudInc"},
+	)
+	withCompanyIntegrations(t, map[string][]string{
+		"TechCorp":  {"candidate", "analytics"},
+		"AIStartup": {},
+	})
+
+	tests := []struct {
+		id   string
+		want JobIntegrations
+	}{
+		{"1", JobIntegrations{JobID: "1", Company: "TechCorp", Integrations: []string{"candidate", "analytics"}}},
+		{"2", JobIntegrations{JobID: "2", Company: "AIStartup", Integrations: []string{}}},
+		// Not configured at all
+		{"3", JobIntegrations{JobID: "3", Company: "CloudInc", Integrations: []string{}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.want.Company, func(t *testing.T) {
+			w, got := getIntegrations(t, tt.id)
+			if w.Code != http.StatusOK {
+				t.Fatalf("status = %d: %s", w.Code, w.Body)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("integrations = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+
+	// An unconfigured company gets [], never null
+	w, _ := getIntegrations(t, "3")
+	var raw struct {
+		Data map[string]json.RawMessage `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &raw); err != nil {
+		t.Fatal(err)
+	}
+	if got := string(raw.Data["integrations"]); got != "[]" {
+		t.Fatalf("integrations encoded as %s, want []", got)
+	}
+}
+
+func TestJobIntegrationsErrors(t *testing.T) {
+	withJobs(t, Job{ID: "1", Company: "TechCorp"})
+	withCompanyIntegrations(t, map[string][]string{})
+
+	if w, _ := getIntegrations(t, "42"); w.Code != http.StatusNotFound {
+		t.Errorf("unknown job: status = %d, want %d", w.Code, http.StatusNotFound)
+	}
+	w := httptest.NewRecorder()
+	jobByIDHandler(w, httptest.NewRequest(http.MethodPost, "/api/v1/jobs/1/integrations", nil))
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("POST: status = %d, want %d", w.Code, http.StatusMethodNotAllowed)
+	}
+}
+
+func TestLoadCompanyIntegrations(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "integrations.json")
+	if err := os.WriteFile(file, []byte(`{"CloudInc":["candidate"]}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name         string
+		inline, path string
+		want         map[string][]string
+		wantErr      bool
+	}{
+		{name: "unset", want: map[string][]string{}},
+		{name: "inline", inline: `{"TechCorp":["candidate","analytics"]}`, want: map[string][]string{"TechCorp": {"candidate", "analytics"}}},
+		{name: "file wins over inline", inline: `{"TechCorp":["candidate"]}`, path: file, want: map[string][]string{"CloudInc": {"candidate"}}},
+		{name: "missing file", path: filepath.Join(t.TempDir(), "missing.json"), wantErr: true},
+		{name: "not an object", inline: `["candidate"]`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("COMPANY_INTEGRATIONS", tt.inline)
+			t.Setenv("COMPANY_INTEGRATIONS_FILE", tt.path)
+			got, err := loadCompanyIntegrations()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("loadCompanyIntegrations() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("loadCompanyIntegrations() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,28 @@
+// Field length limits
+// Job fields are checked against the shared httputil limits, and the
+// description against its own, longer one
+
+package main
+
+import "github.com/xyz-company/examples/internal/httputil"
+
+// descriptionLimit applies to long free-text fields
+var descriptionLimit = httputil.FieldLimitFromEnv("DESCRIPTION", 10000, 50000)
+
+// applyJobLimits enforces field limits on a job, returning any truncation warnings
+func applyJobLimits(job *Job) ([]string, error) {
+	var warnings []string
+	if err := httputil.TextLimit.Apply("title", &job.Title, &warnings); err != nil {
+		return nil, err
+	}
+	if err := httputil.TextLimit.Apply("company", &job.Company, &warnings); err != nil {
+		return nil, err
+	}
+	if err := descriptionLimit.Apply("description", &job.Description, &warnings); err != nil {
+		return nil, err
+	}
+	if err := httputil.ApplySkillLimits(job.Skills, &warnings); err != nil {
+		return nil, err
+	}
+	return warnings, nil
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/xyz-company/examples/internal/httputil"
+)
+
+func TestApplyJobLimitsBoundaries(t *testing.T) {
+	text, desc := httputil.TextLimit, descriptionLimit
+	tests := []struct {
+		name     string
+		job      Job
+		warnings int
+		wantErr  string
+	}{
+		{name: "within every limit", job: Job{Title: "Go", Description: "Short", Skills: []string{"Go"}}},
+		{name: "title at the soft limit", job: Job{Title: strings.Repeat("t", text.Soft)}},
+		{name: "title over the soft limit", job: Job{Title: strings.Repeat("t", text.Soft+1)}, warnings: 1},
+		{name: "title at the hard limit", job: Job{Title: strings.Repeat("t", text.Hard)}, warnings: 1},
+		{name: "title over the hard limit", job: Job{Title: strings.Repeat("t", text.Hard+1)}, wantErr: "title is 1001 characters, maximum is 1000"},
+		{name: "company over the hard limit", job: Job{Company: strings.Repeat("c", text.Hard+1)}, wantErr: "company is"},
+		{name: "description at the soft limit", job: Job{Description: strings.Repeat("d", desc.Soft)}},
+		{name: "description over the soft limit", job: Job{Description: strings.Repeat("d", desc.Soft+1)}, warnings: 1},
+		{name: "description over the hard limit", job: Job{Description: strings.Repeat("d", desc.Hard+1)}, wantErr: "description is 50001 characters, maximum is 50000"},
+		// Limits count characters, not bytes
+		{name: "multibyte title at the soft limit", job: Job{Title: strings.Repeat("é", text.Soft)}},
+		{name: "long skill", job: Job{Skills: []string{"Go", strings.Repeat("s", text.Soft+1)}}, warnings: 1},
+		{name: "skill over the hard limit", job: Job{Skills: []string{strings.Repeat("s", text.Hard+1)}}, wantErr: "skills[0] is"},
+		{name: "skills at the maximum", job: Job{Skills: make([]string, httputil.MaxSkills)}},
+		{name: "too many skills", job: Job{Skills: make([]string, httputil.MaxSkills+1)}, wantErr: "skills has 51 entries, maximum is 50"},
+		{name: "every field truncated", job: Job{
+			Title:       strings.Repeat("t", text.Soft+1),
+			Company:     strings.Repeat("c", text.Soft+1),
+			Description: strings.Repeat("d", desc.Soft+1),
+		}, warnings: 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			job := tt.job
+			warnings, err := applyJobLimits(&job)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("applyJobLimits() = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(warnings) != tt.warnings {
+				t.Fatalf("warnings = %q, want %d", warnings, tt.warnings)
+			}
+			if utf8.RuneCountInString(job.Title) > text.Soft || utf8.RuneCountInString(job.Company) > text.Soft ||
+				utf8.RuneCountInString(job.Description) > desc.Soft {
+				t.Fatalf("%+v left over a soft limit", job)
+			}
+		})
+	}
+}
+
+func TestCreateJobTruncatesWithWarning(t *testing.T) {
+	withJobs(t)
+	body, _ := json.Marshal(JobInput{Title: strings.Repeat("é", httputil.TextLimit.Soft+5), Skills: []string{"Go"}})
+
+	w := httptest.NewRecorder()
+	jobsHandler(w, httptest.NewRequest(http.MethodPost, "/api/v1/jobs", strings.NewReader(string(body))))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("status = %d: %s", w.Code, w.Body)
+	}
+	var resp struct {
+		Data     Job      `json:"data"`
+		Warnings []string `json:"warnings"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Data.Title != strings.Repeat("é", httputil.TextLimit.Soft) {
+		t.Errorf("title stored with %d characters, want %d", utf8.RuneCountInString(resp.Data.Title), httputil.TextLimit.Soft)
+	}
+	if want := "title truncated from 205 to 200 characters"; len(resp.Warnings) != 1 || resp.Warnings[0] != want {
+		t.Errorf("warnings = %q, want [%q]", resp.Warnings, want)
+	}
+	if len(jobs) != 1 || jobs[0].Title != resp.Data.Title {
+		t.Errorf("stored jobs = %+v, want the truncated job", jobs)
+	}
+}
+
+func TestCreateJobRejectsOverHardLimit(t *testing.T) {
+	withJobs(t)
+	body, _ := json.Marshal(JobInput{Title: "Go", Description: strings.Repeat("d", descriptionLimit.Hard+1)})
+
+	w := httptest.NewRecorder()
+	jobsHandler(w, httptest.NewRequest(http.MethodPost, "/api/v1/jobs", strings.NewReader(string(body))))
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusUnprocessableEntity)
+	}
+	if len(jobs) != 0 {
+		t.Fatalf("rejected job stored: %+v", jobs)
+	}
+}
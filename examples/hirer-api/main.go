@@ -4,13 +4,14 @@
 package main
 
 import (
-	"encoding/json"
 	"fmt"
-	"io"
 	"log"
 	"net/http"
 	"os"
+	"strings"
 	"time"
+
+	"github.com/xyz-company/examples/internal/httputil"
 )
 
 // Job represents a job posting
@@ -23,19 +24,25 @@ type Job struct {
 	CreatedAt   time.Time `json:"createdAt"`
 }
 
-// Response is a generic API response
-type Response struct {
-	Status  string      `json:"status"`
-	Data    interface{} `json:"data,omitempty"`
-	Message string      `json:"message,omitempty"`
+// JobInput holds the Job fields clients may set on create. ID and CreatedAt
+// are server-controlled; httputil.DecodeJSON rejects them as unknown fields.
+type JobInput struct {
+	Title       string   `json:"title"`
+	Company     string   `json:"company"`
+	Description string   `json:"description"`
+	Skills      []string `json:"skills"`
 }
 
-var jobs = []Job{
-	{ID: "1", Title: "Senior Backend Engineer", Company: "TechCorp", Description: "Building scalable systems", Skills: []string{"Go", "Kubernetes"}, CreatedAt: time.Now()},
-	{ID: "2", Title: "ML Engineer", Company: "AIStartup", Description: "Developing ML models", Skills: []string{"Python", "TensorFlow"}, CreatedAt: time.Now()},
-	{ID: "3", Title: "Platform Engineer", Company: "CloudInc", Description: "Building internal platform", Skills: []string{"Kubernetes", "Terraform"}, CreatedAt: time.Now()},
+// job maps the input to a new Job
+func (in JobInput) job() Job {
+	return Job{Title: in.Title, Company: in.Company, Description: in.Description, Skills: in.Skills}
 }
 
+var jobs []Job
+
+// startup tracks initialization; API requests are refused until it completes
+var startup = httputil.NewStartupTracker("config", "store")
+
 func main() {
 	port := os.Getenv("PORT")
 	if port == "" {
@@ -43,43 +50,54 @@ func main() {
 	}
 
 	// Routes
-	http.HandleFunc("/", homeHandler)
-	http.HandleFunc("/health", healthHandler)
-	http.HandleFunc("/ready", readyHandler)
+	http.HandleFunc("/", httputil.HomeHandler("Hirer API v1.0.0 - XYZ Platform"))
+	http.HandleFunc("/health", httputil.HealthHandler)
+	http.HandleFunc("/ready", httputil.ReadyHandler(startup.Started))
+	http.HandleFunc("/startupz", startup.Handler)
 	http.HandleFunc("/api/v1/jobs", jobsHandler)
 	http.HandleFunc("/api/v1/jobs/", jobByIDHandler)
 	http.HandleFunc("/api/v1/match", matchCandidatesHandler)
 
+	handler := httputil.LogRequests(http.DefaultServeMux)
+	if capture := httputil.NewRequestCaptureFromEnv(); capture != nil {
+		http.HandleFunc("/debug/requests", capture.Handler)
+		handler = capture.Middleware(handler)
+	}
+	handler = startup.Gate(handler)
+
+	go initialize()
+
 	log.Printf("Starting Hirer API on port %s", port)
-	log.Fatal(http.ListenAndServe(":"+port, logRequest(http.DefaultServeMux)))
+	log.Fatal(http.ListenAndServe(":"+port, handler))
 }
 
-func logRequest(handler http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		log.Printf("%s %s %s", r.RemoteAddr, r.Method, r.URL)
-		handler.ServeHTTP(w, r)
-	})
-}
+// initialize runs the startup phases in order. Configuration errors are fatal.
+func initialize() {
+	fields, err := parseCandidateFieldMapping(os.Getenv("CANDIDATE_FIELD_MAP"))
+	if err != nil {
+		log.Fatalf("Invalid CANDIDATE_FIELD_MAP: %v", err)
+	}
+	candidateFields = fields
 
-func homeHandler(w http.ResponseWriter, r *http.Request) {
-	if r.URL.Path != "/" {
-		http.NotFound(w, r)
-		return
+	integrations, err := loadCompanyIntegrations()
+	if err != nil {
+		log.Fatalf("Invalid company integrations: %v", err)
 	}
-	json.NewEncoder(w).Encode(Response{
-		Status:  "ok",
-		Message: "Hirer API v1.0.0 - XYZ Platform",
-	})
-}
+	companyIntegrations = integrations
+	startup.Complete("config")
 
-func healthHandler(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(Response{Status: "healthy"})
+	jobs = seedJobs()
+	startup.Complete("store")
+	log.Printf("Hirer API started")
 }
 
-func readyHandler(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(Response{Status: "ready"})
+// seedJobs returns the sample jobs the in-memory store starts with
+func seedJobs() []Job {
+	return []Job{
+		{ID: "1", Title: "Senior Backend Engineer", Company: "TechCorp", Description: "Building scalable systems", Skills: []string{"Go", "Kubernetes"}, CreatedAt: time.Now()},
+		{ID: "2", Title: "ML Engineer", Company: "AIStartup", Description: "Developing ML models", Skills: []string{"Python", "TensorFlow"}, CreatedAt: time.Now()},
+		{ID: "3", Title: "Platform Engineer", Company: "CloudInc", Description: "Building internal platform", Skills: []string{"Kubernetes", "Terraform"}, CreatedAt: time.Now()},
+	}
 }
 
 func jobsHandler(w http.ResponseWriter, r *http.Request) {
@@ -87,18 +105,28 @@ func jobsHandler(w http.ResponseWriter, r *http.Request) {
 
 	switch r.Method {
 	case http.MethodGet:
-		json.NewEncoder(w).Encode(Response{Status: "ok", Data: jobs})
+		page, err := httputil.ParsePage(r.URL.Query())
+		if err != nil {
+			httputil.WriteError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		httputil.WritePage(w, jobs, page)
 	case http.MethodPost:
-		var newJob Job
-		if err := json.NewDecoder(r.Body).Decode(&newJob); err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+		var input JobInput
+		if err := httputil.DecodeJSON(w, r, &input); err != nil {
+			httputil.WriteDecodeError(w, err)
+			return
+		}
+		newJob := input.job()
+		warnings, err := applyJobLimits(&newJob)
+		if err != nil {
+			httputil.WriteError(w, http.StatusUnprocessableEntity, err.Error())
 			return
 		}
 		newJob.ID = fmt.Sprintf("%d", len(jobs)+1)
 		newJob.CreatedAt = time.Now()
 		jobs = append(jobs, newJob)
-		w.WriteHeader(http.StatusCreated)
-		json.NewEncoder(w).Encode(Response{Status: "created", Data: newJob})
+		httputil.WriteJSON(w, http.StatusCreated, httputil.Response{Status: "created", Data: newJob, Warnings: warnings})
 	default:
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 	}
@@ -107,52 +135,21 @@ func jobsHandler(w http.ResponseWriter, r *http.Request) {
 func jobByIDHandler(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	id := r.URL.Path[len("/api/v1/jobs/"):]
+	if jobID, ok := strings.CutSuffix(id, "/similar"); ok {
+		similarJobsHandler(w, r, jobID)
+		return
+	}
+	if jobID, ok := strings.CutSuffix(id, "/integrations"); ok {
+		jobIntegrationsHandler(w, r, jobID)
+		return
+	}
 
 	for _, j := range jobs {
 		if j.ID == id {
-			json.NewEncoder(w).Encode(Response{Status: "ok", Data: j})
+			httputil.WriteJSON(w, http.StatusOK, httputil.Response{Status: "ok", Data: j})
 			return
 		}
 	}
 
-	w.WriteHeader(http.StatusNotFound)
-	json.NewEncoder(w).Encode(Response{Status: "error", Message: "Job not found"})
+	httputil.WriteError(w, http.StatusNotFound, "Job not found")
 }
-
-// matchCandidatesHandler demonstrates cross-domain integration
-// It calls the Candidate API to find matching candidates for a job
-func matchCandidatesHandler(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/json")
-
-	// Get the Candidate API URL from environment or use default
-	candidateAPIURL := os.Getenv("CANDIDATE_API_URL")
-	if candidateAPIURL == "" {
-		candidateAPIURL = "http://candidate-api.candidate.svc.cluster.local/api/v1/candidates"
-	}
-
-	// Call Candidate API (demonstrating cross-domain integration)
-	client := &http.Client{Timeout: 5 * time.Second}
-	resp, err := client.Get(candidateAPIURL)
-	if err != nil {
-		log.Printf("Error calling Candidate API: %v", err)
-		w.WriteHeader(http.StatusServiceUnavailable)
-		json.NewEncoder(w).Encode(Response{
-			Status:  "error",
-			Message: "Unable to reach Candidate API",
-		})
-		return
-	}
-	defer resp.Body.Close()
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		log.Printf("Error reading response: %v", err)
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(Response{Status: "error", Message: "Error reading response"})
-		return
-	}
-
-	// Return the candidates data
-	w.Write(body)
-}
-
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/xyz-company/examples/internal/httputil"
+)
+
+func TestInitializeStartsTheAPI(t *testing.T) {
+	withJobs(t)
+	withCompanyIntegrations(t, nil)
+	savedStartup, savedFields := startup, candidateFields
+	startup = httputil.NewStartupTracker("config", "store")
+	t.Cleanup(func() { startup, candidateFields = savedStartup, savedFields })
+	t.Setenv("CANDIDATE_FIELD_MAP", "")
+	t.Setenv("COMPANY_INTEGRATIONS", "")
+	t.Setenv("COMPANY_INTEGRATIONS_FILE", "")
+
+	handler := startup.Gate(http.HandlerFunc(jobsHandler))
+	list := func() int {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/jobs", nil))
+		return w.Code
+	}
+	if code := list(); code != http.StatusServiceUnavailable {
+		t.Fatalf("before initialize: status = %d, want %d", code, http.StatusServiceUnavailable)
+	}
+
+	initialize()
+	if !startup.Started() {
+		t.Fatalf("still waiting for %s after initialize", startup.Pending())
+	}
+	if code := list(); code != http.StatusOK {
+		t.Fatalf("after initialize: status = %d, want %d", code, http.StatusOK)
+	}
+	if len(jobs) == 0 || candidateFields != defaultCandidateFieldMapping {
+		t.Fatalf("initialize left %d jobs and field mapping %+v", len(jobs), candidateFields)
+	}
+}
+
+// createJob posts body to the jobs endpoint
+func createJob(body string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	jobsHandler(w, httptest.NewRequest(http.MethodPost, "/api/v1/jobs", strings.NewReader(body)))
+	return w
+}
+
+func TestCreateJobRejectsServerControlledFields(t *testing.T) {
+	withJobs(t, testJob)
+	for _, tt := range []struct{ body, field string }{
+		{`{"title":"Go","id":"1"}`, `"id"`},
+		{`{"title":"Go","createdAt":"2020-01-01T00:00:00Z"}`, `"createdAt"`},
+		{`{"title":"Go","ID":"99"}`, `"ID"`},
+		{`{"title":"Go","score":1}`, `"score"`},
+	} {
+		w := createJob(tt.body)
+		var resp httputil.Response
+		json.Unmarshal(w.Body.Bytes(), &resp)
+		if want := "Unknown field " + tt.field; w.Code != http.StatusBadRequest || resp.Message != want {
+			t.Errorf("%s: %d %q, want a 400 with %q", tt.body, w.Code, resp.Message, want)
+		}
+	}
+	if len(jobs) != 1 {
+		t.Fatalf("rejected jobs stored: %+v", jobs)
+	}
+}
+
+func TestCreateJobAssignsServerControlledFields(t *testing.T) {
+	withJobs(t, testJob)
+	before := time.Now()
+	w := createJob(`{"title":"SRE","company":"XYZ","description":"On call","skills":["Go"]}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("status = %d: %s", w.Code, w.Body)
+	}
+	var resp struct {
+		Data Job `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	job := resp.Data
+	if job.ID != "2" || job.CreatedAt.Before(before) {
+		t.Fatalf("created job ID %q at %s, want ID 2 assigned now", job.ID, job.CreatedAt)
+	}
+	if job.Title != "SRE" || job.Company != "XYZ" || job.Description != "On call" || len(job.Skills) != 1 {
+		t.Fatalf("created job = %+v, want the client fields kept", job)
+	}
+}
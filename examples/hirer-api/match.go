@@ -0,0 +1,329 @@
+// Candidate matching
+// The match endpoint fetches candidates from the Candidate API and ranks them
+// against a job's required skills
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/xyz-company/examples/internal/httputil"
+)
+
+// Candidate is the subset of the Candidate API's candidate used for matching
+type Candidate struct {
+	ID     string   `json:"id"`
+	Name   string   `json:"name"`
+	Email  string   `json:"email,omitempty"`
+	Skills []string `json:"skills"`
+}
+
+// CandidateMatch is a candidate together with its score against a job
+type CandidateMatch struct {
+	Candidate
+	Score float64 `json:"score"`
+}
+
+// matchCandidatesHandler demonstrates cross-domain integration
+// It calls the Candidate API to find matching candidates for a job
+//
+// Without a jobId the Candidate API response is returned as-is. With a jobId,
+// candidates are scored against the job (see scoreCandidate) and filtered by
+// minScore (default MATCH_MIN_SCORE) and limit. Concurrent requests with the
+// same jobId, minScore and limit share one computation (see matchFlights).
+func matchCandidatesHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	jobID := r.URL.Query().Get("jobId")
+	minScore, limit, err := parseMatchParams(r.URL.Query())
+	if err != nil {
+		httputil.WriteError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var job *Job
+	if jobID != "" {
+		for i := range jobs {
+			if jobs[i].ID == jobID {
+				job = &jobs[i]
+				break
+			}
+		}
+		if job == nil {
+			httputil.WriteError(w, http.StatusNotFound, "Job not found")
+			return
+		}
+	}
+
+	key := fmt.Sprintf("%s|%g|%d", jobID, minScore, limit)
+	result, err, _ := matchFlights.Do(key, func() (matchResult, error) {
+		return fetchMatches(job, minScore, limit)
+	})
+	if err != nil {
+		var me *matchError
+		if !errors.As(err, &me) {
+			me = &matchError{Status: http.StatusInternalServerError, Message: err.Error()}
+		}
+		if me.RetryAfter {
+			w.Header().Set("Retry-After", strconv.Itoa(downstreamRetryAfter))
+		}
+		httputil.WriteError(w, me.Status, me.Message)
+		return
+	}
+	if result.Truncated {
+		httputil.MarkTruncated(w)
+	}
+	w.Write(result.Body)
+}
+
+// matchResult is the response produced by fetchMatches
+type matchResult struct {
+	// Body is shared between coalesced requests and must not be modified
+	Body      []byte
+	Truncated bool
+}
+
+// matchError is a failed match computation and the response it maps to
+type matchError struct {
+	Status  int
+	Message string
+	// RetryAfter asks the client to back off for downstreamRetryAfter seconds
+	RetryAfter bool
+}
+
+func (e *matchError) Error() string {
+	return e.Message
+}
+
+// maxCandidatePages bounds the Candidate API pages one match request reads
+// (CANDIDATE_API_MAX_PAGES); matches over a longer list are marked truncated
+var maxCandidatePages = httputil.EnvInt("CANDIDATE_API_MAX_PAGES", 50)
+
+// fetchMatches calls the Candidate API and returns the response to send: the
+// Candidate API's own first page without a job, ranked matches with one. To
+// rank every candidate it follows truncated pages, up to maxCandidatePages.
+func fetchMatches(job *Job, minScore float64, limit int) (matchResult, error) {
+	// Get the Candidate API URL from environment or use default
+	candidateAPIURL, err := candidateAPIEndpoint()
+	if err != nil {
+		log.Printf("Error building Candidate API URL: %v", err)
+		return matchResult{}, &matchError{Status: http.StatusInternalServerError, Message: "Candidate API is misconfigured"}
+	}
+
+	if job == nil {
+		// Return the candidates data, and whether the Candidate API cut it
+		body, truncated, err := fetchCandidatePage(candidateAPIURL, 0)
+		if err != nil {
+			return matchResult{}, err
+		}
+		return matchResult{Body: body, Truncated: truncated}, nil
+	}
+
+	var candidates []Candidate
+	more := true
+	for pages, offset := 0, 0; more && pages < maxCandidatePages; pages++ {
+		body, truncated, err := fetchCandidatePage(candidateAPIURL, offset)
+		if err != nil {
+			return matchResult{}, err
+		}
+		page, err := decodeCandidates(body, candidateFields)
+		if err != nil {
+			log.Printf("Error decoding Candidate API response: %v", err)
+			return matchResult{}, &matchError{Status: http.StatusBadGateway, Message: "Invalid response from Candidate API"}
+		}
+		if truncated && len(page) == 0 {
+			log.Printf("Candidate API returned an empty truncated page at offset %d", offset)
+			return matchResult{}, &matchError{Status: http.StatusBadGateway, Message: "Invalid response from Candidate API"}
+		}
+		candidates = append(candidates, page...)
+		offset += len(page)
+		more = truncated
+	}
+	if more {
+		log.Printf("Candidate API has more than %d pages, ranking the first %d candidates", maxCandidatePages, len(candidates))
+	}
+
+	matches, capped := httputil.CapPage(rankCandidates(*job, candidates, minScore, limit))
+	truncated := capped || more
+	out, err := json.Marshal(httputil.Response{Status: "ok", Data: matches, Truncated: truncated})
+	if err != nil {
+		return matchResult{}, err
+	}
+	return matchResult{Body: append(out, '\n'), Truncated: truncated}, nil
+}
+
+// fetchCandidatePage reads the Candidate API page starting at offset. It
+// reports whether the Candidate API marked the page truncated, meaning more
+// candidates follow it.
+func fetchCandidatePage(candidateAPIURL string, offset int) ([]byte, bool, error) {
+	if offset > 0 {
+		u, err := url.Parse(candidateAPIURL)
+		if err != nil {
+			return nil, false, err
+		}
+		query := u.Query()
+		query.Set("offset", strconv.Itoa(offset))
+		u.RawQuery = query.Encode()
+		candidateAPIURL = u.String()
+	}
+
+	// Shed load instead of queueing when the Candidate API already has as
+	// many in-flight calls as it is allowed
+	release, ok := acquireDownstreamSlot()
+	if !ok {
+		return nil, false, &matchError{
+			Status:     http.StatusServiceUnavailable,
+			Message:    "Too many concurrent requests to Candidate API, retry later",
+			RetryAfter: true,
+		}
+	}
+	defer release()
+
+	// Call Candidate API (demonstrating cross-domain integration)
+	client := &http.Client{Timeout: 5 * time.Second}
+	resp, err := client.Get(candidateAPIURL)
+	if err != nil {
+		log.Printf("Error calling Candidate API: %v", err)
+		return nil, false, &matchError{Status: http.StatusServiceUnavailable, Message: "Unable to reach Candidate API"}
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		log.Printf("Error reading response: %v", err)
+		return nil, false, &matchError{Status: http.StatusInternalServerError, Message: "Error reading response"}
+	}
+	return body, resp.Header.Get("X-Result-Truncated") == "true", nil
+}
+
+// parseMatchParams reads minScore (0-1) and limit (>= 1) from the query,
+// falling back to MATCH_MIN_SCORE and no limit
+func parseMatchParams(query url.Values) (float64, int, error) {
+	minScore := 0.0
+	if v := os.Getenv("MATCH_MIN_SCORE"); v != "" {
+		if s, err := strconv.ParseFloat(v, 64); err == nil {
+			minScore = s
+		}
+	}
+	if v := query.Get("minScore"); v != "" {
+		s, err := strconv.ParseFloat(v, 64)
+		if err != nil || s < 0 || s > 1 {
+			return 0, 0, fmt.Errorf("minScore must be a number between 0 and 1")
+		}
+		minScore = s
+	}
+
+	limit := 0
+	if v := query.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			return 0, 0, fmt.Errorf("limit must be a positive integer")
+		}
+		limit = n
+	}
+
+	return minScore, limit, nil
+}
+
+// rankCandidates scores candidates against job, drops those below minScore
+// (and those with no overlap at all), sorts by score descending then ID
+// ascending, and keeps the top limit results. A limit of 0 keeps every result.
+func rankCandidates(job Job, candidates []Candidate, minScore float64, limit int) []CandidateMatch {
+	matches := []CandidateMatch{}
+	for _, c := range candidates {
+		score := scoreCandidate(job, c)
+		if score == 0 || score < minScore {
+			continue
+		}
+		matches = append(matches, CandidateMatch{Candidate: c, Score: score})
+	}
+
+	// Ties are broken on ID so identical requests return identical responses
+	sort.SliceStable(matches, func(i, j int) bool {
+		if matches[i].Score != matches[j].Score {
+			return matches[i].Score > matches[j].Score
+		}
+		return lessID(matches[i].ID, matches[j].ID)
+	})
+
+	if limit > 0 && len(matches) > limit {
+		matches = matches[:limit]
+	}
+	return matches
+}
+
+// lessID orders record IDs: numeric ones by value, as the APIs assign them
+// ("9" before "10"), then any others, such as a third-party source's, as
+// strings
+func lessID(a, b string) bool {
+	na, errA := strconv.ParseUint(a, 10, 64)
+	nb, errB := strconv.ParseUint(b, 10, 64)
+	switch {
+	case errA == nil && errB == nil && na != nb:
+		return na < nb
+	case (errA == nil) != (errB == nil):
+		return errA == nil
+	}
+	return a < b
+}
+
+// scoreCandidate returns the fraction of the job's skills the candidate has,
+// from 0 (none) to 1 (all). Skills are compared after normalizeSkill, and
+// duplicate skills count once. A job with no skills scores every candidate 0.
+func scoreCandidate(job Job, c Candidate) float64 {
+	required := normalizeSkills(job.Skills)
+	if len(required) == 0 {
+		return 0
+	}
+
+	have := normalizeSkills(c.Skills)
+	matched := 0
+	for skill := range required {
+		if have[skill] {
+			matched++
+		}
+	}
+	return float64(matched) / float64(len(required))
+}
+
+// normalizeSkills returns the set of normalized, non-empty skills
+func normalizeSkills(skills []string) map[string]bool {
+	set := make(map[string]bool, len(skills))
+	for _, s := range skills {
+		if n := normalizeSkill(s); n != "" {
+			set[n] = true
+		}
+	}
+	return set
+}
+
+// skillAliases maps common alternative spellings to a canonical skill name
+var skillAliases = map[string]string{
+	"golang":   "go",
+	"k8s":      "kubernetes",
+	"postgres": "postgresql",
+	"js":       "javascript",
+	"ts":       "typescript",
+	"ml":       "machine learning",
+}
+
+// normalizeSkill makes skill comparison case- and whitespace-insensitive and
+// folds aliases (e.g. "k8s") into their canonical name
+func normalizeSkill(skill string) string {
+	n := strings.ToLower(strings.TrimSpace(skill))
+	if canonical, ok := skillAliases[n]; ok {
+		return canonical
+	}
+	return n
+}
@@ -0,0 +1,251 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/xyz-company/examples/internal/httputil"
+)
+
+// testCandidates is the Candidate API's data in the match tests. Against
+// testJob (Go, Kubernetes, AWS, Terraform) they score 1, 0.75, 0.5, 0.5,
+// 0.25 and 0.
+var testCandidates = []Candidate{
+	{ID: "1", Name: "Alice", Skills: []string{"Go", "Kubernetes", "AWS", "Terraform"}},
+	{ID: "2", Name: "Bob", Skills: []string{"golang", "k8s", "aws"}},
+	{ID: "3", Name: "Carol", Skills: []string{"Go", "Terraform"}},
+	{ID: "4", Name: "Dan", Skills: []string{" KUBERNETES ", "AWS", "Python"}},
+	{ID: "5", Name: "Erin", Skills: []string{"Terraform"}},
+	{ID: "6", Name: "Frank", Skills: []string{"Java"}},
+}
+
+var testJob = Job{ID: "1", Title: "Platform Engineer", Skills: []string{"Go", "Kubernetes", "AWS", "Terraform"}}
+
+// withJobs replaces the job store for the duration of the test
+func withJobs(t *testing.T, js ...Job) {
+	t.Helper()
+	saved := jobs
+	jobs = js
+	t.Cleanup(func() { jobs = saved })
+}
+
+// candidateAPI serves handler as the Candidate API for the duration of the
+// test and returns the number of requests it received so far
+func candidateAPI(t *testing.T, handler http.HandlerFunc) func() int {
+	t.Helper()
+	calls := make(chan struct{}, 1000)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls <- struct{}{}
+		handler(w, r)
+	}))
+	t.Cleanup(server.Close)
+	t.Setenv("CANDIDATE_API_URL", server.URL+"/api/v1/candidates")
+	return func() int { return len(calls) }
+}
+
+// serveCandidates replies with candidates in the Candidate API's envelope
+func serveCandidates(candidates []Candidate) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		httputil.WriteJSON(w, http.StatusOK, httputil.Response{Status: "ok", Data: candidates})
+	}
+}
+
+// getMatches calls the match endpoint with query and decodes its matches
+func getMatches(t *testing.T, query string) (*httptest.ResponseRecorder, []CandidateMatch) {
+	t.Helper()
+	w := httptest.NewRecorder()
+	matchCandidatesHandler(w, httptest.NewRequest(http.MethodGet, "/api/v1/match?"+query, nil))
+	var resp struct {
+		Data []CandidateMatch `json:"data"`
+	}
+	if w.Code == http.StatusOK {
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("decoding %s: %v", w.Body, err)
+		}
+	}
+	return w, resp.Data
+}
+
+// matchIDs returns the IDs of matches in order
+func matchIDs(matches []CandidateMatch) []string {
+	ids := []string{}
+	for _, m := range matches {
+		ids = append(ids, m.ID)
+	}
+	return ids
+}
+
+func equalIDs(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestRankCandidatesThresholdAndLimit(t *testing.T) {
+	tests := []struct {
+		name     string
+		minScore float64
+		limit    int
+		want     []string
+	}{
+		{"no threshold or limit drops only zero scores", 0, 0, []string{"1", "2", "3", "4", "5"}},
+		{"threshold is inclusive", 0.5, 0, []string{"1", "2", "3", "4"}},
+		{"threshold of 1 keeps perfect matches", 1, 0, []string{"1"}},
+		{"limit keeps the top results", 0, 2, []string{"1", "2"}},
+		{"limit cuts through a tie in ID order", 0, 3, []string{"1", "2", "3"}},
+		{"threshold applies before the limit", 0.75, 3, []string{"1", "2"}},
+		{"limit applies after the threshold", 0.5, 3, []string{"1", "2", "3"}},
+		{"limit above the result count", 0.25, 10, []string{"1", "2", "3", "4", "5"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := matchIDs(rankCandidates(testJob, testCandidates, tt.minScore, tt.limit))
+			if !equalIDs(got, tt.want) {
+				t.Fatalf("rankCandidates(minScore=%g, limit=%d) = %v, want %v", tt.minScore, tt.limit, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestScoreCandidate(t *testing.T) {
+	want := []float64{1, 0.75, 0.5, 0.5, 0.25, 0}
+	for i, c := range testCandidates {
+		if got := scoreCandidate(testJob, c); got != want[i] {
+			t.Errorf("scoreCandidate(%s) = %g, want %g", c.Name, got, want[i])
+		}
+	}
+	if got := scoreCandidate(Job{}, testCandidates[0]); got != 0 {
+		t.Errorf("a job without skills scored %g, want 0", got)
+	}
+	dup := Job{Skills: []string{"Go", "golang", "GO"}}
+	if got := scoreCandidate(dup, Candidate{Skills: []string{"Go"}}); got != 1 {
+		t.Errorf("duplicate job skills scored %g, want 1", got)
+	}
+}
+
+func TestMatchHandlerThresholdAndLimit(t *testing.T) {
+	withJobs(t, testJob)
+	candidateAPI(t, serveCandidates(testCandidates))
+
+	w, matches := getMatches(t, "jobId=1&minScore=0.5&limit=3")
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", w.Code, w.Body)
+	}
+	if got, want := matchIDs(matches), []string{"1", "2", "3"}; !equalIDs(got, want) {
+		t.Fatalf("matches = %v, want %v", got, want)
+	}
+	if matches[1].Score != 0.75 {
+		t.Fatalf("score of %s = %g, want 0.75", matches[1].Name, matches[1].Score)
+	}
+}
+
+func TestMatchHandlerDefaultMinScore(t *testing.T) {
+	withJobs(t, testJob)
+	candidateAPI(t, serveCandidates(testCandidates))
+
+	t.Setenv("MATCH_MIN_SCORE", "0.75")
+	_, matches := getMatches(t, "jobId=1")
+	if got, want := matchIDs(matches), []string{"1", "2"}; !equalIDs(got, want) {
+		t.Fatalf("matches with MATCH_MIN_SCORE = %v, want %v", got, want)
+	}
+
+	// The query overrides the default
+	_, matches = getMatches(t, "jobId=1&minScore=0.25")
+	if got := len(matches); got != 5 {
+		t.Fatalf("minScore=0.25 returned %d matches, want 5", got)
+	}
+}
+
+func TestMatchHandlerRejectsBadParams(t *testing.T) {
+	withJobs(t, testJob)
+	calls := candidateAPI(t, serveCandidates(testCandidates))
+
+	for _, query := range []string{"jobId=1&minScore=1.5", "jobId=1&minScore=-0.1", "jobId=1&minScore=high", "jobId=1&limit=0", "jobId=1&limit=two"} {
+		if w, _ := getMatches(t, query); w.Code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want %d", query, w.Code, http.StatusBadRequest)
+		}
+	}
+	if w, _ := getMatches(t, "jobId=42"); w.Code != http.StatusNotFound {
+		t.Errorf("unknown job: status = %d, want %d", w.Code, http.StatusNotFound)
+	}
+	if n := calls(); n != 0 {
+		t.Fatalf("invalid requests made %d Candidate API calls", n)
+	}
+}
+
+func TestLessID(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want bool
+	}{
+		{"1", "2", true},
+		{"2", "1", false},
+		{"9", "10", true},
+		{"10", "9", false},
+		{"007", "7", true},
+		{"7", "007", false},
+		{"10", "abc", true},
+		{"abc", "10", false},
+		{"abc", "abd", true},
+		{"x-10", "x-9", true},
+		{"1", "1", false},
+	}
+	for _, tt := range tests {
+		if got := lessID(tt.a, tt.b); got != tt.want {
+			t.Errorf("lessID(%q, %q) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestRankCandidatesBreaksTiesOnID(t *testing.T) {
+	// Every candidate scores 1 against the job, in no particular order
+	job := Job{Skills: []string{"Go"}}
+	var candidates []Candidate
+	for _, id := range []string{"10", "b", "2", "x-1", "1", "a", "9"} {
+		candidates = append(candidates, Candidate{ID: id, Skills: []string{"Go"}})
+	}
+	want := []string{"1", "2", "9", "10", "a", "b", "x-1"}
+
+	for i := 0; i < len(candidates); i++ {
+		// Rotate the input so each candidate comes first once
+		rotated := append(append([]Candidate{}, candidates[i:]...), candidates[:i]...)
+		if got := matchIDs(rankCandidates(job, rotated, 0, 0)); !equalIDs(got, want) {
+			t.Fatalf("rotation %d ranked %v, want %v", i, got, want)
+		}
+	}
+}
+
+func TestMatchResponsesIdentical(t *testing.T) {
+	withJobs(t, testJob)
+	// The Candidate API returns the same candidates in a different order
+	// on every call
+	var call int
+	candidateAPI(t, func(w http.ResponseWriter, r *http.Request) {
+		n := call % len(testCandidates)
+		call++
+		serveCandidates(append(append([]Candidate{}, testCandidates[n:]...), testCandidates[:n]...))(w, r)
+	})
+
+	first, _ := getMatches(t, "jobId=1")
+	if first.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", first.Code, first.Body)
+	}
+	for i := 0; i < 10; i++ {
+		w, _ := getMatches(t, "jobId=1")
+		if w.Body.String() != first.Body.String() {
+			t.Fatalf("response %d = %s, want %s", i+2, w.Body, first.Body)
+		}
+	}
+	if call < 11 {
+		t.Fatalf("Candidate API called %d times, want every request to reach it", call)
+	}
+}
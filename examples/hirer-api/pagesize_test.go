@@ -0,0 +1,210 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/xyz-company/examples/internal/httputil"
+)
+
+// withMaxPageSize caps list responses at n records for the duration of the test
+func withMaxPageSize(t *testing.T, n int) {
+	t.Helper()
+	saved := httputil.MaxPageSize
+	httputil.MaxPageSize = n
+	t.Cleanup(func() { httputil.MaxPageSize = saved })
+}
+
+// listResponse is the envelope of a list response
+type listResponse struct {
+	Data       []json.RawMessage `json:"data"`
+	Truncated  bool              `json:"truncated"`
+	NextOffset int               `json:"nextOffset"`
+}
+
+// wantPage fails t unless w is a 200 list of n records, truncated or not
+func wantPage(t *testing.T, w *httptest.ResponseRecorder, n int, truncated bool) {
+	t.Helper()
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", w.Code, w.Body)
+	}
+	var resp listResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Data) != n || resp.Truncated != truncated {
+		t.Fatalf("%d records, truncated %v, want %d, truncated %v", len(resp.Data), resp.Truncated, n, truncated)
+	}
+	if header := w.Header().Get("X-Result-Truncated"); (header == "true") != truncated {
+		t.Fatalf("X-Result-Truncated = %q, want truncated %v", header, truncated)
+	}
+}
+
+// numberedJobs returns n jobs with IDs 1 to n, all needing Go
+func numberedJobs(n int) []Job {
+	js := make([]Job, n)
+	for i := range js {
+		js[i] = Job{ID: strconv.Itoa(i + 1), Title: "Job", Skills: []string{"Go"}}
+	}
+	return js
+}
+
+// listJobs serves GET /api/v1/jobs?query
+func listJobs(query string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	jobsHandler(w, httptest.NewRequest(http.MethodGet, "/api/v1/jobs?"+query, nil))
+	return w
+}
+
+func TestListJobsTruncated(t *testing.T) {
+	withMaxPageSize(t, 3)
+
+	withJobs(t, numberedJobs(3)...)
+	wantPage(t, listJobs(""), 3, false)
+
+	withJobs(t, numberedJobs(5)...)
+	wantPage(t, listJobs(""), 3, true)
+}
+
+func TestListJobsPaged(t *testing.T) {
+	withMaxPageSize(t, 3)
+	withJobs(t, numberedJobs(7)...)
+
+	// Following nextOffset visits every job once, in order
+	var ids []string
+	for offset, pages := 0, 0; ; pages++ {
+		if pages == 5 {
+			t.Fatalf("still paging after %d pages", pages)
+		}
+		w := listJobs("limit=2&offset=" + strconv.Itoa(offset))
+		var resp struct {
+			Data       []Job `json:"data"`
+			Truncated  bool  `json:"truncated"`
+			NextOffset int   `json:"nextOffset"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatal(err)
+		}
+		for _, j := range resp.Data {
+			ids = append(ids, j.ID)
+		}
+		if resp.Truncated != (resp.NextOffset > 0) {
+			t.Fatalf("truncated %v with nextOffset %d", resp.Truncated, resp.NextOffset)
+		}
+		if resp.NextOffset == 0 {
+			break
+		}
+		offset = resp.NextOffset
+	}
+	if want := []string{"1", "2", "3", "4", "5", "6", "7"}; !equalIDs(ids, want) {
+		t.Fatalf("paged jobs = %v, want %v", ids, want)
+	}
+
+	// A limit above the ceiling is cut to it
+	w := listJobs("limit=50&offset=3")
+	wantPage(t, w, 3, true)
+	var resp listResponse
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	if resp.NextOffset != 6 {
+		t.Fatalf("nextOffset = %d, want 6", resp.NextOffset)
+	}
+
+	// Past the end is an empty last page
+	wantPage(t, listJobs("offset=10"), 0, false)
+
+	for _, query := range []string{"offset=-1", "limit=0", "limit=many"} {
+		if w := listJobs(query); w.Code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want 400", query, w.Code)
+		}
+	}
+}
+
+func TestMatchTruncated(t *testing.T) {
+	withMaxPageSize(t, 2)
+	withJobs(t, testJob)
+	candidateAPI(t, serveCandidates(testCandidates))
+
+	// A limit above the ceiling is cut to it
+	w, _ := getMatches(t, "jobId=1&limit=10")
+	wantPage(t, w, 2, true)
+
+	w, _ = getMatches(t, "jobId=1&limit=2")
+	wantPage(t, w, 2, false)
+}
+
+func TestSimilarJobsTruncated(t *testing.T) {
+	withMaxPageSize(t, 2)
+	withJobs(t, numberedJobs(6)...)
+
+	w, _ := getSimilar(t, "1", "limit=50")
+	wantPage(t, w, 2, true)
+}
+
+// serveCandidatePages replies with candidates paged as the Candidate API
+// pages them
+func serveCandidatePages(candidates []Candidate) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		page, err := httputil.ParsePage(r.URL.Query())
+		if err != nil {
+			httputil.WriteError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		httputil.WritePage(w, candidates, page)
+	}
+}
+
+// pagedCandidates returns n candidates with IDs 1 to n, of which only the
+// last two have any of testJob's skills
+func pagedCandidates(n int) []Candidate {
+	cs := make([]Candidate, n)
+	for i := range cs {
+		cs[i] = Candidate{ID: strconv.Itoa(i + 1), Name: "Candidate", Skills: []string{"Java"}}
+	}
+	cs[n-2].Skills = []string{"Go"}
+	cs[n-1].Skills = []string{"Go", "Kubernetes"}
+	return cs
+}
+
+func TestMatchPagesThroughCandidateAPI(t *testing.T) {
+	withMaxPageSize(t, 3)
+	withJobs(t, testJob)
+	// More than two pages, with the only matches on the last
+	calls := candidateAPI(t, serveCandidatePages(pagedCandidates(8)))
+
+	w, matches := getMatches(t, "jobId=1")
+	wantPage(t, w, 2, false)
+	if got, want := matchIDs(matches), []string{"8", "7"}; !equalIDs(got, want) {
+		t.Fatalf("matches = %v, want %v from the last page", got, want)
+	}
+	if n := calls(); n != 3 {
+		t.Fatalf("Candidate API called %d times, want once per page", n)
+	}
+}
+
+func TestMatchFlagsCandidatePagesLeftUnread(t *testing.T) {
+	withMaxPageSize(t, 3)
+	withJobs(t, testJob)
+	saved := maxCandidatePages
+	maxCandidatePages = 2
+	t.Cleanup(func() { maxCandidatePages = saved })
+	candidateAPI(t, serveCandidatePages(append(pagedCandidates(6), pagedCandidates(3)...)))
+
+	// The first two pages are ranked; the third is left out and flagged
+	w, matches := getMatches(t, "jobId=1")
+	wantPage(t, w, 2, true)
+	if got, want := matchIDs(matches), []string{"6", "5"}; !equalIDs(got, want) {
+		t.Fatalf("matches = %v, want %v", got, want)
+	}
+}
+
+func TestMatchWithoutJobRelaysTruncation(t *testing.T) {
+	withMaxPageSize(t, 3)
+	candidateAPI(t, serveCandidatePages(pagedCandidates(5)))
+
+	w, _ := getMatches(t, "")
+	wantPage(t, w, 3, true)
+}
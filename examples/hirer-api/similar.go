@@ -0,0 +1,95 @@
+// Similar jobs
+// Ranks other jobs by how much their skill sets overlap with a given job
+
+package main
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+
+	"github.com/xyz-company/examples/internal/httputil"
+)
+
+const defaultSimilarLimit = 5
+
+// SimilarJob is a job together with its similarity to the requested job
+type SimilarJob struct {
+	Job
+	Similarity float64 `json:"similarity"`
+}
+
+// similarJobsHandler serves GET /api/v1/jobs/{id}/similar?limit=N
+func similarJobsHandler(w http.ResponseWriter, r *http.Request, id string) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	limit := defaultSimilarLimit
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			httputil.WriteError(w, http.StatusBadRequest, "limit must be a positive integer")
+			return
+		}
+		limit = n
+	}
+
+	for _, j := range jobs {
+		if j.ID == id {
+			similar, truncated := httputil.CapPage(rankSimilarJobs(j, jobs, limit))
+			if truncated {
+				httputil.MarkTruncated(w)
+			}
+			httputil.WriteJSON(w, http.StatusOK, httputil.Response{Status: "ok", Data: similar, Truncated: truncated})
+			return
+		}
+	}
+
+	httputil.WriteError(w, http.StatusNotFound, "Job not found")
+}
+
+// rankSimilarJobs returns up to limit jobs from all, excluding job itself and
+// jobs with no skills in common, ordered by similarity (highest first) and
+// then by ID
+func rankSimilarJobs(job Job, all []Job, limit int) []SimilarJob {
+	similar := []SimilarJob{}
+	for _, other := range all {
+		if other.ID == job.ID {
+			continue
+		}
+		if s := jaccardSimilarity(job.Skills, other.Skills); s > 0 {
+			similar = append(similar, SimilarJob{Job: other, Similarity: s})
+		}
+	}
+
+	sort.SliceStable(similar, func(i, j int) bool {
+		if similar[i].Similarity != similar[j].Similarity {
+			return similar[i].Similarity > similar[j].Similarity
+		}
+		return lessID(similar[i].ID, similar[j].ID)
+	})
+
+	if len(similar) > limit {
+		similar = similar[:limit]
+	}
+	return similar
+}
+
+// jaccardSimilarity is |a ∩ b| / |a ∪ b| over the normalized skill sets,
+// from 0 (nothing shared) to 1 (identical)
+func jaccardSimilarity(a, b []string) float64 {
+	setA, setB := normalizeSkills(a), normalizeSkills(b)
+	if len(setA) == 0 && len(setB) == 0 {
+		return 0
+	}
+
+	shared := 0
+	for skill := range setA {
+		if setB[skill] {
+			shared++
+		}
+	}
+	return float64(shared) / float64(len(setA)+len(setB)-shared)
+}
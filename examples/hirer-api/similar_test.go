@@ -0,0 +1,112 @@
+package main
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// similarFixture ranks, against job 1, as 2 (1), 3 and 10 (2/3 each), 5
+// (2/5) and 4 (1/4); 6 shares nothing
+var similarFixture = []Job{
+	{ID: "1", Title: "Platform Engineer", Skills: []string{"Go", "Kubernetes", "AWS"}},
+	{ID: "2", Title: "Cloud Engineer", Skills: []string{"golang", "k8s", "aws"}},
+	{ID: "3", Title: "Backend Engineer", Skills: []string{"Go", "Kubernetes"}},
+	{ID: "4", Title: "Infrastructure Engineer", Skills: []string{"Kubernetes", "Terraform"}},
+	{ID: "5", Title: "Data Engineer", Skills: []string{"Go", "Python", "AWS", "Docker"}},
+	{ID: "6", Title: "Java Developer", Skills: []string{"Java"}},
+	{ID: "10", Title: "SRE", Skills: []string{"Go", "Kubernetes"}},
+}
+
+// getSimilar serves GET /api/v1/jobs/{id}/similar?query
+func getSimilar(t *testing.T, id, query string) (*httptest.ResponseRecorder, []SimilarJob) {
+	t.Helper()
+	w := httptest.NewRecorder()
+	jobByIDHandler(w, httptest.NewRequest(http.MethodGet, "/api/v1/jobs/"+id+"/similar?"+query, nil))
+	var resp struct {
+		Data []SimilarJob `json:"data"`
+	}
+	if w.Code == http.StatusOK {
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("decoding %s: %v", w.Body, err)
+		}
+	}
+	return w, resp.Data
+}
+
+func TestSimilarJobsOrdering(t *testing.T) {
+	withJobs(t, similarFixture...)
+
+	w, similar := getSimilar(t, "1", "limit=10")
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", w.Code, w.Body)
+	}
+	want := []struct {
+		id         string
+		similarity float64
+	}{{"2", 1}, {"3", 2.0 / 3}, {"10", 2.0 / 3}, {"5", 0.4}, {"4", 0.25}}
+	if len(similar) != len(want) {
+		t.Fatalf("got %d similar jobs, want %d: %+v", len(similar), len(want), similar)
+	}
+	for i, job := range want {
+		if similar[i].ID != job.id || math.Abs(similar[i].Similarity-job.similarity) > 1e-9 {
+			t.Errorf("similar[%d] = job %s at %g, want job %s at %g", i, similar[i].ID, similar[i].Similarity, job.id, job.similarity)
+		}
+	}
+}
+
+func TestSimilarJobsLimit(t *testing.T) {
+	withJobs(t, similarFixture...)
+
+	_, similar := getSimilar(t, "1", "")
+	if len(similar) != defaultSimilarLimit {
+		t.Errorf("default limit returned %d jobs, want %d", len(similar), defaultSimilarLimit)
+	}
+	_, similar = getSimilar(t, "1", "limit=2")
+	if len(similar) != 2 || similar[0].ID != "2" || similar[1].ID != "3" {
+		t.Errorf("limit=2 returned %+v, want jobs 2 and 3", similar)
+	}
+	for _, query := range []string{"limit=0", "limit=-1", "limit=many"} {
+		if w, _ := getSimilar(t, "1", query); w.Code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want %d", query, w.Code, http.StatusBadRequest)
+		}
+	}
+}
+
+func TestSimilarJobsUnknownJob(t *testing.T) {
+	withJobs(t, similarFixture...)
+
+	if w, _ := getSimilar(t, "42", ""); w.Code != http.StatusNotFound {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusNotFound)
+	}
+}
+
+func TestSimilarJobsWithoutOverlap(t *testing.T) {
+	withJobs(t, similarFixture...)
+
+	w, similar := getSimilar(t, "6", "")
+	if w.Code != http.StatusOK || similar == nil || len(similar) != 0 {
+		t.Fatalf("status %d with %+v, want 200 and an empty list", w.Code, similar)
+	}
+}
+
+func TestJaccardSimilarity(t *testing.T) {
+	tests := []struct {
+		a, b []string
+		want float64
+	}{
+		{[]string{"Go"}, []string{"golang"}, 1},
+		{[]string{"Go", "Go", "K8s"}, []string{"kubernetes", "go"}, 1},
+		{[]string{"Go", "AWS"}, []string{"Go", "Python"}, 1.0 / 3},
+		{[]string{"Go"}, []string{"Java"}, 0},
+		{nil, nil, 0},
+		{[]string{"", " "}, []string{"Go"}, 0},
+	}
+	for _, tt := range tests {
+		if got := jaccardSimilarity(tt.a, tt.b); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("jaccardSimilarity(%q, %q) = %g, want %g", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,180 @@
+// Debug request capture
+// When DEBUG_CAPTURE=true, recent requests are kept in a bounded ring buffer
+// and exposed on GET /debug/requests to help reproduce production bugs
+
+package httputil
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// CapturedRequest is a single request/response pair recorded by the capture buffer
+type CapturedRequest struct {
+	Time         time.Time `json:"time"`
+	Method       string    `json:"method"`
+	Path         string    `json:"path"`
+	BodyHash     string    `json:"bodyHash,omitempty"`
+	Status       int       `json:"status"`
+	DurationMs   float64   `json:"durationMs"`
+	RequestBody  string    `json:"requestBody,omitempty"`
+	ResponseBody string    `json:"responseBody,omitempty"`
+}
+
+// RequestCapture records the most recent requests in a fixed-size ring buffer.
+// Headers are never stored; bodies only when explicitly enabled.
+type RequestCapture struct {
+	mu      sync.Mutex
+	entries []CapturedRequest
+	next    int
+	full    bool
+
+	storeBodies bool
+	token       string
+}
+
+// NewRequestCaptureFromEnv returns nil unless DEBUG_CAPTURE=true
+func NewRequestCaptureFromEnv() *RequestCapture {
+	if os.Getenv("DEBUG_CAPTURE") != "true" {
+		return nil
+	}
+
+	size := 100
+	if v := os.Getenv("DEBUG_CAPTURE_SIZE"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			size = n
+		}
+	}
+
+	return &RequestCapture{
+		entries:     make([]CapturedRequest, size),
+		storeBodies: os.Getenv("DEBUG_CAPTURE_BODIES") == "true",
+		token:       os.Getenv("DEBUG_CAPTURE_TOKEN"),
+	}
+}
+
+func (c *RequestCapture) record(entry CapturedRequest) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.entries[c.next] = entry
+	c.next = (c.next + 1) % len(c.entries)
+	if c.next == 0 {
+		c.full = true
+	}
+}
+
+// snapshot returns the captured requests, oldest first
+func (c *RequestCapture) snapshot() []CapturedRequest {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.full {
+		return append([]CapturedRequest(nil), c.entries[:c.next]...)
+	}
+	return append(append([]CapturedRequest(nil), c.entries[c.next:]...), c.entries[:c.next]...)
+}
+
+// Middleware records every request except probes and the debug endpoint itself
+func (c *RequestCapture) Middleware(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/health", "/ready", "/startupz", "/debug/requests":
+			handler.ServeHTTP(w, r)
+			return
+		}
+
+		start := time.Now()
+		// Bounded like DecodeJSON, so a large upload isn't buffered whole
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, MaxBodyBytes))
+		if err != nil {
+			status, message := http.StatusBadRequest, "Failed to read request body"
+			var maxBytesErr *http.MaxBytesError
+			if errors.As(err, &maxBytesErr) {
+				status, message = http.StatusRequestEntityTooLarge, fmt.Sprintf("Request body must not exceed %d bytes", maxBytesErr.Limit)
+			}
+			WriteError(w, status, message)
+			c.record(CapturedRequest{
+				Time:       start,
+				Method:     r.Method,
+				Path:       r.URL.Path,
+				Status:     status,
+				DurationMs: float64(time.Since(start).Microseconds()) / 1000,
+			})
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		cw := &captureWriter{ResponseWriter: w, status: http.StatusOK, keepBody: c.storeBodies}
+		handler.ServeHTTP(cw, r)
+
+		entry := CapturedRequest{
+			Time:       start,
+			Method:     r.Method,
+			Path:       r.URL.Path,
+			Status:     cw.status,
+			DurationMs: float64(time.Since(start).Microseconds()) / 1000,
+		}
+		if len(body) > 0 {
+			sum := sha256.Sum256(body)
+			entry.BodyHash = hex.EncodeToString(sum[:])
+		}
+		if c.storeBodies {
+			entry.RequestBody = string(body)
+			entry.ResponseBody = cw.body.String()
+		}
+		c.record(entry)
+	})
+}
+
+// Handler serves GET /debug/requests to callers presenting DEBUG_CAPTURE_TOKEN
+func (c *RequestCapture) Handler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	auth := []byte(r.Header.Get("Authorization"))
+	if c.token == "" || subtle.ConstantTimeCompare(auth, []byte("Bearer "+c.token)) != 1 {
+		WriteError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	WriteJSON(w, http.StatusOK, Response{Status: "ok", Data: c.snapshot()})
+}
+
+// captureWriter records the status code (and optionally the body) written by a handler
+type captureWriter struct {
+	http.ResponseWriter
+	status   int
+	keepBody bool
+	body     bytes.Buffer
+}
+
+func (w *captureWriter) WriteHeader(status int) {
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *captureWriter) Write(b []byte) (int, error) {
+	if w.keepBody {
+		w.body.Write(b)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer, e.g. to flush
+func (w *captureWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
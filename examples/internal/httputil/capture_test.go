@@ -0,0 +1,176 @@
+package httputil
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// newTestCapture returns a capture of size entries behind the token "secret"
+func newTestCapture(size int, storeBodies bool) *RequestCapture {
+	return &RequestCapture{
+		entries:     make([]CapturedRequest, size),
+		storeBodies: storeBodies,
+		token:       "secret",
+	}
+}
+
+// echo replies 201 with the request body
+var echo = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	w.WriteHeader(http.StatusCreated)
+	w.Write(body)
+})
+
+// captured fetches /debug/requests from c with the right token
+func captured(t *testing.T, c *RequestCapture) []CapturedRequest {
+	t.Helper()
+	r := httptest.NewRequest(http.MethodGet, "/debug/requests", nil)
+	r.Header.Set("Authorization", "Bearer secret")
+	w := httptest.NewRecorder()
+	c.Handler(w, r)
+	if w.Code != http.StatusOK {
+		t.Fatalf("GET /debug/requests = %d, want %d", w.Code, http.StatusOK)
+	}
+	var resp struct {
+		Data []CapturedRequest `json:"data"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	return resp.Data
+}
+
+func TestCaptureRecordsRequests(t *testing.T) {
+	c := newTestCapture(10, false)
+	handler := c.Middleware(echo)
+
+	for _, path := range []string{"/api/jobs", "/health", "/ready", "/startupz", "/api/candidates"} {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, path, strings.NewReader(`{"title":"Go"}`)))
+	}
+
+	entries := captured(t, c)
+	if len(entries) != 2 {
+		t.Fatalf("captured %d requests, want 2 with the probes skipped", len(entries))
+	}
+	for i, path := range []string{"/api/jobs", "/api/candidates"} {
+		e := entries[i]
+		if e.Path != path || e.Method != http.MethodPost || e.Status != http.StatusCreated {
+			t.Errorf("entry %d = %s %s %d, want POST %s 201", i, e.Method, e.Path, e.Status, path)
+		}
+		if e.BodyHash == "" {
+			t.Errorf("entry %d has no body hash", i)
+		}
+		if e.RequestBody != "" || e.ResponseBody != "" {
+			t.Errorf("entry %d stored bodies with DEBUG_CAPTURE_BODIES unset", i)
+		}
+	}
+}
+
+func TestCaptureStoresBodies(t *testing.T) {
+	c := newTestCapture(10, true)
+	w := httptest.NewRecorder()
+	c.Middleware(echo).ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/jobs", strings.NewReader(`{"title":"Go"}`)))
+
+	// The handler still sees the whole body
+	if got := w.Body.String(); got != `{"title":"Go"}` {
+		t.Fatalf("handler echoed %q", got)
+	}
+	entries := captured(t, c)
+	if len(entries) != 1 || entries[0].RequestBody != `{"title":"Go"}` || entries[0].ResponseBody != `{"title":"Go"}` {
+		t.Fatalf("captured %+v, want the request and response bodies", entries)
+	}
+}
+
+func TestCaptureBufferWraps(t *testing.T) {
+	c := newTestCapture(3, false)
+	handler := c.Middleware(echo)
+	for _, path := range []string{"/1", "/2", "/3", "/4", "/5"} {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	entries := captured(t, c)
+	var paths []string
+	for _, e := range entries {
+		paths = append(paths, e.Path)
+	}
+	if got := strings.Join(paths, ","); got != "/3,/4,/5" {
+		t.Fatalf("captured %s, want the newest three oldest first", got)
+	}
+}
+
+func TestCaptureRejectsOversizedBody(t *testing.T) {
+	c := newTestCapture(10, false)
+	called := false
+	handler := c.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { called = true }))
+
+	w := httptest.NewRecorder()
+	body := strings.NewReader(strings.Repeat("x", int(MaxBodyBytes)+1))
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/jobs", body))
+
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusRequestEntityTooLarge)
+	}
+	if called {
+		t.Fatal("handler called with an oversized body")
+	}
+	entries := captured(t, c)
+	if len(entries) != 1 || entries[0].Status != http.StatusRequestEntityTooLarge {
+		t.Fatalf("captured %+v, want the rejected request", entries)
+	}
+}
+
+func TestCaptureHandlerAuth(t *testing.T) {
+	tests := []struct {
+		name   string
+		token  string
+		method string
+		auth   string
+		want   int
+	}{
+		{"right token", "secret", http.MethodGet, "Bearer secret", http.StatusOK},
+		{"wrong token", "secret", http.MethodGet, "Bearer guess", http.StatusUnauthorized},
+		{"no token", "secret", http.MethodGet, "", http.StatusUnauthorized},
+		{"token not configured", "", http.MethodGet, "Bearer ", http.StatusUnauthorized},
+		{"not a GET", "secret", http.MethodPost, "Bearer secret", http.StatusMethodNotAllowed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newTestCapture(1, false)
+			c.token = tt.token
+			r := httptest.NewRequest(tt.method, "/debug/requests", nil)
+			if tt.auth != "" {
+				r.Header.Set("Authorization", tt.auth)
+			}
+			w := httptest.NewRecorder()
+			c.Handler(w, r)
+			if w.Code != tt.want {
+				t.Fatalf("status = %d, want %d", w.Code, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewRequestCaptureFromEnv(t *testing.T) {
+	t.Setenv("DEBUG_CAPTURE", "")
+	if NewRequestCaptureFromEnv() != nil {
+		t.Fatal("capture enabled without DEBUG_CAPTURE=true")
+	}
+
+	t.Setenv("DEBUG_CAPTURE", "true")
+	t.Setenv("DEBUG_CAPTURE_SIZE", "7")
+	t.Setenv("DEBUG_CAPTURE_BODIES", "true")
+	t.Setenv("DEBUG_CAPTURE_TOKEN", "secret")
+	c := NewRequestCaptureFromEnv()
+	if c == nil || len(c.entries) != 7 || !c.storeBodies || c.token != "secret" {
+		t.Fatalf("NewRequestCaptureFromEnv() = %+v", c)
+	}
+
+	t.Setenv("DEBUG_CAPTURE_SIZE", "-1")
+	if c := NewRequestCaptureFromEnv(); len(c.entries) != 100 {
+		t.Fatalf("invalid size gave %d entries, want the default 100", len(c.entries))
+	}
+}
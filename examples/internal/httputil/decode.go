@@ -0,0 +1,105 @@
+// Request decoding
+// Every handler decodes JSON bodies through DecodeJSON so malformed input
+// always produces a clean 4xx instead of a panic or a half-decoded value
+
+package httputil
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// defaultMaxBodyBytes caps request bodies unless MAX_BODY_BYTES overrides it
+const defaultMaxBodyBytes = 1 << 20
+
+// MaxBodyBytes is the largest request body DecodeJSON accepts
+var MaxBodyBytes = maxBodyBytesFromEnv()
+
+func maxBodyBytesFromEnv() int64 {
+	if v := os.Getenv("MAX_BODY_BYTES"); v != "" {
+		if n, err := strconv.ParseInt(v, 10, 64); err == nil && n > 0 {
+			return n
+		}
+	}
+	return defaultMaxBodyBytes
+}
+
+// DecodeError is returned by DecodeJSON and carries the HTTP status to reply with
+type DecodeError struct {
+	Status  int
+	Message string
+}
+
+func (e *DecodeError) Error() string {
+	return e.Message
+}
+
+// DecodeJSON decodes a single JSON value from the request body into v.
+// Bodies over MaxBodyBytes, unknown fields and trailing data are rejected.
+func DecodeJSON(w http.ResponseWriter, r *http.Request, v any) error {
+	return DecodeJSONBody(http.MaxBytesReader(w, r.Body, MaxBodyBytes), v)
+}
+
+// DecodeJSONBody does the work for DecodeJSON on a plain reader
+func DecodeJSONBody(body io.Reader, v any) (err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			log.Printf("recovered from panic decoding request body: %v", p)
+			err = &DecodeError{Status: http.StatusBadRequest, Message: "Malformed JSON body"}
+		}
+	}()
+
+	dec := json.NewDecoder(body)
+	dec.DisallowUnknownFields()
+
+	if err := dec.Decode(v); err != nil {
+		return decodeErrorFor(err)
+	}
+	if err := dec.Decode(&struct{}{}); err != io.EOF {
+		return &DecodeError{Status: http.StatusBadRequest, Message: "Request body must contain a single JSON value"}
+	}
+	return nil
+}
+
+// decodeErrorFor maps an encoding/json error to a client-facing DecodeError
+func decodeErrorFor(err error) *DecodeError {
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	var maxBytesErr *http.MaxBytesError
+
+	switch {
+	case errors.As(err, &maxBytesErr):
+		return &DecodeError{Status: http.StatusRequestEntityTooLarge, Message: fmt.Sprintf("Request body must not exceed %d bytes", maxBytesErr.Limit)}
+	case errors.As(err, &syntaxErr):
+		return &DecodeError{Status: http.StatusBadRequest, Message: fmt.Sprintf("Malformed JSON at byte %d", syntaxErr.Offset)}
+	case errors.As(err, &typeErr):
+		return &DecodeError{Status: http.StatusBadRequest, Message: fmt.Sprintf("Invalid value for field %q", typeErr.Field)}
+	case errors.Is(err, io.EOF):
+		return &DecodeError{Status: http.StatusBadRequest, Message: "Request body must not be empty"}
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		return &DecodeError{Status: http.StatusBadRequest, Message: "Request body is truncated"}
+	}
+
+	// DisallowUnknownFields has no typed error
+	if field, ok := strings.CutPrefix(err.Error(), "json: unknown field "); ok {
+		return &DecodeError{Status: http.StatusBadRequest, Message: fmt.Sprintf("Unknown field %s", field)}
+	}
+	return &DecodeError{Status: http.StatusBadRequest, Message: "Malformed JSON body"}
+}
+
+// WriteDecodeError replies with the status and message carried by a DecodeJSON error
+func WriteDecodeError(w http.ResponseWriter, err error) {
+	status := http.StatusBadRequest
+	var de *DecodeError
+	if errors.As(err, &de) {
+		status = de.Status
+	}
+	WriteError(w, status, err.Error())
+}
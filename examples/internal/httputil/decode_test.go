@@ -0,0 +1,57 @@
+package httputil
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// decodeTarget stands in for the create bodies of the APIs, which live in
+// their main packages along with their fuzz targets
+type decodeTarget struct {
+	Name   string   `json:"name"`
+	Skills []string `json:"skills"`
+}
+
+func TestDecodeJSONBodyErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		body   string
+		status int
+		msg    string
+	}{
+		{name: "empty", body: ``, status: http.StatusBadRequest, msg: "Request body must not be empty"},
+		{name: "truncated", body: `{"name":"Go`, status: http.StatusBadRequest, msg: "Request body is truncated"},
+		{name: "syntax", body: `{"name":}`, status: http.StatusBadRequest, msg: "Malformed JSON at byte 9"},
+		{name: "wrong type", body: `{"skills":"Go"}`, status: http.StatusBadRequest, msg: `Invalid value for field "skills"`},
+		{name: "unknown field", body: `{"id":"1"}`, status: http.StatusBadRequest, msg: `Unknown field "id"`},
+		{name: "trailing data", body: `{} {}`, status: http.StatusBadRequest, msg: "Request body must contain a single JSON value"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := DecodeJSONBody(strings.NewReader(tt.body), &decodeTarget{})
+			var de *DecodeError
+			if !errors.As(err, &de) || de.Status != tt.status || de.Message != tt.msg {
+				t.Fatalf("DecodeJSONBody(%q) = %v, want a %d DecodeError %q", tt.body, err, tt.status, tt.msg)
+			}
+		})
+	}
+
+	var v decodeTarget
+	if err := DecodeJSONBody(strings.NewReader(`{"name":"Alice","skills":["Go"]}`), &v); err != nil || v.Name != "Alice" {
+		t.Fatalf("DecodeJSONBody() = %v, %+v, want Alice decoded", err, v)
+	}
+}
+
+func TestDecodeJSONRejectsOversizedBody(t *testing.T) {
+	body := `{"name":"` + strings.Repeat("x", int(MaxBodyBytes)) + `"}`
+	r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+
+	err := DecodeJSON(httptest.NewRecorder(), r, &decodeTarget{})
+	var de *DecodeError
+	if !errors.As(err, &de) || de.Status != http.StatusRequestEntityTooLarge {
+		t.Fatalf("DecodeJSON = %v, want a %d DecodeError", err, http.StatusRequestEntityTooLarge)
+	}
+}
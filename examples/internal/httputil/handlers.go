@@ -0,0 +1,47 @@
+// Shared handlers
+// Home, probe and request logging handlers every example API serves
+
+package httputil
+
+import (
+	"log"
+	"net/http"
+)
+
+// LogRequests logs the remote address, method and URL of every request
+func LogRequests(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		log.Printf("%s %s %s", r.RemoteAddr, r.Method, r.URL)
+		handler.ServeHTTP(w, r)
+	})
+}
+
+// HomeHandler serves GET / with message and 404s every other unrouted path
+func HomeHandler(message string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			http.NotFound(w, r)
+			return
+		}
+		WriteJSON(w, http.StatusOK, Response{Status: "ok", Message: message})
+	}
+}
+
+// HealthHandler serves the liveness probe
+func HealthHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	WriteJSON(w, http.StatusOK, Response{Status: "healthy"})
+}
+
+// ReadyHandler serves the readiness probe, answering 503 until started
+// reports true
+func ReadyHandler(started func() bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if !started() {
+			WriteJSON(w, http.StatusServiceUnavailable, Response{Status: "starting"})
+			return
+		}
+		WriteJSON(w, http.StatusOK, Response{Status: "ready"})
+	}
+}
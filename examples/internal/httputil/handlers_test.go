@@ -0,0 +1,81 @@
+package httputil
+
+import (
+	"bytes"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestHomeHandler(t *testing.T) {
+	home := HomeHandler("Hirer API")
+
+	w := httptest.NewRecorder()
+	home(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("/ = %d, want %d", w.Code, http.StatusOK)
+	}
+	if want := `{"status":"ok","message":"Hirer API"}` + "\n"; w.Body.String() != want {
+		t.Fatalf("/ body = %q, want %q", w.Body, want)
+	}
+
+	// Unrouted paths fall through to the / pattern and must 404
+	w = httptest.NewRecorder()
+	home(w, httptest.NewRequest(http.MethodGet, "/nope", nil))
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("/nope = %d, want %d", w.Code, http.StatusNotFound)
+	}
+}
+
+func TestHealthHandler(t *testing.T) {
+	w := httptest.NewRecorder()
+	HealthHandler(w, httptest.NewRequest(http.MethodGet, "/health", nil))
+
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "application/json" {
+		t.Fatalf("/health = %d %q, want %d application/json", w.Code, w.Header().Get("Content-Type"), http.StatusOK)
+	}
+	if want := `{"status":"healthy"}` + "\n"; w.Body.String() != want {
+		t.Fatalf("/health body = %q, want %q", w.Body, want)
+	}
+}
+
+func TestReadyHandler(t *testing.T) {
+	tests := []struct {
+		started bool
+		code    int
+		body    string
+	}{
+		{started: false, code: http.StatusServiceUnavailable, body: `{"status":"starting"}` + "\n"},
+		{started: true, code: http.StatusOK, body: `{"status":"ready"}` + "\n"},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		ReadyHandler(func() bool { return tt.started })(w, httptest.NewRequest(http.MethodGet, "/ready", nil))
+		if w.Code != tt.code || w.Body.String() != tt.body || w.Header().Get("Content-Type") != "application/json" {
+			t.Errorf("started %v: /ready = %d %q %q, want %d %q", tt.started, w.Code, w.Header().Get("Content-Type"), w.Body, tt.code, tt.body)
+		}
+	}
+}
+
+func TestLogRequests(t *testing.T) {
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+
+	handler := LogRequests(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	}))
+	r := httptest.NewRequest(http.MethodPost, "/api/v1/jobs?limit=5", nil)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+
+	if w.Code != http.StatusTeapot {
+		t.Fatalf("status = %d, want the wrapped handler's %d", w.Code, http.StatusTeapot)
+	}
+	if want := r.RemoteAddr + " POST /api/v1/jobs?limit=5"; !strings.Contains(buf.String(), want) {
+		t.Fatalf("log = %q, want it to contain %q", buf.String(), want)
+	}
+}
@@ -0,0 +1,76 @@
+// Field length limits
+// Text fields longer than their soft limit are truncated with a warning;
+// anything over the hard limit is rejected with 422
+
+package httputil
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"unicode/utf8"
+)
+
+// FieldLimit bounds the length of a text field, in characters
+type FieldLimit struct {
+	Soft int
+	Hard int
+}
+
+var (
+	// TextLimit applies to short text fields and to each skill
+	TextLimit = FieldLimitFromEnv("TEXT_FIELD", 200, 1000)
+	// MaxSkills caps the number of skills on a record
+	MaxSkills = EnvInt("MAX_SKILLS", 50)
+)
+
+// FieldLimitFromEnv reads <prefix>_SOFT_LIMIT and <prefix>_HARD_LIMIT. A soft
+// limit at or above the hard limit disables truncation.
+func FieldLimitFromEnv(prefix string, soft, hard int) FieldLimit {
+	limit := FieldLimit{
+		Soft: EnvInt(prefix+"_SOFT_LIMIT", soft),
+		Hard: EnvInt(prefix+"_HARD_LIMIT", hard),
+	}
+	if limit.Soft > limit.Hard {
+		limit.Soft = limit.Hard
+	}
+	return limit
+}
+
+// EnvInt returns the positive integer in the env var name, or def if it is
+// unset or invalid
+func EnvInt(name string, def int) int {
+	if v := os.Getenv(name); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			return n
+		}
+	}
+	return def
+}
+
+// Apply rejects value if it exceeds the hard limit, or truncates it to the
+// soft limit and records a warning
+func (l FieldLimit) Apply(field string, value *string, warnings *[]string) error {
+	length := utf8.RuneCountInString(*value)
+	if length > l.Hard {
+		return fmt.Errorf("%s is %d characters, maximum is %d", field, length, l.Hard)
+	}
+	if length > l.Soft {
+		*value = string([]rune(*value)[:l.Soft])
+		*warnings = append(*warnings, fmt.Sprintf("%s truncated from %d to %d characters", field, length, l.Soft))
+	}
+	return nil
+}
+
+// ApplySkillLimits rejects more than MaxSkills skills and applies TextLimit to each
+func ApplySkillLimits(skills []string, warnings *[]string) error {
+	if len(skills) > MaxSkills {
+		return fmt.Errorf("skills has %d entries, maximum is %d", len(skills), MaxSkills)
+	}
+	for i := range skills {
+		if err := TextLimit.Apply(fmt.Sprintf("skills[%d]", i), &skills[i], warnings); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,88 @@
+package httputil
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFieldLimitApply(t *testing.T) {
+	limit := FieldLimit{Soft: 5, Hard: 10}
+	tests := []struct {
+		name    string
+		value   string
+		want    string
+		warning string
+		err     string
+	}{
+		{name: "within the soft limit", value: "hello", want: "hello"},
+		{name: "truncated", value: "hello you", want: "hello", warning: "title truncated from 9 to 5 characters"},
+		{name: "counted in characters", value: "héllö wörl", want: "héllö", warning: "title truncated from 10 to 5 characters"},
+		{name: "over the hard limit", value: "hello, world", want: "hello, world", err: "title is 12 characters, maximum is 10"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			value := tt.value
+			var warnings []string
+			err := limit.Apply("title", &value, &warnings)
+			if tt.err != "" {
+				if err == nil || err.Error() != tt.err {
+					t.Fatalf("Apply() = %v, want %q", err, tt.err)
+				}
+			} else if err != nil {
+				t.Fatalf("Apply() = %v", err)
+			}
+			if value != tt.want {
+				t.Fatalf("value = %q, want %q", value, tt.want)
+			}
+			if tt.warning == "" && len(warnings) != 0 || tt.warning != "" && (len(warnings) != 1 || warnings[0] != tt.warning) {
+				t.Fatalf("warnings = %q, want %q", warnings, tt.warning)
+			}
+		})
+	}
+}
+
+func TestApplySkillLimits(t *testing.T) {
+	defer func(limit FieldLimit, max int) { TextLimit, MaxSkills = limit, max }(TextLimit, MaxSkills)
+	TextLimit, MaxSkills = FieldLimit{Soft: 3, Hard: 5}, 2
+
+	skills := []string{"Go", "Kube"}
+	var warnings []string
+	if err := ApplySkillLimits(skills, &warnings); err != nil {
+		t.Fatal(err)
+	}
+	if skills[1] != "Kub" || len(warnings) != 1 || !strings.HasPrefix(warnings[0], "skills[1] truncated") {
+		t.Fatalf("skills = %q, warnings = %q, want skills[1] truncated", skills, warnings)
+	}
+
+	if err := ApplySkillLimits([]string{"a", "b", "c"}, &warnings); err == nil || err.Error() != "skills has 3 entries, maximum is 2" {
+		t.Fatalf("ApplySkillLimits(3 skills) = %v, want the count rejected", err)
+	}
+}
+
+func TestFieldLimitFromEnv(t *testing.T) {
+	t.Setenv("TEST_SOFT_LIMIT", "50")
+	t.Setenv("TEST_HARD_LIMIT", "20")
+	// A soft limit above the hard one is capped, disabling truncation
+	if got := FieldLimitFromEnv("TEST", 10, 100); got != (FieldLimit{Soft: 20, Hard: 20}) {
+		t.Fatalf("FieldLimitFromEnv() = %+v, want {20 20}", got)
+	}
+}
+
+func TestEnvInt(t *testing.T) {
+	tests := []struct {
+		value string
+		want  int
+	}{
+		{"", 7},
+		{"42", 42},
+		{"0", 7},
+		{"-3", 7},
+		{"many", 7},
+	}
+	for _, tt := range tests {
+		t.Setenv("TEST_ENV_INT", tt.value)
+		if got := EnvInt("TEST_ENV_INT", 7); got != tt.want {
+			t.Errorf("EnvInt(%q) = %d, want %d", tt.value, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,83 @@
+// Page size ceiling
+// List responses never carry more than MAX_PAGE_SIZE records, whatever limit
+// the client asks for; truncated responses say so, and paged lists say where
+// the next page starts
+
+package httputil
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// defaultMaxPageSize applies unless MAX_PAGE_SIZE overrides it
+const defaultMaxPageSize = 100
+
+// MaxPageSize is the most records a list response may carry
+var MaxPageSize = EnvInt("MAX_PAGE_SIZE", defaultMaxPageSize)
+
+// CapPage trims items to MaxPageSize and reports whether any were dropped
+func CapPage[T any](items []T) ([]T, bool) {
+	if len(items) <= MaxPageSize {
+		return items, false
+	}
+	return items[:MaxPageSize], true
+}
+
+// Page is the window of a list a client asked for: up to Limit records
+// starting at Offset
+type Page struct {
+	Offset int
+	Limit  int
+}
+
+// ParsePage reads the offset (default 0) and limit query parameters. The
+// limit defaults to MaxPageSize and is cut to it when larger.
+func ParsePage(query url.Values) (Page, error) {
+	page := Page{Limit: MaxPageSize}
+	if v := query.Get("offset"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return Page{}, fmt.Errorf("offset must be a non-negative integer")
+		}
+		page.Offset = n
+	}
+	if v := query.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			return Page{}, fmt.Errorf("limit must be a positive integer")
+		}
+		page.Limit = min(n, MaxPageSize)
+	}
+	return page, nil
+}
+
+// Paginate returns the records of items in page, and the offset of the
+// records following them, or 0 when the page reaches the end of items
+func Paginate[T any](items []T, page Page) ([]T, int) {
+	start := min(page.Offset, len(items))
+	end := min(start+page.Limit, len(items))
+	if end == len(items) {
+		return items[start:end], 0
+	}
+	return items[start:end], end
+}
+
+// WritePage writes the records of items in page as an ok Response. When
+// records follow the page, the response is marked truncated and NextOffset
+// points at them.
+func WritePage[T any](w http.ResponseWriter, items []T, page Page) {
+	records, next := Paginate(items, page)
+	if next > 0 {
+		MarkTruncated(w)
+	}
+	WriteJSON(w, http.StatusOK, Response{Status: "ok", Data: records, Truncated: next > 0, NextOffset: next})
+}
+
+// MarkTruncated sets the X-Result-Truncated header. It must be called before
+// the response status is written.
+func MarkTruncated(w http.ResponseWriter) {
+	w.Header().Set("X-Result-Truncated", "true")
+}
@@ -0,0 +1,119 @@
+package httputil
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func TestCapPage(t *testing.T) {
+	saved := MaxPageSize
+	MaxPageSize = 3
+	t.Cleanup(func() { MaxPageSize = saved })
+
+	tests := []struct {
+		items     []int
+		want      int
+		truncated bool
+	}{
+		{items: nil, want: 0},
+		{items: []int{1, 2}, want: 2},
+		{items: []int{1, 2, 3}, want: 3},
+		{items: []int{1, 2, 3, 4, 5}, want: 3, truncated: true},
+	}
+	for _, tt := range tests {
+		page, truncated := CapPage(tt.items)
+		if len(page) != tt.want || truncated != tt.truncated {
+			t.Errorf("CapPage(%v) = %v, %v, want %d items, truncated %v", tt.items, page, truncated, tt.want, tt.truncated)
+		}
+		if len(page) > 0 && page[0] != 1 {
+			t.Errorf("CapPage(%v) = %v, want the first items", tt.items, page)
+		}
+	}
+}
+
+func TestParsePage(t *testing.T) {
+	saved := MaxPageSize
+	MaxPageSize = 3
+	t.Cleanup(func() { MaxPageSize = saved })
+
+	tests := []struct {
+		query   string
+		want    Page
+		wantErr bool
+	}{
+		{query: "", want: Page{Limit: 3}},
+		{query: "offset=4&limit=2", want: Page{Offset: 4, Limit: 2}},
+		{query: "limit=10", want: Page{Limit: 3}},
+		{query: "offset=-1", wantErr: true},
+		{query: "offset=two", wantErr: true},
+		{query: "limit=0", wantErr: true},
+	}
+	for _, tt := range tests {
+		query, _ := url.ParseQuery(tt.query)
+		page, err := ParsePage(query)
+		if (err != nil) != tt.wantErr || page != tt.want {
+			t.Errorf("ParsePage(%q) = %+v, %v, want %+v, error %v", tt.query, page, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestPaginate(t *testing.T) {
+	items := []int{1, 2, 3, 4, 5}
+	tests := []struct {
+		page  Page
+		first int
+		n     int
+		next  int
+	}{
+		{page: Page{Limit: 2}, first: 1, n: 2, next: 2},
+		{page: Page{Offset: 2, Limit: 2}, first: 3, n: 2, next: 4},
+		{page: Page{Offset: 4, Limit: 2}, first: 5, n: 1},
+		{page: Page{Offset: 3, Limit: 2}, first: 4, n: 2},
+		{page: Page{Offset: 9, Limit: 2}},
+	}
+	for _, tt := range tests {
+		records, next := Paginate(items, tt.page)
+		if len(records) != tt.n || next != tt.next || (tt.n > 0 && records[0] != tt.first) {
+			t.Errorf("Paginate(%+v) = %v, %d, want %d records from %d, next %d", tt.page, records, next, tt.n, tt.first, tt.next)
+		}
+	}
+}
+
+func TestWritePage(t *testing.T) {
+	w := httptest.NewRecorder()
+	WritePage(w, []int{1, 2, 3}, Page{Offset: 1, Limit: 1})
+	if got := w.Header().Get("X-Result-Truncated"); got != "true" {
+		t.Fatalf("X-Result-Truncated = %q, want true", got)
+	}
+	if got, want := w.Body.String(), `{"status":"ok","data":[2],"truncated":true,"nextOffset":2}`+"\n"; got != want {
+		t.Fatalf("body = %q, want %q", got, want)
+	}
+
+	// The last page has no next offset
+	w = httptest.NewRecorder()
+	WritePage(w, []int{1, 2, 3}, Page{Offset: 2, Limit: 5})
+	if got, want := w.Body.String(), `{"status":"ok","data":[3]}`+"\n"; got != want {
+		t.Fatalf("body = %q, want %q", got, want)
+	}
+}
+
+func TestMarkTruncated(t *testing.T) {
+	w := httptest.NewRecorder()
+	MarkTruncated(w)
+	WriteJSON(w, http.StatusOK, Response{Status: "ok", Data: []int{1}, Truncated: true})
+	if got := w.Header().Get("X-Result-Truncated"); got != "true" {
+		t.Fatalf("X-Result-Truncated = %q, want true", got)
+	}
+	if got, want := w.Body.String(), `{"status":"ok","data":[1],"truncated":true}`+"\n"; got != want {
+		t.Fatalf("body = %q, want %q", got, want)
+	}
+
+	// Untruncated responses leave the flag out
+	w = httptest.NewRecorder()
+	WriteJSON(w, http.StatusOK, Response{Status: "ok", Data: []int{1}})
+	if got, want := w.Body.String(), `{"status":"ok","data":[1]}`+"\n"; got != want {
+		t.Fatalf("body = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,34 @@
+// Package httputil holds the HTTP plumbing shared by the example APIs: the
+// response envelope, probe handlers and middleware, and request decoding
+package httputil
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// Response is a generic API response
+type Response struct {
+	Status  string      `json:"status"`
+	Data    interface{} `json:"data,omitempty"`
+	Message string      `json:"message,omitempty"`
+	// Warnings lists non-fatal problems, e.g. truncated fields
+	Warnings []string `json:"warnings,omitempty"`
+	// Truncated is set when a list was cut at MAX_PAGE_SIZE records, or
+	// when more records follow a page
+	Truncated bool `json:"truncated,omitempty"`
+	// NextOffset is the offset of the next page of a truncated paged list
+	NextOffset int `json:"nextOffset,omitempty"`
+}
+
+// WriteJSON writes status and v encoded as JSON. Headers, including
+// Content-Type, must be set before calling it.
+func WriteJSON(w http.ResponseWriter, status int, v any) {
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// WriteError writes status with an error Response carrying message
+func WriteError(w http.ResponseWriter, status int, message string) {
+	WriteJSON(w, status, Response{Status: "error", Message: message})
+}
@@ -0,0 +1,32 @@
+package httputil
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestWriteJSON(t *testing.T) {
+	w := httptest.NewRecorder()
+	WriteJSON(w, http.StatusCreated, Response{Status: "success", Data: map[string]int{"id": 1}})
+
+	if w.Code != http.StatusCreated {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusCreated)
+	}
+	// json.Encoder output, trailing newline included, as the APIs always sent
+	if want := `{"status":"success","data":{"id":1}}` + "\n"; w.Body.String() != want {
+		t.Fatalf("body = %q, want %q", w.Body, want)
+	}
+}
+
+func TestWriteError(t *testing.T) {
+	w := httptest.NewRecorder()
+	WriteError(w, http.StatusNotFound, "Job not found")
+
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusNotFound)
+	}
+	if want := `{"status":"error","message":"Job not found"}` + "\n"; w.Body.String() != want {
+		t.Fatalf("body = %q, want %q", w.Body, want)
+	}
+}
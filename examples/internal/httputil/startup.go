@@ -0,0 +1,96 @@
+// Startup tracking
+// /startupz reports 200 only once every initialization phase has finished,
+// for a Kubernetes startupProbe; until then API requests get 503
+
+package httputil
+
+import (
+	"net/http"
+	"sync"
+)
+
+// StartupPhase is the state of one initialization phase as shown by /startupz
+type StartupPhase struct {
+	Name string `json:"name"`
+	Done bool   `json:"done"`
+}
+
+// StartupTracker records which initialization phases have completed
+type StartupTracker struct {
+	mu     sync.Mutex
+	phases []StartupPhase
+}
+
+// NewStartupTracker returns a tracker for phases, none of them done
+func NewStartupTracker(phases ...string) *StartupTracker {
+	t := &StartupTracker{}
+	for _, name := range phases {
+		t.phases = append(t.phases, StartupPhase{Name: name})
+	}
+	return t
+}
+
+// Complete marks phase as done
+func (t *StartupTracker) Complete(phase string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for i := range t.phases {
+		if t.phases[i].Name == phase {
+			t.phases[i].Done = true
+		}
+	}
+}
+
+// Pending returns the first phase not yet done, or "" once started
+func (t *StartupTracker) Pending() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for _, p := range t.phases {
+		if !p.Done {
+			return p.Name
+		}
+	}
+	return ""
+}
+
+// Started reports whether every phase is done
+func (t *StartupTracker) Started() bool {
+	return t.Pending() == ""
+}
+
+func (t *StartupTracker) snapshot() []StartupPhase {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return append([]StartupPhase(nil), t.phases...)
+}
+
+// Handler serves GET /startupz
+func (t *StartupTracker) Handler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if phase := t.Pending(); phase != "" {
+		WriteJSON(w, http.StatusServiceUnavailable, Response{Status: "starting", Message: "Waiting for " + phase, Data: t.snapshot()})
+		return
+	}
+	WriteJSON(w, http.StatusOK, Response{Status: "started", Data: t.snapshot()})
+}
+
+// Gate answers 503 to everything but the probes until startup has finished
+func (t *StartupTracker) Gate(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/health", "/ready", "/startupz":
+			handler.ServeHTTP(w, r)
+			return
+		}
+		if phase := t.Pending(); phase != "" {
+			w.Header().Set("Content-Type", "application/json")
+			WriteJSON(w, http.StatusServiceUnavailable, Response{Status: "starting", Message: "Waiting for " + phase})
+			return
+		}
+		handler.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,113 @@
+package httputil
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// startupz fetches /startupz from tracker and decodes the response
+func startupz(t *testing.T, tracker *StartupTracker) (int, Response, []StartupPhase) {
+	t.Helper()
+	w := httptest.NewRecorder()
+	tracker.Handler(w, httptest.NewRequest(http.MethodGet, "/startupz", nil))
+	var resp struct {
+		Response
+		Data []StartupPhase `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decoding %s: %v", w.Body, err)
+	}
+	return w.Code, resp.Response, resp.Data
+}
+
+func TestStartupzInitializingToStarted(t *testing.T) {
+	tracker := NewStartupTracker("config", "store")
+
+	steps := []struct {
+		complete string
+		code     int
+		status   string
+		message  string
+		done     []bool
+	}{
+		{code: http.StatusServiceUnavailable, status: "starting", message: "Waiting for config", done: []bool{false, false}},
+		// Phases complete in any order; the first pending one is reported
+		{complete: "store", code: http.StatusServiceUnavailable, status: "starting", message: "Waiting for config", done: []bool{false, true}},
+		{complete: "unknown", code: http.StatusServiceUnavailable, status: "starting", message: "Waiting for config", done: []bool{false, true}},
+		{complete: "config", code: http.StatusOK, status: "started", done: []bool{true, true}},
+	}
+	for _, step := range steps {
+		if step.complete != "" {
+			tracker.Complete(step.complete)
+		}
+		code, resp, phases := startupz(t, tracker)
+		if code != step.code || resp.Status != step.status || resp.Message != step.message {
+			t.Fatalf("after completing %q: %d %+v, want %d %s %q", step.complete, code, resp, step.code, step.status, step.message)
+		}
+		if len(phases) != 2 || phases[0].Name != "config" || phases[1].Name != "store" ||
+			phases[0].Done != step.done[0] || phases[1].Done != step.done[1] {
+			t.Fatalf("after completing %q: phases = %+v, want done %v", step.complete, phases, step.done)
+		}
+		if tracker.Started() != (step.code == http.StatusOK) {
+			t.Fatalf("after completing %q: Started() = %v", step.complete, tracker.Started())
+		}
+	}
+}
+
+func TestStartupTrackerWithoutPhases(t *testing.T) {
+	tracker := NewStartupTracker()
+	if !tracker.Started() || tracker.Pending() != "" {
+		t.Fatal("tracker without phases not started")
+	}
+}
+
+func TestStartupGate(t *testing.T) {
+	tracker := NewStartupTracker("store")
+	handler := tracker.Gate(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	}))
+	serve := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		return w
+	}
+
+	// The probes always get through, the API only once started
+	for _, path := range []string{"/health", "/ready", "/startupz"} {
+		if w := serve(path); w.Code != http.StatusTeapot {
+			t.Errorf("%s while starting: status = %d, want it passed through", path, w.Code)
+		}
+	}
+	w := serve("/api/v1/jobs")
+	if w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("API while starting: status = %d, want %d", w.Code, http.StatusServiceUnavailable)
+	}
+	if want := `{"status":"starting","message":"Waiting for store"}` + "\n"; w.Body.String() != want {
+		t.Fatalf("API while starting: body = %q, want %q", w.Body, want)
+	}
+
+	tracker.Complete("store")
+	if w := serve("/api/v1/jobs"); w.Code != http.StatusTeapot {
+		t.Fatalf("API once started: status = %d, want it passed through", w.Code)
+	}
+}
+
+func TestReadyHandlerFollowsStartup(t *testing.T) {
+	tracker := NewStartupTracker("store")
+	ready := ReadyHandler(tracker.Started)
+
+	w := httptest.NewRecorder()
+	ready(w, httptest.NewRequest(http.MethodGet, "/ready", nil))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("/ready while starting = %d, want %d", w.Code, http.StatusServiceUnavailable)
+	}
+
+	tracker.Complete("store")
+	w = httptest.NewRecorder()
+	ready(w, httptest.NewRequest(http.MethodGet, "/ready", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("/ready once started = %d, want %d", w.Code, http.StatusOK)
+	}
+}
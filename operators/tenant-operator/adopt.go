@@ -0,0 +1,121 @@
+// Resource adoption
+// Tenant resources created before the operator was installed (e.g. from
+// tenants/*/tenant.yaml) are adopted rather than ignored, so they are
+// garbage collected and reconciled like the ones the operator created.
+// Everything is server-side applied, which also corrects drift.
+
+package main
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	platformv1alpha1 "github.com/xyz-company/tenant-operator/api/v1alpha1"
+)
+
+// tenantLabel marks namespaces and resources belonging to a tenant
+const tenantLabel = "platform.xyz.com/tenant"
+
+// fieldManager owns the fields the operator applies with server-side apply
+const fieldManager = "tenant-operator"
+
+// applyOrAdopt server-side applies obj, controlled by tenant, so spec
+// changes and manual edits to the fields the operator sets are reconciled
+// on every pass. If obj already exists without a controller it is adopted,
+// provided it is safe to (see canAdopt). Objects controlled by something
+// else are never taken over. obj's kind must be in ownedKinds, so deletion
+// handles it.
+func (r *TenantReconciler) applyOrAdopt(ctx context.Context, tenant *platformv1alpha1.Tenant, obj client.Object) error {
+	gvk, err := apiutil.GVKForObject(obj, r.Scheme)
+	if err != nil {
+		return err
+	}
+	if !isOwnedKind(gvk) {
+		return fmt.Errorf("%s is not in ownedKinds, refusing to apply %s/%s", gvk.Kind, obj.GetNamespace(), obj.GetName())
+	}
+
+	labels := obj.GetLabels()
+	if labels == nil {
+		labels = map[string]string{}
+	}
+	labels[tenantLabel] = tenant.Name
+	obj.SetLabels(labels)
+	// A fresh owner list: the apply only claims the Tenant's reference, and
+	// owners added by others are kept by the API server
+	obj.SetOwnerReferences(nil)
+	if err := controllerutil.SetControllerReference(tenant, obj, r.Scheme); err != nil {
+		return err
+	}
+
+	existing := obj.DeepCopyObject().(client.Object)
+	err = r.Get(ctx, client.ObjectKeyFromObject(obj), existing)
+	switch {
+	case errors.IsNotFound(err):
+	case err != nil:
+		return err
+	default:
+		if ref := metav1.GetControllerOf(existing); ref != nil {
+			if ref.UID != tenant.UID {
+				return fmt.Errorf("%s/%s is controlled by %s %q, refusing to adopt", existing.GetNamespace(), existing.GetName(), ref.Kind, ref.Name)
+			}
+		} else {
+			ok, err := r.canAdopt(ctx, tenant, existing)
+			if err != nil {
+				return err
+			}
+			if !ok {
+				return fmt.Errorf("%s/%s is not labelled for tenant %q, refusing to adopt", existing.GetNamespace(), existing.GetName(), tenant.Name)
+			}
+			ctrl.LoggerFrom(ctx).Info("Adopting existing resource", "namespace", existing.GetNamespace(), "name", existing.GetName())
+		}
+	}
+
+	return r.apply(ctx, obj)
+}
+
+// apply server-side applies obj as fieldManager, taking over conflicting
+// fields from other managers
+func (r *TenantReconciler) apply(ctx context.Context, obj client.Object) error {
+	return applyWith(ctx, r.Client, obj)
+}
+
+// applyWith server-side applies obj through c, so remote clusters are
+// reconciled the same way as this one
+func applyWith(ctx context.Context, c client.Client, obj client.Object) error {
+	// Apply patches carry apiVersion and kind, which typed objects leave empty
+	gvk, err := apiutil.GVKForObject(obj, c.Scheme())
+	if err != nil {
+		return err
+	}
+	obj.GetObjectKind().SetGroupVersionKind(gvk)
+	obj.SetManagedFields(nil)
+	obj.SetResourceVersion("")
+	return c.Patch(ctx, obj, client.Apply, client.FieldOwner(fieldManager), client.ForceOwnership)
+}
+
+// canAdopt reports whether an unowned object belongs to tenant: either it
+// carries the tenant label, or it has no tenant label and lives in a
+// namespace labelled for the tenant
+func (r *TenantReconciler) canAdopt(ctx context.Context, tenant *platformv1alpha1.Tenant, obj client.Object) (bool, error) {
+	if value, ok := obj.GetLabels()[tenantLabel]; ok {
+		return value == tenant.Name, nil
+	}
+	if obj.GetNamespace() == "" {
+		return false, nil
+	}
+
+	ns := &corev1.Namespace{}
+	if err := r.Get(ctx, types.NamespacedName{Name: obj.GetNamespace()}, ns); err != nil {
+		return false, err
+	}
+	return ns.Labels[tenantLabel] == tenant.Name, nil
+}
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	platformv1alpha1 "github.com/xyz-company/tenant-operator/api/v1alpha1"
+)
+
+// unmanagedQuota is a tenant-quota created by hand in namespace search
+func unmanagedQuota(labels map[string]string, owners ...metav1.OwnerReference) *corev1.ResourceQuota {
+	return &corev1.ResourceQuota{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            "tenant-quota",
+			Namespace:       "search",
+			Labels:          labels,
+			OwnerReferences: owners,
+		},
+		Spec: corev1.ResourceQuotaSpec{
+			Hard: corev1.ResourceList{corev1.ResourcePods: resource.MustParse("3")},
+		},
+	}
+}
+
+func adoptTenant() *platformv1alpha1.Tenant {
+	tenant := newTenant("search", "search-team")
+	tenant.UID = types.UID("search-uid")
+	tenant.Spec.Quota.Pods = 20
+	return tenant
+}
+
+func TestAdoptUnmanagedResourceQuota(t *testing.T) {
+	tests := []struct {
+		name   string
+		labels map[string]string
+	}{
+		// Lives in the tenant namespace, which the operator labels
+		{"unlabelled", nil},
+		{"labelled for the tenant", map[string]string{tenantLabel: "search"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tenant := adoptTenant()
+			c := newReconcilerClient(tenant, unmanagedQuota(tt.labels))
+			reconcileTenant(t, newTestReconciler(c), "search")
+
+			quota := &corev1.ResourceQuota{}
+			if err := c.Get(context.Background(), client.ObjectKey{Namespace: "search", Name: "tenant-quota"}, quota); err != nil {
+				t.Fatal(err)
+			}
+			ref := metav1.GetControllerOf(quota)
+			if ref == nil || ref.Kind != "Tenant" || ref.Name != "search" || ref.UID != tenant.UID {
+				t.Fatalf("controller = %+v, want Tenant search", ref)
+			}
+			if quota.Labels[tenantLabel] != "search" {
+				t.Errorf("labels = %v, want %s=search", quota.Labels, tenantLabel)
+			}
+			if pods := quota.Spec.Hard[corev1.ResourcePods]; pods.String() != "20" {
+				t.Errorf("pods = %s, want the tenant's 20", pods.String())
+			}
+		})
+	}
+}
+
+func TestAdoptRefusesConflictingOwner(t *testing.T) {
+	isController := true
+	tests := []struct {
+		name   string
+		quota  *corev1.ResourceQuota
+		refuse string
+	}{
+		{
+			name:   "labelled for another tenant",
+			quota:  unmanagedQuota(map[string]string{tenantLabel: "ads"}),
+			refuse: `search/tenant-quota is not labelled for tenant "search", refusing to adopt`,
+		},
+		{
+			name: "controlled by another tenant",
+			quota: unmanagedQuota(nil, metav1.OwnerReference{
+				APIVersion: platformv1alpha1.GroupVersion.String(),
+				Kind:       "Tenant",
+				Name:       "ads",
+				UID:        "ads-uid",
+				Controller: &isController,
+			}),
+			refuse: `search/tenant-quota is controlled by Tenant "ads", refusing to adopt`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newReconcilerClient(adoptTenant(), tt.quota)
+			_, err := newTestReconciler(c).Reconcile(context.Background(), ctrl.Request{NamespacedName: types.NamespacedName{Name: "search"}})
+			if err == nil || !strings.Contains(err.Error(), tt.refuse) {
+				t.Fatalf("Reconcile() = %v, want %q", err, tt.refuse)
+			}
+
+			quota := &corev1.ResourceQuota{}
+			if err := c.Get(context.Background(), client.ObjectKeyFromObject(tt.quota), quota); err != nil {
+				t.Fatal(err)
+			}
+			if ref := metav1.GetControllerOf(quota); ref != nil && ref.UID == "search-uid" {
+				t.Fatal("quota adopted despite the conflicting owner")
+			}
+			if pods := quota.Spec.Hard[corev1.ResourcePods]; pods.String() != "3" {
+				t.Errorf("pods = %s, want the original 3", pods.String())
+			}
+
+			tenant := &platformv1alpha1.Tenant{}
+			if err := c.Get(context.Background(), client.ObjectKey{Name: "search"}, tenant); err != nil {
+				t.Fatal(err)
+			}
+			if tenant.Status.Phase != "Error" || !strings.Contains(tenant.Status.Message, "refusing to adopt") {
+				t.Errorf("status = %s %q, want Error with the refusal", tenant.Status.Phase, tenant.Status.Message)
+			}
+		})
+	}
+}
+
+func TestEnvtestAdoptResourceQuota(t *testing.T) {
+	ctx := context.Background()
+	c := envtestClient(t)
+	// A quota made by hand in the namespace before the Tenant existed
+	if err := c.Create(ctx, &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "adoption"}}); err != nil {
+		t.Fatal(err)
+	}
+	manual := unmanagedQuota(nil)
+	manual.Namespace = "adoption"
+	if err := c.Create(ctx, manual, client.FieldOwner("kubectl-create")); err != nil {
+		t.Fatal(err)
+	}
+
+	tenant := newTenant("adoption", "search-team")
+	tenant.Spec.Quota.Pods = 20
+	r := createEnvtestTenant(t, c, tenant)
+	reconcileTenant(t, r, "adoption")
+
+	quota := &corev1.ResourceQuota{}
+	if err := c.Get(ctx, client.ObjectKeyFromObject(manual), quota); err != nil {
+		t.Fatal(err)
+	}
+	stored := storedTenant(t, c, "adoption")
+	if ref := metav1.GetControllerOf(quota); ref == nil || ref.Kind != "Tenant" || ref.UID != stored.UID {
+		t.Fatalf("controller = %+v, want Tenant adoption", ref)
+	}
+	if pods := quota.Spec.Hard[corev1.ResourcePods]; pods.String() != "20" {
+		t.Errorf("pods = %s, want the tenant's 20", pods.String())
+	}
+	if managedFields(quota, fieldManager, metav1.ManagedFieldsOperationApply) == nil {
+		t.Errorf("managed fields = %+v, want an apply by %s", quota.ManagedFields, fieldManager)
+	}
+}
+
+func TestEnvtestAdoptRefusesConflictingOwner(t *testing.T) {
+	ctx := context.Background()
+	c := envtestClient(t)
+	if err := c.Create(ctx, &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "adoption-conflict"}}); err != nil {
+		t.Fatal(err)
+	}
+	isController := true
+	foreign := unmanagedQuota(nil, metav1.OwnerReference{
+		APIVersion: platformv1alpha1.GroupVersion.String(),
+		Kind:       "Tenant",
+		Name:       "ads",
+		UID:        "ads-uid",
+		Controller: &isController,
+	})
+	foreign.Namespace = "adoption-conflict"
+	if err := c.Create(ctx, foreign); err != nil {
+		t.Fatal(err)
+	}
+
+	tenant := newTenant("adoption-conflict", "search-team")
+	tenant.Spec.Quota.Pods = 20
+	r := createEnvtestTenant(t, c, tenant)
+	refuse := `adoption-conflict/tenant-quota is controlled by Tenant "ads", refusing to adopt`
+	if err := reconcileError(r, "adoption-conflict"); err == nil || !strings.Contains(err.Error(), refuse) {
+		t.Fatalf("Reconcile() = %v, want %q", err, refuse)
+	}
+
+	quota := &corev1.ResourceQuota{}
+	if err := c.Get(ctx, client.ObjectKeyFromObject(foreign), quota); err != nil {
+		t.Fatal(err)
+	}
+	if ref := metav1.GetControllerOf(quota); ref == nil || ref.UID != "ads-uid" {
+		t.Fatalf("controller = %+v, want the original Tenant ads", ref)
+	}
+	if pods := quota.Spec.Hard[corev1.ResourcePods]; pods.String() != "3" {
+		t.Errorf("pods = %s, want the original 3", pods.String())
+	}
+	if stored := storedTenant(t, c, "adoption-conflict"); stored.Status.Phase != "Error" {
+		t.Errorf("phase = %s, want Error kept by the status subresource", stored.Status.Phase)
+	}
+}
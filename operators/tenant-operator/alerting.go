@@ -0,0 +1,237 @@
+// Alert routing
+// Alerts firing in a tenant namespace are routed to the tenant's contacts
+// by an AlertmanagerConfig, tenant-alerts in each tenant namespace, which
+// the Prometheus Operator limits to alerts of that namespace:
+//
+//	slack      every alert, to the channel; Alertmanager's slack_api_url
+//	           posts it
+//	email      every alert, to the address, through Alertmanager's SMTP
+//	           settings
+//	pagerduty  critical alerts, to the PagerDuty service, with its routing
+//	           key from --pagerduty-secret copied into the Secret
+//	           tenant-alerts-pagerduty next to the config
+//
+// Alerts none of them takes, such as warnings of a tenant with only a
+// PagerDuty key, go to the platform receiver, --alert-fallback-webhook.
+// Routing is skipped while the Prometheus Operator CRDs are missing.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/mail"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	platformv1alpha1 "github.com/xyz-company/tenant-operator/api/v1alpha1"
+)
+
+var alertmanagerConfigGVK = schema.GroupVersionKind{Group: "monitoring.coreos.com", Version: "v1alpha1", Kind: "AlertmanagerConfig"}
+
+// Spec.Contacts keys alerts are routed to
+const (
+	contactSlack     = "slack"
+	contactEmail     = "email"
+	contactPagerDuty = "pagerduty"
+)
+
+const (
+	// tenantAlertsConfig names the AlertmanagerConfig in each tenant namespace
+	tenantAlertsConfig = "tenant-alerts"
+	// pagerDutySecret names the Secret holding the tenant's PagerDuty key
+	pagerDutySecret = "tenant-alerts-pagerduty"
+	// platformReceiver takes the alerts no tenant receiver takes
+	platformReceiver = "platform"
+)
+
+// validateContacts rejects email contacts that aren't addresses, which
+// Alertmanager would fail to send to
+func validateContacts(contacts map[string]string) error {
+	if email, ok := contacts[contactEmail]; ok {
+		if _, err := mail.ParseAddress(email); err != nil {
+			return fmt.Errorf("contacts.email %q is not an email address", email)
+		}
+	}
+	return nil
+}
+
+// alertsRouted reports whether tenant has a contact alerts are routed to
+func alertsRouted(tenant *platformv1alpha1.Tenant) bool {
+	for _, key := range []string{contactSlack, contactEmail, contactPagerDuty} {
+		if tenant.Spec.Contacts[key] != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// alertmanagerConfig returns the AlertmanagerConfig routing the alerts of
+// namespace to tenant's contacts, to PagerDuty only if pagerDuty is set
+func (r *TenantReconciler) alertmanagerConfig(tenant *platformv1alpha1.Tenant, namespace string, pagerDuty bool) *unstructured.Unstructured {
+	contacts := tenant.Spec.Contacts
+	platform := map[string]interface{}{"name": platformReceiver}
+	if r.AlertFallbackWebhook != "" {
+		platform["webhookConfigs"] = []interface{}{map[string]interface{}{"url": r.AlertFallbackWebhook, "sendResolved": true}}
+	}
+	receivers := []interface{}{platform}
+	var routes []interface{}
+
+	if channel := contacts[contactSlack]; channel != "" {
+		receivers = append(receivers, map[string]interface{}{
+			"name":         contactSlack,
+			"slackConfigs": []interface{}{map[string]interface{}{"channel": channel, "sendResolved": true}},
+		})
+		routes = append(routes, map[string]interface{}{"receiver": contactSlack, "continue": true})
+	}
+	if to := contacts[contactEmail]; to != "" {
+		receivers = append(receivers, map[string]interface{}{
+			"name":         contactEmail,
+			"emailConfigs": []interface{}{map[string]interface{}{"to": to, "sendResolved": true}},
+		})
+		routes = append(routes, map[string]interface{}{"receiver": contactEmail, "continue": true})
+	}
+	if pagerDuty {
+		receivers = append(receivers, map[string]interface{}{
+			"name": contactPagerDuty,
+			"pagerdutyConfigs": []interface{}{map[string]interface{}{
+				"routingKey":   map[string]interface{}{"name": pagerDutySecret, "key": "routingKey"},
+				"sendResolved": true,
+			}},
+		})
+		routes = append(routes, map[string]interface{}{
+			"receiver": contactPagerDuty,
+			"matchers": []interface{}{map[string]interface{}{"name": "severity", "value": "critical", "matchType": "="}},
+			"continue": true,
+		})
+	}
+
+	config := &unstructured.Unstructured{Object: map[string]interface{}{
+		"spec": map[string]interface{}{
+			// Alerts matching no route fall through to the platform
+			"route": map[string]interface{}{
+				"receiver": platformReceiver,
+				"groupBy":  []interface{}{"alertname"},
+				"routes":   routes,
+			},
+			"receivers": receivers,
+		},
+	}}
+	config.SetGroupVersionKind(alertmanagerConfigGVK)
+	config.SetNamespace(namespace)
+	config.SetName(tenantAlertsConfig)
+	config.SetLabels(copyLabels(r.Monitoring.Labels))
+	return config
+}
+
+// reconcileAlertRouting applies the AlertmanagerConfig, and PagerDuty
+// Secret, of each of namespaces, or deletes them once the tenant has no
+// contacts to route alerts to
+func (r *TenantReconciler) reconcileAlertRouting(ctx context.Context, tenant *platformv1alpha1.Tenant, namespaces []string) error {
+	enabled := alertsRouted(tenant)
+	installed, err := r.kindInstalled(alertmanagerConfigGVK)
+	if err != nil {
+		return err
+	}
+	if !installed {
+		if enabled {
+			ctrl.LoggerFrom(ctx).Info("CRD not installed, skipping alert routing", "kind", alertmanagerConfigGVK.Kind)
+		}
+		return nil
+	}
+
+	var key string
+	var desired []*unstructured.Unstructured
+	if enabled {
+		if key, err = r.pagerDutyRoutingKey(ctx, tenant); err != nil {
+			return err
+		}
+		for _, namespace := range namespaces {
+			desired = append(desired, r.alertmanagerConfig(tenant, namespace, key != ""))
+		}
+	}
+	stale, err := staleObjects(ctx, r.Client, tenant, namespaces, alertmanagerConfigGVK, desired)
+	if err != nil {
+		return err
+	}
+	for _, obj := range stale {
+		if err := r.deleteIfControlled(ctx, tenant, obj); err != nil {
+			return err
+		}
+	}
+	for _, namespace := range namespaces {
+		if err := r.reconcilePagerDutySecret(ctx, tenant, namespace, key); err != nil {
+			return err
+		}
+	}
+	for _, obj := range desired {
+		if err := r.applyOrAdopt(ctx, tenant, obj); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// pagerDutyRoutingKey returns the routing key of tenant's PagerDuty
+// service from --pagerduty-secret, empty if it has none. A service missing
+// from the Secret is reported and left unrouted.
+func (r *TenantReconciler) pagerDutyRoutingKey(ctx context.Context, tenant *platformv1alpha1.Tenant) (string, error) {
+	service := tenant.Spec.Contacts[contactPagerDuty]
+	if service == "" || r.PagerDutySecret == "" {
+		return "", nil
+	}
+	keys := &corev1.Secret{}
+	err := r.APIReader.Get(ctx, types.NamespacedName{Namespace: r.Monitoring.Namespace, Name: r.PagerDutySecret}, keys)
+	if err != nil && !errors.IsNotFound(err) {
+		return "", err
+	}
+	key := string(keys.Data[service])
+	if key == "" {
+		r.Recorder.Eventf(tenant, corev1.EventTypeWarning, "PagerDutyKeyMissing", "Secret %s/%s has no routing key for PagerDuty service %q, critical alerts go to the other contacts", r.Monitoring.Namespace, r.PagerDutySecret, service)
+	}
+	return key, nil
+}
+
+// reconcilePagerDutySecret writes the routing key into namespace, or
+// deletes it once the key is empty
+func (r *TenantReconciler) reconcilePagerDutySecret(ctx context.Context, tenant *platformv1alpha1.Tenant, namespace, key string) error {
+	// Secrets are read uncached, the operator doesn't watch them
+	existing := &corev1.Secret{}
+	err := r.APIReader.Get(ctx, types.NamespacedName{Namespace: namespace, Name: pagerDutySecret}, existing)
+	if err != nil && !errors.IsNotFound(err) {
+		return err
+	}
+	found := err == nil
+	controlled := found && metav1.IsControlledBy(existing, tenant)
+
+	if key == "" {
+		if !controlled {
+			return nil
+		}
+		return client.IgnoreNotFound(r.Delete(ctx, existing))
+	}
+	if found && !controlled {
+		return fmt.Errorf("%s/%s is not managed by tenant %q, refusing to overwrite", namespace, pagerDutySecret, tenant.Name)
+	}
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      pagerDutySecret,
+			Namespace: namespace,
+			Labels:    map[string]string{tenantLabel: tenant.Name},
+		},
+		Type: corev1.SecretTypeOpaque,
+		Data: map[string][]byte{"routingKey": []byte(key)},
+	}
+	if err := controllerutil.SetControllerReference(tenant, secret, r.Scheme); err != nil {
+		return err
+	}
+	return r.apply(ctx, secret)
+}
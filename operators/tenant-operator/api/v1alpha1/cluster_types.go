@@ -0,0 +1,60 @@
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Cluster registers a target cluster Tenants can be placed in with
+// Spec.Placement (--multi-cluster)
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:resource:scope=Cluster,shortName=pcl
+type Cluster struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   ClusterSpec               `json:"spec,omitempty"`
+	Status ClusterRegistrationStatus `json:"status,omitempty"`
+}
+
+// ClusterSpec locates a target cluster. Its labels, and the provider and
+// region, are what tenant placements select it by.
+type ClusterSpec struct {
+	// KubeconfigSecretRef names the Secret, in the operator's
+	// --cluster-secret-namespace, holding the cluster's kubeconfig
+	KubeconfigSecretRef KubeconfigSecretReference `json:"kubeconfigSecretRef"`
+	// Provider is the cloud or on-prem platform the cluster runs on
+	// +kubebuilder:validation:Enum=aws;azure;gcp;on-prem
+	Provider string `json:"provider,omitempty"`
+	Region   string `json:"region,omitempty"`
+}
+
+// KubeconfigSecretReference names a Secret key holding a kubeconfig
+type KubeconfigSecretReference struct {
+	Name string `json:"name"`
+	// Key defaults to kubeconfig
+	Key string `json:"key,omitempty"`
+}
+
+// ClusterRegistrationStatus is the cluster's health as last probed
+type ClusterRegistrationStatus struct {
+	// Conditions holds Ready, True while the cluster's API server answers
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+	// KubernetesVersion is the version the API server reported
+	KubernetesVersion string `json:"kubernetesVersion,omitempty"`
+	// LatencyMilliseconds is how long the last probe took to answer
+	LatencyMilliseconds int64       `json:"latencyMilliseconds,omitempty"`
+	LastProbeTime       metav1.Time `json:"lastProbeTime,omitempty"`
+}
+
+// ClusterList contains a list of Cluster
+// +kubebuilder:object:root=true
+type ClusterList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []Cluster `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&Cluster{}, &ClusterList{})
+}
@@ -0,0 +1,698 @@
+package v1alpha1
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+// Tenant is the Schema for the tenants API
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:resource:scope=Cluster,shortName=tn
+type Tenant struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   TenantSpec   `json:"spec,omitempty"`
+	Status TenantStatus `json:"status,omitempty"`
+}
+
+// TenantSpec defines the desired state of Tenant
+type TenantSpec struct {
+	Owner               string            `json:"owner"`
+	CostCenter          string            `json:"costCenter,omitempty"`
+	Quota               TenantQuota       `json:"quota,omitempty"`
+	AllowedIntegrations []string          `json:"allowedIntegrations,omitempty"`
+	Contacts            map[string]string `json:"contacts,omitempty"`
+	// DefaultTolerations are added to every pod in the tenant namespace by
+	// the PodTolerationRestriction admission plugin
+	DefaultTolerations []corev1.Toleration `json:"defaultTolerations,omitempty"`
+	// AllowIntraNamespace lets pods in the tenant namespace reach each other
+	// despite default-deny-ingress. Defaults to true.
+	AllowIntraNamespace *bool `json:"allowIntraNamespace,omitempty"`
+	// ServiceMesh configures Istio for the tenant namespace
+	ServiceMesh *ServiceMeshSpec `json:"serviceMesh,omitempty"`
+	// MeshDefaultDeny denies all mesh traffic into the namespace except from
+	// the namespace itself. Only applies when the service mesh is enabled.
+	MeshDefaultDeny bool `json:"meshDefaultDeny,omitempty"`
+	// NetworkIsolation denies all egress from the namespace except to
+	// cluster DNS, the Kubernetes API, the platform endpoints and
+	// AllowedIntegrations
+	NetworkIsolation bool `json:"networkIsolation,omitempty"`
+	// DisableDefaultSATokenMount stops the default ServiceAccount's token
+	// from being mounted into pods. Workloads needing it must opt in per pod.
+	DisableDefaultSATokenMount bool `json:"disableDefaultSATokenMount,omitempty"`
+	// RequireSeccomp makes the pod webhook give pods a RuntimeDefault
+	// seccomp profile when they don't set one
+	RequireSeccomp bool `json:"requireSeccomp,omitempty"`
+	// MaxReplicasCeiling caps maxReplicas of HorizontalPodAutoscalers in the
+	// namespace. 0 means unlimited.
+	MaxReplicasCeiling int32 `json:"maxReplicasCeiling,omitempty"`
+	// DefaultRequestRateLimit is the request rate applied to the tenant's
+	// HTTPRoutes unless they set their own, e.g. "100r/s" or "6000r/m"
+	DefaultRequestRateLimit string `json:"defaultRequestRateLimit,omitempty"`
+	// DeletionPolicy decides what happens to the namespace and its
+	// resources when the Tenant is deleted. Defaults to Delete.
+	// +kubebuilder:validation:Enum=Delete;Orphan
+	DeletionPolicy DeletionPolicy `json:"deletionPolicy,omitempty"`
+	// Access grants groups and users a role in the tenant namespace. When
+	// empty, the <name>-team group gets the developer role.
+	Access []TenantAccess `json:"access,omitempty"`
+	// ServiceAccounts are CI/CD ServiceAccounts provisioned in the tenant
+	// namespace, each with a rotated kubeconfig Secret
+	ServiceAccounts []TenantServiceAccount `json:"serviceAccounts,omitempty"`
+	// Profile names the TenantProfile supplying the settings this spec
+	// leaves unset
+	Profile string `json:"profile,omitempty"`
+	// Namespaces, when set, gives the tenant one namespace per entry, named
+	// <name>-<entry>, in place of the namespace named after the Tenant. Each
+	// gets the same quota, policies and RBAC.
+	Namespaces []string `json:"namespaces,omitempty"`
+	// QuotaSplit shares Quota across Namespaces, as a percentage per entry.
+	// Without it every namespace gets the whole Quota.
+	QuotaSplit map[string]int `json:"quotaSplit,omitempty"`
+	// Parent names the Tenant this one belongs to. A child inherits its
+	// ancestors' network policies and access grants, and the children of a
+	// Tenant may together get no more than its quota.
+	Parent string `json:"parent,omitempty"`
+	// State Suspended scales the tenant's workloads to zero and blocks new
+	// pods, keeping everything else. Defaults to Active.
+	// +kubebuilder:validation:Enum=Active;Suspended
+	State TenantState `json:"state,omitempty"`
+	// ExpiresAt, or TTL after creation, deletes the Tenant once passed, for
+	// ephemeral environments. Set at most one of them.
+	ExpiresAt *metav1.Time     `json:"expiresAt,omitempty"`
+	TTL       *metav1.Duration `json:"ttl,omitempty"`
+	// PriorityClasses lists the priority classes the tenant's pods may use,
+	// each optionally capped. When set, pods asking for any other class are
+	// refused.
+	PriorityClasses []TenantPriorityClass `json:"priorityClasses,omitempty"`
+	// Disruption keeps the tenant's workloads running through node drains
+	Disruption *TenantDisruptionPolicy `json:"disruption,omitempty"`
+	// Storage limits the StorageClasses the tenant's PVCs may use
+	Storage *TenantStorage `json:"storage,omitempty"`
+	// ImagePolicy limits the registries the tenant's pods pull images from
+	ImagePolicy *TenantImagePolicy `json:"imagePolicy,omitempty"`
+	// SharedSecrets names Secrets in the operator namespace, labelled
+	// platform.xyz.com/shareable=true, mirrored into the tenant namespaces
+	SharedSecrets []string `json:"sharedSecrets,omitempty"`
+	// SecretsBackend gives the tenant namespaces an External Secrets
+	// SecretStore reading the tenant's tree in the platform Vault
+	SecretsBackend *TenantSecretsBackend `json:"secretsBackend,omitempty"`
+	// Certificates names the DNS zones the tenant may request TLS
+	// certificates for from the platform's ClusterIssuers
+	Certificates *TenantCertificates `json:"certificates,omitempty"`
+	// Ingress delegates a domain to the tenant, served by a Gateway of its
+	// own
+	Ingress *TenantIngress `json:"ingress,omitempty"`
+	// GitOps sets up the tenant in the platform's GitOps tooling
+	GitOps *TenantGitOps `json:"gitops,omitempty"`
+	// Placement selects the target clusters the tenant is provisioned in
+	// besides this one (--multi-cluster). Unset places it in all of them.
+	Placement *TenantPlacement `json:"placement,omitempty"`
+	// Budget bounds the tenant's monthly cost estimate (--cost-pricing)
+	Budget *TenantBudget `json:"budget,omitempty"`
+	// CloudIdentity gives the tenant's workloads an identity with the
+	// platform's cloud provider (--cloud-identity)
+	CloudIdentity *TenantCloudIdentity `json:"cloudIdentity,omitempty"`
+	// DedicatedNodes reserves a node pool for the tenant's pods
+	DedicatedNodes *TenantDedicatedNodes `json:"dedicatedNodes,omitempty"`
+	// Compute sets the capacity types the tenant's pods run on
+	// (--spot-node-label)
+	Compute *TenantCompute `json:"compute,omitempty"`
+	// Backup backs the tenant's namespaces up on a schedule with Velero
+	Backup *TenantBackup `json:"backup,omitempty"`
+	// Observability routes the tenant's telemetry to destinations of its
+	// own
+	Observability *TenantObservability `json:"observability,omitempty"`
+	// Policy tunes the guardrails of the policy engine (--policy-engine)
+	// for the tenant
+	Policy *TenantPolicy `json:"policy,omitempty"`
+}
+
+// TenantBudget sets alerts on, and optionally caps, the monthly cost
+// estimate. Amounts are decimal strings in the estimate's currency.
+type TenantBudget struct {
+	MonthlyLimit string `json:"monthlyLimit"`
+	// AlertThresholds are the percentages of MonthlyLimit announced when the
+	// estimate reaches them. Defaults to 80 and 100.
+	AlertThresholds []int32 `json:"alertThresholds,omitempty"`
+	// Notify also notifies the tenant's contacts of the alerts
+	// (--budget-notification-url)
+	Notify bool `json:"notify,omitempty"`
+	// HardCap suspends the tenant once its estimate reaches this amount
+	HardCap string `json:"hardCap,omitempty"`
+}
+
+// TenantCloudIdentity federates a ServiceAccount in each tenant namespace
+// with a cloud identity of the tenant's own: an IAM role on AWS, a Google
+// service account on GCP or a managed identity on Azure
+type TenantCloudIdentity struct {
+	// ServiceAccountName names the federated ServiceAccounts. Defaults to
+	// cloud-identity.
+	ServiceAccountName string `json:"serviceAccountName,omitempty"`
+	// Grants are all the identity may do, each on the listed resources only
+	Grants []TenantCloudIdentityGrant `json:"grants,omitempty"`
+}
+
+// TenantCloudIdentityGrant allows the identity Actions, or Role, on
+// Resources
+type TenantCloudIdentityGrant struct {
+	// Actions are the IAM actions allowed on AWS, such as s3:GetObject
+	Actions []string `json:"actions,omitempty"`
+	// Role is the IAM role granted on GCP, such as
+	// roles/storage.objectViewer, or the role definition assigned on Azure,
+	// by GUID or resource ID
+	Role string `json:"role,omitempty"`
+	// Resources are ARNs on AWS, resource names on GCP and scopes on Azure.
+	// On AWS and GCP a trailing * matches a prefix.
+	Resources []string `json:"resources"`
+}
+
+// TenantBackup is a Velero backup schedule of the tenant's namespaces
+type TenantBackup struct {
+	// Schedule is a cron expression, such as "0 2 * * *", or a descriptor
+	// such as @daily
+	Schedule string `json:"schedule"`
+	// TTL is how long each backup is kept, such as 720h. Defaults to
+	// Velero's 30 days.
+	TTL string `json:"ttl,omitempty"`
+	// IncludeVolumes snapshots the tenant's persistent volumes too
+	IncludeVolumes bool `json:"includeVolumes,omitempty"`
+}
+
+// TenantObservability routes the tenant's telemetry
+type TenantObservability struct {
+	// LogDestination receives the container logs of the tenant's
+	// namespaces, and only theirs
+	LogDestination *TenantLogDestination `json:"logDestination,omitempty"`
+	// Metrics scrapes the tenant's workloads and gives it recording rules
+	// and a Grafana dashboard
+	Metrics bool `json:"metrics,omitempty"`
+	// AvailabilityObjective is the percent of requests to the tenant's
+	// services to be served without a 5xx error, such as 99.9, which the
+	// dashboard measures the error budget against. Defaults to 99.9.
+	AvailabilityObjective string `json:"availabilityObjective,omitempty"`
+}
+
+// TenantPolicy tunes the policy engine's guardrails for one tenant
+type TenantPolicy struct {
+	// RequiredLabels are labels the tenant's Deployments, StatefulSets and
+	// DaemonSets must carry besides the platform's
+	RequiredLabels []string `json:"requiredLabels,omitempty"`
+	// AllowedCapabilities are the Linux capabilities containers may add,
+	// such as NET_ADMIN. Defaults to the Pod Security baseline set.
+	// Kyverno only.
+	AllowedCapabilities []string `json:"allowedCapabilities,omitempty"`
+}
+
+// Log destination types
+const (
+	LogDestinationElasticsearch = "elasticsearch"
+	LogDestinationS3            = "s3"
+)
+
+// TenantLogDestination is the index or bucket a tenant's logs land in, on
+// a log store the platform runs
+type TenantLogDestination struct {
+	// Type is elasticsearch or s3
+	Type string `json:"type"`
+	// Index is the Elasticsearch index, tenant-<name> or starting with
+	// tenant-<name>-. Defaults to tenant-<name>.
+	Index string `json:"index,omitempty"`
+	// Bucket is the S3 bucket. The logs are written below tenants/<name>/.
+	Bucket string `json:"bucket,omitempty"`
+	// RetentionDays deletes Elasticsearch indices older than this many
+	// days. S3 buckets expire objects through their lifecycle rules.
+	RetentionDays int32 `json:"retentionDays,omitempty"`
+}
+
+// TenantCompute sets the capacity types the tenant's pods run on
+type TenantCompute struct {
+	// SpotAllowed steers the tenant's batch pods, those of Jobs, to spot
+	// nodes. Stateful pods stay on on-demand nodes.
+	SpotAllowed bool `json:"spotAllowed,omitempty"`
+}
+
+// TenantDedicatedNodes is a node pool only the tenant's pods run on, and
+// all of them do. Its nodes are labelled and tainted for the tenant.
+type TenantDedicatedNodes struct {
+	// NodeSelector selects the nodes of the pool, such as those of a cloud
+	// node group. Defaults to the nodes of MachineDeployment.
+	NodeSelector map[string]string `json:"nodeSelector,omitempty"`
+	// MachineDeployment provisions the pool with Cluster API
+	MachineDeployment *TenantMachineDeployment `json:"machineDeployment,omitempty"`
+	// CPU caps the tenant's requests.cpu on the pool. Defaults to the
+	// allocatable CPU of its nodes.
+	CPU string `json:"cpu,omitempty"`
+}
+
+// TenantMachineDeployment is the Cluster API MachineDeployment of a
+// dedicated node pool. The templates live in --cluster-api-namespace.
+type TenantMachineDeployment struct {
+	// ClusterName is the Cluster API Cluster the machines join
+	ClusterName string `json:"clusterName"`
+	Replicas    int32  `json:"replicas"`
+	// Version is the machines' Kubernetes version, such as v1.28.3
+	Version string `json:"version"`
+	// InfrastructureRef is the machine template, such as an
+	// AWSMachineTemplate
+	InfrastructureRef TenantTemplateRef `json:"infrastructureRef"`
+	// BootstrapRef is the bootstrap config template, such as a
+	// KubeadmConfigTemplate
+	BootstrapRef TenantTemplateRef `json:"bootstrapRef"`
+}
+
+// TenantTemplateRef names a Cluster API template
+type TenantTemplateRef struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	Name       string `json:"name"`
+}
+
+// TenantPlacement selects target clusters, registered Clusters or legacy
+// kubeconfig Secrets, by name or labels. A cluster is selected when either
+// matches; an empty placement keeps the tenant in this cluster only.
+// RequiredRegion and Cloud are data residency constraints every selected
+// cluster must meet. Set without names or a selector, they select all the
+// clusters meeting them.
+type TenantPlacement struct {
+	Clusters []string `json:"clusters,omitempty"`
+	// ClusterSelector matches a Cluster's labels, and platform.xyz.com/provider
+	// and platform.xyz.com/region set from its spec
+	ClusterSelector *metav1.LabelSelector `json:"clusterSelector,omitempty"`
+	// RequiredRegion is the only region the tenant may be placed in
+	RequiredRegion string `json:"requiredRegion,omitempty"`
+	// Cloud is the only provider the tenant may be placed on
+	// +kubebuilder:validation:Enum=aws;azure;gcp;on-prem
+	Cloud string `json:"cloud,omitempty"`
+	// Failover places the tenant on a primary and a standby cluster, and
+	// activates the standby when the primary stays unreachable
+	Failover *TenantFailover `json:"failover,omitempty"`
+	// ServiceDiscovery makes the tenant's Services resolvable from its
+	// other clusters, through the Multi-Cluster Services API or Istio
+	// ServiceEntries. Unset leaves each cluster's Services to itself.
+	// +kubebuilder:validation:Enum=MCS;Istio
+	ServiceDiscovery ServiceDiscoveryMode `json:"serviceDiscovery,omitempty"`
+}
+
+// ServiceDiscoveryMode is how a tenant's Services are published across
+// clusters
+type ServiceDiscoveryMode string
+
+const (
+	// ServiceDiscoveryMCS exports the Services with MCS ServiceExports
+	ServiceDiscoveryMCS ServiceDiscoveryMode = "MCS"
+	// ServiceDiscoveryIstio gives the other clusters Istio ServiceEntries
+	// for them
+	ServiceDiscoveryIstio ServiceDiscoveryMode = "Istio"
+)
+
+// TenantFailover names the clusters of a disaster recovery pair. Both are
+// placed like clusters named in Clusters.
+type TenantFailover struct {
+	Primary string `json:"primary"`
+	Standby string `json:"standby"`
+	// Threshold is how long the primary Cluster must be Unreachable before
+	// the standby is activated. Defaults to 5m.
+	Threshold *metav1.Duration `json:"threshold,omitempty"`
+}
+
+// TenantGitOps configures the tenant's GitOps tooling
+type TenantGitOps struct {
+	// ArgoCD gives the tenant an Argo CD AppProject
+	ArgoCD *TenantArgoCD `json:"argocd,omitempty"`
+}
+
+// TenantArgoCD restricts the tenant's Argo CD AppProject
+type TenantArgoCD struct {
+	// SourceRepos lists the Git repositories, or patterns such as
+	// https://github.com/xyz-company/team-a-*, Applications may sync from
+	SourceRepos []string `json:"sourceRepos"`
+	// Destinations lists the Argo CD clusters Applications may deploy the
+	// tenant's namespaces to. Defaults to in-cluster.
+	Destinations []string `json:"destinations,omitempty"`
+}
+
+// TenantIngress configures the tenant's Gateway
+type TenantIngress struct {
+	// Domain the tenant's Gateway serves subdomains of. It must lie below
+	// the platform base domain or within DNSZones of Certificates.
+	// Defaults to <tenant name>.<base domain>.
+	Domain string `json:"domain,omitempty"`
+}
+
+// TenantCertificates configures the tenant's TLS certificates
+type TenantCertificates struct {
+	// DNSZones lists the domains the tenant owns, such as team-a.xyz.com.
+	// Certificates from a ClusterIssuer may name them and the names below
+	// them only. No two Tenants may have overlapping zones.
+	DNSZones []string `json:"dnsZones,omitempty"`
+}
+
+// TenantSecretsBackend configures the tenant's access to the platform Vault
+type TenantSecretsBackend struct {
+	// PathPrefix narrows what the tenant namespaces may read to a path
+	// within the tenant's tree, such as tenants/<tenant name>/app. Defaults
+	// to the whole tree.
+	PathPrefix string `json:"pathPrefix,omitempty"`
+}
+
+// TenantImagePolicy restricts the images of the tenant's pods
+type TenantImagePolicy struct {
+	// AllowedRegistries lists registry hosts, optionally with a repository
+	// path such as registry.xyz.com/team, that images must come from.
+	// Empty allows any registry.
+	AllowedRegistries []string `json:"allowedRegistries,omitempty"`
+}
+
+// TenantStorage configures the tenant's persistent storage
+type TenantStorage struct {
+	// AllowedClasses lists the StorageClasses PVCs may use. Empty allows
+	// any class.
+	AllowedClasses []TenantStorageClass `json:"allowedClasses,omitempty"`
+}
+
+// TenantStorageClass allows the tenant one StorageClass. Storage and PVCs
+// cap its requested capacity and claims in each namespace; omitted, the
+// class is only bounded by quota.pvcs.
+type TenantStorageClass struct {
+	Name    string `json:"name"`
+	Storage string `json:"storage,omitempty"`
+	PVCs    int    `json:"pvcs,omitempty"`
+}
+
+// TenantDisruptionPolicy gives tenant workloads baseline protection against
+// voluntary disruptions such as node pool rotation
+type TenantDisruptionPolicy struct {
+	// PodDisruptionBudgets creates a PodDisruptionBudget for every
+	// Deployment with more than one replica that no PDB covers
+	PodDisruptionBudgets bool `json:"podDisruptionBudgets,omitempty"`
+	// MaxUnavailable of those PDBs, a count or a percentage. Defaults to 1.
+	MaxUnavailable *intstr.IntOrString `json:"maxUnavailable,omitempty"`
+	// TopologySpread makes the pod webhook spread pods that set no
+	// topologySpreadConstraints over TopologyKeys
+	TopologySpread bool `json:"topologySpread,omitempty"`
+	// TopologyKeys defaults to topology.kubernetes.io/zone and
+	// kubernetes.io/hostname
+	TopologyKeys []string `json:"topologyKeys,omitempty"`
+}
+
+// TenantRole is a tier of access to a tenant namespace
+type TenantRole string
+
+const (
+	// TenantRoleViewer can read everything but Secrets (ClusterRole view)
+	TenantRoleViewer TenantRole = "viewer"
+	// TenantRoleDeveloper can manage workloads (ClusterRole edit)
+	TenantRoleDeveloper TenantRole = "developer"
+	// TenantRoleAdmin can also manage RBAC, but not the NetworkPolicies,
+	// ResourceQuotas and LimitRanges the platform sets (ClusterRole tenant-admin)
+	TenantRoleAdmin TenantRole = "admin"
+)
+
+// TenantPriorityClass allows the tenant one PriorityClass. Without Value it
+// is a platform class, referenced by Name; with Value the operator creates
+// a tenant class named <tenant>-<name>.
+type TenantPriorityClass struct {
+	Name  string `json:"name"`
+	Value *int32 `json:"value,omitempty"`
+	// CPU, Memory and Pods cap the pods using the class, like TenantQuota.
+	// Omitted, the class is only bounded by the tenant quota.
+	CPU    string `json:"cpu,omitempty"`
+	Memory string `json:"memory,omitempty"`
+	Pods   int    `json:"pods,omitempty"`
+}
+
+// TenantServiceAccount is a pipeline ServiceAccount bound to the restricted
+// tenant-pipeline Role. Its token is written, with a kubeconfig, to the
+// <name>-kubeconfig Secret.
+type TenantServiceAccount struct {
+	Name string `json:"name"`
+	// TokenTTL is how long each token is valid. Tokens are replaced once two
+	// thirds of it has passed. Defaults to 24h, minimum 10m.
+	TokenTTL *metav1.Duration `json:"tokenTTL,omitempty"`
+}
+
+// TenantAccess grants Role to Groups and Users
+type TenantAccess struct {
+	// +kubebuilder:validation:Enum=viewer;developer;admin
+	Role   TenantRole `json:"role"`
+	Groups []string   `json:"groups,omitempty"`
+	Users  []string   `json:"users,omitempty"`
+}
+
+// TenantState is whether a tenant's workloads run
+type TenantState string
+
+const (
+	// TenantStateActive runs the tenant's workloads
+	TenantStateActive TenantState = "Active"
+	// TenantStateSuspended scales them to zero, to be restored on Active
+	TenantStateSuspended TenantState = "Suspended"
+)
+
+// DeletionPolicy is what the operator does with a deleted Tenant's resources
+type DeletionPolicy string
+
+const (
+	// DeletionPolicyDelete deletes the namespace and child resources
+	DeletionPolicyDelete DeletionPolicy = "Delete"
+	// DeletionPolicyOrphan leaves them in place, without owner references
+	DeletionPolicyOrphan DeletionPolicy = "Orphan"
+)
+
+// TenantQuota sizes the tenant's ResourceQuota. Omitted fields get the
+// platform defaults: 10 CPU, 20Gi memory, 100 pods, 20 PVCs, 50 services,
+// 100 secrets, 100 configmaps, 50 jobs and 2 load balancers.
+type TenantQuota struct {
+	// CPU and Memory are the requests quota; limits are allowed twice that
+	CPU      string `json:"cpu,omitempty"`
+	Memory   string `json:"memory,omitempty"`
+	Pods     int    `json:"pods,omitempty"`
+	PVCs     int    `json:"pvcs,omitempty"`
+	Services int    `json:"services,omitempty"`
+	// Secrets, ConfigMaps and Jobs cap object counts that would otherwise
+	// only be bounded by etcd, LoadBalancers the Services of type
+	// LoadBalancer, each of which costs a cloud load balancer
+	Secrets       int `json:"secrets,omitempty"`
+	ConfigMaps    int `json:"configMaps,omitempty"`
+	Jobs          int `json:"jobs,omitempty"`
+	LoadBalancers int `json:"loadBalancers,omitempty"`
+	// ExtendedResources caps the requests of extended resources, such as
+	// nvidia.com/gpu, by resource name. Omitted resources are uncapped.
+	ExtendedResources map[string]string `json:"extendedResources,omitempty"`
+	// Scopes adds a ResourceQuota per quota scope, capping for example
+	// best-effort pods separately from the rest
+	Scopes []TenantQuotaScope `json:"scopes,omitempty"`
+	// SoftThresholdPercent is the share of any hard limit at which the
+	// tenant is warned. Defaults to 80.
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=100
+	SoftThresholdPercent int `json:"softThresholdPercent,omitempty"`
+}
+
+// TenantQuotaScope caps the pods of one ResourceQuota scope. Omitted
+// fields leave the scope bounded by the tenant quota only.
+type TenantQuotaScope struct {
+	// Scope is BestEffort, NotBestEffort, Terminating or NotTerminating
+	Scope corev1.ResourceQuotaScope `json:"scope"`
+	// CPU and Memory cap requests like TenantQuota; BestEffort pods request
+	// neither, so that scope only takes Pods
+	CPU    string `json:"cpu,omitempty"`
+	Memory string `json:"memory,omitempty"`
+	Pods   int    `json:"pods,omitempty"`
+}
+
+// ServiceMeshSpec configures Istio for the tenant namespace
+type ServiceMeshSpec struct {
+	// Enabled sets the namespace's istio-injection label and, with Istio
+	// installed, STRICT mTLS and a Sidecar limiting egress to the tenant's
+	// integrations. Defaults to true.
+	Enabled *bool `json:"enabled,omitempty"`
+}
+
+// TenantStatus defines the observed state of Tenant
+type TenantStatus struct {
+	Phase                string `json:"phase,omitempty"`
+	NamespaceCreated     bool   `json:"namespaceCreated,omitempty"`
+	QuotaApplied         bool   `json:"quotaApplied,omitempty"`
+	NetworkPolicyApplied bool   `json:"networkPolicyApplied,omitempty"`
+	RBACApplied          bool   `json:"rbacApplied,omitempty"`
+	// Message explains an Error phase, e.g. an invalid quota
+	Message string `json:"message,omitempty"`
+	// Conditions are Ready, QuotaReady, NetworkPolicyReady, RBACReady and
+	// QuotaNearLimit
+	// +listType=map
+	// +listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+	// SpecHash is the hash of the effective spec last applied
+	SpecHash string `json:"specHash,omitempty"`
+	// ObservedGeneration is the Tenant generation SpecHash was computed from
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+	// Clusters reports the tenant in each target cluster (--multi-cluster)
+	Clusters []ClusterStatus `json:"clusters,omitempty"`
+	// Failover reports which cluster of spec.placement.failover is active
+	Failover *FailoverStatus `json:"failover,omitempty"`
+	// RecommendedQuota is an advisory quota based on observed usage. Only
+	// set for tenants with a single namespace; see Namespaces otherwise.
+	RecommendedQuota *RecommendedQuota `json:"recommendedQuota,omitempty"`
+	// Namespaces reports each tenant namespace
+	Namespaces []NamespaceStatus `json:"namespaces,omitempty"`
+	// Adopted is true when the tenant adopted an existing namespace through
+	// its platform.xyz.com/adopt annotation
+	Adopted bool `json:"adopted,omitempty"`
+	// VaultRole is the Vault role and policy the operator wrote for the
+	// tenant's secrets backend
+	VaultRole string `json:"vaultRole,omitempty"`
+	// CloudIdentity is the cloud identity the tenant's ServiceAccounts are
+	// federated with: a role ARN, Google service account email or managed
+	// identity client ID
+	CloudIdentity string `json:"cloudIdentity,omitempty"`
+	// DedicatedNodes describes the tenant's dedicated node pool
+	DedicatedNodes *DedicatedNodesStatus `json:"dedicatedNodes,omitempty"`
+	// Backup reports the tenant's latest Velero backup
+	Backup *BackupStatus `json:"backup,omitempty"`
+	// DNSZone is the domain allocated to the tenant's ingress, whose
+	// records ExternalDNS publishes
+	DNSZone string `json:"dnsZone,omitempty"`
+	// Cost estimates the tenant's monthly cost from its resource requests
+	// (--cost-pricing)
+	Cost *TenantCost `json:"cost,omitempty"`
+	// Policy reports the violations of the tenant's policy constraints
+	// (--policy-engine)
+	Policy *PolicyStatus `json:"policy,omitempty"`
+}
+
+// TenantCost is a monthly cost estimate at the tenant's current resource
+// requests. Amounts are decimal strings in Currency, e.g. "412.50".
+type TenantCost struct {
+	MonthlyEstimate string `json:"monthlyEstimate"`
+	Currency        string `json:"currency,omitempty"`
+	// CPU, Memory and Storage break MonthlyEstimate down
+	CPU     string `json:"cpu,omitempty"`
+	Memory  string `json:"memory,omitempty"`
+	Storage string `json:"storage,omitempty"`
+	// Pricing is the price source: static, aws, gcp or azure
+	Pricing string `json:"pricing,omitempty"`
+	// LastEstimated is when the estimate was last computed
+	LastEstimated metav1.Time `json:"lastEstimated,omitempty"`
+	// BudgetThreshold is the highest alert threshold of Spec.Budget the
+	// estimate has reached and that was announced
+	BudgetThreshold int32 `json:"budgetThreshold,omitempty"`
+	// Requests are the requests.cpu, requests.memory and requests.storage
+	// the estimate prices
+	Requests corev1.ResourceList `json:"requests,omitempty"`
+	// Period is the cost accrued in the current billing period, and
+	// PreviousPeriod that of the one before
+	Period         *TenantCostPeriod `json:"period,omitempty"`
+	PreviousPeriod *TenantCostPeriod `json:"previousPeriod,omitempty"`
+}
+
+// TenantCostPeriod is what a tenant's requests cost and amounted to over a
+// billing period, a calendar month in UTC, accrued estimate by estimate
+type TenantCostPeriod struct {
+	// Month is the period, e.g. "2024-03"
+	Month string `json:"month"`
+	Cost  string `json:"cost"`
+	// CPUCoreHours, MemoryGiBHours and StorageGiBMonths are the requests
+	// accrued
+	CPUCoreHours     string `json:"cpuCoreHours,omitempty"`
+	MemoryGiBHours   string `json:"memoryGiBHours,omitempty"`
+	StorageGiBMonths string `json:"storageGiBMonths,omitempty"`
+}
+
+// BackupStatus reports the latest backup of a tenant's schedule
+type BackupStatus struct {
+	// LastBackup names the latest Backup
+	LastBackup string `json:"lastBackup,omitempty"`
+	// LastBackupTime is when it completed, or started if it hasn't
+	LastBackupTime *metav1.Time `json:"lastBackupTime,omitempty"`
+	// LastBackupStatus is its Velero phase, such as Completed or Failed
+	LastBackupStatus string `json:"lastBackupStatus,omitempty"`
+}
+
+// PolicyStatus reports the violations the policy engine's audit found in
+// the tenant namespaces
+type PolicyStatus struct {
+	// Violations is the total over Constraints
+	Violations int32 `json:"violations"`
+	// Constraints reports each of the tenant's constraints
+	Constraints []PolicyConstraintStatus `json:"constraints,omitempty"`
+}
+
+// PolicyConstraintStatus reports the violations of one constraint
+type PolicyConstraintStatus struct {
+	// Kind is the constraint's kind, such as K8sRequiredLabels
+	Kind string `json:"kind"`
+	// Namespace is set for namespaced constraints, such as Kyverno
+	// Policies
+	Namespace  string `json:"namespace,omitempty"`
+	Name       string `json:"name"`
+	Violations int32  `json:"violations"`
+	// LastAudit is when the policy engine last audited the constraint
+	LastAudit *metav1.Time `json:"lastAudit,omitempty"`
+}
+
+// DedicatedNodesStatus reports the nodes of a dedicated node pool
+type DedicatedNodesStatus struct {
+	// Nodes are the nodes labelled and tainted for the tenant
+	Nodes []string `json:"nodes,omitempty"`
+	// AllocatableCPU is the CPU the nodes can allocate to pods
+	AllocatableCPU string `json:"allocatableCPU,omitempty"`
+}
+
+// NamespaceStatus reports one tenant namespace
+type NamespaceStatus struct {
+	Name string `json:"name"`
+	// Ready is true once every resource in the namespace is applied
+	Ready   bool   `json:"ready"`
+	Message string `json:"message,omitempty"`
+	// Adopted is true for an existing namespace annotated for adoption
+	Adopted bool `json:"adopted,omitempty"`
+	// RecommendedQuota is an advisory quota for this namespace
+	RecommendedQuota *RecommendedQuota `json:"recommendedQuota,omitempty"`
+}
+
+// ClusterStatus reports a tenant in one target cluster
+type ClusterStatus struct {
+	// Name is the name of the kubeconfig Secret
+	Name               string      `json:"name"`
+	Ready              bool        `json:"ready"`
+	Reason             string      `json:"reason,omitempty"`
+	Message            string      `json:"message,omitempty"`
+	LastTransitionTime metav1.Time `json:"lastTransitionTime,omitempty"`
+}
+
+// FailoverStatus reports the active cluster of a failover pair
+type FailoverStatus struct {
+	// ActiveCluster runs the tenant's workloads: the primary, or the
+	// standby after a failover
+	ActiveCluster string `json:"activeCluster"`
+	// LastFailoverTime is when the standby was last activated
+	LastFailoverTime *metav1.Time `json:"lastFailoverTime,omitempty"`
+	// LastFailbackTime is when the primary was last reactivated
+	LastFailbackTime *metav1.Time `json:"lastFailbackTime,omitempty"`
+}
+
+// RecommendedQuota is the advisory quota suggested from observed usage
+type RecommendedQuota struct {
+	// CPU and Memory are the suggested limits.cpu and limits.memory
+	CPU    string `json:"cpu"`
+	Memory string `json:"memory"`
+	// Samples is how many usage samples the recommendation is based on
+	Samples int `json:"samples"`
+}
+
+// TenantList contains a list of Tenant
+// +kubebuilder:object:root=true
+type TenantList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []Tenant `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&Tenant{}, &TenantList{})
+}
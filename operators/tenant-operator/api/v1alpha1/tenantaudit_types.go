@@ -0,0 +1,53 @@
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// TenantAudit is the change history of the Tenant of the same name: who
+// created, changed or deleted it, when, and which spec fields changed. The
+// Tenant validating webhook appends an entry for every change it admits.
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:scope=Cluster,shortName=tna
+type TenantAudit struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	// Entries are the changes, oldest first. Only the latest
+	// --audit-history are kept.
+	Entries []TenantAuditEntry `json:"entries,omitempty"`
+}
+
+// TenantAuditEntry is one admitted change to a Tenant
+type TenantAuditEntry struct {
+	Time metav1.Time `json:"time"`
+	// User and Groups made the request, as authenticated by the API server
+	User   string   `json:"user"`
+	Groups []string `json:"groups,omitempty"`
+	// Operation is CREATE, UPDATE or DELETE
+	Operation string `json:"operation"`
+	// Changes are the spec fields the request changed; none for DELETE
+	Changes []TenantAuditChange `json:"changes,omitempty"`
+}
+
+// TenantAuditChange is one changed spec field
+type TenantAuditChange struct {
+	// Path is the field's path, such as spec.quota.cpu
+	Path string `json:"path"`
+	// Old and New are the field's JSON values before and after the change,
+	// empty where it was or is unset
+	Old string `json:"old,omitempty"`
+	New string `json:"new,omitempty"`
+}
+
+// TenantAuditList contains a list of TenantAudit
+// +kubebuilder:object:root=true
+type TenantAuditList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []TenantAudit `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&TenantAudit{}, &TenantAuditList{})
+}
@@ -0,0 +1,59 @@
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// TenantProfile is a named bundle of quota, LimitRange and policy settings
+// Tenants select with Spec.Profile
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:scope=Cluster,shortName=tnp
+type TenantProfile struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec TenantProfileSpec `json:"spec,omitempty"`
+}
+
+// TenantProfileSpec holds the settings a profile gives its Tenants. Fields a
+// Tenant sets itself take precedence; boolean policies are on if either the
+// profile or the Tenant turns them on.
+type TenantProfileSpec struct {
+	Quota TenantQuota `json:"quota,omitempty"`
+	// LimitRange overrides the operator's --limitrange-* defaults
+	LimitRange TenantLimitRange `json:"limitRange,omitempty"`
+	// AllowIntraNamespace applies to Tenants that leave it unset
+	AllowIntraNamespace        *bool `json:"allowIntraNamespace,omitempty"`
+	NetworkIsolation           bool  `json:"networkIsolation,omitempty"`
+	MeshDefaultDeny            bool  `json:"meshDefaultDeny,omitempty"`
+	RequireSeccomp             bool  `json:"requireSeccomp,omitempty"`
+	DisableDefaultSATokenMount bool  `json:"disableDefaultSATokenMount,omitempty"`
+	// MaxReplicasCeiling applies to Tenants that leave it 0
+	MaxReplicasCeiling int32 `json:"maxReplicasCeiling,omitempty"`
+	// DefaultRequestRateLimit applies to Tenants that leave it empty
+	DefaultRequestRateLimit string `json:"defaultRequestRateLimit,omitempty"`
+}
+
+// TenantLimitRange sizes the tenant LimitRange. Omitted fields keep the
+// operator's defaults.
+type TenantLimitRange struct {
+	DefaultRequestCPU    string `json:"defaultRequestCPU,omitempty"`
+	DefaultRequestMemory string `json:"defaultRequestMemory,omitempty"`
+	DefaultLimitCPU      string `json:"defaultLimitCPU,omitempty"`
+	DefaultLimitMemory   string `json:"defaultLimitMemory,omitempty"`
+	// MaxContainerPercent caps a container's limits at this share of the
+	// tenant's CPU and memory quota
+	MaxContainerPercent int `json:"maxContainerPercent,omitempty"`
+}
+
+// TenantProfileList contains a list of TenantProfile
+// +kubebuilder:object:root=true
+type TenantProfileList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []TenantProfile `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&TenantProfile{}, &TenantProfileList{})
+}
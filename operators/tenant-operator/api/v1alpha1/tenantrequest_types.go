@@ -0,0 +1,84 @@
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// TenantRequest asks for the Tenant of the same name. Platform admins
+// approve or reject it by setting Status.Phase through the status
+// subresource; once approved, the operator creates the Tenant.
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:resource:scope=Cluster,shortName=tnr
+type TenantRequest struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   TenantRequestSpec   `json:"spec,omitempty"`
+	Status TenantRequestStatus `json:"status,omitempty"`
+}
+
+// TenantRequestSpec is the Tenant asked for. Its fields become those of
+// the Tenant's spec; the webhooks default the rest, from the profile if
+// one is given.
+type TenantRequestSpec struct {
+	Owner      string            `json:"owner"`
+	CostCenter string            `json:"costCenter,omitempty"`
+	Profile    string            `json:"profile,omitempty"`
+	Parent     string            `json:"parent,omitempty"`
+	Namespaces []string          `json:"namespaces,omitempty"`
+	Contacts   map[string]string `json:"contacts,omitempty"`
+	Quota      TenantQuota       `json:"quota,omitempty"`
+	TTL        *metav1.Duration  `json:"ttl,omitempty"`
+	// Reason tells the reviewers what the tenant is for
+	Reason string `json:"reason,omitempty"`
+	// RequestedBy is the user who created the request, set by the
+	// TenantRequest webhook
+	RequestedBy string `json:"requestedBy,omitempty"`
+}
+
+// TenantRequestPhase is where a TenantRequest is in its review
+type TenantRequestPhase string
+
+const (
+	// TenantRequestPending requests await a reviewer's decision
+	TenantRequestPending TenantRequestPhase = "Pending"
+	// TenantRequestApproved requests get their Tenant created
+	TenantRequestApproved TenantRequestPhase = "Approved"
+	// TenantRequestRejected requests are closed without a Tenant
+	TenantRequestRejected TenantRequestPhase = "Rejected"
+)
+
+// TenantRequestStatus is the review of a TenantRequest and its outcome
+type TenantRequestStatus struct {
+	// Phase is Pending until a reviewer sets it Approved or Rejected; the
+	// decision is final
+	// +kubebuilder:validation:Enum=Pending;Approved;Rejected
+	Phase TenantRequestPhase `json:"phase,omitempty"`
+	// Comment is the reviewer's note, such as why the request was rejected
+	Comment string `json:"comment,omitempty"`
+	// ReviewedBy and ReviewedAt are who decided on the request and when,
+	// set by the TenantRequest webhook
+	ReviewedBy string       `json:"reviewedBy,omitempty"`
+	ReviewedAt *metav1.Time `json:"reviewedAt,omitempty"`
+	// AutoApproved is true when the request was approved without review,
+	// being within the operator's --auto-approve-* limits
+	AutoApproved bool `json:"autoApproved,omitempty"`
+	// Tenant names the Tenant created for an approved request
+	Tenant string `json:"tenant,omitempty"`
+	// Message explains why an approved request's Tenant couldn't be
+	// created
+	Message string `json:"message,omitempty"`
+}
+
+// TenantRequestList contains a list of TenantRequest
+// +kubebuilder:object:root=true
+type TenantRequestList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []TenantRequest `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&TenantRequest{}, &TenantRequestList{})
+}
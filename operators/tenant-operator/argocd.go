@@ -0,0 +1,169 @@
+// Argo CD projects
+// Spec.GitOps.ArgoCD gives the tenant an Argo CD AppProject named after
+// it. Its Applications may only sync the tenant's source repos into the
+// tenant's namespaces on the listed clusters, never cluster-scoped
+// resources or the quotas the operator manages. Project roles mirror
+// Spec.Access, so the tenant's groups get the same say in Argo CD as in
+// the cluster.
+// Argo CD is optional, so the AppProject is skipped when its CRD is
+// missing.
+
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	platformv1alpha1 "github.com/xyz-company/tenant-operator/api/v1alpha1"
+)
+
+var appProjectGVK = schema.GroupVersionKind{Group: "argoproj.io", Version: "v1alpha1", Kind: "AppProject"}
+
+// defaultArgoCDDestination is the cluster name Argo CD gives its own cluster
+const defaultArgoCDDestination = "in-cluster"
+
+// argoCDRolePolicies are the Application permissions of each tenant role
+// in its project, as Argo CD RBAC actions
+var argoCDRolePolicies = map[platformv1alpha1.TenantRole][]string{
+	platformv1alpha1.TenantRoleViewer:    {"get"},
+	platformv1alpha1.TenantRoleDeveloper: {"get", "sync", "action/*"},
+	platformv1alpha1.TenantRoleAdmin:     {"*"},
+}
+
+// validateArgoCD rejects projects without source repos, or allowing every
+// repo
+func validateArgoCD(gitops *platformv1alpha1.TenantGitOps) error {
+	if gitops == nil || gitops.ArgoCD == nil {
+		return nil
+	}
+	if len(gitops.ArgoCD.SourceRepos) == 0 {
+		return fmt.Errorf("gitops.argocd.sourceRepos must list the tenant's repositories")
+	}
+	for _, repo := range gitops.ArgoCD.SourceRepos {
+		if repo == "" || repo == "*" {
+			return fmt.Errorf("gitops.argocd.sourceRepos %q must name a repository or a pattern narrower than *", repo)
+		}
+	}
+	for _, cluster := range gitops.ArgoCD.Destinations {
+		if cluster == "" || cluster == "*" {
+			return fmt.Errorf("gitops.argocd.destinations %q must name an Argo CD cluster", cluster)
+		}
+	}
+	return nil
+}
+
+// reconcileAppProject applies the tenant's AppProject for namespaces, or
+// deletes it once the tenant no longer has Spec.GitOps.ArgoCD
+func (r *TenantReconciler) reconcileAppProject(ctx context.Context, tenant *platformv1alpha1.Tenant, namespaces []string) error {
+	log := ctrl.LoggerFrom(ctx)
+	enabled := tenant.Spec.GitOps != nil && tenant.Spec.GitOps.ArgoCD != nil
+
+	installed, err := r.kindInstalled(appProjectGVK)
+	if err != nil {
+		return err
+	}
+	if !installed {
+		if enabled {
+			log.Info("Argo CD CRD not installed, skipping AppProject")
+		}
+		return nil
+	}
+
+	project := r.appProject(tenant, namespaces)
+	if !enabled {
+		return r.deleteIfControlled(ctx, tenant, project)
+	}
+	if err := r.applyOrAdopt(ctx, tenant, project); err != nil {
+		return err
+	}
+	log.Info("AppProject applied", "appProject", r.ArgoCDNamespace+"/"+tenant.Name)
+	return nil
+}
+
+// appProject returns the tenant's AppProject, limited to its repos, its
+// namespaces on the destination clusters and namespaced resources, with a
+// role per granted tenant role
+func (r *TenantReconciler) appProject(tenant *platformv1alpha1.Tenant, namespaces []string) *unstructured.Unstructured {
+	project := &unstructured.Unstructured{Object: map[string]interface{}{}}
+	project.SetGroupVersionKind(appProjectGVK)
+	project.SetNamespace(r.ArgoCDNamespace)
+	project.SetName(tenant.Name)
+	if tenant.Spec.GitOps == nil || tenant.Spec.GitOps.ArgoCD == nil {
+		return project
+	}
+	argocd := tenant.Spec.GitOps.ArgoCD
+
+	clusters := argocd.Destinations
+	if len(clusters) == 0 {
+		clusters = []string{defaultArgoCDDestination}
+	}
+	var destinations, sourceNamespaces []interface{}
+	for _, namespace := range namespaces {
+		for _, cluster := range clusters {
+			destinations = append(destinations, map[string]interface{}{"name": cluster, "namespace": namespace})
+		}
+		sourceNamespaces = append(sourceNamespaces, namespace)
+	}
+	repos := make([]interface{}, 0, len(argocd.SourceRepos))
+	for _, repo := range argocd.SourceRepos {
+		repos = append(repos, repo)
+	}
+
+	project.Object["spec"] = map[string]interface{}{
+		"description":      fmt.Sprintf("Applications of tenant %s", tenant.Name),
+		"sourceRepos":      repos,
+		"destinations":     destinations,
+		"sourceNamespaces": sourceNamespaces,
+		// No cluster-scoped resources, and none of the operator's own
+		"clusterResourceWhitelist": []interface{}{},
+		"namespaceResourceBlacklist": []interface{}{
+			map[string]interface{}{"group": "", "kind": "ResourceQuota"},
+			map[string]interface{}{"group": "", "kind": "LimitRange"},
+		},
+		"roles": appProjectRoles(tenant),
+	}
+	return project
+}
+
+// appProjectRoles returns a project role per tenant role granted to groups
+// in Spec.Access, in tenantRoles order. Argo CD project roles only map
+// groups, so users granted a role get none.
+func appProjectRoles(tenant *platformv1alpha1.Tenant) []interface{} {
+	groups := map[platformv1alpha1.TenantRole][]interface{}{}
+	seen := map[platformv1alpha1.TenantRole]map[string]bool{}
+	for _, access := range tenantAccess(tenant) {
+		for _, group := range access.Groups {
+			if seen[access.Role] == nil {
+				seen[access.Role] = map[string]bool{}
+			}
+			if !seen[access.Role][group] {
+				seen[access.Role][group] = true
+				groups[access.Role] = append(groups[access.Role], group)
+			}
+		}
+	}
+
+	roles := []interface{}{}
+	for _, role := range tenantRoles {
+		if len(groups[role.role]) == 0 {
+			continue
+		}
+		subject := fmt.Sprintf("proj:%s:%s", tenant.Name, role.role)
+		policies := []interface{}{}
+		for _, action := range argoCDRolePolicies[role.role] {
+			policies = append(policies, fmt.Sprintf("p, %s, applications, %s, %s/*, allow", subject, action, tenant.Name))
+		}
+		policies = append(policies, fmt.Sprintf("p, %s, logs, get, %s/*, allow", subject, tenant.Name))
+		roles = append(roles, map[string]interface{}{
+			"name":        string(role.role),
+			"description": fmt.Sprintf("Tenant %s %ss", tenant.Name, role.role),
+			"policies":    policies,
+			"groups":      groups[role.role],
+		})
+	}
+	return roles
+}
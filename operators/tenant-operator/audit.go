@@ -0,0 +1,159 @@
+// Tenant audit
+// The validating webhook records every Tenant change it admits in the
+// TenantAudit of the same name: who made it, when and which spec fields it
+// changed, so "who raised this tenant's quota" has an answer. Requests
+// changing no spec field, such as the operator's own finalizer updates, and
+// dry runs aren't recorded. A change whose entry can't be written is
+// rejected rather than left unaudited. TenantAudits outlive their Tenant,
+// keeping its deletion on record.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	platformv1alpha1 "github.com/xyz-company/tenant-operator/api/v1alpha1"
+)
+
+// TenantAuditor appends admitted Tenant changes to their TenantAudit
+type TenantAuditor struct {
+	Client client.Client
+	// Reader reads the TenantAudits uncached; the operator doesn't watch them
+	Reader client.Reader
+	// History is how many entries a TenantAudit keeps (--audit-history)
+	History int
+}
+
+// Record appends the change req makes to its Tenant, if any
+func (a *TenantAuditor) Record(ctx context.Context, req admission.Request) error {
+	if req.DryRun != nil && *req.DryRun {
+		return nil
+	}
+	entry := platformv1alpha1.TenantAuditEntry{
+		Time:      metav1.Now(),
+		User:      req.UserInfo.Username,
+		Groups:    req.UserInfo.Groups,
+		Operation: string(req.Operation),
+	}
+	if req.Operation != admissionv1.Delete {
+		changes, err := specChanges(req.OldObject.Raw, req.Object.Raw)
+		if err != nil {
+			return err
+		}
+		if len(changes) == 0 {
+			return nil
+		}
+		entry.Changes = changes
+	}
+
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		audit := &platformv1alpha1.TenantAudit{}
+		err := a.Reader.Get(ctx, types.NamespacedName{Name: req.Name}, audit)
+		if errors.IsNotFound(err) {
+			audit = &platformv1alpha1.TenantAudit{
+				ObjectMeta: metav1.ObjectMeta{Name: req.Name, Labels: map[string]string{tenantLabel: req.Name}},
+				Entries:    []platformv1alpha1.TenantAuditEntry{entry},
+			}
+			err = a.Client.Create(ctx, audit)
+			if errors.IsAlreadyExists(err) {
+				// Raced with another change; retried as a conflict
+				return errors.NewConflict(platformv1alpha1.GroupVersion.WithResource("tenantaudits").GroupResource(), req.Name, err)
+			}
+			return err
+		}
+		if err != nil {
+			return err
+		}
+		audit.Entries = append(audit.Entries, entry)
+		if excess := len(audit.Entries) - a.History; excess > 0 {
+			audit.Entries = audit.Entries[excess:]
+		}
+		return a.Client.Update(ctx, audit)
+	})
+}
+
+// specChanges lists the spec fields that differ between the Tenants old
+// and new, as JSON. An empty old, on CREATE, lists every field set.
+func specChanges(old, new []byte) ([]platformv1alpha1.TenantAuditChange, error) {
+	var before, after struct {
+		Spec map[string]interface{} `json:"spec"`
+	}
+	if len(old) > 0 {
+		if err := json.Unmarshal(old, &before); err != nil {
+			return nil, err
+		}
+	}
+	if err := json.Unmarshal(new, &after); err != nil {
+		return nil, err
+	}
+	var changes []platformv1alpha1.TenantAuditChange
+	diffFields("spec", before.Spec, after.Spec, &changes)
+	return changes, nil
+}
+
+// diffFields appends the fields under path that differ between before and
+// after to changes. Objects are compared field by field; lists and values
+// whole.
+func diffFields(path string, before, after map[string]interface{}, changes *[]platformv1alpha1.TenantAuditChange) {
+	keys := make([]string, 0, len(before)+len(after))
+	for key := range before {
+		keys = append(keys, key)
+	}
+	for key := range after {
+		if _, ok := before[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		field := path + "." + key
+		old, new := before[key], after[key]
+		oldObject, oldIsObject := old.(map[string]interface{})
+		newObject, newIsObject := new.(map[string]interface{})
+		switch {
+		case oldIsObject && newIsObject:
+			diffFields(field, oldObject, newObject, changes)
+		case equality.Semantic.DeepEqual(old, new):
+		default:
+			*changes = append(*changes, platformv1alpha1.TenantAuditChange{Path: field, Old: auditValue(old), New: auditValue(new)})
+		}
+	}
+}
+
+// auditValue returns value as JSON, empty if unset
+func auditValue(value interface{}) string {
+	if value == nil {
+		return ""
+	}
+	raw, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	return string(raw)
+}
+
+// recordAudit records the change req makes in the audit trail, rejecting
+// it if that fails
+func (v *TenantValidator) recordAudit(ctx context.Context, req admission.Request) admission.Response {
+	if v.Audit == nil {
+		return admission.Allowed("")
+	}
+	if err := v.Audit.Record(ctx, req); err != nil {
+		return admission.Errored(http.StatusInternalServerError, fmt.Errorf("recording the change in TenantAudit %q: %w", req.Name, err))
+	}
+	return admission.Allowed("")
+}
@@ -0,0 +1,205 @@
+// Tenant backups
+// Spec.Backup gives the tenant a Velero Schedule, tenant-<name> in
+// --velero-namespace, backing up its namespaces and nothing else. The
+// latest Backup the schedule made is reported in Status.Backup. Velero is
+// optional, so the schedule is skipped when its CRDs are missing. Backups
+// outlive the schedule and the Tenant until their TTL runs out.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	platformv1alpha1 "github.com/xyz-company/tenant-operator/api/v1alpha1"
+)
+
+var (
+	veleroScheduleGVK = schema.GroupVersionKind{Group: "velero.io", Version: "v1", Kind: "Schedule"}
+	veleroBackupGVK   = schema.GroupVersionKind{Group: "velero.io", Version: "v1", Kind: "Backup"}
+)
+
+const (
+	// veleroScheduleLabel names the Schedule on the Backups it makes
+	veleroScheduleLabel = "velero.io/schedule-name"
+	// backupStatusInterval is how often the latest backup of a tenant with
+	// a schedule is looked up; Backups aren't watched
+	backupStatusInterval = 15 * time.Minute
+)
+
+// Velero Backup phases reported as failures
+const (
+	BackupPhaseFailed          = "Failed"
+	BackupPhasePartiallyFailed = "PartiallyFailed"
+)
+
+var (
+	// cronField matches one field of a cron expression
+	cronField = regexp.MustCompile(`^[0-9A-Za-z*?/,\-]+$`)
+	// cronDescriptors are the schedules Velero accepts besides cron
+	// expressions, other than @every
+	cronDescriptors = map[string]bool{"@yearly": true, "@annually": true, "@monthly": true, "@weekly": true, "@daily": true, "@midnight": true, "@hourly": true}
+)
+
+// validateBackup rejects schedules that aren't cron expressions or
+// descriptors and TTLs that aren't positive durations
+func validateBackup(backup *platformv1alpha1.TenantBackup) error {
+	if backup == nil {
+		return nil
+	}
+	schedule := strings.TrimSpace(backup.Schedule)
+	switch {
+	case cronDescriptors[schedule]:
+	case strings.HasPrefix(schedule, "@every "):
+		if every, err := time.ParseDuration(strings.TrimPrefix(schedule, "@every ")); err != nil || every <= 0 {
+			return fmt.Errorf("backup.schedule %q must be @every with a positive duration", backup.Schedule)
+		}
+	default:
+		fields := strings.Fields(schedule)
+		if len(fields) != 5 {
+			return fmt.Errorf("backup.schedule %q must be a cron expression of five fields or a descriptor such as @daily", backup.Schedule)
+		}
+		for _, field := range fields {
+			if !cronField.MatchString(field) {
+				return fmt.Errorf("backup.schedule %q: invalid field %q", backup.Schedule, field)
+			}
+		}
+	}
+	if backup.TTL != "" {
+		if ttl, err := time.ParseDuration(backup.TTL); err != nil || ttl <= 0 {
+			return fmt.Errorf("backup.ttl %q must be a positive duration such as 720h", backup.TTL)
+		}
+	}
+	return nil
+}
+
+// backupScheduleName names tenant's Velero Schedule
+func backupScheduleName(tenant *platformv1alpha1.Tenant) string {
+	return "tenant-" + tenant.Name
+}
+
+// backupSchedule returns tenant's Velero Schedule, backing up namespaces
+func (r *TenantReconciler) backupSchedule(tenant *platformv1alpha1.Tenant, namespaces []string) *unstructured.Unstructured {
+	schedule := &unstructured.Unstructured{Object: map[string]interface{}{}}
+	schedule.SetGroupVersionKind(veleroScheduleGVK)
+	schedule.SetNamespace(r.VeleroNamespace)
+	schedule.SetName(backupScheduleName(tenant))
+	backup := tenant.Spec.Backup
+	if backup == nil {
+		return schedule
+	}
+
+	included := make([]interface{}, 0, len(namespaces))
+	for _, namespace := range namespaces {
+		included = append(included, namespace)
+	}
+	template := map[string]interface{}{
+		"includedNamespaces": included,
+		"snapshotVolumes":    backup.IncludeVolumes,
+		// Only what the tenant owns; the operator recreates the rest
+		"includeClusterResources": false,
+		"metadata": map[string]interface{}{
+			"labels": map[string]interface{}{tenantLabel: tenant.Name},
+		},
+	}
+	if backup.TTL != "" {
+		template["ttl"] = backup.TTL
+	}
+	schedule.Object["spec"] = map[string]interface{}{
+		"schedule": backup.Schedule,
+		"template": template,
+		// Backups must survive the schedule, and the tenant, being deleted
+		"useOwnerReferencesInBackup": false,
+	}
+	return schedule
+}
+
+// reconcileBackup applies tenant's Velero Schedule for namespaces, or
+// deletes it once the tenant no longer has Spec.Backup, and records the
+// latest backup. It returns when to look for the next backup.
+func (r *TenantReconciler) reconcileBackup(ctx context.Context, tenant *platformv1alpha1.Tenant, namespaces []string) (time.Duration, error) {
+	log := ctrl.LoggerFrom(ctx)
+	enabled := tenant.Spec.Backup != nil
+
+	installed, err := r.kindInstalled(veleroScheduleGVK)
+	if err != nil {
+		return 0, err
+	}
+	if !installed {
+		if enabled {
+			log.Info("Velero CRDs not installed, skipping backup schedule")
+		}
+		tenant.Status.Backup = nil
+		return 0, nil
+	}
+
+	schedule := r.backupSchedule(tenant, namespaces)
+	if !enabled {
+		tenant.Status.Backup = nil
+		return 0, r.deleteIfControlled(ctx, tenant, schedule)
+	}
+	if err := r.applyOrAdopt(ctx, tenant, schedule); err != nil {
+		return 0, err
+	}
+
+	latest, err := r.latestBackup(ctx, tenant)
+	if err != nil {
+		return 0, err
+	}
+	if latest == nil {
+		tenant.Status.Backup = nil
+		return backupStatusInterval, nil
+	}
+	phase, _, _ := unstructured.NestedString(latest.Object, "status", "phase")
+	status := &platformv1alpha1.BackupStatus{LastBackup: latest.GetName(), LastBackupStatus: phase}
+	for _, field := range []string{"completionTimestamp", "startTimestamp"} {
+		if value, _, _ := unstructured.NestedString(latest.Object, "status", field); value != "" {
+			if t, err := time.Parse(time.RFC3339, value); err == nil {
+				status.LastBackupTime = &metav1.Time{Time: t}
+				break
+			}
+		}
+	}
+
+	previous := tenant.Status.Backup
+	changed := previous == nil || previous.LastBackup != status.LastBackup || previous.LastBackupStatus != status.LastBackupStatus
+	if changed && (phase == BackupPhaseFailed || phase == BackupPhasePartiallyFailed) {
+		r.Recorder.Eventf(tenant, corev1.EventTypeWarning, "BackupFailed", "Velero backup %s/%s is %s", r.VeleroNamespace, status.LastBackup, phase)
+	}
+	tenant.Status.Backup = status
+	return backupStatusInterval, nil
+}
+
+// latestBackup returns the newest Backup of tenant's schedule, nil if it
+// made none yet. Backups aren't cached, so they are listed from the API
+// server.
+func (r *TenantReconciler) latestBackup(ctx context.Context, tenant *platformv1alpha1.Tenant) (*unstructured.Unstructured, error) {
+	backups := &unstructured.UnstructuredList{}
+	backups.SetGroupVersionKind(veleroBackupGVK.GroupVersion().WithKind(veleroBackupGVK.Kind + "List"))
+	if err := r.APIReader.List(ctx, backups, client.InNamespace(r.VeleroNamespace), client.MatchingLabels{veleroScheduleLabel: backupScheduleName(tenant)}); err != nil {
+		return nil, err
+	}
+	var latest *unstructured.Unstructured
+	for i := range backups.Items {
+		backup := &backups.Items[i]
+		if latest == nil {
+			latest = backup
+			continue
+		}
+		newest, created := latest.GetCreationTimestamp(), backup.GetCreationTimestamp()
+		if newest.Before(&created) {
+			latest = backup
+		}
+	}
+	return latest, nil
+}
@@ -0,0 +1,343 @@
+// Cost pricing
+// The static price source is a rate card ConfigMap, for on-prem clusters:
+//
+//	cpuCoreHour: "0.031"
+//	memoryGiBHour: "0.0042"
+//	storageGiBMonth: "0.10"
+//	currency: USD
+//
+// The cloud sources derive the rates from what the cluster was actually
+// billed, reading a billing export the platform syncs to a file: an AWS
+// Cost and Usage Report, a GCP billing export from BigQuery or an Azure
+// Cost Management export, as CSV, optionally gzipped. Each rate is the
+// cost of the matching line items over their usage. Instance hours, billed
+// as a whole, are split between their vCPUs and memory at
+// cpuMemoryPriceRatio. Rates the export doesn't yield, such as Azure
+// managed disks billed per disk, are taken from the rate card if there is
+// one.
+
+package main
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// Price sources of --cost-pricing
+const (
+	PricingStatic = "static"
+	PricingAWS    = "aws"
+	PricingGCP    = "gcp"
+	PricingAzure  = "azure"
+)
+
+const (
+	// cpuMemoryPriceRatio is how many GiB-hours of memory cost as much as a
+	// vCPU-hour, as in general purpose instance prices
+	cpuMemoryPriceRatio = 7.5
+	// azureMemoryPerVCPU is the GiB of memory assumed per vCPU of an Azure
+	// VM, whose usage records don't carry its memory
+	azureMemoryPerVCPU = 4
+	// defaultCurrency is assumed for rate cards and exports that name none
+	defaultCurrency = "USD"
+)
+
+// RateCard prices resources at the rates in a ConfigMap
+type RateCard struct {
+	Reader    client.Reader
+	ConfigMap types.NamespacedName
+}
+
+// Name implements CostPricing
+func (c *RateCard) Name() string {
+	return PricingStatic
+}
+
+// Rates implements CostPricing, reading the rate card on every call so
+// edits apply straight away
+func (c *RateCard) Rates(ctx context.Context) (CostRates, error) {
+	cm := &corev1.ConfigMap{}
+	if err := c.Reader.Get(ctx, c.ConfigMap, cm); err != nil {
+		return CostRates{}, err
+	}
+	rates := CostRates{Currency: cm.Data["currency"]}
+	if rates.Currency == "" {
+		rates.Currency = defaultCurrency
+	}
+	for key, rate := range map[string]*float64{
+		"cpuCoreHour":     &rates.CPUCoreHour,
+		"memoryGiBHour":   &rates.MemoryGiBHour,
+		"storageGiBMonth": &rates.StorageGiBMonth,
+	} {
+		value, ok := cm.Data[key]
+		if !ok {
+			continue
+		}
+		parsed, err := strconv.ParseFloat(value, 64)
+		if err != nil || parsed < 0 {
+			return CostRates{}, fmt.Errorf("rate card %s: %s must be a non-negative number, got %q", c.ConfigMap, key, value)
+		}
+		*rate = parsed
+	}
+	return rates, nil
+}
+
+// BillingExport derives rates from a cloud provider's billing export
+type BillingExport struct {
+	// Provider is PricingAWS, PricingGCP or PricingAzure
+	Provider string
+	// Path is the export file (--cost-billing-export)
+	Path string
+	// RateCard, when set, prices what the export doesn't
+	RateCard *RateCard
+
+	mu      sync.Mutex
+	modTime time.Time
+	rates   CostRates
+}
+
+// Name implements CostPricing
+func (e *BillingExport) Name() string {
+	return e.Provider
+}
+
+// Rates implements CostPricing. The export is parsed again only once the
+// file changes.
+func (e *BillingExport) Rates(ctx context.Context) (CostRates, error) {
+	info, err := os.Stat(e.Path)
+	if err != nil {
+		return CostRates{}, err
+	}
+
+	e.mu.Lock()
+	rates, fresh := e.rates, info.ModTime().Equal(e.modTime)
+	e.mu.Unlock()
+	if !fresh {
+		if rates, err = e.parse(); err != nil {
+			return CostRates{}, fmt.Errorf("billing export %s: %w", e.Path, err)
+		}
+		e.mu.Lock()
+		e.rates, e.modTime = rates, info.ModTime()
+		e.mu.Unlock()
+	}
+
+	if e.RateCard == nil || (rates.CPUCoreHour > 0 && rates.MemoryGiBHour > 0 && rates.StorageGiBMonth > 0) {
+		return rates, nil
+	}
+	card, err := e.RateCard.Rates(ctx)
+	if errors.IsNotFound(err) {
+		return rates, nil
+	}
+	if err != nil {
+		return CostRates{}, err
+	}
+	for _, fill := range []struct{ rate, fallback *float64 }{
+		{&rates.CPUCoreHour, &card.CPUCoreHour},
+		{&rates.MemoryGiBHour, &card.MemoryGiBHour},
+		{&rates.StorageGiBMonth, &card.StorageGiBMonth},
+	} {
+		if *fill.rate == 0 {
+			*fill.rate = *fill.fallback
+		}
+	}
+	return rates, nil
+}
+
+// billingTotals accumulates the cost and usage of each priced resource
+type billingTotals struct {
+	currency                   string
+	cpuCost, cpuHours          float64
+	memoryCost, memoryHours    float64
+	storageCost, storageMonths float64
+}
+
+// addInstance splits the cost of hours of an instance with vcpus and
+// memoryGiB between its CPU and memory
+func (t *billingTotals) addInstance(hours, vcpus, memoryGiB, cost float64) {
+	if vcpus <= 0 {
+		return
+	}
+	memoryShare := memoryGiB / (vcpus*cpuMemoryPriceRatio + memoryGiB)
+	t.cpuCost += cost * (1 - memoryShare)
+	t.cpuHours += vcpus * hours
+	t.memoryCost += cost * memoryShare
+	t.memoryHours += memoryGiB * hours
+}
+
+// rates returns the cost per unit of usage of each resource, 0 for those
+// without usage
+func (t *billingTotals) rates() CostRates {
+	rate := func(cost, usage float64) float64 {
+		if usage <= 0 {
+			return 0
+		}
+		return cost / usage
+	}
+	currency := t.currency
+	if currency == "" {
+		currency = defaultCurrency
+	}
+	return CostRates{
+		CPUCoreHour:     rate(t.cpuCost, t.cpuHours),
+		MemoryGiBHour:   rate(t.memoryCost, t.memoryHours),
+		StorageGiBMonth: rate(t.storageCost, t.storageMonths),
+		Currency:        currency,
+	}
+}
+
+// billingRow reads the columns of one export row by name
+type billingRow struct {
+	columns map[string]int
+	record  []string
+}
+
+// get returns the named column, "" when the export doesn't have it
+func (r billingRow) get(column string) string {
+	if i, ok := r.columns[column]; ok && i < len(r.record) {
+		return strings.TrimSpace(r.record[i])
+	}
+	return ""
+}
+
+// number returns the named column as a number, 0 when it isn't one
+func (r billingRow) number(column string) float64 {
+	value, _ := strconv.ParseFloat(r.get(column), 64)
+	return value
+}
+
+// billingColumns are the columns each provider's export must have
+var billingColumns = map[string][]string{
+	PricingAWS:   {"lineItem/UsageType", "lineItem/UsageAmount", "lineItem/UnblendedCost", "product/productFamily"},
+	PricingGCP:   {"sku.description", "usage.amount_in_pricing_units", "usage.pricing_unit", "cost"},
+	PricingAzure: {"MeterCategory", "MeterName", "Quantity", "CostInBillingCurrency"},
+}
+
+// parse reads the export and totals the line items priced
+func (e *BillingExport) parse() (CostRates, error) {
+	file, err := os.Open(e.Path)
+	if err != nil {
+		return CostRates{}, err
+	}
+	defer file.Close()
+	var in io.Reader = file
+	if strings.HasSuffix(e.Path, ".gz") {
+		gz, err := gzip.NewReader(file)
+		if err != nil {
+			return CostRates{}, err
+		}
+		defer gz.Close()
+		in = gz
+	}
+
+	reader := csv.NewReader(in)
+	reader.FieldsPerRecord = -1
+	header, err := reader.Read()
+	if err != nil {
+		return CostRates{}, err
+	}
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[strings.TrimSpace(name)] = i
+	}
+	for _, column := range billingColumns[e.Provider] {
+		if _, ok := columns[column]; !ok {
+			return CostRates{}, fmt.Errorf("no %s column in a %s export", column, e.Provider)
+		}
+	}
+
+	var totals billingTotals
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return CostRates{}, err
+		}
+		row := billingRow{columns: columns, record: record}
+		switch e.Provider {
+		case PricingAWS:
+			addAWSLineItem(&totals, row)
+		case PricingGCP:
+			addGCPLineItem(&totals, row)
+		case PricingAzure:
+			addAzureLineItem(&totals, row)
+		}
+	}
+	return totals.rates(), nil
+}
+
+// addAWSLineItem totals EC2 instance hours and EBS volume GB-months of a
+// Cost and Usage Report
+func addAWSLineItem(totals *billingTotals, row billingRow) {
+	if itemType := row.get("lineItem/LineItemType"); itemType != "" && itemType != "Usage" && itemType != "DiscountedUsage" {
+		return
+	}
+	if totals.currency == "" {
+		totals.currency = row.get("lineItem/CurrencyCode")
+	}
+	usageType := row.get("lineItem/UsageType")
+	switch {
+	case row.get("product/productFamily") == "Compute Instance" && strings.Contains(usageType, "BoxUsage"):
+		// product/memory reads like "8 GiB"
+		memory, _, _ := strings.Cut(row.get("product/memory"), " ")
+		memoryGiB, _ := strconv.ParseFloat(strings.ReplaceAll(memory, ",", ""), 64)
+		totals.addInstance(row.number("lineItem/UsageAmount"), row.number("product/vcpu"), memoryGiB, row.number("lineItem/UnblendedCost"))
+	case strings.Contains(usageType, "EBS:VolumeUsage"):
+		totals.storageCost += row.number("lineItem/UnblendedCost")
+		totals.storageMonths += row.number("lineItem/UsageAmount")
+	}
+}
+
+// addGCPLineItem totals the core, RAM and persistent disk SKUs of a GCP
+// billing export, which Compute Engine and GKE bill separately
+func addGCPLineItem(totals *billingTotals, row billingRow) {
+	if totals.currency == "" {
+		totals.currency = row.get("currency")
+	}
+	sku := strings.ToLower(row.get("sku.description"))
+	amount, cost := row.number("usage.amount_in_pricing_units"), row.number("cost")
+	switch unit := row.get("usage.pricing_unit"); {
+	case unit == "hour" && (strings.Contains(sku, "core") || strings.Contains(sku, "cpu")):
+		totals.cpuCost += cost
+		totals.cpuHours += amount
+	case unit == "gibibyte hour" && (strings.Contains(sku, "ram") || strings.Contains(sku, "memory")):
+		totals.memoryCost += cost
+		totals.memoryHours += amount
+	case unit == "gibibyte month" && (strings.Contains(sku, "pd capacity") || strings.Contains(sku, "storage pd")):
+		totals.storageCost += cost
+		totals.storageMonths += amount
+	}
+}
+
+// addAzureLineItem totals the VM hours of an Azure Cost Management export,
+// taking each VM's vCPUs from the AdditionalInfo of its usage record
+func addAzureLineItem(totals *billingTotals, row billingRow) {
+	if totals.currency == "" {
+		totals.currency = row.get("BillingCurrency")
+	}
+	if row.get("MeterCategory") != "Virtual Machines" {
+		return
+	}
+	var info struct {
+		VCPUs float64 `json:"VCPUs"`
+	}
+	if json.Unmarshal([]byte(row.get("AdditionalInfo")), &info) != nil || info.VCPUs == 0 {
+		return
+	}
+	totals.addInstance(row.number("Quantity"), info.VCPUs, info.VCPUs*azureMemoryPerVCPU, row.number("CostInBillingCurrency"))
+}
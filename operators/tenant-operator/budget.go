@@ -0,0 +1,195 @@
+// Tenant budgets
+// Spec.Budget puts a monthly limit on the cost estimate. Every time the
+// estimate first reaches one of the alert thresholds, a percentage of the
+// limit, the tenant gets a warning event and, with Notify, its contacts a
+// notification. The BudgetExceeded condition is True while the estimate is
+// at or above the limit. An estimate reaching HardCap suspends the tenant;
+// resuming it is left to its owners.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	platformv1alpha1 "github.com/xyz-company/tenant-operator/api/v1alpha1"
+)
+
+// ConditionBudgetExceeded is True while the cost estimate is at or above
+// Spec.Budget.MonthlyLimit
+const ConditionBudgetExceeded = "BudgetExceeded"
+
+// BudgetExceeded condition reasons
+const (
+	ReasonOverBudget   = "OverBudget"
+	ReasonWithinBudget = "WithinBudget"
+)
+
+// defaultBudgetThresholds are the alert thresholds of budgets setting none
+var defaultBudgetThresholds = []int32{80, 100}
+
+// validateBudget rejects budgets whose amounts aren't positive numbers or
+// whose thresholds aren't positive percentages
+func validateBudget(budget *platformv1alpha1.TenantBudget) error {
+	if budget == nil {
+		return nil
+	}
+	if amount, err := strconv.ParseFloat(budget.MonthlyLimit, 64); err != nil || amount <= 0 {
+		return fmt.Errorf("budget.monthlyLimit %q must be a positive amount", budget.MonthlyLimit)
+	}
+	if budget.HardCap != "" {
+		if amount, err := strconv.ParseFloat(budget.HardCap, 64); err != nil || amount <= 0 {
+			return fmt.Errorf("budget.hardCap %q must be a positive amount", budget.HardCap)
+		}
+	}
+	for _, threshold := range budget.AlertThresholds {
+		if threshold <= 0 {
+			return fmt.Errorf("budget.alertThresholds must be positive percentages, got %d", threshold)
+		}
+	}
+	return nil
+}
+
+// budgetThresholds returns the alert thresholds of budget
+func budgetThresholds(budget *platformv1alpha1.TenantBudget) []int32 {
+	if len(budget.AlertThresholds) == 0 {
+		return defaultBudgetThresholds
+	}
+	return budget.AlertThresholds
+}
+
+// reconcileBudget checks cost, the tenant's new estimate, against its
+// budget: it sets BudgetExceeded and cost.BudgetThreshold, announces a
+// newly reached threshold and suspends a tenant reaching its hard cap.
+// Tenants without a budget lose the condition.
+func (r *CostReconciler) reconcileBudget(ctx context.Context, tenant *platformv1alpha1.Tenant, cost *platformv1alpha1.TenantCost) error {
+	log := ctrl.LoggerFrom(ctx)
+	budget := tenant.Spec.Budget
+	if budget == nil || validateBudget(budget) != nil {
+		meta.RemoveStatusCondition(&tenant.Status.Conditions, ConditionBudgetExceeded)
+		return nil
+	}
+	estimate, _ := strconv.ParseFloat(cost.MonthlyEstimate, 64)
+	limit, _ := strconv.ParseFloat(budget.MonthlyLimit, 64)
+	percent := estimate / limit * 100
+
+	var reached int32
+	for _, threshold := range budgetThresholds(budget) {
+		if percent >= float64(threshold) && threshold > reached {
+			reached = threshold
+		}
+	}
+	var announced int32
+	if tenant.Status.Cost != nil {
+		announced = tenant.Status.Cost.BudgetThreshold
+	}
+	cost.BudgetThreshold = reached
+	if reached > announced {
+		r.Recorder.Eventf(tenant, corev1.EventTypeWarning, "BudgetThresholdReached", "Projected monthly cost %s %s is %.0f%% of the %s budget, reaching the %d%% alert threshold",
+			cost.MonthlyEstimate, cost.Currency, percent, budget.MonthlyLimit, reached)
+		if budget.Notify && r.Notifier != nil && len(tenant.Spec.Contacts) > 0 {
+			if err := r.Notifier.Notify(ctx, tenant, cost, reached); err != nil {
+				log.Error(err, "Failed to send budget notification")
+				r.Recorder.Eventf(tenant, corev1.EventTypeWarning, "NotificationFailed", "Budget notification failed: %v", err)
+				// Not recorded as announced, so the next estimate retries
+				cost.BudgetThreshold = announced
+			}
+		}
+	}
+
+	condition := metav1.Condition{
+		Type:               ConditionBudgetExceeded,
+		Status:             metav1.ConditionFalse,
+		Reason:             ReasonWithinBudget,
+		Message:            fmt.Sprintf("Projected monthly cost %s %s is %.0f%% of the %s budget", cost.MonthlyEstimate, cost.Currency, percent, budget.MonthlyLimit),
+		ObservedGeneration: tenant.Generation,
+	}
+	if percent >= 100 {
+		condition.Status = metav1.ConditionTrue
+		condition.Reason = ReasonOverBudget
+	}
+	meta.SetStatusCondition(&tenant.Status.Conditions, condition)
+
+	if budget.HardCap == "" || tenantSuspended(&tenant.Spec) {
+		return nil
+	}
+	if hardCap, _ := strconv.ParseFloat(budget.HardCap, 64); estimate < hardCap {
+		return nil
+	}
+	// Patch a copy, so the response doesn't overwrite the status being
+	// recorded
+	suspended := tenant.DeepCopy()
+	patch := client.MergeFrom(suspended.DeepCopy())
+	suspended.Spec.State = platformv1alpha1.TenantStateSuspended
+	if err := r.Patch(ctx, suspended, patch); err != nil {
+		return err
+	}
+	tenant.Spec.State = suspended.Spec.State
+	tenant.ResourceVersion = suspended.ResourceVersion
+	r.Recorder.Eventf(tenant, corev1.EventTypeWarning, "BudgetSuspended", "Projected monthly cost %s %s reached the %s hard cap, suspended the tenant",
+		cost.MonthlyEstimate, cost.Currency, budget.HardCap)
+	log.Info("Suspended tenant at its budget hard cap", "monthlyEstimate", cost.MonthlyEstimate, "hardCap", budget.HardCap)
+	return nil
+}
+
+// BudgetNotifier posts a JSON notification about tenants reaching a budget
+// alert threshold to a webhook, which delivers it to the contacts
+type BudgetNotifier struct {
+	URL    string
+	Client *http.Client
+}
+
+// budgetNotification is the body BudgetNotifier posts
+type budgetNotification struct {
+	Tenant          string            `json:"tenant"`
+	Owner           string            `json:"owner"`
+	CostCenter      string            `json:"costCenter,omitempty"`
+	Contacts        map[string]string `json:"contacts"`
+	MonthlyEstimate string            `json:"monthlyEstimate"`
+	MonthlyLimit    string            `json:"monthlyLimit"`
+	Currency        string            `json:"currency"`
+	Threshold       int32             `json:"threshold"`
+}
+
+// Notify tells tenant's contacts that its estimate cost reached threshold
+// percent of its budget
+func (n *BudgetNotifier) Notify(ctx context.Context, tenant *platformv1alpha1.Tenant, cost *platformv1alpha1.TenantCost, threshold int32) error {
+	body, err := json.Marshal(budgetNotification{
+		Tenant:          tenant.Name,
+		Owner:           tenant.Spec.Owner,
+		CostCenter:      tenant.Spec.CostCenter,
+		Contacts:        tenant.Spec.Contacts,
+		MonthlyEstimate: cost.MonthlyEstimate,
+		MonthlyLimit:    tenant.Spec.Budget.MonthlyLimit,
+		Currency:        cost.Currency,
+		Threshold:       threshold,
+	})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := n.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("notification webhook returned %s", resp.Status)
+	}
+	return nil
+}
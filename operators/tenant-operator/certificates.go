@@ -0,0 +1,213 @@
+// TLS certificate domains
+// Tenants request certificates from the platform's cert-manager
+// ClusterIssuers, which can issue for any domain the platform's DNS
+// serves. Spec.Certificates.DNSZones names the zones a tenant owns; the
+// certificate webhook only lets a tenant namespace request certificates
+// from a ClusterIssuer for names within them, or within its ingress
+// domain. Issuers the tenant runs in its own namespace are left alone.
+
+package main
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"strings"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	platformv1alpha1 "github.com/xyz-company/tenant-operator/api/v1alpha1"
+)
+
+// certificateZones returns the DNS zones certificates of the tenant may name
+func certificateZones(tenant *platformv1alpha1.Tenant) []string {
+	if tenant.Spec.Certificates == nil {
+		return nil
+	}
+	return tenant.Spec.Certificates.DNSZones
+}
+
+// validateCertificates rejects dnsZones entries that are listed twice or
+// aren't lowercase DNS names with at least two labels
+func validateCertificates(certificates *platformv1alpha1.TenantCertificates) error {
+	if certificates == nil {
+		return nil
+	}
+	seen := make(map[string]bool, len(certificates.DNSZones))
+	for _, zone := range certificates.DNSZones {
+		if errs := validation.IsDNS1123Subdomain(zone); len(errs) > 0 {
+			return fmt.Errorf("certificates.dnsZones %q is invalid: %s", zone, strings.Join(errs, ", "))
+		}
+		if !strings.Contains(zone, ".") {
+			return fmt.Errorf("certificates.dnsZones %q must not be a top-level domain", zone)
+		}
+		if seen[zone] {
+			return fmt.Errorf("certificates.dnsZones lists %q twice", zone)
+		}
+		seen[zone] = true
+	}
+	return nil
+}
+
+// zonesOverlap reports whether one of a and b is the other or lies below it
+func zonesOverlap(a, b string) bool {
+	return a == b || strings.HasSuffix(a, "."+b) || strings.HasSuffix(b, "."+a)
+}
+
+// inZones reports whether the DNS name, or the domain of a wildcard, lies
+// within one of zones
+func inZones(zones []string, name string) bool {
+	name = strings.TrimSuffix(strings.TrimPrefix(strings.ToLower(name), "*."), ".")
+	for _, zone := range zones {
+		if name == zone || strings.HasSuffix(name, "."+zone) {
+			return true
+		}
+	}
+	return false
+}
+
+// validateZoneClaims rejects Tenants with a DNS zone or ingress domain
+// overlapping one of another Tenant's, so each domain is owned by a single
+// tenant
+func (v *TenantValidator) validateZoneClaims(ctx context.Context, tenant *platformv1alpha1.Tenant) error {
+	zones := v.Ingress.ownedDomains(tenant)
+	if len(zones) == 0 {
+		return nil
+	}
+	tenants, err := listTenants(ctx, v.Client)
+	if err != nil {
+		return err
+	}
+	for i := range tenants {
+		if tenants[i].Name == tenant.Name {
+			continue
+		}
+		claims := v.Ingress.ownedDomains(&tenants[i])
+		// A zone stays allocated until the tenant's records are removed
+		if zone := tenants[i].Status.DNSZone; zone != "" {
+			claims = append(claims, zone)
+		}
+		for _, claimed := range claims {
+			for _, zone := range zones {
+				if zonesOverlap(zone, claimed) {
+					return fmt.Errorf("domain %s overlaps domain %s of tenant %q", zone, claimed, tenants[i].Name)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// CertificateValidator validates cert-manager Certificate and
+// CertificateRequest admission requests in tenant namespaces
+type CertificateValidator struct {
+	Client  client.Client
+	Decoder *admission.Decoder
+	Ingress IngressConfig
+}
+
+// Handle rejects Certificates and CertificateRequests for a ClusterIssuer
+// naming anything but DNS names within the dnsZones or ingress domain of
+// the Tenant owning their namespace. Tenants with neither can't use
+// ClusterIssuers.
+func (v *CertificateValidator) Handle(ctx context.Context, req admission.Request) admission.Response {
+	if req.Operation != admissionv1.Create && req.Operation != admissionv1.Update {
+		return admission.Allowed("")
+	}
+
+	tenant, err := tenantForNamespace(ctx, v.Client, req.Namespace)
+	if err != nil {
+		return admission.Errored(http.StatusInternalServerError, err)
+	}
+	if tenant == nil {
+		return admission.Allowed("")
+	}
+
+	obj := &unstructured.Unstructured{}
+	if err := v.Decoder.Decode(req, obj); err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+	kind, _, _ := unstructured.NestedString(obj.Object, "spec", "issuerRef", "kind")
+	if kind != "ClusterIssuer" {
+		return admission.Allowed("")
+	}
+	issuer, _, _ := unstructured.NestedString(obj.Object, "spec", "issuerRef", "name")
+
+	var names []string
+	switch req.Kind.Kind {
+	case "Certificate":
+		names, err = certificateNames(obj)
+	case "CertificateRequest":
+		names, err = certificateRequestNames(obj)
+	default:
+		return admission.Allowed("")
+	}
+	if err != nil {
+		return admission.Denied(err.Error())
+	}
+
+	zones := v.Ingress.ownedDomains(tenant)
+	if len(zones) == 0 {
+		return admission.Denied(fmt.Sprintf("tenant %q has no ingress domain or certificates.dnsZones and can't use ClusterIssuer %q", tenant.Name, issuer))
+	}
+	var denied []string
+	for _, name := range names {
+		if !inZones(zones, name) {
+			denied = append(denied, name)
+		}
+	}
+	if len(denied) == 0 {
+		return admission.Allowed("")
+	}
+	return admission.Denied(fmt.Sprintf("tenant %q may only request certificates from ClusterIssuer %q for %s; not allowed: %s",
+		tenant.Name, issuer, strings.Join(zones, ", "), strings.Join(denied, ", ")))
+}
+
+// certificateNames returns the common name and DNS names of a Certificate,
+// rejecting the other kinds of subject names ClusterIssuers can't verify
+func certificateNames(obj *unstructured.Unstructured) ([]string, error) {
+	for _, field := range []string{"ipAddresses", "uris", "emailAddresses", "otherNames"} {
+		if values, _, _ := unstructured.NestedSlice(obj.Object, "spec", field); len(values) > 0 {
+			return nil, fmt.Errorf("certificates from a ClusterIssuer may only name DNS names, not %s", field)
+		}
+	}
+	names, _, _ := unstructured.NestedStringSlice(obj.Object, "spec", "dnsNames")
+	if commonName, _, _ := unstructured.NestedString(obj.Object, "spec", "commonName"); commonName != "" {
+		names = append(names, commonName)
+	}
+	return names, nil
+}
+
+// certificateRequestNames returns the common name and DNS names of the CSR
+// in a CertificateRequest, rejecting the other kinds of subject names
+func certificateRequestNames(obj *unstructured.Unstructured) ([]string, error) {
+	// spec.request is []byte, so JSON carries the PEM base64-encoded
+	encoded, _, _ := unstructured.NestedString(obj.Object, "spec", "request")
+	raw, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("spec.request is not base64: %w", err)
+	}
+	block, _ := pem.Decode(raw)
+	if block == nil {
+		return nil, fmt.Errorf("spec.request holds no PEM certificate request")
+	}
+	csr, err := x509.ParseCertificateRequest(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("spec.request is invalid: %w", err)
+	}
+	if len(csr.IPAddresses) > 0 || len(csr.URIs) > 0 || len(csr.EmailAddresses) > 0 {
+		return nil, fmt.Errorf("certificates from a ClusterIssuer may only name DNS names")
+	}
+	names := csr.DNSNames
+	if csr.Subject.CommonName != "" {
+		names = append(names, csr.Subject.CommonName)
+	}
+	return names, nil
+}
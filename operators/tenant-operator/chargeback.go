@@ -0,0 +1,203 @@
+// Chargeback report
+// GET /tenants/chargeback reports what each tenant's requests amounted to
+// and cost over a billing period, by cost center, as JSON or CSV for
+// finance tooling. It reads the periods the cost estimates accrue in
+// Status.Cost, see cost.go.
+
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	platformv1alpha1 "github.com/xyz-company/tenant-operator/api/v1alpha1"
+)
+
+// ChargebackReport is the cost of a billing period by cost center
+type ChargebackReport struct {
+	Month       string                 `json:"month"`
+	GeneratedAt time.Time              `json:"generatedAt"`
+	CostCenters []CostCenterChargeback `json:"costCenters"`
+}
+
+// CostCenterChargeback totals the tenants of a cost center billed in one
+// currency
+type CostCenterChargeback struct {
+	CostCenter string `json:"costCenter"`
+	Currency   string `json:"currency"`
+	chargebackUsage
+	Tenants []TenantChargeback `json:"tenants"`
+}
+
+// TenantChargeback is one tenant's share of the period
+type TenantChargeback struct {
+	Name  string `json:"name"`
+	Owner string `json:"owner"`
+	chargebackUsage
+}
+
+// chargebackUsage is the cost of a period and the requests it accrued
+type chargebackUsage struct {
+	Cost             string `json:"cost"`
+	CPUCoreHours     string `json:"cpuCoreHours"`
+	MemoryGiBHours   string `json:"memoryGiBHours"`
+	StorageGiBMonths string `json:"storageGiBMonths"`
+}
+
+// chargebackColumns is the CSV header, one row per tenant
+var chargebackColumns = []string{"month", "costCenter", "currency", "tenant", "owner", "cost", "cpuCoreHours", "memoryGiBHours", "storageGiBMonths"}
+
+// handleChargeback serves the report of the month parameter, the current
+// month by default, in the format parameter, json or csv
+func (s *InventoryServer) handleChargeback(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !s.exportAuthorized(r) {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	query := r.URL.Query()
+	month := query.Get("month")
+	if month == "" {
+		month = billingMonth(time.Now())
+	} else if _, err := time.Parse("2006-01", month); err != nil {
+		http.Error(w, "month must be a month such as 2024-03", http.StatusBadRequest)
+		return
+	}
+	format := query.Get("format")
+	if format == "" {
+		format = "json"
+	}
+	if format != "json" && format != "csv" {
+		http.Error(w, "format must be json or csv", http.StatusBadRequest)
+		return
+	}
+
+	tenants, err := listTenants(r.Context(), s.Reader)
+	if err != nil {
+		ctrl.Log.WithName("chargeback").Error(err, "Failed to list Tenants")
+		http.Error(w, "Failed to list tenants", http.StatusInternalServerError)
+		return
+	}
+	report := buildChargeback(tenants, month, query.Get("costCenter"))
+
+	if format == "json" {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(report)
+		return
+	}
+	w.Header().Set("Content-Type", "text/csv")
+	w.Header().Set("Content-Disposition", "attachment; filename=chargeback-"+month+".csv")
+	out := csv.NewWriter(w)
+	out.Write(chargebackColumns)
+	for _, center := range report.CostCenters {
+		for _, tenant := range center.Tenants {
+			out.Write([]string{month, center.CostCenter, center.Currency, tenant.Name, tenant.Owner,
+				tenant.Cost, tenant.CPUCoreHours, tenant.MemoryGiBHours, tenant.StorageGiBMonths})
+		}
+	}
+	out.Flush()
+}
+
+// buildChargeback reports the tenants with a period of month, those of
+// costCenter only if set, sorted by cost center, currency and name
+func buildChargeback(tenants []platformv1alpha1.Tenant, month, costCenter string) ChargebackReport {
+	report := ChargebackReport{Month: month, GeneratedAt: time.Now().UTC(), CostCenters: []CostCenterChargeback{}}
+	type centerKey struct{ costCenter, currency string }
+	centers := map[centerKey]*CostCenterChargeback{}
+	totals := map[centerKey]*chargebackTotals{}
+
+	for i := range tenants {
+		tenant := &tenants[i]
+		if costCenter != "" && tenant.Spec.CostCenter != costCenter {
+			continue
+		}
+		period := chargebackPeriod(tenant.Status.Cost, month)
+		if period == nil {
+			continue
+		}
+		key := centerKey{tenant.Spec.CostCenter, tenant.Status.Cost.Currency}
+		center, ok := centers[key]
+		if !ok {
+			center = &CostCenterChargeback{CostCenter: key.costCenter, Currency: key.currency}
+			centers[key] = center
+			totals[key] = &chargebackTotals{}
+		}
+		var own chargebackTotals
+		own.add(period)
+		totals[key].add(period)
+		center.Tenants = append(center.Tenants, TenantChargeback{
+			Name:            tenant.Name,
+			Owner:           tenant.Spec.Owner,
+			chargebackUsage: own.usage(),
+		})
+	}
+
+	for key, center := range centers {
+		center.chargebackUsage = totals[key].usage()
+		sort.Slice(center.Tenants, func(i, j int) bool { return center.Tenants[i].Name < center.Tenants[j].Name })
+		report.CostCenters = append(report.CostCenters, *center)
+	}
+	sort.Slice(report.CostCenters, func(i, j int) bool {
+		a, b := report.CostCenters[i], report.CostCenters[j]
+		if a.CostCenter != b.CostCenter {
+			return a.CostCenter < b.CostCenter
+		}
+		return a.Currency < b.Currency
+	})
+	return report
+}
+
+// chargebackPeriod returns the period of cost that is month, nil if cost
+// has none
+func chargebackPeriod(cost *platformv1alpha1.TenantCost, month string) *platformv1alpha1.TenantCostPeriod {
+	if cost == nil {
+		return nil
+	}
+	for _, period := range []*platformv1alpha1.TenantCostPeriod{cost.Period, cost.PreviousPeriod} {
+		if period != nil && period.Month == month {
+			return period
+		}
+	}
+	return nil
+}
+
+// chargebackTotals sums billing periods
+type chargebackTotals struct {
+	cost, cpuCoreHours, memoryGiBHours, storageGiBMonths float64
+}
+
+// add adds period to the totals
+func (t *chargebackTotals) add(period *platformv1alpha1.TenantCostPeriod) {
+	for _, sum := range []struct {
+		total  *float64
+		amount string
+	}{
+		{&t.cost, period.Cost},
+		{&t.cpuCoreHours, period.CPUCoreHours},
+		{&t.memoryGiBHours, period.MemoryGiBHours},
+		{&t.storageGiBMonths, period.StorageGiBMonths},
+	} {
+		value, _ := strconv.ParseFloat(sum.amount, 64)
+		*sum.total += value
+	}
+}
+
+// usage renders the totals, money with two decimals
+func (t *chargebackTotals) usage() chargebackUsage {
+	return chargebackUsage{
+		Cost:             formatAmount(t.cost),
+		CPUCoreHours:     strconv.FormatFloat(t.cpuCoreHours, 'f', 2, 64),
+		MemoryGiBHours:   strconv.FormatFloat(t.memoryGiBHours, 'f', 2, 64),
+		StorageGiBMonths: strconv.FormatFloat(t.storageGiBMonths, 'f', 2, 64),
+	}
+}
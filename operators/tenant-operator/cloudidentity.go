@@ -0,0 +1,495 @@
+// Cloud identity
+// Spec.CloudIdentity gives each tenant namespace a ServiceAccount federated
+// with a cloud identity of the tenant's own, so its pods reach the cloud
+// resources the tenant is granted without static credentials. The identity
+// and its grants are written as resources of the provider's Kubernetes
+// controller, in --cloud-identity-namespace where tenants can't edit them:
+//
+//	aws    ACK IAM Role, trusting the cluster's OIDC provider (IRSA)
+//	gcp    Config Connector IAMServiceAccount and IAMPartialPolicies
+//	       (Workload Identity)
+//	azure  ASO UserAssignedIdentity, FederatedIdentityCredentials and
+//	       RoleAssignments (Azure Workload Identity)
+//
+// The controller is optional, so the identity is skipped when its CRDs are
+// missing.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	platformv1alpha1 "github.com/xyz-company/tenant-operator/api/v1alpha1"
+)
+
+// Cloud providers of --cloud-identity
+const (
+	CloudIdentityAWS   = "aws"
+	CloudIdentityGCP   = "gcp"
+	CloudIdentityAzure = "azure"
+)
+
+var (
+	ackRoleGVK                     = schema.GroupVersionKind{Group: "iam.services.k8s.aws", Version: "v1alpha1", Kind: "Role"}
+	iamServiceAccountGVK           = schema.GroupVersionKind{Group: "iam.cnrm.cloud.google.com", Version: "v1beta1", Kind: "IAMServiceAccount"}
+	iamPartialPolicyGVK            = schema.GroupVersionKind{Group: "iam.cnrm.cloud.google.com", Version: "v1beta1", Kind: "IAMPartialPolicy"}
+	userAssignedIdentityGVK        = schema.GroupVersionKind{Group: "managedidentity.azure.com", Version: "v1api20230131", Kind: "UserAssignedIdentity"}
+	federatedIdentityCredentialGVK = schema.GroupVersionKind{Group: "managedidentity.azure.com", Version: "v1api20230131", Kind: "FederatedIdentityCredential"}
+	roleAssignmentGVK              = schema.GroupVersionKind{Group: "authorization.azure.com", Version: "v1api20220401", Kind: "RoleAssignment"}
+)
+
+const (
+	// defaultCloudIdentityServiceAccount names the federated ServiceAccounts
+	// of tenants naming none
+	defaultCloudIdentityServiceAccount = "cloud-identity"
+	// cloudIdentityLabel marks the federated ServiceAccounts, so those of a
+	// former name are found and deleted
+	cloudIdentityLabel = "platform.xyz.com/cloud-identity"
+	// cloudIdentityRetryInterval is how often a tenant is looked at while
+	// its Azure identity's client ID isn't known yet
+	cloudIdentityRetryInterval = 30 * time.Second
+	// maxGoogleServiceAccountID is the longest Google service account ID
+	maxGoogleServiceAccountID = 30
+)
+
+// azureRoleDefinitionID matches a role definition given by GUID
+var azureRoleDefinitionID = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// CloudIdentityConfig is the platform's side of tenant cloud identities
+type CloudIdentityConfig struct {
+	// Provider is CloudIdentityAWS, CloudIdentityGCP or CloudIdentityAzure
+	Provider string
+	// Namespace holds the identity resources (--cloud-identity-namespace)
+	Namespace string
+
+	// AWSAccountID and AWSOIDCProvider, the cluster's OIDC issuer without
+	// https://, are what IAM roles trust on AWS
+	AWSAccountID    string
+	AWSOIDCProvider string
+	// GCPProject holds the Google service accounts and the cluster's
+	// workload identity pool
+	GCPProject string
+	// AzureSubscriptionID and AzureResourceGroup hold the managed
+	// identities, in AzureLocation, federated with AzureOIDCIssuer, the
+	// cluster's OIDC issuer URL
+	AzureSubscriptionID string
+	AzureResourceGroup  string
+	AzureLocation       string
+	AzureOIDCIssuer     string
+}
+
+// validate checks that the provider is known and its settings are given
+func (c *CloudIdentityConfig) validate() error {
+	var missing []string
+	require := func(flag, value string) {
+		if value == "" {
+			missing = append(missing, "--"+flag)
+		}
+	}
+	switch c.Provider {
+	case CloudIdentityAWS:
+		require("aws-account-id", c.AWSAccountID)
+		require("aws-oidc-provider", c.AWSOIDCProvider)
+	case CloudIdentityGCP:
+		require("gcp-project", c.GCPProject)
+	case CloudIdentityAzure:
+		require("azure-subscription-id", c.AzureSubscriptionID)
+		require("azure-resource-group", c.AzureResourceGroup)
+		require("azure-location", c.AzureLocation)
+		require("azure-oidc-issuer", c.AzureOIDCIssuer)
+	default:
+		return fmt.Errorf("--cloud-identity must be aws, gcp or azure, got %q", c.Provider)
+	}
+	require("cloud-identity-namespace", c.Namespace)
+	if len(missing) > 0 {
+		return fmt.Errorf("--cloud-identity=%s needs %s", c.Provider, strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// cloudIdentityName names tenant's identity and the resources making it up
+func cloudIdentityName(tenant *platformv1alpha1.Tenant) string {
+	return "tenant-" + tenant.Name
+}
+
+// cloudIdentityServiceAccount returns the name of tenant's federated
+// ServiceAccounts
+func cloudIdentityServiceAccount(identity *platformv1alpha1.TenantCloudIdentity) string {
+	if identity.ServiceAccountName == "" {
+		return defaultCloudIdentityServiceAccount
+	}
+	return identity.ServiceAccountName
+}
+
+// validateCloudIdentity rejects cloud identities the provider can't have:
+// grants without resources, allowing every resource, or of the wrong kind
+func (c *CloudIdentityConfig) validateCloudIdentity(tenant *platformv1alpha1.Tenant) error {
+	identity := tenant.Spec.CloudIdentity
+	if identity == nil {
+		return nil
+	}
+	if c == nil {
+		return fmt.Errorf("cloudIdentity can't be used: the operator runs without --cloud-identity")
+	}
+	if identity.ServiceAccountName != "" {
+		if errs := validation.IsDNS1123Subdomain(identity.ServiceAccountName); len(errs) > 0 {
+			return fmt.Errorf("cloudIdentity.serviceAccountName %q is invalid: %s", identity.ServiceAccountName, strings.Join(errs, ", "))
+		}
+		if identity.ServiceAccountName == "default" {
+			return fmt.Errorf("cloudIdentity.serviceAccountName must not be default")
+		}
+	}
+	if c.Provider == CloudIdentityGCP && len(cloudIdentityName(tenant)) > maxGoogleServiceAccountID {
+		return fmt.Errorf("cloudIdentity needs a Google service account named %s, longer than %d characters", cloudIdentityName(tenant), maxGoogleServiceAccountID)
+	}
+
+	for i, grant := range identity.Grants {
+		if len(grant.Resources) == 0 {
+			return fmt.Errorf("cloudIdentity.grants[%d] must list the resources it applies to", i)
+		}
+		for _, resource := range grant.Resources {
+			if strings.TrimSuffix(resource, "*") == "" {
+				return fmt.Errorf("cloudIdentity.grants[%d].resources %q must name resources narrower than *", i, resource)
+			}
+			if c.Provider == CloudIdentityAzure && (strings.Contains(resource, "*") || !strings.HasPrefix(resource, "/subscriptions/")) {
+				return fmt.Errorf("cloudIdentity.grants[%d].resources %q must be an Azure scope such as /subscriptions/<id>/resourceGroups/<name>", i, resource)
+			}
+		}
+		switch c.Provider {
+		case CloudIdentityAWS:
+			if len(grant.Actions) == 0 || grant.Role != "" {
+				return fmt.Errorf("cloudIdentity.grants[%d] must list actions, not a role, on AWS", i)
+			}
+			for _, action := range grant.Actions {
+				if action == "*" {
+					return fmt.Errorf("cloudIdentity.grants[%d].actions must be narrower than *", i)
+				}
+			}
+		default:
+			if grant.Role == "" || len(grant.Actions) > 0 {
+				return fmt.Errorf("cloudIdentity.grants[%d] must name a role, not actions, on %s", i, c.Provider)
+			}
+		}
+	}
+	return nil
+}
+
+// kinds returns the resource kinds the provider's identities are made of
+func (c *CloudIdentityConfig) kinds() []schema.GroupVersionKind {
+	switch c.Provider {
+	case CloudIdentityAWS:
+		return []schema.GroupVersionKind{ackRoleGVK}
+	case CloudIdentityGCP:
+		return []schema.GroupVersionKind{iamServiceAccountGVK, iamPartialPolicyGVK}
+	default:
+		return []schema.GroupVersionKind{userAssignedIdentityGVK, federatedIdentityCredentialGVK, roleAssignmentGVK}
+	}
+}
+
+// reconcileCloudIdentity applies the tenant's cloud identity, its grants
+// and the federated ServiceAccount of each of namespaces, or deletes them
+// once the tenant no longer has Spec.CloudIdentity. It returns when to look
+// again for an identity the provider hasn't created yet, 0 otherwise.
+func (r *TenantReconciler) reconcileCloudIdentity(ctx context.Context, tenant *platformv1alpha1.Tenant, namespaces []string) (time.Duration, error) {
+	c := r.CloudIdentity
+	if c == nil {
+		return 0, nil
+	}
+	log := ctrl.LoggerFrom(ctx)
+	identity := tenant.Spec.CloudIdentity
+
+	var desired []*unstructured.Unstructured
+	if identity != nil {
+		desired = c.identityObjects(tenant, namespaces)
+	}
+	for _, gvk := range c.kinds() {
+		installed, err := r.kindInstalled(gvk)
+		if err != nil {
+			return 0, err
+		}
+		if !installed {
+			if identity != nil {
+				log.Info("CRD not installed, skipping cloud identity", "kind", gvk.Kind)
+				return 0, nil
+			}
+			continue
+		}
+		stale, err := staleObjects(ctx, r.Client, tenant, []string{c.Namespace}, gvk, desired)
+		if err != nil {
+			return 0, err
+		}
+		for _, obj := range stale {
+			if err := r.deleteIfControlled(ctx, tenant, obj); err != nil {
+				return 0, err
+			}
+		}
+		for _, obj := range ofKind(desired, gvk) {
+			if err := r.applyOrAdopt(ctx, tenant, obj); err != nil {
+				return 0, err
+			}
+		}
+	}
+
+	var name, ref string
+	var annotations map[string]string
+	if identity != nil {
+		name = cloudIdentityServiceAccount(identity)
+		var err error
+		if ref, annotations, err = r.cloudIdentityRef(ctx, tenant); err != nil {
+			return 0, err
+		}
+	}
+	for _, namespace := range namespaces {
+		if err := r.reconcileCloudIdentityServiceAccount(ctx, tenant, namespace, name, annotations); err != nil {
+			return 0, err
+		}
+	}
+
+	if ref != "" && tenant.Status.CloudIdentity != ref {
+		r.Recorder.Eventf(tenant, corev1.EventTypeNormal, "CloudIdentityBound", "ServiceAccount %s is federated with %s", name, ref)
+	}
+	tenant.Status.CloudIdentity = ref
+	if identity != nil && ref == "" {
+		log.Info("Waiting for the managed identity's client ID", "configMap", c.Namespace+"/"+cloudIdentityName(tenant)+"-identity")
+		return cloudIdentityRetryInterval, nil
+	}
+	return 0, nil
+}
+
+// reconcileCloudIdentityServiceAccount applies the federated ServiceAccount
+// name of namespace with annotations, and deletes the tenant's others. An
+// empty name deletes them all.
+func (r *TenantReconciler) reconcileCloudIdentityServiceAccount(ctx context.Context, tenant *platformv1alpha1.Tenant, namespace, name string, annotations map[string]string) error {
+	accounts := &corev1.ServiceAccountList{}
+	if err := r.List(ctx, accounts, client.InNamespace(namespace), client.MatchingLabels{tenantLabel: tenant.Name, cloudIdentityLabel: "true"}); err != nil {
+		return err
+	}
+	for i := range accounts.Items {
+		if sa := &accounts.Items[i]; sa.Name != name && metav1.IsControlledBy(sa, tenant) {
+			if err := client.IgnoreNotFound(r.Delete(ctx, sa)); err != nil {
+				return err
+			}
+		}
+	}
+	if name == "" {
+		return nil
+	}
+	return r.applyOrAdopt(ctx, tenant, &corev1.ServiceAccount{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        name,
+			Namespace:   namespace,
+			Labels:      map[string]string{cloudIdentityLabel: "true"},
+			Annotations: annotations,
+		},
+	})
+}
+
+// cloudIdentityRef returns the identity the tenant's ServiceAccounts are
+// federated with and the annotations telling the provider's webhook so.
+// The client ID of an Azure identity is only known once ASO has created
+// it; until then both are empty.
+func (r *TenantReconciler) cloudIdentityRef(ctx context.Context, tenant *platformv1alpha1.Tenant) (string, map[string]string, error) {
+	c := r.CloudIdentity
+	switch c.Provider {
+	case CloudIdentityAWS:
+		arn := fmt.Sprintf("arn:aws:iam::%s:role/%s", c.AWSAccountID, cloudIdentityName(tenant))
+		return arn, map[string]string{"eks.amazonaws.com/role-arn": arn}, nil
+	case CloudIdentityGCP:
+		email := c.googleServiceAccount(tenant)
+		return email, map[string]string{"iam.gke.io/gcp-service-account": email}, nil
+	}
+
+	cm := &corev1.ConfigMap{}
+	err := r.APIReader.Get(ctx, types.NamespacedName{Namespace: c.Namespace, Name: cloudIdentityName(tenant) + "-identity"}, cm)
+	if errors.IsNotFound(err) || (err == nil && cm.Data["clientId"] == "") {
+		return "", nil, nil
+	}
+	if err != nil {
+		return "", nil, err
+	}
+	clientID := cm.Data["clientId"]
+	return clientID, map[string]string{"azure.workload.identity/client-id": clientID}, nil
+}
+
+// googleServiceAccount returns the email of tenant's Google service account
+func (c *CloudIdentityConfig) googleServiceAccount(tenant *platformv1alpha1.Tenant) string {
+	return fmt.Sprintf("%s@%s.iam.gserviceaccount.com", cloudIdentityName(tenant), c.GCPProject)
+}
+
+// identityObjects returns the provider resources making up tenant's
+// identity, federated with its ServiceAccount in namespaces
+func (c *CloudIdentityConfig) identityObjects(tenant *platformv1alpha1.Tenant, namespaces []string) []*unstructured.Unstructured {
+	identity := tenant.Spec.CloudIdentity
+	subjects := make([]string, 0, len(namespaces))
+	for _, namespace := range namespaces {
+		subjects = append(subjects, fmt.Sprintf("system:serviceaccount:%s:%s", namespace, cloudIdentityServiceAccount(identity)))
+	}
+
+	var objects []*unstructured.Unstructured
+	object := func(gvk schema.GroupVersionKind, name string, spec map[string]interface{}) *unstructured.Unstructured {
+		obj := &unstructured.Unstructured{Object: map[string]interface{}{"spec": spec}}
+		obj.SetGroupVersionKind(gvk)
+		obj.SetNamespace(c.Namespace)
+		obj.SetName(name)
+		objects = append(objects, obj)
+		return obj
+	}
+	name := cloudIdentityName(tenant)
+
+	switch c.Provider {
+	case CloudIdentityAWS:
+		spec := map[string]interface{}{
+			"name":                     name,
+			"description":              fmt.Sprintf("Workloads of tenant %s", tenant.Name),
+			"assumeRolePolicyDocument": c.awsTrustPolicy(subjects),
+		}
+		if len(identity.Grants) > 0 {
+			spec["inlinePolicies"] = map[string]interface{}{"tenant": awsGrantPolicy(identity.Grants)}
+		}
+		object(ackRoleGVK, name, spec)
+
+	case CloudIdentityGCP:
+		account := object(iamServiceAccountGVK, name, map[string]interface{}{
+			"displayName": fmt.Sprintf("Workloads of tenant %s", tenant.Name),
+		})
+		account.SetAnnotations(map[string]string{"cnrm.cloud.google.com/project-id": c.GCPProject})
+
+		members := make([]interface{}, 0, len(namespaces))
+		for _, namespace := range namespaces {
+			members = append(members, map[string]interface{}{
+				"member": fmt.Sprintf("serviceAccount:%s.svc.id.goog[%s/%s]", c.GCPProject, namespace, cloudIdentityServiceAccount(identity)),
+			})
+		}
+		object(iamPartialPolicyGVK, name+"-workload-identity", map[string]interface{}{
+			"resourceRef": map[string]interface{}{"kind": iamServiceAccountGVK.Kind, "name": name},
+			"bindings": []interface{}{
+				map[string]interface{}{"role": "roles/iam.workloadIdentityUser", "members": members},
+			},
+		})
+
+		if len(identity.Grants) > 0 {
+			bindings := make([]interface{}, 0, len(identity.Grants))
+			for i, grant := range identity.Grants {
+				bindings = append(bindings, map[string]interface{}{
+					"role": grant.Role,
+					"members": []interface{}{
+						map[string]interface{}{"memberFrom": map[string]interface{}{"serviceAccountRef": map[string]interface{}{"name": name}}},
+					},
+					"condition": map[string]interface{}{
+						"title":      fmt.Sprintf("%s-%d", name, i),
+						"expression": gcpResourceCondition(grant.Resources),
+					},
+				})
+			}
+			object(iamPartialPolicyGVK, name+"-grants", map[string]interface{}{
+				"resourceRef": map[string]interface{}{"kind": "Project", "external": "projects/" + c.GCPProject},
+				"bindings":    bindings,
+			})
+		}
+
+	case CloudIdentityAzure:
+		configMap := name + "-identity"
+		object(userAssignedIdentityGVK, name, map[string]interface{}{
+			"location": c.AzureLocation,
+			"owner":    map[string]interface{}{"armId": fmt.Sprintf("/subscriptions/%s/resourceGroups/%s", c.AzureSubscriptionID, c.AzureResourceGroup)},
+			"operatorSpec": map[string]interface{}{
+				"configMaps": map[string]interface{}{
+					"clientId":    map[string]interface{}{"name": configMap, "key": "clientId"},
+					"principalId": map[string]interface{}{"name": configMap, "key": "principalId"},
+				},
+			},
+		})
+		for i, namespace := range namespaces {
+			object(federatedIdentityCredentialGVK, name+"-"+namespace, map[string]interface{}{
+				"owner":     map[string]interface{}{"name": name},
+				"audiences": []interface{}{"api://AzureADTokenExchange"},
+				"issuer":    c.AzureOIDCIssuer,
+				"subject":   subjects[i],
+			})
+		}
+		n := 0
+		for _, grant := range identity.Grants {
+			role := grant.Role
+			if azureRoleDefinitionID.MatchString(role) {
+				role = fmt.Sprintf("/subscriptions/%s/providers/Microsoft.Authorization/roleDefinitions/%s", c.AzureSubscriptionID, role)
+			}
+			for _, scope := range grant.Resources {
+				object(roleAssignmentGVK, fmt.Sprintf("%s-%d", name, n), map[string]interface{}{
+					"owner":                   map[string]interface{}{"armId": scope},
+					"principalIdFromConfig":   map[string]interface{}{"name": configMap, "key": "principalId"},
+					"roleDefinitionReference": map[string]interface{}{"armId": role},
+				})
+				n++
+			}
+		}
+	}
+	return objects
+}
+
+// awsTrustPolicy returns the trust policy letting subjects, ServiceAccounts
+// of the cluster, assume the role through its OIDC provider
+func (c *CloudIdentityConfig) awsTrustPolicy(subjects []string) string {
+	return awsPolicyDocument(map[string]interface{}{
+		"Effect":    "Allow",
+		"Principal": map[string]interface{}{"Federated": fmt.Sprintf("arn:aws:iam::%s:oidc-provider/%s", c.AWSAccountID, c.AWSOIDCProvider)},
+		"Action":    "sts:AssumeRoleWithWebIdentity",
+		"Condition": map[string]interface{}{
+			"StringEquals": map[string]interface{}{
+				c.AWSOIDCProvider + ":aud": "sts.amazonaws.com",
+				c.AWSOIDCProvider + ":sub": subjects,
+			},
+		},
+	})
+}
+
+// awsGrantPolicy returns the permissions policy allowing each grant's
+// actions on its resources
+func awsGrantPolicy(grants []platformv1alpha1.TenantCloudIdentityGrant) string {
+	statements := make([]interface{}, 0, len(grants))
+	for _, grant := range grants {
+		statements = append(statements, map[string]interface{}{
+			"Effect":   "Allow",
+			"Action":   grant.Actions,
+			"Resource": grant.Resources,
+		})
+	}
+	return awsPolicyDocument(statements...)
+}
+
+// awsPolicyDocument renders an IAM policy of statements
+func awsPolicyDocument(statements ...interface{}) string {
+	document, _ := json.Marshal(map[string]interface{}{
+		"Version":   "2012-10-17",
+		"Statement": statements,
+	})
+	return string(document)
+}
+
+// gcpResourceCondition returns the IAM condition limiting a binding to
+// resources, matching a prefix for those ending in *
+func gcpResourceCondition(resources []string) string {
+	matches := make([]string, 0, len(resources))
+	for _, resource := range resources {
+		if prefix, ok := strings.CutSuffix(resource, "*"); ok {
+			matches = append(matches, fmt.Sprintf("resource.name.startsWith(%q)", prefix))
+		} else {
+			matches = append(matches, fmt.Sprintf("resource.name == %q", resource))
+		}
+	}
+	return strings.Join(matches, " || ")
+}
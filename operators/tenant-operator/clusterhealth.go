@@ -0,0 +1,181 @@
+// Cluster health
+// With --multi-cluster, every registered Cluster is probed each
+// --cluster-probe-interval: its kubeconfig Secret must hold a usable
+// kubeconfig and its API server must answer a version request in time. The
+// outcome is recorded in the Cluster's Ready condition, with the version
+// and latency, and exported as metrics. Tenants placed on a Cluster that
+// isn't Ready skip it until it recovers, rather than waiting out its
+// timeouts on every reconcile.
+
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+
+	platformv1alpha1 "github.com/xyz-company/tenant-operator/api/v1alpha1"
+)
+
+// Cluster Ready condition reasons
+const (
+	ReasonReachable         = "Reachable"
+	ReasonUnreachable       = "Unreachable"
+	ReasonInvalidKubeconfig = "InvalidKubeconfig"
+)
+
+var (
+	// clusterHealthy is 1 while a registered Cluster is Ready
+	clusterHealthy = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "tenant_operator_cluster_healthy",
+		Help: "Whether the registered Cluster answered its last probe (1) or not (0).",
+	}, []string{"cluster", "provider", "region"})
+	// clusterProbeLatency is how long the last probe of a Cluster took
+	clusterProbeLatency = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "tenant_operator_cluster_probe_latency_seconds",
+		Help: "Duration of the last API server probe of the registered Cluster.",
+	}, []string{"cluster"})
+)
+
+// ClusterReconciler probes registered Clusters and records their health
+type ClusterReconciler struct {
+	client.Client
+	Recorder record.EventRecorder
+	// Targets reads the kubeconfig Secrets
+	Targets *ClusterTargets
+	// ProbeInterval is how often each Cluster is probed
+	// (--cluster-probe-interval)
+	ProbeInterval time.Duration
+}
+
+// Reconcile probes one Cluster and updates its status
+func (r *ClusterReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := ctrl.LoggerFrom(ctx)
+
+	cluster := &platformv1alpha1.Cluster{}
+	if err := r.Get(ctx, req.NamespacedName, cluster); err != nil {
+		if errors.IsNotFound(err) {
+			clusterHealthy.DeletePartialMatch(prometheus.Labels{"cluster": req.Name})
+			clusterProbeLatency.DeleteLabelValues(req.Name)
+		}
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+
+	ready, version, latency := r.probe(ctx, cluster)
+	ready.ObservedGeneration = cluster.Generation
+
+	previous := meta.FindStatusCondition(cluster.Status.Conditions, ConditionReady)
+	if previous == nil || previous.Status != ready.Status {
+		if ready.Status == metav1.ConditionTrue {
+			r.Recorder.Eventf(cluster, corev1.EventTypeNormal, "ClusterReachable", "Cluster reachable, Kubernetes %s", version)
+		} else {
+			r.Recorder.Event(cluster, corev1.EventTypeWarning, "ClusterUnreachable", ready.Message)
+		}
+	}
+
+	healthy := 0.0
+	if ready.Status == metav1.ConditionTrue {
+		healthy = 1
+	}
+	clusterHealthy.DeletePartialMatch(prometheus.Labels{"cluster": cluster.Name})
+	clusterHealthy.WithLabelValues(cluster.Name, cluster.Spec.Provider, cluster.Spec.Region).Set(healthy)
+	clusterProbeLatency.WithLabelValues(cluster.Name).Set(latency.Seconds())
+
+	patch := client.MergeFrom(cluster.DeepCopy())
+	meta.SetStatusCondition(&cluster.Status.Conditions, ready)
+	if version != "" {
+		cluster.Status.KubernetesVersion = version
+	}
+	cluster.Status.LatencyMilliseconds = latency.Milliseconds()
+	cluster.Status.LastProbeTime = metav1.Now()
+	if err := r.Status().Patch(ctx, cluster, patch); err != nil {
+		log.Error(err, "Failed to update Cluster status")
+		return ctrl.Result{}, err
+	}
+	log.V(1).Info("Cluster probed", "ready", ready.Status, "latency", latency)
+
+	return ctrl.Result{RequeueAfter: r.ProbeInterval}, nil
+}
+
+// probe builds a client from cluster's kubeconfig and asks its API server
+// for its version. It returns the resulting Ready condition, the version
+// and how long the API server took to answer.
+func (r *ClusterReconciler) probe(ctx context.Context, cluster *platformv1alpha1.Cluster) (metav1.Condition, string, time.Duration) {
+	ready := metav1.Condition{Type: ConditionReady, Status: metav1.ConditionFalse, Reason: ReasonInvalidKubeconfig}
+
+	secret, key, err := r.Targets.kubeconfigSecret(ctx, cluster)
+	if err != nil {
+		ready.Message = err.Error()
+		return ready, "", 0
+	}
+	config, err := clusterRESTConfig(secret.Data[key], key)
+	if err != nil {
+		ready.Message = err.Error()
+		return ready, "", 0
+	}
+	discoveryClient, err := discovery.NewDiscoveryClientForConfig(config)
+	if err != nil {
+		ready.Message = err.Error()
+		return ready, "", 0
+	}
+
+	start := time.Now()
+	info, err := discoveryClient.ServerVersion()
+	latency := time.Since(start)
+	if err != nil {
+		ready.Reason = ReasonUnreachable
+		ready.Message = err.Error()
+		return ready, "", latency
+	}
+	ready.Status = metav1.ConditionTrue
+	ready.Reason = ReasonReachable
+	ready.Message = "API server answered in " + latency.Round(time.Millisecond).String()
+	return ready, info.GitVersion, latency
+}
+
+// clusterReady reports whether cluster's Ready condition is True; Clusters
+// not probed yet count as ready
+func clusterReady(cluster *platformv1alpha1.Cluster) bool {
+	ready := meta.FindStatusCondition(cluster.Status.Conditions, ConditionReady)
+	return ready == nil || ready.Status != metav1.ConditionFalse
+}
+
+// clusterPlacementChanged passes Cluster updates that change what tenant
+// placements see: its spec, labels or readiness. The status written by
+// every probe passes only when readiness flips.
+func clusterPlacementChanged() predicate.Predicate {
+	return predicate.Funcs{
+		UpdateFunc: func(e event.UpdateEvent) bool {
+			oldCluster, okOld := e.ObjectOld.(*platformv1alpha1.Cluster)
+			newCluster, okNew := e.ObjectNew.(*platformv1alpha1.Cluster)
+			if !okOld || !okNew {
+				return true
+			}
+			return oldCluster.Generation != newCluster.Generation ||
+				!equality.Semantic.DeepEqual(oldCluster.Labels, newCluster.Labels) ||
+				clusterReady(oldCluster) != clusterReady(newCluster)
+		},
+	}
+}
+
+// SetupWithManager sets up the controller with the Manager. Status updates
+// don't trigger a probe; the next one is scheduled by the last.
+func (r *ClusterReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&platformv1alpha1.Cluster{}, builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		Named("cluster").
+		Complete(r)
+}
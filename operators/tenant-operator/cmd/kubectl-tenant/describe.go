@@ -0,0 +1,183 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	platformv1alpha1 "github.com/xyz-company/tenant-operator/api/v1alpha1"
+)
+
+// tenantQuotaName is the ResourceQuota the operator creates in every tenant
+// namespace
+const tenantQuotaName = "tenant-quota"
+
+// describe prints a Tenant's spec summary, contacts, the health of its
+// namespaces and child resources, and its quota usage
+func describe(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("describe", flag.ContinueOnError)
+	name, err := parseNamed(fs, args)
+	if err != nil {
+		return err
+	}
+	c, err := newClient()
+	if err != nil {
+		return err
+	}
+	tenant := &platformv1alpha1.Tenant{}
+	if err := c.Get(ctx, client.ObjectKey{Name: name}, tenant); err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "Name:\t%s\n", tenant.Name)
+	fmt.Fprintf(w, "Owner:\t%s\n", tenant.Spec.Owner)
+	fmt.Fprintf(w, "Cost center:\t%s\n", orNone(tenant.Spec.CostCenter))
+	fmt.Fprintf(w, "Profile:\t%s\n", orNone(tenant.Spec.Profile))
+	fmt.Fprintf(w, "Parent:\t%s\n", orNone(tenant.Spec.Parent))
+	fmt.Fprintf(w, "State:\t%s\n", state(tenant))
+	fmt.Fprintf(w, "Phase:\t%s\n", orNone(tenant.Status.Phase))
+	if tenant.Status.Message != "" {
+		fmt.Fprintf(w, "Message:\t%s\n", tenant.Status.Message)
+	}
+	if expiry, ok := expiresAt(tenant); ok {
+		fmt.Fprintf(w, "Expires:\t%s (in %s)\n", expiry.UTC().Format(time.RFC3339), time.Until(expiry).Round(time.Minute))
+	}
+	fmt.Fprintf(w, "Age:\t%s\n", age(tenant.CreationTimestamp))
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	fmt.Println("\nContacts:")
+	if len(tenant.Spec.Contacts) == 0 {
+		fmt.Println("  <none>")
+	} else {
+		keys := make([]string, 0, len(tenant.Spec.Contacts))
+		for key := range tenant.Spec.Contacts {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		w = tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		for _, key := range keys {
+			fmt.Fprintf(w, "  %s\t%s\n", key, tenant.Spec.Contacts[key])
+		}
+		if err := w.Flush(); err != nil {
+			return err
+		}
+	}
+
+	fmt.Println("\nConditions:")
+	w = tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "  TYPE\tSTATUS\tREASON\tAGE\tMESSAGE")
+	for _, condition := range tenant.Status.Conditions {
+		fmt.Fprintf(w, "  %s\t%s\t%s\t%s\t%s\n", condition.Type, condition.Status, condition.Reason,
+			age(condition.LastTransitionTime), condition.Message)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	fmt.Println("\nNamespaces:")
+	w = tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "  NAME\tREADY\tADOPTED\tMESSAGE")
+	for _, ns := range tenant.Status.Namespaces {
+		fmt.Fprintf(w, "  %s\t%t\t%t\t%s\n", ns.Name, ns.Ready, ns.Adopted, orNone(ns.Message))
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	if len(tenant.Status.Clusters) > 0 {
+		fmt.Println("\nClusters:")
+		w = tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "  NAME\tREADY\tREASON\tMESSAGE")
+		for _, cluster := range tenant.Status.Clusters {
+			fmt.Fprintf(w, "  %s\t%t\t%s\t%s\n", cluster.Name, cluster.Ready, orNone(cluster.Reason), orNone(cluster.Message))
+		}
+		if err := w.Flush(); err != nil {
+			return err
+		}
+	}
+
+	fmt.Println("\nQuota:")
+	return printQuota(ctx, c, os.Stdout, tenant, "  ")
+}
+
+// quota prints the usage of a Tenant's ResourceQuotas
+func quota(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("quota", flag.ContinueOnError)
+	name, err := parseNamed(fs, args)
+	if err != nil {
+		return err
+	}
+	c, err := newClient()
+	if err != nil {
+		return err
+	}
+	tenant := &platformv1alpha1.Tenant{}
+	if err := c.Get(ctx, client.ObjectKey{Name: name}, tenant); err != nil {
+		return err
+	}
+	return printQuota(ctx, c, os.Stdout, tenant, "")
+}
+
+// printQuota writes a table of used against hard limits for every resource
+// of tenant's ResourceQuotas, one row per namespace and resource
+func printQuota(ctx context.Context, c client.Client, out io.Writer, tenant *platformv1alpha1.Tenant, indent string) error {
+	w := tabwriter.NewWriter(out, 0, 0, 3, ' ', 0)
+	fmt.Fprintf(w, "%sNAMESPACE\tRESOURCE\tUSED\tHARD\tUSE\n", indent)
+	for _, namespace := range statusNamespaces(tenant) {
+		rq := &corev1.ResourceQuota{}
+		if err := c.Get(ctx, client.ObjectKey{Namespace: namespace, Name: tenantQuotaName}, rq); err != nil {
+			if errors.IsNotFound(err) {
+				fmt.Fprintf(w, "%s%s\t<no quota yet>\t\t\t\n", indent, namespace)
+				continue
+			}
+			return err
+		}
+		resources := make([]string, 0, len(rq.Status.Hard))
+		for name := range rq.Status.Hard {
+			resources = append(resources, string(name))
+		}
+		sort.Strings(resources)
+		for _, name := range resources {
+			hard := rq.Status.Hard[corev1.ResourceName(name)]
+			used := rq.Status.Used[corev1.ResourceName(name)]
+			fmt.Fprintf(w, "%s%s\t%s\t%s\t%s\t%s\n", indent, namespace, name, used.String(), hard.String(), percent(used, hard))
+		}
+	}
+	return w.Flush()
+}
+
+// statusNamespaces returns the namespaces the operator last reported for
+// tenant, or the one named after it before its first reconcile
+func statusNamespaces(tenant *platformv1alpha1.Tenant) []string {
+	if len(tenant.Status.Namespaces) == 0 {
+		return []string{tenant.Name}
+	}
+	namespaces := make([]string, 0, len(tenant.Status.Namespaces))
+	for _, ns := range tenant.Status.Namespaces {
+		namespaces = append(namespaces, ns.Name)
+	}
+	return namespaces
+}
+
+// expiresAt returns when tenant expires, from spec.expiresAt or spec.ttl
+func expiresAt(tenant *platformv1alpha1.Tenant) (time.Time, bool) {
+	switch {
+	case tenant.Spec.ExpiresAt != nil:
+		return tenant.Spec.ExpiresAt.Time, true
+	case tenant.Spec.TTL != nil:
+		return tenant.CreationTimestamp.Add(tenant.Spec.TTL.Duration), true
+	}
+	return time.Time{}, false
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	platformv1alpha1 "github.com/xyz-company/tenant-operator/api/v1alpha1"
+)
+
+// history prints the changes recorded in a Tenant's TenantAudit, oldest
+// first, one row per changed field
+func history(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("history", flag.ContinueOnError)
+	field := fs.String("field", "", "Only show changes to this field and the fields below it, such as spec.quota.")
+	name, err := parseNamed(fs, args)
+	if err != nil {
+		return err
+	}
+	c, err := newClient()
+	if err != nil {
+		return err
+	}
+	audit := &platformv1alpha1.TenantAudit{}
+	if err := c.Get(ctx, client.ObjectKey{Name: name}, audit); err != nil {
+		if errors.IsNotFound(err) {
+			return fmt.Errorf("no changes recorded for tenant %q", name)
+		}
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(w, "TIME\tUSER\tOPERATION\tFIELD\tOLD\tNEW")
+	for _, entry := range audit.Entries {
+		when := entry.Time.UTC().Format(time.RFC3339)
+		if len(entry.Changes) == 0 {
+			if *field == "" {
+				fmt.Fprintf(w, "%s\t%s\t%s\t-\t-\t-\n", when, entry.User, entry.Operation)
+			}
+			continue
+		}
+		for _, change := range entry.Changes {
+			if *field != "" && change.Path != *field && !strings.HasPrefix(change.Path, *field+".") {
+				continue
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", when, entry.User, entry.Operation,
+				change.Path, orNone(change.Old), orNone(change.New))
+		}
+	}
+	return w.Flush()
+}
@@ -0,0 +1,331 @@
+// kubectl-tenant is a kubectl plugin for the Tenant lifecycle. Installed on
+// the PATH it runs as `kubectl tenant <command>`:
+//
+//	create NAME --owner TEAM   create a Tenant
+//	list                       list Tenants
+//	describe NAME              show a Tenant's status, namespaces and quota
+//	quota NAME                 show a Tenant's quota usage
+//	history NAME               show who changed a Tenant and how
+//	suspend NAME               suspend a Tenant
+//	resume NAME                make a suspended Tenant active again
+//	request NAME --owner TEAM  ask for a Tenant with a TenantRequest
+//	requests                   list TenantRequests
+//	approve NAME, reject NAME  decide on a pending TenantRequest
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/duration"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	platformv1alpha1 "github.com/xyz-company/tenant-operator/api/v1alpha1"
+)
+
+const usage = `Usage: kubectl tenant [--kubeconfig FILE] <command> [flags]
+
+Commands:
+  create NAME --owner TEAM   Create a Tenant
+  list                       List Tenants
+  describe NAME              Show a Tenant's status, namespaces and quota
+  quota NAME                 Show a Tenant's quota usage per namespace
+  history NAME               Show who changed a Tenant, when and how
+  suspend NAME               Scale a Tenant's workloads to zero
+  resume NAME                Make a suspended Tenant active again
+  request NAME --owner TEAM  Ask for a Tenant, for a platform admin to approve
+  requests                   List TenantRequests and their review
+  approve NAME               Approve a pending TenantRequest, creating its Tenant
+  reject NAME                Reject a pending TenantRequest
+
+Run "kubectl tenant <command> --help" for the flags of a command.
+`
+
+// requestTimeout bounds a single command's API requests
+const requestTimeout = 30 * time.Second
+
+// command runs one subcommand with its arguments
+type command func(ctx context.Context, args []string) error
+
+var commands = map[string]command{
+	"create":   create,
+	"list":     list,
+	"describe": describe,
+	"quota":    quota,
+	"history":  history,
+	"suspend":  suspend,
+	"resume":   resume,
+	"request":  request,
+	"requests": requests,
+	"approve":  approve,
+	"reject":   reject,
+}
+
+func main() {
+	// --kubeconfig is registered by controller-runtime; KUBECONFIG and the
+	// in-cluster config work too
+	flag.Usage = func() { fmt.Fprint(os.Stderr, usage) }
+	flag.Parse()
+	run, ok := commands[flag.Arg(0)]
+	if !ok {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+	err := run(ctx, flag.Args()[1:])
+	cancel()
+	switch {
+	case errors.Is(err, flag.ErrHelp):
+		// The command's flags were printed already
+	case err != nil:
+		fmt.Fprintln(os.Stderr, "error:", err)
+		os.Exit(1)
+	}
+}
+
+// newClient returns a client for the current kubeconfig context
+func newClient() (client.Client, error) {
+	config, err := ctrl.GetConfig()
+	if err != nil {
+		return nil, err
+	}
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		return nil, err
+	}
+	if err := platformv1alpha1.AddToScheme(scheme); err != nil {
+		return nil, err
+	}
+	return client.New(config, client.Options{Scheme: scheme})
+}
+
+// parseNamed parses a command's flags around its single NAME argument, so
+// both `create foo --owner bar` and `create --owner bar foo` work
+func parseNamed(fs *flag.FlagSet, args []string) (string, error) {
+	var name string
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		name, args = args[0], args[1:]
+	}
+	if err := fs.Parse(args); err != nil {
+		return "", err
+	}
+	if name == "" && fs.NArg() > 0 {
+		name = fs.Arg(0)
+	}
+	if name == "" {
+		fs.Usage()
+		return "", fmt.Errorf("%s requires a Tenant name", fs.Name())
+	}
+	return name, nil
+}
+
+// contactsFlag collects repeated --contact KEY=VALUE flags
+type contactsFlag map[string]string
+
+func (f contactsFlag) String() string { return "" }
+
+func (f contactsFlag) Set(value string) error {
+	key, contact, ok := strings.Cut(value, "=")
+	if !ok || key == "" || contact == "" {
+		return fmt.Errorf("want KEY=VALUE, got %q", value)
+	}
+	f[key] = contact
+	return nil
+}
+
+// specFlags are the flags create and request fill a Tenant spec from
+type specFlags struct {
+	owner, costCenter, profile, parent, namespaces, cpu, memory *string
+	ttl                                                         *time.Duration
+	contacts                                                    contactsFlag
+}
+
+// addSpecFlags registers the spec flags on fs
+func addSpecFlags(fs *flag.FlagSet) *specFlags {
+	f := &specFlags{
+		owner:      fs.String("owner", "", "Team owning the Tenant (required)."),
+		costCenter: fs.String("cost-center", "", "Cost center the Tenant is charged to."),
+		profile:    fs.String("profile", "", "TenantProfile to start from."),
+		parent:     fs.String("parent", "", "Parent Tenant."),
+		namespaces: fs.String("namespaces", "", "Comma-separated namespace suffixes, each giving a <name>-<suffix> namespace."),
+		cpu:        fs.String("cpu", "", "CPU quota, such as 4."),
+		memory:     fs.String("memory", "", "Memory quota, such as 8Gi."),
+		ttl:        fs.Duration("ttl", 0, "Delete the Tenant this long after its creation, for sandboxes."),
+		contacts:   contactsFlag{},
+	}
+	fs.Var(f.contacts, "contact", "Contact as KEY=VALUE, such as email=team@xyz.com or slack=#team. Repeatable.")
+	return f
+}
+
+// spec returns the Tenant spec the flags describe
+func (f *specFlags) spec() (platformv1alpha1.TenantSpec, error) {
+	if *f.owner == "" {
+		return platformv1alpha1.TenantSpec{}, fmt.Errorf("--owner is required")
+	}
+	spec := platformv1alpha1.TenantSpec{
+		Owner:      *f.owner,
+		CostCenter: *f.costCenter,
+		Profile:    *f.profile,
+		Parent:     *f.parent,
+		Quota:      platformv1alpha1.TenantQuota{CPU: *f.cpu, Memory: *f.memory},
+	}
+	if len(f.contacts) > 0 {
+		spec.Contacts = f.contacts
+	}
+	if *f.namespaces != "" {
+		spec.Namespaces = strings.Split(*f.namespaces, ",")
+	}
+	if *f.ttl > 0 {
+		spec.TTL = &metav1.Duration{Duration: *f.ttl}
+	}
+	return spec, nil
+}
+
+// create creates a Tenant from flags. The webhooks default and validate it
+// like any other, so --dry-run shows whether it would be admitted.
+func create(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("create", flag.ContinueOnError)
+	flags := addSpecFlags(fs)
+	dryRun := fs.Bool("dry-run", false, "Only check that the Tenant would be admitted.")
+	name, err := parseNamed(fs, args)
+	if err != nil {
+		return err
+	}
+	c, err := newClient()
+	if err != nil {
+		return err
+	}
+	spec, err := flags.spec()
+	if err != nil {
+		return err
+	}
+	tenant := &platformv1alpha1.Tenant{ObjectMeta: metav1.ObjectMeta{Name: name}, Spec: spec}
+
+	var opts []client.CreateOption
+	if *dryRun {
+		opts = append(opts, client.DryRunAll)
+	}
+	if err := c.Create(ctx, tenant, opts...); err != nil {
+		return err
+	}
+	if *dryRun {
+		fmt.Printf("tenant/%s would be created\n", name)
+	} else {
+		fmt.Printf("tenant/%s created\n", name)
+	}
+	return nil
+}
+
+// list prints a table of Tenants, optionally only those of one owner
+func list(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("list", flag.ContinueOnError)
+	owner := fs.String("owner", "", "Only list the Tenants of this owner.")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	c, err := newClient()
+	if err != nil {
+		return err
+	}
+
+	tenants := &platformv1alpha1.TenantList{}
+	if err := c.List(ctx, tenants); err != nil {
+		return err
+	}
+	sort.Slice(tenants.Items, func(i, j int) bool { return tenants.Items[i].Name < tenants.Items[j].Name })
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(w, "NAME\tOWNER\tPROFILE\tPARENT\tSTATE\tPHASE\tNAMESPACES\tAGE")
+	for _, tenant := range tenants.Items {
+		if *owner != "" && tenant.Spec.Owner != *owner {
+			continue
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%d\t%s\n", tenant.Name, tenant.Spec.Owner,
+			orNone(tenant.Spec.Profile), orNone(tenant.Spec.Parent), state(&tenant), orNone(tenant.Status.Phase),
+			len(tenant.Status.Namespaces), age(tenant.CreationTimestamp))
+	}
+	return w.Flush()
+}
+
+// suspend sets the named Tenant Suspended
+func suspend(ctx context.Context, args []string) error {
+	return setState(ctx, "suspend", args, platformv1alpha1.TenantStateSuspended)
+}
+
+// resume sets the named Tenant Active
+func resume(ctx context.Context, args []string) error {
+	return setState(ctx, "resume", args, platformv1alpha1.TenantStateActive)
+}
+
+// setState patches spec.state of the named Tenant
+func setState(ctx context.Context, verb string, args []string, want platformv1alpha1.TenantState) error {
+	fs := flag.NewFlagSet(verb, flag.ContinueOnError)
+	name, err := parseNamed(fs, args)
+	if err != nil {
+		return err
+	}
+	c, err := newClient()
+	if err != nil {
+		return err
+	}
+	tenant := &platformv1alpha1.Tenant{}
+	if err := c.Get(ctx, client.ObjectKey{Name: name}, tenant); err != nil {
+		return err
+	}
+	if state(tenant) == want {
+		fmt.Printf("tenant/%s is already %s\n", name, want)
+		return nil
+	}
+	patch := client.MergeFrom(tenant.DeepCopy())
+	tenant.Spec.State = want
+	if err := c.Patch(ctx, tenant, patch); err != nil {
+		return err
+	}
+	fmt.Printf("tenant/%s is now %s\n", name, want)
+	return nil
+}
+
+// state returns tenant's state, Active when unset
+func state(tenant *platformv1alpha1.Tenant) platformv1alpha1.TenantState {
+	if tenant.Spec.State == "" {
+		return platformv1alpha1.TenantStateActive
+	}
+	return tenant.Spec.State
+}
+
+// orNone renders empty values as "-"
+func orNone(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+// age renders how long ago t was, like kubectl get does
+func age(t metav1.Time) string {
+	if t.IsZero() {
+		return "<unknown>"
+	}
+	return duration.HumanDuration(time.Since(t.Time))
+}
+
+// percent renders used as a share of hard
+func percent(used, hard resource.Quantity) string {
+	if hard.IsZero() {
+		return "-"
+	}
+	return fmt.Sprintf("%d%%", used.MilliValue()*100/hard.MilliValue())
+}
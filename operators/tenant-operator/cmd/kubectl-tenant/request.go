@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"text/tabwriter"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	platformv1alpha1 "github.com/xyz-company/tenant-operator/api/v1alpha1"
+)
+
+// request submits a TenantRequest from the same flags as create, for a
+// platform admin to review
+func request(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("request", flag.ContinueOnError)
+	flags := addSpecFlags(fs)
+	reason := fs.String("reason", "", "What the Tenant is for, for the reviewers.")
+	name, err := parseNamed(fs, args)
+	if err != nil {
+		return err
+	}
+	c, err := newClient()
+	if err != nil {
+		return err
+	}
+	spec, err := flags.spec()
+	if err != nil {
+		return err
+	}
+
+	tenantRequest := &platformv1alpha1.TenantRequest{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Spec: platformv1alpha1.TenantRequestSpec{
+			Owner:      spec.Owner,
+			CostCenter: spec.CostCenter,
+			Profile:    spec.Profile,
+			Parent:     spec.Parent,
+			Namespaces: spec.Namespaces,
+			Contacts:   spec.Contacts,
+			Quota:      spec.Quota,
+			TTL:        spec.TTL,
+			Reason:     *reason,
+		},
+	}
+	if err := c.Create(ctx, tenantRequest); err != nil {
+		return err
+	}
+	fmt.Printf("tenantrequest/%s submitted; `kubectl tenant requests` shows its review\n", name)
+	return nil
+}
+
+// requests prints a table of TenantRequests, optionally only those in one
+// phase
+func requests(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("requests", flag.ContinueOnError)
+	phase := fs.String("phase", "", "Only list the requests in this phase: Pending, Approved or Rejected.")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	c, err := newClient()
+	if err != nil {
+		return err
+	}
+
+	list := &platformv1alpha1.TenantRequestList{}
+	if err := c.List(ctx, list); err != nil {
+		return err
+	}
+	sort.Slice(list.Items, func(i, j int) bool { return list.Items[i].Name < list.Items[j].Name })
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(w, "NAME\tOWNER\tREQUESTED BY\tPHASE\tREVIEWED BY\tTENANT\tAGE\tREASON")
+	for _, item := range list.Items {
+		if *phase != "" && string(item.Status.Phase) != *phase {
+			continue
+		}
+		reviewedBy := item.Status.ReviewedBy
+		if item.Status.AutoApproved {
+			reviewedBy = "<auto-approved>"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n", item.Name, item.Spec.Owner, orNone(item.Spec.RequestedBy),
+			orNone(string(item.Status.Phase)), orNone(reviewedBy), orNone(item.Status.Tenant), age(item.CreationTimestamp),
+			orNone(item.Spec.Reason))
+	}
+	return w.Flush()
+}
+
+// approve approves the named TenantRequest, creating its Tenant
+func approve(ctx context.Context, args []string) error {
+	return review(ctx, "approve", args, platformv1alpha1.TenantRequestApproved)
+}
+
+// reject rejects the named TenantRequest
+func reject(ctx context.Context, args []string) error {
+	return review(ctx, "reject", args, platformv1alpha1.TenantRequestRejected)
+}
+
+// review sets the phase of the named Pending TenantRequest through its
+// status subresource, with an optional comment
+func review(ctx context.Context, verb string, args []string, decision platformv1alpha1.TenantRequestPhase) error {
+	fs := flag.NewFlagSet(verb, flag.ContinueOnError)
+	comment := fs.String("comment", "", "Note for the requester, such as why the request was rejected.")
+	name, err := parseNamed(fs, args)
+	if err != nil {
+		return err
+	}
+	c, err := newClient()
+	if err != nil {
+		return err
+	}
+	tenantRequest := &platformv1alpha1.TenantRequest{}
+	if err := c.Get(ctx, client.ObjectKey{Name: name}, tenantRequest); err != nil {
+		return err
+	}
+	if phase := tenantRequest.Status.Phase; phase != platformv1alpha1.TenantRequestPending {
+		return fmt.Errorf("tenantrequest/%s is %s, not Pending", name, orNone(string(phase)))
+	}
+
+	// Locked, so a concurrent review fails rather than being overwritten
+	patch := client.MergeFromWithOptions(tenantRequest.DeepCopy(), client.MergeFromWithOptimisticLock{})
+	tenantRequest.Status.Phase = decision
+	tenantRequest.Status.Comment = *comment
+	if err := c.Status().Patch(ctx, tenantRequest, patch); err != nil {
+		return err
+	}
+	if decision == platformv1alpha1.TenantRequestApproved {
+		fmt.Printf("tenantrequest/%s approved; the operator creates tenant/%s\n", name, name)
+	} else {
+		fmt.Printf("tenantrequest/%s rejected\n", name)
+	}
+	return nil
+}
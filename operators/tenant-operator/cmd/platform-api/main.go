@@ -0,0 +1,166 @@
+// platform-api is a REST API over the Tenant CRD for the internal portals,
+// so they can browse the tenant catalog and onboard teams without cluster
+// access. Callers authenticate with an ID token of the company's OpenID
+// Connect issuer:
+//
+//	GET  /api/v1/tenants                      list Tenants
+//	GET  /api/v1/tenants/NAME                 show a Tenant
+//	GET  /api/v1/tenants/NAME/usage           show a Tenant's quota usage and cost
+//	POST /api/v1/tenants                      onboard a Tenant
+//	GET  /api/v1/tenantrequests               list TenantRequests
+//	GET  /api/v1/tenantrequests/NAME          show a TenantRequest
+//	POST /api/v1/tenantrequests               request a Tenant for review
+//	POST /api/v1/tenantrequests/NAME/approve  approve a TenantRequest
+//	POST /api/v1/tenantrequests/NAME/reject   reject a TenantRequest
+//
+// Reads are served with the API's own ServiceAccount. Onboarding, requests
+// and reviews impersonate the caller, so the API server's RBAC decides who
+// may create Tenants or review TenantRequests and the webhooks and
+// TenantAudit see who did.
+package main
+
+import (
+	"context"
+	"flag"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+
+	platformv1alpha1 "github.com/xyz-company/tenant-operator/api/v1alpha1"
+)
+
+var setupLog = ctrl.Log.WithName("setup")
+
+func main() {
+	var (
+		bindAddr          string
+		certFile          string
+		keyFile           string
+		impersonateGroups string
+	)
+	verifier := &OIDCVerifier{HTTPClient: &http.Client{Timeout: 10 * time.Second}}
+	flag.StringVar(&bindAddr, "bind-address", ":8080", "Address the API binds to.")
+	flag.StringVar(&certFile, "tls-cert-file", "", "TLS certificate to serve the API with; plain HTTP if unset.")
+	flag.StringVar(&keyFile, "tls-private-key-file", "", "Private key of --tls-cert-file.")
+	flag.StringVar(&verifier.Issuer, "oidc-issuer-url", "", "OpenID Connect issuer whose ID tokens authenticate callers (required).")
+	flag.StringVar(&verifier.ClientID, "oidc-client-id", "", "Client ID ID tokens must be issued for (required).")
+	flag.StringVar(&verifier.UsernameClaim, "oidc-username-claim", "email", "ID token claim holding the caller's username.")
+	flag.StringVar(&verifier.UsernamePrefix, "oidc-username-prefix", "oidc:", "Prefix of usernames; set it like the API server's so onboarding impersonates the same user. Can't be empty.")
+	flag.StringVar(&verifier.GroupsClaim, "oidc-groups-claim", "groups", "ID token claim holding the caller's groups.")
+	flag.StringVar(&verifier.GroupsPrefix, "oidc-groups-prefix", "oidc:", "Prefix of groups; set it like the API server's. Can't be empty.")
+	flag.StringVar(&impersonateGroups, "impersonate-groups", "", "Comma-separated groups, prefixed, passed on when impersonating a caller in them; the ClusterRole must allow impersonating each. Callers' other groups are dropped.")
+	// --kubeconfig is registered by controller-runtime; the in-cluster
+	// config is used without it
+	logOptions := zap.Options{}
+	logOptions.BindFlags(flag.CommandLine)
+	flag.Parse()
+	ctrl.SetLogger(zap.New(zap.UseFlagOptions(&logOptions)))
+
+	if verifier.Issuer == "" || verifier.ClientID == "" {
+		setupLog.Error(nil, "--oidc-issuer-url and --oidc-client-id are required")
+		os.Exit(1)
+	}
+	for _, prefix := range []string{verifier.UsernamePrefix, verifier.GroupsPrefix} {
+		if prefix == "" || strings.HasPrefix(prefix, "system:") {
+			setupLog.Error(nil, "--oidc-username-prefix and --oidc-groups-prefix must be set, and not to system:")
+			os.Exit(1)
+		}
+	}
+	if (certFile == "") != (keyFile == "") {
+		setupLog.Error(nil, "--tls-cert-file and --tls-private-key-file must be set together")
+		os.Exit(1)
+	}
+
+	config, err := ctrl.GetConfig()
+	if err != nil {
+		setupLog.Error(err, "unable to load the kubeconfig")
+		os.Exit(1)
+	}
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		setupLog.Error(err, "unable to build the scheme")
+		os.Exit(1)
+	}
+	if err := platformv1alpha1.AddToScheme(scheme); err != nil {
+		setupLog.Error(err, "unable to build the scheme")
+		os.Exit(1)
+	}
+	httpClient, err := rest.HTTPClientFor(config)
+	if err != nil {
+		setupLog.Error(err, "unable to create an HTTP client")
+		os.Exit(1)
+	}
+	c, err := client.New(config, client.Options{HTTPClient: httpClient, Scheme: scheme})
+	if err != nil {
+		setupLog.Error(err, "unable to create a client")
+		os.Exit(1)
+	}
+
+	api := &Server{Client: c, Config: config, HTTPClient: httpClient, Verifier: verifier, ImpersonateGroups: map[string]bool{}}
+	for _, group := range strings.Split(impersonateGroups, ",") {
+		if group = strings.TrimSpace(group); group != "" {
+			api.ImpersonateGroups[group] = true
+		}
+	}
+	srv := &http.Server{Addr: bindAddr, Handler: api.Handler(), ReadHeaderTimeout: 10 * time.Second}
+	ctx := ctrl.SetupSignalHandler()
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		srv.Shutdown(shutdownCtx)
+	}()
+
+	setupLog.Info("serving the platform API", "address", bindAddr, "issuer", verifier.Issuer)
+	if certFile != "" {
+		err = srv.ListenAndServeTLS(certFile, keyFile)
+	} else {
+		err = srv.ListenAndServe()
+	}
+	if err != nil && err != http.ErrServerClosed {
+		setupLog.Error(err, "problem serving the platform API")
+		os.Exit(1)
+	}
+}
+
+// Handler routes the API's requests, all but /healthz authenticated
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	})
+	mux.HandleFunc("/api/v1/tenants", s.authenticate(s.handleTenants))
+	mux.HandleFunc("/api/v1/tenants/", s.authenticate(s.handleTenant))
+	mux.HandleFunc("/api/v1/tenantrequests", s.authenticate(s.handleTenantRequests))
+	mux.HandleFunc("/api/v1/tenantrequests/", s.authenticate(s.handleTenantRequest))
+	return mux
+}
+
+// authenticate wraps next, passing it the Identity of the request's bearer
+// token and rejecting requests without a valid one
+func (s *Server) authenticate(next func(http.ResponseWriter, *http.Request, *Identity)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || token == "" {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		identity, err := s.Verifier.Verify(r.Context(), token)
+		if err != nil {
+			ctrl.Log.WithName("platform-api").V(1).Info("Rejected token", "reason", err.Error())
+			w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next(w, r, identity)
+	}
+}
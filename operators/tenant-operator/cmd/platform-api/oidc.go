@@ -0,0 +1,320 @@
+package main
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// keyRefreshInterval is how often an unknown key ID may trigger a JWKS
+	// fetch, so forged tokens can't make the API hammer the issuer
+	keyRefreshInterval = time.Minute
+	// keyFetchTimeout bounds a JWKS fetch, which runs detached from the
+	// request that started it
+	keyFetchTimeout = 10 * time.Second
+	// clockSkew is the leeway given to exp and nbf
+	clockSkew = time.Minute
+)
+
+// Identity is the caller a verified ID token names
+type Identity struct {
+	Username string
+	Groups   []string
+}
+
+// OIDCVerifier verifies the ID tokens of an OpenID Connect issuer, signed
+// with RS256 or ES256, and maps their claims to an Identity the way the API
+// server's --oidc-* flags do
+type OIDCVerifier struct {
+	Issuer string
+	// ClientID is the audience tokens must be issued for
+	ClientID string
+	// UsernameClaim and GroupsClaim name the claims holding the caller's
+	// username and groups; UsernamePrefix and GroupsPrefix are prepended to
+	// their values. The prefixes are required, so no token can name a
+	// cluster user or group such as system:masters.
+	UsernameClaim  string
+	UsernamePrefix string
+	GroupsClaim    string
+	GroupsPrefix   string
+	HTTPClient     *http.Client
+
+	mu          sync.Mutex
+	keys        map[string]crypto.PublicKey
+	lastRefresh time.Time
+	// refreshing is closed when the keys being fetched are stored; nil
+	// while no fetch is in flight
+	refreshing chan struct{}
+}
+
+// jwk is the subset of a JSON Web Key the verifier understands
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// Verify checks token's signature, issuer, audience and lifetime and
+// returns the Identity it names
+func (v *OIDCVerifier) Verify(ctx context.Context, token string) (*Identity, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("malformed token")
+	}
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, fmt.Errorf("malformed token header: %w", err)
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("malformed token signature: %w", err)
+	}
+	key, err := v.key(ctx, header.Kid)
+	if err != nil {
+		return nil, err
+	}
+	if err := verifySignature(header.Alg, key, parts[0]+"."+parts[1], signature); err != nil {
+		return nil, err
+	}
+
+	var claims map[string]interface{}
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, fmt.Errorf("malformed token claims: %w", err)
+	}
+	if iss, _ := claims["iss"].(string); iss != v.Issuer {
+		return nil, fmt.Errorf("token issued by %q, not %q", iss, v.Issuer)
+	}
+	if !hasAudience(claims["aud"], v.ClientID) {
+		return nil, fmt.Errorf("token not issued for %q", v.ClientID)
+	}
+	now := time.Now()
+	exp, ok := claims["exp"].(float64)
+	if !ok {
+		return nil, errors.New("token has no expiry")
+	}
+	if now.After(time.Unix(int64(exp), 0).Add(clockSkew)) {
+		return nil, errors.New("token expired")
+	}
+	if nbf, ok := claims["nbf"].(float64); ok && now.Add(clockSkew).Before(time.Unix(int64(nbf), 0)) {
+		return nil, errors.New("token not valid yet")
+	}
+
+	if v.UsernamePrefix == "" || v.GroupsPrefix == "" {
+		return nil, errors.New("the verifier has no username or groups prefix")
+	}
+	username, _ := claims[v.UsernameClaim].(string)
+	if username == "" {
+		return nil, fmt.Errorf("token has no %s claim", v.UsernameClaim)
+	}
+	// An unverified address may well be someone else's
+	if verified, _ := claims["email_verified"].(bool); v.UsernameClaim == "email" && !verified {
+		return nil, errors.New("token's email is not verified")
+	}
+	identity := &Identity{Username: v.UsernamePrefix + username}
+	var groups []string
+	switch claim := claims[v.GroupsClaim].(type) {
+	case string:
+		groups = []string{claim}
+	case []interface{}:
+		for _, group := range claim {
+			if group, ok := group.(string); ok {
+				groups = append(groups, group)
+			}
+		}
+	}
+	for _, group := range groups {
+		// Reserved for the cluster's own groups, whatever the prefix
+		if strings.HasPrefix(group, "system:") {
+			continue
+		}
+		identity.Groups = append(identity.Groups, v.GroupsPrefix+group)
+	}
+	return identity, nil
+}
+
+// decodeSegment decodes a base64url-encoded JSON token segment into out
+func decodeSegment(segment string, out interface{}) error {
+	raw, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(raw, out)
+}
+
+// hasAudience reports whether the aud claim, a string or a list of them,
+// contains clientID
+func hasAudience(aud interface{}, clientID string) bool {
+	switch aud := aud.(type) {
+	case string:
+		return aud == clientID
+	case []interface{}:
+		return slices.Contains(aud, interface{}(clientID))
+	}
+	return false
+}
+
+// verifySignature checks signature over signed with key for alg
+func verifySignature(alg string, key crypto.PublicKey, signed string, signature []byte) error {
+	digest := sha256.Sum256([]byte(signed))
+	switch alg {
+	case "RS256":
+		rsaKey, ok := key.(*rsa.PublicKey)
+		if !ok {
+			return errors.New("token signed with RS256 but its key is not an RSA key")
+		}
+		if err := rsa.VerifyPKCS1v15(rsaKey, crypto.SHA256, digest[:], signature); err != nil {
+			return errors.New("invalid token signature")
+		}
+	case "ES256":
+		ecKey, ok := key.(*ecdsa.PublicKey)
+		if !ok || len(signature) != 64 {
+			return errors.New("token signed with ES256 but its key is not a P-256 key")
+		}
+		r, s := new(big.Int).SetBytes(signature[:32]), new(big.Int).SetBytes(signature[32:])
+		if !ecdsa.Verify(ecKey, digest[:], r, s) {
+			return errors.New("invalid token signature")
+		}
+	default:
+		return fmt.Errorf("unsupported token algorithm %q", alg)
+	}
+	return nil
+}
+
+// key returns the issuer's signing key kid, refetching the issuer's keys
+// if it isn't known, as after a key rotation. The keys are fetched outside
+// the lock, so a slow issuer only holds up the requests waiting for them,
+// and on a context of their own: other requests wait for the fetch, so the
+// one that started it going away mustn't cancel it.
+func (v *OIDCVerifier) key(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	v.mu.Lock()
+	if key, ok := v.keys[kid]; ok {
+		v.mu.Unlock()
+		return key, nil
+	}
+	refreshing := v.refreshing
+	if refreshing == nil && time.Since(v.lastRefresh) < keyRefreshInterval {
+		v.mu.Unlock()
+		return nil, fmt.Errorf("token signed with unknown key %q", kid)
+	}
+	if refreshing == nil {
+		v.lastRefresh = time.Now()
+		v.refreshing = make(chan struct{})
+		v.mu.Unlock()
+
+		fetchCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), keyFetchTimeout)
+		keys, err := v.fetchKeys(fetchCtx)
+		cancel()
+		v.mu.Lock()
+		if err == nil {
+			v.keys = keys
+		}
+		close(v.refreshing)
+		v.refreshing = nil
+		v.mu.Unlock()
+		if err != nil {
+			return nil, fmt.Errorf("fetching the keys of %s: %w", v.Issuer, err)
+		}
+	} else {
+		// Another request is fetching the keys already
+		v.mu.Unlock()
+		select {
+		case <-refreshing:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if key, ok := v.keys[kid]; ok {
+		return key, nil
+	}
+	return nil, fmt.Errorf("token signed with unknown key %q", kid)
+}
+
+// fetchKeys reads the issuer's signing keys from the jwks_uri of its
+// discovery document. Keys other than RSA and P-256 ones are skipped.
+func (v *OIDCVerifier) fetchKeys(ctx context.Context) (map[string]crypto.PublicKey, error) {
+	var discovery struct {
+		Issuer  string `json:"issuer"`
+		JWKSURI string `json:"jwks_uri"`
+	}
+	if err := v.getJSON(ctx, strings.TrimSuffix(v.Issuer, "/")+"/.well-known/openid-configuration", &discovery); err != nil {
+		return nil, err
+	}
+	if discovery.Issuer != v.Issuer {
+		return nil, fmt.Errorf("discovery document is for issuer %q", discovery.Issuer)
+	}
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := v.getJSON(ctx, discovery.JWKSURI, &set); err != nil {
+		return nil, err
+	}
+
+	keys := map[string]crypto.PublicKey{}
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		switch {
+		case k.Kty == "RSA":
+			n, errN := base64.RawURLEncoding.DecodeString(k.N)
+			e, errE := base64.RawURLEncoding.DecodeString(k.E)
+			if errN != nil || errE != nil || len(e) > 4 {
+				continue
+			}
+			keys[k.Kid] = &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+		case k.Kty == "EC" && k.Crv == "P-256":
+			x, errX := base64.RawURLEncoding.DecodeString(k.X)
+			y, errY := base64.RawURLEncoding.DecodeString(k.Y)
+			if errX != nil || errY != nil {
+				continue
+			}
+			keys[k.Kid] = &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		}
+	}
+	if len(keys) == 0 {
+		return nil, errors.New("no usable signing keys")
+	}
+	return keys, nil
+}
+
+// getJSON fetches url and decodes its JSON body into out
+func (v *OIDCVerifier) getJSON(ctx context.Context, url string, out interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := v.HTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: %s", url, resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
@@ -0,0 +1,381 @@
+package main
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testIssuer is an OpenID Connect issuer serving a discovery document and
+// the JWKS of its keys
+type testIssuer struct {
+	*httptest.Server
+	rsaKey *rsa.PrivateKey
+	ecKey  *ecdsa.PrivateKey
+
+	mu sync.Mutex
+	// keys are the public keys the JWKS serves, by key ID
+	keys map[string]crypto.PublicKey
+	// jwksFetches counts the requests for the JWKS
+	jwksFetches int
+}
+
+func newTestIssuer(t *testing.T) *testIssuer {
+	t.Helper()
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	issuer := &testIssuer{
+		rsaKey: rsaKey,
+		ecKey:  ecKey,
+		keys:   map[string]crypto.PublicKey{"rsa": &rsaKey.PublicKey, "ec": &ecKey.PublicKey},
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{"issuer": issuer.URL, "jwks_uri": issuer.URL + "/keys"})
+	})
+	mux.HandleFunc("/keys", issuer.serveKeys)
+	issuer.Server = httptest.NewServer(mux)
+	t.Cleanup(issuer.Close)
+	return issuer
+}
+
+func (issuer *testIssuer) serveKeys(w http.ResponseWriter, r *http.Request) {
+	issuer.mu.Lock()
+	defer issuer.mu.Unlock()
+	issuer.jwksFetches++
+	// A key for encryption, which the verifier skips
+	set := []jwk{{Kty: "RSA", Kid: "enc", Use: "enc", N: encodeInt(issuer.rsaKey.N), E: "AQAB"}}
+	for kid, key := range issuer.keys {
+		switch key := key.(type) {
+		case *rsa.PublicKey:
+			set = append(set, jwk{Kty: "RSA", Kid: kid, Use: "sig", N: encodeInt(key.N), E: encodeInt(big.NewInt(int64(key.E)))})
+		case *ecdsa.PublicKey:
+			set = append(set, jwk{Kty: "EC", Kid: kid, Crv: "P-256", X: encodeInt(key.X), Y: encodeInt(key.Y)})
+		}
+	}
+	json.NewEncoder(w).Encode(map[string][]jwk{"keys": set})
+}
+
+// fetches returns how often the JWKS was requested
+func (issuer *testIssuer) fetches() int {
+	issuer.mu.Lock()
+	defer issuer.mu.Unlock()
+	return issuer.jwksFetches
+}
+
+// verifier returns a verifier of the issuer's tokens for the audience
+// platform-api, with username and groups taken from email and groups
+func (issuer *testIssuer) verifier() *OIDCVerifier {
+	return &OIDCVerifier{
+		Issuer:         issuer.URL,
+		ClientID:       "platform-api",
+		UsernameClaim:  "email",
+		UsernamePrefix: "oidc:",
+		GroupsClaim:    "groups",
+		GroupsPrefix:   "oidc:",
+		HTTPClient:     issuer.Client(),
+	}
+}
+
+// claims returns valid claims of alice's token, with overrides applied;
+// an override of nil deletes the claim
+func (issuer *testIssuer) claims(overrides map[string]interface{}) map[string]interface{} {
+	now := time.Now()
+	claims := map[string]interface{}{
+		"iss":            issuer.URL,
+		"aud":            "platform-api",
+		"exp":            now.Add(time.Hour).Unix(),
+		"iat":            now.Unix(),
+		"email":          "alice@example.com",
+		"email_verified": true,
+		"groups":         []string{"search-team"},
+	}
+	for claim, value := range overrides {
+		if value == nil {
+			delete(claims, claim)
+		} else {
+			claims[claim] = value
+		}
+	}
+	return claims
+}
+
+// sign returns a token of claims signed for alg with the issuer's key of
+// that type, naming kid in its header
+func (issuer *testIssuer) sign(t *testing.T, alg, kid string, claims map[string]interface{}) string {
+	t.Helper()
+	signed := encodeSegment(t, map[string]string{"alg": alg, "kid": kid, "typ": "JWT"}) + "." + encodeSegment(t, claims)
+	digest := sha256.Sum256([]byte(signed))
+	var signature []byte
+	switch alg {
+	case "RS256":
+		var err error
+		if signature, err = rsa.SignPKCS1v15(rand.Reader, issuer.rsaKey, crypto.SHA256, digest[:]); err != nil {
+			t.Fatal(err)
+		}
+	case "ES256":
+		r, s, err := ecdsa.Sign(rand.Reader, issuer.ecKey, digest[:])
+		if err != nil {
+			t.Fatal(err)
+		}
+		signature = make([]byte, 64)
+		r.FillBytes(signature[:32])
+		s.FillBytes(signature[32:])
+	case "HS256":
+		// The public key as the HMAC secret, as in the classic key
+		// confusion attack on verifiers trusting the header's alg
+		secret, err := x509.MarshalPKIXPublicKey(&issuer.rsaKey.PublicKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		mac := hmac.New(sha256.New, secret)
+		mac.Write([]byte(signed))
+		signature = mac.Sum(nil)
+	case "none":
+	default:
+		t.Fatalf("can't sign for %s", alg)
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func encodeSegment(t *testing.T, v interface{}) string {
+	t.Helper()
+	raw, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+func encodeInt(n *big.Int) string {
+	return base64.RawURLEncoding.EncodeToString(n.Bytes())
+}
+
+func TestVerify(t *testing.T) {
+	issuer := newTestIssuer(t)
+	now := time.Now()
+	alice := &Identity{Username: "oidc:alice@example.com", Groups: []string{"oidc:search-team"}}
+
+	tests := []struct {
+		name      string
+		alg, kid  string
+		overrides map[string]interface{}
+		// tamper, if set, changes the signed token
+		tamper  func(token string) string
+		want    *Identity
+		wantErr string
+	}{
+		{name: "RS256", alg: "RS256", kid: "rsa", want: alice},
+		{name: "ES256", alg: "ES256", kid: "ec", want: alice},
+		{name: "alg none", alg: "none", kid: "rsa", wantErr: `unsupported token algorithm "none"`},
+		{name: "HS256 with the public key as secret", alg: "HS256", kid: "rsa", wantErr: `unsupported token algorithm "HS256"`},
+		{name: "RS256 with an EC key", alg: "RS256", kid: "ec", wantErr: "signed with RS256 but its key is not an RSA key"},
+		{name: "ES256 with an RSA key", alg: "ES256", kid: "rsa", wantErr: "signed with ES256 but its key is not a P-256 key"},
+		{name: "encryption key", alg: "RS256", kid: "enc", wantErr: `unknown key "enc"`},
+		{
+			name: "bad signature", alg: "RS256", kid: "rsa",
+			tamper: func(token string) string {
+				parts := strings.Split(token, ".")
+				claims, _ := json.Marshal(issuer.claims(map[string]interface{}{"email": "mallory@example.com"}))
+				parts[1] = base64.RawURLEncoding.EncodeToString(claims)
+				return strings.Join(parts, ".")
+			},
+			wantErr: "invalid token signature",
+		},
+		{name: "malformed", alg: "RS256", kid: "rsa", tamper: func(string) string { return "a.b" }, wantErr: "malformed token"},
+		{name: "wrong issuer", alg: "RS256", kid: "rsa", overrides: map[string]interface{}{"iss": "https://evil.example.com"}, wantErr: "token issued by"},
+		{name: "wrong audience", alg: "RS256", kid: "rsa", overrides: map[string]interface{}{"aud": "other-app"}, wantErr: `token not issued for "platform-api"`},
+		{name: "audience list", alg: "RS256", kid: "rsa", overrides: map[string]interface{}{"aud": []string{"other-app", "platform-api"}}, want: alice},
+		{name: "expired", alg: "RS256", kid: "rsa", overrides: map[string]interface{}{"exp": now.Add(-2 * clockSkew).Unix()}, wantErr: "token expired"},
+		{name: "expired within the skew", alg: "RS256", kid: "rsa", overrides: map[string]interface{}{"exp": now.Add(-clockSkew / 2).Unix()}, want: alice},
+		{name: "no expiry", alg: "RS256", kid: "rsa", overrides: map[string]interface{}{"exp": nil}, wantErr: "token has no expiry"},
+		{name: "not valid yet", alg: "RS256", kid: "rsa", overrides: map[string]interface{}{"nbf": now.Add(2 * clockSkew).Unix()}, wantErr: "token not valid yet"},
+		{name: "valid within the skew", alg: "RS256", kid: "rsa", overrides: map[string]interface{}{"nbf": now.Add(clockSkew / 2).Unix()}, want: alice},
+		{name: "email not verified", alg: "RS256", kid: "rsa", overrides: map[string]interface{}{"email_verified": false}, wantErr: "token's email is not verified"},
+		{name: "email verification missing", alg: "RS256", kid: "rsa", overrides: map[string]interface{}{"email_verified": nil}, wantErr: "token's email is not verified"},
+		{name: "no username", alg: "RS256", kid: "rsa", overrides: map[string]interface{}{"email": nil}, wantErr: "token has no email claim"},
+		{
+			name: "system groups dropped", alg: "RS256", kid: "rsa",
+			overrides: map[string]interface{}{"groups": []string{"system:masters", "search-team", "system:nodes"}},
+			want:      alice,
+		},
+		{name: "groups as a string", alg: "RS256", kid: "rsa", overrides: map[string]interface{}{"groups": "search-team"}, want: alice},
+		{name: "no groups", alg: "RS256", kid: "rsa", overrides: map[string]interface{}{"groups": nil}, want: &Identity{Username: "oidc:alice@example.com"}},
+	}
+	v := issuer.verifier()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token := issuer.sign(t, tt.alg, tt.kid, issuer.claims(tt.overrides))
+			if tt.tamper != nil {
+				token = tt.tamper(token)
+			}
+			got, err := v.Verify(context.Background(), token)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Verify() = %+v, %v, want an error containing %q", got, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Verify() = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("Verify() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestVerifyRequiresPrefixes(t *testing.T) {
+	issuer := newTestIssuer(t)
+	token := issuer.sign(t, "RS256", "rsa", issuer.claims(nil))
+	for _, unset := range []func(*OIDCVerifier){
+		func(v *OIDCVerifier) { v.UsernamePrefix = "" },
+		func(v *OIDCVerifier) { v.GroupsPrefix = "" },
+	} {
+		v := issuer.verifier()
+		unset(v)
+		if _, err := v.Verify(context.Background(), token); err == nil || !strings.Contains(err.Error(), "no username or groups prefix") {
+			t.Fatalf("Verify() with prefixes %q and %q = %v, want them required", v.UsernamePrefix, v.GroupsPrefix, err)
+		}
+	}
+}
+
+func TestVerifyOtherClaims(t *testing.T) {
+	issuer := newTestIssuer(t)
+	v := issuer.verifier()
+	v.UsernameClaim, v.UsernamePrefix = "sub", "sso:"
+	v.GroupsClaim, v.GroupsPrefix = "roles", "sso-group:"
+
+	// email_verified only matters for the email claim
+	token := issuer.sign(t, "ES256", "ec", issuer.claims(map[string]interface{}{
+		"sub":            "1234",
+		"email_verified": false,
+		"roles":          []interface{}{"admins", 7, "system:masters"},
+	}))
+	got, err := v.Verify(context.Background(), token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (&Identity{Username: "sso:1234", Groups: []string{"sso-group:admins"}}); !reflect.DeepEqual(got, want) {
+		t.Fatalf("Verify() = %+v, want %+v", got, want)
+	}
+}
+
+func TestVerifyUnknownKeyRefreshIsRateLimited(t *testing.T) {
+	issuer := newTestIssuer(t)
+	v := issuer.verifier()
+	ctx := context.Background()
+
+	if _, err := v.Verify(ctx, issuer.sign(t, "RS256", "rsa", issuer.claims(nil))); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := v.Verify(ctx, issuer.sign(t, "ES256", "ec", issuer.claims(nil))); err != nil {
+		t.Fatal(err)
+	}
+	if got := issuer.fetches(); got != 1 {
+		t.Fatalf("JWKS fetched %d times for two known keys, want once", got)
+	}
+
+	// The issuer rotates to a new key, but the keys were fetched too
+	// recently to refetch them for it
+	rotated, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	issuer.mu.Lock()
+	issuer.keys["rotated"] = &rotated.PublicKey
+	issuer.rsaKey = rotated
+	issuer.mu.Unlock()
+	token := issuer.sign(t, "RS256", "rotated", issuer.claims(nil))
+	for i := 0; i < 3; i++ {
+		if _, err := v.Verify(ctx, token); err == nil || !strings.Contains(err.Error(), `unknown key "rotated"`) {
+			t.Fatalf("Verify() = %v, want the key unknown", err)
+		}
+	}
+	if got := issuer.fetches(); got != 1 {
+		t.Fatalf("JWKS fetched %d times within the refresh interval, want once", got)
+	}
+
+	// After the interval the unknown key triggers a fetch, which finds it
+	v.mu.Lock()
+	v.lastRefresh = time.Now().Add(-keyRefreshInterval)
+	v.mu.Unlock()
+	if _, err := v.Verify(ctx, token); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := v.Verify(ctx, issuer.sign(t, "RS256", "forged", issuer.claims(nil))); err == nil {
+		t.Fatal("Verify() of an unknown key succeeded")
+	}
+	if got := issuer.fetches(); got != 2 {
+		t.Fatalf("JWKS fetched %d times, want twice", got)
+	}
+}
+
+func TestVerifyKeyFetchOutlivesCanceledRequest(t *testing.T) {
+	issuer := newTestIssuer(t)
+	v := issuer.verifier()
+	token := issuer.sign(t, "RS256", "rsa", issuer.claims(nil))
+
+	// The request starting the fetch is gone already; the fetch other
+	// requests may be waiting for still completes
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := v.Verify(ctx, token); err != nil {
+		t.Fatalf("Verify() with a canceled context = %v, want the keys fetched regardless", err)
+	}
+	if _, err := v.Verify(context.Background(), token); err != nil {
+		t.Fatal(err)
+	}
+	if got := issuer.fetches(); got != 1 {
+		t.Fatalf("JWKS fetched %d times, want once", got)
+	}
+}
+
+func TestVerifyCoalescesKeyFetches(t *testing.T) {
+	issuer := newTestIssuer(t)
+	v := issuer.verifier()
+	token := issuer.sign(t, "RS256", "rsa", issuer.claims(nil))
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 10)
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := v.Verify(context.Background(), token); err != nil {
+				errs <- err
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+	if got := issuer.fetches(); got != 1 {
+		t.Fatalf("JWKS fetched %d times by concurrent requests, want once", got)
+	}
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	platformv1alpha1 "github.com/xyz-company/tenant-operator/api/v1alpha1"
+)
+
+// TenantRequestView is a TenantRequest as the API returns it
+type TenantRequestView struct {
+	Name      string                               `json:"name"`
+	Spec      platformv1alpha1.TenantRequestSpec   `json:"spec"`
+	Status    platformv1alpha1.TenantRequestStatus `json:"status"`
+	CreatedAt metav1.Time                          `json:"createdAt"`
+}
+
+// TenantRequestList is the response of GET /api/v1/tenantrequests
+type TenantRequestList struct {
+	Items []TenantRequestView `json:"items"`
+}
+
+// TenantRequestSubmission is the body of POST /api/v1/tenantrequests: the
+// Tenant asked for and what it is for
+type TenantRequestSubmission struct {
+	OnboardingRequest
+	Reason string `json:"reason,omitempty"`
+}
+
+// ReviewRequest is the body of POST /api/v1/tenantrequests/NAME/approve
+// and /reject; it may be omitted
+type ReviewRequest struct {
+	Comment string `json:"comment,omitempty"`
+}
+
+// handleTenantRequests serves GET and POST /api/v1/tenantrequests
+func (s *Server) handleTenantRequests(w http.ResponseWriter, r *http.Request, identity *Identity) {
+	switch r.Method {
+	case http.MethodGet:
+		s.listTenantRequests(w, r)
+	case http.MethodPost:
+		s.submitTenantRequest(w, r, identity)
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleTenantRequest serves GET /api/v1/tenantrequests/NAME and
+// POST /api/v1/tenantrequests/NAME/approve and /reject
+func (s *Server) handleTenantRequest(w http.ResponseWriter, r *http.Request, identity *Identity) {
+	name, action, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/api/v1/tenantrequests/"), "/")
+	var decision platformv1alpha1.TenantRequestPhase
+	switch action {
+	case "":
+	case "approve":
+		decision = platformv1alpha1.TenantRequestApproved
+	case "reject":
+		decision = platformv1alpha1.TenantRequestRejected
+	default:
+		http.NotFound(w, r)
+		return
+	}
+	if name == "" {
+		http.NotFound(w, r)
+		return
+	}
+	want := http.MethodGet
+	if decision != "" {
+		want = http.MethodPost
+	}
+	if r.Method != want {
+		w.Header().Set("Allow", want)
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	tenantRequest := &platformv1alpha1.TenantRequest{}
+	if err := s.Client.Get(r.Context(), client.ObjectKey{Name: name}, tenantRequest); err != nil {
+		writeError(w, err)
+		return
+	}
+	if decision == "" {
+		writeJSON(w, http.StatusOK, tenantRequestView(tenantRequest))
+		return
+	}
+	s.reviewTenantRequest(w, r, identity, tenantRequest, decision)
+}
+
+// listTenantRequests lists TenantRequests by name, optionally only those
+// in the phase query parameter
+func (s *Server) listTenantRequests(w http.ResponseWriter, r *http.Request) {
+	list := &platformv1alpha1.TenantRequestList{}
+	if err := s.Client.List(r.Context(), list); err != nil {
+		writeError(w, err)
+		return
+	}
+	phase := r.URL.Query().Get("phase")
+
+	response := TenantRequestList{Items: []TenantRequestView{}}
+	for i := range list.Items {
+		if phase != "" && string(list.Items[i].Status.Phase) != phase {
+			continue
+		}
+		response.Items = append(response.Items, tenantRequestView(&list.Items[i]))
+	}
+	sort.Slice(response.Items, func(i, j int) bool {
+		return response.Items[i].Name < response.Items[j].Name
+	})
+	writeJSON(w, http.StatusOK, response)
+}
+
+// submitTenantRequest creates a TenantRequest as the caller, who the
+// webhook records as its requester
+func (s *Server) submitTenantRequest(w http.ResponseWriter, r *http.Request, identity *Identity) {
+	var submission TenantRequestSubmission
+	if !decodeBody(w, r, &submission) {
+		return
+	}
+	spec, err := submission.spec()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	tenantRequest := &platformv1alpha1.TenantRequest{
+		ObjectMeta: metav1.ObjectMeta{Name: submission.Name},
+		Spec: platformv1alpha1.TenantRequestSpec{
+			Owner:      spec.Owner,
+			CostCenter: spec.CostCenter,
+			Profile:    spec.Profile,
+			Parent:     spec.Parent,
+			Namespaces: spec.Namespaces,
+			Contacts:   spec.Contacts,
+			Quota:      spec.Quota,
+			TTL:        spec.TTL,
+			Reason:     submission.Reason,
+		},
+	}
+
+	c, err := s.clientFor(identity)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if err := c.Create(r.Context(), tenantRequest); err != nil {
+		writeError(w, err)
+		return
+	}
+	ctrl.Log.WithName("platform-api").Info("Submitted tenant request", "tenantRequest", tenantRequest.Name, "user", identity.Username)
+	w.Header().Set("Location", "/api/v1/tenantrequests/"+tenantRequest.Name)
+	writeJSON(w, http.StatusCreated, tenantRequestView(tenantRequest))
+}
+
+// reviewTenantRequest approves or rejects a Pending TenantRequest as the
+// caller, so the API server's RBAC on tenantrequests/status decides who
+// may review
+func (s *Server) reviewTenantRequest(w http.ResponseWriter, r *http.Request, identity *Identity, tenantRequest *platformv1alpha1.TenantRequest, decision platformv1alpha1.TenantRequestPhase) {
+	var review ReviewRequest
+	if r.ContentLength != 0 && !decodeBody(w, r, &review) {
+		return
+	}
+	if phase := tenantRequest.Status.Phase; phase != platformv1alpha1.TenantRequestPending {
+		http.Error(w, "TenantRequest "+tenantRequest.Name+" is "+string(phase)+", not Pending", http.StatusConflict)
+		return
+	}
+
+	c, err := s.clientFor(identity)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	// Locked, so a concurrent review fails rather than being overwritten
+	patch := client.MergeFromWithOptions(tenantRequest.DeepCopy(), client.MergeFromWithOptimisticLock{})
+	tenantRequest.Status.Phase = decision
+	tenantRequest.Status.Comment = review.Comment
+	if err := c.Status().Patch(r.Context(), tenantRequest, patch); err != nil {
+		writeError(w, err)
+		return
+	}
+	ctrl.Log.WithName("platform-api").Info("Reviewed tenant request", "tenantRequest", tenantRequest.Name, "phase", decision, "user", identity.Username)
+	writeJSON(w, http.StatusOK, tenantRequestView(tenantRequest))
+}
+
+// tenantRequestView returns the API's view of tenantRequest
+func tenantRequestView(tenantRequest *platformv1alpha1.TenantRequest) TenantRequestView {
+	return TenantRequestView{
+		Name:      tenantRequest.Name,
+		Spec:      tenantRequest.Spec,
+		Status:    tenantRequest.Status,
+		CreatedAt: tenantRequest.CreationTimestamp,
+	}
+}
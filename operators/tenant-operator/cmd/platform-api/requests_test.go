@@ -0,0 +1,411 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	platformv1alpha1 "github.com/xyz-company/tenant-operator/api/v1alpha1"
+)
+
+// reviewersGroup is the group tenant-request-approver is bound to in the
+// test API server, as k8s/tenant-requests.yaml suggests
+const reviewersGroup = "oidc:platform-admins"
+
+// impersonation is who a request to the test API server impersonated
+type impersonation struct {
+	Method string
+	User   string
+	Groups []string
+}
+
+// testAPIServer stands in for the API server behind the clients that
+// impersonate callers. It stores objects in Client, the one the Server
+// reads with, and plays the RBAC of k8s/tenant-requests.yaml: anyone may
+// create TenantRequests, only reviewersGroup may update their status, and
+// nothing may be written without impersonating a caller.
+type testAPIServer struct {
+	*httptest.Server
+	Client client.Client
+
+	mu       sync.Mutex
+	requests []impersonation
+}
+
+func newTestAPIServer(t *testing.T, objs ...client.Object) *testAPIServer {
+	t.Helper()
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	if err := platformv1alpha1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	mapper := meta.NewDefaultRESTMapper(nil)
+	mapper.Add(platformv1alpha1.GroupVersion.WithKind("Tenant"), meta.RESTScopeRoot)
+	mapper.Add(platformv1alpha1.GroupVersion.WithKind("TenantRequest"), meta.RESTScopeRoot)
+	api := &testAPIServer{
+		Client: fake.NewClientBuilder().
+			WithScheme(scheme).
+			WithRESTMapper(mapper).
+			WithObjects(objs...).
+			WithStatusSubresource(&platformv1alpha1.TenantRequest{}).
+			Build(),
+	}
+	api.Server = httptest.NewServer(http.HandlerFunc(api.serve))
+	t.Cleanup(api.Close)
+	return api
+}
+
+func (api *testAPIServer) serve(w http.ResponseWriter, r *http.Request) {
+	caller := impersonation{Method: r.Method, User: r.Header.Get("Impersonate-User"), Groups: r.Header.Values("Impersonate-Group")}
+	api.mu.Lock()
+	api.requests = append(api.requests, caller)
+	api.mu.Unlock()
+
+	path, ok := strings.CutPrefix(r.URL.Path, "/apis/platform.xyz.com/v1alpha1/tenantrequests")
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	name, subResource, _ := strings.Cut(strings.TrimPrefix(path, "/"), "/")
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeStatus(w, apierrors.NewBadRequest(err.Error()))
+		return
+	}
+	resource := platformv1alpha1.GroupVersion.WithResource("tenantrequests").GroupResource()
+	tenantRequest := &platformv1alpha1.TenantRequest{}
+	switch {
+	case caller.User == "":
+		err = apierrors.NewForbidden(resource, name, errNotImpersonating)
+	case r.Method == http.MethodPost && name == "":
+		if err = json.Unmarshal(body, tenantRequest); err == nil {
+			// As the TenantRequest webhook would
+			tenantRequest.Spec.RequestedBy = caller.User
+			err = api.Client.Create(r.Context(), tenantRequest)
+		}
+	case r.Method == http.MethodPatch && subResource == "status":
+		if !slices.Contains(caller.Groups, reviewersGroup) {
+			err = apierrors.NewForbidden(resource, name, errNotReviewer)
+			break
+		}
+		tenantRequest.Name = name
+		err = api.Client.Status().Patch(r.Context(), tenantRequest, client.RawPatch(types.PatchType(r.Header.Get("Content-Type")), body))
+	default:
+		err = apierrors.NewMethodNotSupported(resource, r.Method)
+	}
+	if err != nil {
+		writeStatus(w, err)
+		return
+	}
+	tenantRequest.APIVersion, tenantRequest.Kind = platformv1alpha1.GroupVersion.String(), "TenantRequest"
+	w.Header().Set("Content-Type", "application/json")
+	if r.Method == http.MethodPost {
+		w.WriteHeader(http.StatusCreated)
+	}
+	json.NewEncoder(w).Encode(tenantRequest)
+}
+
+var (
+	errNotImpersonating = errors.New("the platform API's ServiceAccount can't write TenantRequests itself")
+	errNotReviewer      = errors.New("only platform admins may review TenantRequests")
+)
+
+// writeStatus answers with err as the API server's Status
+func writeStatus(w http.ResponseWriter, err error) {
+	status := apierrors.NewInternalError(err).ErrStatus
+	var apiStatus apierrors.APIStatus
+	if errors.As(err, &apiStatus) {
+		status = apiStatus.Status()
+	}
+	status.APIVersion, status.Kind = "v1", "Status"
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(int(status.Code))
+	json.NewEncoder(w).Encode(status)
+}
+
+// writes returns the impersonations of the requests the API server got
+func (api *testAPIServer) writes() []impersonation {
+	api.mu.Lock()
+	defer api.mu.Unlock()
+	return slices.Clone(api.requests)
+}
+
+// newTestServer returns a Server whose reads use api's objects and whose
+// impersonated writes go to api, authenticating callers with issuer's
+// tokens. Callers in the reviewers, team-leads or engineering groups are
+// impersonated with them.
+func newTestServer(api *testAPIServer, issuer *testIssuer) http.Handler {
+	s := &Server{
+		Client:            api.Client,
+		Config:            &rest.Config{Host: api.URL},
+		HTTPClient:        api.Server.Client(),
+		Verifier:          issuer.verifier(),
+		ImpersonateGroups: map[string]bool{reviewersGroup: true, "oidc:team-leads": true, "oidc:engineering": true},
+	}
+	return s.Handler()
+}
+
+// call sends method path with body to handler with a token of email in
+// groups, or without a token if email is empty
+func call(t *testing.T, handler http.Handler, issuer *testIssuer, method, path, body, email string, groups ...string) *httptest.ResponseRecorder {
+	t.Helper()
+	var reader io.Reader
+	if body != "" {
+		reader = strings.NewReader(body)
+	}
+	req := httptest.NewRequest(method, path, reader)
+	if email != "" {
+		claims := issuer.claims(map[string]interface{}{"email": email, "groups": groups})
+		req.Header.Set("Authorization", "Bearer "+issuer.sign(t, "RS256", "rsa", claims))
+	}
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	return w
+}
+
+func pendingRequest(name string) *platformv1alpha1.TenantRequest {
+	return &platformv1alpha1.TenantRequest{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Spec:       platformv1alpha1.TenantRequestSpec{Owner: "search-team", RequestedBy: "oidc:jane@xyz.com"},
+		Status:     platformv1alpha1.TenantRequestStatus{Phase: platformv1alpha1.TenantRequestPending},
+	}
+}
+
+func storedStatus(t *testing.T, api *testAPIServer, name string) platformv1alpha1.TenantRequestStatus {
+	t.Helper()
+	tenantRequest := &platformv1alpha1.TenantRequest{}
+	if err := api.Client.Get(context.Background(), client.ObjectKey{Name: name}, tenantRequest); err != nil {
+		t.Fatal(err)
+	}
+	return tenantRequest.Status
+}
+
+func TestReviewTenantRequest(t *testing.T) {
+	for _, decision := range []platformv1alpha1.TenantRequestPhase{platformv1alpha1.TenantRequestApproved, platformv1alpha1.TenantRequestRejected} {
+		t.Run(string(decision), func(t *testing.T) {
+			issuer := newTestIssuer(t)
+			api := newTestAPIServer(t, pendingRequest("sandbox"))
+			handler := newTestServer(api, issuer)
+			action := map[platformv1alpha1.TenantRequestPhase]string{
+				platformv1alpha1.TenantRequestApproved: "approve",
+				platformv1alpha1.TenantRequestRejected: "reject",
+			}[decision]
+
+			w := call(t, handler, issuer, http.MethodPost, "/api/v1/tenantrequests/sandbox/"+action, `{"comment":"Sandbox sized"}`,
+				"admin@xyz.com", "platform-admins", "search-team", "system:masters")
+			if w.Code != http.StatusOK {
+				t.Fatalf("status = %d: %s", w.Code, w.Body)
+			}
+			var view TenantRequestView
+			if err := json.Unmarshal(w.Body.Bytes(), &view); err != nil {
+				t.Fatal(err)
+			}
+			if view.Status.Phase != decision || view.Status.Comment != "Sandbox sized" {
+				t.Errorf("response status = %+v, want %s with the comment", view.Status, decision)
+			}
+			if status := storedStatus(t, api, "sandbox"); status.Phase != decision || status.Comment != "Sandbox sized" {
+				t.Errorf("stored status = %+v, want %s with the comment", status, decision)
+			}
+
+			// Written as the reviewer, with only the groups the API may
+			// impersonate
+			want := []impersonation{{Method: http.MethodPatch, User: "oidc:admin@xyz.com", Groups: []string{reviewersGroup}}}
+			if got := api.writes(); !sameImpersonations(got, want) {
+				t.Fatalf("API server requests = %+v, want %+v", got, want)
+			}
+		})
+	}
+}
+
+func TestReviewTenantRequestForbidden(t *testing.T) {
+	issuer := newTestIssuer(t)
+	api := newTestAPIServer(t, pendingRequest("sandbox"))
+	handler := newTestServer(api, issuer)
+
+	// The requester's team lead can't approve it; the API server's RBAC
+	// decides, not the API
+	w := call(t, handler, issuer, http.MethodPost, "/api/v1/tenantrequests/sandbox/approve", "", "lead@xyz.com", "team-leads")
+	if w.Code != http.StatusForbidden || !strings.Contains(w.Body.String(), errNotReviewer.Error()) {
+		t.Fatalf("status = %d: %s, want 403 with the API server's reason", w.Code, w.Body)
+	}
+	if got := api.writes(); len(got) != 1 || got[0].User != "oidc:lead@xyz.com" {
+		t.Fatalf("API server requests = %+v, want one as the caller", got)
+	}
+	if status := storedStatus(t, api, "sandbox"); status.Phase != platformv1alpha1.TenantRequestPending {
+		t.Fatalf("phase = %q, want it still Pending", status.Phase)
+	}
+
+	// Nor can a caller claiming a cluster group, which the verifier drops
+	w = call(t, handler, issuer, http.MethodPost, "/api/v1/tenantrequests/sandbox/approve", "", "mallory@xyz.com", "system:masters")
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("status = %d: %s, want 403", w.Code, w.Body)
+	}
+
+	// Without a valid token nothing reaches the API server
+	for _, token := range []string{"", "Bearer forged.token.here"} {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/tenantrequests/sandbox/approve", nil)
+		if token != "" {
+			req.Header.Set("Authorization", token)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		if w.Code != http.StatusUnauthorized {
+			t.Fatalf("Authorization %q: status = %d, want 401", token, w.Code)
+		}
+	}
+	if got := api.writes(); len(got) != 2 {
+		t.Fatalf("API server requests = %+v, want only the two reviews", got)
+	}
+}
+
+func TestReviewTenantRequestDecided(t *testing.T) {
+	issuer := newTestIssuer(t)
+	approved := pendingRequest("approved")
+	approved.Status.Phase = platformv1alpha1.TenantRequestApproved
+	rejected := pendingRequest("rejected")
+	rejected.Status.Phase = platformv1alpha1.TenantRequestRejected
+	api := newTestAPIServer(t, approved, rejected)
+	handler := newTestServer(api, issuer)
+
+	for _, path := range []string{
+		"/api/v1/tenantrequests/approved/reject",
+		"/api/v1/tenantrequests/approved/approve",
+		"/api/v1/tenantrequests/rejected/approve",
+	} {
+		w := call(t, handler, issuer, http.MethodPost, path, "", "admin@xyz.com", "platform-admins")
+		if w.Code != http.StatusConflict {
+			t.Errorf("%s: status = %d: %s, want 409", path, w.Code, w.Body)
+		}
+	}
+	if got := api.writes(); len(got) != 0 {
+		t.Fatalf("API server requests = %+v, want none for decided requests", got)
+	}
+	if status := storedStatus(t, api, "rejected"); status.Phase != platformv1alpha1.TenantRequestRejected {
+		t.Fatalf("phase = %q, want it still Rejected", status.Phase)
+	}
+}
+
+func TestTenantRequestRoutes(t *testing.T) {
+	issuer := newTestIssuer(t)
+	api := newTestAPIServer(t, pendingRequest("sandbox"))
+	handler := newTestServer(api, issuer)
+
+	tests := []struct {
+		method, path, body string
+		want               int
+	}{
+		{http.MethodGet, "/api/v1/tenantrequests/sandbox", "", http.StatusOK},
+		{http.MethodGet, "/api/v1/tenantrequests/missing", "", http.StatusNotFound},
+		{http.MethodGet, "/api/v1/tenantrequests/sandbox/approve", "", http.StatusMethodNotAllowed},
+		{http.MethodPost, "/api/v1/tenantrequests/sandbox", "", http.StatusMethodNotAllowed},
+		{http.MethodPost, "/api/v1/tenantrequests/sandbox/escalate", "", http.StatusNotFound},
+		{http.MethodPost, "/api/v1/tenantrequests/sandbox/approve", `{"comment":1}`, http.StatusBadRequest},
+		{http.MethodPost, "/api/v1/tenantrequests/sandbox/approve", `{"phase":"Approved"}`, http.StatusBadRequest},
+		{http.MethodDelete, "/api/v1/tenantrequests", "", http.StatusMethodNotAllowed},
+	}
+	for _, tt := range tests {
+		if w := call(t, handler, issuer, tt.method, tt.path, tt.body, "admin@xyz.com", "platform-admins"); w.Code != tt.want {
+			t.Errorf("%s %s: status = %d: %s, want %d", tt.method, tt.path, w.Code, w.Body, tt.want)
+		}
+	}
+	if got := api.writes(); len(got) != 0 {
+		t.Fatalf("API server requests = %+v, want none", got)
+	}
+}
+
+func TestSubmitTenantRequest(t *testing.T) {
+	issuer := newTestIssuer(t)
+	api := newTestAPIServer(t)
+	handler := newTestServer(api, issuer)
+
+	body := `{"name":"sandbox","owner":"search-team","quota":{"cpu":"1","memory":"2Gi"},"ttl":"72h","reason":"Trying out the search index"}`
+	w := call(t, handler, issuer, http.MethodPost, "/api/v1/tenantrequests", body, "jane@xyz.com", "engineering")
+	if w.Code != http.StatusCreated {
+		t.Fatalf("status = %d: %s", w.Code, w.Body)
+	}
+	if got := w.Header().Get("Location"); got != "/api/v1/tenantrequests/sandbox" {
+		t.Errorf("Location = %q", got)
+	}
+	want := []impersonation{{Method: http.MethodPost, User: "oidc:jane@xyz.com", Groups: []string{"oidc:engineering"}}}
+	if got := api.writes(); !sameImpersonations(got, want) {
+		t.Fatalf("API server requests = %+v, want %+v", got, want)
+	}
+	tenantRequest := &platformv1alpha1.TenantRequest{}
+	if err := api.Client.Get(context.Background(), client.ObjectKey{Name: "sandbox"}, tenantRequest); err != nil {
+		t.Fatal(err)
+	}
+	spec := tenantRequest.Spec
+	if spec.Owner != "search-team" || spec.Quota.CPU != "1" || spec.TTL == nil || spec.TTL.Duration.Hours() != 72 ||
+		spec.Reason != "Trying out the search index" || spec.RequestedBy != "oidc:jane@xyz.com" {
+		t.Fatalf("stored spec = %+v, want the submitted one requested by the caller", spec)
+	}
+
+	for _, body := range []string{
+		`{"owner":"search-team"}`,
+		`{"name":"sandbox","owner":"search-team","ttl":"a week"}`,
+		`{"name":"sandbox","owner":"search-team","requestedBy":"someone-else"}`,
+	} {
+		if w := call(t, handler, issuer, http.MethodPost, "/api/v1/tenantrequests", body, "jane@xyz.com"); w.Code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d: %s, want 400", body, w.Code, w.Body)
+		}
+	}
+	if w := call(t, handler, issuer, http.MethodPost, "/api/v1/tenantrequests", body, "jane@xyz.com"); w.Code != http.StatusConflict {
+		t.Errorf("resubmitted: status = %d: %s, want 409", w.Code, w.Body)
+	}
+}
+
+func TestListTenantRequests(t *testing.T) {
+	issuer := newTestIssuer(t)
+	approved := pendingRequest("approved")
+	approved.Status.Phase = platformv1alpha1.TenantRequestApproved
+	api := newTestAPIServer(t, pendingRequest("zebra"), approved, pendingRequest("alpha"))
+	handler := newTestServer(api, issuer)
+
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"", []string{"alpha", "approved", "zebra"}},
+		{"?phase=Pending", []string{"alpha", "zebra"}},
+		{"?phase=Rejected", []string{}},
+	}
+	for _, tt := range tests {
+		w := call(t, handler, issuer, http.MethodGet, "/api/v1/tenantrequests"+tt.query, "", "jane@xyz.com")
+		var list TenantRequestList
+		if err := json.Unmarshal(w.Body.Bytes(), &list); err != nil {
+			t.Fatalf("%q: %v: %s", tt.query, err, w.Body)
+		}
+		names := []string{}
+		for _, item := range list.Items {
+			names = append(names, item.Name)
+		}
+		if !slices.Equal(names, tt.want) {
+			t.Errorf("%q: listed %v, want %v", tt.query, names, tt.want)
+		}
+	}
+}
+
+func sameImpersonations(a, b []impersonation) bool {
+	return slices.EqualFunc(a, b, func(x, y impersonation) bool {
+		return x.Method == y.Method && x.User == y.User && slices.Equal(x.Groups, y.Groups)
+	})
+}
@@ -0,0 +1,360 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/transport"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	platformv1alpha1 "github.com/xyz-company/tenant-operator/api/v1alpha1"
+)
+
+const (
+	// tenantQuotaName is the ResourceQuota the operator creates in every
+	// tenant namespace
+	tenantQuotaName = "tenant-quota"
+	// maxRequestBody bounds the size of POST bodies
+	maxRequestBody = 64 << 10
+)
+
+// Server serves the platform API
+type Server struct {
+	// Client reads Tenants, their ResourceQuotas and TenantRequests as the
+	// API's own ServiceAccount
+	Client client.Client
+	// Config and HTTPClient are the API's client config and its HTTP
+	// client, from which the clients writing as the caller are derived;
+	// they all share its connections
+	Config     *rest.Config
+	HTTPClient *http.Client
+	Verifier   *OIDCVerifier
+	// ImpersonateGroups are the groups a caller is impersonated with, if
+	// they are in them; the API's ClusterRole only allows impersonating
+	// these
+	ImpersonateGroups map[string]bool
+}
+
+// TenantSummary is the catalog view of a single Tenant
+type TenantSummary struct {
+	Name       string   `json:"name"`
+	Owner      string   `json:"owner"`
+	CostCenter string   `json:"costCenter,omitempty"`
+	Profile    string   `json:"profile,omitempty"`
+	Parent     string   `json:"parent,omitempty"`
+	State      string   `json:"state"`
+	Phase      string   `json:"phase,omitempty"`
+	Namespaces []string `json:"namespaces"`
+	// MonthlyCost is the cost estimate of Status.Cost, in Currency
+	MonthlyCost string      `json:"monthlyCost,omitempty"`
+	Currency    string      `json:"currency,omitempty"`
+	CreatedAt   metav1.Time `json:"createdAt"`
+}
+
+// TenantList is the response of GET /api/v1/tenants
+type TenantList struct {
+	Items []TenantSummary `json:"items"`
+}
+
+// TenantDetail is the response of GET /api/v1/tenants/NAME
+type TenantDetail struct {
+	TenantSummary
+	Contacts   map[string]string            `json:"contacts,omitempty"`
+	Quota      platformv1alpha1.TenantQuota `json:"quota"`
+	Message    string                       `json:"message,omitempty"`
+	Conditions []metav1.Condition           `json:"conditions,omitempty"`
+	Cost       *platformv1alpha1.TenantCost `json:"cost,omitempty"`
+}
+
+// TenantUsage is the response of GET /api/v1/tenants/NAME/usage
+type TenantUsage struct {
+	Name       string           `json:"name"`
+	Namespaces []NamespaceUsage `json:"namespaces"`
+	// Cost is the tenant's cost estimate and accrued billing periods
+	Cost *platformv1alpha1.TenantCost `json:"cost,omitempty"`
+}
+
+// NamespaceUsage is the usage of one tenant namespace's ResourceQuota,
+// by resource; empty until the operator created it
+type NamespaceUsage struct {
+	Namespace string                   `json:"namespace"`
+	Resources map[string]ResourceUsage `json:"resources,omitempty"`
+}
+
+// ResourceUsage is the used and hard amounts of one quota resource
+type ResourceUsage struct {
+	Used string `json:"used"`
+	Hard string `json:"hard"`
+}
+
+// OnboardingRequest is the body of POST /api/v1/tenants: the fields the
+// portal's onboarding form sets. The webhooks default the rest of the
+// spec, from the profile if one is given.
+type OnboardingRequest struct {
+	Name       string                       `json:"name"`
+	Owner      string                       `json:"owner"`
+	CostCenter string                       `json:"costCenter,omitempty"`
+	Profile    string                       `json:"profile,omitempty"`
+	Parent     string                       `json:"parent,omitempty"`
+	Namespaces []string                     `json:"namespaces,omitempty"`
+	Contacts   map[string]string            `json:"contacts,omitempty"`
+	Quota      platformv1alpha1.TenantQuota `json:"quota,omitempty"`
+	// TTL deletes the Tenant this long after its creation, e.g. "168h",
+	// for sandboxes
+	TTL string `json:"ttl,omitempty"`
+}
+
+// handleTenants serves GET and POST /api/v1/tenants
+func (s *Server) handleTenants(w http.ResponseWriter, r *http.Request, identity *Identity) {
+	switch r.Method {
+	case http.MethodGet:
+		s.listTenants(w, r)
+	case http.MethodPost:
+		s.onboardTenant(w, r, identity)
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleTenant serves GET /api/v1/tenants/NAME and
+// GET /api/v1/tenants/NAME/usage
+func (s *Server) handleTenant(w http.ResponseWriter, r *http.Request, identity *Identity) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", "GET")
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	name, sub, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/api/v1/tenants/"), "/")
+	if name == "" || (sub != "" && sub != "usage") {
+		http.NotFound(w, r)
+		return
+	}
+
+	tenant := &platformv1alpha1.Tenant{}
+	if err := s.Client.Get(r.Context(), client.ObjectKey{Name: name}, tenant); err != nil {
+		writeError(w, err)
+		return
+	}
+	if sub == "" {
+		writeJSON(w, http.StatusOK, tenantDetail(tenant))
+		return
+	}
+
+	usage := TenantUsage{Name: tenant.Name, Namespaces: []NamespaceUsage{}, Cost: tenant.Status.Cost}
+	for _, namespace := range statusNamespaces(tenant) {
+		ns := NamespaceUsage{Namespace: namespace}
+		rq := &corev1.ResourceQuota{}
+		err := s.Client.Get(r.Context(), client.ObjectKey{Namespace: namespace, Name: tenantQuotaName}, rq)
+		switch {
+		case apierrors.IsNotFound(err):
+		case err != nil:
+			writeError(w, err)
+			return
+		default:
+			ns.Resources = map[string]ResourceUsage{}
+			for resource, hard := range rq.Status.Hard {
+				used := rq.Status.Used[resource]
+				ns.Resources[string(resource)] = ResourceUsage{Used: used.String(), Hard: hard.String()}
+			}
+		}
+		usage.Namespaces = append(usage.Namespaces, ns)
+	}
+	writeJSON(w, http.StatusOK, usage)
+}
+
+// listTenants serves the catalog, sorted by name and optionally filtered
+// by the owner and costCenter query parameters
+func (s *Server) listTenants(w http.ResponseWriter, r *http.Request) {
+	list := &platformv1alpha1.TenantList{}
+	if err := s.Client.List(r.Context(), list); err != nil {
+		writeError(w, err)
+		return
+	}
+	owner, costCenter := r.URL.Query().Get("owner"), r.URL.Query().Get("costCenter")
+
+	response := TenantList{Items: []TenantSummary{}}
+	for i := range list.Items {
+		tenant := &list.Items[i]
+		if (owner != "" && tenant.Spec.Owner != owner) || (costCenter != "" && tenant.Spec.CostCenter != costCenter) {
+			continue
+		}
+		response.Items = append(response.Items, tenantSummary(tenant))
+	}
+	sort.Slice(response.Items, func(i, j int) bool {
+		return response.Items[i].Name < response.Items[j].Name
+	})
+	writeJSON(w, http.StatusOK, response)
+}
+
+// onboardTenant creates the Tenant of an OnboardingRequest as the caller.
+// With ?dryRun=true it only checks that the Tenant would be admitted.
+func (s *Server) onboardTenant(w http.ResponseWriter, r *http.Request, identity *Identity) {
+	var request OnboardingRequest
+	if !decodeBody(w, r, &request) {
+		return
+	}
+	spec, err := request.spec()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	tenant := &platformv1alpha1.Tenant{ObjectMeta: metav1.ObjectMeta{Name: request.Name}, Spec: spec}
+	dryRun := r.URL.Query().Get("dryRun") == "true"
+	var opts []client.CreateOption
+	if dryRun {
+		opts = append(opts, client.DryRunAll)
+	}
+
+	c, err := s.clientFor(identity)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	// Admission errors, such as an invalid quota or an owner over its
+	// tenant limit, come back with the webhook's message
+	if err := c.Create(r.Context(), tenant, opts...); err != nil {
+		writeError(w, err)
+		return
+	}
+	if dryRun {
+		writeJSON(w, http.StatusOK, tenantDetail(tenant))
+		return
+	}
+	ctrl.Log.WithName("platform-api").Info("Onboarded tenant", "tenant", tenant.Name, "owner", tenant.Spec.Owner, "user", identity.Username)
+	w.Header().Set("Location", "/api/v1/tenants/"+tenant.Name)
+	writeJSON(w, http.StatusCreated, tenantDetail(tenant))
+}
+
+// clientFor returns a client impersonating identity, with those of its
+// groups in ImpersonateGroups
+func (s *Server) clientFor(identity *Identity) (client.Client, error) {
+	var groups []string
+	for _, group := range identity.Groups {
+		if s.ImpersonateGroups[group] {
+			groups = append(groups, group)
+		}
+	}
+	httpClient := &http.Client{
+		Transport: transport.NewImpersonatingRoundTripper(transport.ImpersonationConfig{UserName: identity.Username, Groups: groups}, s.HTTPClient.Transport),
+		Timeout:   s.HTTPClient.Timeout,
+	}
+	return client.New(s.Config, client.Options{HTTPClient: httpClient, Scheme: s.Client.Scheme(), Mapper: s.Client.RESTMapper()})
+}
+
+// decodeBody decodes the JSON request body into out, answering 400 and
+// returning false if it isn't valid
+func decodeBody(w http.ResponseWriter, r *http.Request, out interface{}) bool {
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestBody))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(out); err != nil {
+		http.Error(w, fmt.Sprintf("invalid request body: %v", err), http.StatusBadRequest)
+		return false
+	}
+	return true
+}
+
+// spec returns the Tenant spec of the onboarding request
+func (o *OnboardingRequest) spec() (platformv1alpha1.TenantSpec, error) {
+	if o.Name == "" || o.Owner == "" {
+		return platformv1alpha1.TenantSpec{}, fmt.Errorf("name and owner are required")
+	}
+	spec := platformv1alpha1.TenantSpec{
+		Owner:      o.Owner,
+		CostCenter: o.CostCenter,
+		Profile:    o.Profile,
+		Parent:     o.Parent,
+		Namespaces: o.Namespaces,
+		Contacts:   o.Contacts,
+		Quota:      o.Quota,
+	}
+	if o.TTL != "" {
+		ttl, err := time.ParseDuration(o.TTL)
+		if err != nil || ttl <= 0 {
+			return platformv1alpha1.TenantSpec{}, fmt.Errorf("ttl must be a positive duration, such as 168h, not %q", o.TTL)
+		}
+		spec.TTL = &metav1.Duration{Duration: ttl}
+	}
+	return spec, nil
+}
+
+// tenantSummary returns the catalog view of tenant
+func tenantSummary(tenant *platformv1alpha1.Tenant) TenantSummary {
+	summary := TenantSummary{
+		Name:       tenant.Name,
+		Owner:      tenant.Spec.Owner,
+		CostCenter: tenant.Spec.CostCenter,
+		Profile:    tenant.Spec.Profile,
+		Parent:     tenant.Spec.Parent,
+		State:      string(tenant.Spec.State),
+		Phase:      tenant.Status.Phase,
+		Namespaces: statusNamespaces(tenant),
+		CreatedAt:  tenant.CreationTimestamp,
+	}
+	if summary.State == "" {
+		summary.State = string(platformv1alpha1.TenantStateActive)
+	}
+	if cost := tenant.Status.Cost; cost != nil {
+		summary.MonthlyCost = cost.MonthlyEstimate
+		summary.Currency = cost.Currency
+	}
+	return summary
+}
+
+// tenantDetail returns the detailed view of tenant
+func tenantDetail(tenant *platformv1alpha1.Tenant) TenantDetail {
+	return TenantDetail{
+		TenantSummary: tenantSummary(tenant),
+		Contacts:      tenant.Spec.Contacts,
+		Quota:         tenant.Spec.Quota,
+		Message:       tenant.Status.Message,
+		Conditions:    tenant.Status.Conditions,
+		Cost:          tenant.Status.Cost,
+	}
+}
+
+// statusNamespaces returns the namespaces the operator last reported for
+// tenant, or the one named after it before its first reconcile
+func statusNamespaces(tenant *platformv1alpha1.Tenant) []string {
+	if len(tenant.Status.Namespaces) == 0 {
+		return []string{tenant.Name}
+	}
+	namespaces := make([]string, 0, len(tenant.Status.Namespaces))
+	for _, ns := range tenant.Status.Namespaces {
+		namespaces = append(namespaces, ns.Name)
+	}
+	return namespaces
+}
+
+// writeJSON writes body as the JSON response with status
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+// writeError writes err as the response, with the status the API server
+// answered it with, if any, even when wrapped
+func writeError(w http.ResponseWriter, err error) {
+	var status apierrors.APIStatus
+	if errors.As(err, &status) {
+		code := int(status.Status().Code)
+		if code < 400 {
+			code = http.StatusInternalServerError
+		}
+		http.Error(w, status.Status().Message, code)
+		return
+	}
+	ctrl.Log.WithName("platform-api").Error(err, "Request failed")
+	http.Error(w, "Internal server error", http.StatusInternalServerError)
+}
@@ -0,0 +1,136 @@
+// Tenant conditions
+// Standard metav1.Conditions alongside the status flags, so kstatus, Argo CD
+// health checks and `kubectl wait --for=condition=Ready` work on Tenants
+
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	platformv1alpha1 "github.com/xyz-company/tenant-operator/api/v1alpha1"
+)
+
+// Condition types
+const (
+	// ConditionReady summarizes the other resource conditions
+	ConditionReady              = "Ready"
+	ConditionQuotaReady         = "QuotaReady"
+	ConditionNetworkPolicyReady = "NetworkPolicyReady"
+	ConditionRBACReady          = "RBACReady"
+	// ConditionQuotaNearLimit is True while quota usage is at or above the
+	// soft threshold (see reconcileQuotaUsage)
+	ConditionQuotaNearLimit = "QuotaNearLimit"
+	// ConditionClustersReady is True while the tenant is reconciled in every
+	// target cluster it is placed in (--multi-cluster)
+	ConditionClustersReady = "ClustersReady"
+)
+
+// Condition reasons
+const (
+	ReasonReconciled      = "Reconciled"
+	ReasonProvisioning    = "Provisioning"
+	ReasonApplied         = "Applied"
+	ReasonReconcileFailed = "ReconcileFailed"
+	ReasonSuspended       = "Suspended"
+	ReasonExpired         = "Expired"
+	ReasonAboveThreshold  = "AboveSoftThreshold"
+	ReasonBelowThreshold  = "BelowSoftThreshold"
+	ReasonClusterFailed   = "ClusterFailed"
+)
+
+// setConditions derives the Ready and per-resource conditions from the
+// status flags, Phase and Message. meta.SetStatusCondition only moves
+// LastTransitionTime when a condition's status actually changes.
+func setConditions(tenant *platformv1alpha1.Tenant) {
+	status := &tenant.Status
+
+	resources := []struct {
+		condition string
+		applied   bool
+		what      string
+	}{
+		{ConditionQuotaReady, status.QuotaApplied, "ResourceQuota and LimitRange"},
+		{ConditionNetworkPolicyReady, status.NetworkPolicyApplied, "NetworkPolicies"},
+		{ConditionRBACReady, status.RBACApplied, "RoleBinding"},
+	}
+	for _, res := range resources {
+		condition := metav1.Condition{
+			Type:               res.condition,
+			Status:             metav1.ConditionTrue,
+			Reason:             ReasonApplied,
+			Message:            res.what + " applied",
+			ObservedGeneration: tenant.Generation,
+		}
+		if !res.applied {
+			condition.Status = metav1.ConditionFalse
+			condition.Reason = ReasonProvisioning
+			condition.Message = res.what + " not applied yet"
+			if status.Phase == TenantPhaseError {
+				condition.Reason = ReasonReconcileFailed
+				condition.Message = status.Message
+			}
+		}
+		meta.SetStatusCondition(&status.Conditions, condition)
+	}
+
+	setClustersCondition(tenant)
+
+	ready := metav1.Condition{
+		Type:               ConditionReady,
+		Status:             metav1.ConditionFalse,
+		ObservedGeneration: tenant.Generation,
+	}
+	switch status.Phase {
+	case TenantPhaseReady:
+		ready.Status = metav1.ConditionTrue
+		ready.Reason = ReasonReconciled
+		ready.Message = "All tenant resources are applied"
+	case TenantPhaseError:
+		ready.Reason = ReasonReconcileFailed
+		ready.Message = status.Message
+	case TenantPhaseExpired:
+		ready.Reason = ReasonExpired
+		ready.Message = status.Message
+	case TenantPhaseSuspended:
+		ready.Reason = ReasonSuspended
+		ready.Message = "Tenant is suspended; its workloads are scaled to zero"
+	default:
+		ready.Reason = ReasonProvisioning
+		ready.Message = "Tenant resources are being applied"
+	}
+	meta.SetStatusCondition(&status.Conditions, ready)
+}
+
+// setClustersCondition summarizes status.clusters in ClustersReady, naming
+// the clusters that aren't ready. Tenants in no target cluster have none.
+func setClustersCondition(tenant *platformv1alpha1.Tenant) {
+	status := &tenant.Status
+	if len(status.Clusters) == 0 {
+		meta.RemoveStatusCondition(&status.Conditions, ConditionClustersReady)
+		return
+	}
+
+	var failed []string
+	for _, cluster := range status.Clusters {
+		if !cluster.Ready {
+			failed = append(failed, fmt.Sprintf("%s (%s)", cluster.Name, cluster.Reason))
+		}
+	}
+	condition := metav1.Condition{
+		Type:               ConditionClustersReady,
+		Status:             metav1.ConditionTrue,
+		Reason:             ReasonReconciled,
+		Message:            fmt.Sprintf("Reconciled in %d target clusters", len(status.Clusters)),
+		ObservedGeneration: tenant.Generation,
+	}
+	if len(failed) > 0 {
+		condition.Status = metav1.ConditionFalse
+		condition.Reason = ReasonClusterFailed
+		condition.Message = "Not reconciled in " + strings.Join(failed, ", ")
+	}
+	meta.SetStatusCondition(&status.Conditions, condition)
+}
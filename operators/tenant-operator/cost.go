@@ -0,0 +1,247 @@
+// Cost allocation
+// With --cost-pricing, every tenant's resource requests, the requests.cpu,
+// requests.memory and requests.storage its tenant-quota ResourceQuotas
+// count, are priced each --cost-interval. The estimate of a month at those
+// requests is written to Status.Cost and exported by cost center, so
+// platform spend can be charged back. Prices come from a static rate card
+// or are derived from a cloud billing export, see billing.go. Tenants with
+// a budget are checked against it, see budget.go.
+//
+// Each estimate also accrues what the previous one cost since it was made
+// to its billing period, the calendar month in UTC, for the chargeback
+// report, see chargeback.go.
+
+package main
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+
+	platformv1alpha1 "github.com/xyz-company/tenant-operator/api/v1alpha1"
+)
+
+// hoursPerMonth is the average month, 365 * 24 / 12
+const hoursPerMonth = 730
+
+// tenantMonthlyCost is the monthly cost estimate of each tenant, by cost
+// center
+var tenantMonthlyCost = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+	Name: "tenant_operator_tenant_monthly_cost",
+	Help: "Estimated monthly cost of the Tenant at its current resource requests.",
+}, []string{"tenant", "cost_center", "currency"})
+
+// CostRates are the unit prices resource requests are costed at
+type CostRates struct {
+	CPUCoreHour     float64
+	MemoryGiBHour   float64
+	StorageGiBMonth float64
+	Currency        string
+}
+
+// CostPricing supplies the current CostRates
+type CostPricing interface {
+	// Name identifies the price source in Status.Cost
+	Name() string
+	Rates(ctx context.Context) (CostRates, error)
+}
+
+// pricedResources are the requests estimates price
+var pricedResources = []corev1.ResourceName{corev1.ResourceRequestsCPU, corev1.ResourceRequestsMemory, corev1.ResourceRequestsStorage}
+
+// CostReconciler estimates the monthly cost of every Tenant
+type CostReconciler struct {
+	client.Client
+	Pricing CostPricing
+	// Interval is how often each tenant is costed (--cost-interval)
+	Interval time.Duration
+	Recorder record.EventRecorder
+	// Notifier, when set, notifies the contacts of tenants with
+	// Spec.Budget.Notify of each alert threshold reached
+	Notifier *BudgetNotifier
+}
+
+// Reconcile prices one tenant's requests and records the estimate
+func (r *CostReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := ctrl.LoggerFrom(ctx)
+
+	tenant := &platformv1alpha1.Tenant{}
+	if err := r.Get(ctx, req.NamespacedName, tenant); err != nil {
+		if errors.IsNotFound(err) {
+			tenantMonthlyCost.DeletePartialMatch(prometheus.Labels{"tenant": req.Name})
+		}
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	if !tenant.DeletionTimestamp.IsZero() {
+		return ctrl.Result{}, nil
+	}
+
+	rates, err := r.Pricing.Rates(ctx)
+	if err != nil {
+		log.Error(err, "Failed to read prices", "pricing", r.Pricing.Name())
+		return ctrl.Result{}, err
+	}
+	requests, err := r.tenantRequests(ctx, tenant)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	cost := estimateCost(requests, rates)
+	cost.Pricing = r.Pricing.Name()
+	now := metav1.Now()
+	cost.Period, cost.PreviousPeriod = accrueCost(tenant.Status.Cost, now.Time)
+
+	total, _ := strconv.ParseFloat(cost.MonthlyEstimate, 64)
+	tenantMonthlyCost.DeletePartialMatch(prometheus.Labels{"tenant": tenant.Name})
+	tenantMonthlyCost.WithLabelValues(tenant.Name, tenant.Spec.CostCenter, cost.Currency).Set(total)
+
+	patch := client.MergeFrom(tenant.DeepCopy())
+	if err := r.reconcileBudget(ctx, tenant, &cost); err != nil {
+		log.Error(err, "Failed to suspend tenant over budget")
+		return ctrl.Result{}, err
+	}
+	cost.LastEstimated = now
+	tenant.Status.Cost = &cost
+	if err := r.Status().Patch(ctx, tenant, patch); err != nil {
+		log.Error(err, "Failed to record cost estimate")
+		return ctrl.Result{}, err
+	}
+	log.V(1).Info("Tenant costed", "monthlyEstimate", cost.MonthlyEstimate, "currency", cost.Currency)
+
+	return ctrl.Result{RequeueAfter: r.Interval}, nil
+}
+
+// tenantRequests sums the requests counted by the tenant-quota
+// ResourceQuotas of the tenant's namespaces
+func (r *CostReconciler) tenantRequests(ctx context.Context, tenant *platformv1alpha1.Tenant) (corev1.ResourceList, error) {
+	requests := corev1.ResourceList{}
+	for _, namespace := range knownNamespaces(tenant) {
+		quota := &corev1.ResourceQuota{}
+		err := r.Get(ctx, types.NamespacedName{Namespace: namespace, Name: "tenant-quota"}, quota)
+		if errors.IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			return requests, err
+		}
+		for _, name := range pricedResources {
+			used, ok := quota.Status.Used[name]
+			if !ok {
+				continue
+			}
+			total := requests[name]
+			total.Add(used)
+			requests[name] = total
+		}
+	}
+	return requests, nil
+}
+
+// requestAmounts returns the cores, GiB of memory and GiB of storage of
+// requests
+func requestAmounts(requests corev1.ResourceList) (cores, memoryGiB, storageGiB float64) {
+	cpu := requests[corev1.ResourceRequestsCPU]
+	memory := requests[corev1.ResourceRequestsMemory]
+	storage := requests[corev1.ResourceRequestsStorage]
+	return float64(cpu.MilliValue()) / 1000, float64(memory.Value()) / (1 << 30), float64(storage.Value()) / (1 << 30)
+}
+
+// estimateCost prices a month of requests at rates
+func estimateCost(requests corev1.ResourceList, rates CostRates) platformv1alpha1.TenantCost {
+	cores, memoryGiB, storageGiB := requestAmounts(requests)
+	cpu := cores * rates.CPUCoreHour * hoursPerMonth
+	memory := memoryGiB * rates.MemoryGiBHour * hoursPerMonth
+	storage := storageGiB * rates.StorageGiBMonth
+	return platformv1alpha1.TenantCost{
+		MonthlyEstimate: formatAmount(cpu + memory + storage),
+		Currency:        rates.Currency,
+		CPU:             formatAmount(cpu),
+		Memory:          formatAmount(memory),
+		Storage:         formatAmount(storage),
+		Requests:        requests,
+	}
+}
+
+// billingMonth is the billing period t falls in
+func billingMonth(t time.Time) string {
+	return t.UTC().Format("2006-01")
+}
+
+// accrueCost adds what the previous estimate cost from when it was made
+// until now to its billing periods, returning the current period and the
+// one before. An interval spanning the start of a month is split at it.
+func accrueCost(previous *platformv1alpha1.TenantCost, now time.Time) (period, previousPeriod *platformv1alpha1.TenantCostPeriod) {
+	month := billingMonth(now)
+	if previous == nil || previous.LastEstimated.IsZero() {
+		period = &platformv1alpha1.TenantCostPeriod{Month: month}
+		accrue(period, nil, 0)
+		return period, nil
+	}
+	period, previousPeriod = previous.Period.DeepCopy(), previous.PreviousPeriod.DeepCopy()
+	from := previous.LastEstimated.Time
+	if period == nil || period.Month != month {
+		monthStart := time.Date(now.UTC().Year(), now.UTC().Month(), 1, 0, 0, 0, 0, time.UTC)
+		if period != nil {
+			if from.Before(monthStart) {
+				accrue(period, previous, monthStart.Sub(from))
+			}
+			previousPeriod = period
+		}
+		period = &platformv1alpha1.TenantCostPeriod{Month: month}
+		if from.Before(monthStart) {
+			from = monthStart
+		}
+	}
+	accrue(period, previous, now.Sub(from))
+	return period, previousPeriod
+}
+
+// accrue adds elapsed time at cost's requests and estimate to period. A
+// nil cost adds nothing.
+func accrue(period *platformv1alpha1.TenantCostPeriod, cost *platformv1alpha1.TenantCost, elapsed time.Duration) {
+	var monthly, cores, memoryGiB, storageGiB float64
+	if cost != nil && elapsed > 0 {
+		monthly, _ = strconv.ParseFloat(cost.MonthlyEstimate, 64)
+		cores, memoryGiB, storageGiB = requestAmounts(cost.Requests)
+	}
+	hours := elapsed.Hours()
+	add := func(total *string, amount float64) {
+		current, _ := strconv.ParseFloat(*total, 64)
+		*total = formatAccrued(current + amount)
+	}
+	add(&period.Cost, monthly*hours/hoursPerMonth)
+	add(&period.CPUCoreHours, cores*hours)
+	add(&period.MemoryGiBHours, memoryGiB*hours)
+	add(&period.StorageGiBMonths, storageGiB*hours/hoursPerMonth)
+}
+
+// formatAmount renders an amount of money with two decimals
+func formatAmount(amount float64) string {
+	return strconv.FormatFloat(amount, 'f', 2, 64)
+}
+
+// formatAccrued renders an accrued amount with four decimals, so rounding
+// doesn't add up over the estimates of a period
+func formatAccrued(amount float64) string {
+	return strconv.FormatFloat(amount, 'f', 4, 64)
+}
+
+// SetupWithManager sets up the controller with the Manager. Only new
+// Tenants and spec changes trigger an estimate out of turn; the next one is
+// scheduled by the last.
+func (r *CostReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&platformv1alpha1.Tenant{}, builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		Named("cost").
+		Complete(r)
+}
@@ -0,0 +1,424 @@
+// Dedicated nodes
+// Spec.DedicatedNodes reserves a node pool for a tenant. The operator labels
+// and taints the pool's nodes platform.xyz.com/dedicated-tenant=<tenant>,
+// and the pod mutating webhook gives the tenant's pods the matching
+// toleration and node selector, so they run on the pool and nothing else
+// does. Pods tolerating another tenant's taint are denied. A ResourceQuota,
+// tenant-dedicated-nodes, caps the tenant's requests.cpu at what the pool
+// holds.
+//
+// The pool is either a cloud node group the platform provisions, selected
+// by NodeSelector, or a Cluster API MachineDeployment the operator applies
+// in --cluster-api-namespace. Nodes join untainted and are tainted once the
+// operator sees them; node groups that register their nodes with the taint
+// close that window.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	platformv1alpha1 "github.com/xyz-company/tenant-operator/api/v1alpha1"
+)
+
+const (
+	// dedicatedTenantLabel is the label and taint key of dedicated nodes,
+	// its value the tenant's name
+	dedicatedTenantLabel = "platform.xyz.com/dedicated-tenant"
+	// machineDedicatedTenantLabel is set on the machines of a tenant's
+	// MachineDeployment; Cluster API syncs node.cluster.x-k8s.io labels to
+	// their Nodes, where it selects the pool by default
+	machineDedicatedTenantLabel = "node.cluster.x-k8s.io/dedicated-tenant"
+	// dedicatedNodesQuota names the ResourceQuota capping requests.cpu on
+	// the pool
+	dedicatedNodesQuota = "tenant-dedicated-nodes"
+	// dedicatedNodesRetryInterval is how often a tenant is looked at while
+	// its pool has no nodes
+	dedicatedNodesRetryInterval = time.Minute
+)
+
+var machineDeploymentGVK = schema.GroupVersionKind{Group: "cluster.x-k8s.io", Version: "v1beta1", Kind: "MachineDeployment"}
+
+// validateDedicatedNodes rejects pools selecting no nodes, invalid node
+// selectors or CPU, and incomplete MachineDeployments
+func validateDedicatedNodes(nodes *platformv1alpha1.TenantDedicatedNodes) error {
+	if nodes == nil {
+		return nil
+	}
+	if len(nodes.NodeSelector) == 0 && nodes.MachineDeployment == nil {
+		return fmt.Errorf("dedicatedNodes needs a nodeSelector or a machineDeployment")
+	}
+	for key, value := range nodes.NodeSelector {
+		if errs := validation.IsQualifiedName(key); len(errs) > 0 {
+			return fmt.Errorf("dedicatedNodes.nodeSelector key %q: %s", key, errs[0])
+		}
+		if errs := validation.IsValidLabelValue(value); len(errs) > 0 {
+			return fmt.Errorf("dedicatedNodes.nodeSelector %s value %q: %s", key, value, errs[0])
+		}
+		if key == dedicatedTenantLabel {
+			return fmt.Errorf("dedicatedNodes.nodeSelector must not select on %s, which the operator sets", dedicatedTenantLabel)
+		}
+	}
+	if nodes.CPU != "" {
+		cpu, err := resource.ParseQuantity(nodes.CPU)
+		if err != nil {
+			return fmt.Errorf("dedicatedNodes.cpu %q is not a valid quantity", nodes.CPU)
+		}
+		if cpu.Sign() <= 0 {
+			return fmt.Errorf("dedicatedNodes.cpu must be positive, got %s", nodes.CPU)
+		}
+	}
+	if md := nodes.MachineDeployment; md != nil {
+		switch {
+		case md.ClusterName == "":
+			return fmt.Errorf("dedicatedNodes.machineDeployment.clusterName is required")
+		case md.Replicas < 0:
+			return fmt.Errorf("dedicatedNodes.machineDeployment.replicas must not be negative, got %d", md.Replicas)
+		case !strings.HasPrefix(md.Version, "v"):
+			return fmt.Errorf("dedicatedNodes.machineDeployment.version %q must be a Kubernetes version such as v1.28.3", md.Version)
+		}
+		for field, ref := range map[string]platformv1alpha1.TenantTemplateRef{"infrastructureRef": md.InfrastructureRef, "bootstrapRef": md.BootstrapRef} {
+			if ref.APIVersion == "" || ref.Kind == "" || ref.Name == "" {
+				return fmt.Errorf("dedicatedNodes.machineDeployment.%s needs apiVersion, kind and name", field)
+			}
+		}
+	}
+	return nil
+}
+
+// dedicatedNodeSelector returns the labels selecting the nodes of tenant's
+// pool
+func dedicatedNodeSelector(tenant *platformv1alpha1.Tenant) map[string]string {
+	if selector := tenant.Spec.DedicatedNodes.NodeSelector; len(selector) > 0 {
+		return selector
+	}
+	return map[string]string{machineDedicatedTenantLabel: tenant.Name}
+}
+
+// machineDeployment returns the MachineDeployment provisioning tenant's
+// pool in namespace
+func machineDeployment(tenant *platformv1alpha1.Tenant, namespace string) *unstructured.Unstructured {
+	md := tenant.Spec.DedicatedNodes.MachineDeployment
+	machineLabels := map[string]interface{}{
+		"cluster.x-k8s.io/cluster-name": md.ClusterName,
+		dedicatedTenantLabel:            tenant.Name,
+		machineDedicatedTenantLabel:     tenant.Name,
+	}
+	ref := func(ref platformv1alpha1.TenantTemplateRef) map[string]interface{} {
+		return map[string]interface{}{"apiVersion": ref.APIVersion, "kind": ref.Kind, "name": ref.Name}
+	}
+	obj := &unstructured.Unstructured{Object: map[string]interface{}{
+		"spec": map[string]interface{}{
+			"clusterName": md.ClusterName,
+			"replicas":    int64(md.Replicas),
+			"selector": map[string]interface{}{
+				"matchLabels": map[string]interface{}{
+					"cluster.x-k8s.io/cluster-name": md.ClusterName,
+					dedicatedTenantLabel:            tenant.Name,
+				},
+			},
+			"template": map[string]interface{}{
+				"metadata": map[string]interface{}{"labels": machineLabels},
+				"spec": map[string]interface{}{
+					"clusterName":       md.ClusterName,
+					"version":           md.Version,
+					"bootstrap":         map[string]interface{}{"configRef": ref(md.BootstrapRef)},
+					"infrastructureRef": ref(md.InfrastructureRef),
+				},
+			},
+		},
+	}}
+	obj.SetGroupVersionKind(machineDeploymentGVK)
+	obj.SetNamespace(namespace)
+	obj.SetName("tenant-" + tenant.Name)
+	obj.SetLabels(map[string]string{"cluster.x-k8s.io/cluster-name": md.ClusterName})
+	return obj
+}
+
+// reconcileDedicatedNodes provisions tenant's MachineDeployment, labels and
+// taints the nodes of its pool, releases the nodes that left it and caps
+// the requests.cpu of namespaces at the pool's CPU. Tenants without
+// Spec.DedicatedNodes release all their nodes. It returns when to look
+// again for the nodes of a pool that has none yet.
+func (r *TenantReconciler) reconcileDedicatedNodes(ctx context.Context, tenant *platformv1alpha1.Tenant, namespaces []string) (time.Duration, error) {
+	log := ctrl.LoggerFrom(ctx)
+	dedicated := tenant.Spec.DedicatedNodes
+
+	installed, err := r.kindInstalled(machineDeploymentGVK)
+	if err != nil {
+		return 0, err
+	}
+	if installed {
+		var desired []*unstructured.Unstructured
+		if dedicated != nil && dedicated.MachineDeployment != nil {
+			desired = append(desired, machineDeployment(tenant, r.ClusterAPINamespace))
+		}
+		stale, err := staleObjects(ctx, r.Client, tenant, []string{r.ClusterAPINamespace}, machineDeploymentGVK, desired)
+		if err != nil {
+			return 0, err
+		}
+		for _, obj := range stale {
+			if err := r.deleteIfControlled(ctx, tenant, obj); err != nil {
+				return 0, err
+			}
+		}
+		for _, obj := range desired {
+			if err := r.applyOrAdopt(ctx, tenant, obj); err != nil {
+				return 0, err
+			}
+		}
+	} else if dedicated != nil && dedicated.MachineDeployment != nil {
+		log.Info("CRD not installed, skipping MachineDeployment", "kind", machineDeploymentGVK.Kind)
+	}
+
+	if dedicated == nil {
+		if err := r.releaseDedicatedNodes(ctx, tenant, nil); err != nil {
+			return 0, err
+		}
+		for _, namespace := range namespaces {
+			quota := &corev1.ResourceQuota{ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: dedicatedNodesQuota}}
+			if err := r.deleteIfControlled(ctx, tenant, quota); err != nil {
+				return 0, err
+			}
+		}
+		tenant.Status.DedicatedNodes = nil
+		return 0, nil
+	}
+
+	pool := &corev1.NodeList{}
+	if err := r.List(ctx, pool, client.MatchingLabels(dedicatedNodeSelector(tenant))); err != nil {
+		return 0, err
+	}
+	members := map[string]bool{}
+	allocatable := resource.Quantity{Format: resource.DecimalSI}
+	var names []string
+	for i := range pool.Items {
+		node := &pool.Items[i]
+		if owner, ok := node.Labels[dedicatedTenantLabel]; ok && owner != tenant.Name {
+			r.Recorder.Eventf(tenant, corev1.EventTypeWarning, "DedicatedNodeConflict", "Node %s is dedicated to tenant %s", node.Name, owner)
+			continue
+		}
+		if err := r.dedicateNode(ctx, tenant, node); err != nil {
+			return 0, err
+		}
+		members[node.Name] = true
+		names = append(names, node.Name)
+		allocatable.Add(node.Status.Allocatable[corev1.ResourceCPU])
+	}
+	if err := r.releaseDedicatedNodes(ctx, tenant, members); err != nil {
+		return 0, err
+	}
+	sort.Strings(names)
+	tenant.Status.DedicatedNodes = &platformv1alpha1.DedicatedNodesStatus{Nodes: names, AllocatableCPU: allocatable.String()}
+
+	// A pool without nodes admits no pods until it has some
+	cpu := allocatable
+	if dedicated.CPU != "" {
+		cpu = resource.MustParse(dedicated.CPU)
+	}
+	for _, namespace := range namespaces {
+		if err := r.applyOrAdopt(ctx, tenant, dedicatedNodesResourceQuota(tenant, namespace, cpu)); err != nil {
+			return 0, err
+		}
+	}
+
+	if len(names) == 0 {
+		log.Info("Waiting for the nodes of the dedicated node pool", "nodeSelector", labels.Set(dedicatedNodeSelector(tenant)).String())
+		return dedicatedNodesRetryInterval, nil
+	}
+	return 0, nil
+}
+
+// dedicatedNodesResourceQuota caps the requests.cpu of one of tenant's
+// namespaces at its Spec.QuotaSplit share of cpu
+func dedicatedNodesResourceQuota(tenant *platformv1alpha1.Tenant, namespace string, cpu resource.Quantity) *corev1.ResourceQuota {
+	if percent, ok := tenant.Spec.QuotaSplit[strings.TrimPrefix(namespace, tenant.Name+"-")]; ok {
+		cpu = *resource.NewMilliQuantity(cpu.MilliValue()*int64(percent)/100, resource.DecimalSI)
+	}
+	return &corev1.ResourceQuota{
+		ObjectMeta: metav1.ObjectMeta{Name: dedicatedNodesQuota, Namespace: namespace},
+		Spec: corev1.ResourceQuotaSpec{
+			Hard: corev1.ResourceList{corev1.ResourceRequestsCPU: cpu},
+		},
+	}
+}
+
+// dedicatedTaint is the taint keeping other tenants' pods off tenant's
+// nodes
+func dedicatedTaint(tenant string) corev1.Taint {
+	return corev1.Taint{Key: dedicatedTenantLabel, Value: tenant, Effect: corev1.TaintEffectNoSchedule}
+}
+
+// dedicateNode labels and taints node for tenant, unless it already is
+func (r *TenantReconciler) dedicateNode(ctx context.Context, tenant *platformv1alpha1.Tenant, node *corev1.Node) error {
+	taint := dedicatedTaint(tenant.Name)
+	tainted := false
+	for _, t := range node.Spec.Taints {
+		tainted = tainted || (t.MatchTaint(&taint) && t.Value == taint.Value)
+	}
+	if node.Labels[dedicatedTenantLabel] == tenant.Name && tainted {
+		return nil
+	}
+
+	patch := client.MergeFrom(node.DeepCopy())
+	if node.Labels == nil {
+		node.Labels = map[string]string{}
+	}
+	node.Labels[dedicatedTenantLabel] = tenant.Name
+	if !tainted {
+		node.Spec.Taints = append(removeTaint(node.Spec.Taints, taint), taint)
+	}
+	if err := r.Patch(ctx, node, patch); err != nil {
+		return err
+	}
+	r.Recorder.Eventf(tenant, corev1.EventTypeNormal, "DedicatedNodeAdded", "Node %s is dedicated to the tenant", node.Name)
+	return nil
+}
+
+// releaseDedicatedNodes removes the label and taint from tenant's nodes
+// other than keep, returning them to the shared pool
+func (r *TenantReconciler) releaseDedicatedNodes(ctx context.Context, tenant *platformv1alpha1.Tenant, keep map[string]bool) error {
+	nodes := &corev1.NodeList{}
+	if err := r.List(ctx, nodes, client.MatchingLabels{dedicatedTenantLabel: tenant.Name}); err != nil {
+		return err
+	}
+	for i := range nodes.Items {
+		node := &nodes.Items[i]
+		if keep[node.Name] {
+			continue
+		}
+		patch := client.MergeFrom(node.DeepCopy())
+		delete(node.Labels, dedicatedTenantLabel)
+		node.Spec.Taints = removeTaint(node.Spec.Taints, dedicatedTaint(tenant.Name))
+		if err := client.IgnoreNotFound(r.Patch(ctx, node, patch)); err != nil {
+			return err
+		}
+		r.Recorder.Eventf(tenant, corev1.EventTypeNormal, "DedicatedNodeReleased", "Node %s returned to the shared pool", node.Name)
+	}
+	return nil
+}
+
+// removeTaint returns taints without those matching taint's key and effect
+func removeTaint(taints []corev1.Taint, taint corev1.Taint) []corev1.Taint {
+	kept := make([]corev1.Taint, 0, len(taints))
+	for _, t := range taints {
+		if !t.MatchTaint(&taint) {
+			kept = append(kept, t)
+		}
+	}
+	return kept
+}
+
+// nodeToTenants maps a node joining, leaving or relabelled to a reconcile
+// of the tenant it is dedicated to and the tenants whose pool selects it
+func (r *TenantReconciler) nodeToTenants(ctx context.Context, obj client.Object) []reconcile.Request {
+	var requests []reconcile.Request
+	owner, dedicated := obj.GetLabels()[dedicatedTenantLabel]
+	if dedicated {
+		requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Name: owner}})
+	}
+	tenants, err := listTenants(ctx, r.Client)
+	if err != nil {
+		ctrl.LoggerFrom(ctx).Error(err, "Failed to list Tenants for node", "node", obj.GetName())
+		return requests
+	}
+	for i := range tenants {
+		tenant := &tenants[i]
+		if tenant.Spec.DedicatedNodes == nil || (dedicated && tenant.Name == owner) {
+			continue
+		}
+		if labels.SelectorFromSet(dedicatedNodeSelector(tenant)).Matches(labels.Set(obj.GetLabels())) {
+			requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Name: tenant.Name}})
+		}
+	}
+	return requests
+}
+
+// PodDedicatedNodesDefaulter mutates pod admission requests in tenant
+// namespaces
+type PodDedicatedNodesDefaulter struct {
+	Client  client.Client
+	Decoder *admission.Decoder
+}
+
+// Handle denies new pods tolerating another tenant's dedicated nodes and
+// pins those of tenants with Spec.DedicatedNodes to their pool
+func (d *PodDedicatedNodesDefaulter) Handle(ctx context.Context, req admission.Request) admission.Response {
+	if req.Operation != admissionv1.Create {
+		return admission.Allowed("")
+	}
+
+	tenant, err := tenantForNamespace(ctx, d.Client, req.Namespace)
+	if err != nil {
+		return admission.Errored(http.StatusInternalServerError, err)
+	}
+	if tenant == nil {
+		return admission.Allowed("")
+	}
+
+	pod := &corev1.Pod{}
+	if err := d.Decoder.Decode(req, pod); err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+	for _, toleration := range pod.Spec.Tolerations {
+		if toleratesOtherTenants(toleration, tenant.Name) {
+			return admission.Denied(fmt.Sprintf("toleration %q %s tolerates the dedicated nodes of other tenants", toleration.Key, toleration.Operator))
+		}
+	}
+	if tenant.Spec.DedicatedNodes == nil {
+		return admission.Allowed("")
+	}
+
+	if pod.Spec.NodeSelector == nil {
+		pod.Spec.NodeSelector = map[string]string{}
+	}
+	pod.Spec.NodeSelector[dedicatedTenantLabel] = tenant.Name
+	pod.Spec.Tolerations = append(pod.Spec.Tolerations, corev1.Toleration{
+		Key:      dedicatedTenantLabel,
+		Operator: corev1.TolerationOpEqual,
+		Value:    tenant.Name,
+		Effect:   corev1.TaintEffectNoSchedule,
+	})
+
+	raw, err := json.Marshal(pod)
+	if err != nil {
+		return admission.Errored(http.StatusInternalServerError, err)
+	}
+	return admission.PatchResponseFromRaw(req.Object.Raw, raw)
+}
+
+// toleratesOtherTenants reports whether toleration lets a pod of tenant
+// onto nodes dedicated to another tenant: it tolerates every taint, or the
+// dedicated taint with any value but tenant
+func toleratesOtherTenants(toleration corev1.Toleration, tenant string) bool {
+	if toleration.Effect != "" && toleration.Effect != corev1.TaintEffectNoSchedule {
+		return false
+	}
+	switch toleration.Key {
+	case "":
+		return toleration.Operator == corev1.TolerationOpExists
+	case dedicatedTenantLabel:
+		return toleration.Operator == corev1.TolerationOpExists || toleration.Value != tenant
+	}
+	return false
+}
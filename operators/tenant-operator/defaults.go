@@ -0,0 +1,83 @@
+// Tenant defaulting
+// The Tenant mutating webhook writes the platform defaults into the spec at
+// admission time, so the stored object shows the configuration the
+// reconciler actually applies
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"unicode"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	platformv1alpha1 "github.com/xyz-company/tenant-operator/api/v1alpha1"
+)
+
+// TenantDefaulter mutates Tenant admission requests
+type TenantDefaulter struct {
+	// ContactEmailDomain is appended to Spec.Owner for the default email
+	// contact. Empty disables contact defaulting.
+	ContactEmailDomain string
+}
+
+// Handle fills in the default quota and contacts and normalizes the cost center
+func (d *TenantDefaulter) Handle(ctx context.Context, req admission.Request) admission.Response {
+	if req.Operation != admissionv1.Create && req.Operation != admissionv1.Update {
+		return admission.Allowed("")
+	}
+
+	tenant := &platformv1alpha1.Tenant{}
+	if err := json.Unmarshal(req.Object.Raw, tenant); err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+
+	d.defaultTenant(tenant)
+
+	raw, err := json.Marshal(tenant)
+	if err != nil {
+		return admission.Errored(http.StatusInternalServerError, err)
+	}
+	return admission.PatchResponseFromRaw(req.Object.Raw, raw)
+}
+
+// defaultTenant applies the platform defaults to tenant's spec
+func (d *TenantDefaulter) defaultTenant(tenant *platformv1alpha1.Tenant) {
+	// Tenants with a profile get their quota from it at reconcile time
+	if tenant.Spec.Profile == "" {
+		tenant.Spec.Quota = effectiveQuota(tenant.Spec.Quota)
+	}
+	tenant.Spec.CostCenter = normalizeCostCenter(tenant.Spec.CostCenter)
+
+	if d.ContactEmailDomain == "" || tenant.Spec.Owner == "" || tenant.Spec.Contacts["email"] != "" {
+		return
+	}
+	email := tenant.Spec.Owner
+	if !strings.Contains(email, "@") {
+		email += "@" + d.ContactEmailDomain
+	}
+	if tenant.Spec.Contacts == nil {
+		tenant.Spec.Contacts = map[string]string{}
+	}
+	tenant.Spec.Contacts["email"] = email
+}
+
+// normalizeCostCenter rewrites a cost center into the CC-<CODE>-<NNN> form
+// used by billing: upper case, hyphen separated, with a CC prefix. For
+// example "cc_ai 001" and "ai-001" both become "CC-AI-001".
+func normalizeCostCenter(costCenter string) string {
+	parts := strings.FieldsFunc(strings.ToUpper(costCenter), func(r rune) bool {
+		return r == '-' || r == '_' || unicode.IsSpace(r)
+	})
+	if len(parts) == 0 {
+		return ""
+	}
+	if parts[0] != "CC" {
+		parts = append([]string{"CC"}, parts...)
+	}
+	return strings.Join(parts, "-")
+}
@@ -0,0 +1,264 @@
+// Tenant DNS records
+// ExternalDNS publishes the records of tenant ingress. The operator gives
+// each tenant Gateway a DNSEndpoint pointing the tenant's domain and its
+// subdomains at the Gateway's address, and records that domain as the
+// tenant's DNS zone in its status. The DNS webhook keeps every other record
+// source in tenant namespaces, DNSEndpoints, the ExternalDNS hostname
+// annotations and Ingress hosts, within the domains the tenant owns. The
+// DNSEndpoint is deleted with the tenant, and ExternalDNS removes the
+// records with it.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	platformv1alpha1 "github.com/xyz-company/tenant-operator/api/v1alpha1"
+)
+
+var dnsEndpointGVK = schema.GroupVersionKind{Group: "externaldns.k8s.io", Version: "v1alpha1", Kind: "DNSEndpoint"}
+
+// dnsAddressRetryInterval is how soon a tenant is requeued while its
+// Gateway has no address to publish
+const dnsAddressRetryInterval = 30 * time.Second
+
+// ExternalDNS annotations naming the records of a Service or Ingress
+var dnsHostnameAnnotations = []string{
+	"external-dns.alpha.kubernetes.io/hostname",
+	"external-dns.alpha.kubernetes.io/internal-hostname",
+}
+
+// reconcileDNS applies the DNSEndpoint of the tenant Gateway in namespace
+// and records the tenant's DNS zone, or deletes the DNSEndpoint and
+// releases the zone once the tenant no longer has Spec.Ingress. It returns
+// when to check again while the Gateway has no address yet, 0 otherwise.
+func (r *TenantReconciler) reconcileDNS(ctx context.Context, tenant *platformv1alpha1.Tenant, namespace string) (time.Duration, error) {
+	log := ctrl.LoggerFrom(ctx)
+	domain := r.Ingress.ingressDomain(tenant)
+
+	for _, gvk := range []schema.GroupVersionKind{dnsEndpointGVK, gatewayGVK} {
+		installed, err := r.kindInstalled(gvk)
+		if err != nil {
+			return 0, err
+		}
+		if !installed {
+			if domain != "" {
+				log.Info("CRD not installed, skipping DNSEndpoint", "namespace", namespace, "kind", gvk.Kind)
+			}
+			tenant.Status.DNSZone = domain
+			return 0, nil
+		}
+	}
+
+	if domain == "" {
+		endpoint := &unstructured.Unstructured{}
+		endpoint.SetGroupVersionKind(dnsEndpointGVK)
+		endpoint.SetNamespace(namespace)
+		endpoint.SetName(tenantGatewayName)
+		if err := r.deleteIfControlled(ctx, tenant, endpoint); err != nil {
+			return 0, err
+		}
+		tenant.Status.DNSZone = ""
+		return 0, nil
+	}
+	tenant.Status.DNSZone = domain
+
+	gateway := &unstructured.Unstructured{}
+	gateway.SetGroupVersionKind(gatewayGVK)
+	err := r.Get(ctx, types.NamespacedName{Namespace: namespace, Name: tenantGatewayName}, gateway)
+	if errors.IsNotFound(err) {
+		return dnsAddressRetryInterval, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	endpoint := gatewayDNSEndpoint(namespace, domain, gateway)
+	if endpoint == nil {
+		log.V(1).Info("Gateway has no address yet, waiting to publish DNS", "namespace", namespace)
+		return dnsAddressRetryInterval, nil
+	}
+	if err := r.applyOrAdopt(ctx, tenant, endpoint); err != nil {
+		return 0, err
+	}
+	log.Info("DNSEndpoint applied", "namespace", namespace, "domain", domain)
+	return 0, nil
+}
+
+// gatewayDNSEndpoint returns the DNSEndpoint pointing domain and
+// *.domain at the addresses in gateway's status, nil while it has none.
+// Hostname addresses get a CNAME for the subdomains only, since the apex
+// of a zone can't be a CNAME.
+func gatewayDNSEndpoint(namespace, domain string, gateway *unstructured.Unstructured) *unstructured.Unstructured {
+	addresses, _, _ := unstructured.NestedSlice(gateway.Object, "status", "addresses")
+	targets := map[string][]interface{}{}
+	for _, address := range addresses {
+		entry, _ := address.(map[string]interface{})
+		value, _, _ := unstructured.NestedString(entry, "value")
+		if value == "" {
+			continue
+		}
+		switch ip := net.ParseIP(value); {
+		case ip == nil:
+			targets["CNAME"] = append(targets["CNAME"], value)
+		case ip.To4() != nil:
+			targets["A"] = append(targets["A"], value)
+		default:
+			targets["AAAA"] = append(targets["AAAA"], value)
+		}
+	}
+	var endpoints []interface{}
+	for _, recordType := range []string{"A", "AAAA", "CNAME"} {
+		if len(targets[recordType]) == 0 {
+			continue
+		}
+		names := []string{domain, "*." + domain}
+		if recordType == "CNAME" {
+			// One CNAME per name, and none beside other records
+			if len(endpoints) > 0 {
+				continue
+			}
+			names = names[1:]
+			targets[recordType] = targets[recordType][:1]
+		}
+		for _, name := range names {
+			endpoints = append(endpoints, map[string]interface{}{
+				"dnsName":    name,
+				"recordType": recordType,
+				"targets":    targets[recordType],
+			})
+		}
+	}
+	if len(endpoints) == 0 {
+		return nil
+	}
+
+	endpoint := &unstructured.Unstructured{Object: map[string]interface{}{
+		"spec": map[string]interface{}{"endpoints": endpoints},
+	}}
+	endpoint.SetGroupVersionKind(dnsEndpointGVK)
+	endpoint.SetNamespace(namespace)
+	endpoint.SetName(tenantGatewayName)
+	return endpoint
+}
+
+// deleteDNSEndpoints deletes the tenant's DNSEndpoints in namespaces, so
+// ExternalDNS removes its records even from namespaces that outlive it
+func (r *TenantReconciler) deleteDNSEndpoints(ctx context.Context, tenant *platformv1alpha1.Tenant, namespaces []string) error {
+	installed, err := r.kindInstalled(dnsEndpointGVK)
+	if err != nil || !installed {
+		return err
+	}
+	for _, namespace := range namespaces {
+		endpoint := &unstructured.Unstructured{}
+		endpoint.SetGroupVersionKind(dnsEndpointGVK)
+		endpoint.SetNamespace(namespace)
+		endpoint.SetName(tenantGatewayName)
+		if err := r.deleteIfControlled(ctx, tenant, endpoint); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DNSRecordValidator validates admission requests for the ExternalDNS
+// record sources in tenant namespaces: DNSEndpoints, Services and Ingresses
+type DNSRecordValidator struct {
+	Client  client.Client
+	Decoder *admission.Decoder
+	Ingress IngressConfig
+}
+
+// Handle rejects DNSEndpoints, ExternalDNS hostname annotations and Ingress
+// hosts naming anything outside the ingress domain and dnsZones of the
+// Tenant owning their namespace
+func (v *DNSRecordValidator) Handle(ctx context.Context, req admission.Request) admission.Response {
+	if req.Operation != admissionv1.Create && req.Operation != admissionv1.Update {
+		return admission.Allowed("")
+	}
+
+	tenant, err := tenantForNamespace(ctx, v.Client, req.Namespace)
+	if err != nil {
+		return admission.Errored(http.StatusInternalServerError, err)
+	}
+	if tenant == nil {
+		return admission.Allowed("")
+	}
+
+	obj := &unstructured.Unstructured{}
+	if err := v.Decoder.Decode(req, obj); err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+	names := dnsRecordNames(req.Kind.Kind, obj)
+	if len(names) == 0 {
+		return admission.Allowed("")
+	}
+
+	domains := v.Ingress.ownedDomains(tenant)
+	if len(domains) == 0 {
+		return admission.Denied(fmt.Sprintf("tenant %q has no ingress domain or certificates.dnsZones, so it can't publish DNS records", tenant.Name))
+	}
+	var denied []string
+	for _, name := range names {
+		if !inZones(domains, name) {
+			denied = append(denied, name)
+		}
+	}
+	if len(denied) == 0 {
+		return admission.Allowed("")
+	}
+	return admission.Denied(fmt.Sprintf("tenant %q may only publish DNS records within %s; not allowed: %s",
+		tenant.Name, strings.Join(domains, ", "), strings.Join(denied, ", ")))
+}
+
+// dnsRecordNames returns the DNS names ExternalDNS would publish for obj,
+// of the given kind
+func dnsRecordNames(kind string, obj *unstructured.Unstructured) []string {
+	var names []string
+	for _, annotation := range dnsHostnameAnnotations {
+		for _, name := range strings.Split(obj.GetAnnotations()[annotation], ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				names = append(names, name)
+			}
+		}
+	}
+
+	switch kind {
+	case "DNSEndpoint":
+		endpoints, _, _ := unstructured.NestedSlice(obj.Object, "spec", "endpoints")
+		for _, endpoint := range endpoints {
+			entry, _ := endpoint.(map[string]interface{})
+			if name, _, _ := unstructured.NestedString(entry, "dnsName"); name != "" {
+				names = append(names, name)
+			}
+		}
+	case "Ingress":
+		rules, _, _ := unstructured.NestedSlice(obj.Object, "spec", "rules")
+		for _, rule := range rules {
+			entry, _ := rule.(map[string]interface{})
+			if host, _, _ := unstructured.NestedString(entry, "host"); host != "" {
+				names = append(names, host)
+			}
+		}
+		tls, _, _ := unstructured.NestedSlice(obj.Object, "spec", "tls")
+		for _, entry := range tls {
+			tlsEntry, _ := entry.(map[string]interface{})
+			hosts, _, _ := unstructured.NestedStringSlice(tlsEntry, "hosts")
+			names = append(names, hosts...)
+		}
+	}
+	return names
+}
@@ -0,0 +1,89 @@
+// Drain on delete
+// With --drain-on-delete, deleting a Tenant first drains its namespace of
+// pods, so workloads release load balancers and volumes before the namespace
+// and everything in it is removed (see reconcileDelete)
+
+package main
+
+import (
+	"context"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	platformv1alpha1 "github.com/xyz-company/tenant-operator/api/v1alpha1"
+)
+
+const (
+	// drainFinalizer held deleted Tenants until their namespace was drained.
+	// The drain now runs under tenantFinalizer; Tenants still carrying this
+	// one are released with it.
+	drainFinalizer = "platform.xyz.com/drain"
+	// drainPollInterval is how often a draining namespace is rechecked
+	drainPollInterval = 10 * time.Second
+)
+
+// drainNamespace zeroes the pod quota of one of tenant's namespaces so
+// nothing is rescheduled and deletes the remaining pods. It reports whether the namespace is drained: no pods
+// are left, or DrainTimeout has passed since the Tenant was deleted.
+// Otherwise the caller must requeue after drainPollInterval.
+func (r *TenantReconciler) drainNamespace(ctx context.Context, tenant *platformv1alpha1.Tenant, namespace string) (bool, error) {
+	log := ctrl.LoggerFrom(ctx)
+
+	if err := r.zeroPodQuota(ctx, namespace); err != nil {
+		return false, err
+	}
+
+	pods := &corev1.PodList{}
+	if err := r.APIReader.List(ctx, pods, client.InNamespace(namespace)); err != nil {
+		return false, err
+	}
+
+	if remaining := len(pods.Items); remaining > 0 {
+		elapsed := time.Since(tenant.DeletionTimestamp.Time)
+		if elapsed < r.DrainTimeout {
+			for i := range pods.Items {
+				pod := &pods.Items[i]
+				if pod.DeletionTimestamp != nil {
+					continue
+				}
+				if err := r.Delete(ctx, pod); err != nil && !errors.IsNotFound(err) {
+					return false, err
+				}
+			}
+			log.Info("Draining namespace", "namespace", namespace, "pods", remaining)
+			r.Recorder.Eventf(tenant, corev1.EventTypeNormal, "Draining", "Waiting for %d pods in namespace %s to terminate", remaining, namespace)
+			return false, nil
+		}
+
+		log.Info("Drain timed out, deleting namespace", "namespace", namespace, "pods", remaining)
+		r.Recorder.Eventf(tenant, corev1.EventTypeWarning, "DrainTimedOut", "%d pods still running after %s, deleting namespace %s anyway", remaining, r.DrainTimeout, namespace)
+	} else {
+		r.Recorder.Eventf(tenant, corev1.EventTypeNormal, "Drained", "Namespace %s has no pods left, deleting it", namespace)
+	}
+	return true, nil
+}
+
+// zeroPodQuota sets the tenant quota's pod limit to 0 so controllers can't
+// replace drained pods
+func (r *TenantReconciler) zeroPodQuota(ctx context.Context, namespace string) error {
+	quota := &corev1.ResourceQuota{}
+	if err := r.Get(ctx, types.NamespacedName{Namespace: namespace, Name: "tenant-quota"}, quota); err != nil {
+		return client.IgnoreNotFound(err)
+	}
+	if pods, ok := quota.Spec.Hard[corev1.ResourcePods]; ok && pods.IsZero() {
+		return nil
+	}
+
+	patch := client.MergeFrom(quota.DeepCopy())
+	if quota.Spec.Hard == nil {
+		quota.Spec.Hard = corev1.ResourceList{}
+	}
+	quota.Spec.Hard[corev1.ResourcePods] = resource.MustParse("0")
+	return r.Patch(ctx, quota, patch)
+}
@@ -0,0 +1,232 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+// podFinalizer keeps a deleted test pod terminating until it is removed
+const podFinalizer = "test.xyz.com/terminating"
+
+// deletedTenant provisions the tenant search with a terminating pod in its
+// namespace, then deletes the Tenant
+func deletedTenant(t *testing.T, drainTimeout time.Duration) (client.Client, *TenantReconciler) {
+	t.Helper()
+	ctx := context.Background()
+	c := newReconcilerClient(newTenant("search", "search-team"))
+	r := newTestReconciler(c)
+	r.DrainOnDelete = true
+	r.DrainTimeout = drainTimeout
+	reconcileTenant(t, r, "search")
+
+	pod := newPod("search", nil)
+	pod.Finalizers = []string{podFinalizer}
+	if err := c.Create(ctx, pod); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Delete(ctx, storedTenant(t, c, "search")); err != nil {
+		t.Fatal(err)
+	}
+	return c, r
+}
+
+// namespaceExists reports whether the namespace name is still there
+func namespaceExists(t *testing.T, c client.Client, name string) bool {
+	t.Helper()
+	err := c.Get(context.Background(), client.ObjectKey{Name: name}, &corev1.Namespace{})
+	if err != nil && !apierrors.IsNotFound(err) {
+		t.Fatal(err)
+	}
+	return err == nil
+}
+
+// tenantGone fails t unless the Tenant name has been deleted
+func tenantGone(t *testing.T, c client.Client, name string) {
+	t.Helper()
+	if err := c.Get(context.Background(), client.ObjectKey{Name: name}, newTenant(name, "")); !apierrors.IsNotFound(err) {
+		t.Fatalf("getting tenant %s: %v, want it deleted", name, err)
+	}
+}
+
+// eventsWithReason returns the recorded events of reason
+func eventsWithReason(events []string, reason string) []string {
+	var matching []string
+	for _, event := range events {
+		if strings.Contains(event, " "+reason+" ") {
+			matching = append(matching, event)
+		}
+	}
+	return matching
+}
+
+func TestDrainThenDelete(t *testing.T) {
+	ctx := context.Background()
+	c, r := deletedTenant(t, time.Hour)
+
+	// The pod quota is zeroed and the pod deleted, but it is still terminating
+	for i := 0; i < 2; i++ {
+		if result := reconcileTenant(t, r, "search"); result.RequeueAfter != drainPollInterval {
+			t.Fatalf("reconcile %d while draining requeued after %s, want %s", i, result.RequeueAfter, drainPollInterval)
+		}
+	}
+	if !namespaceExists(t, c, "search") {
+		t.Fatal("namespace deleted before its pods terminated")
+	}
+	quota := &corev1.ResourceQuota{}
+	if err := c.Get(ctx, client.ObjectKey{Namespace: "search", Name: "tenant-quota"}, quota); err != nil {
+		t.Fatal(err)
+	}
+	if pods := quota.Spec.Hard[corev1.ResourcePods]; !pods.IsZero() {
+		t.Fatalf("pod quota while draining = %s, want 0", pods.String())
+	}
+	pod := &corev1.Pod{}
+	if err := c.Get(ctx, client.ObjectKey{Namespace: "search", Name: "web"}, pod); err != nil {
+		t.Fatal(err)
+	}
+	if pod.DeletionTimestamp == nil {
+		t.Fatal("pod not deleted while draining")
+	}
+	events := recordedEvents(r)
+	if draining := eventsWithReason(events, "Draining"); len(draining) != 2 || !strings.Contains(draining[0], "Waiting for 1 pods in namespace search") {
+		t.Fatalf("events = %q, want a Draining event per reconcile", events)
+	}
+
+	// Once the pod is gone the namespace and the Tenant are deleted
+	controllerutil.RemoveFinalizer(pod, podFinalizer)
+	if err := c.Update(ctx, pod); err != nil {
+		t.Fatal(err)
+	}
+	if result := reconcileTenant(t, r, "search"); result.RequeueAfter != 0 {
+		t.Fatalf("drained reconcile requeued after %s", result.RequeueAfter)
+	}
+	if namespaceExists(t, c, "search") {
+		t.Fatal("namespace left after draining")
+	}
+	tenantGone(t, c, "search")
+	events = recordedEvents(r)
+	if len(eventsWithReason(events, "Drained")) != 1 || len(eventsWithReason(events, "Deleted")) != 1 {
+		t.Fatalf("events = %q, want Drained then Deleted", events)
+	}
+}
+
+func TestDrainTimesOut(t *testing.T) {
+	c, r := deletedTenant(t, time.Nanosecond)
+	time.Sleep(time.Millisecond)
+
+	if result := reconcileTenant(t, r, "search"); result.RequeueAfter != 0 {
+		t.Fatalf("timed out drain requeued after %s", result.RequeueAfter)
+	}
+	if namespaceExists(t, c, "search") {
+		t.Fatal("namespace kept after the drain timed out")
+	}
+	tenantGone(t, c, "search")
+	if events := recordedEvents(r); len(eventsWithReason(events, "DrainTimedOut")) != 1 {
+		t.Fatalf("events = %q, want DrainTimedOut", events)
+	}
+}
+
+func TestDeleteWithoutDrain(t *testing.T) {
+	c, r := deletedTenant(t, time.Hour)
+	r.DrainOnDelete = false
+
+	reconcileTenant(t, r, "search")
+	if namespaceExists(t, c, "search") {
+		t.Fatal("namespace kept without --drain-on-delete")
+	}
+	tenantGone(t, c, "search")
+	if events := recordedEvents(r); len(eventsWithReason(events, "Draining")) != 0 {
+		t.Fatalf("events = %q, want no drain", events)
+	}
+}
+
+func TestDrainReleasesLegacyFinalizer(t *testing.T) {
+	ctx := context.Background()
+	tenant := newTenant("search", "search-team")
+	tenant.Finalizers = []string{drainFinalizer}
+	now := metav1.Now()
+	tenant.DeletionTimestamp = &now
+	c := newReconcilerClient(tenant)
+	r := newTestReconciler(c)
+	r.DrainOnDelete = true
+	r.DrainTimeout = time.Hour
+
+	reconcileTenant(t, r, "search")
+	if err := c.Get(ctx, client.ObjectKey{Name: "search"}, newTenant("search", "")); !apierrors.IsNotFound(err) {
+		t.Fatalf("tenant with only the %s finalizer: %v, want it released", drainFinalizer, err)
+	}
+}
+
+func TestEnvtestDrainThenDelete(t *testing.T) {
+	ctx := context.Background()
+	c := envtestClient(t)
+	// Nothing runs the ServiceAccount controller, and quota admission refuses
+	// pods until the quota controller has set the quota's usage, so the
+	// namespace, its default ServiceAccount and the pod come first
+	if err := c.Create(ctx, &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "drain"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Create(ctx, defaultServiceAccount("drain", nil)); err != nil {
+		t.Fatal(err)
+	}
+	pod := newPod("drain", nil)
+	pod.Finalizers = []string{podFinalizer}
+	if err := c.Create(ctx, pod); err != nil {
+		t.Fatal(err)
+	}
+
+	r := createEnvtestTenant(t, c, newTenant("drain", "search-team"))
+	r.DrainOnDelete = true
+	r.DrainTimeout = time.Hour
+	reconcileTenant(t, r, "drain")
+	if err := c.Delete(ctx, storedTenant(t, c, "drain")); err != nil {
+		t.Fatal(err)
+	}
+
+	if result := reconcileTenant(t, r, "drain"); result.RequeueAfter != drainPollInterval {
+		t.Fatalf("reconcile while draining requeued after %s, want %s", result.RequeueAfter, drainPollInterval)
+	}
+	quota := &corev1.ResourceQuota{}
+	if err := c.Get(ctx, client.ObjectKey{Namespace: "drain", Name: "tenant-quota"}, quota); err != nil {
+		t.Fatal(err)
+	}
+	if pods := quota.Spec.Hard[corev1.ResourcePods]; !pods.IsZero() {
+		t.Fatalf("pod quota while draining = %s, want 0", pods.String())
+	}
+	if err := c.Get(ctx, client.ObjectKeyFromObject(pod), pod); err != nil {
+		t.Fatal(err)
+	}
+	if pod.DeletionTimestamp == nil {
+		t.Fatal("pod not deleted while draining")
+	}
+	if stored := storedTenant(t, c, "drain"); !controllerutil.ContainsFinalizer(stored, tenantFinalizer) {
+		t.Fatalf("finalizers while draining = %v, want %s", stored.Finalizers, tenantFinalizer)
+	}
+
+	controllerutil.RemoveFinalizer(pod, podFinalizer)
+	if err := c.Update(ctx, pod); err != nil {
+		t.Fatal(err)
+	}
+	if result := reconcileTenant(t, r, "drain"); result.RequeueAfter != 0 {
+		t.Fatalf("drained reconcile requeued after %s", result.RequeueAfter)
+	}
+	// Without the namespace controller the namespace stays Terminating
+	ns := &corev1.Namespace{}
+	if err := c.Get(ctx, client.ObjectKey{Name: "drain"}, ns); client.IgnoreNotFound(err) != nil {
+		t.Fatal(err)
+	} else if err == nil && ns.DeletionTimestamp == nil {
+		t.Fatal("namespace not deleted after draining")
+	}
+	tenantGone(t, c, "drain")
+	events := recordedEvents(r)
+	if len(eventsWithReason(events, "Drained")) != 1 || len(eventsWithReason(events, "Deleted")) != 1 {
+		t.Fatalf("events = %q, want Drained then Deleted", events)
+	}
+}
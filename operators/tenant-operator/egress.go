@@ -0,0 +1,373 @@
+// Egress and integration network policies
+// Tenants with networkIsolation get default-deny-egress. Tenants with
+// AllowedIntegrations get an egress policy admitting traffic to those
+// namespaces, and every integrated tenant a matching ingress policy carving
+// an exception for the caller out of its default-deny-ingress. Whenever
+// egress is restricted, allow-essential-egress keeps the namespace itself,
+// cluster DNS, the Kubernetes API and the platform endpoints reachable.
+
+package main
+
+import (
+	"context"
+	"net"
+
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	platformv1alpha1 "github.com/xyz-company/tenant-operator/api/v1alpha1"
+)
+
+const (
+	// defaultDenyEgressPolicy blocks all egress of a networkIsolation tenant
+	defaultDenyEgressPolicy = "default-deny-egress"
+	// essentialEgressPolicy admits egress every restricted tenant needs
+	essentialEgressPolicy = "allow-essential-egress"
+	// integrationEgressPolicy admits egress to a tenant's integrations
+	integrationEgressPolicy = "allow-integrations-egress"
+
+	// integrationSourceLabel marks ingress policies in integrated tenant
+	// namespaces with the tenant they admit traffic from
+	integrationSourceLabel = "platform.xyz.com/integration-source"
+
+	// namespaceNameLabel is set on every namespace by the API server
+	namespaceNameLabel = "kubernetes.io/metadata.name"
+)
+
+// reconcileEgressPolicies applies the egress policies tenant needs in
+// namespace and deletes the ones it doesn't. Egress is restricted once the
+// tenant sets networkIsolation or lists integrations.
+func (r *TenantReconciler) reconcileEgressPolicies(ctx context.Context, tenant *platformv1alpha1.Tenant, namespace string) error {
+	log := ctrl.LoggerFrom(ctx)
+
+	integrations := len(tenant.Spec.AllowedIntegrations) > 0
+	restricted := tenant.Spec.NetworkIsolation || integrations
+
+	// Only built when needed, they look up the API server endpoints and
+	// the integrated tenants
+	essential := &networkingv1.NetworkPolicy{ObjectMeta: metav1.ObjectMeta{Name: essentialEgressPolicy, Namespace: namespace}}
+	integration := &networkingv1.NetworkPolicy{ObjectMeta: metav1.ObjectMeta{Name: integrationEgressPolicy, Namespace: namespace}}
+	if restricted {
+		var err error
+		if essential, err = r.tenantEssentialEgressPolicy(ctx, namespace); err != nil {
+			return err
+		}
+	}
+	if integrations {
+		targets, err := r.integrationNamespaces(ctx, tenant)
+		if err != nil {
+			return err
+		}
+		integration = tenantIntegrationEgressPolicy(namespace, targets)
+	}
+
+	policies := []struct {
+		policy *networkingv1.NetworkPolicy
+		want   bool
+	}{
+		{tenantDefaultDenyEgressPolicy(namespace), tenant.Spec.NetworkIsolation},
+		{essential, restricted},
+		{integration, integrations},
+	}
+
+	for _, p := range policies {
+		if p.want {
+			if err := r.applyOrAdopt(ctx, tenant, p.policy); err != nil {
+				return err
+			}
+			log.Info("NetworkPolicy applied", "namespace", namespace, "networkPolicy", p.policy.Name)
+		} else if err := r.Delete(ctx, p.policy); err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// reconcileIntegrationPolicies applies the ingress policies in the
+// integrated tenants' namespaces, and removes those of integrations no
+// longer listed
+func (r *TenantReconciler) reconcileIntegrationPolicies(ctx context.Context, tenant *platformv1alpha1.Tenant) error {
+	log := ctrl.LoggerFrom(ctx)
+
+	targets, err := r.integratedTenantNamespaces(ctx, tenant)
+	if err != nil {
+		return err
+	}
+	sources := knownNamespaces(tenant)
+	for _, target := range targets {
+		policy := integrationIngressPolicy(tenant.Name, sources, target)
+		if err := r.applyOrAdopt(ctx, tenant, policy); err != nil {
+			return err
+		}
+		log.Info("NetworkPolicy applied", "namespace", target, "networkPolicy", policy.Name)
+	}
+
+	existing := &networkingv1.NetworkPolicyList{}
+	if err := r.List(ctx, existing, client.MatchingLabels{integrationSourceLabel: tenant.Name}); err != nil {
+		return err
+	}
+	keep := make(map[string]bool, len(targets))
+	for _, target := range targets {
+		keep[target] = true
+	}
+	for i := range existing.Items {
+		policy := &existing.Items[i]
+		if keep[policy.Namespace] {
+			continue
+		}
+		if err := r.Delete(ctx, policy); err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+		log.Info("NetworkPolicy deleted", "namespace", policy.Namespace, "networkPolicy", policy.Name)
+	}
+	return nil
+}
+
+// integratedTenantNamespaces returns the existing namespaces of the other
+// Tenants in tenant's AllowedIntegrations. Plain namespaces are reachable
+// through the egress policy but get no ingress policy, since the operator
+// doesn't manage their ingress.
+func (r *TenantReconciler) integratedTenantNamespaces(ctx context.Context, tenant *platformv1alpha1.Tenant) ([]string, error) {
+	var targets []string
+	for _, name := range tenant.Spec.AllowedIntegrations {
+		if name == tenant.Name {
+			continue
+		}
+		target, err := getTenant(ctx, r.Client, name)
+		if err != nil {
+			if errors.IsNotFound(err) {
+				continue
+			}
+			return nil, err
+		}
+		for _, namespace := range knownNamespaces(target) {
+			if err := r.Get(ctx, types.NamespacedName{Name: namespace}, &corev1.Namespace{}); err != nil {
+				if errors.IsNotFound(err) {
+					continue
+				}
+				return nil, err
+			}
+			targets = append(targets, namespace)
+		}
+	}
+	return targets, nil
+}
+
+// integrationNamespaces returns the namespaces tenant's AllowedIntegrations
+// stand for: those of an integrated Tenant, or else the namespace named
+func (r *TenantReconciler) integrationNamespaces(ctx context.Context, tenant *platformv1alpha1.Tenant) ([]string, error) {
+	var namespaces []string
+	for _, name := range tenant.Spec.AllowedIntegrations {
+		target, err := getTenant(ctx, r.Client, name)
+		switch {
+		case err == nil:
+			namespaces = append(namespaces, knownNamespaces(target)...)
+		case errors.IsNotFound(err):
+			namespaces = append(namespaces, name)
+		default:
+			return nil, err
+		}
+	}
+	return namespaces, nil
+}
+
+// tenantDefaultDenyEgressPolicy returns the policy blocking all egress
+// from the pods in namespace
+func tenantDefaultDenyEgressPolicy(namespace string) *networkingv1.NetworkPolicy {
+	return &networkingv1.NetworkPolicy{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      defaultDenyEgressPolicy,
+			Namespace: namespace,
+		},
+		Spec: networkingv1.NetworkPolicySpec{
+			PodSelector: metav1.LabelSelector{},
+			PolicyTypes: []networkingv1.PolicyType{
+				networkingv1.PolicyTypeEgress,
+			},
+		},
+	}
+}
+
+// tenantEssentialEgressPolicy returns the policy admitting egress from the
+// pods in namespace to namespace itself, cluster DNS, the Kubernetes API and
+// the platform namespaces and CIDRs
+func (r *TenantReconciler) tenantEssentialEgressPolicy(ctx context.Context, namespace string) (*networkingv1.NetworkPolicy, error) {
+	udp, tcp := corev1.ProtocolUDP, corev1.ProtocolTCP
+	dnsPort := intstr.FromInt(53)
+
+	rules := []networkingv1.NetworkPolicyEgressRule{
+		{
+			To: []networkingv1.NetworkPolicyPeer{{PodSelector: &metav1.LabelSelector{}}},
+		},
+		{
+			To: []networkingv1.NetworkPolicyPeer{
+				{
+					NamespaceSelector: &metav1.LabelSelector{MatchLabels: map[string]string{namespaceNameLabel: "kube-system"}},
+					PodSelector:       &metav1.LabelSelector{MatchLabels: map[string]string{"k8s-app": "kube-dns"}},
+				},
+			},
+			Ports: []networkingv1.NetworkPolicyPort{
+				{Protocol: &udp, Port: &dnsPort},
+				{Protocol: &tcp, Port: &dnsPort},
+			},
+		},
+	}
+
+	apiServer, err := r.apiServerEgressRule(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if apiServer != nil {
+		rules = append(rules, *apiServer)
+	}
+	if len(r.PlatformNamespaces) > 0 {
+		rules = append(rules, networkingv1.NetworkPolicyEgressRule{
+			To: []networkingv1.NetworkPolicyPeer{namespacesPeer(r.PlatformNamespaces)},
+		})
+	}
+	if len(r.PlatformEgressCIDRs) > 0 {
+		rule := networkingv1.NetworkPolicyEgressRule{}
+		for _, cidr := range r.PlatformEgressCIDRs {
+			rule.To = append(rule.To, networkingv1.NetworkPolicyPeer{IPBlock: &networkingv1.IPBlock{CIDR: cidr}})
+		}
+		rules = append(rules, rule)
+	}
+
+	return &networkingv1.NetworkPolicy{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      essentialEgressPolicy,
+			Namespace: namespace,
+		},
+		Spec: networkingv1.NetworkPolicySpec{
+			PodSelector: metav1.LabelSelector{},
+			PolicyTypes: []networkingv1.PolicyType{
+				networkingv1.PolicyTypeEgress,
+			},
+			Egress: rules,
+		},
+	}, nil
+}
+
+// apiServerEgressRule returns a rule admitting egress to the Kubernetes API
+// server endpoints. The API server isn't a pod, so it is matched by the IPs
+// behind the default/kubernetes Service. Nil if it has no endpoints.
+func (r *TenantReconciler) apiServerEgressRule(ctx context.Context) (*networkingv1.NetworkPolicyEgressRule, error) {
+	endpoints := &corev1.Endpoints{}
+	if err := r.APIReader.Get(ctx, types.NamespacedName{Namespace: metav1.NamespaceDefault, Name: "kubernetes"}, endpoints); err != nil {
+		if errors.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	rule := &networkingv1.NetworkPolicyEgressRule{}
+	for _, subset := range endpoints.Subsets {
+		for _, address := range subset.Addresses {
+			ip := net.ParseIP(address.IP)
+			if ip == nil {
+				continue
+			}
+			bits := 32
+			if ip.To4() == nil {
+				bits = 128
+			}
+			rule.To = append(rule.To, networkingv1.NetworkPolicyPeer{
+				IPBlock: &networkingv1.IPBlock{CIDR: (&net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}).String()},
+			})
+		}
+		for _, port := range subset.Ports {
+			protocol, p := port.Protocol, intstr.FromInt(int(port.Port))
+			rule.Ports = append(rule.Ports, networkingv1.NetworkPolicyPort{Protocol: &protocol, Port: &p})
+		}
+	}
+	if len(rule.To) == 0 {
+		return nil, nil
+	}
+	return rule, nil
+}
+
+// tenantIntegrationEgressPolicy returns the policy admitting egress from
+// the pods in namespace to the integration namespaces targets
+func tenantIntegrationEgressPolicy(namespace string, targets []string) *networkingv1.NetworkPolicy {
+	return &networkingv1.NetworkPolicy{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      integrationEgressPolicy,
+			Namespace: namespace,
+		},
+		Spec: networkingv1.NetworkPolicySpec{
+			PodSelector: metav1.LabelSelector{},
+			PolicyTypes: []networkingv1.PolicyType{
+				networkingv1.PolicyTypeEgress,
+			},
+			Egress: []networkingv1.NetworkPolicyEgressRule{
+				{
+					To: []networkingv1.NetworkPolicyPeer{namespacesPeer(targets)},
+				},
+			},
+		},
+	}
+}
+
+// integrationIngressPolicy returns the policy admitting traffic from the
+// source tenant's namespaces into the target namespace
+func integrationIngressPolicy(source string, sourceNamespaces []string, target string) *networkingv1.NetworkPolicy {
+	return &networkingv1.NetworkPolicy{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "allow-from-" + source,
+			Namespace: target,
+			Labels:    map[string]string{integrationSourceLabel: source},
+		},
+		Spec: networkingv1.NetworkPolicySpec{
+			PodSelector: metav1.LabelSelector{},
+			PolicyTypes: []networkingv1.PolicyType{
+				networkingv1.PolicyTypeIngress,
+			},
+			Ingress: []networkingv1.NetworkPolicyIngressRule{
+				{
+					From: []networkingv1.NetworkPolicyPeer{namespacesPeer(sourceNamespaces)},
+				},
+			},
+		},
+	}
+}
+
+// namespacesPeer matches every pod in the named namespaces
+func namespacesPeer(names []string) networkingv1.NetworkPolicyPeer {
+	return networkingv1.NetworkPolicyPeer{
+		NamespaceSelector: &metav1.LabelSelector{
+			MatchExpressions: []metav1.LabelSelectorRequirement{
+				{Key: namespaceNameLabel, Operator: metav1.LabelSelectorOpIn, Values: names},
+			},
+		},
+	}
+}
+
+// namespaceToIntegratingTenants maps a namespace appearing or going away to
+// a reconcile of every tenant integrating with it, or with the tenant it
+// belongs to, so their policies there are created or cleaned up
+func (r *TenantReconciler) namespaceToIntegratingTenants(ctx context.Context, obj client.Object) []reconcile.Request {
+	owner := obj.GetLabels()[tenantLabel]
+	tenants, err := listTenants(ctx, r.Client)
+	if err != nil {
+		ctrl.LoggerFrom(ctx).Error(err, "Failed to list Tenants for namespace", "namespace", obj.GetName())
+		return nil
+	}
+
+	var requests []reconcile.Request
+	for _, tenant := range tenants {
+		for _, name := range tenant.Spec.AllowedIntegrations {
+			if name == obj.GetName() || name == owner {
+				requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Name: tenant.Name}})
+				break
+			}
+		}
+	}
+	return requests
+}
@@ -0,0 +1,95 @@
+// Namespace exclusion
+// System namespaces, the operator's own namespace and those matching
+// --excluded-namespaces are never provisioned or adopted, and their events
+// are filtered out of the Namespace watches so they don't cost reconciles.
+
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/equality"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+)
+
+// NamespaceExclusion decides which namespaces the operator leaves alone:
+// the reserved system namespaces, those starting with "kube-", and the ones
+// configured here
+type NamespaceExclusion struct {
+	// OperatorNamespace is the namespace the operator runs in
+	// (--operator-namespace)
+	OperatorNamespace string
+	// Pattern, when set, matches further namespaces to exclude
+	// (--excluded-namespaces)
+	Pattern *regexp.Regexp
+}
+
+// parseExcludedNamespaces compiles the --excluded-namespaces regular
+// expression, which must match whole namespace names. Empty excludes nothing
+// beyond the defaults.
+func parseExcludedNamespaces(expr string) (*regexp.Regexp, error) {
+	if expr == "" {
+		return nil, nil
+	}
+	pattern, err := regexp.Compile("^(?:" + expr + ")$")
+	if err != nil {
+		return nil, fmt.Errorf("invalid --excluded-namespaces: %w", err)
+	}
+	return pattern, nil
+}
+
+// Excluded reports whether the operator must not manage namespace
+func (e NamespaceExclusion) Excluded(namespace string) bool {
+	switch {
+	case reservedNamespaces[namespace], strings.HasPrefix(namespace, "kube-"):
+		return true
+	case e.OperatorNamespace != "" && namespace == e.OperatorNamespace:
+		return true
+	}
+	return e.Pattern != nil && e.Pattern.MatchString(namespace)
+}
+
+// predicate drops the events of objects in excluded namespaces, and of
+// excluded Namespaces themselves
+func (e NamespaceExclusion) predicate() predicate.Predicate {
+	return predicate.NewPredicateFuncs(func(obj client.Object) bool {
+		namespace := obj.GetNamespace()
+		if namespace == "" {
+			namespace = obj.GetName()
+		}
+		return !e.Excluded(namespace)
+	})
+}
+
+// namespaceChanged drops Namespace updates that leave its labels,
+// annotations and deletion alone, such as resyncs and status updates,
+// since the map funcs look at nothing else
+func namespaceChanged() predicate.Predicate {
+	return predicate.Funcs{
+		UpdateFunc: func(e event.UpdateEvent) bool {
+			return !equality.Semantic.DeepEqual(e.ObjectOld.GetLabels(), e.ObjectNew.GetLabels()) ||
+				!equality.Semantic.DeepEqual(e.ObjectOld.GetAnnotations(), e.ObjectNew.GetAnnotations()) ||
+				!e.ObjectOld.GetDeletionTimestamp().Equal(e.ObjectNew.GetDeletionTimestamp())
+		},
+	}
+}
+
+// managedNamespace drops the events of namespaces that are neither
+// labelled for a tenant nor annotated for adoption. An update passes if
+// either version qualifies, so removing the label still reaches the tenant.
+func managedNamespace() predicate.Predicate {
+	managed := func(obj client.Object) bool {
+		_, labelled := obj.GetLabels()[tenantLabel]
+		return labelled || obj.GetAnnotations()[adoptAnnotation] != ""
+	}
+	return predicate.Funcs{
+		CreateFunc:  func(e event.CreateEvent) bool { return managed(e.Object) },
+		UpdateFunc:  func(e event.UpdateEvent) bool { return managed(e.ObjectOld) || managed(e.ObjectNew) },
+		DeleteFunc:  func(e event.DeleteEvent) bool { return managed(e.Object) },
+		GenericFunc: func(e event.GenericEvent) bool { return managed(e.Object) },
+	}
+}
@@ -0,0 +1,170 @@
+// Tenant expiry
+// Sandbox tenants can set Spec.ExpiresAt or Spec.TTL. Ahead of the expiry
+// the tenant gets a warning event and its contacts a notification; once it
+// has passed the Tenant moves to the Expired phase and is deleted, honouring
+// its deletion policy.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	platformv1alpha1 "github.com/xyz-company/tenant-operator/api/v1alpha1"
+)
+
+const (
+	// expiryWarnedAnnotation records the expiry the tenant was last warned
+	// about, so each expiry is only announced once
+	expiryWarnedAnnotation = "platform.xyz.com/expiry-warned"
+	// notificationRetryInterval is how soon a failed notification is retried
+	notificationRetryInterval = 5 * time.Minute
+)
+
+// tenantExpiry returns when tenant expires: Spec.ExpiresAt, or Spec.TTL
+// after its creation. ok is false for tenants that never expire.
+func tenantExpiry(tenant *platformv1alpha1.Tenant) (expiry time.Time, ok bool) {
+	switch {
+	case tenant.Spec.ExpiresAt != nil:
+		return tenant.Spec.ExpiresAt.Time, true
+	case tenant.Spec.TTL != nil:
+		return tenant.CreationTimestamp.Add(tenant.Spec.TTL.Duration), true
+	}
+	return time.Time{}, false
+}
+
+// reconcileExpiry warns about and deletes expiring tenants. It reports
+// whether tenant has expired, in which case the caller must stop, and
+// otherwise when the tenant needs to be looked at again, 0 if never.
+func (r *TenantReconciler) reconcileExpiry(ctx context.Context, tenant *platformv1alpha1.Tenant) (bool, time.Duration, error) {
+	expiry, ok := tenantExpiry(tenant)
+	if !ok {
+		return false, 0, nil
+	}
+	log := ctrl.LoggerFrom(ctx)
+	stamp := expiry.UTC().Format(time.RFC3339)
+
+	remaining := time.Until(expiry)
+	if remaining <= 0 {
+		return true, 0, r.expire(ctx, tenant, stamp)
+	}
+	if remaining > r.ExpiryWarning {
+		return false, remaining - r.ExpiryWarning, nil
+	}
+	if tenant.Annotations[expiryWarnedAnnotation] == stamp {
+		return false, remaining, nil
+	}
+
+	r.Recorder.Eventf(tenant, corev1.EventTypeWarning, "ExpiringSoon", "Tenant expires at %s and will then be deleted", stamp)
+	if r.ExpiryNotifier != nil && len(tenant.Spec.Contacts) > 0 {
+		if err := r.ExpiryNotifier.Notify(ctx, tenant, expiry); err != nil {
+			log.Error(err, "Failed to send expiry notification")
+			r.Recorder.Eventf(tenant, corev1.EventTypeWarning, "NotificationFailed", "Expiry notification failed: %v", err)
+			// Not marked as warned, so the next attempt warns again
+			if notificationRetryInterval < remaining {
+				return false, notificationRetryInterval, nil
+			}
+			return false, remaining, nil
+		}
+		log.Info("Sent expiry notification", "expiresAt", stamp)
+	}
+
+	patch := client.MergeFrom(tenant.DeepCopy())
+	if tenant.Annotations == nil {
+		tenant.Annotations = map[string]string{}
+	}
+	tenant.Annotations[expiryWarnedAnnotation] = stamp
+	if err := r.Patch(ctx, tenant, patch); err != nil {
+		return false, 0, err
+	}
+	return false, remaining, nil
+}
+
+// expire moves tenant to the Expired phase and deletes it. A Tenant with
+// deletion protection stays Expired until the protection is removed.
+func (r *TenantReconciler) expire(ctx context.Context, tenant *platformv1alpha1.Tenant, stamp string) error {
+	previous := tenant.Status.DeepCopy()
+	tenant.Status.Phase = TenantPhaseExpired
+	tenant.Status.Message = "Expired at " + stamp
+	setConditions(tenant)
+	if !equality.Semantic.DeepEqual(previous, &tenant.Status) {
+		if err := r.Status().Update(ctx, tenant); err != nil {
+			return err
+		}
+	}
+
+	if deletionProtected(tenant) {
+		if previous.Phase != TenantPhaseExpired {
+			r.Recorder.Eventf(tenant, corev1.EventTypeWarning, "ExpiryBlocked", "Tenant expired at %s but has deletion protection", stamp)
+		}
+		return nil
+	}
+	ctrl.LoggerFrom(ctx).Info("Deleting expired Tenant", "expiresAt", stamp)
+	r.Recorder.Eventf(tenant, corev1.EventTypeNormal, "Expired", "Tenant expired at %s, deleting it", stamp)
+	return client.IgnoreNotFound(r.Delete(ctx, tenant))
+}
+
+// ExpiryNotifier posts a JSON notification about expiring tenants to a
+// webhook, such as a mail or chat relay, which delivers it to the contacts
+type ExpiryNotifier struct {
+	URL    string
+	Client *http.Client
+}
+
+// expiryNotification is the body ExpiryNotifier posts
+type expiryNotification struct {
+	Tenant    string            `json:"tenant"`
+	Owner     string            `json:"owner"`
+	Contacts  map[string]string `json:"contacts"`
+	ExpiresAt metav1.Time       `json:"expiresAt"`
+}
+
+// Notify tells tenant's contacts that it expires at expiry
+func (n *ExpiryNotifier) Notify(ctx context.Context, tenant *platformv1alpha1.Tenant, expiry time.Time) error {
+	body, err := json.Marshal(expiryNotification{
+		Tenant:    tenant.Name,
+		Owner:     tenant.Spec.Owner,
+		Contacts:  tenant.Spec.Contacts,
+		ExpiresAt: metav1.NewTime(expiry),
+	})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := n.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("notification webhook returned %s", resp.Status)
+	}
+	return nil
+}
+
+// validateExpiry rejects setting both Spec.ExpiresAt and Spec.TTL, and
+// TTLs that aren't positive
+func validateExpiry(spec *platformv1alpha1.TenantSpec) error {
+	if spec.ExpiresAt != nil && spec.TTL != nil {
+		return fmt.Errorf("set either expiresAt or ttl, not both")
+	}
+	if spec.TTL != nil && spec.TTL.Duration <= 0 {
+		return fmt.Errorf("ttl must be positive, got %s", spec.TTL.Duration)
+	}
+	return nil
+}
@@ -0,0 +1,177 @@
+// Tenant export
+// GET /tenants/export streams every Tenant with its status and managed
+// resources as NDJSON, for DR snapshots and audits
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"os"
+
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	platformv1alpha1 "github.com/xyz-company/tenant-operator/api/v1alpha1"
+)
+
+// exportPageSize bounds how many Tenants are held in memory at once
+const exportPageSize = 100
+
+// TenantExportRecord is one line of the export
+type TenantExportRecord struct {
+	Name             string                        `json:"name"`
+	Spec             platformv1alpha1.TenantSpec   `json:"spec"`
+	Status           platformv1alpha1.TenantStatus `json:"status"`
+	ManagedResources ManagedResources              `json:"managedResources"`
+}
+
+// ManagedResources lists the tenant-labelled resources in the tenant namespaces
+type ManagedResources struct {
+	ResourceQuotas  []string `json:"resourceQuotas"`
+	LimitRanges     []string `json:"limitRanges"`
+	NetworkPolicies []string `json:"networkPolicies"`
+	RoleBindings    []string `json:"roleBindings"`
+}
+
+// handleExport streams Tenants page by page from the API server so memory
+// use stays flat on large clusters
+func (s *InventoryServer) handleExport(w http.ResponseWriter, r *http.Request) {
+	log := ctrl.Log.WithName("export")
+
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !s.exportAuthorized(r) {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	ctx := r.Context()
+	enc := json.NewEncoder(w)
+	flusher, _ := w.(http.Flusher)
+	started := false
+
+	continueToken := ""
+	for {
+		list := &platformv1alpha1.TenantList{}
+		if err := s.APIReader.List(ctx, list, client.Limit(exportPageSize), client.Continue(continueToken)); err != nil {
+			log.Error(err, "Failed to list Tenants")
+			if !started {
+				http.Error(w, "Failed to list tenants", http.StatusInternalServerError)
+			}
+			return
+		}
+
+		if !started {
+			w.Header().Set("Content-Type", "application/x-ndjson")
+			started = true
+		}
+
+		for i := range list.Items {
+			tenant := &list.Items[i]
+			resources, err := s.managedResources(ctx, tenant)
+			if err != nil {
+				log.Error(err, "Failed to list managed resources", "tenant", tenant.Name)
+				return
+			}
+
+			if err := enc.Encode(TenantExportRecord{
+				Name:             tenant.Name,
+				Spec:             tenant.Spec,
+				Status:           tenant.Status,
+				ManagedResources: resources,
+			}); err != nil {
+				return
+			}
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+
+		continueToken = list.GetContinue()
+		if continueToken == "" {
+			return
+		}
+	}
+}
+
+// exportAuthorized checks the bearer token against ExportTokenFile. The file
+// is re-read on every request so the token can be rotated via its Secret.
+func (s *InventoryServer) exportAuthorized(r *http.Request) bool {
+	if s.ExportTokenFile == "" {
+		return false
+	}
+	token, err := os.ReadFile(s.ExportTokenFile)
+	if err != nil {
+		ctrl.Log.WithName("export").Error(err, "Failed to read export token")
+		return false
+	}
+	token = bytes.TrimSpace(token)
+	if len(token) == 0 {
+		return false
+	}
+
+	expected := append([]byte("Bearer "), token...)
+	return subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), expected) == 1
+}
+
+// managedResources lists the resources labelled for tenant in its
+// namespaces. Names are qualified with the namespace for tenants with
+// Spec.Namespaces.
+func (s *InventoryServer) managedResources(ctx context.Context, tenant *platformv1alpha1.Tenant) (ManagedResources, error) {
+	resources := ManagedResources{ResourceQuotas: []string{}, LimitRanges: []string{}, NetworkPolicies: []string{}, RoleBindings: []string{}}
+	namespaces := knownNamespaces(tenant)
+	name := func(obj metav1.Object) string {
+		if len(namespaces) > 1 {
+			return obj.GetNamespace() + "/" + obj.GetName()
+		}
+		return obj.GetName()
+	}
+
+	for _, namespace := range namespaces {
+		opts := []client.ListOption{client.InNamespace(namespace), client.MatchingLabels{tenantLabel: tenant.Name}}
+
+		quotas := &corev1.ResourceQuotaList{}
+		if err := s.APIReader.List(ctx, quotas, opts...); err != nil {
+			return resources, err
+		}
+		for i := range quotas.Items {
+			resources.ResourceQuotas = append(resources.ResourceQuotas, name(&quotas.Items[i]))
+		}
+
+		limitRanges := &corev1.LimitRangeList{}
+		if err := s.APIReader.List(ctx, limitRanges, opts...); err != nil {
+			return resources, err
+		}
+		for i := range limitRanges.Items {
+			resources.LimitRanges = append(resources.LimitRanges, name(&limitRanges.Items[i]))
+		}
+
+		netpols := &networkingv1.NetworkPolicyList{}
+		if err := s.APIReader.List(ctx, netpols, opts...); err != nil {
+			return resources, err
+		}
+		for i := range netpols.Items {
+			resources.NetworkPolicies = append(resources.NetworkPolicies, name(&netpols.Items[i]))
+		}
+
+		roleBindings := &rbacv1.RoleBindingList{}
+		if err := s.APIReader.List(ctx, roleBindings, opts...); err != nil {
+			return resources, err
+		}
+		for i := range roleBindings.Items {
+			resources.RoleBindings = append(resources.RoleBindings, name(&roleBindings.Items[i]))
+		}
+	}
+
+	return resources, nil
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"testing"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+
+	platformv1alpha1 "github.com/xyz-company/tenant-operator/api/v1alpha1"
+)
+
+// pagedTenants wraps c so Tenant lists honour Limit and Continue, which the
+// fake client ignores, and counts the pages served
+func pagedTenants(c client.WithWatch, pages *int) client.WithWatch {
+	return interceptor.NewClient(c, interceptor.Funcs{
+		List: func(ctx context.Context, c client.WithWatch, list client.ObjectList, opts ...client.ListOption) error {
+			tenants, ok := list.(*platformv1alpha1.TenantList)
+			if !ok {
+				return c.List(ctx, list, opts...)
+			}
+			listOpts := (&client.ListOptions{}).ApplyOptions(opts)
+			all := &platformv1alpha1.TenantList{}
+			if err := c.List(ctx, all); err != nil {
+				return err
+			}
+			start := 0
+			if listOpts.Continue != "" {
+				start, _ = strconv.Atoi(listOpts.Continue)
+			}
+			end := len(all.Items)
+			if listOpts.Limit > 0 && start+int(listOpts.Limit) < end {
+				end = start + int(listOpts.Limit)
+				tenants.Continue = strconv.Itoa(end)
+			}
+			tenants.Items = all.Items[start:end]
+			*pages++
+			return nil
+		},
+	})
+}
+
+// exportServer returns an InventoryServer exporting from c with the token
+// "secret"
+func exportServer(t *testing.T, c client.Reader) *InventoryServer {
+	t.Helper()
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(tokenFile, []byte("secret\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return &InventoryServer{Reader: c, APIReader: c, ExportTokenFile: tokenFile}
+}
+
+// export fetches GET /tenants/export from s and decodes its records
+func export(t *testing.T, s *InventoryServer) []TenantExportRecord {
+	t.Helper()
+	r := httptest.NewRequest(http.MethodGet, "/tenants/export", nil)
+	r.Header.Set("Authorization", "Bearer secret")
+	w := httptest.NewRecorder()
+	s.handleExport(w, r)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", w.Code, w.Body)
+	}
+	if ct := w.Header().Get("Content-Type"); ct != "application/x-ndjson" {
+		t.Fatalf("Content-Type = %s, want application/x-ndjson", ct)
+	}
+
+	var records []TenantExportRecord
+	scanner := bufio.NewScanner(w.Body)
+	for scanner.Scan() {
+		var record TenantExportRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			t.Fatalf("line %d: %v", len(records)+1, err)
+		}
+		records = append(records, record)
+	}
+	return records
+}
+
+func TestExportContainsEveryTenant(t *testing.T) {
+	failed := newTenant("ads", "ads-team")
+	failed.Status = platformv1alpha1.TenantStatus{Phase: "Error", Message: "quota: invalid cpu"}
+	c := newReconcilerClient(newTenant("search", "search-team"), failed)
+	r := newTestReconciler(c)
+	reconcileTenant(t, r, "search")
+
+	records := export(t, exportServer(t, c))
+	if len(records) != 2 {
+		t.Fatalf("exported %d tenants, want 2", len(records))
+	}
+	byName := map[string]TenantExportRecord{}
+	for _, record := range records {
+		byName[record.Name] = record
+	}
+
+	search := byName["search"]
+	if search.Spec.Owner != "search-team" || search.Status.Phase != "Ready" || !search.Status.QuotaApplied {
+		t.Errorf("search exported as %+v, want its spec and Ready status", search)
+	}
+	want := ManagedResources{
+		ResourceQuotas:  []string{"tenant-quota"},
+		LimitRanges:     []string{"tenant-limits"},
+		NetworkPolicies: []string{"allow-same-namespace", "default-deny-ingress"},
+		RoleBindings:    []string{"search-developers"},
+	}
+	if !reflect.DeepEqual(search.ManagedResources, want) {
+		t.Errorf("search managed resources = %+v, want %+v", search.ManagedResources, want)
+	}
+
+	ads := byName["ads"]
+	if ads.Status.Phase != "Error" || ads.Status.Message != "quota: invalid cpu" {
+		t.Errorf("ads status = %+v, want the failed status", ads.Status)
+	}
+}
+
+func TestExportPagesThroughTenants(t *testing.T) {
+	c := newFakeClient(inventoryTenants(250)...)
+	pages := 0
+	records := export(t, exportServer(t, pagedTenants(c, &pages)))
+
+	if len(records) != 250 {
+		t.Fatalf("exported %d tenants, want 250", len(records))
+	}
+	seen := map[string]bool{}
+	for _, record := range records {
+		seen[record.Name] = true
+	}
+	for i := 0; i < 250; i++ {
+		if name := fmt.Sprintf("tenant-%03d", i); !seen[name] {
+			t.Fatalf("%s missing from the export", name)
+		}
+	}
+	if pages != 3 {
+		t.Fatalf("listed %d pages, want 3 of at most %d", pages, exportPageSize)
+	}
+}
+
+func TestExportRequiresToken(t *testing.T) {
+	c := newFakeClient(newTenant("search", "search-team"))
+
+	tests := []struct {
+		name      string
+		tokenFile bool
+		auth      string
+		want      int
+	}{
+		{"no token configured", false, "Bearer secret", http.StatusUnauthorized},
+		{"no token sent", true, "", http.StatusUnauthorized},
+		{"wrong token", true, "Bearer guess", http.StatusUnauthorized},
+		{"right token", true, "Bearer secret", http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := exportServer(t, c)
+			if !tt.tokenFile {
+				s.ExportTokenFile = ""
+			}
+			r := httptest.NewRequest(http.MethodGet, "/tenants/export", nil)
+			if tt.auth != "" {
+				r.Header.Set("Authorization", tt.auth)
+			}
+			w := httptest.NewRecorder()
+			s.handleExport(w, r)
+			if w.Code != tt.want {
+				t.Fatalf("status = %d, want %d", w.Code, tt.want)
+			}
+		})
+	}
+}
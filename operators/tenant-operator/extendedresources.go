@@ -0,0 +1,84 @@
+// Extended resource quotas
+// TenantQuota.ExtendedResources caps device plugin resources such as
+// nvidia.com/gpu. Kubernetes doesn't overcommit them, so only their requests
+// are quota'd: nvidia.com/gpu: 4 becomes requests.nvidia.com/gpu: 4.
+
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// extendedResourceQuota returns the ResourceQuota hard limits for
+// extended, rejecting names that aren't extended resources and counts that
+// aren't whole and non-negative
+func extendedResourceQuota(extended map[string]string) (corev1.ResourceList, error) {
+	names := make([]string, 0, len(extended))
+	for name := range extended {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	hard := corev1.ResourceList{}
+	for _, name := range names {
+		if err := validateExtendedResourceName(name); err != nil {
+			return nil, err
+		}
+		q, err := resource.ParseQuantity(extended[name])
+		if err != nil {
+			return nil, fmt.Errorf("quota.extendedResources[%s] %q is not a valid quantity", name, extended[name])
+		}
+		if q.Sign() < 0 || q.MilliValue()%1000 != 0 {
+			return nil, fmt.Errorf("quota.extendedResources[%s] must be a whole number of devices, got %s", name, extended[name])
+		}
+		hard[corev1.ResourceName(corev1.DefaultResourceRequestsPrefix+name)] = q
+	}
+	return hard, nil
+}
+
+// validateExtendedResourceName accepts the names device plugins advertise:
+// a domain-prefixed qualified name outside the kubernetes.io domains
+func validateExtendedResourceName(name string) error {
+	if errs := validation.IsQualifiedName(name); len(errs) > 0 {
+		return fmt.Errorf("quota.extendedResources name %q is invalid: %s", name, strings.Join(errs, ", "))
+	}
+	domain, _, ok := strings.Cut(name, "/")
+	switch {
+	case !ok:
+		return fmt.Errorf("quota.extendedResources name %q needs a domain prefix, such as nvidia.com/gpu", name)
+	case strings.HasPrefix(name, corev1.DefaultResourceRequestsPrefix):
+		return fmt.Errorf("quota.extendedResources name %q must not start with %q; requests are implied", name, corev1.DefaultResourceRequestsPrefix)
+	case domain == "kubernetes.io" || strings.HasSuffix(domain, ".kubernetes.io"):
+		return fmt.Errorf("quota.extendedResources name %q is in the reserved kubernetes.io domain", name)
+	}
+	return nil
+}
+
+// shareExtendedResources returns a copy of extended with every count cut
+// to percent, rounded down but never below 1 device
+func shareExtendedResources(extended map[string]string, percent int) (map[string]string, error) {
+	if len(extended) == 0 {
+		return nil, nil
+	}
+	shared := make(map[string]string, len(extended))
+	for name, value := range extended {
+		q, err := resource.ParseQuantity(value)
+		if err != nil {
+			return nil, fmt.Errorf("quota.extendedResources[%s] %q is not a valid quantity", name, value)
+		}
+		n := q.Value()
+		if n > 0 {
+			if n = n * int64(percent) / 100; n < 1 {
+				n = 1
+			}
+		}
+		shared[name] = resource.NewQuantity(n, resource.DecimalSI).String()
+	}
+	return shared, nil
+}
@@ -0,0 +1,234 @@
+// Cross-cluster failover
+// Spec.Placement.Failover places a tenant on a primary and a standby
+// cluster. The tenant namespaces in both are labelled with their role,
+// platform.xyz.com/failover-role active or standby, for GitOps tooling and
+// traffic management to follow. Workloads pre-staged in both clusters with
+// the platform.xyz.com/failover-replicas annotation run with those replicas
+// in the active cluster and are scaled to zero in the standby.
+// Once the primary's Cluster has been Unreachable for the threshold, the
+// standby becomes active. Failover is sticky, so a flapping primary doesn't
+// bounce workloads between clusters: annotating the Tenant with
+// platform.xyz.com/failback hands them back once the primary is Ready.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	platformv1alpha1 "github.com/xyz-company/tenant-operator/api/v1alpha1"
+)
+
+const (
+	// failoverRoleLabel marks a remote tenant namespace active or standby
+	failoverRoleLabel = "platform.xyz.com/failover-role"
+	// failoverReplicasAnnotation gives a pre-staged workload's replicas in
+	// the active cluster
+	failoverReplicasAnnotation = "platform.xyz.com/failover-replicas"
+	// failbackAnnotation on a Tenant asks for the primary to be reactivated
+	failbackAnnotation = "platform.xyz.com/failback"
+
+	// defaultFailoverThreshold is how long a primary may be unreachable
+	// before its standby is activated
+	defaultFailoverThreshold = 5 * time.Minute
+)
+
+// Failover roles of a cluster
+const (
+	failoverRoleActive  = "active"
+	failoverRoleStandby = "standby"
+)
+
+// validateFailover rejects failover pairs missing a cluster or naming the
+// same cluster twice
+func validateFailover(failover *platformv1alpha1.TenantFailover) error {
+	if failover == nil {
+		return nil
+	}
+	if failover.Primary == "" || failover.Standby == "" {
+		return fmt.Errorf("placement.failover needs both a primary and a standby cluster")
+	}
+	if failover.Primary == failover.Standby {
+		return fmt.Errorf("placement.failover.standby must differ from the primary %q", failover.Primary)
+	}
+	if failover.Threshold != nil && failover.Threshold.Duration <= 0 {
+		return fmt.Errorf("placement.failover.threshold must be positive")
+	}
+	return nil
+}
+
+// tenantFailover returns the tenant's failover pair, nil without one
+func tenantFailover(tenant *platformv1alpha1.Tenant) *platformv1alpha1.TenantFailover {
+	if tenant.Spec.Placement == nil {
+		return nil
+	}
+	return tenant.Spec.Placement.Failover
+}
+
+// failoverRole returns the role of cluster in the tenant's failover pair,
+// "" for clusters outside it
+func failoverRole(tenant *platformv1alpha1.Tenant, cluster string) string {
+	failover := tenantFailover(tenant)
+	if failover == nil || (cluster != failover.Primary && cluster != failover.Standby) {
+		return ""
+	}
+	active := failover.Primary
+	if tenant.Status.Failover != nil {
+		active = tenant.Status.Failover.ActiveCluster
+	}
+	if cluster == active {
+		return failoverRoleActive
+	}
+	return failoverRoleStandby
+}
+
+// reconcileFailover decides which cluster of the tenant's failover pair is
+// active, recording it in Status.Failover. It returns when to check again
+// while the primary is unreachable but within the threshold, 0 otherwise.
+func (r *TenantReconciler) reconcileFailover(ctx context.Context, tenant *platformv1alpha1.Tenant) (time.Duration, error) {
+	log := ctrl.LoggerFrom(ctx)
+	failover := tenantFailover(tenant)
+	if failover == nil {
+		tenant.Status.Failover = nil
+		return 0, nil
+	}
+	status := tenant.Status.Failover
+	if status == nil || (status.ActiveCluster != failover.Primary && status.ActiveCluster != failover.Standby) {
+		status = &platformv1alpha1.FailoverStatus{ActiveCluster: failover.Primary}
+		tenant.Status.Failover = status
+	}
+
+	if status.ActiveCluster == failover.Standby {
+		if _, ok := tenant.Annotations[failbackAnnotation]; !ok {
+			return 0, nil
+		}
+		primary, err := r.clusterReadyCondition(ctx, failover.Primary)
+		if err != nil {
+			return 0, err
+		}
+		if primary != nil && primary.Status == metav1.ConditionFalse {
+			log.Info("Primary cluster not Ready, holding failback", "cluster", failover.Primary)
+			return 0, nil
+		}
+
+		now := metav1.Now()
+		status.ActiveCluster = failover.Primary
+		status.LastFailbackTime = &now
+		r.Recorder.Eventf(tenant, corev1.EventTypeNormal, "Failback", "Reactivated primary cluster %s at %s, %s is standby again",
+			failover.Primary, now.UTC().Format(time.RFC3339), failover.Standby)
+
+		// Patch a copy, so the response doesn't overwrite the status
+		// being reconciled
+		patched := tenant.DeepCopy()
+		patch := client.MergeFrom(patched.DeepCopy())
+		delete(patched.Annotations, failbackAnnotation)
+		if err := r.Patch(ctx, patched, patch); err != nil {
+			return 0, err
+		}
+		tenant.Annotations = patched.Annotations
+		tenant.ResourceVersion = patched.ResourceVersion
+		return 0, nil
+	}
+
+	primary, err := r.clusterReadyCondition(ctx, failover.Primary)
+	if err != nil || primary == nil || primary.Reason != ReasonUnreachable {
+		return 0, err
+	}
+	threshold := defaultFailoverThreshold
+	if failover.Threshold != nil {
+		threshold = failover.Threshold.Duration
+	}
+	down := time.Since(primary.LastTransitionTime.Time)
+	if down < threshold {
+		return threshold - down, nil
+	}
+	standby, err := r.clusterReadyCondition(ctx, failover.Standby)
+	if err != nil {
+		return 0, err
+	}
+	if standby != nil && standby.Status == metav1.ConditionFalse {
+		log.Info("Primary and standby clusters both down, can't fail over", "primary", failover.Primary, "standby", failover.Standby)
+		return 0, nil
+	}
+
+	now := metav1.Now()
+	status.ActiveCluster = failover.Standby
+	status.LastFailoverTime = &now
+	r.Recorder.Eventf(tenant, corev1.EventTypeWarning, "Failover", "Primary cluster %s unreachable since %s, over %s; activated standby %s at %s",
+		failover.Primary, primary.LastTransitionTime.UTC().Format(time.RFC3339), threshold, failover.Standby, now.UTC().Format(time.RFC3339))
+	log.Info("Failed over to standby cluster", "primary", failover.Primary, "standby", failover.Standby)
+	return 0, nil
+}
+
+// clusterReadyCondition returns the Ready condition of the registered
+// Cluster called name, nil when it isn't registered or probed yet
+func (r *TenantReconciler) clusterReadyCondition(ctx context.Context, name string) (*metav1.Condition, error) {
+	cluster := &platformv1alpha1.Cluster{}
+	err := r.Get(ctx, types.NamespacedName{Name: name}, cluster)
+	if errors.IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return meta.FindStatusCondition(cluster.Status.Conditions, ConditionReady), nil
+}
+
+// scaleFailoverWorkloads scales the pre-staged Deployments and StatefulSets
+// in namespace of a target cluster to their failover replicas when role is
+// active, and to zero when it is standby
+func scaleFailoverWorkloads(ctx context.Context, c client.Client, namespace, role string) error {
+	deployments := &appsv1.DeploymentList{}
+	if err := c.List(ctx, deployments, client.InNamespace(namespace)); err != nil {
+		return err
+	}
+	statefulSets := &appsv1.StatefulSetList{}
+	if err := c.List(ctx, statefulSets, client.InNamespace(namespace)); err != nil {
+		return err
+	}
+
+	var workloads []client.Object
+	for i := range deployments.Items {
+		workloads = append(workloads, &deployments.Items[i])
+	}
+	for i := range statefulSets.Items {
+		workloads = append(workloads, &statefulSets.Items[i])
+	}
+	for _, workload := range workloads {
+		value, ok := workload.GetAnnotations()[failoverReplicasAnnotation]
+		if !ok {
+			continue
+		}
+		active, err := strconv.ParseInt(value, 10, 32)
+		if err != nil {
+			ctrl.LoggerFrom(ctx).Info("Ignoring invalid failover replicas annotation", "namespace", namespace, "name", workload.GetName(), "value", value)
+			continue
+		}
+		want := int32(active)
+		if role == failoverRoleStandby {
+			want = 0
+		}
+
+		replicas := workloadReplicas(workload)
+		if *replicas != nil && **replicas == want {
+			continue
+		}
+		patch := client.MergeFrom(workload.DeepCopyObject().(client.Object))
+		*replicas = &want
+		if err := c.Patch(ctx, workload, patch); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,282 @@
+// Tenant deletion
+// A finalizer holds deleted Tenants until the operator has removed their
+// namespace and child resources, or, with deletionPolicy: Orphan, released
+// them so they outlive the Tenant
+
+package main
+
+import (
+	"context"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	schedulingv1 "k8s.io/api/scheduling/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	platformv1alpha1 "github.com/xyz-company/tenant-operator/api/v1alpha1"
+)
+
+// tenantFinalizer holds a deleted Tenant until its resources are cleaned up
+const tenantFinalizer = "platform.xyz.com/tenant-finalizer"
+
+// ownedKinds are the kinds of every object the operator creates with a
+// Tenant as controller, so that deletionPolicy: Orphan releases all of them
+// and Delete removes those in the tenant namespaces. applyOrAdopt refuses
+// kinds missing here. Cluster-scoped kinds are only released with the Tenant
+// itself, never with one of its namespaces.
+var ownedKinds = []struct {
+	gvk           schema.GroupVersionKind
+	clusterScoped bool
+}{
+	{gvk: corev1.SchemeGroupVersion.WithKind("ResourceQuota")},
+	{gvk: corev1.SchemeGroupVersion.WithKind("LimitRange")},
+	{gvk: corev1.SchemeGroupVersion.WithKind("ServiceAccount")},
+	{gvk: corev1.SchemeGroupVersion.WithKind("Secret")},
+	{gvk: corev1.SchemeGroupVersion.WithKind("ConfigMap")},
+	{gvk: networkingv1.SchemeGroupVersion.WithKind("NetworkPolicy")},
+	{gvk: rbacv1.SchemeGroupVersion.WithKind("Role")},
+	{gvk: rbacv1.SchemeGroupVersion.WithKind("RoleBinding")},
+	{gvk: policyv1.SchemeGroupVersion.WithKind("PodDisruptionBudget")},
+	{gvk: schedulingv1.SchemeGroupVersion.WithKind("PriorityClass"), clusterScoped: true},
+	{gvk: authorizationPolicyGVK},
+	{gvk: peerAuthenticationGVK},
+	{gvk: sidecarGVK},
+	{gvk: serviceEntryGVK},
+	{gvk: serviceExportGVK},
+	{gvk: secretStoreGVK},
+	{gvk: gatewayGVK},
+	{gvk: certificateGVK},
+	{gvk: dnsEndpointGVK},
+	{gvk: appProjectGVK},
+	{gvk: veleroScheduleGVK},
+	{gvk: machineDeploymentGVK},
+	{gvk: alertmanagerConfigGVK},
+	{gvk: serviceMonitorGVK},
+	{gvk: podMonitorGVK},
+	{gvk: prometheusRuleGVK},
+	{gvk: flowGVK},
+	{gvk: clusterOutputGVK},
+	{gvk: kyvernoPolicyGVK},
+	{gvk: requiredLabelsGVK, clusterScoped: true},
+	{gvk: containerLimitsGVK, clusterScoped: true},
+	{gvk: hostNetworkingGVK, clusterScoped: true},
+	{gvk: clusterPropagationPolicyGVK, clusterScoped: true},
+	{gvk: manifestWorkGVK},
+	{gvk: ackRoleGVK},
+	{gvk: iamServiceAccountGVK},
+	{gvk: iamPartialPolicyGVK},
+	{gvk: userAssignedIdentityGVK},
+	{gvk: federatedIdentityCredentialGVK},
+	{gvk: roleAssignmentGVK},
+}
+
+// ownedKindInstalled reports whether the API server serves gvk. Built-in
+// kinds always are; the others come with optional integrations' CRDs.
+func (r *TenantReconciler) ownedKindInstalled(gvk schema.GroupVersionKind) (bool, error) {
+	if r.Scheme.Recognizes(gvk) {
+		return true, nil
+	}
+	return r.kindInstalled(gvk)
+}
+
+// isOwnedKind reports whether gvk is in ownedKinds
+func isOwnedKind(gvk schema.GroupVersionKind) bool {
+	for _, kind := range ownedKinds {
+		if kind.gvk == gvk {
+			return true
+		}
+	}
+	return false
+}
+
+// reconcileDelete adds the finalizer to live Tenants and cleans up deleted
+// ones according to Spec.DeletionPolicy. It reports whether it handled the
+// request, in which case the regular reconcile must not run.
+func (r *TenantReconciler) reconcileDelete(ctx context.Context, tenant *platformv1alpha1.Tenant) (bool, ctrl.Result, error) {
+	if tenant.DeletionTimestamp.IsZero() {
+		if controllerutil.AddFinalizer(tenant, tenantFinalizer) {
+			if err := r.Update(ctx, tenant); err != nil {
+				return false, ctrl.Result{}, err
+			}
+		}
+		return false, ctrl.Result{}, nil
+	}
+	if !controllerutil.ContainsFinalizer(tenant, tenantFinalizer) && !controllerutil.ContainsFinalizer(tenant, drainFinalizer) {
+		return true, ctrl.Result{}, nil
+	}
+
+	log := ctrl.LoggerFrom(ctx)
+	namespaces, err := r.ownedNamespaces(ctx, tenant)
+	if err != nil {
+		return true, ctrl.Result{}, err
+	}
+	if deletionProtected(tenant) {
+		// Removing the annotation triggers another reconcile
+		log.Info("Tenant has deletion protection, keeping its resources", "namespaces", namespaces)
+		r.Recorder.Eventf(tenant, corev1.EventTypeWarning, "DeletionProtected", "Remove the %s annotation to finish deleting the tenant", deletionProtectionAnnotation)
+		return true, ctrl.Result{}, nil
+	}
+	// Dedicated nodes return to the shared pool whatever the policy
+	if err := r.releaseDedicatedNodes(ctx, tenant, nil); err != nil {
+		return true, ctrl.Result{}, err
+	}
+	if deletionPolicy(&tenant.Spec) == platformv1alpha1.DeletionPolicyOrphan {
+		if err := r.orphanResources(ctx, tenant, metav1.NamespaceAll); err != nil {
+			return true, ctrl.Result{}, err
+		}
+		log.Info("Orphaned tenant resources", "namespaces", namespaces)
+		r.Recorder.Eventf(tenant, corev1.EventTypeNormal, "Orphaned", "Left namespace %s and its resources in place", strings.Join(namespaces, ", "))
+	} else {
+		// The tenant's DNS zone is released even where its namespaces stay
+		if err := r.deleteDNSEndpoints(ctx, tenant, namespaces); err != nil {
+			return true, ctrl.Result{}, err
+		}
+		// Adopted namespaces predate the Tenant and outlive it
+		adopted, namespaces, err := r.partitionAdopted(ctx, namespaces)
+		if err != nil {
+			return true, ctrl.Result{}, err
+		}
+		for _, namespace := range adopted {
+			if err := r.orphanResources(ctx, tenant, namespace); err != nil {
+				return true, ctrl.Result{}, err
+			}
+		}
+		if len(adopted) > 0 {
+			log.Info("Orphaned adopted namespaces", "namespaces", adopted)
+			r.Recorder.Eventf(tenant, corev1.EventTypeNormal, "Orphaned", "Left adopted namespace %s and its resources in place", strings.Join(adopted, ", "))
+		}
+
+		if r.DrainOnDelete {
+			drained := true
+			for _, namespace := range namespaces {
+				done, err := r.drainNamespace(ctx, tenant, namespace)
+				if err != nil {
+					return true, ctrl.Result{}, err
+				}
+				drained = drained && done
+			}
+			if !drained {
+				return true, ctrl.Result{RequeueAfter: drainPollInterval}, nil
+			}
+		}
+		for _, namespace := range namespaces {
+			if err := r.deleteResources(ctx, tenant, namespace); err != nil {
+				return true, ctrl.Result{}, err
+			}
+		}
+		if len(namespaces) > 0 {
+			log.Info("Deleted tenant resources", "namespaces", namespaces)
+			r.Recorder.Eventf(tenant, corev1.EventTypeNormal, "Deleted", "Deleted namespace %s and its resources", strings.Join(namespaces, ", "))
+		}
+		// With deletionPolicy: Orphan the role stays, so the namespaces keep
+		// reading their secrets
+		if tenant.Status.VaultRole != "" && r.Vault != nil && r.Vault.TokenFile != "" {
+			if err := r.Vault.DeleteRole(ctx, tenant); err != nil {
+				return true, ctrl.Result{}, err
+			}
+			log.Info("Deleted Vault role", "role", tenant.Status.VaultRole)
+		}
+	}
+
+	if r.Lifecycle != nil {
+		r.notify(ctx, tenant, LifecycleDeleted, "")
+	}
+	controllerutil.RemoveFinalizer(tenant, tenantFinalizer)
+	controllerutil.RemoveFinalizer(tenant, drainFinalizer)
+	return true, ctrl.Result{}, r.Update(ctx, tenant)
+}
+
+// deletionPolicy returns the effective Spec.DeletionPolicy
+func deletionPolicy(spec *platformv1alpha1.TenantSpec) platformv1alpha1.DeletionPolicy {
+	if spec.DeletionPolicy == "" {
+		return platformv1alpha1.DeletionPolicyDelete
+	}
+	return spec.DeletionPolicy
+}
+
+// deleteResources deletes the tenant's child resources in namespace, then
+// the namespace itself
+func (r *TenantReconciler) deleteResources(ctx context.Context, tenant *platformv1alpha1.Tenant, namespace string) error {
+	for _, kind := range ownedKinds {
+		if kind.clusterScoped {
+			continue
+		}
+		installed, err := r.ownedKindInstalled(kind.gvk)
+		if err != nil {
+			return err
+		}
+		if !installed {
+			continue
+		}
+		obj := &unstructured.Unstructured{}
+		obj.SetGroupVersionKind(kind.gvk)
+		err = r.DeleteAllOf(ctx, obj, client.InNamespace(namespace), client.MatchingLabels{tenantLabel: tenant.Name})
+		if err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+	}
+
+	ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: namespace}}
+	if err := r.Delete(ctx, ns); err != nil && !errors.IsNotFound(err) {
+		return err
+	}
+	return nil
+}
+
+// orphanResources removes the tenant's owner references from its resources
+// in namespace, so garbage collection keeps them once the Tenant is gone.
+// With metav1.NamespaceAll it releases all of them: those in every
+// namespace, including ones outside the tenant's, and cluster-scoped ones.
+func (r *TenantReconciler) orphanResources(ctx context.Context, tenant *platformv1alpha1.Tenant, namespace string) error {
+	for _, kind := range ownedKinds {
+		if kind.clusterScoped && namespace != metav1.NamespaceAll {
+			continue
+		}
+		installed, err := r.ownedKindInstalled(kind.gvk)
+		if err != nil {
+			return err
+		}
+		if !installed {
+			continue
+		}
+		list := &unstructured.UnstructuredList{}
+		list.SetGroupVersionKind(kind.gvk.GroupVersion().WithKind(kind.gvk.Kind + "List"))
+		opts := []client.ListOption{client.MatchingLabels{tenantLabel: tenant.Name}}
+		if !kind.clusterScoped {
+			opts = append(opts, client.InNamespace(namespace))
+		}
+		if err := r.List(ctx, list, opts...); err != nil {
+			return err
+		}
+
+		for i := range list.Items {
+			obj := &list.Items[i]
+			refs := obj.GetOwnerReferences()
+			kept := make([]metav1.OwnerReference, 0, len(refs))
+			for _, ref := range refs {
+				if ref.UID != tenant.UID {
+					kept = append(kept, ref)
+				}
+			}
+			if len(kept) == len(refs) {
+				continue
+			}
+
+			patch := client.MergeFrom(obj.DeepCopy())
+			obj.SetOwnerReferences(kept)
+			if err := r.Patch(ctx, obj, patch); err != nil && !errors.IsNotFound(err) {
+				return err
+			}
+		}
+	}
+	return nil
+}
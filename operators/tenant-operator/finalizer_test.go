@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+	corev1 "k8s.io/api/core/v1"
+	schedulingv1 "k8s.io/api/scheduling/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+
+	platformv1alpha1 "github.com/xyz-company/tenant-operator/api/v1alpha1"
+)
+
+// issueToken answers ServiceAccount TokenRequests, which the fake client
+// doesn't serve
+func issueToken(ctx context.Context, c client.Client, subResourceName string, obj, subResource client.Object, opts ...client.SubResourceCreateOption) error {
+	request, ok := subResource.(*authenticationv1.TokenRequest)
+	if subResourceName != "token" || !ok {
+		return c.SubResource(subResourceName).Create(ctx, obj, subResource, opts...)
+	}
+	request.Status.Token = "token-of-" + obj.GetName()
+	request.Status.ExpirationTimestamp = metav1.NewTime(time.Now().Add(time.Duration(*request.Spec.ExpirationSeconds) * time.Second))
+	return nil
+}
+
+// ownedBy lists, as "Kind namespace/name", the objects of every installed
+// owned kind that have an owner reference to uid
+func ownedBy(t *testing.T, r *TenantReconciler, uid types.UID) []string {
+	t.Helper()
+	var owned []string
+	for _, kind := range ownedKinds {
+		installed, err := r.ownedKindInstalled(kind.gvk)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !installed {
+			continue
+		}
+		list := &unstructured.UnstructuredList{}
+		list.SetGroupVersionKind(kind.gvk.GroupVersion().WithKind(kind.gvk.Kind + "List"))
+		if err := r.List(context.Background(), list); err != nil {
+			t.Fatal(err)
+		}
+		for _, obj := range list.Items {
+			for _, ref := range obj.GetOwnerReferences() {
+				if ref.UID == uid {
+					owned = append(owned, kind.gvk.Kind+" "+obj.GetNamespace()+"/"+obj.GetName())
+				}
+			}
+		}
+	}
+	return owned
+}
+
+func TestOrphanReleasesEveryOwnedResource(t *testing.T) {
+	ctx := context.Background()
+	value := int32(1000)
+	tenant := newTenant("search", "search-team")
+	tenant.UID = "search-uid"
+	tenant.Spec.DeletionPolicy = platformv1alpha1.DeletionPolicyOrphan
+	tenant.Spec.MeshDefaultDeny = true
+	tenant.Spec.PriorityClasses = []platformv1alpha1.TenantPriorityClass{{Name: "high", Value: &value}}
+	tenant.Spec.ServiceAccounts = []platformv1alpha1.TenantServiceAccount{{Name: "ci"}}
+	c := reconcilerClientBuilder(tenant).
+		WithRESTMapper(installedKinds(authorizationPolicyGVK, peerAuthenticationGVK, sidecarGVK)).
+		WithInterceptorFuncs(interceptor.Funcs{Patch: serverSideApply, SubResourceCreate: issueToken}).
+		Build()
+	r := newTestReconciler(c)
+	reconcileTenant(t, r, "search")
+
+	// Beyond the kinds the cleanup once knew: a cluster-scoped PriorityClass,
+	// pipeline ServiceAccounts and token Secrets, and Istio policies
+	owned := map[string]bool{}
+	for _, obj := range ownedBy(t, r, tenant.UID) {
+		owned[obj] = true
+	}
+	for _, want := range []string{
+		"ResourceQuota search/tenant-quota",
+		"PriorityClass /" + priorityClassName(tenant, tenant.Spec.PriorityClasses[0]),
+		"ServiceAccount search/ci",
+		"Secret search/ci-kubeconfig",
+		"AuthorizationPolicy search/deny-all",
+	} {
+		if !owned[want] {
+			t.Fatalf("owned before deletion = %v, want %s among them", owned, want)
+		}
+	}
+
+	if err := c.Delete(ctx, storedTenant(t, c, "search")); err != nil {
+		t.Fatal(err)
+	}
+	reconcileTenant(t, r, "search")
+	tenantGone(t, c, "search")
+
+	if owned := ownedBy(t, r, tenant.UID); len(owned) != 0 {
+		t.Fatalf("still owned by the deleted Tenant: %v", owned)
+	}
+	if !namespaceExists(t, c, "search") {
+		t.Fatal("namespace deleted with deletionPolicy: Orphan")
+	}
+	pc := &schedulingv1.PriorityClass{}
+	if err := c.Get(ctx, client.ObjectKey{Name: priorityClassName(tenant, tenant.Spec.PriorityClasses[0])}, pc); err != nil {
+		t.Fatalf("cluster-scoped PriorityClass not kept: %v", err)
+	}
+	if pc.Labels[tenantLabel] != "search" {
+		t.Errorf("PriorityClass labels = %v, want the tenant label kept", pc.Labels)
+	}
+}
+
+func TestApplyOrAdoptRefusesUnregisteredKind(t *testing.T) {
+	tenant := newTenant("search", "search-team")
+	c := newReconcilerClient(tenant)
+	svc := &corev1.Service{}
+	svc.Namespace, svc.Name = "search", "web"
+	err := newTestReconciler(c).applyOrAdopt(context.Background(), tenant, svc)
+	if want := "Service is not in ownedKinds, refusing to apply search/web"; err == nil || err.Error() != want {
+		t.Fatalf("applyOrAdopt() = %v, want %q", err, want)
+	}
+}
@@ -0,0 +1,333 @@
+// Tenant hierarchy
+// A Tenant can name a parent in Spec.Parent. It inherits the network policies
+// and access grants of its ancestors, and its quota is a slice of its
+// parent's: the children of a Tenant together get no more than its quota.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	platformv1alpha1 "github.com/xyz-company/tenant-operator/api/v1alpha1"
+)
+
+// parentLabel is set on the namespaces of a child Tenant to its parent
+const parentLabel = "platform.xyz.com/parent"
+
+// parentNotFoundError and parentCycleError are returned by tenantAncestors
+// for a broken Spec.Parent chain
+type parentNotFoundError struct{ tenant, parent string }
+
+func (e parentNotFoundError) Error() string {
+	return fmt.Sprintf("parent tenant %q of %q does not exist", e.parent, e.tenant)
+}
+
+type parentCycleError struct{ tenant string }
+
+func (e parentCycleError) Error() string {
+	return fmt.Sprintf("tenant %q is its own ancestor", e.tenant)
+}
+
+// tenantsByName indexes tenants by name, pointing into the slice
+func tenantsByName(tenants []platformv1alpha1.Tenant) map[string]*platformv1alpha1.Tenant {
+	byName := make(map[string]*platformv1alpha1.Tenant, len(tenants))
+	for i := range tenants {
+		byName[tenants[i].Name] = &tenants[i]
+	}
+	return byName
+}
+
+// tenantAncestors returns tenant's parent, grandparent and so on, nearest
+// first, looked up in byName
+func tenantAncestors(tenant *platformv1alpha1.Tenant, byName map[string]*platformv1alpha1.Tenant) ([]*platformv1alpha1.Tenant, error) {
+	var ancestors []*platformv1alpha1.Tenant
+	seen := map[string]bool{tenant.Name: true}
+	for child := tenant; child.Spec.Parent != ""; {
+		parent, ok := byName[child.Spec.Parent]
+		if !ok {
+			return nil, parentNotFoundError{tenant: child.Name, parent: child.Spec.Parent}
+		}
+		if seen[parent.Name] {
+			return nil, parentCycleError{tenant: parent.Name}
+		}
+		seen[parent.Name] = true
+		ancestors = append(ancestors, parent)
+		child = parent
+	}
+	return ancestors, nil
+}
+
+// tenantChildren returns the Tenants in tenants whose parent is name
+func tenantChildren(tenants []platformv1alpha1.Tenant, name string) []*platformv1alpha1.Tenant {
+	var children []*platformv1alpha1.Tenant
+	for i := range tenants {
+		if tenants[i].Spec.Parent == name && tenants[i].Name != name {
+			children = append(children, &tenants[i])
+		}
+	}
+	return children
+}
+
+// tenantDescendants returns the names of the children of name, their
+// children and so on
+func tenantDescendants(tenants []platformv1alpha1.Tenant, name string) []string {
+	var names []string
+	seen := map[string]bool{name: true}
+	for queue := []string{name}; len(queue) > 0; queue = queue[1:] {
+		for _, child := range tenantChildren(tenants, queue[0]) {
+			if seen[child.Name] {
+				continue
+			}
+			seen[child.Name] = true
+			names = append(names, child.Name)
+			queue = append(queue, child.Name)
+		}
+	}
+	return names
+}
+
+// applyParent merges what tenant inherits from parent into tenant.Spec:
+// the parent's network policies and access grants, and its cost center when
+// tenant has none. parent should have its own profile applied.
+func applyParent(tenant, parent *platformv1alpha1.Tenant) {
+	spec := &tenant.Spec
+	spec.NetworkIsolation = spec.NetworkIsolation || parent.Spec.NetworkIsolation
+	spec.MeshDefaultDeny = spec.MeshDefaultDeny || parent.Spec.MeshDefaultDeny
+	if spec.AllowIntraNamespace == nil && parent.Spec.AllowIntraNamespace != nil {
+		allow := *parent.Spec.AllowIntraNamespace
+		spec.AllowIntraNamespace = &allow
+	}
+
+	integrations := make(map[string]bool, len(spec.AllowedIntegrations))
+	for _, name := range spec.AllowedIntegrations {
+		integrations[name] = true
+	}
+	for _, name := range parent.Spec.AllowedIntegrations {
+		if !integrations[name] && name != tenant.Name {
+			integrations[name] = true
+			spec.AllowedIntegrations = append(spec.AllowedIntegrations, name)
+		}
+	}
+
+	// tenantAccess keeps the child's default grant once Access is no
+	// longer empty
+	spec.Access = append(append([]platformv1alpha1.TenantAccess{}, tenantAccess(tenant)...), tenantAccess(parent)...)
+
+	if spec.CostCenter == "" {
+		spec.CostCenter = parent.Spec.CostCenter
+	}
+}
+
+// tenantQuotaTotal returns tenant's ResourceQuota hard limits summed over
+// its namespaces
+func tenantQuotaTotal(tenant *platformv1alpha1.Tenant) (corev1.ResourceList, error) {
+	total := corev1.ResourceList{}
+	for _, namespace := range knownNamespaces(tenant) {
+		quota, err := tenantQuota(tenant, namespace)
+		if err != nil {
+			return nil, err
+		}
+		addResources(total, quota.Spec.Hard)
+	}
+	return total, nil
+}
+
+// validateChildQuotas rejects children whose quotas add up to more than
+// parent's. All of them should have their profiles applied.
+func validateChildQuotas(parent *platformv1alpha1.Tenant, children []*platformv1alpha1.Tenant) error {
+	if len(children) == 0 {
+		return nil
+	}
+	limit, err := tenantQuotaTotal(parent)
+	if err != nil {
+		return err
+	}
+	used := corev1.ResourceList{}
+	for _, child := range children {
+		total, err := tenantQuotaTotal(child)
+		if err != nil {
+			return fmt.Errorf("child tenant %q: %w", child.Name, err)
+		}
+		addResources(used, total)
+	}
+
+	names := make([]string, 0, len(limit))
+	for name := range limit {
+		names = append(names, string(name))
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		got, max := used[corev1.ResourceName(name)], limit[corev1.ResourceName(name)]
+		if got.Cmp(max) > 0 {
+			return fmt.Errorf("the %d children of tenant %q have %s %s between them, more than its %s", len(children), parent.Name, got.String(), name, max.String())
+		}
+	}
+	return nil
+}
+
+// profiledTenants returns copies of tenants with their profiles applied
+func profiledTenants(ctx context.Context, reader client.Reader, tenants []*platformv1alpha1.Tenant) ([]*platformv1alpha1.Tenant, error) {
+	profiled := make([]*platformv1alpha1.Tenant, 0, len(tenants))
+	for _, tenant := range tenants {
+		copied := tenant.DeepCopy()
+		if err := withProfile(ctx, reader, copied); err != nil {
+			return nil, err
+		}
+		profiled = append(profiled, copied)
+	}
+	return profiled, nil
+}
+
+// resolveParent checks that tenant and its siblings fit in their parent's
+// quota, then merges what tenant inherits from its ancestors into
+// tenant.Spec. Like resolveProfile it only changes the spec in memory, and
+// expects the profile to be applied already.
+func (r *TenantReconciler) resolveParent(ctx context.Context, tenant *platformv1alpha1.Tenant) error {
+	if tenant.Spec.Parent == "" {
+		return nil
+	}
+
+	tenants, err := listTenants(ctx, r.Client)
+	if err != nil {
+		return err
+	}
+	byName := tenantsByName(tenants)
+	byName[tenant.Name] = tenant
+	ancestors, err := tenantAncestors(tenant, byName)
+	if err != nil {
+		reason := "InvalidParent"
+		if _, ok := err.(parentNotFoundError); ok {
+			reason = "UnknownParent"
+		}
+		r.Recorder.Event(tenant, corev1.EventTypeWarning, reason, err.Error())
+		// Creating or fixing the parent triggers a new reconcile
+		return reconcile.TerminalError(err)
+	}
+	ancestors, err = profiledTenants(ctx, r.Client, ancestors)
+	if err != nil {
+		return err
+	}
+
+	var siblings []*platformv1alpha1.Tenant
+	for _, child := range tenantChildren(tenants, tenant.Spec.Parent) {
+		if child.Name != tenant.Name {
+			siblings = append(siblings, child)
+		}
+	}
+	siblings, err = profiledTenants(ctx, r.Client, siblings)
+	if err != nil {
+		return err
+	}
+	if err := validateChildQuotas(ancestors[0], append(siblings, tenant)); err != nil {
+		r.Recorder.Event(tenant, corev1.EventTypeWarning, "QuotaExceedsParent", err.Error())
+		return reconcile.TerminalError(err)
+	}
+
+	for _, ancestor := range ancestors {
+		applyParent(tenant, ancestor)
+	}
+	return nil
+}
+
+// tenantToRelatives maps a Tenant change to a reconcile of its descendants,
+// which inherit from it, and of its siblings, whose quotas share its
+// parent's
+func (r *TenantReconciler) tenantToRelatives(ctx context.Context, obj client.Object) []reconcile.Request {
+	tenant, ok := obj.(*platformv1alpha1.Tenant)
+	if !ok {
+		return nil
+	}
+	tenants, err := listTenants(ctx, r.Client)
+	if err != nil {
+		ctrl.LoggerFrom(ctx).Error(err, "Failed to list Tenants for hierarchy", "tenant", tenant.Name)
+		return nil
+	}
+
+	var requests []reconcile.Request
+	for _, name := range tenantDescendants(tenants, tenant.Name) {
+		requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Name: name}})
+	}
+	if tenant.Spec.Parent != "" {
+		for _, sibling := range tenantChildren(tenants, tenant.Spec.Parent) {
+			if sibling.Name != tenant.Name {
+				requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Name: sibling.Name}})
+			}
+		}
+	}
+	return requests
+}
+
+// validateHierarchy rejects a Spec.Parent that is missing or makes a cycle,
+// and quotas that take tenant and its siblings over their parent's quota,
+// or tenant's children over its own. tenant should have its profile applied.
+func (v *TenantValidator) validateHierarchy(ctx context.Context, tenant *platformv1alpha1.Tenant) error {
+	tenants, err := listTenants(ctx, v.Client)
+	if err != nil {
+		return err
+	}
+	byName := tenantsByName(tenants)
+	byName[tenant.Name] = tenant
+
+	if parent := tenant.Spec.Parent; parent != "" {
+		if parent == tenant.Name {
+			return fmt.Errorf("tenant %q can't be its own parent", tenant.Name)
+		}
+		if _, err := tenantAncestors(tenant, byName); err != nil {
+			if _, ok := err.(parentCycleError); ok {
+				return fmt.Errorf("parent %q is a descendant of tenant %q", parent, tenant.Name)
+			}
+			return err
+		}
+
+		parents, err := profiledTenants(ctx, v.Client, []*platformv1alpha1.Tenant{byName[parent]})
+		if err != nil {
+			return err
+		}
+		var siblings []*platformv1alpha1.Tenant
+		for _, child := range tenantChildren(tenants, parent) {
+			if child.Name != tenant.Name {
+				siblings = append(siblings, child)
+			}
+		}
+		siblings, err = profiledTenants(ctx, v.Client, siblings)
+		if err != nil {
+			return err
+		}
+		if err := validateChildQuotas(parents[0], append(siblings, tenant)); err != nil {
+			return err
+		}
+	}
+
+	children, err := profiledTenants(ctx, v.Client, tenantChildren(tenants, tenant.Name))
+	if err != nil {
+		return err
+	}
+	return validateChildQuotas(tenant, children)
+}
+
+// validateNoChildren rejects deleting a Tenant that is still a parent,
+// since its children can't be reconciled without it
+func (v *TenantValidator) validateNoChildren(ctx context.Context, tenant *platformv1alpha1.Tenant) error {
+	tenants, err := listTenants(ctx, v.Client)
+	if err != nil {
+		return err
+	}
+	children := tenantChildren(tenants, tenant.Name)
+	if len(children) == 0 {
+		return nil
+	}
+	names := make([]string, 0, len(children))
+	for _, child := range children {
+		names = append(names, child.Name)
+	}
+	return fmt.Errorf("tenant %q is the parent of %s; delete or reparent them first", tenant.Name, strings.Join(names, ", "))
+}
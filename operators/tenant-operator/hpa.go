@@ -0,0 +1,75 @@
+// HorizontalPodAutoscaler guardrail
+// The HPA webhook keeps maxReplicas at or below the tenant's ceiling, so one
+// runaway autoscaler can't consume the whole quota
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+// HPACeilingMode selects what happens to an HPA above the tenant's ceiling
+type HPACeilingMode string
+
+const (
+	// HPACeilingReject denies the request
+	HPACeilingReject HPACeilingMode = "reject"
+	// HPACeilingClamp lowers maxReplicas to the ceiling and admits the HPA
+	HPACeilingClamp HPACeilingMode = "clamp"
+)
+
+// HPAReplicasGuard enforces TenantSpec.MaxReplicasCeiling on HPA admission requests
+type HPAReplicasGuard struct {
+	Client  client.Client
+	Decoder *admission.Decoder
+	Mode    HPACeilingMode
+}
+
+// Handle rejects or clamps HPAs whose maxReplicas exceeds the ceiling of the
+// Tenant owning their namespace. Tenants without a ceiling are unlimited.
+func (g *HPAReplicasGuard) Handle(ctx context.Context, req admission.Request) admission.Response {
+	if req.Operation != admissionv1.Create && req.Operation != admissionv1.Update {
+		return admission.Allowed("")
+	}
+
+	tenant, err := tenantForNamespace(ctx, g.Client, req.Namespace)
+	if err != nil {
+		return admission.Errored(http.StatusInternalServerError, err)
+	}
+	if tenant == nil || tenant.Spec.MaxReplicasCeiling == 0 {
+		return admission.Allowed("")
+	}
+	ceiling := tenant.Spec.MaxReplicasCeiling
+
+	hpa := &autoscalingv2.HorizontalPodAutoscaler{}
+	if err := g.Decoder.Decode(req, hpa); err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+	if hpa.Spec.MaxReplicas <= ceiling {
+		return admission.Allowed("")
+	}
+
+	msg := fmt.Sprintf("maxReplicas %d exceeds the ceiling of %d for tenant %q", hpa.Spec.MaxReplicas, ceiling, tenant.Name)
+	if g.Mode != HPACeilingClamp {
+		return admission.Denied(msg)
+	}
+
+	hpa.Spec.MaxReplicas = ceiling
+	if hpa.Spec.MinReplicas != nil && *hpa.Spec.MinReplicas > ceiling {
+		hpa.Spec.MinReplicas = &ceiling
+	}
+	raw, err := json.Marshal(hpa)
+	if err != nil {
+		return admission.Errored(http.StatusInternalServerError, err)
+	}
+	resp := admission.PatchResponseFromRaw(req.Object.Raw, raw)
+	return resp.WithWarnings(msg + ", clamped")
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	platformv1alpha1 "github.com/xyz-company/tenant-operator/api/v1alpha1"
+)
+
+// hpaGuard returns an HPAReplicasGuard in mode for a "search" Tenant owning
+// the namespace "search" with a maxReplicas ceiling of ceiling
+func hpaGuard(mode HPACeilingMode, ceiling int32) *HPAReplicasGuard {
+	tenant := newTenant("search", "search-team")
+	tenant.Spec.MaxReplicasCeiling = ceiling
+	return &HPAReplicasGuard{
+		Client:  newFakeClient(tenant, labelledNamespace("search", "search")),
+		Decoder: admission.NewDecoder(scheme),
+		Mode:    mode,
+	}
+}
+
+// newHPA returns an HPA in namespace scaling between min, if set, and max replicas
+func newHPA(namespace string, min *int32, max int32) *autoscalingv2.HorizontalPodAutoscaler {
+	return &autoscalingv2.HorizontalPodAutoscaler{
+		ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: "web"},
+		Spec: autoscalingv2.HorizontalPodAutoscalerSpec{
+			ScaleTargetRef: autoscalingv2.CrossVersionObjectReference{APIVersion: "apps/v1", Kind: "Deployment", Name: "web"},
+			MinReplicas:    min,
+			MaxReplicas:    max,
+		},
+	}
+}
+
+// int32Ptr returns a pointer to n
+func int32Ptr(n int32) *int32 { return &n }
+
+func TestHPACeilingReject(t *testing.T) {
+	g := hpaGuard(HPACeilingReject, 10)
+	for _, op := range []admissionv1.Operation{admissionv1.Create, admissionv1.Update} {
+		resp := g.Handle(context.Background(), admissionRequest(t, op, newHPA("search", nil, 50), nil))
+		wantDenied(t, resp, `maxReplicas 50 exceeds the ceiling of 10 for tenant "search"`)
+	}
+
+	// At the ceiling is fine
+	resp := g.Handle(context.Background(), admissionRequest(t, admissionv1.Create, newHPA("search", nil, 10), nil))
+	wantAllowed(t, resp)
+	if len(resp.Patches) != 0 {
+		t.Fatalf("patches = %+v, want none", resp.Patches)
+	}
+}
+
+func TestHPACeilingClamp(t *testing.T) {
+	tests := []struct {
+		name    string
+		min     *int32
+		patches map[string]int32
+	}{
+		{name: "maxReplicas", min: int32Ptr(2), patches: map[string]int32{"/spec/maxReplicas": 10}},
+		{name: "minReplicas above the ceiling", min: int32Ptr(20), patches: map[string]int32{"/spec/maxReplicas": 10, "/spec/minReplicas": 10}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := hpaGuard(HPACeilingClamp, 10).Handle(context.Background(), admissionRequest(t, admissionv1.Create, newHPA("search", tt.min, 50), nil))
+			wantAllowed(t, resp)
+			if len(resp.Patches) != len(tt.patches) {
+				t.Fatalf("patches = %+v, want %v", resp.Patches, tt.patches)
+			}
+			for _, patch := range resp.Patches {
+				want, ok := tt.patches[patch.Path]
+				if !ok || patch.Operation != "replace" || patch.Value != float64(want) {
+					t.Fatalf("patch %+v, want %v", patch, tt.patches)
+				}
+			}
+			if len(resp.Warnings) != 1 || !strings.HasSuffix(resp.Warnings[0], "exceeds the ceiling of 10 for tenant \"search\", clamped") {
+				t.Fatalf("warnings = %q, want the clamp explained", resp.Warnings)
+			}
+		})
+	}
+}
+
+func TestHPACeilingUnlimited(t *testing.T) {
+	tests := []struct {
+		name      string
+		guard     *HPAReplicasGuard
+		namespace string
+		op        admissionv1.Operation
+	}{
+		{name: "no ceiling by default", guard: hpaGuard(HPACeilingReject, 0), namespace: "search", op: admissionv1.Create},
+		{name: "not a tenant namespace", guard: hpaGuard(HPACeilingReject, 10), namespace: "kube-system", op: admissionv1.Create},
+		{name: "delete", guard: hpaGuard(HPACeilingReject, 10), namespace: "search", op: admissionv1.Delete},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := tt.guard.Handle(context.Background(), admissionRequest(t, tt.op, newHPA(tt.namespace, nil, 1000), nil))
+			wantAllowed(t, resp)
+			if len(resp.Patches) != 0 {
+				t.Fatalf("patches = %+v, want none", resp.Patches)
+			}
+		})
+	}
+}
+
+func TestHPACeilingFromProfile(t *testing.T) {
+	profile := &platformv1alpha1.TenantProfile{
+		ObjectMeta: metav1.ObjectMeta{Name: "small"},
+		Spec:       platformv1alpha1.TenantProfileSpec{MaxReplicasCeiling: 5},
+	}
+	tenant := newTenant("search", "search-team")
+	tenant.Spec.Profile = "small"
+	g := &HPAReplicasGuard{
+		Client:  newFakeClient(profile, tenant, labelledNamespace("search", "search")),
+		Decoder: admission.NewDecoder(scheme),
+		Mode:    HPACeilingReject,
+	}
+	wantDenied(t, g.Handle(context.Background(), admissionRequest(t, admissionv1.Create, newHPA("search", nil, 6), nil)), "ceiling of 5")
+}
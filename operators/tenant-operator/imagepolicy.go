@@ -0,0 +1,167 @@
+// Image registry policy
+// Spec.ImagePolicy.AllowedRegistries limits where the tenant's pods may pull
+// images from. The pod validating webhook rejects pods, and ephemeral
+// containers added to them, with an image from anywhere else.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	platformv1alpha1 "github.com/xyz-company/tenant-operator/api/v1alpha1"
+)
+
+// defaultRegistry is where images without a registry host are pulled from
+const defaultRegistry = "docker.io"
+
+// imageRepository returns image without its tag or digest and with the
+// registry host spelled out, the way the container runtime resolves it:
+// nginx:1.25 is docker.io/library/nginx
+func imageRepository(image string) string {
+	repository, _, _ := strings.Cut(image, "@")
+	// A tag follows the last colon, unless that colon is a registry port
+	if i := strings.LastIndex(repository, ":"); i > strings.LastIndex(repository, "/") {
+		repository = repository[:i]
+	}
+
+	host, rest, ok := strings.Cut(repository, "/")
+	if !ok || (!strings.ContainsAny(host, ".:") && host != "localhost") {
+		if !strings.Contains(repository, "/") {
+			repository = "library/" + repository
+		}
+		return defaultRegistry + "/" + repository
+	}
+	if host == "index.docker.io" {
+		return defaultRegistry + "/" + rest
+	}
+	return repository
+}
+
+// validateImagePolicy rejects allowedRegistries entries that aren't a
+// registry host, optionally followed by a repository path
+func validateImagePolicy(policy *platformv1alpha1.TenantImagePolicy) error {
+	if policy == nil {
+		return nil
+	}
+	for _, registry := range policy.AllowedRegistries {
+		host, _, _ := strings.Cut(registry, "/")
+		hostname, port, hasPort := strings.Cut(host, ":")
+		if hasPort {
+			if n, err := strconv.Atoi(port); err != nil || len(validation.IsValidPortNum(n)) > 0 {
+				return fmt.Errorf("imagePolicy.allowedRegistries %q has an invalid port", registry)
+			}
+		}
+		switch {
+		case strings.Contains(registry, "://"):
+			return fmt.Errorf("imagePolicy.allowedRegistries %q must not include a scheme", registry)
+		case strings.HasSuffix(registry, "/") || strings.ContainsAny(registry, "@ "):
+			return fmt.Errorf("imagePolicy.allowedRegistries %q must be a registry host with an optional repository path, such as registry.xyz.com/team", registry)
+		case hostname != "localhost" && len(validation.IsDNS1123Subdomain(hostname)) > 0:
+			return fmt.Errorf("imagePolicy.allowedRegistries %q does not start with a registry host", registry)
+		case strings.Contains(strings.TrimPrefix(registry, host), ":"):
+			return fmt.Errorf("imagePolicy.allowedRegistries %q must not include a tag", registry)
+		}
+	}
+	return nil
+}
+
+// allowedImage reports whether image comes from one of registries: its
+// repository equals an entry or lies below it
+func allowedImage(registries []string, image string) bool {
+	repository := imageRepository(image)
+	for _, registry := range registries {
+		if registry == defaultRegistry || registry == "index.docker.io" {
+			registry = defaultRegistry
+		}
+		if repository == registry || strings.HasPrefix(repository, registry+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// PodImageValidator validates pod admission requests in tenant namespaces
+type PodImageValidator struct {
+	Client  client.Client
+	Decoder *admission.Decoder
+}
+
+// Handle rejects pods, and ephemeral containers added to them, using an
+// image outside the allowedRegistries of the Tenant owning their namespace.
+// On updates only images the pod didn't run already are checked, so pods
+// predating the policy can still be relabelled and deleted.
+func (v *PodImageValidator) Handle(ctx context.Context, req admission.Request) admission.Response {
+	if req.Operation != admissionv1.Create && req.Operation != admissionv1.Update {
+		return admission.Allowed("")
+	}
+
+	tenant, err := tenantForNamespace(ctx, v.Client, req.Namespace)
+	if err != nil {
+		return admission.Errored(http.StatusInternalServerError, err)
+	}
+	if tenant == nil || tenant.Spec.ImagePolicy == nil || len(tenant.Spec.ImagePolicy.AllowedRegistries) == 0 {
+		return admission.Allowed("")
+	}
+	registries := tenant.Spec.ImagePolicy.AllowedRegistries
+
+	pod := &corev1.Pod{}
+	if err := v.Decoder.Decode(req, pod); err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+	running := map[string]bool{}
+	if req.Operation == admissionv1.Update {
+		old := &corev1.Pod{}
+		if err := v.Decoder.DecodeRaw(req.OldObject, old); err != nil {
+			return admission.Errored(http.StatusBadRequest, err)
+		}
+		for _, image := range podImages(old) {
+			running[image] = true
+		}
+	}
+
+	var denied []string
+	check := func(name, image string) {
+		if !running[image] && !allowedImage(registries, image) {
+			denied = append(denied, fmt.Sprintf("%s (%s)", name, image))
+		}
+	}
+	for _, c := range pod.Spec.InitContainers {
+		check(c.Name, c.Image)
+	}
+	for _, c := range pod.Spec.Containers {
+		check(c.Name, c.Image)
+	}
+	for _, c := range pod.Spec.EphemeralContainers {
+		check(c.Name, c.Image)
+	}
+	if len(denied) == 0 {
+		return admission.Allowed("")
+	}
+	return admission.Denied(fmt.Sprintf("tenant %q only allows images from %s; not allowed: %s",
+		tenant.Name, strings.Join(registries, ", "), strings.Join(denied, ", ")))
+}
+
+// podImages returns the images of all of pod's containers
+func podImages(pod *corev1.Pod) []string {
+	var images []string
+	for _, c := range pod.Spec.InitContainers {
+		images = append(images, c.Image)
+	}
+	for _, c := range pod.Spec.Containers {
+		images = append(images, c.Image)
+	}
+	for _, c := range pod.Spec.EphemeralContainers {
+		images = append(images, c.Image)
+	}
+	return images
+}
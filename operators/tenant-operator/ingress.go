@@ -0,0 +1,252 @@
+// Tenant ingress
+// Spec.Ingress delegates a domain to the tenant, <tenant>.<base domain> by
+// default. The operator gives the tenant a Gateway API Gateway serving
+// *.<domain> over HTTPS, in its first namespace, with a wildcard
+// certificate from the platform ClusterIssuer. Routes from any of the
+// tenant's namespaces can attach to it. The route webhook keeps the
+// tenant's routes to hostnames within the domains it owns, so it can't
+// take over another tenant's traffic on a shared Gateway.
+// The Gateway API and cert-manager are optional, so the Gateway and the
+// certificate are skipped when their CRDs are missing.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	platformv1alpha1 "github.com/xyz-company/tenant-operator/api/v1alpha1"
+)
+
+var (
+	gatewayGVK     = schema.GroupVersionKind{Group: "gateway.networking.k8s.io", Version: "v1", Kind: "Gateway"}
+	certificateGVK = schema.GroupVersionKind{Group: "cert-manager.io", Version: "v1", Kind: "Certificate"}
+)
+
+// Names of the tenant Gateway and its certificate and TLS Secret
+const (
+	tenantGatewayName    = "tenant-gateway"
+	tenantGatewayTLSName = "tenant-gateway-tls"
+)
+
+// IngressConfig configures the tenant Gateways
+type IngressConfig struct {
+	// BaseDomain is the platform domain tenant domains default to a
+	// subdomain of (--ingress-base-domain)
+	BaseDomain string
+	// GatewayClass is the gatewayClassName of the tenant Gateways
+	GatewayClass string
+	// ClusterIssuer issues their wildcard certificates
+	ClusterIssuer string
+}
+
+// ingressDomain returns the domain delegated to tenant, "" without
+// Spec.Ingress
+func (c IngressConfig) ingressDomain(tenant *platformv1alpha1.Tenant) string {
+	if tenant.Spec.Ingress == nil {
+		return ""
+	}
+	if tenant.Spec.Ingress.Domain != "" {
+		return tenant.Spec.Ingress.Domain
+	}
+	return tenant.Name + "." + c.BaseDomain
+}
+
+// ownedDomains returns the domains the tenant may name in routes and
+// certificates: its ingress domain and its dnsZones
+func (c IngressConfig) ownedDomains(tenant *platformv1alpha1.Tenant) []string {
+	domains := certificateZones(tenant)
+	if domain := c.ingressDomain(tenant); domain != "" {
+		domains = append([]string{domain}, domains...)
+	}
+	return domains
+}
+
+// validateIngress rejects domains that aren't DNS names, or that lie
+// neither below the base domain nor within the tenant's dnsZones
+func (c IngressConfig) validateIngress(tenant *platformv1alpha1.Tenant) error {
+	if tenant.Spec.Ingress == nil {
+		return nil
+	}
+	if tenant.Spec.Ingress.Domain == "" && c.BaseDomain == "" {
+		return fmt.Errorf("ingress.domain is required, the operator runs without --ingress-base-domain")
+	}
+	domain := c.ingressDomain(tenant)
+	if errs := validation.IsDNS1123Subdomain(domain); len(errs) > 0 {
+		return fmt.Errorf("ingress.domain %q is invalid: %s", domain, strings.Join(errs, ", "))
+	}
+	if tenant.Spec.Ingress.Domain == "" {
+		return nil
+	}
+	if c.BaseDomain != "" && strings.HasSuffix(domain, "."+c.BaseDomain) {
+		return nil
+	}
+	if inZones(certificateZones(tenant), domain) {
+		return nil
+	}
+	return fmt.Errorf("ingress.domain %q must lie below %s or within certificates.dnsZones", domain, c.BaseDomain)
+}
+
+// reconcileIngress applies the tenant Gateway and its wildcard Certificate
+// in namespace, the tenant's first, or deletes both once the tenant no
+// longer has Spec.Ingress
+func (r *TenantReconciler) reconcileIngress(ctx context.Context, tenant *platformv1alpha1.Tenant, namespace string) error {
+	log := ctrl.LoggerFrom(ctx)
+	domain := r.Ingress.ingressDomain(tenant)
+
+	for _, object := range []*unstructured.Unstructured{
+		tenantCertificate(namespace, domain, r.Ingress.ClusterIssuer),
+		r.tenantGateway(tenant, namespace, domain),
+	} {
+		installed, err := r.kindInstalled(object.GroupVersionKind())
+		if err != nil {
+			return err
+		}
+		if !installed {
+			if domain != "" {
+				log.Info("CRD not installed, skipping", "namespace", namespace, "kind", object.GetKind())
+			}
+			continue
+		}
+
+		if domain == "" {
+			if err := r.deleteIfControlled(ctx, tenant, object); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := r.applyOrAdopt(ctx, tenant, object); err != nil {
+			return err
+		}
+		log.Info("Ingress resource applied", "namespace", namespace, "kind", object.GetKind(), "domain", domain)
+	}
+	return nil
+}
+
+// tenantCertificate returns the wildcard Certificate for domain, stored in
+// the tenant Gateway's TLS Secret
+func tenantCertificate(namespace, domain, issuer string) *unstructured.Unstructured {
+	certificate := &unstructured.Unstructured{Object: map[string]interface{}{
+		"spec": map[string]interface{}{
+			"secretName": tenantGatewayTLSName,
+			"dnsNames":   []interface{}{domain, "*." + domain},
+			"issuerRef": map[string]interface{}{
+				"kind": "ClusterIssuer",
+				"name": issuer,
+			},
+		},
+	}}
+	certificate.SetGroupVersionKind(certificateGVK)
+	certificate.SetNamespace(namespace)
+	certificate.SetName(tenantGatewayTLSName)
+	return certificate
+}
+
+// tenantGateway returns the Gateway serving *.domain over HTTPS for routes
+// in the tenant's namespaces
+func (r *TenantReconciler) tenantGateway(tenant *platformv1alpha1.Tenant, namespace, domain string) *unstructured.Unstructured {
+	gateway := &unstructured.Unstructured{Object: map[string]interface{}{
+		"spec": map[string]interface{}{
+			"gatewayClassName": r.Ingress.GatewayClass,
+			"listeners": []interface{}{
+				map[string]interface{}{
+					"name":     "https",
+					"protocol": "HTTPS",
+					"port":     int64(443),
+					"hostname": "*." + domain,
+					"tls": map[string]interface{}{
+						"mode": "Terminate",
+						"certificateRefs": []interface{}{
+							map[string]interface{}{"name": tenantGatewayTLSName},
+						},
+					},
+					"allowedRoutes": map[string]interface{}{
+						"namespaces": map[string]interface{}{
+							"from": "Selector",
+							"selector": map[string]interface{}{
+								"matchLabels": map[string]interface{}{tenantLabel: tenant.Name},
+							},
+						},
+					},
+				},
+			},
+		},
+	}}
+	gateway.SetGroupVersionKind(gatewayGVK)
+	gateway.SetNamespace(namespace)
+	gateway.SetName(tenantGatewayName)
+	return gateway
+}
+
+// RouteValidator validates Gateway API route admission requests in tenant
+// namespaces
+type RouteValidator struct {
+	Client  client.Client
+	Decoder *admission.Decoder
+	Ingress IngressConfig
+}
+
+// Handle rejects HTTPRoutes, GRPCRoutes and TLSRoutes with a hostname
+// outside the ingress domain and dnsZones of the Tenant owning their
+// namespace. Routes without hostnames take those of the listeners they
+// attach to, so they may only attach to Gateways in the tenant's own
+// namespaces.
+func (v *RouteValidator) Handle(ctx context.Context, req admission.Request) admission.Response {
+	if req.Operation != admissionv1.Create && req.Operation != admissionv1.Update {
+		return admission.Allowed("")
+	}
+
+	tenant, err := tenantForNamespace(ctx, v.Client, req.Namespace)
+	if err != nil {
+		return admission.Errored(http.StatusInternalServerError, err)
+	}
+	if tenant == nil {
+		return admission.Allowed("")
+	}
+
+	route := &unstructured.Unstructured{}
+	if err := v.Decoder.Decode(req, route); err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+	domains := v.Ingress.ownedDomains(tenant)
+
+	hostnames, _, _ := unstructured.NestedStringSlice(route.Object, "spec", "hostnames")
+	if len(hostnames) == 0 {
+		namespaces := knownNamespaces(tenant)
+		parents, _, _ := unstructured.NestedSlice(route.Object, "spec", "parentRefs")
+		for _, parent := range parents {
+			ref, _ := parent.(map[string]interface{})
+			namespace, _, _ := unstructured.NestedString(ref, "namespace")
+			if namespace != "" && !slices.Contains(namespaces, namespace) {
+				return admission.Denied(fmt.Sprintf("routes attaching to Gateways outside tenant %q must set hostnames", tenant.Name))
+			}
+		}
+		return admission.Allowed("")
+	}
+
+	if len(domains) == 0 {
+		return admission.Denied(fmt.Sprintf("tenant %q has no ingress domain or certificates.dnsZones, so its routes can't set hostnames", tenant.Name))
+	}
+	var denied []string
+	for _, hostname := range hostnames {
+		if !inZones(domains, hostname) {
+			denied = append(denied, hostname)
+		}
+	}
+	if len(denied) == 0 {
+		return admission.Allowed("")
+	}
+	return admission.Denied(fmt.Sprintf("tenant %q may only route hostnames within %s; not allowed: %s",
+		tenant.Name, strings.Join(domains, ", "), strings.Join(denied, ", ")))
+}
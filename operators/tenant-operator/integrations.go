@@ -0,0 +1,89 @@
+// Tenant integrations
+// AllowedIntegrations name other tenants; references to tenants that don't
+// exist are caught at admission and flagged again at reconcile time
+
+package main
+
+import (
+	"context"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	platformv1alpha1 "github.com/xyz-company/tenant-operator/api/v1alpha1"
+)
+
+// unknownIntegrations returns the entries of names that match neither a
+// Tenant nor a namespace
+func unknownIntegrations(ctx context.Context, reader client.Reader, names []string) ([]string, error) {
+	var unknown []string
+	for _, name := range names {
+		exists, err := tenantOrNamespaceExists(ctx, reader, name)
+		if err != nil {
+			return nil, err
+		}
+		if !exists {
+			unknown = append(unknown, name)
+		}
+	}
+	return unknown, nil
+}
+
+func tenantOrNamespaceExists(ctx context.Context, reader client.Reader, name string) (bool, error) {
+	if _, err := getTenant(ctx, reader, name); err == nil {
+		return true, nil
+	} else if !errors.IsNotFound(err) {
+		return false, err
+	}
+
+	ns := &corev1.Namespace{}
+	if err := reader.Get(ctx, types.NamespacedName{Name: name}, ns); err != nil {
+		if errors.IsNotFound(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// addedIntegrations returns the entries of current missing from previous
+func addedIntegrations(previous, current []string) []string {
+	seen := make(map[string]bool, len(previous))
+	for _, name := range previous {
+		seen[name] = true
+	}
+
+	var added []string
+	for _, name := range current {
+		if !seen[name] {
+			added = append(added, name)
+		}
+	}
+	return added
+}
+
+// reconcileIntegrations emits an UnknownIntegration Warning event on each
+// tenant namespace for every integration whose target no longer exists. It
+// never fails the reconcile; the target may simply not be created yet.
+func (r *TenantReconciler) reconcileIntegrations(ctx context.Context, tenant *platformv1alpha1.Tenant, namespaces []string) error {
+	unknown, err := unknownIntegrations(ctx, r.Client, tenant.Spec.AllowedIntegrations)
+	if err != nil || len(unknown) == 0 {
+		return err
+	}
+
+	for _, namespace := range namespaces {
+		ns := &corev1.Namespace{}
+		if err := r.Get(ctx, types.NamespacedName{Name: namespace}, ns); err != nil {
+			return err
+		}
+
+		ctrl.LoggerFrom(ctx).Info("Unknown integrations", "namespace", namespace, "integrations", unknown)
+		r.Recorder.Eventf(ns, corev1.EventTypeWarning, "UnknownIntegration",
+			"allowedIntegrations references tenants that don't exist: %s", strings.Join(unknown, ", "))
+	}
+	return nil
+}
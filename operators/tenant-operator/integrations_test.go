@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	platformv1alpha1 "github.com/xyz-company/tenant-operator/api/v1alpha1"
+)
+
+// integratingTenant returns the Tenant search allowing integrations with names
+func integratingTenant(names ...string) *platformv1alpha1.Tenant {
+	tenant := newTenant("search", "search-team")
+	tenant.Spec.AllowedIntegrations = names
+	return tenant
+}
+
+func TestValidateIntegrations(t *testing.T) {
+	existing := []client.Object{
+		newTenant("ads", "ads-team"),
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "monitoring"}},
+	}
+	tests := []struct {
+		name         string
+		allowUnknown bool
+		integrations []string
+		denied       string
+		warning      string
+	}{
+		{name: "none"},
+		{name: "existing tenant", integrations: []string{"ads"}},
+		{name: "existing namespace", integrations: []string{"monitoring"}},
+		{
+			name:         "unknown tenant",
+			integrations: []string{"ads", "payments", "adz"},
+			denied:       "allowedIntegrations references unknown tenants: payments, adz",
+		},
+		{
+			name:         "unknown tenant allowed",
+			allowUnknown: true,
+			integrations: []string{"payments"},
+			warning:      "allowedIntegrations references unknown tenants: payments",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newFakeClient(existing...)
+			v := &TenantValidator{Client: c, Reader: c, AllowUnknownIntegrations: tt.allowUnknown}
+
+			resp := v.Handle(context.Background(), admissionRequest(t, admissionv1.Create, integratingTenant(tt.integrations...), nil))
+			if tt.denied != "" {
+				wantDenied(t, resp, tt.denied)
+				return
+			}
+			wantAllowed(t, resp)
+			warned := strings.Join(resp.Warnings, "; ")
+			if tt.warning != "" && !strings.Contains(warned, tt.warning) {
+				t.Fatalf("warnings = %q, want %q", resp.Warnings, tt.warning)
+			}
+			if tt.warning == "" && strings.Contains(warned, "allowedIntegrations") {
+				t.Fatalf("warnings = %q, want none about integrations", resp.Warnings)
+			}
+		})
+	}
+}
+
+func TestValidateIntegrationsOnUpdate(t *testing.T) {
+	old := integratingTenant("payments")
+	c := newFakeClient(old, newTenant("ads", "ads-team"))
+	v := &TenantValidator{Client: c, Reader: c}
+
+	// An entry whose tenant was deleted since doesn't block other edits
+	updated := integratingTenant("payments", "ads")
+	wantAllowed(t, v.Handle(context.Background(), admissionRequest(t, admissionv1.Update, updated, old)))
+
+	// Entries added by the update are checked
+	updated = integratingTenant("payments", "billing")
+	wantDenied(t, v.Handle(context.Background(), admissionRequest(t, admissionv1.Update, updated, old)), "unknown tenants: billing")
+}
+
+func TestReconcileIntegrationsFlagsUnknownTargets(t *testing.T) {
+	c := newReconcilerClient(integratingTenant("ads", "payments"), newTenant("ads", "ads-team"))
+	r := newTestReconciler(c)
+	reconcileTenant(t, r, "search")
+
+	events := eventsWithReason(recordedEvents(r), "UnknownIntegration")
+	if len(events) != 1 || !strings.HasSuffix(events[0], "allowedIntegrations references tenants that don't exist: payments") {
+		t.Fatalf("UnknownIntegration events = %q, want one naming payments", events)
+	}
+	if phase := storedTenant(t, c, "search").Status.Phase; phase != "Ready" {
+		t.Fatalf("phase = %s, want Ready despite the unknown integration", phase)
+	}
+
+	// Once the target exists the warning stops
+	if err := c.Create(context.Background(), newTenant("payments", "payments-team")); err != nil {
+		t.Fatal(err)
+	}
+	reconcileTenant(t, r, "search")
+	if events := eventsWithReason(recordedEvents(r), "UnknownIntegration"); len(events) != 0 {
+		t.Fatalf("UnknownIntegration events = %q after the target was created", events)
+	}
+}
+
+func TestAddedIntegrations(t *testing.T) {
+	got := addedIntegrations([]string{"ads", "payments"}, []string{"payments", "billing", "ads", "search"})
+	if strings.Join(got, ",") != "billing,search" {
+		t.Fatalf("addedIntegrations() = %v, want [billing search]", got)
+	}
+	if got := addedIntegrations([]string{"ads"}, nil); len(got) != 0 {
+		t.Fatalf("addedIntegrations() = %v for removed entries, want none", got)
+	}
+}
@@ -0,0 +1,180 @@
+// Tenant inventory endpoint
+// Serves a read-only, filterable list of Tenants from the manager's cache so
+// the developer portal doesn't need cluster access
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"time"
+
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	platformv1alpha1 "github.com/xyz-company/tenant-operator/api/v1alpha1"
+)
+
+const (
+	defaultInventoryLimit = 100
+	maxInventoryLimit     = 1000
+)
+
+// InventoryServer serves GET /tenants, GET /tenants/export and GET
+// /tenants/chargeback
+type InventoryServer struct {
+	Addr string
+	// Reader serves the inventory from the manager cache
+	Reader client.Reader
+	// APIReader pages through the API server for exports
+	APIReader client.Reader
+	// ExportTokenFile holds the bearer token required for exports and
+	// chargeback reports; empty disables them
+	ExportTokenFile string
+}
+
+// TenantInventoryItem is the inventory view of a single Tenant
+type TenantInventoryItem struct {
+	Name       string                       `json:"name"`
+	Owner      string                       `json:"owner"`
+	CostCenter string                       `json:"costCenter,omitempty"`
+	Phase      string                       `json:"phase,omitempty"`
+	Quota      platformv1alpha1.TenantQuota `json:"quota"`
+	// RecommendedQuota is the advisory quota from Status, if any
+	RecommendedQuota *platformv1alpha1.RecommendedQuota `json:"recommendedQuota,omitempty"`
+}
+
+// TenantInventory is a page of inventory items
+type TenantInventory struct {
+	Items  []TenantInventoryItem `json:"items"`
+	Total  int                   `json:"total"`
+	Limit  int                   `json:"limit"`
+	Offset int                   `json:"offset"`
+}
+
+// inventoryQuery holds the parsed GET /tenants query parameters
+type inventoryQuery struct {
+	limit      int
+	offset     int
+	owner      string
+	costCenter string
+	phase      string
+}
+
+// Start runs the inventory HTTP server until ctx is cancelled
+func (s *InventoryServer) Start(ctx context.Context) error {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/tenants", s.handleList)
+	mux.HandleFunc("/tenants/export", s.handleExport)
+	mux.HandleFunc("/tenants/chargeback", s.handleChargeback)
+
+	srv := &http.Server{Addr: s.Addr, Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		<-ctx.Done()
+		srv.Shutdown(context.Background())
+	}()
+
+	if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		return err
+	}
+	return nil
+}
+
+// NeedLeaderElection lets every replica serve the inventory
+func (s *InventoryServer) NeedLeaderElection() bool {
+	return false
+}
+
+func (s *InventoryServer) handleList(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	query, err := parseInventoryQuery(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	tenants, err := listTenants(r.Context(), s.Reader)
+	if err != nil {
+		ctrl.Log.WithName("inventory").Error(err, "Failed to list Tenants")
+		http.Error(w, "Failed to list tenants", http.StatusInternalServerError)
+		return
+	}
+
+	json.NewEncoder(w).Encode(buildInventory(tenants, query))
+}
+
+// parseInventoryQuery validates the limit, offset and filter parameters
+func parseInventoryQuery(values url.Values) (inventoryQuery, error) {
+	query := inventoryQuery{
+		limit:      defaultInventoryLimit,
+		owner:      values.Get("owner"),
+		costCenter: values.Get("costCenter"),
+		phase:      values.Get("phase"),
+	}
+
+	if v := values.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxInventoryLimit {
+			return query, fmt.Errorf("limit must be an integer between 1 and %d", maxInventoryLimit)
+		}
+		query.limit = n
+	}
+	if v := values.Get("offset"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return query, fmt.Errorf("offset must be a non-negative integer")
+		}
+		query.offset = n
+	}
+
+	return query, nil
+}
+
+// buildInventory filters tenants, sorts them by name and returns the requested page
+func buildInventory(tenants []platformv1alpha1.Tenant, query inventoryQuery) TenantInventory {
+	items := []TenantInventoryItem{}
+	for _, t := range tenants {
+		if query.owner != "" && t.Spec.Owner != query.owner {
+			continue
+		}
+		if query.costCenter != "" && t.Spec.CostCenter != query.costCenter {
+			continue
+		}
+		if query.phase != "" && t.Status.Phase != query.phase {
+			continue
+		}
+		items = append(items, TenantInventoryItem{
+			Name:       t.Name,
+			Owner:      t.Spec.Owner,
+			CostCenter: t.Spec.CostCenter,
+			Phase:      t.Status.Phase,
+			Quota:      t.Spec.Quota,
+
+			RecommendedQuota: t.Status.RecommendedQuota,
+		})
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].Name < items[j].Name })
+
+	inventory := TenantInventory{Total: len(items), Limit: query.limit, Offset: query.offset}
+	start := query.offset
+	if start > len(items) {
+		start = len(items)
+	}
+	end := start + query.limit
+	if end > len(items) {
+		end = len(items)
+	}
+	inventory.Items = items[start:end]
+	return inventory
+}
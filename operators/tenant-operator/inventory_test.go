@@ -0,0 +1,148 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// inventoryTenants returns n Tenants named tenant-000 onwards, spread over
+// three owners, two cost centers and two phases
+func inventoryTenants(n int) []client.Object {
+	owners := []string{"search-team", "ads-team", "data-team"}
+	phases := []string{"Ready", "Failed"}
+	tenants := make([]client.Object, 0, n)
+	for i := 0; i < n; i++ {
+		tenant := newTenant(fmt.Sprintf("tenant-%03d", i), owners[i%3])
+		tenant.Spec.CostCenter = fmt.Sprintf("CC-%d", i%2)
+		tenant.Status.Phase = phases[i%4/2]
+		tenants = append(tenants, tenant)
+	}
+	return tenants
+}
+
+// getInventory serves GET /tenants?query from s
+func getInventory(t *testing.T, s *InventoryServer, query string) (*httptest.ResponseRecorder, TenantInventory) {
+	t.Helper()
+	w := httptest.NewRecorder()
+	s.handleList(w, httptest.NewRequest(http.MethodGet, "/tenants?"+query, nil))
+	var inventory TenantInventory
+	if w.Code == http.StatusOK {
+		if err := json.Unmarshal(w.Body.Bytes(), &inventory); err != nil {
+			t.Fatalf("decoding %s: %v", w.Body, err)
+		}
+	}
+	return w, inventory
+}
+
+func TestInventoryPagination(t *testing.T) {
+	s := &InventoryServer{Reader: newFakeClient(inventoryTenants(250)...)}
+
+	tests := []struct {
+		query       string
+		total       int
+		first, last string
+		count       int
+	}{
+		{"", 250, "tenant-000", "tenant-099", defaultInventoryLimit},
+		{"limit=10", 250, "tenant-000", "tenant-009", 10},
+		{"limit=10&offset=245", 250, "tenant-245", "tenant-249", 5},
+		{"offset=250", 250, "", "", 0},
+		{"offset=1000", 250, "", "", 0},
+		{"limit=1000", 250, "tenant-000", "tenant-249", 250},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			w, inventory := getInventory(t, s, tt.query)
+			if w.Code != http.StatusOK {
+				t.Fatalf("status = %d: %s", w.Code, w.Body)
+			}
+			if inventory.Total != tt.total || len(inventory.Items) != tt.count {
+				t.Fatalf("total %d with %d items, want %d with %d", inventory.Total, len(inventory.Items), tt.total, tt.count)
+			}
+			if tt.count > 0 && (inventory.Items[0].Name != tt.first || inventory.Items[tt.count-1].Name != tt.last) {
+				t.Fatalf("page %s..%s, want %s..%s", inventory.Items[0].Name, inventory.Items[tt.count-1].Name, tt.first, tt.last)
+			}
+		})
+	}
+}
+
+func TestInventoryPagesCoverEveryTenant(t *testing.T) {
+	s := &InventoryServer{Reader: newFakeClient(inventoryTenants(250)...)}
+
+	seen := map[string]bool{}
+	previous := ""
+	for offset := 0; offset < 250; offset += 30 {
+		_, inventory := getInventory(t, s, fmt.Sprintf("limit=30&offset=%d", offset))
+		for _, item := range inventory.Items {
+			if seen[item.Name] || item.Name <= previous {
+				t.Fatalf("%s out of order or repeated at offset %d", item.Name, offset)
+			}
+			seen[item.Name] = true
+			previous = item.Name
+		}
+	}
+	if len(seen) != 250 {
+		t.Fatalf("pages covered %d tenants, want 250", len(seen))
+	}
+}
+
+func TestInventoryFilters(t *testing.T) {
+	s := &InventoryServer{Reader: newFakeClient(inventoryTenants(250)...)}
+
+	tests := []struct {
+		query string
+		total int
+		match func(TenantInventoryItem) bool
+	}{
+		{"owner=search-team", 84, func(i TenantInventoryItem) bool { return i.Owner == "search-team" }},
+		{"costCenter=CC-1", 125, func(i TenantInventoryItem) bool { return i.CostCenter == "CC-1" }},
+		{"phase=Failed", 124, func(i TenantInventoryItem) bool { return i.Phase == "Failed" }},
+		{"owner=ads-team&costCenter=CC-0&phase=Ready", 21, func(i TenantInventoryItem) bool {
+			return i.Owner == "ads-team" && i.CostCenter == "CC-0" && i.Phase == "Ready"
+		}},
+		{"owner=nobody", 0, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			w, inventory := getInventory(t, s, tt.query+"&limit=1000")
+			if w.Code != http.StatusOK {
+				t.Fatalf("status = %d: %s", w.Code, w.Body)
+			}
+			if inventory.Total != tt.total || len(inventory.Items) != tt.total {
+				t.Fatalf("total %d with %d items, want %d", inventory.Total, len(inventory.Items), tt.total)
+			}
+			for _, item := range inventory.Items {
+				if !tt.match(item) {
+					t.Fatalf("%+v doesn't match %s", item, tt.query)
+				}
+			}
+		})
+	}
+
+	// Total counts the filtered tenants, not the page
+	_, inventory := getInventory(t, s, "owner=search-team&limit=5&offset=80")
+	if inventory.Total != 84 || len(inventory.Items) != 4 {
+		t.Fatalf("last filtered page: total %d with %d items, want 84 with 4", inventory.Total, len(inventory.Items))
+	}
+}
+
+func TestInventoryRejectsBadParams(t *testing.T) {
+	s := &InventoryServer{Reader: newFakeClient()}
+
+	for _, query := range []string{"limit=0", "limit=1001", "limit=ten", "offset=-1", "offset=first"} {
+		if w, _ := getInventory(t, s, query); w.Code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want %d", query, w.Code, http.StatusBadRequest)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	s.handleList(w, httptest.NewRequest(http.MethodPost, "/tenants", nil))
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("POST: status = %d, want %d", w.Code, http.StatusMethodNotAllowed)
+	}
+}
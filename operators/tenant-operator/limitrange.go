@@ -0,0 +1,115 @@
+// Container limits
+// Every tenant namespace gets a LimitRange, so containers that don't set
+// requests and limits still fit the ResourceQuota, and no single container
+// can claim most of the tenant's capacity
+
+package main
+
+import (
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	platformv1alpha1 "github.com/xyz-company/tenant-operator/api/v1alpha1"
+)
+
+// LimitRangeDefaults configures the tenant LimitRange (--limitrange-* flags)
+type LimitRangeDefaults struct {
+	// DefaultRequest and DefaultLimit are applied to containers that set none
+	DefaultRequestCPU    resource.Quantity
+	DefaultRequestMemory resource.Quantity
+	DefaultLimitCPU      resource.Quantity
+	DefaultLimitMemory   resource.Quantity
+
+	// MaxContainerPercent caps a container's limits at this share of the
+	// tenant's CPU and memory quota
+	MaxContainerPercent int
+}
+
+// tenantLimitRange returns the LimitRange for one of tenant's namespaces,
+// sized to its share of the quota. Defaults larger than the per-container
+// maximum are lowered to it, so pods relying on them are always admitted.
+func tenantLimitRange(tenant *platformv1alpha1.Tenant, namespace string, defaults LimitRangeDefaults) (*corev1.LimitRange, error) {
+	spec, err := namespaceQuota(tenant, namespace)
+	if err != nil {
+		return nil, err
+	}
+	cpu, err := parseQuotaQuantity("cpu", spec.CPU)
+	if err != nil {
+		return nil, err
+	}
+	memory, err := parseQuotaQuantity("memory", spec.Memory)
+	if err != nil {
+		return nil, err
+	}
+
+	percent := int64(defaults.MaxContainerPercent)
+	maxCPU := *resource.NewMilliQuantity(cpu.MilliValue()*percent/100, resource.DecimalSI)
+	maxMemory := *resource.NewQuantity(memory.Value()*percent/100, resource.BinarySI)
+
+	limitCPU := minQuantity(defaults.DefaultLimitCPU, maxCPU)
+	limitMemory := minQuantity(defaults.DefaultLimitMemory, maxMemory)
+
+	return &corev1.LimitRange{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "tenant-limits",
+			Namespace: namespace,
+		},
+		Spec: corev1.LimitRangeSpec{
+			Limits: []corev1.LimitRangeItem{
+				{
+					Type: corev1.LimitTypeContainer,
+					Default: corev1.ResourceList{
+						corev1.ResourceCPU:    limitCPU,
+						corev1.ResourceMemory: limitMemory,
+					},
+					DefaultRequest: corev1.ResourceList{
+						corev1.ResourceCPU:    minQuantity(defaults.DefaultRequestCPU, limitCPU),
+						corev1.ResourceMemory: minQuantity(defaults.DefaultRequestMemory, limitMemory),
+					},
+					Max: corev1.ResourceList{
+						corev1.ResourceCPU:    maxCPU,
+						corev1.ResourceMemory: maxMemory,
+					},
+				},
+			},
+		},
+	}, nil
+}
+
+// minQuantity returns the smaller of a and b
+func minQuantity(a, b resource.Quantity) resource.Quantity {
+	if a.Cmp(b) > 0 {
+		return b.DeepCopy()
+	}
+	return a.DeepCopy()
+}
+
+// parseLimitRangeDefaults parses the --limitrange-* flag values
+func parseLimitRangeDefaults(requestCPU, requestMemory, limitCPU, limitMemory string, maxContainerPercent int) (LimitRangeDefaults, error) {
+	if maxContainerPercent < 1 || maxContainerPercent > 100 {
+		return LimitRangeDefaults{}, fmt.Errorf("--limitrange-max-container-percent must be between 1 and 100, got %d", maxContainerPercent)
+	}
+
+	defaults := LimitRangeDefaults{MaxContainerPercent: maxContainerPercent}
+	values := []struct {
+		flag  string
+		value string
+		into  *resource.Quantity
+	}{
+		{"--limitrange-default-request-cpu", requestCPU, &defaults.DefaultRequestCPU},
+		{"--limitrange-default-request-memory", requestMemory, &defaults.DefaultRequestMemory},
+		{"--limitrange-default-limit-cpu", limitCPU, &defaults.DefaultLimitCPU},
+		{"--limitrange-default-limit-memory", limitMemory, &defaults.DefaultLimitMemory},
+	}
+	for _, v := range values {
+		q, err := resource.ParseQuantity(v.value)
+		if err != nil || q.Sign() <= 0 {
+			return LimitRangeDefaults{}, fmt.Errorf("%s must be a positive quantity, got %q", v.flag, v.value)
+		}
+		*v.into = q
+	}
+	return defaults, nil
+}
@@ -0,0 +1,261 @@
+// Log routing
+// Spec.Observability.LogDestination routes the container logs of the
+// tenant's namespaces to an Elasticsearch index or S3 bucket of its own,
+// through the Logging Operator:
+//
+//	ClusterOutput  tenant-<name> in --logging-namespace, writing to the
+//	               destination with the platform's credentials
+//	Flow           tenant-logs in each tenant namespace, sending all of
+//	               the namespace's logs, and nothing else, to it
+//
+// A Flow only ever selects logs of its own namespace, so no tenant's logs
+// reach another's destination. The credentials stay in the logging
+// namespace, out of the tenants' reach. The Logging Operator is optional,
+// so the routing is skipped when its CRDs are missing.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	platformv1alpha1 "github.com/xyz-company/tenant-operator/api/v1alpha1"
+)
+
+var (
+	clusterOutputGVK = schema.GroupVersionKind{Group: "logging.banzaicloud.io", Version: "v1beta1", Kind: "ClusterOutput"}
+	flowGVK          = schema.GroupVersionKind{Group: "logging.banzaicloud.io", Version: "v1beta1", Kind: "Flow"}
+)
+
+// tenantLogFlow names the Flow in each tenant namespace
+const tenantLogFlow = "tenant-logs"
+
+var (
+	// s3BucketName matches the names S3 allows for buckets
+	s3BucketName = regexp.MustCompile(`^[a-z0-9][a-z0-9.-]{1,61}[a-z0-9]$`)
+	// elasticsearchIndexInvalid matches the characters Elasticsearch
+	// doesn't allow in index names
+	elasticsearchIndexInvalid = regexp.MustCompile(`[\\/*?"<>| ,#:A-Z]`)
+)
+
+// LoggingConfig is the platform's side of tenant log routing: where the
+// Logging Operator runs and the log stores it writes to
+type LoggingConfig struct {
+	// Namespace is the Logging Operator's control namespace, holding the
+	// ClusterOutputs (--logging-namespace)
+	Namespace string
+	// ElasticsearchHosts lists the Elasticsearch nodes as
+	// scheme://host:port (--log-elasticsearch-hosts). Empty rejects
+	// elasticsearch destinations.
+	ElasticsearchHosts string
+	// ElasticsearchSecret, in Namespace, holds the username and password
+	// the logs are written with (--log-elasticsearch-secret)
+	ElasticsearchSecret string
+	// S3Region is the region of the tenant buckets (--log-s3-region).
+	// Empty rejects s3 destinations.
+	S3Region string
+	// S3Secret, in Namespace, holds the awsAccessKeyId and
+	// awsSecretAccessKey the logs are written with (--log-s3-secret).
+	// Empty uses the log forwarder's own AWS identity.
+	S3Secret string
+}
+
+// logIndex returns the Elasticsearch index of tenant's logs
+func logIndex(tenant *platformv1alpha1.Tenant) string {
+	if index := tenant.Spec.Observability.LogDestination.Index; index != "" {
+		return index
+	}
+	return "tenant-" + tenant.Name
+}
+
+// logDestination returns Spec.Observability.LogDestination, nil if unset
+func logDestination(tenant *platformv1alpha1.Tenant) *platformv1alpha1.TenantLogDestination {
+	if tenant.Spec.Observability == nil {
+		return nil
+	}
+	return tenant.Spec.Observability.LogDestination
+}
+
+// validateLogDestination rejects destinations the platform has no log
+// store for, indexes outside the tenant's own and invalid bucket names
+func (c *LoggingConfig) validateLogDestination(tenant *platformv1alpha1.Tenant) error {
+	destination := logDestination(tenant)
+	if destination == nil {
+		return nil
+	}
+	if destination.RetentionDays < 0 {
+		return fmt.Errorf("observability.logDestination.retentionDays must not be negative, got %d", destination.RetentionDays)
+	}
+	switch destination.Type {
+	case platformv1alpha1.LogDestinationElasticsearch:
+		if c.ElasticsearchHosts == "" {
+			return fmt.Errorf("observability.logDestination type elasticsearch needs the operator to run with --log-elasticsearch-hosts")
+		}
+		if destination.Bucket != "" {
+			return fmt.Errorf("observability.logDestination.bucket is for type s3")
+		}
+		own := "tenant-" + tenant.Name
+		if index := destination.Index; index != "" {
+			if index != own && !strings.HasPrefix(index, own+"-") {
+				return fmt.Errorf("observability.logDestination.index %q must be %s or start with %s-", index, own, own)
+			}
+			if elasticsearchIndexInvalid.MatchString(index) {
+				return fmt.Errorf("observability.logDestination.index %q must be lowercase without spaces or any of \\/*?\"<>|,#:", index)
+			}
+		}
+	case platformv1alpha1.LogDestinationS3:
+		if c.S3Region == "" {
+			return fmt.Errorf("observability.logDestination type s3 needs the operator to run with --log-s3-region")
+		}
+		if !s3BucketName.MatchString(destination.Bucket) {
+			return fmt.Errorf("observability.logDestination.bucket %q must be an S3 bucket name", destination.Bucket)
+		}
+		if destination.Index != "" {
+			return fmt.Errorf("observability.logDestination.index is for type elasticsearch")
+		}
+		if destination.RetentionDays > 0 {
+			return fmt.Errorf("observability.logDestination.retentionDays isn't supported for s3; set a lifecycle rule on the bucket")
+		}
+	default:
+		return fmt.Errorf("observability.logDestination.type must be elasticsearch or s3, got %q", destination.Type)
+	}
+	return nil
+}
+
+// secretValue references key of the Secret name, as Logging Operator
+// outputs take credentials
+func secretValue(name, key string) map[string]interface{} {
+	return map[string]interface{}{
+		"valueFrom": map[string]interface{}{
+			"secretKeyRef": map[string]interface{}{"name": name, "key": key},
+		},
+	}
+}
+
+// logRoutingObjects returns tenant's ClusterOutput, writing to its
+// destination, and the Flow of each of namespaces sending it all of the
+// namespace's logs
+func (c *LoggingConfig) logRoutingObjects(tenant *platformv1alpha1.Tenant, namespaces []string) []*unstructured.Unstructured {
+	destination := logDestination(tenant)
+	output := &unstructured.Unstructured{Object: map[string]interface{}{}}
+	output.SetGroupVersionKind(clusterOutputGVK)
+	output.SetNamespace(c.Namespace)
+	output.SetName("tenant-" + tenant.Name)
+
+	buffer := map[string]interface{}{"timekey": "1m", "timekey_wait": "30s", "timekey_use_utc": true}
+	switch destination.Type {
+	case platformv1alpha1.LogDestinationElasticsearch:
+		index := logIndex(tenant)
+		elasticsearch := map[string]interface{}{
+			"hosts":              c.ElasticsearchHosts,
+			"index_name":         index,
+			"suppress_type_name": true,
+			"buffer":             buffer,
+		}
+		if c.ElasticsearchSecret != "" {
+			elasticsearch["user"] = secretValue(c.ElasticsearchSecret, "username")
+			elasticsearch["password"] = secretValue(c.ElasticsearchSecret, "password")
+		}
+		if days := destination.RetentionDays; days > 0 {
+			policy, _ := json.Marshal(map[string]interface{}{
+				"policy": map[string]interface{}{
+					"phases": map[string]interface{}{
+						"hot":    map[string]interface{}{"actions": map[string]interface{}{"rollover": map[string]interface{}{"max_age": "1d"}}},
+						"delete": map[string]interface{}{"min_age": fmt.Sprintf("%dd", days), "actions": map[string]interface{}{"delete": map[string]interface{}{}}},
+					},
+				},
+			})
+			elasticsearch["enable_ilm"] = true
+			elasticsearch["ilm_policy_id"] = index
+			elasticsearch["ilm_policy"] = string(policy)
+			elasticsearch["ilm_policy_overwrite"] = true
+		}
+		output.Object["spec"] = map[string]interface{}{"elasticsearch": elasticsearch}
+
+	case platformv1alpha1.LogDestinationS3:
+		s3 := map[string]interface{}{
+			"s3_bucket": destination.Bucket,
+			"s3_region": c.S3Region,
+			// The tenant's prefix, should the bucket ever be shared
+			"path":   fmt.Sprintf("tenants/%s/${tag}/%%Y/%%m/%%d/", tenant.Name),
+			"buffer": buffer,
+		}
+		if c.S3Secret != "" {
+			s3["aws_key_id"] = secretValue(c.S3Secret, "awsAccessKeyId")
+			s3["aws_sec_key"] = secretValue(c.S3Secret, "awsSecretAccessKey")
+		}
+		output.Object["spec"] = map[string]interface{}{"s3": s3}
+	}
+
+	objects := []*unstructured.Unstructured{output}
+	for _, namespace := range namespaces {
+		flow := &unstructured.Unstructured{Object: map[string]interface{}{
+			"spec": map[string]interface{}{
+				"match":            []interface{}{map[string]interface{}{"select": map[string]interface{}{}}},
+				"globalOutputRefs": []interface{}{output.GetName()},
+			},
+		}}
+		flow.SetGroupVersionKind(flowGVK)
+		flow.SetNamespace(namespace)
+		flow.SetName(tenantLogFlow)
+		objects = append(objects, flow)
+	}
+	return objects
+}
+
+// reconcileLogRouting applies tenant's ClusterOutput and the Flows of
+// namespaces, or deletes them once the tenant no longer has a log
+// destination
+func (r *TenantReconciler) reconcileLogRouting(ctx context.Context, tenant *platformv1alpha1.Tenant, namespaces []string) error {
+	log := ctrl.LoggerFrom(ctx)
+	c := r.Logging
+	enabled := logDestination(tenant) != nil
+
+	var desired []*unstructured.Unstructured
+	if enabled {
+		desired = c.logRoutingObjects(tenant, namespaces)
+	}
+	// The Flows are deleted before the output they send to
+	for _, gvk := range []schema.GroupVersionKind{flowGVK, clusterOutputGVK} {
+		installed, err := r.kindInstalled(gvk)
+		if err != nil {
+			return err
+		}
+		if !installed {
+			if enabled {
+				log.Info("CRD not installed, skipping log routing", "kind", gvk.Kind)
+				return nil
+			}
+			continue
+		}
+		scope := namespaces
+		if gvk == clusterOutputGVK {
+			scope = []string{c.Namespace}
+		}
+		stale, err := staleObjects(ctx, r.Client, tenant, scope, gvk, desired)
+		if err != nil {
+			return err
+		}
+		for _, obj := range stale {
+			if err := r.deleteIfControlled(ctx, tenant, obj); err != nil {
+				return err
+			}
+		}
+	}
+	// and the output is applied before the Flows sending to it
+	for _, gvk := range []schema.GroupVersionKind{clusterOutputGVK, flowGVK} {
+		for _, obj := range ofKind(desired, gvk) {
+			if err := r.applyOrAdopt(ctx, tenant, obj); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
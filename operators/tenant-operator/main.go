@@ -7,21 +7,46 @@ package main
 import (
 	"context"
 	"flag"
+	"fmt"
+	"net"
+	"net/http"
 	"os"
+	"sort"
+	"strings"
+	"time"
 
+	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
 	networkingv1 "k8s.io/api/networking/v1"
+	policyv1 "k8s.io/api/policy/v1"
 	rbacv1 "k8s.io/api/rbac/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
 	"k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/healthz"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+	metricsserver "sigs.k8s.io/controller-runtime/pkg/metrics/server"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	platformv1alpha1 "github.com/xyz-company/tenant-operator/api/v1alpha1"
 )
 
 var (
@@ -31,109 +56,659 @@ var (
 
 func init() {
 	utilruntime.Must(clientgoscheme.AddToScheme(scheme))
-	// Add custom API types to scheme
-	// utilruntime.Must(platformv1alpha1.AddToScheme(scheme))
+	utilruntime.Must(platformv1alpha1.AddToScheme(scheme))
 }
 
-// TenantSpec defines the desired state of Tenant
-type TenantSpec struct {
-	Owner               string              `json:"owner"`
-	CostCenter          string              `json:"costCenter,omitempty"`
-	Quota               TenantQuota         `json:"quota,omitempty"`
-	AllowedIntegrations []string            `json:"allowedIntegrations,omitempty"`
-	Contacts            map[string]string   `json:"contacts,omitempty"`
+// listTenants returns every Tenant visible to reader
+func listTenants(ctx context.Context, reader client.Reader) ([]platformv1alpha1.Tenant, error) {
+	list := &platformv1alpha1.TenantList{}
+	if err := reader.List(ctx, list); err != nil {
+		return nil, err
+	}
+	return list.Items, nil
 }
 
-type TenantQuota struct {
-	CPU      string `json:"cpu,omitempty"`
-	Memory   string `json:"memory,omitempty"`
-	Pods     int    `json:"pods,omitempty"`
-	PVCs     int    `json:"pvcs,omitempty"`
-	Services int    `json:"services,omitempty"`
+// getTenant fetches the Tenant called name
+func getTenant(ctx context.Context, reader client.Reader, name string) (*platformv1alpha1.Tenant, error) {
+	tenant := &platformv1alpha1.Tenant{}
+	if err := reader.Get(ctx, types.NamespacedName{Name: name}, tenant); err != nil {
+		return nil, err
+	}
+	return tenant, nil
 }
 
-// TenantStatus defines the observed state of Tenant
-type TenantStatus struct {
-	Phase                  string `json:"phase,omitempty"`
-	NamespaceCreated       bool   `json:"namespaceCreated,omitempty"`
-	QuotaApplied           bool   `json:"quotaApplied,omitempty"`
-	NetworkPolicyApplied   bool   `json:"networkPolicyApplied,omitempty"`
-	RBACApplied            bool   `json:"rbacApplied,omitempty"`
+// tenantForNamespace returns the Tenant that namespace is labelled for, with
+// its profile applied, or nil if it isn't a tenant namespace or the Tenant
+// doesn't exist
+func tenantForNamespace(ctx context.Context, reader client.Reader, namespace string) (*platformv1alpha1.Tenant, error) {
+	ns := &corev1.Namespace{}
+	if err := reader.Get(ctx, types.NamespacedName{Name: namespace}, ns); err != nil {
+		return nil, client.IgnoreNotFound(err)
+	}
+	name, ok := ns.Labels[tenantLabel]
+	if !ok {
+		return nil, nil
+	}
+
+	tenant, err := getTenant(ctx, reader, name)
+	if errors.IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := withProfile(ctx, reader, tenant); err != nil {
+		return nil, err
+	}
+	return tenant, nil
 }
 
 // TenantReconciler reconciles a Tenant object
 type TenantReconciler struct {
 	client.Client
-	Scheme *runtime.Scheme
+	Scheme   *runtime.Scheme
+	Recorder record.EventRecorder
+
+	// Clusters, when set, fans each tenant out to additional clusters
+	// (--multi-cluster). Nil means this cluster only.
+	Clusters *ClusterTargets
+
+	// APIReader reads uncached, for objects the operator shouldn't watch
+	// cluster-wide, such as pods
+	APIReader client.Reader
+
+	// DrainOnDelete drains a deleted Tenant's namespace of pods before
+	// deleting it (--drain-on-delete), giving up after DrainTimeout
+	DrainOnDelete bool
+	DrainTimeout  time.Duration
+
+	// QuotaHeadroomPercent is added on top of peak usage for RecommendedQuota
+	QuotaHeadroomPercent int
+
+	// LimitRange configures the container defaults and maximums of the
+	// tenant LimitRange
+	LimitRange LimitRangeDefaults
+
+	// PlatformNamespaces are always reachable from tenants whose egress is
+	// restricted to their integrations
+	PlatformNamespaces []string
+	// PlatformEgressCIDRs are further platform endpoints, outside the
+	// cluster, always reachable from tenants with restricted egress
+	PlatformEgressCIDRs []string
+
+	// KubeconfigServer and KubeconfigCA are written into the kubeconfig
+	// Secrets of pipeline ServiceAccounts
+	KubeconfigServer string
+	KubeconfigCA     []byte
+
+	// ExpiryWarning is how long before its expiry a tenant is warned
+	// (--expiry-warning-days). ExpiryNotifier, when set, also notifies the
+	// tenant's contacts then.
+	ExpiryWarning  time.Duration
+	ExpiryNotifier *ExpiryNotifier
+
+	// Lifecycle, when set, notifies tenant contacts of the tenant's
+	// creation, quota nearing its limit, suspension and deletion
+	Lifecycle *LifecycleNotifier
+
+	// Exclusion names the namespaces the operator never provisions, adopts
+	// or watches
+	Exclusion NamespaceExclusion
+
+	// Limits sets the number of reconcile workers and the work queue's rate
+	// limiting
+	Limits ReconcileLimits
+
+	// SecretNamespace, the operator namespace, holds the Secrets mirrored
+	// into tenant namespaces: the ImagePullSecrets copied into all of them
+	// (--image-pull-secrets) and the shareable ones Tenants pick
+	SecretNamespace  string
+	ImagePullSecrets []string
+
+	// Vault, when set, backs the SecretStores of tenants with a secrets
+	// backend (--vault-addr). Nil rejects those tenants.
+	Vault *VaultClient
+
+	// Ingress configures the Gateways of tenants with Spec.Ingress
+	Ingress IngressConfig
+
+	// ArgoCDNamespace holds the AppProjects of tenants with
+	// Spec.GitOps.ArgoCD (--argocd-namespace)
+	ArgoCDNamespace string
+
+	// CloudIdentity, when set, provisions the cloud identities of tenants
+	// with Spec.CloudIdentity (--cloud-identity). Nil rejects those tenants.
+	CloudIdentity *CloudIdentityConfig
+
+	// ClusterAPINamespace holds the MachineDeployments of tenants with
+	// Spec.DedicatedNodes (--cluster-api-namespace)
+	ClusterAPINamespace string
+
+	// Spot, when set, identifies the spot nodes (--spot-node-label). Nil
+	// rejects tenants with Spec.Compute.SpotAllowed.
+	Spot *SpotConfig
+
+	// VeleroNamespace holds the backup Schedules of tenants with
+	// Spec.Backup (--velero-namespace)
+	VeleroNamespace string
+
+	// Logging routes the logs of tenants with
+	// Spec.Observability.LogDestination to their own index or bucket
+	Logging LoggingConfig
+
+	// Monitoring publishes the monitors, recording rules and dashboards of
+	// tenants with Spec.Observability.Metrics
+	Monitoring MonitoringConfig
+
+	// AlertFallbackWebhook receives the alerts of tenant namespaces that
+	// none of the tenant's contacts take (--alert-fallback-webhook)
+	AlertFallbackWebhook string
+	// PagerDutySecret, in the monitoring namespace, maps PagerDuty service
+	// names to their routing keys (--pagerduty-secret). Empty leaves
+	// pagerduty contacts unrouted.
+	PagerDutySecret string
+
+	// Policy gives every tenant policy engine constraints
+	// (--policy-engine)
+	Policy PolicyConfig
 }
 
 // Reconcile handles the reconciliation loop for Tenant resources
 func (r *TenantReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	// controller-runtime logs the reconcileID; the tenant field is the same
+	// in every log line about a Tenant, whichever helper writes it
+	log := ctrl.LoggerFrom(ctx).WithValues("tenant", req.Name)
+	ctx = ctrl.LoggerInto(ctx, log)
+	log.Info("Reconciling Tenant")
+
+	tenant := &platformv1alpha1.Tenant{}
+	if err := r.Get(ctx, req.NamespacedName, tenant); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+
+	handled, result, err := r.reconcileDelete(ctx, tenant)
+	if err != nil {
+		log.Error(err, "Failed to reconcile Tenant deletion")
+		return ctrl.Result{}, err
+	}
+	if handled {
+		return result, nil
+	}
+
+	expired, expiresIn, err := r.reconcileExpiry(ctx, tenant)
+	if err != nil {
+		log.Error(err, "Failed to reconcile Tenant expiry")
+		return ctrl.Result{}, err
+	}
+	if expired {
+		return ctrl.Result{}, nil
+	}
+
+	previous := tenant.Status.DeepCopy()
+	result, err = r.reconcileResources(ctx, tenant)
+	if statusErr := r.updateStatus(ctx, tenant, previous, err); statusErr != nil {
+		log.Error(statusErr, "Failed to update Tenant status")
+		if err == nil {
+			return ctrl.Result{}, statusErr
+		}
+	} else {
+		// Only once the status is written, so retries don't notify twice
+		r.notifyLifecycle(ctx, tenant, previous)
+	}
+	if err != nil {
+		reconcileErrors.WithLabelValues(tenant.Name).Inc()
+		r.Recorder.Event(tenant, corev1.EventTypeWarning, "ReconcileFailed", tenant.Status.Message)
+	}
+	if expiresIn > 0 && (result.RequeueAfter == 0 || expiresIn < result.RequeueAfter) {
+		result.RequeueAfter = expiresIn
+	}
+	return result, err
+}
+
+// reconcileResources creates the tenant's namespaces and child resources,
+// recording progress in tenant.Status as it goes
+func (r *TenantReconciler) reconcileResources(ctx context.Context, tenant *platformv1alpha1.Tenant) (ctrl.Result, error) {
 	log := ctrl.LoggerFrom(ctx)
-	log.Info("Reconciling Tenant", "name", req.Name)
-
-	// This is a simplified example - in production, you would:
-	// 1. Fetch the Tenant CR
-	// 2. Create namespace if not exists
-	// 3. Apply ResourceQuota
-	// 4. Apply LimitRange
-	// 5. Apply NetworkPolicies
-	// 6. Apply RBAC
-	// 7. Update status
-
-	// For now, we'll create resources based on the tenant name
-	tenantName := req.Name
-
-	// Create namespace
-	ns := &corev1.Namespace{
-		ObjectMeta: metav1.ObjectMeta{
-			Name: tenantName,
-			Labels: map[string]string{
-				"platform.xyz.com/tenant":                     tenantName,
-				"istio-injection":                             "enabled",
-				"pod-security.kubernetes.io/enforce":          "restricted",
-			},
-		},
+	resetProgress(&tenant.Status)
+
+	limitRangeDefaults, err := r.resolveProfile(ctx, tenant)
+	if err != nil {
+		log.Error(err, "Failed to resolve TenantProfile", "profile", tenant.Spec.Profile)
+		return ctrl.Result{}, err
+	}
+
+	if err := r.resolveParent(ctx, tenant); err != nil {
+		log.Error(err, "Failed to resolve parent Tenant", "parent", tenant.Spec.Parent)
+		return ctrl.Result{}, err
+	}
+
+	// Checked before removeStaleNamespaces, so a bad edit removes nothing
+	if err := validateNamespaces(tenant); err != nil {
+		r.Recorder.Event(tenant, corev1.EventTypeWarning, "InvalidNamespaces", err.Error())
+		return ctrl.Result{}, reconcile.TerminalError(err)
+	}
+
+	namespaces := tenantNamespaces(tenant)
+	for _, namespace := range namespaces {
+		if r.Exclusion.Excluded(namespace) {
+			err := fmt.Errorf("namespace %s is excluded from management", namespace)
+			r.Recorder.Event(tenant, corev1.EventTypeWarning, "ExcludedNamespace", err.Error())
+			return ctrl.Result{}, reconcile.TerminalError(err)
+		}
+	}
+	adoptable, err := r.adoptableNamespaces(ctx, tenant, namespaces)
+	if err != nil {
+		log.Error(err, "Failed to find namespaces to adopt")
+		return ctrl.Result{}, err
+	}
+	namespaces = append(namespaces, adoptable...)
+	if err := r.removeStaleNamespaces(ctx, tenant, namespaces); err != nil {
+		log.Error(err, "Failed to remove namespaces no longer in the spec")
+		return ctrl.Result{}, err
+	}
+
+	// The classes must exist before the quotas allowing pods to use them
+	if err := validatePriorityClasses(tenant); err != nil {
+		r.Recorder.Event(tenant, corev1.EventTypeWarning, "InvalidPriorityClasses", err.Error())
+		return ctrl.Result{}, reconcile.TerminalError(err)
+	}
+	if err := r.reconcilePriorityClasses(ctx, tenant); err != nil {
+		log.Error(err, "Failed to apply PriorityClasses")
+		return ctrl.Result{}, err
 	}
 
-	if err := r.Create(ctx, ns); err != nil {
-		if !errors.IsAlreadyExists(err) {
-			log.Error(err, "Failed to create namespace")
+	// The role must exist before the SecretStores log in with it
+	if err := r.Vault.validateSecretsBackend(tenant); err != nil {
+		r.Recorder.Event(tenant, corev1.EventTypeWarning, "InvalidSecretsBackend", err.Error())
+		return ctrl.Result{}, reconcile.TerminalError(err)
+	}
+	if err := r.reconcileVaultRole(ctx, tenant, namespaces); err != nil {
+		log.Error(err, "Failed to write Vault role")
+		return ctrl.Result{}, err
+	}
+	if err := r.Ingress.validateIngress(tenant); err != nil {
+		r.Recorder.Event(tenant, corev1.EventTypeWarning, "InvalidIngress", err.Error())
+		return ctrl.Result{}, reconcile.TerminalError(err)
+	}
+	if err := validateArgoCD(tenant.Spec.GitOps); err != nil {
+		r.Recorder.Event(tenant, corev1.EventTypeWarning, "InvalidGitOps", err.Error())
+		return ctrl.Result{}, reconcile.TerminalError(err)
+	}
+	if err := validatePlacement(tenant.Spec.Placement); err != nil {
+		r.Recorder.Event(tenant, corev1.EventTypeWarning, "InvalidPlacement", err.Error())
+		return ctrl.Result{}, reconcile.TerminalError(err)
+	}
+	if err := validateBudget(tenant.Spec.Budget); err != nil {
+		r.Recorder.Event(tenant, corev1.EventTypeWarning, "InvalidBudget", err.Error())
+		return ctrl.Result{}, reconcile.TerminalError(err)
+	}
+	if err := r.CloudIdentity.validateCloudIdentity(tenant); err != nil {
+		r.Recorder.Event(tenant, corev1.EventTypeWarning, "InvalidCloudIdentity", err.Error())
+		return ctrl.Result{}, reconcile.TerminalError(err)
+	}
+	if err := validateDedicatedNodes(tenant.Spec.DedicatedNodes); err != nil {
+		r.Recorder.Event(tenant, corev1.EventTypeWarning, "InvalidDedicatedNodes", err.Error())
+		return ctrl.Result{}, reconcile.TerminalError(err)
+	}
+	if err := r.Spot.validateCompute(tenant.Spec.Compute); err != nil {
+		r.Recorder.Event(tenant, corev1.EventTypeWarning, "InvalidCompute", err.Error())
+		return ctrl.Result{}, reconcile.TerminalError(err)
+	}
+	if err := validateBackup(tenant.Spec.Backup); err != nil {
+		r.Recorder.Event(tenant, corev1.EventTypeWarning, "InvalidBackup", err.Error())
+		return ctrl.Result{}, reconcile.TerminalError(err)
+	}
+	if err := r.Logging.validateLogDestination(tenant); err != nil {
+		r.Recorder.Event(tenant, corev1.EventTypeWarning, "InvalidObservability", err.Error())
+		return ctrl.Result{}, reconcile.TerminalError(err)
+	}
+	if err := validateMetrics(tenant.Spec.Observability); err != nil {
+		r.Recorder.Event(tenant, corev1.EventTypeWarning, "InvalidObservability", err.Error())
+		return ctrl.Result{}, reconcile.TerminalError(err)
+	}
+	if err := validateContacts(tenant.Spec.Contacts); err != nil {
+		r.Recorder.Event(tenant, corev1.EventTypeWarning, "InvalidContacts", err.Error())
+		return ctrl.Result{}, reconcile.TerminalError(err)
+	}
+	if err := validatePolicy(tenant.Spec.Policy); err != nil {
+		r.Recorder.Event(tenant, corev1.EventTypeWarning, "InvalidPolicy", err.Error())
+		return ctrl.Result{}, reconcile.TerminalError(err)
+	}
+
+	// Namespaces are reconciled in order and the first failure stops the
+	// pass; the status flags describe the namespace reconciled last
+	var rotateIn time.Duration
+	tenant.Status.Namespaces = make([]platformv1alpha1.NamespaceStatus, 0, len(namespaces))
+	for i, namespace := range namespaces {
+		resetProgress(&tenant.Status)
+		status := platformv1alpha1.NamespaceStatus{Name: namespace}
+		next, err := r.reconcileNamespace(ctx, tenant, namespace, limitRangeDefaults, &status)
+		if err != nil {
+			status.Message = err.Error()
+			tenant.Status.Namespaces = append(tenant.Status.Namespaces, status)
+			for _, pending := range namespaces[i+1:] {
+				tenant.Status.Namespaces = append(tenant.Status.Namespaces, platformv1alpha1.NamespaceStatus{
+					Name:    pending,
+					Message: fmt.Sprintf("Waiting for namespace %s", namespace),
+				})
+			}
 			return ctrl.Result{}, err
 		}
+		status.Ready = true
+		tenant.Status.Namespaces = append(tenant.Status.Namespaces, status)
+		if next > 0 && (rotateIn == 0 || next < rotateIn) {
+			rotateIn = next
+		}
+	}
+	tenant.Status.Adopted = false
+	for _, status := range tenant.Status.Namespaces {
+		tenant.Status.Adopted = tenant.Status.Adopted || status.Adopted
+	}
+	tenant.Status.RecommendedQuota = nil
+	if len(namespaces) == 1 {
+		tenant.Status.RecommendedQuota = tenant.Status.Namespaces[0].RecommendedQuota
 	}
-	log.Info("Namespace created/exists", "namespace", tenantName)
 
-	// Create ResourceQuota
-	quota := &corev1.ResourceQuota{
-		ObjectMeta: metav1.ObjectMeta{
-			Name:      "tenant-quota",
-			Namespace: tenantName,
-		},
-		Spec: corev1.ResourceQuotaSpec{
-			Hard: corev1.ResourceList{
-				corev1.ResourceRequestsCPU:    resource.MustParse("10"),
-				corev1.ResourceRequestsMemory: resource.MustParse("20Gi"),
-				corev1.ResourceLimitsCPU:      resource.MustParse("20"),
-				corev1.ResourceLimitsMemory:   resource.MustParse("40Gi"),
-				corev1.ResourcePods:           resource.MustParse("100"),
-			},
-		},
+	// Routes in any tenant namespace attach to the Gateway in the first
+	if err := r.reconcileIngress(ctx, tenant, namespaces[0]); err != nil {
+		log.Error(err, "Failed to reconcile tenant Gateway")
+		return ctrl.Result{}, err
+	}
+	dnsIn, err := r.reconcileDNS(ctx, tenant, namespaces[0])
+	if err != nil {
+		log.Error(err, "Failed to reconcile tenant DNSEndpoint")
+		return ctrl.Result{}, err
+	}
+	if dnsIn > 0 && (rotateIn == 0 || dnsIn < rotateIn) {
+		rotateIn = dnsIn
+	}
+	if err := r.reconcileAppProject(ctx, tenant, namespaces); err != nil {
+		log.Error(err, "Failed to apply Argo CD AppProject")
+		return ctrl.Result{}, err
+	}
+	identityIn, err := r.reconcileCloudIdentity(ctx, tenant, namespaces)
+	if err != nil {
+		log.Error(err, "Failed to reconcile cloud identity")
+		return ctrl.Result{}, err
+	}
+	if identityIn > 0 && (rotateIn == 0 || identityIn < rotateIn) {
+		rotateIn = identityIn
+	}
+	nodesIn, err := r.reconcileDedicatedNodes(ctx, tenant, namespaces)
+	if err != nil {
+		log.Error(err, "Failed to reconcile dedicated nodes")
+		return ctrl.Result{}, err
+	}
+	if nodesIn > 0 && (rotateIn == 0 || nodesIn < rotateIn) {
+		rotateIn = nodesIn
+	}
+	backupIn, err := r.reconcileBackup(ctx, tenant, namespaces)
+	if err != nil {
+		log.Error(err, "Failed to reconcile backup schedule")
+		return ctrl.Result{}, err
+	}
+	if backupIn > 0 && (rotateIn == 0 || backupIn < rotateIn) {
+		rotateIn = backupIn
+	}
+	if err := r.reconcileLogRouting(ctx, tenant, namespaces); err != nil {
+		log.Error(err, "Failed to reconcile log routing")
+		return ctrl.Result{}, err
+	}
+	if err := r.reconcileMonitoring(ctx, tenant, namespaces); err != nil {
+		log.Error(err, "Failed to reconcile tenant monitoring")
+		return ctrl.Result{}, err
+	}
+	if err := r.reconcileAlertRouting(ctx, tenant, namespaces); err != nil {
+		log.Error(err, "Failed to reconcile alert routing")
+		return ctrl.Result{}, err
+	}
+	policyIn, err := r.reconcilePolicy(ctx, tenant, namespaces)
+	if err != nil {
+		log.Error(err, "Failed to reconcile policy constraints")
+		return ctrl.Result{}, err
+	}
+	if policyIn > 0 && (rotateIn == 0 || policyIn < rotateIn) {
+		rotateIn = policyIn
+	}
+
+	if err := r.reconcileQuotaUsage(ctx, tenant, namespaces); err != nil {
+		log.Error(err, "Failed to check quota usage")
+		return ctrl.Result{}, err
+	}
+
+	if err := r.reconcileIntegrations(ctx, tenant, namespaces); err != nil {
+		log.Error(err, "Failed to check integrations")
+		return ctrl.Result{}, err
+	}
+
+	if err := r.reconcileIntegrationPolicies(ctx, tenant); err != nil {
+		log.Error(err, "Failed to reconcile integration NetworkPolicies")
+		return ctrl.Result{}, err
 	}
 
-	if err := r.Create(ctx, quota); err != nil {
-		if !errors.IsAlreadyExists(err) {
-			log.Error(err, "Failed to create ResourceQuota")
+	if err := r.reconcileSpecHash(ctx, tenant, namespaces); err != nil {
+		log.Error(err, "Failed to record spec hash")
+		return ctrl.Result{}, err
+	}
+
+	if r.Clusters != nil {
+		failoverIn, err := r.reconcileFailover(ctx, tenant)
+		if err != nil {
+			log.Error(err, "Failed to reconcile failover")
+			return ctrl.Result{}, err
+		}
+		if failoverIn > 0 && (rotateIn == 0 || failoverIn < rotateIn) {
+			rotateIn = failoverIn
+		}
+		statuses, reached := r.reconcileClusters(ctx, tenant, limitRangeDefaults)
+		discoveryIn, err := r.reconcileServiceDiscovery(ctx, tenant, namespaces, reached, statuses)
+		if err != nil {
+			log.Error(err, "Failed to reconcile cross-cluster service discovery")
 			return ctrl.Result{}, err
 		}
+		if discoveryIn > 0 && (rotateIn == 0 || discoveryIn < rotateIn) {
+			rotateIn = discoveryIn
+		}
+		tenant.Status.Clusters = statuses
+		for _, cluster := range tenant.Status.Clusters {
+			if !cluster.Ready && (rotateIn == 0 || clusterRetryInterval < rotateIn) {
+				return ctrl.Result{RequeueAfter: clusterRetryInterval}, nil
+			}
+		}
 	}
-	log.Info("ResourceQuota created/exists", "namespace", tenantName)
+
+	// Come back when the next pipeline token is due for rotation, to
+	// publish the Gateway's DNS, to fail over, to pick up the Services of
+	// other clusters, to record the latest backup or the policy violations
+	return ctrl.Result{RequeueAfter: rotateIn}, nil
+}
+
+// reconcileNamespace creates one tenant namespace and its child resources,
+// recording namespace-level results in status. It returns when the next
+// pipeline token in the namespace is due for rotation, 0 if none is.
+func (r *TenantReconciler) reconcileNamespace(ctx context.Context, tenant *platformv1alpha1.Tenant, namespace string, limitRangeDefaults LimitRangeDefaults, status *platformv1alpha1.NamespaceStatus) (time.Duration, error) {
+	log := ctrl.LoggerFrom(ctx).WithValues("namespace", namespace)
+
+	// Apply namespace. It isn't owned by the Tenant; the finalizer deletes it.
+	ns := tenantNamespace(tenant, namespace)
+	existing := &corev1.Namespace{}
+	err := r.Get(ctx, types.NamespacedName{Name: namespace}, existing)
+	if err != nil && !errors.IsNotFound(err) {
+		return 0, err
+	}
+	created := errors.IsNotFound(err)
+	owner, labelled := existing.Labels[tenantLabel]
+	if labelled && owner != tenant.Name {
+		return 0, fmt.Errorf("namespace %s belongs to tenant %q", namespace, owner)
+	}
+	status.Adopted = !created && existing.Annotations[adoptAnnotation] == tenant.Name
+	if status.Adopted {
+		ns.Annotations[adoptedAnnotation] = "true"
+	}
+	if err := r.apply(ctx, ns); err != nil {
+		log.Error(err, "Failed to apply namespace")
+		return 0, err
+	}
+	tenant.Status.NamespaceCreated = true
+	log.Info("Namespace applied")
+	if status.Adopted && !labelled {
+		log.Info("Adopted existing namespace")
+		r.Recorder.Eventf(tenant, corev1.EventTypeNormal, "NamespaceAdopted", "Adopted existing namespace %s", namespace)
+	}
+	if created {
+		r.Recorder.Eventf(tenant, corev1.EventTypeNormal, "NamespaceCreated", "Created namespace %s", namespace)
+	}
+
+	if err := r.reconcileDefaultTolerations(ctx, tenant, namespace); err != nil {
+		log.Error(err, "Failed to apply default tolerations")
+		return 0, err
+	}
+
+	if err := r.reconcileDefaultServiceAccount(ctx, tenant, namespace); err != nil {
+		log.Error(err, "Failed to reconcile default ServiceAccount")
+		return 0, err
+	}
+	if err := r.reconcileImagePullSecrets(ctx, tenant, namespace); err != nil {
+		log.Error(err, "Failed to distribute image pull secrets")
+		return 0, err
+	}
+	if err := r.reconcileSharedSecrets(ctx, tenant, namespace); err != nil {
+		log.Error(err, "Failed to mirror shared secrets")
+		return 0, err
+	}
+	if err := r.reconcileSecretsBackend(ctx, tenant, namespace); err != nil {
+		log.Error(err, "Failed to apply SecretStore")
+		return 0, err
+	}
+
+	// Create ResourceQuota
+	quota, err := tenantQuota(tenant, namespace)
+	if err != nil {
+		r.Recorder.Event(tenant, corev1.EventTypeWarning, "InvalidQuota", err.Error())
+		// Requeueing won't help; fixing the spec triggers a new reconcile
+		return 0, reconcile.TerminalError(err)
+	}
+	previousQuota := &corev1.ResourceQuota{}
+	err = r.Get(ctx, client.ObjectKeyFromObject(quota), previousQuota)
+	if err != nil && !errors.IsNotFound(err) {
+		return 0, err
+	}
+	quotaChanged := err == nil && !equality.Semantic.DeepEqual(previousQuota.Spec.Hard, quota.Spec.Hard)
+	if err := r.applyOrAdopt(ctx, tenant, quota); err != nil {
+		log.Error(err, "Failed to create ResourceQuota")
+		return 0, err
+	}
+	log.Info("ResourceQuota applied")
+	if quotaChanged {
+		r.Recorder.Eventf(tenant, corev1.EventTypeNormal, "QuotaUpdated", "Updated ResourceQuota %s/%s to %s", namespace, quota.Name, formatResourceList(quota.Spec.Hard))
+	}
+	if err := r.reconcileScopedQuotas(ctx, tenant, namespace); err != nil {
+		log.Error(err, "Failed to apply scoped ResourceQuotas")
+		return 0, err
+	}
+	if err := r.reconcilePriorityQuotas(ctx, tenant, namespace); err != nil {
+		log.Error(err, "Failed to apply priority ResourceQuotas")
+		return 0, err
+	}
+	if err := r.reconcileStorageQuota(ctx, tenant, namespace); err != nil {
+		log.Error(err, "Failed to apply storage ResourceQuota")
+		return 0, err
+	}
+
+	// Create LimitRange
+	limitRange, err := tenantLimitRange(tenant, namespace, limitRangeDefaults)
+	if err != nil {
+		return 0, err
+	}
+	if err := r.applyOrAdopt(ctx, tenant, limitRange); err != nil {
+		log.Error(err, "Failed to create LimitRange")
+		return 0, err
+	}
+	tenant.Status.QuotaApplied = true
+	log.Info("LimitRange applied")
+
+	// The pod quota is zeroed first, so nothing is rescheduled meanwhile
+	if err := r.reconcileSuspension(ctx, tenant, namespace); err != nil {
+		log.Error(err, "Failed to scale workloads for suspension")
+		return 0, err
+	}
+	if err := r.reconcileDefaultPDBs(ctx, tenant, namespace); err != nil {
+		log.Error(err, "Failed to apply default PodDisruptionBudgets")
+		return 0, err
+	}
+
+	recommended, err := r.reconcileQuotaRecommendation(ctx, namespace)
+	if err != nil {
+		log.Error(err, "Failed to update quota recommendation")
+		return 0, err
+	}
+	status.RecommendedQuota = recommended
 
 	// Create default deny NetworkPolicy
-	netpol := &networkingv1.NetworkPolicy{
+	if err := r.applyOrAdopt(ctx, tenant, defaultDenyIngressPolicy(namespace)); err != nil {
+		log.Error(err, "Failed to create NetworkPolicy")
+		return 0, err
+	}
+	log.Info("NetworkPolicy applied")
+
+	sameNamespace := sameNamespacePolicy(namespace)
+	if allowIntraNamespace(tenant) {
+		if err := r.applyOrAdopt(ctx, tenant, sameNamespace); err != nil {
+			log.Error(err, "Failed to create NetworkPolicy", "networkPolicy", sameNamespace.Name)
+			return 0, err
+		}
+		log.Info("NetworkPolicy applied", "networkPolicy", sameNamespace.Name)
+	} else {
+		if err := r.Delete(ctx, sameNamespace); err != nil && !errors.IsNotFound(err) {
+			log.Error(err, "Failed to delete NetworkPolicy", "networkPolicy", sameNamespace.Name)
+			return 0, err
+		}
+	}
+	tenant.Status.NetworkPolicyApplied = true
+
+	if err := r.reconcileEgressPolicies(ctx, tenant, namespace); err != nil {
+		log.Error(err, "Failed to reconcile egress NetworkPolicies")
+		return 0, err
+	}
+
+	if err := r.reconcileMeshTraffic(ctx, tenant, namespace); err != nil {
+		log.Error(err, "Failed to reconcile mesh mTLS and Sidecar")
+		return 0, err
+	}
+
+	if err := r.reconcileMeshAuthorization(ctx, tenant, namespace); err != nil {
+		log.Error(err, "Failed to reconcile mesh AuthorizationPolicies")
+		return 0, err
+	}
+
+	if err := r.reconcileRequestRateLimit(ctx, tenant, namespace); err != nil {
+		log.Error(err, "Failed to apply default request rate limit")
+		return 0, err
+	}
+
+	// Bind the Spec.Access roles
+	if err := r.reconcileAccess(ctx, tenant, namespace); err != nil {
+		log.Error(err, "Failed to apply RoleBindings")
+		return 0, err
+	}
+	tenant.Status.RBACApplied = true
+	log.Info("RoleBindings applied")
+
+	rotateIn, err := r.reconcilePipelineServiceAccounts(ctx, tenant, namespace)
+	if err != nil {
+		log.Error(err, "Failed to reconcile pipeline ServiceAccounts")
+		return 0, err
+	}
+	return rotateIn, nil
+}
+
+// defaultDenyIngressPolicy returns the NetworkPolicy denying all ingress
+// into namespace
+func defaultDenyIngressPolicy(namespace string) *networkingv1.NetworkPolicy {
+	return &networkingv1.NetworkPolicy{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      "default-deny-ingress",
-			Namespace: tenantName,
+			Namespace: namespace,
 		},
 		Spec: networkingv1.NetworkPolicySpec{
 			PodSelector: metav1.LabelSelector{},
@@ -142,81 +717,894 @@ func (r *TenantReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctr
 			},
 		},
 	}
+}
 
-	if err := r.Create(ctx, netpol); err != nil {
-		if !errors.IsAlreadyExists(err) {
-			log.Error(err, "Failed to create NetworkPolicy")
-			return ctrl.Result{}, err
-		}
-	}
-	log.Info("NetworkPolicy created/exists", "namespace", tenantName)
-
-	// Create RoleBinding for tenant team
-	roleBinding := &rbacv1.RoleBinding{
+// sameNamespacePolicy returns the NetworkPolicy allowing ingress from pods
+// in the same namespace. NetworkPolicies are additive, so this carves an
+// exception out of default-deny-ingress.
+func sameNamespacePolicy(namespace string) *networkingv1.NetworkPolicy {
+	return &networkingv1.NetworkPolicy{
 		ObjectMeta: metav1.ObjectMeta{
-			Name:      tenantName + "-developers",
-			Namespace: tenantName,
+			Name:      "allow-same-namespace",
+			Namespace: namespace,
 		},
-		Subjects: []rbacv1.Subject{
-			{
-				Kind:     "Group",
-				Name:     tenantName + "-team",
-				APIGroup: "rbac.authorization.k8s.io",
+		Spec: networkingv1.NetworkPolicySpec{
+			PodSelector: metav1.LabelSelector{},
+			PolicyTypes: []networkingv1.PolicyType{
+				networkingv1.PolicyTypeIngress,
+			},
+			Ingress: []networkingv1.NetworkPolicyIngressRule{
+				{
+					From: []networkingv1.NetworkPolicyPeer{
+						{PodSelector: &metav1.LabelSelector{}},
+					},
+				},
 			},
 		},
-		RoleRef: rbacv1.RoleRef{
-			Kind:     "ClusterRole",
-			Name:     "edit",
-			APIGroup: "rbac.authorization.k8s.io",
+	}
+}
+
+// allowIntraNamespace reports whether the tenant gets allow-same-namespace
+func allowIntraNamespace(tenant *platformv1alpha1.Tenant) bool {
+	return tenant.Spec.AllowIntraNamespace == nil || *tenant.Spec.AllowIntraNamespace
+}
+
+// Tenant details recorded on the tenant namespace
+const (
+	ownerAnnotation         = "platform.xyz.com/owner"
+	costCenterAnnotation    = "platform.xyz.com/cost-center"
+	contactAnnotationPrefix = "platform.xyz.com/contact-"
+)
+
+// tenantNamespace returns the tenant namespace called name. Its annotations
+// carry the owner, cost center and contacts, so they can be found from the
+// namespace alone. Namespaces of a child Tenant are labelled with its parent.
+func tenantNamespace(tenant *platformv1alpha1.Tenant, name string) *corev1.Namespace {
+	annotations := map[string]string{ownerAnnotation: tenant.Spec.Owner}
+	if tenant.Spec.CostCenter != "" {
+		annotations[costCenterAnnotation] = tenant.Spec.CostCenter
+	}
+	for key, value := range tenant.Spec.Contacts {
+		annotations[contactAnnotationPrefix+key] = value
+	}
+
+	labels := map[string]string{
+		tenantLabel:                          tenant.Name,
+		"istio-injection":                    istioInjection(&tenant.Spec),
+		"pod-security.kubernetes.io/enforce": "restricted",
+	}
+	if tenant.Spec.Parent != "" {
+		labels[parentLabel] = tenant.Spec.Parent
+	}
+
+	return &corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        name,
+			Annotations: annotations,
+			Labels:      labels,
 		},
 	}
+}
 
-	if err := r.Create(ctx, roleBinding); err != nil {
-		if !errors.IsAlreadyExists(err) {
-			log.Error(err, "Failed to create RoleBinding")
-			return ctrl.Result{}, err
+// Platform defaults for TenantQuota fields left unset
+const (
+	defaultQuotaCPU           = "10"
+	defaultQuotaMemory        = "20Gi"
+	defaultQuotaPods          = 100
+	defaultQuotaPVCs          = 20
+	defaultQuotaServices      = 50
+	defaultQuotaSecrets       = 100
+	defaultQuotaConfigMaps    = 100
+	defaultQuotaJobs          = 50
+	defaultQuotaLoadBalancers = 2
+)
+
+// effectiveQuota returns quota with platform defaults filled in
+func effectiveQuota(quota platformv1alpha1.TenantQuota) platformv1alpha1.TenantQuota {
+	if quota.CPU == "" {
+		quota.CPU = defaultQuotaCPU
+	}
+	if quota.Memory == "" {
+		quota.Memory = defaultQuotaMemory
+	}
+	if quota.Pods == 0 {
+		quota.Pods = defaultQuotaPods
+	}
+	if quota.PVCs == 0 {
+		quota.PVCs = defaultQuotaPVCs
+	}
+	if quota.Services == 0 {
+		quota.Services = defaultQuotaServices
+	}
+	if quota.Secrets == 0 {
+		quota.Secrets = defaultQuotaSecrets
+	}
+	if quota.ConfigMaps == 0 {
+		quota.ConfigMaps = defaultQuotaConfigMaps
+	}
+	if quota.Jobs == 0 {
+		quota.Jobs = defaultQuotaJobs
+	}
+	if quota.LoadBalancers == 0 {
+		quota.LoadBalancers = defaultQuotaLoadBalancers
+	}
+	quota.SoftThresholdPercent = softThresholdPercent(quota)
+	return quota
+}
+
+// tenantQuota returns the ResourceQuota for one of tenant's namespaces,
+// built from its share of Spec.Quota. CPU and memory requests are capped at
+// the quota values and limits at twice that.
+func tenantQuota(tenant *platformv1alpha1.Tenant, namespace string) (*corev1.ResourceQuota, error) {
+	spec, err := namespaceQuota(tenant, namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	cpu, err := parseQuotaQuantity("cpu", spec.CPU)
+	if err != nil {
+		return nil, err
+	}
+	memory, err := parseQuotaQuantity("memory", spec.Memory)
+	if err != nil {
+		return nil, err
+	}
+
+	hard := corev1.ResourceList{
+		corev1.ResourceRequestsCPU:    cpu,
+		corev1.ResourceRequestsMemory: memory,
+		corev1.ResourceLimitsCPU:      doubled(cpu),
+		corev1.ResourceLimitsMemory:   doubled(memory),
+	}
+	counts := []struct {
+		field string
+		name  corev1.ResourceName
+		value int
+	}{
+		{"pods", corev1.ResourcePods, spec.Pods},
+		{"pvcs", corev1.ResourcePersistentVolumeClaims, spec.PVCs},
+		{"services", corev1.ResourceServices, spec.Services},
+		{"secrets", corev1.ResourceSecrets, spec.Secrets},
+		{"configMaps", corev1.ResourceConfigMaps, spec.ConfigMaps},
+		{"jobs", "count/jobs.batch", spec.Jobs},
+		{"loadBalancers", corev1.ResourceServicesLoadBalancers, spec.LoadBalancers},
+	}
+	for _, c := range counts {
+		if c.value < 0 {
+			return nil, fmt.Errorf("quota.%s must not be negative, got %d", c.field, c.value)
+		}
+		hard[c.name] = *resource.NewQuantity(int64(c.value), resource.DecimalSI)
+	}
+	extended, err := extendedResourceQuota(spec.ExtendedResources)
+	if err != nil {
+		return nil, err
+	}
+	// Scopes get their own ResourceQuotas but are checked with the rest
+	if err := validateQuotaScopes(spec.Scopes); err != nil {
+		return nil, err
+	}
+	for name, q := range extended {
+		hard[name] = q
+	}
+	if tenantSuspended(&tenant.Spec) {
+		hard[corev1.ResourcePods] = resource.MustParse("0")
+	}
+
+	return &corev1.ResourceQuota{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "tenant-quota",
+			Namespace: namespace,
+		},
+		Spec: corev1.ResourceQuotaSpec{Hard: hard},
+	}, nil
+}
+
+// parseQuotaQuantity parses the quota.<field> quantity, rejecting negatives
+func parseQuotaQuantity(field, value string) (resource.Quantity, error) {
+	q, err := resource.ParseQuantity(value)
+	if err != nil {
+		return resource.Quantity{}, fmt.Errorf("quota.%s %q is not a valid quantity", field, value)
+	}
+	if q.Sign() < 0 {
+		return resource.Quantity{}, fmt.Errorf("quota.%s must not be negative, got %s", field, value)
+	}
+	return q, nil
+}
+
+// parseMaxQuota builds the cluster-wide Tenant quota cap from the
+// --max-tenant-* flags, leaving out the empty ones
+func parseMaxQuota(cpu, memory string) (corev1.ResourceList, error) {
+	max := corev1.ResourceList{}
+	for name, value := range map[corev1.ResourceName]string{corev1.ResourceCPU: cpu, corev1.ResourceMemory: memory} {
+		if value == "" {
+			continue
+		}
+		q, err := resource.ParseQuantity(value)
+		if err != nil || q.Sign() <= 0 {
+			return nil, fmt.Errorf("--max-tenant-%s %q is not a positive quantity", name, value)
+		}
+		max[name] = q
+	}
+	return max, nil
+}
+
+// splitList splits a comma-separated flag value, dropping empty entries
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
 		}
 	}
-	log.Info("RoleBinding created/exists", "namespace", tenantName)
+	return items
+}
+
+// formatResourceList renders list as sorted name=quantity pairs
+func formatResourceList(list corev1.ResourceList) string {
+	pairs := make([]string, 0, len(list))
+	for name, quantity := range list {
+		pairs = append(pairs, fmt.Sprintf("%s=%s", name, quantity.String()))
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ", ")
+}
 
-	return ctrl.Result{}, nil
+// doubled returns 2*q
+func doubled(q resource.Quantity) resource.Quantity {
+	out := q.DeepCopy()
+	out.Add(q)
+	return out
 }
 
 // SetupWithManager sets up the controller with the Manager
 func (r *TenantReconciler) SetupWithManager(mgr ctrl.Manager) error {
-	return ctrl.NewControllerManagedBy(mgr).
-		// For(&platformv1alpha1.Tenant{}).  // Uncomment when CRD is registered
-		For(&corev1.Namespace{}). // Temporary: watch namespaces instead
-		Complete(r)
+	b := ctrl.NewControllerManagedBy(mgr).
+		For(&platformv1alpha1.Tenant{}).
+		WithOptions(r.Limits.options()).
+		// Owned resources are watched so manual edits are reverted promptly
+		Owns(&corev1.LimitRange{}).
+		Owns(&networkingv1.NetworkPolicy{}).
+		Owns(&rbacv1.RoleBinding{}).
+		Owns(&policyv1.PodDisruptionBudget{}).
+		// System namespaces are filtered out before they reach the queue
+		Watches(&corev1.Namespace{}, handler.EnqueueRequestsFromMapFunc(namespaceToTenant),
+			builder.WithPredicates(r.Exclusion.predicate(), namespaceChanged(), managedNamespace())).
+		Watches(&corev1.Namespace{}, handler.EnqueueRequestsFromMapFunc(r.namespaceToIntegratingTenants),
+			builder.WithPredicates(r.Exclusion.predicate(), namespaceChanged())).
+		Watches(&corev1.ResourceQuota{}, handler.EnqueueRequestsFromMapFunc(quotaToTenant),
+			builder.WithPredicates(r.Exclusion.predicate())).
+		Watches(&corev1.ServiceAccount{}, handler.EnqueueRequestsFromMapFunc(r.serviceAccountToTenant),
+			builder.WithPredicates(r.Exclusion.predicate())).
+		// Only metadata is cached; a new generation means a scale or
+		// selector change that may need a default PDB
+		Watches(&appsv1.Deployment{}, handler.EnqueueRequestsFromMapFunc(r.objectToTenant),
+			builder.OnlyMetadata, builder.WithPredicates(r.Exclusion.predicate(), predicate.GenerationChangedPredicate{})).
+		Watches(&platformv1alpha1.Tenant{}, handler.EnqueueRequestsFromMapFunc(r.tenantToRelatives)).
+		Watches(&platformv1alpha1.Tenant{}, handler.EnqueueRequestsFromMapFunc(r.tenantToIntegrationTargets)).
+		Watches(&platformv1alpha1.TenantProfile{}, handler.EnqueueRequestsFromMapFunc(r.profileToTenants)).
+		// Nodes joining, leaving or relabelled change the dedicated pools
+		Watches(&corev1.Node{}, handler.EnqueueRequestsFromMapFunc(r.nodeToTenants),
+			builder.WithPredicates(predicate.LabelChangedPredicate{})).
+		Watches(&storagev1.StorageClass{}, handler.EnqueueRequestsFromMapFunc(r.storageClassToTenants),
+			builder.WithPredicates(predicate.Funcs{UpdateFunc: func(event.UpdateEvent) bool { return false }}))
+	// Secrets are only cached, and watched, in the operator namespace
+	if r.SecretNamespace != "" {
+		b = b.Watches(&corev1.Secret{}, handler.EnqueueRequestsFromMapFunc(r.sourceSecretToTenants))
+	}
+	// Cluster placements are only resolved with --multi-cluster
+	if r.Clusters != nil {
+		b = b.Watches(&platformv1alpha1.Cluster{}, handler.EnqueueRequestsFromMapFunc(r.clusterToTenants),
+			builder.WithPredicates(clusterPlacementChanged())).
+			// Services created, deleted or opted out of service discovery
+			Watches(&corev1.Service{}, handler.EnqueueRequestsFromMapFunc(r.objectToTenant),
+				builder.OnlyMetadata, builder.WithPredicates(r.Exclusion.predicate(), predicate.AnnotationChangedPredicate{}))
+	}
+	return b.Complete(r)
+}
+
+// namespaceToTenant maps changes to a tenant namespace, such as its labels
+// being edited, to a reconcile of its tenant, and namespaces annotated for
+// adoption to a reconcile of the tenant adopting them
+func namespaceToTenant(ctx context.Context, obj client.Object) []reconcile.Request {
+	var requests []reconcile.Request
+	name, ok := obj.GetLabels()[tenantLabel]
+	if ok {
+		requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Name: name}})
+	}
+	if adopter := obj.GetAnnotations()[adoptAnnotation]; adopter != "" && adopter != name {
+		requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Name: adopter}})
+	}
+	return requests
 }
 
 func main() {
 	var metricsAddr string
+	var probeAddr string
+	var pprofAddr string
 	var enableLeaderElection bool
+	var enableWebhooks bool
+	var webhookPort int
+	var maxTenantsPerOwner int
+	var ownerLimitsConfigMap string
+	var bindableClusterRoles string
+	var inventoryAddr string
+	var exportTokenFile string
+	var multiCluster bool
+	var clusterSecretNamespace string
+	var clusterSecretSelector string
+	var clusterProbeInterval time.Duration
+	var propagationBackend string
+	var drainOnDelete bool
+	var allowUnknownIntegrations bool
+	var quotaHeadroomPercent int
+	var hpaCeilingMode string
+	var drainTimeout time.Duration
+	var limitRangeRequestCPU string
+	var limitRangeRequestMemory string
+	var limitRangeLimitCPU string
+	var limitRangeLimitMemory string
+	var limitRangeMaxContainerPercent int
+	var maxTenantCPU string
+	var maxTenantMemory string
+	var contactEmailDomain string
+	var platformNamespaces string
+	var platformEgressCIDRs string
+	var kubeconfigServer string
+	var expiryWarningDays int
+	var expiryNotificationURL string
+	var notifySlackWebhook, notifyWebhook, notificationTemplates string
+	var notifyEmail EmailChannel
+	var operatorNamespace string
+	var excludedNamespaces string
+	var maxTenantPriority int
+	var auditHistory int
+	var autoApproveMaxCPU, autoApproveMaxMemory string
+	var autoApproveMaxTTL time.Duration
+	var imagePullSecrets string
+	var vault VaultClient
+	var ingress IngressConfig
+	var argoCDNamespace string
+	var clusterAPINamespace string
+	var spotNodeLabel string
+	var spotTaint string
+	var veleroNamespace string
+	var logging LoggingConfig
+	var monitoringNamespace, monitoringLabels, grafanaDashboardLabel string
+	var alertFallbackWebhook, pagerDutySecret string
+	var policy PolicyConfig
+	var policyRequiredLabels, imageVerificationKey string
+	var cloudIdentity CloudIdentityConfig
+	var costPricing, costRateCard, costBillingExport string
+	var costInterval time.Duration
+	var budgetNotificationURL string
+	var limits ReconcileLimits
 	flag.StringVar(&metricsAddr, "metrics-bind-address", ":8080", "The address the metric endpoint binds to.")
+	flag.StringVar(&probeAddr, "health-probe-bind-address", ":8081", "The address the /healthz and /readyz probe endpoints bind to.")
+	flag.StringVar(&pprofAddr, "pprof-bind-address", "0", "The address the pprof profiling endpoint binds to. \"0\" disables it.")
 	flag.BoolVar(&enableLeaderElection, "leader-elect", false, "Enable leader election.")
+	flag.BoolVar(&enableWebhooks, "enable-webhooks", false, "Serve the Tenant admission webhooks (requires serving certificates, see k8s/webhook.yaml).")
+	flag.IntVar(&webhookPort, "webhook-port", 9443, "The port the webhook server listens on.")
+	flag.IntVar(&maxTenantsPerOwner, "max-tenants-per-owner", 0, "Maximum number of Tenants a single owner may create. 0 means unlimited.")
+	flag.StringVar(&ownerLimitsConfigMap, "owner-limits-configmap", "platform-system/tenant-owner-limits", "Namespace/name of the ConfigMap holding per-owner Tenant limit overrides.")
+	flag.StringVar(&bindableClusterRoles, "bindable-cluster-roles", "platform-system/tenant-bindable-clusterroles", "Namespace/name of the ConfigMap whose keys are the ClusterRoles RoleBindings in tenant namespaces may bind besides view, edit and tenant-admin.")
+	flag.StringVar(&inventoryAddr, "inventory-bind-address", "0", "The address the Tenant inventory endpoint binds to. \"0\" disables it.")
+	flag.StringVar(&exportTokenFile, "export-token-file", "", "File containing the bearer token for GET /tenants/export and /tenants/chargeback. Empty disables both.")
+	flag.BoolVar(&multiCluster, "multi-cluster", false, "Also provision tenants in the registered Clusters, and the clusters whose kubeconfigs are stored in labelled Secrets, their placement selects.")
+	flag.StringVar(&clusterSecretNamespace, "cluster-secret-namespace", "platform-system", "Namespace holding the kubeconfig Secrets of Clusters and target cluster Secrets.")
+	flag.StringVar(&clusterSecretSelector, "cluster-secret-selector", "platform.xyz.com/target-cluster=true", "Label selector for the target cluster kubeconfig Secrets.")
+	flag.DurationVar(&clusterProbeInterval, "cluster-probe-interval", time.Minute, "How often each registered Cluster's API server is probed.")
+	flag.StringVar(&propagationBackend, "propagation-backend", string(PropagationDirect), "How --multi-cluster delivers tenants to target clusters: direct, karmada or ocm.")
+	flag.BoolVar(&allowUnknownIntegrations, "allow-unknown-integrations", false, "Admit Tenants whose allowedIntegrations name tenants that don't exist, with a warning.")
+	flag.BoolVar(&drainOnDelete, "drain-on-delete", false, "On Tenant deletion, wait for the namespace's pods to terminate before deleting it.")
+	flag.DurationVar(&drainTimeout, "drain-timeout", 10*time.Minute, "How long --drain-on-delete waits for pods before deleting the namespace anyway.")
+	flag.IntVar(&quotaHeadroomPercent, "quota-headroom-percent", 20, "Headroom added to peak usage when recommending a tenant quota.")
+	flag.StringVar(&hpaCeilingMode, "hpa-ceiling-mode", string(HPACeilingReject), "What the HPA webhook does with maxReplicas above the tenant ceiling: reject or clamp.")
+	flag.StringVar(&limitRangeRequestCPU, "limitrange-default-request-cpu", "100m", "CPU request given to tenant containers that set none.")
+	flag.StringVar(&limitRangeRequestMemory, "limitrange-default-request-memory", "128Mi", "Memory request given to tenant containers that set none.")
+	flag.StringVar(&limitRangeLimitCPU, "limitrange-default-limit-cpu", "500m", "CPU limit given to tenant containers that set none.")
+	flag.StringVar(&limitRangeLimitMemory, "limitrange-default-limit-memory", "512Mi", "Memory limit given to tenant containers that set none.")
+	flag.IntVar(&limitRangeMaxContainerPercent, "limitrange-max-container-percent", 50, "Largest share of the tenant's CPU and memory quota a single container may use.")
+	flag.StringVar(&maxTenantCPU, "max-tenant-cpu", "", "Largest quota.cpu the webhook admits for a single Tenant. Empty means uncapped.")
+	flag.StringVar(&maxTenantMemory, "max-tenant-memory", "", "Largest quota.memory the webhook admits for a single Tenant. Empty means uncapped.")
+	flag.StringVar(&contactEmailDomain, "contact-email-domain", "xyz.com", "Domain of the email contact defaulted from spec.owner. Empty disables contact defaulting.")
+	flag.StringVar(&platformNamespaces, "platform-namespaces", "istio-system,platform-system", "Comma-separated namespaces tenants with restricted egress may always reach.")
+	flag.StringVar(&platformEgressCIDRs, "platform-egress-cidrs", "", "Comma-separated CIDRs of platform endpoints tenants with restricted egress may always reach.")
+	flag.StringVar(&kubeconfigServer, "kubeconfig-server", "", "API server URL written into pipeline ServiceAccount kubeconfigs. Empty uses the operator's own.")
+	flag.IntVar(&expiryWarningDays, "expiry-warning-days", 3, "How many days before a tenant expires its contacts are warned.")
+	flag.StringVar(&expiryNotificationURL, "expiry-notification-url", "", "Webhook URL expiry notifications are posted to. Empty sends none.")
+	flag.StringVar(&notifySlackWebhook, "notify-slack-webhook", "", "Slack incoming webhook URL lifecycle notifications are posted to, in the tenant's contacts.slack channel. Empty sends none.")
+	flag.StringVar(&notifyEmail.Server, "notify-smtp-server", "", "SMTP server, as host:port, lifecycle notifications are mailed to contacts.email through. Empty sends none.")
+	flag.StringVar(&notifyEmail.From, "notify-smtp-from", "tenant-operator@xyz.com", "Sender address of lifecycle notification mails.")
+	flag.StringVar(&notifyEmail.Username, "notify-smtp-username", "", "Username the operator authenticates to the SMTP server with. Empty sends unauthenticated.")
+	flag.StringVar(&notifyEmail.PasswordFile, "notify-smtp-password-file", "", "File containing the SMTP password, re-read on every mail.")
+	flag.StringVar(&notifyWebhook, "notify-webhook", "", "Webhook URL every lifecycle notification is posted to as JSON. Empty sends none.")
+	flag.StringVar(&notificationTemplates, "notification-templates", "", "Namespace/name of the ConfigMap overriding the lifecycle notification templates, keyed by event. Empty uses the built-in ones.")
+	flag.StringVar(&operatorNamespace, "operator-namespace", os.Getenv("POD_NAMESPACE"), "Namespace the operator runs in, never managed as a tenant namespace. Defaults to $POD_NAMESPACE.")
+	flag.StringVar(&excludedNamespaces, "excluded-namespaces", "", "Regular expression matching further namespaces the operator never manages, adopts or watches.")
+	flag.IntVar(&maxTenantPriority, "max-tenant-priority", 1000000, "Highest value the webhook admits for a PriorityClass a Tenant creates for itself.")
+	flag.IntVar(&auditHistory, "audit-history", 100, "Changes kept in each Tenant's TenantAudit, recorded by the webhook. 0 disables the audit trail.")
+	flag.StringVar(&autoApproveMaxCPU, "auto-approve-max-cpu", "", "Largest quota.cpu of TenantRequests approved without review. Empty sends every request to review.")
+	flag.StringVar(&autoApproveMaxMemory, "auto-approve-max-memory", "", "Largest quota.memory of TenantRequests approved without review, set with --auto-approve-max-cpu.")
+	flag.DurationVar(&autoApproveMaxTTL, "auto-approve-max-ttl", 0, "If set, TenantRequests are only approved without review with a ttl no longer than this.")
+	flag.StringVar(&imagePullSecrets, "image-pull-secrets", "", "Comma-separated image pull Secrets in the operator namespace copied into every tenant namespace and its default ServiceAccount.")
+	flag.StringVar(&vault.Address, "vault-addr", "", "Address of the platform Vault backing tenant secrets backends. Empty rejects them.")
+	flag.StringVar(&vault.TokenFile, "vault-token-file", "", "File containing the Vault token the operator writes tenant roles and policies with. Empty leaves them to the platform team.")
+	flag.StringVar(&vault.AuthMount, "vault-auth-mount", "kubernetes", "Path of the Vault Kubernetes auth method tenant SecretStores log in with.")
+	flag.StringVar(&vault.KVMount, "vault-kv-mount", "secret", "Path of the Vault KV version 2 engine holding tenant secrets.")
+	flag.StringVar(&vault.PathPrefix, "vault-path-prefix", "tenants", "Path in the KV engine below which each tenant has a tree named after it.")
+	flag.StringVar(&ingress.BaseDomain, "ingress-base-domain", "platform.xyz.com", "Domain whose subdomains are delegated to tenants with spec.ingress.")
+	flag.StringVar(&ingress.GatewayClass, "gateway-class", "istio", "GatewayClass of the tenant Gateways.")
+	flag.StringVar(&ingress.ClusterIssuer, "ingress-cluster-issuer", "letsencrypt", "cert-manager ClusterIssuer of the tenant Gateways' wildcard certificates.")
+	flag.StringVar(&argoCDNamespace, "argocd-namespace", "argocd", "Namespace Argo CD runs in, holding the tenant AppProjects.")
+	flag.StringVar(&clusterAPINamespace, "cluster-api-namespace", "platform-system", "Namespace holding the Cluster API MachineDeployments of tenant dedicated node pools.")
+	flag.StringVar(&spotNodeLabel, "spot-node-label", "", "Label of the spot nodes tenants with spec.compute.spotAllowed run Jobs on, as key=value. Empty rejects them.")
+	flag.StringVar(&spotTaint, "spot-taint", "", "Taint of the spot nodes, as key[=value]:effect, tolerated by the pods steered to them.")
+	flag.StringVar(&veleroNamespace, "velero-namespace", "velero", "Namespace Velero runs in, holding the tenant backup Schedules.")
+	flag.StringVar(&logging.Namespace, "logging-namespace", "logging", "Control namespace of the Logging Operator, holding the tenant ClusterOutputs.")
+	flag.StringVar(&logging.ElasticsearchHosts, "log-elasticsearch-hosts", "", "Elasticsearch nodes tenant logs are written to, as comma-separated scheme://host:port. Empty rejects elasticsearch log destinations.")
+	flag.StringVar(&logging.ElasticsearchSecret, "log-elasticsearch-secret", "", "Secret in --logging-namespace with the username and password tenant logs are written to Elasticsearch with.")
+	flag.StringVar(&logging.S3Region, "log-s3-region", "", "AWS region of the tenant log buckets. Empty rejects s3 log destinations.")
+	flag.StringVar(&logging.S3Secret, "log-s3-secret", "", "Secret in --logging-namespace with the awsAccessKeyId and awsSecretAccessKey tenant logs are written to S3 with. Empty uses the log forwarder's AWS identity.")
+	flag.StringVar(&monitoringNamespace, "monitoring-namespace", "monitoring", "Namespace holding the tenant PrometheusRules and Grafana dashboards.")
+	flag.StringVar(&monitoringLabels, "monitoring-labels", "", "Comma-separated key=value labels set on the tenant ServiceMonitors, PodMonitors, PrometheusRules and AlertmanagerConfigs for Prometheus and Alertmanager to select them.")
+	flag.StringVar(&grafanaDashboardLabel, "grafana-dashboard-label", "grafana_dashboard=1", "Label, as key=value, set on the tenant dashboard ConfigMaps for the Grafana sidecar to load them.")
+	flag.StringVar(&alertFallbackWebhook, "alert-fallback-webhook", "", "Webhook URL receiving the alerts of tenant namespaces none of the tenant's contacts take. Empty leaves them to Alertmanager's own routes.")
+	flag.StringVar(&policy.Engine, "policy-engine", "", "Policy engine every tenant gets constraints of: gatekeeper or kyverno. Empty creates none.")
+	flag.StringVar(&policy.Enforcement, "policy-enforcement", PolicyEnforcementDeny, "enforcementAction of the tenant constraints: deny, warn or dryrun.")
+	flag.StringVar(&policyRequiredLabels, "policy-required-labels", "app.kubernetes.io/name", "Comma-separated labels the tenant constraints require on Deployments, StatefulSets and DaemonSets.")
+	flag.StringVar(&imageVerificationKey, "image-verification-key", "", "File containing the PEM cosign public key the Kyverno tenant policies verify image signatures with. Empty verifies none.")
+	flag.StringVar(&pagerDutySecret, "pagerduty-secret", "", "Secret in --monitoring-namespace mapping the PagerDuty services of tenant contacts to their routing keys. Empty leaves pagerduty contacts unrouted.")
+	flag.StringVar(&cloudIdentity.Provider, "cloud-identity", "", "Cloud provider tenant cloud identities are created with: aws, gcp or azure. Empty rejects them.")
+	flag.StringVar(&cloudIdentity.Namespace, "cloud-identity-namespace", "platform-system", "Namespace holding the ACK, Config Connector or ASO resources of tenant cloud identities.")
+	flag.StringVar(&cloudIdentity.AWSAccountID, "aws-account-id", "", "AWS account the tenant IAM roles are created in.")
+	flag.StringVar(&cloudIdentity.AWSOIDCProvider, "aws-oidc-provider", "", "The cluster's OIDC issuer without https://, trusted by the tenant IAM roles.")
+	flag.StringVar(&cloudIdentity.GCPProject, "gcp-project", "", "GCP project of the tenant Google service accounts and the cluster's workload identity pool.")
+	flag.StringVar(&cloudIdentity.AzureSubscriptionID, "azure-subscription-id", "", "Azure subscription of the tenant managed identities.")
+	flag.StringVar(&cloudIdentity.AzureResourceGroup, "azure-resource-group", "", "Resource group the tenant managed identities are created in.")
+	flag.StringVar(&cloudIdentity.AzureLocation, "azure-location", "", "Azure region of the tenant managed identities.")
+	flag.StringVar(&cloudIdentity.AzureOIDCIssuer, "azure-oidc-issuer", "", "The cluster's OIDC issuer URL, federated with the tenant managed identities.")
+	flag.StringVar(&costPricing, "cost-pricing", "", "Price tenant resource requests for cost allocation: static, aws, gcp or azure. Empty disables cost estimates.")
+	flag.StringVar(&costRateCard, "cost-rate-card", "platform-system/tenant-cost-rates", "Namespace/name of the ConfigMap holding the static rate card.")
+	flag.StringVar(&costBillingExport, "cost-billing-export", "", "CSV billing export the aws, gcp and azure pricing derive rates from.")
+	flag.DurationVar(&costInterval, "cost-interval", time.Hour, "How often each tenant's cost is estimated.")
+	flag.StringVar(&budgetNotificationURL, "budget-notification-url", "", "Webhook URL budget alert notifications are posted to. Empty sends none.")
+	flag.IntVar(&limits.MaxConcurrentReconciles, "max-concurrent-reconciles", 1, "Number of Tenants reconciled in parallel.")
+	flag.Float64Var(&limits.QPS, "reconcile-qps", 10, "Average rate at which Tenants are taken off the work queue, per second.")
+	flag.IntVar(&limits.Burst, "reconcile-burst", 100, "Tenants that may be taken off the work queue at once above --reconcile-qps.")
+	flag.DurationVar(&limits.BackoffBase, "reconcile-backoff-base", 5*time.Millisecond, "First retry delay of a Tenant whose reconcile failed, doubled on each further failure.")
+	flag.DurationVar(&limits.BackoffMax, "reconcile-backoff-max", 1000*time.Second, "Longest retry delay of a failing Tenant.")
+	// --zap-log-level, --zap-encoder, --zap-stacktrace-level and
+	// --zap-devel; the defaults log JSON at info level
+	logOptions := zap.Options{}
+	logOptions.BindFlags(flag.CommandLine)
 	flag.Parse()
+	ctrl.SetLogger(zap.New(zap.UseFlagOptions(&logOptions)))
 
-	ctrl.SetLogger(zap.New(zap.UseDevMode(true)))
+	if mode := HPACeilingMode(hpaCeilingMode); mode != HPACeilingReject && mode != HPACeilingClamp {
+		setupLog.Error(nil, "--hpa-ceiling-mode must be reject or clamp", "value", hpaCeilingMode)
+		os.Exit(1)
+	}
+	switch costPricing {
+	case "", PricingStatic:
+	case PricingAWS, PricingGCP, PricingAzure:
+		if costBillingExport == "" {
+			setupLog.Error(nil, "--cost-pricing needs --cost-billing-export", "value", costPricing)
+			os.Exit(1)
+		}
+	default:
+		setupLog.Error(nil, "--cost-pricing must be static, aws, gcp or azure", "value", costPricing)
+		os.Exit(1)
+	}
+	switch PropagationBackend(propagationBackend) {
+	case PropagationDirect, PropagationKarmada, PropagationOCM:
+	default:
+		setupLog.Error(nil, "--propagation-backend must be direct, karmada or ocm", "value", propagationBackend)
+		os.Exit(1)
+	}
+	switch policy.Engine {
+	case "", PolicyEngineGatekeeper, PolicyEngineKyverno:
+	default:
+		setupLog.Error(nil, "--policy-engine must be gatekeeper or kyverno", "value", policy.Engine)
+		os.Exit(1)
+	}
+	switch policy.Enforcement {
+	case PolicyEnforcementDeny, PolicyEnforcementWarn, PolicyEnforcementDryRun:
+	default:
+		setupLog.Error(nil, "--policy-enforcement must be deny, warn or dryrun", "value", policy.Enforcement)
+		os.Exit(1)
+	}
+	policy.RequiredLabels = splitList(policyRequiredLabels)
+	if imageVerificationKey != "" {
+		key, err := os.ReadFile(imageVerificationKey)
+		if err != nil {
+			setupLog.Error(err, "unable to read --image-verification-key")
+			os.Exit(1)
+		}
+		policy.ImageKey = strings.TrimSpace(string(key))
+	}
 
-	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
-		Scheme:             scheme,
-		MetricsBindAddress: metricsAddr,
-		LeaderElection:     enableLeaderElection,
-		LeaderElectionID:   "tenant-operator.platform.xyz.com",
+	if err := limits.validate(); err != nil {
+		setupLog.Error(err, "invalid reconcile limits")
+		os.Exit(1)
+	}
+	var cloudIdentityConfig *CloudIdentityConfig
+	if cloudIdentity.Provider != "" {
+		if err := cloudIdentity.validate(); err != nil {
+			setupLog.Error(err, "invalid cloud identity settings")
+			os.Exit(1)
+		}
+		cloudIdentityConfig = &cloudIdentity
+	}
+
+	limitRange, err := parseLimitRangeDefaults(limitRangeRequestCPU, limitRangeRequestMemory, limitRangeLimitCPU, limitRangeLimitMemory, limitRangeMaxContainerPercent)
+	if err != nil {
+		setupLog.Error(err, "invalid LimitRange defaults")
+		os.Exit(1)
+	}
+
+	egressCIDRs := splitList(platformEgressCIDRs)
+	for _, cidr := range egressCIDRs {
+		if _, _, err := net.ParseCIDR(cidr); err != nil {
+			setupLog.Error(err, "invalid --platform-egress-cidrs entry", "value", cidr)
+			os.Exit(1)
+		}
+	}
+
+	spot, err := parseSpotConfig(spotNodeLabel, spotTaint)
+	if err != nil {
+		setupLog.Error(err, "invalid spot capacity settings")
+		os.Exit(1)
+	}
+	monitoring, err := parseMonitoringConfig(monitoringNamespace, monitoringLabels, grafanaDashboardLabel)
+	if err != nil {
+		setupLog.Error(err, "invalid monitoring settings")
+		os.Exit(1)
+	}
+
+	excludedPattern, err := parseExcludedNamespaces(excludedNamespaces)
+	if err != nil {
+		setupLog.Error(err, "invalid namespace exclusion")
+		os.Exit(1)
+	}
+	exclusion := NamespaceExclusion{OperatorNamespace: operatorNamespace, Pattern: excludedPattern}
+
+	maxQuota, err := parseMaxQuota(maxTenantCPU, maxTenantMemory)
+	if err != nil {
+		setupLog.Error(err, "invalid cluster-wide quota cap")
+		os.Exit(1)
+	}
+	autoApprove, err := parseAutoApprovePolicy(autoApproveMaxCPU, autoApproveMaxMemory, autoApproveMaxTTL)
+	if err != nil {
+		setupLog.Error(err, "invalid auto-approve limits")
+		os.Exit(1)
+	}
+
+	pullSecrets := splitList(imagePullSecrets)
+	if len(pullSecrets) > 0 && operatorNamespace == "" {
+		setupLog.Error(fmt.Errorf("--operator-namespace is not set"), "--image-pull-secrets needs the namespace holding the secrets")
+		os.Exit(1)
+	}
+	cacheOptions := cache.Options{ByObject: map[client.Object]cache.ByObject{
+		// Only the tenant dashboards are watched; the other ConfigMaps the
+		// operator reads are read uncached
+		&corev1.ConfigMap{}: {Namespaces: map[string]cache.Config{monitoring.Namespace: {}}},
+	}}
+	if operatorNamespace != "" {
+		// Only the Secrets mirrored into tenant namespaces are watched; the
+		// other Secrets the operator touches are read uncached
+		cacheOptions.ByObject[&corev1.Secret{}] = cache.ByObject{Namespaces: map[string]cache.Config{operatorNamespace: {}}}
+	}
+
+	config := ctrl.GetConfigOrDie()
+	if kubeconfigServer == "" {
+		kubeconfigServer = config.Host
+	}
+	kubeconfigCA := config.CAData
+	if len(kubeconfigCA) == 0 && config.CAFile != "" {
+		if kubeconfigCA, err = os.ReadFile(config.CAFile); err != nil {
+			setupLog.Error(err, "unable to read API server CA")
+			os.Exit(1)
+		}
+	}
+
+	mgr, err := ctrl.NewManager(config, ctrl.Options{
+		Scheme:                 scheme,
+		Metrics:                metricsserver.Options{BindAddress: metricsAddr},
+		HealthProbeBindAddress: probeAddr,
+		PprofBindAddress:       pprofAddr,
+		Cache:                  cacheOptions,
+		WebhookServer:          webhook.NewServer(webhook.Options{Port: webhookPort}),
+		LeaderElection:         enableLeaderElection,
+		LeaderElectionID:       "tenant-operator.platform.xyz.com",
 	})
 	if err != nil {
 		setupLog.Error(err, "unable to start manager")
 		os.Exit(1)
 	}
 
+	var clusters *ClusterTargets
+	if multiCluster {
+		selector, err := labels.Parse(clusterSecretSelector)
+		if err != nil {
+			setupLog.Error(err, "invalid --cluster-secret-selector")
+			os.Exit(1)
+		}
+		clusters = &ClusterTargets{
+			Reader:    mgr.GetAPIReader(),
+			Namespace: clusterSecretNamespace,
+			Selector:  selector,
+			Scheme:    mgr.GetScheme(),
+			Backend:   PropagationBackend(propagationBackend),
+		}
+	}
+
+	var expiryNotifier *ExpiryNotifier
+	if expiryNotificationURL != "" {
+		expiryNotifier = &ExpiryNotifier{URL: expiryNotificationURL, Client: &http.Client{Timeout: 10 * time.Second}}
+	}
+	var channels []NotificationChannel
+	if notifySlackWebhook != "" {
+		channels = append(channels, &SlackChannel{WebhookURL: notifySlackWebhook, Client: &http.Client{Timeout: 10 * time.Second}})
+	}
+	if notifyEmail.Server != "" {
+		channels = append(channels, &notifyEmail)
+	}
+	if notifyWebhook != "" {
+		channels = append(channels, &WebhookChannel{URL: notifyWebhook, Client: &http.Client{Timeout: 10 * time.Second}})
+	}
+	var lifecycleNotifier *LifecycleNotifier
+	if len(channels) > 0 {
+		templatesNamespace, templatesName, _ := strings.Cut(notificationTemplates, "/")
+		lifecycleNotifier = &LifecycleNotifier{
+			Channels:  channels,
+			Reader:    mgr.GetAPIReader(),
+			Templates: types.NamespacedName{Namespace: templatesNamespace, Name: templatesName},
+		}
+	}
+	var vaultClient *VaultClient
+	if vault.Address != "" {
+		vault.Client = &http.Client{Timeout: 10 * time.Second}
+		vaultClient = &vault
+	}
+
 	if err = (&TenantReconciler{
-		Client: mgr.GetClient(),
-		Scheme: mgr.GetScheme(),
+		Client:    mgr.GetClient(),
+		Scheme:    mgr.GetScheme(),
+		Recorder:  mgr.GetEventRecorderFor("tenant-operator"),
+		APIReader: mgr.GetAPIReader(),
+		Clusters:  clusters,
+
+		DrainOnDelete: drainOnDelete,
+		DrainTimeout:  drainTimeout,
+
+		QuotaHeadroomPercent: quotaHeadroomPercent,
+		LimitRange:           limitRange,
+		PlatformNamespaces:   splitList(platformNamespaces),
+		PlatformEgressCIDRs:  egressCIDRs,
+		KubeconfigServer:     kubeconfigServer,
+		KubeconfigCA:         kubeconfigCA,
+
+		ExpiryWarning:  time.Duration(expiryWarningDays) * 24 * time.Hour,
+		ExpiryNotifier: expiryNotifier,
+		Lifecycle:      lifecycleNotifier,
+
+		Exclusion: exclusion,
+		Limits:    limits,
+
+		SecretNamespace:  operatorNamespace,
+		ImagePullSecrets: pullSecrets,
+
+		Vault:           vaultClient,
+		Ingress:         ingress,
+		ArgoCDNamespace: argoCDNamespace,
+		CloudIdentity:   cloudIdentityConfig,
+
+		ClusterAPINamespace: clusterAPINamespace,
+		Spot:                spot,
+		VeleroNamespace:     veleroNamespace,
+		Logging:             logging,
+		Monitoring:          monitoring,
+
+		AlertFallbackWebhook: alertFallbackWebhook,
+		PagerDutySecret:      pagerDutySecret,
+		Policy:               policy,
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "Tenant")
 		os.Exit(1)
 	}
+	if clusters != nil {
+		if err = (&ClusterReconciler{
+			Client:        mgr.GetClient(),
+			Recorder:      mgr.GetEventRecorderFor("tenant-operator"),
+			Targets:       clusters,
+			ProbeInterval: clusterProbeInterval,
+		}).SetupWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create controller", "controller", "Cluster")
+			os.Exit(1)
+		}
+	}
+	if err = (&TenantRequestReconciler{
+		Client:      mgr.GetClient(),
+		Recorder:    mgr.GetEventRecorderFor("tenant-operator"),
+		AutoApprove: autoApprove,
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "TenantRequest")
+		os.Exit(1)
+	}
+
+	if costPricing != "" {
+		rateCardNamespace, rateCardName, _ := strings.Cut(costRateCard, "/")
+		rateCard := &RateCard{
+			Reader:    mgr.GetAPIReader(),
+			ConfigMap: types.NamespacedName{Namespace: rateCardNamespace, Name: rateCardName},
+		}
+		var pricing CostPricing = rateCard
+		if costPricing != PricingStatic {
+			pricing = &BillingExport{Provider: costPricing, Path: costBillingExport, RateCard: rateCard}
+		}
+		var budgetNotifier *BudgetNotifier
+		if budgetNotificationURL != "" {
+			budgetNotifier = &BudgetNotifier{URL: budgetNotificationURL, Client: &http.Client{Timeout: 10 * time.Second}}
+		}
+		if err = (&CostReconciler{
+			Client:   mgr.GetClient(),
+			Pricing:  pricing,
+			Interval: costInterval,
+			Recorder: mgr.GetEventRecorderFor("tenant-operator"),
+			Notifier: budgetNotifier,
+		}).SetupWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create controller", "controller", "Cost")
+			os.Exit(1)
+		}
+	}
+
+	if enableWebhooks {
+		limitsNamespace, limitsName, _ := strings.Cut(ownerLimitsConfigMap, "/")
+		var audit *TenantAuditor
+		if auditHistory > 0 {
+			audit = &TenantAuditor{Client: mgr.GetClient(), Reader: mgr.GetAPIReader(), History: auditHistory}
+		}
+		mgr.GetWebhookServer().Register("/validate-platform-xyz-com-v1alpha1-tenant", &webhook.Admission{
+			Handler: &TenantValidator{
+				Client:               mgr.GetClient(),
+				Reader:               mgr.GetAPIReader(),
+				MaxTenantsPerOwner:   maxTenantsPerOwner,
+				OwnerLimitsConfigMap: types.NamespacedName{Namespace: limitsNamespace, Name: limitsName},
+
+				AllowUnknownIntegrations: allowUnknownIntegrations,
+				MaxQuota:                 maxQuota,
+				Exclusion:                exclusion,
+				MaxTenantPriority:        int32(maxTenantPriority),
+				Vault:                    vaultClient,
+				Ingress:                  ingress,
+				CloudIdentity:            cloudIdentityConfig,
+				Spot:                     spot,
+				Logging:                  logging,
+				Recorder:                 mgr.GetEventRecorderFor("tenant-operator"),
+				Audit:                    audit,
+			},
+		})
+		mgr.GetWebhookServer().Register("/mutate-platform-xyz-com-v1alpha1-tenant", &webhook.Admission{
+			Handler: &TenantDefaulter{ContactEmailDomain: contactEmailDomain},
+		})
+		mgr.GetWebhookServer().Register("/validate-platform-xyz-com-v1alpha1-tenantrequest", &webhook.Admission{
+			Handler: &TenantRequestValidator{Client: mgr.GetClient()},
+		})
+		mgr.GetWebhookServer().Register("/mutate-platform-xyz-com-v1alpha1-tenantrequest", &webhook.Admission{
+			Handler: &TenantRequestDefaulter{},
+		})
+		mgr.GetWebhookServer().Register("/mutate--v1-pod", &webhook.Admission{
+			Handler: &PodSeccompDefaulter{
+				Client:  mgr.GetClient(),
+				Decoder: admission.NewDecoder(mgr.GetScheme()),
+			},
+		})
+		mgr.GetWebhookServer().Register("/mutate--v1-pod-topology-spread", &webhook.Admission{
+			Handler: &PodTopologySpreadDefaulter{
+				Client:  mgr.GetClient(),
+				Decoder: admission.NewDecoder(mgr.GetScheme()),
+			},
+		})
+		mgr.GetWebhookServer().Register("/mutate--v1-pod-dedicated-nodes", &webhook.Admission{
+			Handler: &PodDedicatedNodesDefaulter{
+				Client:  mgr.GetClient(),
+				Decoder: admission.NewDecoder(mgr.GetScheme()),
+			},
+		})
+		mgr.GetWebhookServer().Register("/mutate--v1-pod-spot", &webhook.Admission{
+			Handler: &PodSpotDefaulter{
+				Client:  mgr.GetClient(),
+				Decoder: admission.NewDecoder(mgr.GetScheme()),
+				Spot:    spot,
+			},
+		})
+		mgr.GetWebhookServer().Register("/validate--v1-pod", &webhook.Admission{
+			Handler: &PodImageValidator{
+				Client:  mgr.GetClient(),
+				Decoder: admission.NewDecoder(mgr.GetScheme()),
+			},
+		})
+		mgr.GetWebhookServer().Register("/mutate-workload-labels", &webhook.Admission{
+			Handler: &WorkloadLabelDefaulter{Client: mgr.GetClient()},
+		})
+		mgr.GetWebhookServer().Register("/validate-workload-labels", &webhook.Admission{
+			Handler: &WorkloadLabelValidator{Client: mgr.GetClient()},
+		})
+		bindableNamespace, bindableName, _ := strings.Cut(bindableClusterRoles, "/")
+		mgr.GetWebhookServer().Register("/validate-rbac-authorization-k8s-io-v1-rolebinding", &webhook.Admission{
+			Handler: &RoleBindingValidator{
+				Decoder:              admission.NewDecoder(mgr.GetScheme()),
+				Reader:               mgr.GetAPIReader(),
+				BindableClusterRoles: types.NamespacedName{Namespace: bindableNamespace, Name: bindableName},
+			},
+		})
+		mgr.GetWebhookServer().Register("/validate-cert-manager-io-v1-certificate", &webhook.Admission{
+			Handler: &CertificateValidator{
+				Client:  mgr.GetClient(),
+				Decoder: admission.NewDecoder(mgr.GetScheme()),
+				Ingress: ingress,
+			},
+		})
+		mgr.GetWebhookServer().Register("/validate-gateway-networking-k8s-io-route", &webhook.Admission{
+			Handler: &RouteValidator{
+				Client:  mgr.GetClient(),
+				Decoder: admission.NewDecoder(mgr.GetScheme()),
+				Ingress: ingress,
+			},
+		})
+		mgr.GetWebhookServer().Register("/validate-external-dns", &webhook.Admission{
+			Handler: &DNSRecordValidator{
+				Client:  mgr.GetClient(),
+				Decoder: admission.NewDecoder(mgr.GetScheme()),
+				Ingress: ingress,
+			},
+		})
+		mgr.GetWebhookServer().Register("/validate--v1-persistentvolumeclaim", &webhook.Admission{
+			Handler: &PVCStorageClassValidator{
+				Client:  mgr.GetClient(),
+				Decoder: admission.NewDecoder(mgr.GetScheme()),
+			},
+		})
+		mgr.GetWebhookServer().Register("/mutate-autoscaling-v2-horizontalpodautoscaler", &webhook.Admission{
+			Handler: &HPAReplicasGuard{
+				Client:  mgr.GetClient(),
+				Decoder: admission.NewDecoder(mgr.GetScheme()),
+				Mode:    HPACeilingMode(hpaCeilingMode),
+			},
+		})
+	}
+
+	if err := mgr.AddHealthzCheck("healthz", healthz.Ping); err != nil {
+		setupLog.Error(err, "unable to set up health check")
+		os.Exit(1)
+	}
+	// With webhooks, the operator is only ready once their server is up,
+	// so the API server isn't sent admission requests it can't answer
+	readyCheck := healthz.Ping
+	if enableWebhooks {
+		readyCheck = mgr.GetWebhookServer().StartedChecker()
+	}
+	if err := mgr.AddReadyzCheck("readyz", readyCheck); err != nil {
+		setupLog.Error(err, "unable to set up ready check")
+		os.Exit(1)
+	}
+
+	metrics.Registry.MustRegister(reconcileErrors, clusterHealthy, clusterProbeLatency, tenantMonthlyCost, &tenantCollector{reader: mgr.GetCache()})
+	if spot != nil {
+		metrics.Registry.MustRegister(&spotCollector{reader: mgr.GetCache(), apiReader: mgr.GetAPIReader(), spot: spot})
+	}
+
+	if inventoryAddr != "0" {
+		if err := mgr.Add(&InventoryServer{
+			Addr:            inventoryAddr,
+			Reader:          mgr.GetCache(),
+			APIReader:       mgr.GetAPIReader(),
+			ExportTokenFile: exportTokenFile,
+		}); err != nil {
+			setupLog.Error(err, "unable to add inventory server")
+			os.Exit(1)
+		}
+	}
 
 	setupLog.Info("starting manager")
 	if err := mgr.Start(ctrl.SetupSignalHandler()); err != nil {
@@ -224,4 +1612,3 @@ func main() {
 		os.Exit(1)
 	}
 }
-
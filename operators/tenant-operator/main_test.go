@@ -0,0 +1,236 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	networkingv1 "k8s.io/api/networking/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+
+	platformv1alpha1 "github.com/xyz-company/tenant-operator/api/v1alpha1"
+)
+
+// newReconcilerClient is newFakeClient for the reconciler. The fake client
+// treats a server-side apply as a strategic merge patch of an existing
+// object, so this creates missing objects first, as the API server does.
+func newReconcilerClient(objs ...client.Object) client.WithWatch {
+	return reconcilerClientBuilder(objs...).Build()
+}
+
+// reconcilerClientBuilder returns the builder of newReconcilerClient, for
+// tests needing more, such as installedKinds
+func reconcilerClientBuilder(objs ...client.Object) *fake.ClientBuilder {
+	return fake.NewClientBuilder().
+		WithScheme(scheme).
+		WithObjects(objs...).
+		WithStatusSubresource(&platformv1alpha1.Tenant{}, &platformv1alpha1.TenantRequest{}).
+		WithInterceptorFuncs(interceptor.Funcs{Patch: serverSideApply})
+}
+
+// installedKinds returns a RESTMapper serving the namespaced kinds gvks, so
+// kindInstalled finds the optional CRDs a test needs. The fake client's
+// default mapper serves none, which skips every optional integration.
+func installedKinds(gvks ...schema.GroupVersionKind) meta.RESTMapper {
+	mapper := meta.NewDefaultRESTMapper(nil)
+	for _, gvk := range gvks {
+		mapper.Add(gvk, meta.RESTScopeNamespace)
+	}
+	return mapper
+}
+
+func serverSideApply(ctx context.Context, c client.WithWatch, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+	if patch.Type() != types.ApplyPatchType {
+		return c.Patch(ctx, obj, patch, opts...)
+	}
+	current := obj.DeepCopyObject().(client.Object)
+	err := c.Get(ctx, client.ObjectKeyFromObject(obj), current)
+	if apierrors.IsNotFound(err) {
+		return c.Create(ctx, obj)
+	}
+	if err != nil {
+		return err
+	}
+	// Strategic merge needs a Go type; a merge patch does for everything else
+	if _, ok := obj.(runtime.Unstructured); ok {
+		raw, err := json.Marshal(obj)
+		if err != nil {
+			return err
+		}
+		return c.Patch(ctx, obj, client.RawPatch(types.MergePatchType, raw))
+	}
+	return c.Patch(ctx, obj, patch, opts...)
+}
+
+// newTestReconciler returns a TenantReconciler of c with the defaults main
+// would give it
+func newTestReconciler(c client.Client) *TenantReconciler {
+	return &TenantReconciler{
+		Client:    c,
+		Scheme:    scheme,
+		Recorder:  record.NewFakeRecorder(100),
+		APIReader: c,
+	}
+}
+
+// reconcileTenant reconciles the Tenant name and fails t on error
+func reconcileTenant(t *testing.T, r *TenantReconciler, name string) ctrl.Result {
+	t.Helper()
+	result, err := r.Reconcile(context.Background(), ctrl.Request{NamespacedName: types.NamespacedName{Name: name}})
+	if err != nil {
+		t.Fatalf("reconciling tenant %s: %v", name, err)
+	}
+	return result
+}
+
+func TestSameNamespacePolicy(t *testing.T) {
+	c := newReconcilerClient(newTenant("search", "search-team"))
+	reconcileTenant(t, newTestReconciler(c), "search")
+
+	deny := &networkingv1.NetworkPolicy{}
+	if err := c.Get(context.Background(), client.ObjectKey{Namespace: "search", Name: "default-deny-ingress"}, deny); err != nil {
+		t.Fatal(err)
+	}
+	if len(deny.Spec.Ingress) != 0 || len(deny.Spec.PodSelector.MatchLabels) != 0 {
+		t.Fatalf("default-deny-ingress spec = %+v, want every pod selected and no ingress", deny.Spec)
+	}
+
+	policy := &networkingv1.NetworkPolicy{}
+	if err := c.Get(context.Background(), client.ObjectKey{Namespace: "search", Name: "allow-same-namespace"}, policy); err != nil {
+		t.Fatalf("allow-same-namespace not created by default: %v", err)
+	}
+	spec := policy.Spec
+	if len(spec.PodSelector.MatchLabels) != 0 || len(spec.PodSelector.MatchExpressions) != 0 {
+		t.Errorf("podSelector = %+v, want every pod", spec.PodSelector)
+	}
+	if len(spec.PolicyTypes) != 1 || spec.PolicyTypes[0] != networkingv1.PolicyTypeIngress {
+		t.Errorf("policyTypes = %v, want [Ingress]", spec.PolicyTypes)
+	}
+	if len(spec.Ingress) != 1 || len(spec.Ingress[0].From) != 1 {
+		t.Fatalf("ingress = %+v, want one rule with one peer", spec.Ingress)
+	}
+	peer := spec.Ingress[0].From[0]
+	// A pod selector alone matches pods in the policy's own namespace; a
+	// namespace selector would open it to other namespaces
+	if peer.PodSelector == nil || len(peer.PodSelector.MatchLabels) != 0 || len(peer.PodSelector.MatchExpressions) != 0 {
+		t.Errorf("peer podSelector = %+v, want every pod", peer.PodSelector)
+	}
+	if peer.NamespaceSelector != nil || peer.IPBlock != nil {
+		t.Errorf("peer = %+v, want no namespace selector or IP block", peer)
+	}
+	if len(spec.Ingress[0].Ports) != 0 {
+		t.Errorf("ports = %v, want every port", spec.Ingress[0].Ports)
+	}
+}
+
+func TestSameNamespacePolicyDisabled(t *testing.T) {
+	tenant := newTenant("search", "search-team")
+	c := newReconcilerClient(tenant)
+	r := newTestReconciler(c)
+	reconcileTenant(t, r, "search")
+
+	disallow := false
+	if err := c.Get(context.Background(), client.ObjectKeyFromObject(tenant), tenant); err != nil {
+		t.Fatal(err)
+	}
+	tenant.Spec.AllowIntraNamespace = &disallow
+	if err := c.Update(context.Background(), tenant); err != nil {
+		t.Fatal(err)
+	}
+	reconcileTenant(t, r, "search")
+
+	err := c.Get(context.Background(), client.ObjectKey{Namespace: "search", Name: "allow-same-namespace"}, &networkingv1.NetworkPolicy{})
+	if !apierrors.IsNotFound(err) {
+		t.Fatalf("allow-same-namespace with allowIntraNamespace false: got %v, want not found", err)
+	}
+	if err := c.Get(context.Background(), client.ObjectKey{Namespace: "search", Name: "default-deny-ingress"}, &networkingv1.NetworkPolicy{}); err != nil {
+		t.Fatalf("default-deny-ingress removed: %v", err)
+	}
+}
+
+// recordedEvents drains the events r has recorded so far
+func recordedEvents(r *TenantReconciler) []string {
+	var events []string
+	recorder := r.Recorder.(*record.FakeRecorder)
+	for {
+		select {
+		case event := <-recorder.Events:
+			events = append(events, event)
+		default:
+			return events
+		}
+	}
+}
+
+// storedTenant returns the Tenant name as stored in c
+func storedTenant(t *testing.T, c client.Client, name string) *platformv1alpha1.Tenant {
+	t.Helper()
+	tenant := &platformv1alpha1.Tenant{}
+	if err := c.Get(context.Background(), client.ObjectKey{Name: name}, tenant); err != nil {
+		t.Fatal(err)
+	}
+	return tenant
+}
+
+func TestEnvtestSameNamespacePolicy(t *testing.T) {
+	ctx := context.Background()
+	c := envtestClient(t)
+	tenant := newTenant("same-namespace", "search-team")
+	r := createEnvtestTenant(t, c, tenant)
+	reconcileTenant(t, r, "same-namespace")
+
+	key := client.ObjectKey{Namespace: "same-namespace", Name: "allow-same-namespace"}
+	policy := &networkingv1.NetworkPolicy{}
+	if err := c.Get(ctx, key, policy); err != nil {
+		t.Fatal(err)
+	}
+	if ref := metav1.GetControllerOf(policy); ref == nil || ref.Kind != "Tenant" || ref.Name != "same-namespace" {
+		t.Fatalf("controller = %+v, want Tenant same-namespace", ref)
+	}
+	if managedFields(policy, fieldManager, metav1.ManagedFieldsOperationApply) == nil {
+		t.Fatalf("managed fields = %+v, want an apply by %s", policy.ManagedFields, fieldManager)
+	}
+
+	// The operator owns the rules, so an apply changing them conflicts
+	conflicting := sameNamespacePolicy("same-namespace")
+	conflicting.TypeMeta = metav1.TypeMeta{APIVersion: "networking.k8s.io/v1", Kind: "NetworkPolicy"}
+	conflicting.Spec.Ingress[0].From[0].NamespaceSelector = &metav1.LabelSelector{}
+	err := c.Patch(ctx, conflicting, client.Apply, client.FieldOwner("kubectl"))
+	if !apierrors.IsConflict(err) {
+		t.Fatalf("conflicting apply = %v, want a conflict with %s", err, fieldManager)
+	}
+
+	// An edit takes the rules over; the next reconcile forces them back
+	policy.Spec.Ingress[0].From[0].NamespaceSelector = &metav1.LabelSelector{}
+	if err := c.Update(ctx, policy, client.FieldOwner("kubectl-edit")); err != nil {
+		t.Fatal(err)
+	}
+	reconcileTenant(t, r, "same-namespace")
+	if err := c.Get(ctx, key, policy); err != nil {
+		t.Fatal(err)
+	}
+	if peer := policy.Spec.Ingress[0].From[0]; peer.NamespaceSelector != nil {
+		t.Fatalf("peer after an edit = %+v, want the namespace selector reverted", peer)
+	}
+
+	disallow := false
+	tenant = storedTenant(t, c, "same-namespace")
+	tenant.Spec.AllowIntraNamespace = &disallow
+	if err := c.Update(ctx, tenant); err != nil {
+		t.Fatal(err)
+	}
+	reconcileTenant(t, r, "same-namespace")
+	if err := c.Get(ctx, key, &networkingv1.NetworkPolicy{}); !apierrors.IsNotFound(err) {
+		t.Fatalf("allow-same-namespace with allowIntraNamespace false: got %v, want not found", err)
+	}
+}
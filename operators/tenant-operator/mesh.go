@@ -0,0 +1,350 @@
+// Istio integration
+// Mesh-enabled tenant namespaces get sidecar injection, STRICT mTLS and a
+// Sidecar limiting egress to the tenant's integrations. Their
+// AuthorizationPolicies admit callers from the same namespace and from the
+// tenants integrating with them; once there are such tenants, or with
+// MeshDefaultDeny, everything else is denied.
+// Unlike the integration NetworkPolicies, which only add exceptions, an
+// Istio ALLOW policy denies whatever it doesn't match, so each tenant writes
+// the AuthorizationPolicies of its own namespaces.
+// Istio is optional, so every step skips itself when its CRDs are missing.
+
+package main
+
+import (
+	"context"
+	"sort"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	platformv1alpha1 "github.com/xyz-company/tenant-operator/api/v1alpha1"
+)
+
+var (
+	authorizationPolicyGVK = schema.GroupVersionKind{Group: "security.istio.io", Version: "v1beta1", Kind: "AuthorizationPolicy"}
+	peerAuthenticationGVK  = schema.GroupVersionKind{Group: "security.istio.io", Version: "v1beta1", Kind: "PeerAuthentication"}
+	sidecarGVK             = schema.GroupVersionKind{Group: "networking.istio.io", Version: "v1beta1", Kind: "Sidecar"}
+)
+
+// meshIstioNamespace hosts the Istio control plane, which sidecars must
+// always reach
+const meshIstioNamespace = "istio-system"
+
+// meshEnabled reports whether the tenant runs in the mesh
+func meshEnabled(spec *platformv1alpha1.TenantSpec) bool {
+	return spec.ServiceMesh == nil || spec.ServiceMesh.Enabled == nil || *spec.ServiceMesh.Enabled
+}
+
+// istioInjection returns the istio-injection namespace label value for spec
+func istioInjection(spec *platformv1alpha1.TenantSpec) string {
+	if meshEnabled(spec) {
+		return "enabled"
+	}
+	return "disabled"
+}
+
+// reconcileMeshTraffic applies the STRICT mTLS PeerAuthentication and the
+// default Sidecar of a mesh-enabled tenant namespace, and removes them when
+// the mesh is disabled
+func (r *TenantReconciler) reconcileMeshTraffic(ctx context.Context, tenant *platformv1alpha1.Tenant, namespace string) error {
+	log := ctrl.LoggerFrom(ctx)
+	enabled := meshEnabled(&tenant.Spec)
+
+	var integrations []string
+	if enabled {
+		var err error
+		if integrations, err = r.integrationNamespaces(ctx, tenant); err != nil {
+			return err
+		}
+	}
+
+	for _, object := range []*unstructured.Unstructured{
+		meshPeerAuthentication(namespace),
+		meshSidecar(namespace, append(append([]string{}, r.PlatformNamespaces...), integrations...)),
+	} {
+		installed, err := r.kindInstalled(object.GroupVersionKind())
+		if err != nil {
+			return err
+		}
+		if !installed {
+			if enabled {
+				log.Info("Istio CRD not installed, skipping", "namespace", namespace, "kind", object.GetKind())
+			}
+			continue
+		}
+
+		if !enabled {
+			if err := r.Delete(ctx, object); err != nil && !errors.IsNotFound(err) {
+				return err
+			}
+			continue
+		}
+		if err := r.applyOrAdopt(ctx, tenant, object); err != nil {
+			return err
+		}
+		log.Info("Istio resource applied", "namespace", namespace, "kind", object.GetKind(), "name", object.GetName())
+	}
+	return nil
+}
+
+// meshPeerAuthentication returns the namespace-wide PeerAuthentication
+// requiring mTLS for all traffic into namespace
+func meshPeerAuthentication(namespace string) *unstructured.Unstructured {
+	policy := &unstructured.Unstructured{Object: map[string]interface{}{
+		"spec": map[string]interface{}{
+			"mtls": map[string]interface{}{"mode": "STRICT"},
+		},
+	}}
+	policy.SetGroupVersionKind(peerAuthenticationGVK)
+	policy.SetNamespace(namespace)
+	policy.SetName("default")
+	return policy
+}
+
+// meshSidecar returns the default Sidecar of namespace, which only lets its
+// proxies reach services in the namespace itself, the Istio control plane
+// and allowed, e.g. the tenant's integrations
+func meshSidecar(namespace string, allowed []string) *unstructured.Unstructured {
+	hosts := []interface{}{"./*", meshIstioNamespace + "/*"}
+	seen := map[string]bool{namespace: true, meshIstioNamespace: true}
+	for _, name := range allowed {
+		if !seen[name] {
+			seen[name] = true
+			hosts = append(hosts, name+"/*")
+		}
+	}
+
+	sidecar := &unstructured.Unstructured{Object: map[string]interface{}{
+		"spec": map[string]interface{}{
+			"egress": []interface{}{
+				map[string]interface{}{"hosts": hosts},
+			},
+		},
+	}}
+	sidecar.SetGroupVersionKind(sidecarGVK)
+	sidecar.SetNamespace(namespace)
+	sidecar.SetName("default")
+	return sidecar
+}
+
+// reconcileMeshAuthorization applies the AuthorizationPolicies of a
+// mesh-enabled tenant namespace: allow-integrations for the namespaces of
+// the tenants integrating with it, and the deny-all and allow-same-namespace
+// pair when there are such tenants or Spec.MeshDefaultDeny is set. Policies
+// not needed are removed.
+func (r *TenantReconciler) reconcileMeshAuthorization(ctx context.Context, tenant *platformv1alpha1.Tenant, namespace string) error {
+	log := ctrl.LoggerFrom(ctx)
+
+	installed, err := r.kindInstalled(authorizationPolicyGVK)
+	if err != nil {
+		return err
+	}
+	if !installed {
+		if tenant.Spec.MeshDefaultDeny {
+			log.Info("Istio AuthorizationPolicy CRD not installed, skipping mesh default deny", "namespace", namespace)
+		}
+		return nil
+	}
+
+	enabled := meshEnabled(&tenant.Spec)
+	var callers []string
+	if enabled {
+		if callers, err = r.integratingNamespaces(ctx, tenant); err != nil {
+			return err
+		}
+	}
+	defaultDeny := enabled && (tenant.Spec.MeshDefaultDeny || len(callers) > 0)
+
+	// The allow rules go first, so callers are never denied in between
+	defaults := meshDefaultDenyPolicies(namespace)
+	policies := []struct {
+		policy *unstructured.Unstructured
+		want   bool
+	}{
+		{defaults[1], defaultDeny},
+		{meshIntegrationPolicy(namespace, callers), len(callers) > 0},
+		{defaults[0], defaultDeny},
+	}
+
+	for _, p := range policies {
+		if p.want {
+			if err := r.applyOrAdopt(ctx, tenant, p.policy); err != nil {
+				return err
+			}
+			log.Info("AuthorizationPolicy applied", "namespace", namespace, "authorizationPolicy", p.policy.GetName())
+		} else if err := r.Delete(ctx, p.policy); err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// meshIntegrationPolicy returns the ALLOW policy admitting requests into
+// namespace from workloads in the callers namespaces. Istio derives the
+// source namespace from the caller's mTLS identity.
+func meshIntegrationPolicy(namespace string, callers []string) *unstructured.Unstructured {
+	namespaces := make([]interface{}, 0, len(callers))
+	for _, caller := range callers {
+		namespaces = append(namespaces, caller)
+	}
+
+	policy := &unstructured.Unstructured{Object: map[string]interface{}{
+		"spec": map[string]interface{}{
+			"action": "ALLOW",
+			"rules": []interface{}{
+				map[string]interface{}{
+					"from": []interface{}{
+						map[string]interface{}{
+							"source": map[string]interface{}{
+								"namespaces": namespaces,
+							},
+						},
+					},
+				},
+			},
+		},
+	}}
+	policy.SetGroupVersionKind(authorizationPolicyGVK)
+	policy.SetNamespace(namespace)
+	policy.SetName("allow-integrations")
+	return policy
+}
+
+// integratingNamespaces returns the namespaces of the other Tenants whose
+// AllowedIntegrations, their own or inherited, name tenant or one of its
+// namespaces, sorted
+func (r *TenantReconciler) integratingNamespaces(ctx context.Context, tenant *platformv1alpha1.Tenant) ([]string, error) {
+	tenants, err := listTenants(ctx, r.Client)
+	if err != nil {
+		return nil, err
+	}
+	byName := tenantsByName(tenants)
+	targets := map[string]bool{tenant.Name: true}
+	for _, namespace := range knownNamespaces(tenant) {
+		targets[namespace] = true
+	}
+
+	var namespaces []string
+	for i := range tenants {
+		caller := &tenants[i]
+		if caller.Name != tenant.Name && integratesWith(caller, byName, targets) {
+			namespaces = append(namespaces, knownNamespaces(caller)...)
+		}
+	}
+	sort.Strings(namespaces)
+	return namespaces, nil
+}
+
+// integratesWith reports whether tenant's AllowedIntegrations, or those it
+// inherits from its ancestors, name any of targets
+func integratesWith(tenant *platformv1alpha1.Tenant, byName map[string]*platformv1alpha1.Tenant, targets map[string]bool) bool {
+	for _, name := range lineageIntegrations(tenant, byName) {
+		if targets[name] {
+			return true
+		}
+	}
+	return false
+}
+
+// lineageIntegrations returns the AllowedIntegrations of tenant and its
+// ancestors. A broken parent chain contributes only tenant's own.
+func lineageIntegrations(tenant *platformv1alpha1.Tenant, byName map[string]*platformv1alpha1.Tenant) []string {
+	names := append([]string{}, tenant.Spec.AllowedIntegrations...)
+	ancestors, err := tenantAncestors(tenant, byName)
+	if err != nil {
+		return names
+	}
+	for _, ancestor := range ancestors {
+		names = append(names, ancestor.Spec.AllowedIntegrations...)
+	}
+	return names
+}
+
+// tenantToIntegrationTargets maps a Tenant change to a reconcile of the
+// tenants it integrates with, so their AllowedIntegrations policies follow.
+// Updates map both the old and the new object, so dropped targets are
+// reconciled too.
+func (r *TenantReconciler) tenantToIntegrationTargets(ctx context.Context, obj client.Object) []reconcile.Request {
+	tenant, ok := obj.(*platformv1alpha1.Tenant)
+	if !ok {
+		return nil
+	}
+	tenants, err := listTenants(ctx, r.Client)
+	if err != nil {
+		ctrl.LoggerFrom(ctx).Error(err, "Failed to list Tenants for integrations", "tenant", tenant.Name)
+		return nil
+	}
+	byName := tenantsByName(tenants)
+	byName[tenant.Name] = tenant
+
+	names := map[string]bool{}
+	for _, name := range lineageIntegrations(tenant, byName) {
+		names[name] = true
+	}
+	var requests []reconcile.Request
+	for i := range tenants {
+		target := &tenants[i]
+		if target.Name == tenant.Name {
+			continue
+		}
+		for _, name := range append([]string{target.Name}, knownNamespaces(target)...) {
+			if names[name] {
+				requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Name: target.Name}})
+				break
+			}
+		}
+	}
+	return requests
+}
+
+// meshDefaultDenyPolicies returns an empty-spec (deny-all) policy and an
+// ALLOW policy for requests from the same namespace. Istio denies any request
+// not matched by an ALLOW policy, so together they only admit same-namespace
+// traffic; more ALLOW policies can be added next to them.
+func meshDefaultDenyPolicies(namespace string) []*unstructured.Unstructured {
+	denyAll := &unstructured.Unstructured{Object: map[string]interface{}{
+		"spec": map[string]interface{}{},
+	}}
+	denyAll.SetGroupVersionKind(authorizationPolicyGVK)
+	denyAll.SetNamespace(namespace)
+	denyAll.SetName("deny-all")
+
+	allowSameNamespace := &unstructured.Unstructured{Object: map[string]interface{}{
+		"spec": map[string]interface{}{
+			"action": "ALLOW",
+			"rules": []interface{}{
+				map[string]interface{}{
+					"from": []interface{}{
+						map[string]interface{}{
+							"source": map[string]interface{}{
+								"namespaces": []interface{}{namespace},
+							},
+						},
+					},
+				},
+			},
+		},
+	}}
+	allowSameNamespace.SetGroupVersionKind(authorizationPolicyGVK)
+	allowSameNamespace.SetNamespace(namespace)
+	allowSameNamespace.SetName("allow-same-namespace")
+
+	return []*unstructured.Unstructured{denyAll, allowSameNamespace}
+}
+
+// kindInstalled reports whether the API server serves gvk, so optional
+// integrations can skip themselves when their CRDs are absent
+func (r *TenantReconciler) kindInstalled(gvk schema.GroupVersionKind) (bool, error) {
+	_, err := r.RESTMapper().RESTMapping(gvk.GroupKind(), gvk.Version)
+	if meta.IsNoMatchError(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
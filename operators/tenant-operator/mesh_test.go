@@ -0,0 +1,210 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	platformv1alpha1 "github.com/xyz-company/tenant-operator/api/v1alpha1"
+)
+
+// getAuthorizationPolicy returns the AuthorizationPolicy name in namespace,
+// or nil if there is none
+func getAuthorizationPolicy(t *testing.T, c client.Client, namespace, name string) *unstructured.Unstructured {
+	t.Helper()
+	policy := &unstructured.Unstructured{}
+	policy.SetGroupVersionKind(authorizationPolicyGVK)
+	err := c.Get(context.Background(), client.ObjectKey{Namespace: namespace, Name: name}, policy)
+	if client.IgnoreNotFound(err) != nil {
+		t.Fatal(err)
+	}
+	if err != nil {
+		return nil
+	}
+	return policy
+}
+
+func meshTenant(defaultDeny bool) *platformv1alpha1.Tenant {
+	tenant := newTenant("search", "search-team")
+	tenant.UID = "search-uid"
+	tenant.Spec.MeshDefaultDeny = defaultDeny
+	return tenant
+}
+
+func TestMeshDefaultDeny(t *testing.T) {
+	c := reconcilerClientBuilder(meshTenant(true)).WithRESTMapper(installedKinds(authorizationPolicyGVK)).Build()
+	reconcileTenant(t, newTestReconciler(c), "search")
+
+	denyAll := getAuthorizationPolicy(t, c, "search", "deny-all")
+	if denyAll == nil {
+		t.Fatal("deny-all not created")
+	}
+	// An empty spec matches nothing as ALLOW, so it denies everything else
+	if spec, _, _ := unstructured.NestedMap(denyAll.Object, "spec"); len(spec) != 0 {
+		t.Errorf("deny-all spec = %v, want empty", spec)
+	}
+
+	allow := getAuthorizationPolicy(t, c, "search", "allow-same-namespace")
+	if allow == nil {
+		t.Fatal("allow-same-namespace not created")
+	}
+	if action, _, _ := unstructured.NestedString(allow.Object, "spec", "action"); action != "ALLOW" {
+		t.Errorf("allow-same-namespace action = %q, want ALLOW", action)
+	}
+	rules, _, _ := unstructured.NestedSlice(allow.Object, "spec", "rules")
+	want := []interface{}{map[string]interface{}{
+		"from": []interface{}{map[string]interface{}{
+			"source": map[string]interface{}{"namespaces": []interface{}{"search"}},
+		}},
+	}}
+	if !reflect.DeepEqual(rules, want) {
+		t.Errorf("allow-same-namespace rules = %v, want %v", rules, want)
+	}
+
+	for _, policy := range []*unstructured.Unstructured{denyAll, allow} {
+		owner := metav1.GetControllerOf(policy)
+		if owner == nil || owner.Kind != "Tenant" || owner.UID != "search-uid" {
+			t.Errorf("%s controller = %+v, want Tenant search", policy.GetName(), owner)
+		}
+		if policy.GetLabels()[tenantLabel] != "search" {
+			t.Errorf("%s labels = %v, want %s=search", policy.GetName(), policy.GetLabels(), tenantLabel)
+		}
+	}
+	if getAuthorizationPolicy(t, c, "search", "allow-integrations") != nil {
+		t.Error("allow-integrations created without integrating tenants")
+	}
+}
+
+func TestMeshDefaultDenyRemoved(t *testing.T) {
+	tenant := meshTenant(true)
+	c := reconcilerClientBuilder(tenant).WithRESTMapper(installedKinds(authorizationPolicyGVK)).Build()
+	r := newTestReconciler(c)
+	reconcileTenant(t, r, "search")
+
+	if err := c.Get(context.Background(), client.ObjectKeyFromObject(tenant), tenant); err != nil {
+		t.Fatal(err)
+	}
+	tenant.Spec.MeshDefaultDeny = false
+	if err := c.Update(context.Background(), tenant); err != nil {
+		t.Fatal(err)
+	}
+	reconcileTenant(t, r, "search")
+
+	for _, name := range []string{"deny-all", "allow-same-namespace"} {
+		if getAuthorizationPolicy(t, c, "search", name) != nil {
+			t.Errorf("%s kept after meshDefaultDeny was unset", name)
+		}
+	}
+}
+
+func TestMeshDefaultDenyNeedsMesh(t *testing.T) {
+	disabled := false
+	tenant := meshTenant(true)
+	tenant.Spec.ServiceMesh = &platformv1alpha1.ServiceMeshSpec{Enabled: &disabled}
+	c := reconcilerClientBuilder(tenant).WithRESTMapper(installedKinds(authorizationPolicyGVK)).Build()
+	reconcileTenant(t, newTestReconciler(c), "search")
+
+	if getAuthorizationPolicy(t, c, "search", "deny-all") != nil {
+		t.Fatal("deny-all created for a tenant outside the mesh")
+	}
+}
+
+func TestMeshDefaultDenyWithoutIstio(t *testing.T) {
+	c := newReconcilerClient(meshTenant(true))
+	reconcileTenant(t, newTestReconciler(c), "search")
+
+	tenant := &platformv1alpha1.Tenant{}
+	if err := c.Get(context.Background(), client.ObjectKey{Name: "search"}, tenant); err != nil {
+		t.Fatal(err)
+	}
+	if tenant.Status.Phase != "Ready" {
+		t.Fatalf("phase = %s (%s), want Ready with the policies skipped", tenant.Status.Phase, tenant.Status.Message)
+	}
+	policy := &unstructured.Unstructured{}
+	policy.SetGroupVersionKind(authorizationPolicyGVK)
+	if err := c.Get(context.Background(), client.ObjectKey{Namespace: "search", Name: "deny-all"}, policy); err == nil {
+		t.Fatal("deny-all created without the Istio CRDs")
+	} else if !meta.IsNoMatchError(err) && client.IgnoreNotFound(err) != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMeshIntegrationsImplyDefaultDeny(t *testing.T) {
+	caller := newTenant("ads", "ads-team")
+	caller.Spec.AllowedIntegrations = []string{"search"}
+	c := reconcilerClientBuilder(meshTenant(false), caller).WithRESTMapper(installedKinds(authorizationPolicyGVK)).Build()
+	reconcileTenant(t, newTestReconciler(c), "search")
+
+	allow := getAuthorizationPolicy(t, c, "search", "allow-integrations")
+	if allow == nil {
+		t.Fatal("allow-integrations not created for an integrating tenant")
+	}
+	rules, _, _ := unstructured.NestedSlice(allow.Object, "spec", "rules")
+	want := []interface{}{map[string]interface{}{
+		"from": []interface{}{map[string]interface{}{
+			"source": map[string]interface{}{"namespaces": []interface{}{"ads"}},
+		}},
+	}}
+	if !reflect.DeepEqual(rules, want) {
+		t.Errorf("allow-integrations rules = %v, want %v", rules, want)
+	}
+	// Allowing some callers only restricts anything once the rest are denied
+	for _, name := range []string{"deny-all", "allow-same-namespace"} {
+		if getAuthorizationPolicy(t, c, "search", name) == nil {
+			t.Errorf("%s not created alongside allow-integrations", name)
+		}
+	}
+}
+
+func TestEnvtestMeshDefaultDeny(t *testing.T) {
+	ctx := context.Background()
+	c := envtestClient(t)
+	tenant := newTenant("mesh-deny", "search-team")
+	tenant.Spec.MeshDefaultDeny = true
+	r := createEnvtestTenant(t, c, tenant)
+	reconcileTenant(t, r, "mesh-deny")
+
+	uid := storedTenant(t, c, "mesh-deny").UID
+	for _, name := range []string{"deny-all", "allow-same-namespace"} {
+		policy := getAuthorizationPolicy(t, c, "mesh-deny", name)
+		if policy == nil {
+			t.Fatalf("%s not created", name)
+		}
+		if owner := metav1.GetControllerOf(policy); owner == nil || owner.UID != uid {
+			t.Errorf("%s controller = %+v, want Tenant mesh-deny", name, owner)
+		}
+	}
+	peer := &unstructured.Unstructured{}
+	peer.SetGroupVersionKind(peerAuthenticationGVK)
+	if err := c.Get(ctx, client.ObjectKey{Namespace: "mesh-deny", Name: "default"}, peer); err != nil {
+		t.Fatalf("PeerAuthentication: %v", err)
+	}
+
+	// A deleted policy is put back on the next pass
+	denyAll := getAuthorizationPolicy(t, c, "mesh-deny", "deny-all")
+	if err := c.Delete(ctx, denyAll); err != nil {
+		t.Fatal(err)
+	}
+	reconcileTenant(t, r, "mesh-deny")
+	if getAuthorizationPolicy(t, c, "mesh-deny", "deny-all") == nil {
+		t.Fatal("deny-all not recreated after deletion")
+	}
+
+	disabled := false
+	stored := storedTenant(t, c, "mesh-deny")
+	stored.Spec.ServiceMesh = &platformv1alpha1.ServiceMeshSpec{Enabled: &disabled}
+	if err := c.Update(ctx, stored); err != nil {
+		t.Fatal(err)
+	}
+	reconcileTenant(t, r, "mesh-deny")
+	for _, name := range []string{"deny-all", "allow-same-namespace"} {
+		if getAuthorizationPolicy(t, c, "mesh-deny", name) != nil {
+			t.Errorf("%s kept after the mesh was disabled", name)
+		}
+	}
+}
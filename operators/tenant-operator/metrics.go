@@ -0,0 +1,114 @@
+// Tenant metrics
+// Tenant counts and quota capacity are exported next to the controller-runtime
+// metrics on --metrics-bind-address, for per-tenant capacity dashboards
+
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	platformv1alpha1 "github.com/xyz-company/tenant-operator/api/v1alpha1"
+)
+
+// collectTimeout bounds the cache reads of a single scrape
+const collectTimeout = 5 * time.Second
+
+var (
+	// reconcileErrors counts failed reconciles per tenant
+	reconcileErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "tenant_operator_reconcile_errors_total",
+		Help: "Number of failed Tenant reconciles.",
+	}, []string{"tenant"})
+
+	tenantsDesc = prometheus.NewDesc("tenant_operator_tenants_total",
+		"Number of Tenants by status phase.", []string{"phase"}, nil)
+	quotaHardDesc = prometheus.NewDesc("tenant_operator_quota_hard",
+		"Hard limit of the tenant ResourceQuota.", []string{"tenant", "resource"}, nil)
+	quotaUsedDesc = prometheus.NewDesc("tenant_operator_quota_used",
+		"Current usage of the tenant ResourceQuota.", []string{"tenant", "resource"}, nil)
+)
+
+// tenantCollector reports Tenants and their quotas from the cache at scrape
+// time, so the numbers are never staler than the cache
+type tenantCollector struct {
+	reader client.Reader
+}
+
+// Describe implements prometheus.Collector
+func (c *tenantCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- tenantsDesc
+	ch <- quotaHardDesc
+	ch <- quotaUsedDesc
+}
+
+// Collect implements prometheus.Collector
+func (c *tenantCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), collectTimeout)
+	defer cancel()
+
+	tenants, err := listTenants(ctx, c.reader)
+	if err != nil {
+		ch <- prometheus.NewInvalidMetric(tenantsDesc, err)
+		return
+	}
+	phases := map[string]int{
+		TenantPhaseProvisioning: 0,
+		TenantPhaseReady:        0,
+		TenantPhaseError:        0,
+		TenantPhaseSuspended:    0,
+		TenantPhaseExpired:      0,
+	}
+	for _, tenant := range tenants {
+		phase := tenant.Status.Phase
+		if phase == "" {
+			// Not reconciled yet
+			phase = TenantPhaseProvisioning
+		}
+		phases[phase]++
+	}
+	for phase, count := range phases {
+		ch <- prometheus.MustNewConstMetric(tenantsDesc, prometheus.GaugeValue, float64(count), phase)
+	}
+
+	for _, tenant := range tenants {
+		c.collectQuota(ctx, ch, &tenant)
+	}
+}
+
+// collectQuota reports the hard limits and usage of tenant's ResourceQuotas,
+// summed over its namespaces
+func (c *tenantCollector) collectQuota(ctx context.Context, ch chan<- prometheus.Metric, tenant *platformv1alpha1.Tenant) {
+	hard, used := corev1.ResourceList{}, corev1.ResourceList{}
+	for _, namespace := range knownNamespaces(tenant) {
+		quota := &corev1.ResourceQuota{}
+		if err := c.reader.Get(ctx, client.ObjectKey{Namespace: namespace, Name: "tenant-quota"}, quota); err != nil {
+			if client.IgnoreNotFound(err) != nil {
+				ch <- prometheus.NewInvalidMetric(quotaHardDesc, err)
+				return
+			}
+			continue
+		}
+		addResources(hard, quota.Status.Hard)
+		addResources(used, quota.Status.Used)
+	}
+	for name, value := range hard {
+		ch <- prometheus.MustNewConstMetric(quotaHardDesc, prometheus.GaugeValue, value.AsApproximateFloat64(), tenant.Name, string(name))
+	}
+	for name, value := range used {
+		ch <- prometheus.MustNewConstMetric(quotaUsedDesc, prometheus.GaugeValue, value.AsApproximateFloat64(), tenant.Name, string(name))
+	}
+}
+
+// addResources adds every quantity in list to total
+func addResources(total, list corev1.ResourceList) {
+	for name, value := range list {
+		sum := total[name]
+		sum.Add(value)
+		total[name] = sum
+	}
+}
@@ -0,0 +1,299 @@
+// Tenant monitoring
+// Spec.Observability.Metrics gives the tenant its slice of the platform's
+// Prometheus Operator stack:
+//
+//	ServiceMonitor, PodMonitor  tenant-metrics in each tenant namespace,
+//	                            scraping the "metrics" port of its Services
+//	                            and pods, and only that namespace's
+//	PrometheusRule              tenant-<name> in --monitoring-namespace,
+//	                            recording the tenant's quota usage,
+//	                            utilization and request errors as
+//	                            tenant_<name>:... series
+//	ConfigMap                   tenant-<name>-dashboard in
+//	                            --monitoring-namespace, a Grafana dashboard
+//	                            of those series and the error budget left
+//
+// The monitors and rules are skipped while the Prometheus Operator CRDs are
+// missing; the dashboard is published regardless.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	platformv1alpha1 "github.com/xyz-company/tenant-operator/api/v1alpha1"
+)
+
+var (
+	serviceMonitorGVK = schema.GroupVersionKind{Group: "monitoring.coreos.com", Version: "v1", Kind: "ServiceMonitor"}
+	podMonitorGVK     = schema.GroupVersionKind{Group: "monitoring.coreos.com", Version: "v1", Kind: "PodMonitor"}
+	prometheusRuleGVK = schema.GroupVersionKind{Group: "monitoring.coreos.com", Version: "v1", Kind: "PrometheusRule"}
+)
+
+const (
+	// tenantMetricsMonitor names the monitors in each tenant namespace
+	tenantMetricsMonitor = "tenant-metrics"
+	// metricsPortName is the port the monitors scrape
+	metricsPortName = "metrics"
+	// defaultAvailabilityObjective is the availability objective of tenants
+	// not setting one, in percent
+	defaultAvailabilityObjective = "99.9"
+	// errorBudgetWindow is the window the error budget is spent over
+	errorBudgetWindow = "30d"
+)
+
+// MonitoringConfig is where the platform's Prometheus and Grafana pick up
+// tenant monitoring
+type MonitoringConfig struct {
+	// Namespace holds the tenant PrometheusRules and dashboards
+	// (--monitoring-namespace)
+	Namespace string
+	// Labels are set on the monitors and rules for Prometheus to select
+	// them (--monitoring-labels)
+	Labels map[string]string
+	// DashboardLabels are set on the dashboard ConfigMaps for Grafana's
+	// sidecar to load them (--grafana-dashboard-label)
+	DashboardLabels map[string]string
+}
+
+// parseMonitoringConfig reads the comma-separated key=value labels of
+// --monitoring-labels and --grafana-dashboard-label
+func parseMonitoringConfig(namespace, monitoringLabels, dashboardLabel string) (MonitoringConfig, error) {
+	config := MonitoringConfig{Namespace: namespace}
+	var err error
+	if config.Labels, err = labels.ConvertSelectorToLabelsMap(monitoringLabels); err != nil {
+		return MonitoringConfig{}, fmt.Errorf("--monitoring-labels %q: %w", monitoringLabels, err)
+	}
+	if config.DashboardLabels, err = labels.ConvertSelectorToLabelsMap(dashboardLabel); err != nil {
+		return MonitoringConfig{}, fmt.Errorf("--grafana-dashboard-label %q: %w", dashboardLabel, err)
+	}
+	if len(config.DashboardLabels) == 0 {
+		return MonitoringConfig{}, fmt.Errorf("--grafana-dashboard-label must not be empty")
+	}
+	return config, nil
+}
+
+// metricsEnabled reports whether tenant has Spec.Observability.Metrics
+func metricsEnabled(tenant *platformv1alpha1.Tenant) bool {
+	return tenant.Spec.Observability != nil && tenant.Spec.Observability.Metrics
+}
+
+// validateMetrics rejects availability objectives that aren't a percent
+// below 100
+func validateMetrics(observability *platformv1alpha1.TenantObservability) error {
+	if observability == nil || observability.AvailabilityObjective == "" {
+		return nil
+	}
+	objective, err := strconv.ParseFloat(observability.AvailabilityObjective, 64)
+	if err != nil || objective <= 0 || objective >= 100 {
+		return fmt.Errorf("observability.availabilityObjective %q must be a percent between 0 and 100, such as 99.9", observability.AvailabilityObjective)
+	}
+	return nil
+}
+
+// availabilityObjective returns tenant's availability objective in percent
+func availabilityObjective(tenant *platformv1alpha1.Tenant) string {
+	if objective := tenant.Spec.Observability.AvailabilityObjective; objective != "" {
+		return objective
+	}
+	return defaultAvailabilityObjective
+}
+
+// recordingPrefix returns the prefix of tenant's recorded series; tenant
+// names may hold dashes, which metric names can't
+func recordingPrefix(tenant *platformv1alpha1.Tenant) string {
+	return "tenant_" + strings.ReplaceAll(tenant.Name, "-", "_")
+}
+
+// monitoringObjects returns tenant's monitors in each of namespaces and
+// its PrometheusRule
+func (c *MonitoringConfig) monitoringObjects(tenant *platformv1alpha1.Tenant, namespaces []string) []*unstructured.Unstructured {
+	var objects []*unstructured.Unstructured
+	for _, namespace := range namespaces {
+		for _, monitor := range []struct {
+			gvk       schema.GroupVersionKind
+			endpoints string
+		}{
+			{serviceMonitorGVK, "endpoints"},
+			{podMonitorGVK, "podMetricsEndpoints"},
+		} {
+			obj := &unstructured.Unstructured{Object: map[string]interface{}{
+				"spec": map[string]interface{}{
+					"selector":          map[string]interface{}{},
+					"namespaceSelector": map[string]interface{}{"matchNames": []interface{}{namespace}},
+					monitor.endpoints:   []interface{}{map[string]interface{}{"port": metricsPortName}},
+				},
+			}}
+			obj.SetGroupVersionKind(monitor.gvk)
+			obj.SetNamespace(namespace)
+			obj.SetName(tenantMetricsMonitor)
+			obj.SetLabels(copyLabels(c.Labels))
+			objects = append(objects, obj)
+		}
+	}
+
+	prefix := recordingPrefix(tenant)
+	selector := namespaceMatcher("namespace", namespaces)
+	requests := namespaceMatcher("destination_workload_namespace", namespaces)
+	recorded := []struct{ record, expr string }{
+		{":quota_used:ratio", fmt.Sprintf(`sum by (resource) (kube_resourcequota{%s,resourcequota="tenant-quota",type="used"}) / sum by (resource) (kube_resourcequota{%s,resourcequota="tenant-quota",type="hard"} > 0)`, selector, selector)},
+		{":cpu_usage:cores", fmt.Sprintf(`sum(rate(container_cpu_usage_seconds_total{%s,container!=""}[5m]))`, selector)},
+		{":memory_working_set:bytes", fmt.Sprintf(`sum(container_memory_working_set_bytes{%s,container!=""})`, selector)},
+		{":requests:rate5m", fmt.Sprintf(`sum(rate(istio_requests_total{reporter="destination",%s}[5m]))`, requests)},
+		{":request_errors:ratio_rate5m", fmt.Sprintf(`sum(rate(istio_requests_total{reporter="destination",%s,response_code=~"5.."}[5m])) / sum(rate(istio_requests_total{reporter="destination",%s}[5m]))`, requests, requests)},
+		{":error_budget_remaining:ratio", fmt.Sprintf(`1 - avg_over_time(%s:request_errors:ratio_rate5m[%s]) / (1 - %s / 100)`, prefix, errorBudgetWindow, availabilityObjective(tenant))},
+	}
+	rules := make([]interface{}, 0, len(recorded))
+	for _, rule := range recorded {
+		rules = append(rules, map[string]interface{}{
+			"record": prefix + rule.record,
+			"expr":   rule.expr,
+			"labels": map[string]interface{}{"tenant": tenant.Name},
+		})
+	}
+	rule := &unstructured.Unstructured{Object: map[string]interface{}{
+		"spec": map[string]interface{}{
+			"groups": []interface{}{map[string]interface{}{
+				"name":  "tenant-" + tenant.Name,
+				"rules": rules,
+			}},
+		},
+	}}
+	rule.SetGroupVersionKind(prometheusRuleGVK)
+	rule.SetNamespace(c.Namespace)
+	rule.SetName("tenant-" + tenant.Name)
+	rule.SetLabels(copyLabels(c.Labels))
+	return append(objects, rule)
+}
+
+// namespaceMatcher returns the PromQL matcher of label for namespaces
+func namespaceMatcher(label string, namespaces []string) string {
+	// Namespace names hold no regular expression metacharacters
+	return fmt.Sprintf(`%s=~"%s"`, label, strings.Join(namespaces, "|"))
+}
+
+// copyLabels returns a copy of labels, which applyOrAdopt adds to
+func copyLabels(labels map[string]string) map[string]string {
+	copied := make(map[string]string, len(labels))
+	for key, value := range labels {
+		copied[key] = value
+	}
+	return copied
+}
+
+// dashboardConfigMap returns the ConfigMap of tenant's Grafana dashboard
+func (c *MonitoringConfig) dashboardConfigMap(tenant *platformv1alpha1.Tenant) (*corev1.ConfigMap, error) {
+	cm := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{
+		Namespace: c.Namespace,
+		Name:      "tenant-" + tenant.Name + "-dashboard",
+		Labels:    copyLabels(c.DashboardLabels),
+	}}
+	if !metricsEnabled(tenant) {
+		return cm, nil
+	}
+
+	prefix := recordingPrefix(tenant)
+	panel := func(id int, kind, title, unit, expr, legend string, x, y, w int) map[string]interface{} {
+		return map[string]interface{}{
+			"id":         id,
+			"type":       kind,
+			"title":      title,
+			"datasource": map[string]interface{}{"type": "prometheus", "uid": "${datasource}"},
+			"gridPos":    map[string]interface{}{"x": x, "y": y, "w": w, "h": 8},
+			"fieldConfig": map[string]interface{}{
+				"defaults":  map[string]interface{}{"unit": unit},
+				"overrides": []interface{}{},
+			},
+			"targets": []interface{}{map[string]interface{}{"refId": "A", "expr": expr, "legendFormat": legend}},
+		}
+	}
+	dashboard := map[string]interface{}{
+		"uid":           "tenant-" + tenant.Name,
+		"title":         "Tenant " + tenant.Name,
+		"tags":          []interface{}{"tenant"},
+		"schemaVersion": 39,
+		"time":          map[string]interface{}{"from": "now-24h", "to": "now"},
+		"templating": map[string]interface{}{"list": []interface{}{map[string]interface{}{
+			"name":  "datasource",
+			"type":  "datasource",
+			"query": "prometheus",
+		}}},
+		"panels": []interface{}{
+			panel(1, "timeseries", "CPU usage", "short", prefix+":cpu_usage:cores", "cores", 0, 0, 12),
+			panel(2, "timeseries", "Memory working set", "bytes", prefix+":memory_working_set:bytes", "working set", 12, 0, 12),
+			panel(3, "bargauge", "Quota used", "percentunit", prefix+":quota_used:ratio", "{{resource}}", 0, 8, 24),
+			panel(4, "timeseries", "Request error ratio", "percentunit", prefix+":request_errors:ratio_rate5m", "5xx", 0, 16, 12),
+			panel(5, "stat", fmt.Sprintf("Error budget left (%s%%, %s)", availabilityObjective(tenant), errorBudgetWindow), "percentunit", prefix+":error_budget_remaining:ratio", "", 12, 16, 12),
+		},
+	}
+	raw, err := json.MarshalIndent(dashboard, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	cm.Data = map[string]string{"tenant-" + tenant.Name + ".json": string(raw)}
+	return cm, nil
+}
+
+// reconcileMonitoring applies tenant's monitors in namespaces, its
+// PrometheusRule and its dashboard, or deletes them once the tenant no
+// longer has Spec.Observability.Metrics
+func (r *TenantReconciler) reconcileMonitoring(ctx context.Context, tenant *platformv1alpha1.Tenant, namespaces []string) error {
+	log := ctrl.LoggerFrom(ctx)
+	c := &r.Monitoring
+	enabled := metricsEnabled(tenant)
+
+	var desired []*unstructured.Unstructured
+	if enabled {
+		desired = c.monitoringObjects(tenant, namespaces)
+	}
+	for _, gvk := range []schema.GroupVersionKind{serviceMonitorGVK, podMonitorGVK, prometheusRuleGVK} {
+		installed, err := r.kindInstalled(gvk)
+		if err != nil {
+			return err
+		}
+		if !installed {
+			if enabled {
+				log.Info("CRD not installed, skipping tenant monitoring", "kind", gvk.Kind)
+			}
+			continue
+		}
+		scope := namespaces
+		if gvk == prometheusRuleGVK {
+			scope = []string{c.Namespace}
+		}
+		stale, err := staleObjects(ctx, r.Client, tenant, scope, gvk, desired)
+		if err != nil {
+			return err
+		}
+		for _, obj := range stale {
+			if err := r.deleteIfControlled(ctx, tenant, obj); err != nil {
+				return err
+			}
+		}
+		for _, obj := range ofKind(desired, gvk) {
+			if err := r.applyOrAdopt(ctx, tenant, obj); err != nil {
+				return err
+			}
+		}
+	}
+
+	dashboard, err := c.dashboardConfigMap(tenant)
+	if err != nil {
+		return err
+	}
+	if !enabled {
+		return r.deleteIfControlled(ctx, tenant, dashboard)
+	}
+	return r.applyOrAdopt(ctx, tenant, dashboard)
+}
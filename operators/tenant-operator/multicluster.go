@@ -0,0 +1,438 @@
+// Multi-cluster fan-out
+// With --multi-cluster, each tenant's namespaces, quota, LimitRange, default
+// NetworkPolicies and RBAC are also provisioned in the target clusters its
+// Spec.Placement selects. Targets are registered Clusters, each naming its
+// kubeconfig Secret, and the legacy labelled kubeconfig Secrets.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	platformv1alpha1 "github.com/xyz-company/tenant-operator/api/v1alpha1"
+)
+
+const (
+	// clusterKubeconfigKey is the Secret key holding the target kubeconfig
+	clusterKubeconfigKey = "kubeconfig"
+	// clusterTimeout bounds every request to a target cluster so an
+	// unreachable one can't stall the reconcile
+	clusterTimeout = 10 * time.Second
+	// clusterRetryInterval is how soon a tenant is requeued after a target
+	// cluster failed
+	clusterRetryInterval = time.Minute
+)
+
+// Labels placements can select registered Clusters by, set from their spec
+const (
+	clusterProviderLabel = "platform.xyz.com/provider"
+	clusterRegionLabel   = "platform.xyz.com/region"
+)
+
+// ClusterTargets discovers target clusters from registered Clusters and
+// kubeconfig Secrets and reconciles tenants into them
+type ClusterTargets struct {
+	// Reader reads the Clusters and kubeconfig Secrets. An uncached reader
+	// avoids watching every Secret in the cluster.
+	Reader client.Reader
+	// Namespace holds the kubeconfig Secrets of both kinds of targets
+	Namespace string
+	// Selector selects the legacy kubeconfig Secrets
+	Selector labels.Selector
+	Scheme   *runtime.Scheme
+	// Backend delivers tenants to the targets (--propagation-backend)
+	Backend PropagationBackend
+
+	mu      sync.Mutex
+	clients map[string]clusterClient
+}
+
+// clusterClient is a client built from a Secret, rebuilt when the Secret changes
+type clusterClient struct {
+	resourceVersion string
+	client          client.Client
+}
+
+// targetCluster is a target resolved from its Secret; err is set when no
+// client could be built, and unhealthy holds the last failed probe of a
+// registered Cluster
+type targetCluster struct {
+	name      string
+	labels    labels.Set
+	client    client.Client
+	err       error
+	unhealthy string
+}
+
+// Reconcile provisions tenant directly in every target cluster its
+// placement selects and returns the clusters' status, and the targets
+// reconciled. A failing cluster is reported in its ClusterStatus and
+// doesn't affect the others. Clusters that are no longer selected are
+// dropped from the status; what the tenant has there is left in place.
+func (t *ClusterTargets) Reconcile(ctx context.Context, tenant *platformv1alpha1.Tenant, limitRange LimitRangeDefaults) ([]platformv1alpha1.ClusterStatus, []targetCluster) {
+	log := ctrl.LoggerFrom(ctx)
+
+	targets, unknown, err := t.placed(ctx, tenant)
+	if err != nil {
+		log.Error(err, "Failed to list target clusters")
+		return tenant.Status.Clusters, nil
+	}
+
+	statuses := make([]platformv1alpha1.ClusterStatus, len(targets))
+	var wg sync.WaitGroup
+	for i, target := range targets {
+		wg.Add(1)
+		go func(i int, target targetCluster) {
+			defer wg.Done()
+
+			status := platformv1alpha1.ClusterStatus{Name: target.name, Ready: true, Reason: "Reconciled"}
+			if target.err != nil {
+				status = platformv1alpha1.ClusterStatus{Name: target.name, Reason: "InvalidKubeconfig", Message: target.err.Error()}
+			} else if target.unhealthy != "" {
+				// Don't wait out the timeouts of a cluster known to be down
+				status = platformv1alpha1.ClusterStatus{Name: target.name, Reason: "ClusterUnhealthy", Message: target.unhealthy}
+			} else if err := reconcileRemoteTenant(ctx, target.client, tenant, limitRange, failoverRole(tenant, target.name)); err != nil {
+				status = platformv1alpha1.ClusterStatus{Name: target.name, Reason: "ReconcileFailed", Message: err.Error()}
+			}
+			if !status.Ready {
+				log.Error(fmt.Errorf("%s", status.Message), "Failed to reconcile tenant in target cluster", "cluster", target.name, "reason", status.Reason)
+			}
+			statuses[i] = withTransitionTime(status, tenant.Status.Clusters)
+		}(i, target)
+	}
+	wg.Wait()
+
+	var reached []targetCluster
+	for i, target := range targets {
+		if statuses[i].Ready {
+			reached = append(reached, target)
+		}
+	}
+	return append(statuses, unknown...), reached
+}
+
+// placed returns the targets tenant's placement selects, and the status of
+// each cluster it names that isn't registered. It sets the tenant's
+// PlacementViolation condition.
+func (t *ClusterTargets) placed(ctx context.Context, tenant *platformv1alpha1.Tenant) ([]targetCluster, []platformv1alpha1.ClusterStatus, error) {
+	all, err := t.targets(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	targets, unknown, refused := placedTargets(tenant.Spec.Placement, all)
+	setPlacementCondition(tenant, len(targets), refused)
+
+	statuses := make([]platformv1alpha1.ClusterStatus, 0, len(unknown))
+	for _, name := range unknown {
+		status := platformv1alpha1.ClusterStatus{Name: name, Reason: "UnknownCluster", Message: "no Cluster or kubeconfig Secret of that name is registered"}
+		statuses = append(statuses, withTransitionTime(status, tenant.Status.Clusters))
+	}
+	return targets, statuses, nil
+}
+
+// validatePlacement rejects placements whose cluster selector doesn't parse
+// or whose failover pair is incomplete
+func validatePlacement(placement *platformv1alpha1.TenantPlacement) error {
+	if placement == nil {
+		return nil
+	}
+	if placement.ClusterSelector != nil {
+		if _, err := metav1.LabelSelectorAsSelector(placement.ClusterSelector); err != nil {
+			return fmt.Errorf("placement.clusterSelector is invalid: %w", err)
+		}
+	}
+	return validateFailover(placement.Failover)
+}
+
+// namedClusters returns the clusters placement names, in Clusters or as its
+// failover pair
+func namedClusters(placement *platformv1alpha1.TenantPlacement) []string {
+	names := placement.Clusters
+	if placement.Failover != nil {
+		names = append(slices.Clone(names), placement.Failover.Primary, placement.Failover.Standby)
+	}
+	return names
+}
+
+// placedTargets returns the targets placement selects, all of them when it
+// is nil, and the clusters it names matching no target. Named clusters
+// that violate its residency constraints are returned as refused instead of
+// selected.
+func placedTargets(placement *platformv1alpha1.TenantPlacement, targets []targetCluster) (selected []targetCluster, unknown, refused []string) {
+	if placement == nil {
+		return targets, nil, nil
+	}
+	names := namedClusters(placement)
+	// validatePlacement rejected selectors that don't parse
+	selector := labels.Nothing()
+	if placement.ClusterSelector != nil {
+		if parsed, err := metav1.LabelSelectorAsSelector(placement.ClusterSelector); err == nil {
+			selector = parsed
+		}
+	} else if len(names) == 0 && hasResidency(placement) {
+		selector = labels.Everything()
+	}
+
+	found := map[string]bool{}
+	for _, target := range targets {
+		found[target.name] = true
+		named := slices.Contains(names, target.name)
+		if !named && !selector.Matches(target.labels) {
+			continue
+		}
+		if violation := residencyViolation(placement, target.labels); violation != "" {
+			if named {
+				refused = append(refused, fmt.Sprintf("%s (%s)", target.name, violation))
+			}
+			continue
+		}
+		selected = append(selected, target)
+	}
+	for _, name := range names {
+		if !found[name] && !slices.Contains(unknown, name) {
+			unknown = append(unknown, name)
+		}
+	}
+	return selected, unknown, refused
+}
+
+// withTransitionTime keeps the previous transition time unless Ready changed
+func withTransitionTime(status platformv1alpha1.ClusterStatus, previous []platformv1alpha1.ClusterStatus) platformv1alpha1.ClusterStatus {
+	status.LastTransitionTime = metav1.Now()
+	for _, p := range previous {
+		if p.Name == status.Name && p.Ready == status.Ready {
+			status.LastTransitionTime = p.LastTransitionTime
+		}
+	}
+	return status
+}
+
+// targets returns a client for each registered Cluster and each labelled
+// kubeconfig Secret, reusing clients whose Secret hasn't changed. Secrets a
+// Cluster refers to, or named like one, only count as that Cluster.
+func (t *ClusterTargets) targets(ctx context.Context) ([]targetCluster, error) {
+	registered := &platformv1alpha1.ClusterList{}
+	if err := t.Reader.List(ctx, registered); err != nil {
+		return nil, err
+	}
+	secrets := &corev1.SecretList{}
+	if err := t.Reader.List(ctx, secrets, client.InNamespace(t.Namespace), client.MatchingLabelsSelector{Selector: t.Selector}); err != nil {
+		return nil, err
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	clients := make(map[string]clusterClient, len(registered.Items)+len(secrets.Items))
+	targets := make([]targetCluster, 0, len(registered.Items)+len(secrets.Items))
+	claimed := map[string]bool{}
+	for _, cluster := range registered.Items {
+		ref := cluster.Spec.KubeconfigSecretRef
+		claimed[cluster.Name] = true
+		claimed[ref.Name] = true
+
+		clusterLabels := labels.Set{}
+		for key, value := range cluster.Labels {
+			clusterLabels[key] = value
+		}
+		if cluster.Spec.Provider != "" {
+			clusterLabels[clusterProviderLabel] = cluster.Spec.Provider
+		}
+		if cluster.Spec.Region != "" {
+			clusterLabels[clusterRegionLabel] = cluster.Spec.Region
+		}
+		target := targetCluster{name: cluster.Name, labels: clusterLabels}
+		if !clusterReady(&cluster) {
+			target.unhealthy = meta.FindStatusCondition(cluster.Status.Conditions, ConditionReady).Message
+		}
+
+		secret, key, err := t.kubeconfigSecret(ctx, &cluster)
+		if err != nil {
+			target.err = err
+			targets = append(targets, target)
+			continue
+		}
+		target.client, target.err = t.cachedClient(clients, cluster.Name, secret, key)
+		targets = append(targets, target)
+	}
+	for i := range secrets.Items {
+		secret := &secrets.Items[i]
+		if claimed[secret.Name] {
+			continue
+		}
+		target := targetCluster{name: secret.Name, labels: labels.Set(secret.Labels)}
+		target.client, target.err = t.cachedClient(clients, secret.Name, secret, clusterKubeconfigKey)
+		targets = append(targets, target)
+	}
+	t.clients = clients
+
+	return targets, nil
+}
+
+// kubeconfigSecret reads the Secret holding cluster's kubeconfig and returns
+// it with the key of the kubeconfig
+func (t *ClusterTargets) kubeconfigSecret(ctx context.Context, cluster *platformv1alpha1.Cluster) (*corev1.Secret, string, error) {
+	ref := cluster.Spec.KubeconfigSecretRef
+	key := ref.Key
+	if key == "" {
+		key = clusterKubeconfigKey
+	}
+	secret := &corev1.Secret{}
+	if err := t.Reader.Get(ctx, types.NamespacedName{Namespace: t.Namespace, Name: ref.Name}, secret); err != nil {
+		return nil, key, fmt.Errorf("reading kubeconfig Secret %s/%s: %w", t.Namespace, ref.Name, err)
+	}
+	return secret, key, nil
+}
+
+// cachedClient returns the client of the target called name, built from key
+// of secret, and records it in clients. The previous client is reused while
+// the Secret and key are unchanged. t.mu must be held.
+func (t *ClusterTargets) cachedClient(clients map[string]clusterClient, name string, secret *corev1.Secret, key string) (client.Client, error) {
+	version := secret.ResourceVersion + "/" + key
+	cached, ok := t.clients[name]
+	if !ok || cached.resourceVersion != version {
+		c, err := t.newClient(secret.Data[key], key)
+		if err != nil {
+			return nil, err
+		}
+		cached = clusterClient{resourceVersion: version, client: c}
+	}
+	clients[name] = cached
+	return cached.client, nil
+}
+
+func (t *ClusterTargets) newClient(kubeconfig []byte, key string) (client.Client, error) {
+	config, err := clusterRESTConfig(kubeconfig, key)
+	if err != nil {
+		return nil, err
+	}
+	return client.New(config, client.Options{Scheme: t.Scheme})
+}
+
+// clusterRESTConfig returns the config of the kubeconfig stored under key,
+// with requests bounded by clusterTimeout
+func clusterRESTConfig(kubeconfig []byte, key string) (*rest.Config, error) {
+	if len(kubeconfig) == 0 {
+		return nil, fmt.Errorf("secret has no %q key", key)
+	}
+	config, err := clientcmd.RESTConfigFromKubeConfig(kubeconfig)
+	if err != nil {
+		return nil, err
+	}
+	config.Timeout = clusterTimeout
+	return config, nil
+}
+
+// reconcileRemoteTenant applies the tenant's remote objects in a target
+// cluster. The Tenant CR only lives in this cluster, so remote objects are
+// labelled for the tenant but carry no owner references. In a cluster of
+// the tenant's failover pair, role also scales the pre-staged workloads.
+func reconcileRemoteTenant(ctx context.Context, c client.Client, tenant *platformv1alpha1.Tenant, limitRange LimitRangeDefaults, role string) error {
+	objects, err := remoteObjects(tenant, limitRange, role)
+	if err != nil {
+		return err
+	}
+	for _, obj := range objects {
+		if err := applyRemote(ctx, c, tenant, obj); err != nil {
+			return err
+		}
+	}
+	if role == "" {
+		return nil
+	}
+	for _, namespace := range tenantNamespaces(tenant) {
+		if err := scaleFailoverWorkloads(ctx, c, namespace, role); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// remoteObjects returns the tenant's namespaces, quotas, LimitRanges,
+// default NetworkPolicies and RoleBindings for a target cluster. role, if
+// set, labels the namespaces with their failover role.
+func remoteObjects(tenant *platformv1alpha1.Tenant, limitRange LimitRangeDefaults, role string) ([]client.Object, error) {
+	var objects []client.Object
+	for _, namespace := range tenantNamespaces(tenant) {
+		quota, err := tenantQuota(tenant, namespace)
+		if err != nil {
+			return nil, err
+		}
+		limits, err := tenantLimitRange(tenant, namespace, limitRange)
+		if err != nil {
+			return nil, err
+		}
+		ns := tenantNamespace(tenant, namespace)
+		if role != "" {
+			ns.Labels[failoverRoleLabel] = role
+		}
+		objects = append(objects, ns, quota, limits, defaultDenyIngressPolicy(namespace))
+		if allowIntraNamespace(tenant) {
+			objects = append(objects, sameNamespacePolicy(namespace))
+		}
+		for _, binding := range tenantRoleBindings(tenant, namespace) {
+			if len(binding.Subjects) > 0 {
+				objects = append(objects, binding)
+			}
+		}
+	}
+	return objects, nil
+}
+
+// applyRemote server-side applies obj, so spec changes reach remote clusters
+// too. Existing objects labelled for a different tenant are reported rather
+// than silently shared.
+func applyRemote(ctx context.Context, c client.Client, tenant *platformv1alpha1.Tenant, obj client.Object) error {
+	objLabels := obj.GetLabels()
+	if objLabels == nil {
+		objLabels = map[string]string{}
+	}
+	objLabels[tenantLabel] = tenant.Name
+	obj.SetLabels(objLabels)
+
+	existing := obj.DeepCopyObject().(client.Object)
+	err := c.Get(ctx, client.ObjectKeyFromObject(obj), existing)
+	if err != nil && !errors.IsNotFound(err) {
+		return err
+	}
+	if err == nil {
+		if value, ok := existing.GetLabels()[tenantLabel]; ok && value != tenant.Name {
+			return fmt.Errorf("%s/%s belongs to tenant %q", existing.GetNamespace(), existing.GetName(), value)
+		}
+	}
+	return applyWith(ctx, c, obj)
+}
+
+// clusterToTenants maps a Cluster change to a reconcile of every Tenant, so
+// placements selecting it by labels pick it up, or let go of it, and a
+// recovered Cluster is caught up
+func (r *TenantReconciler) clusterToTenants(ctx context.Context, obj client.Object) []reconcile.Request {
+	tenants, err := listTenants(ctx, r.Client)
+	if err != nil {
+		ctrl.LoggerFrom(ctx).Error(err, "Failed to list Tenants for cluster", "cluster", obj.GetName())
+		return nil
+	}
+
+	requests := make([]reconcile.Request, 0, len(tenants))
+	for _, tenant := range tenants {
+		requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Name: tenant.Name}})
+	}
+	return requests
+}
@@ -0,0 +1,217 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http/httptest"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	platformv1alpha1 "github.com/xyz-company/tenant-operator/api/v1alpha1"
+)
+
+// kubeconfigSecret returns the labelled kubeconfig Secret of the target
+// cluster name, whose API server is at server
+func kubeconfigSecret(name, server string) *corev1.Secret {
+	kubeconfig := fmt.Sprintf(`apiVersion: v1
+kind: Config
+clusters:
+- name: %[1]s
+  cluster:
+    server: %[2]s
+contexts:
+- name: %[1]s
+  context:
+    cluster: %[1]s
+    user: operator
+current-context: %[1]s
+users:
+- name: operator
+  user:
+    token: secret
+`, name, server)
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "platform-system",
+			Name:      name,
+			Labels:    map[string]string{"platform.xyz.com/target-cluster": "true"},
+		},
+		Data: map[string][]byte{clusterKubeconfigKey: []byte(kubeconfig)},
+	}
+}
+
+// unreachableServer returns the URL of an API server that is already down
+func unreachableServer() string {
+	server := httptest.NewServer(nil)
+	server.Close()
+	return server.URL
+}
+
+// fakeClusterTargets returns the targets of the kubeconfig Secrets in c.
+// Targets named in fakes are served by the fake client given rather than
+// the API server of their kubeconfig.
+func fakeClusterTargets(t *testing.T, c client.Client, fakes map[string]client.Client) *ClusterTargets {
+	t.Helper()
+	targets := &ClusterTargets{
+		Reader:    c,
+		Namespace: "platform-system",
+		Selector:  labels.SelectorFromSet(labels.Set{"platform.xyz.com/target-cluster": "true"}),
+		Scheme:    scheme,
+		clients:   map[string]clusterClient{},
+	}
+	for name, fake := range fakes {
+		secret := &corev1.Secret{}
+		if err := c.Get(context.Background(), client.ObjectKey{Namespace: "platform-system", Name: name}, secret); err != nil {
+			t.Fatal(err)
+		}
+		targets.clients[name] = clusterClient{resourceVersion: secret.ResourceVersion + "/" + clusterKubeconfigKey, client: fake}
+	}
+	return targets
+}
+
+// wantRemoteTenant fails t unless the tenant search is provisioned in c
+func wantRemoteTenant(t *testing.T, c client.Client, cluster string) {
+	t.Helper()
+	ctx := context.Background()
+	for _, obj := range []client.Object{
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "search"}},
+		&corev1.ResourceQuota{ObjectMeta: metav1.ObjectMeta{Namespace: "search", Name: "tenant-quota"}},
+		&corev1.LimitRange{ObjectMeta: metav1.ObjectMeta{Namespace: "search", Name: "tenant-limits"}},
+	} {
+		if err := c.Get(ctx, client.ObjectKeyFromObject(obj), obj); err != nil {
+			t.Fatalf("%s: %v", cluster, err)
+		}
+		if got := obj.GetLabels()[tenantLabel]; got != "search" {
+			t.Fatalf("%s: %s/%s labelled for tenant %q, want search", cluster, obj.GetNamespace(), obj.GetName(), got)
+		}
+		if len(obj.GetOwnerReferences()) != 0 {
+			t.Fatalf("%s: %s/%s has owner references to the Tenant of another cluster", cluster, obj.GetNamespace(), obj.GetName())
+		}
+	}
+}
+
+// clusterStatus returns the status of cluster name in tenant
+func clusterStatus(t *testing.T, tenant *platformv1alpha1.Tenant, name string) platformv1alpha1.ClusterStatus {
+	t.Helper()
+	for _, status := range tenant.Status.Clusters {
+		if status.Name == name {
+			return status
+		}
+	}
+	t.Fatalf("no status of cluster %s in %+v", name, tenant.Status.Clusters)
+	return platformv1alpha1.ClusterStatus{}
+}
+
+func TestMultiClusterFanOut(t *testing.T) {
+	east, west := newReconcilerClient(), newReconcilerClient()
+	c := newReconcilerClient(
+		newTenant("search", "search-team"),
+		kubeconfigSecret("east", "https://east.example.com"),
+		kubeconfigSecret("west", "https://west.example.com"),
+	)
+	r := newTestReconciler(c)
+	r.Clusters = fakeClusterTargets(t, c, map[string]client.Client{"east": east, "west": west})
+	reconcileTenant(t, r, "search")
+
+	wantRemoteTenant(t, east, "east")
+	wantRemoteTenant(t, west, "west")
+
+	tenant := storedTenant(t, c, "search")
+	for _, name := range []string{"east", "west"} {
+		if status := clusterStatus(t, tenant, name); !status.Ready || status.Reason != "Reconciled" {
+			t.Errorf("cluster %s status = %+v, want ready", name, status)
+		}
+	}
+	if !meta.IsStatusConditionTrue(tenant.Status.Conditions, ConditionClustersReady) {
+		t.Errorf("conditions = %+v, want ClustersReady", tenant.Status.Conditions)
+	}
+}
+
+func TestMultiClusterUnreachableCluster(t *testing.T) {
+	east := newReconcilerClient()
+	c := newReconcilerClient(
+		newTenant("search", "search-team"),
+		kubeconfigSecret("east", "https://east.example.com"),
+		kubeconfigSecret("west", unreachableServer()),
+	)
+	r := newTestReconciler(c)
+	r.Clusters = fakeClusterTargets(t, c, map[string]client.Client{"east": east})
+
+	// The healthy cluster is still provisioned, and the tenant retried
+	if result := reconcileTenant(t, r, "search"); result.RequeueAfter != clusterRetryInterval {
+		t.Errorf("requeued after %s, want %s", result.RequeueAfter, clusterRetryInterval)
+	}
+	wantRemoteTenant(t, east, "east")
+
+	tenant := storedTenant(t, c, "search")
+	if status := clusterStatus(t, tenant, "east"); !status.Ready {
+		t.Errorf("cluster east status = %+v, want ready", status)
+	}
+	if status := clusterStatus(t, tenant, "west"); status.Ready || status.Reason != "ReconcileFailed" || status.Message == "" {
+		t.Errorf("cluster west status = %+v, want ReconcileFailed", status)
+	}
+	condition := meta.FindStatusCondition(tenant.Status.Conditions, ConditionClustersReady)
+	if condition == nil || condition.Status != metav1.ConditionFalse || condition.Message != "Not reconciled in west (ReconcileFailed)" {
+		t.Errorf("ClustersReady = %+v, want False naming west", condition)
+	}
+	if tenant.Status.Phase != "Ready" {
+		t.Errorf("phase = %s, want Ready despite the unreachable cluster", tenant.Status.Phase)
+	}
+}
+
+func TestMultiClusterInvalidKubeconfig(t *testing.T) {
+	secret := kubeconfigSecret("east", "")
+	secret.Data[clusterKubeconfigKey] = []byte("not a kubeconfig")
+	c := newReconcilerClient(newTenant("search", "search-team"), secret)
+	r := newTestReconciler(c)
+	r.Clusters = fakeClusterTargets(t, c, nil)
+	reconcileTenant(t, r, "search")
+
+	if status := clusterStatus(t, storedTenant(t, c, "search"), "east"); status.Ready || status.Reason != "InvalidKubeconfig" {
+		t.Fatalf("cluster east status = %+v, want InvalidKubeconfig", status)
+	}
+}
+
+func TestPlacedTargets(t *testing.T) {
+	targets := []targetCluster{
+		{name: "east", labels: labels.Set{clusterRegionLabel: "us-east-1"}},
+		{name: "west", labels: labels.Set{clusterRegionLabel: "us-west-2"}},
+	}
+	tests := []struct {
+		name        string
+		placement   *platformv1alpha1.TenantPlacement
+		want        []string
+		wantUnknown []string
+	}{
+		{name: "every cluster by default", want: []string{"east", "west"}},
+		{name: "named", placement: &platformv1alpha1.TenantPlacement{Clusters: []string{"west"}}, want: []string{"west"}},
+		{
+			name:      "selected by labels",
+			placement: &platformv1alpha1.TenantPlacement{ClusterSelector: &metav1.LabelSelector{MatchLabels: map[string]string{clusterRegionLabel: "us-east-1"}}},
+			want:      []string{"east"},
+		},
+		{
+			name:        "unknown name",
+			placement:   &platformv1alpha1.TenantPlacement{Clusters: []string{"east", "north"}},
+			want:        []string{"east"},
+			wantUnknown: []string{"north"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			selected, unknown, _ := placedTargets(tt.placement, targets)
+			var names []string
+			for _, target := range selected {
+				names = append(names, target.name)
+			}
+			if fmt.Sprint(names) != fmt.Sprint(tt.want) || fmt.Sprint(unknown) != fmt.Sprint(tt.wantUnknown) {
+				t.Fatalf("placedTargets() = %v, unknown %v, want %v, unknown %v", names, unknown, tt.want, tt.wantUnknown)
+			}
+		})
+	}
+}
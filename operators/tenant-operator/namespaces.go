@@ -0,0 +1,294 @@
+// Tenant namespaces
+// A Tenant gets the namespace named after it, or with Spec.Namespaces one
+// namespace per entry, named <tenant>-<entry>. Existing namespaces annotated
+// with platform.xyz.com/adopt: <tenant> are adopted on top. Every namespace
+// is provisioned the same way, sharing the quota if Spec.QuotaSplit says so.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/util/validation"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	platformv1alpha1 "github.com/xyz-company/tenant-operator/api/v1alpha1"
+)
+
+const (
+	// adoptAnnotation on an existing namespace names the Tenant to adopt it
+	adoptAnnotation = "platform.xyz.com/adopt"
+	// adoptedAnnotation marks namespaces the operator adopted, so they are
+	// released rather than deleted once no longer adopted
+	adoptedAnnotation = "platform.xyz.com/adopted"
+)
+
+// tenantNamespaces returns the names of tenant's namespaces, in spec order
+func tenantNamespaces(tenant *platformv1alpha1.Tenant) []string {
+	if len(tenant.Spec.Namespaces) == 0 {
+		return []string{tenant.Name}
+	}
+	namespaces := make([]string, 0, len(tenant.Spec.Namespaces))
+	for _, entry := range tenant.Spec.Namespaces {
+		namespaces = append(namespaces, tenant.Name+"-"+entry)
+	}
+	return namespaces
+}
+
+// knownNamespaces returns tenant's spec namespaces followed by those it
+// adopted, as last recorded in its status
+func knownNamespaces(tenant *platformv1alpha1.Tenant) []string {
+	namespaces := tenantNamespaces(tenant)
+	seen := make(map[string]bool, len(namespaces))
+	for _, namespace := range namespaces {
+		seen[namespace] = true
+	}
+	for _, status := range tenant.Status.Namespaces {
+		if status.Adopted && !seen[status.Name] {
+			seen[status.Name] = true
+			namespaces = append(namespaces, status.Name)
+		}
+	}
+	return namespaces
+}
+
+// adoptableNamespaces returns the existing namespaces outside namespaces
+// annotated for adoption by tenant, sorted. Namespaces that can't be
+// adopted are skipped with an AdoptionRefused event.
+func (r *TenantReconciler) adoptableNamespaces(ctx context.Context, tenant *platformv1alpha1.Tenant, namespaces []string) ([]string, error) {
+	list := &corev1.NamespaceList{}
+	if err := r.List(ctx, list); err != nil {
+		return nil, err
+	}
+	skip := make(map[string]bool, len(namespaces))
+	for _, namespace := range namespaces {
+		skip[namespace] = true
+	}
+
+	var adoptable []string
+	for _, ns := range list.Items {
+		if ns.Annotations[adoptAnnotation] != tenant.Name || skip[ns.Name] {
+			continue
+		}
+		var refused string
+		switch owner := ns.Labels[tenantLabel]; {
+		case owner != "" && owner != tenant.Name:
+			refused = fmt.Sprintf("it belongs to tenant %q", owner)
+		case r.Exclusion.Excluded(ns.Name):
+			refused = "it is excluded from management"
+		case len(tenant.Spec.QuotaSplit) > 0:
+			refused = "the tenant splits its quota with quotaSplit"
+		}
+		if refused != "" {
+			r.Recorder.Eventf(tenant, corev1.EventTypeWarning, "AdoptionRefused", "Not adopting namespace %s: %s", ns.Name, refused)
+			continue
+		}
+		adoptable = append(adoptable, ns.Name)
+	}
+	sort.Strings(adoptable)
+	return adoptable, nil
+}
+
+// partitionAdopted splits namespaces into those the operator adopted and
+// the others. Namespaces that no longer exist count as others.
+func (r *TenantReconciler) partitionAdopted(ctx context.Context, namespaces []string) (adopted, others []string, err error) {
+	for _, namespace := range namespaces {
+		ns := &corev1.Namespace{}
+		if err := r.Get(ctx, client.ObjectKey{Name: namespace}, ns); err != nil && !errors.IsNotFound(err) {
+			return nil, nil, err
+		}
+		if ns.Annotations[adoptedAnnotation] != "" {
+			adopted = append(adopted, namespace)
+		} else {
+			others = append(others, namespace)
+		}
+	}
+	return adopted, others, nil
+}
+
+// namespaceQuota returns the share of Spec.Quota, with defaults filled in,
+// given to one of tenant's namespaces. Counts are rounded down but never
+// below 1.
+func namespaceQuota(tenant *platformv1alpha1.Tenant, namespace string) (platformv1alpha1.TenantQuota, error) {
+	quota := effectiveQuota(tenant.Spec.Quota)
+	if len(tenant.Spec.QuotaSplit) == 0 {
+		return quota, nil
+	}
+	percent, ok := tenant.Spec.QuotaSplit[strings.TrimPrefix(namespace, tenant.Name+"-")]
+	if !ok {
+		return quota, fmt.Errorf("quotaSplit has no share for namespace %s", namespace)
+	}
+
+	cpu, err := parseQuotaQuantity("cpu", quota.CPU)
+	if err != nil {
+		return quota, err
+	}
+	memory, err := parseQuotaQuantity("memory", quota.Memory)
+	if err != nil {
+		return quota, err
+	}
+	quota.CPU = resource.NewMilliQuantity(cpu.MilliValue()*int64(percent)/100, resource.DecimalSI).String()
+	quota.Memory = resource.NewQuantity(memory.Value()*int64(percent)/100, resource.BinarySI).String()
+
+	share := func(n int) int {
+		if n <= 0 {
+			return n
+		}
+		if n = n * percent / 100; n < 1 {
+			return 1
+		}
+		return n
+	}
+	quota.Pods = share(quota.Pods)
+	quota.PVCs = share(quota.PVCs)
+	quota.Services = share(quota.Services)
+	quota.Secrets = share(quota.Secrets)
+	quota.ConfigMaps = share(quota.ConfigMaps)
+	quota.Jobs = share(quota.Jobs)
+	quota.LoadBalancers = share(quota.LoadBalancers)
+	if quota.ExtendedResources, err = shareExtendedResources(quota.ExtendedResources, percent); err != nil {
+		return quota, err
+	}
+	if quota.Scopes, err = shareQuotaScopes(quota.Scopes, percent, share); err != nil {
+		return quota, err
+	}
+	return quota, nil
+}
+
+// validateNamespaces rejects Spec.Namespaces entries that are duplicated or
+// don't make valid namespace names, and a Spec.QuotaSplit that doesn't give
+// every namespace a share or hands out more than the whole quota
+func validateNamespaces(tenant *platformv1alpha1.Tenant) error {
+	seen := make(map[string]bool, len(tenant.Spec.Namespaces))
+	for _, entry := range tenant.Spec.Namespaces {
+		if errs := validation.IsDNS1123Label(entry); len(errs) > 0 {
+			return fmt.Errorf("namespaces entry %q is invalid: %s", entry, strings.Join(errs, ", "))
+		}
+		if errs := validation.IsDNS1123Label(tenant.Name + "-" + entry); len(errs) > 0 {
+			return fmt.Errorf("namespace %s-%s is invalid: %s", tenant.Name, entry, strings.Join(errs, ", "))
+		}
+		if seen[entry] {
+			return fmt.Errorf("namespaces entry %q is listed twice", entry)
+		}
+		seen[entry] = true
+	}
+
+	if len(tenant.Spec.QuotaSplit) == 0 {
+		return nil
+	}
+	if len(tenant.Spec.Namespaces) == 0 {
+		return fmt.Errorf("quotaSplit requires namespaces")
+	}
+	total := 0
+	for entry, percent := range tenant.Spec.QuotaSplit {
+		if !seen[entry] {
+			return fmt.Errorf("quotaSplit entry %q is not in namespaces", entry)
+		}
+		if percent < 1 || percent > 100 {
+			return fmt.Errorf("quotaSplit %q must be between 1 and 100, got %d", entry, percent)
+		}
+		total += percent
+	}
+	for _, entry := range tenant.Spec.Namespaces {
+		if _, ok := tenant.Spec.QuotaSplit[entry]; !ok {
+			return fmt.Errorf("quotaSplit has no share for namespaces entry %q", entry)
+		}
+	}
+	if total > 100 {
+		return fmt.Errorf("quotaSplit shares add up to %d%%, more than the whole quota", total)
+	}
+	return nil
+}
+
+// labelledNamespaces returns the namespaces labelled for tenant, sorted
+func (r *TenantReconciler) labelledNamespaces(ctx context.Context, tenant *platformv1alpha1.Tenant) ([]string, error) {
+	list := &corev1.NamespaceList{}
+	if err := r.List(ctx, list, client.MatchingLabels{tenantLabel: tenant.Name}); err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(list.Items))
+	for _, ns := range list.Items {
+		names = append(names, ns.Name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// ownedNamespaces returns tenant's namespaces, both those in the spec and
+// any still labelled for it, for cleanup on deletion
+func (r *TenantReconciler) ownedNamespaces(ctx context.Context, tenant *platformv1alpha1.Tenant) ([]string, error) {
+	labelled, err := r.labelledNamespaces(ctx, tenant)
+	if err != nil {
+		return nil, err
+	}
+	namespaces := tenantNamespaces(tenant)
+	seen := make(map[string]bool, len(namespaces))
+	for _, namespace := range namespaces {
+		seen[namespace] = true
+	}
+	for _, namespace := range labelled {
+		if !seen[namespace] {
+			namespaces = append(namespaces, namespace)
+		}
+	}
+	return namespaces, nil
+}
+
+// removeStaleNamespaces handles the namespaces labelled for tenant that are
+// no longer in its spec or adopted. With the Delete policy they are deleted
+// like on Tenant deletion; with Orphan, while the Tenant has deletion
+// protection, or if they were adopted, they are released: left in place
+// without the tenant label and owner references.
+func (r *TenantReconciler) removeStaleNamespaces(ctx context.Context, tenant *platformv1alpha1.Tenant, namespaces []string) error {
+	labelled, err := r.labelledNamespaces(ctx, tenant)
+	if err != nil {
+		return err
+	}
+	keep := make(map[string]bool, len(namespaces))
+	for _, namespace := range namespaces {
+		keep[namespace] = true
+	}
+
+	log := ctrl.LoggerFrom(ctx)
+	release := deletionPolicy(&tenant.Spec) == platformv1alpha1.DeletionPolicyOrphan || deletionProtected(tenant)
+	for _, namespace := range labelled {
+		if keep[namespace] {
+			continue
+		}
+		ns := &corev1.Namespace{}
+		if err := r.Get(ctx, client.ObjectKey{Name: namespace}, ns); err != nil {
+			if errors.IsNotFound(err) {
+				continue
+			}
+			return err
+		}
+		if !release && ns.Annotations[adoptedAnnotation] == "" {
+			if err := r.deleteResources(ctx, tenant, namespace); err != nil {
+				return err
+			}
+			log.Info("Deleted namespace no longer in the spec", "namespace", namespace)
+			r.Recorder.Eventf(tenant, corev1.EventTypeNormal, "NamespaceRemoved", "Deleted namespace %s, no longer in spec.namespaces", namespace)
+			continue
+		}
+
+		if err := r.orphanResources(ctx, tenant, namespace); err != nil {
+			return err
+		}
+		patch := client.MergeFrom(ns.DeepCopy())
+		delete(ns.Labels, tenantLabel)
+		delete(ns.Annotations, adoptedAnnotation)
+		if err := r.Patch(ctx, ns, patch); err != nil {
+			return client.IgnoreNotFound(err)
+		}
+		log.Info("Released namespace no longer in the spec", "namespace", namespace)
+		r.Recorder.Eventf(tenant, corev1.EventTypeNormal, "NamespaceReleased", "Left namespace %s in place, no longer in spec.namespaces or adopted", namespace)
+	}
+	return nil
+}
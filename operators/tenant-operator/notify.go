@@ -0,0 +1,261 @@
+// Lifecycle notifications
+// With a notification channel configured, the operator tells a tenant's
+// contacts when the tenant is created, nears its quota, is suspended and
+// is deleted. Each channel delivers to the contact it is for:
+//
+//	Slack    --notify-slack-webhook, to the contacts.slack channel
+//	email    --notify-smtp-server, to the contacts.email address
+//	webhook  --notify-webhook, every notification as JSON, for relays
+//	         routing on the contacts themselves
+//
+// Messages are rendered from Go templates, built in or overridden per event
+// by the --notification-templates ConfigMap. A failed notification is
+// reported as a NotificationFailed event and not retried.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/smtp"
+	"os"
+	"strings"
+	"text/template"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	platformv1alpha1 "github.com/xyz-company/tenant-operator/api/v1alpha1"
+)
+
+// Lifecycle events contacts are notified of, also the keys of their
+// templates in --notification-templates
+const (
+	LifecycleCreated        = "created"
+	LifecycleQuotaNearLimit = "quotaNearLimit"
+	LifecycleSuspended      = "suspended"
+	LifecycleDeleted        = "deleted"
+)
+
+// defaultNotificationTemplates are the messages of events
+// --notification-templates doesn't override
+var defaultNotificationTemplates = map[string]string{
+	LifecycleCreated:        `Tenant {{.Tenant}} of {{.Owner}} was created with namespace {{join .Namespaces ", "}}.`,
+	LifecycleQuotaNearLimit: `Tenant {{.Tenant}} is near its quota: {{.Message}}.`,
+	LifecycleSuspended:      `Tenant {{.Tenant}} is suspended; its workloads are scaled to zero until it is resumed.`,
+	LifecycleDeleted:        `Tenant {{.Tenant}} was deleted.`,
+}
+
+// Notification is what contacts are told about a lifecycle event, and the
+// data its template is rendered with
+type Notification struct {
+	Event      string            `json:"event"`
+	Tenant     string            `json:"tenant"`
+	Owner      string            `json:"owner"`
+	CostCenter string            `json:"costCenter,omitempty"`
+	Contacts   map[string]string `json:"contacts"`
+	Namespaces []string          `json:"namespaces"`
+	// Message gives the event's details, such as the resources near their
+	// quota
+	Message string `json:"message,omitempty"`
+	// Text is the rendered message
+	Text string `json:"text"`
+}
+
+// NotificationChannel delivers notifications to one kind of contact
+type NotificationChannel interface {
+	// Name identifies the channel in logs and events
+	Name() string
+	// Send delivers n, doing nothing for tenants without a contact the
+	// channel delivers to
+	Send(ctx context.Context, n *Notification) error
+}
+
+// LifecycleNotifier renders lifecycle notifications and sends them
+// through every channel
+type LifecycleNotifier struct {
+	Channels []NotificationChannel
+	// Reader reads the Templates ConfigMap, uncached
+	Reader client.Reader
+	// Templates, when set, names the ConfigMap overriding the built-in
+	// templates, keyed by event
+	Templates types.NamespacedName
+}
+
+// Notify tells tenant's contacts about event, with message as its details
+func (n *LifecycleNotifier) Notify(ctx context.Context, tenant *platformv1alpha1.Tenant, event, message string) error {
+	text, err := n.template(ctx, event)
+	if err != nil {
+		return err
+	}
+	tmpl, err := template.New(event).Funcs(template.FuncMap{"join": strings.Join}).Parse(text)
+	if err != nil {
+		return fmt.Errorf("template %q: %w", event, err)
+	}
+	notification := &Notification{
+		Event:      event,
+		Tenant:     tenant.Name,
+		Owner:      tenant.Spec.Owner,
+		CostCenter: tenant.Spec.CostCenter,
+		Contacts:   tenant.Spec.Contacts,
+		Namespaces: knownNamespaces(tenant),
+		Message:    message,
+	}
+	var rendered bytes.Buffer
+	if err := tmpl.Execute(&rendered, notification); err != nil {
+		return fmt.Errorf("template %q: %w", event, err)
+	}
+	notification.Text = rendered.String()
+
+	var errs []error
+	for _, channel := range n.Channels {
+		if err := channel.Send(ctx, notification); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", channel.Name(), err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// template returns the template of event, from the Templates ConfigMap if
+// it has one
+func (n *LifecycleNotifier) template(ctx context.Context, event string) (string, error) {
+	if n.Templates.Name != "" {
+		cm := &corev1.ConfigMap{}
+		err := n.Reader.Get(ctx, n.Templates, cm)
+		if err != nil && !apierrors.IsNotFound(err) {
+			return "", err
+		}
+		if text := cm.Data[event]; text != "" {
+			return text, nil
+		}
+	}
+	return defaultNotificationTemplates[event], nil
+}
+
+// SlackChannel posts notifications to the tenant's Slack channel through
+// an incoming webhook
+type SlackChannel struct {
+	WebhookURL string
+	Client     *http.Client
+}
+
+// Name implements NotificationChannel
+func (c *SlackChannel) Name() string { return "slack" }
+
+// Send implements NotificationChannel
+func (c *SlackChannel) Send(ctx context.Context, n *Notification) error {
+	channel := n.Contacts[contactSlack]
+	if channel == "" {
+		return nil
+	}
+	return postJSON(ctx, c.Client, c.WebhookURL, map[string]string{"channel": channel, "text": n.Text})
+}
+
+// EmailChannel mails notifications to the tenant's email contact
+type EmailChannel struct {
+	// Server is the SMTP server as host:port
+	Server string
+	From   string
+	// Username, when set, authenticates to Server with the password in
+	// PasswordFile, re-read on every mail so it can be rotated in place
+	Username     string
+	PasswordFile string
+}
+
+// Name implements NotificationChannel
+func (c *EmailChannel) Name() string { return "email" }
+
+// Send implements NotificationChannel
+func (c *EmailChannel) Send(ctx context.Context, n *Notification) error {
+	to := n.Contacts[contactEmail]
+	if to == "" {
+		return nil
+	}
+	var auth smtp.Auth
+	if c.Username != "" {
+		password, err := os.ReadFile(c.PasswordFile)
+		if err != nil {
+			return err
+		}
+		host, _, _ := strings.Cut(c.Server, ":")
+		auth = smtp.PlainAuth("", c.Username, strings.TrimSpace(string(password)), host)
+	}
+	body := fmt.Sprintf("From: %s\r\nTo: %s\r\nSubject: Tenant %s %s\r\nContent-Type: text/plain; charset=utf-8\r\n\r\n%s\r\n",
+		c.From, to, n.Tenant, n.Event, n.Text)
+	// net/smtp takes no context; the server's own timeouts apply
+	return smtp.SendMail(c.Server, auth, c.From, []string{to}, []byte(body))
+}
+
+// WebhookChannel posts every notification as JSON to a webhook
+type WebhookChannel struct {
+	URL    string
+	Client *http.Client
+}
+
+// Name implements NotificationChannel
+func (c *WebhookChannel) Name() string { return "webhook" }
+
+// Send implements NotificationChannel
+func (c *WebhookChannel) Send(ctx context.Context, n *Notification) error {
+	return postJSON(ctx, c.Client, c.URL, n)
+}
+
+// postJSON posts body as JSON to url, failing on non-2xx responses
+func postJSON(ctx context.Context, c *http.Client, url string, body interface{}) error {
+	raw, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(raw))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := c.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned %s", resp.Status)
+	}
+	return nil
+}
+
+// notifyLifecycle notifies tenant's contacts of the lifecycle events the
+// reconcile that moved its status on from previous brought about
+func (r *TenantReconciler) notifyLifecycle(ctx context.Context, tenant *platformv1alpha1.Tenant, previous *platformv1alpha1.TenantStatus) {
+	if r.Lifecycle == nil {
+		return
+	}
+	// A status never written means the tenant was just created
+	if previous.Phase == "" {
+		r.notify(ctx, tenant, LifecycleCreated, "")
+	}
+	if meta.IsStatusConditionTrue(tenant.Status.Conditions, ConditionQuotaNearLimit) && !meta.IsStatusConditionTrue(previous.Conditions, ConditionQuotaNearLimit) {
+		near := meta.FindStatusCondition(tenant.Status.Conditions, ConditionQuotaNearLimit)
+		r.notify(ctx, tenant, LifecycleQuotaNearLimit, near.Message)
+	}
+	if tenant.Status.Phase == TenantPhaseSuspended && previous.Phase != TenantPhaseSuspended {
+		r.notify(ctx, tenant, LifecycleSuspended, "")
+	}
+}
+
+// notify sends the notification of event, reporting failures as events
+func (r *TenantReconciler) notify(ctx context.Context, tenant *platformv1alpha1.Tenant, event, message string) {
+	log := ctrl.LoggerFrom(ctx)
+	if err := r.Lifecycle.Notify(ctx, tenant, event, message); err != nil {
+		log.Error(err, "Failed to send lifecycle notification", "event", event)
+		r.Recorder.Eventf(tenant, corev1.EventTypeWarning, "NotificationFailed", "%s notification failed: %v", event, err)
+		return
+	}
+	log.Info("Sent lifecycle notification", "event", event)
+}
@@ -0,0 +1,170 @@
+// Default PodDisruptionBudgets
+// With Spec.Disruption.PodDisruptionBudgets, every Deployment in a tenant
+// namespace running more than one replica gets a PodDisruptionBudget unless
+// one of the tenant's own already covers it, so node drains during node
+// pool rotation evict its pods a few at a time.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/util/validation"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	platformv1alpha1 "github.com/xyz-company/tenant-operator/api/v1alpha1"
+)
+
+// defaultPDBLabel marks the PodDisruptionBudgets the operator creates,
+// telling them apart from the tenant's own
+const defaultPDBLabel = "platform.xyz.com/default-pdb"
+
+// defaultPDBSuffix is appended to the Deployment name to name its PDB
+const defaultPDBSuffix = "-default"
+
+// defaultMaxUnavailable is how many pods of a Deployment a drain may evict
+// at once when Spec.Disruption.MaxUnavailable is unset
+var defaultMaxUnavailable = intstr.FromInt(1)
+
+// defaultTopologyKeys are the keys pods are spread over when
+// Spec.Disruption.TopologyKeys is unset
+var defaultTopologyKeys = []string{corev1.LabelTopologyZone, corev1.LabelHostname}
+
+// validateDisruption rejects a maxUnavailable that isn't a positive count
+// or percentage, and invalid or repeated topology keys
+func validateDisruption(disruption *platformv1alpha1.TenantDisruptionPolicy) error {
+	if disruption == nil {
+		return nil
+	}
+	if m := disruption.MaxUnavailable; m != nil {
+		// Scaled against 100 replicas, so 0% and 0 are both caught
+		n, err := intstr.GetScaledValueFromIntOrPercent(m, 100, true)
+		if err != nil || n <= 0 || (m.Type == intstr.String && n > 100) {
+			return fmt.Errorf("disruption.maxUnavailable must be a positive count or a percentage up to 100%%, got %s", m.String())
+		}
+	}
+	seen := make(map[string]bool, len(disruption.TopologyKeys))
+	for _, key := range disruption.TopologyKeys {
+		if errs := validation.IsQualifiedName(key); len(errs) > 0 {
+			return fmt.Errorf("disruption.topologyKeys %q is invalid: %s", key, strings.Join(errs, ", "))
+		}
+		if seen[key] {
+			return fmt.Errorf("disruption.topologyKeys lists %q twice", key)
+		}
+		seen[key] = true
+	}
+	return nil
+}
+
+// reconcileDefaultPDBs applies a PodDisruptionBudget for every Deployment
+// in namespace that needs one, and deletes those whose Deployment is gone,
+// scaled down to one replica, or covered by a PDB of the tenant's own
+func (r *TenantReconciler) reconcileDefaultPDBs(ctx context.Context, tenant *platformv1alpha1.Tenant, namespace string) error {
+	if err := validateDisruption(tenant.Spec.Disruption); err != nil {
+		return err
+	}
+
+	pdbs := &policyv1.PodDisruptionBudgetList{}
+	if err := r.List(ctx, pdbs, client.InNamespace(namespace)); err != nil {
+		return err
+	}
+	wanted := map[string]bool{}
+	if disruption := tenant.Spec.Disruption; disruption != nil && disruption.PodDisruptionBudgets {
+		maxUnavailable := defaultMaxUnavailable
+		if disruption.MaxUnavailable != nil {
+			maxUnavailable = *disruption.MaxUnavailable
+		}
+		deployments := &appsv1.DeploymentList{}
+		if err := r.APIReader.List(ctx, deployments, client.InNamespace(namespace)); err != nil {
+			return err
+		}
+		for i := range deployments.Items {
+			deployment := &deployments.Items[i]
+			if !needsDefaultPDB(deployment, pdbs.Items) {
+				continue
+			}
+			pdb := defaultPDB(deployment, maxUnavailable)
+			if err := r.applyOrAdopt(ctx, tenant, pdb); err != nil {
+				return err
+			}
+			wanted[pdb.Name] = true
+		}
+	}
+
+	for i := range pdbs.Items {
+		pdb := &pdbs.Items[i]
+		if pdb.Labels[defaultPDBLabel] != "true" || pdb.Labels[tenantLabel] != tenant.Name || wanted[pdb.Name] {
+			continue
+		}
+		if err := r.Delete(ctx, pdb); err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+		ctrl.LoggerFrom(ctx).Info("Removed default PodDisruptionBudget", "namespace", namespace, "pdb", pdb.Name)
+	}
+	return nil
+}
+
+// needsDefaultPDB reports whether deployment runs more than one replica and
+// no PDB other than the operator's selects its pods. A PDB of the tenant's
+// own named like the default one also counts, so it is never adopted.
+func needsDefaultPDB(deployment *appsv1.Deployment, pdbs []policyv1.PodDisruptionBudget) bool {
+	if deployment.Spec.Replicas == nil || *deployment.Spec.Replicas <= 1 {
+		return false
+	}
+	podLabels := labels.Set(deployment.Spec.Template.Labels)
+	for i := range pdbs {
+		pdb := &pdbs[i]
+		if pdb.Labels[defaultPDBLabel] == "true" {
+			continue
+		}
+		if pdb.Name == deployment.Name+defaultPDBSuffix {
+			return false
+		}
+		selector, err := metav1.LabelSelectorAsSelector(pdb.Spec.Selector)
+		if err == nil && selector.Matches(podLabels) {
+			return false
+		}
+	}
+	return true
+}
+
+// defaultPDB returns the PodDisruptionBudget for deployment. Unhealthy pods
+// may always be evicted, so a crashlooping Deployment can't block a drain.
+func defaultPDB(deployment *appsv1.Deployment, maxUnavailable intstr.IntOrString) *policyv1.PodDisruptionBudget {
+	alwaysAllow := policyv1.AlwaysAllow
+	return &policyv1.PodDisruptionBudget{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      deployment.Name + defaultPDBSuffix,
+			Namespace: deployment.Namespace,
+			Labels:    map[string]string{defaultPDBLabel: "true"},
+		},
+		Spec: policyv1.PodDisruptionBudgetSpec{
+			MaxUnavailable:             &maxUnavailable,
+			Selector:                   deployment.Spec.Selector.DeepCopy(),
+			UnhealthyPodEvictionPolicy: &alwaysAllow,
+		},
+	}
+}
+
+// objectToTenant maps changes to objects in a tenant namespace, such as
+// Deployments being created, deleted or scaled, to a reconcile of the
+// tenant owning it
+func (r *TenantReconciler) objectToTenant(ctx context.Context, obj client.Object) []reconcile.Request {
+	ns := &corev1.Namespace{}
+	if err := r.Get(ctx, types.NamespacedName{Name: obj.GetNamespace()}, ns); err != nil {
+		return nil
+	}
+	return namespaceToTenant(ctx, ns)
+}
@@ -0,0 +1,266 @@
+// Pipeline ServiceAccounts
+// Spec.ServiceAccounts are provisioned for CI/CD: each is bound to the
+// restricted tenant-pipeline Role and gets a <name>-kubeconfig Secret holding
+// a short-lived token, which the operator replaces before it expires
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/client-go/tools/clientcmd"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	platformv1alpha1 "github.com/xyz-company/tenant-operator/api/v1alpha1"
+)
+
+const (
+	// pipelineRole is the Role pipeline ServiceAccounts are bound to
+	pipelineRole = "tenant-pipeline"
+
+	// pipelineLabel marks the ServiceAccounts provisioned from Spec.ServiceAccounts
+	pipelineLabel = "platform.xyz.com/pipeline-service-account"
+
+	// tokenExpiresAnnotation records when the token in a kubeconfig Secret expires
+	tokenExpiresAnnotation = "platform.xyz.com/token-expires-at"
+
+	defaultTokenTTL = 24 * time.Hour
+	minTokenTTL     = 10 * time.Minute
+)
+
+// reconcilePipelineServiceAccounts provisions Spec.ServiceAccounts in
+// namespace, rotates tokens past two thirds of their TTL and removes
+// ServiceAccounts no longer listed. It returns when the next token is due
+// for rotation, 0 if none is.
+func (r *TenantReconciler) reconcilePipelineServiceAccounts(ctx context.Context, tenant *platformv1alpha1.Tenant, namespace string) (time.Duration, error) {
+	if err := r.removeStalePipelineServiceAccounts(ctx, tenant, namespace); err != nil {
+		return 0, err
+	}
+
+	role := tenantPipelineRole(namespace)
+	if len(tenant.Spec.ServiceAccounts) == 0 {
+		if err := r.Delete(ctx, role); err != nil && !errors.IsNotFound(err) {
+			return 0, err
+		}
+		return 0, nil
+	}
+	if err := r.applyOrAdopt(ctx, tenant, role); err != nil {
+		return 0, err
+	}
+
+	var next time.Duration
+	for _, spec := range tenant.Spec.ServiceAccounts {
+		rotateIn, err := r.reconcilePipelineServiceAccount(ctx, tenant, namespace, spec)
+		if err != nil {
+			return 0, err
+		}
+		if next == 0 || rotateIn < next {
+			next = rotateIn
+		}
+	}
+	return next, nil
+}
+
+// reconcilePipelineServiceAccount applies one ServiceAccount and its
+// RoleBinding and refreshes its token if due, returning when it is next due
+func (r *TenantReconciler) reconcilePipelineServiceAccount(ctx context.Context, tenant *platformv1alpha1.Tenant, namespace string, spec platformv1alpha1.TenantServiceAccount) (time.Duration, error) {
+	sa := &corev1.ServiceAccount{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      spec.Name,
+			Namespace: namespace,
+			Labels:    map[string]string{pipelineLabel: "true"},
+		},
+	}
+	if err := r.applyOrAdopt(ctx, tenant, sa); err != nil {
+		return 0, err
+	}
+	if err := r.applyOrAdopt(ctx, tenant, pipelineRoleBinding(namespace, spec.Name)); err != nil {
+		return 0, err
+	}
+
+	// Secrets are read uncached, the operator doesn't watch them
+	ttl := tokenTTL(spec)
+	secret := &corev1.Secret{}
+	err := r.APIReader.Get(ctx, types.NamespacedName{Namespace: namespace, Name: pipelineSecretName(spec.Name)}, secret)
+	if err != nil && !errors.IsNotFound(err) {
+		return 0, err
+	}
+	if err == nil {
+		if ref := metav1.GetControllerOf(secret); ref == nil || ref.UID != tenant.UID {
+			return 0, fmt.Errorf("%s/%s is not managed by tenant %q, refusing to overwrite", secret.Namespace, secret.Name, tenant.Name)
+		}
+		if expires, err := time.Parse(time.RFC3339, secret.Annotations[tokenExpiresAnnotation]); err == nil {
+			if rotateIn := time.Until(expires) - ttl/3; rotateIn > 0 {
+				return rotateIn, nil
+			}
+		}
+	}
+
+	expirationSeconds := int64(ttl.Seconds())
+	request := &authenticationv1.TokenRequest{
+		Spec: authenticationv1.TokenRequestSpec{ExpirationSeconds: &expirationSeconds},
+	}
+	if err := r.SubResource("token").Create(ctx, sa, request); err != nil {
+		return 0, err
+	}
+
+	kubeconfig, err := pipelineKubeconfig(r.KubeconfigServer, r.KubeconfigCA, namespace, spec.Name, request.Status.Token)
+	if err != nil {
+		return 0, err
+	}
+	expires := request.Status.ExpirationTimestamp.Time
+	secret = &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        pipelineSecretName(spec.Name),
+			Namespace:   namespace,
+			Labels:      map[string]string{tenantLabel: tenant.Name},
+			Annotations: map[string]string{tokenExpiresAnnotation: expires.UTC().Format(time.RFC3339)},
+		},
+		Type: corev1.SecretTypeOpaque,
+		Data: map[string][]byte{
+			"token":      []byte(request.Status.Token),
+			"kubeconfig": kubeconfig,
+		},
+	}
+	if err := controllerutil.SetControllerReference(tenant, secret, r.Scheme); err != nil {
+		return 0, err
+	}
+	if err := r.apply(ctx, secret); err != nil {
+		return 0, err
+	}
+	ctrl.LoggerFrom(ctx).Info("Rotated pipeline token", "namespace", namespace, "serviceAccount", spec.Name, "expires", expires)
+	r.Recorder.Eventf(tenant, corev1.EventTypeNormal, "TokenRotated", "Issued a new token for ServiceAccount %s/%s, valid until %s", namespace, spec.Name, expires.UTC().Format(time.RFC3339))
+
+	return time.Until(expires) - ttl/3, nil
+}
+
+// removeStalePipelineServiceAccounts deletes the pipeline ServiceAccounts in
+// namespace no longer in Spec.ServiceAccounts, with their RoleBinding and Secret
+func (r *TenantReconciler) removeStalePipelineServiceAccounts(ctx context.Context, tenant *platformv1alpha1.Tenant, namespace string) error {
+	existing := &corev1.ServiceAccountList{}
+	if err := r.List(ctx, existing, client.InNamespace(namespace), client.MatchingLabels{pipelineLabel: "true"}); err != nil {
+		return err
+	}
+
+	listed := make(map[string]bool, len(tenant.Spec.ServiceAccounts))
+	for _, spec := range tenant.Spec.ServiceAccounts {
+		listed[spec.Name] = true
+	}
+	for i := range existing.Items {
+		sa := &existing.Items[i]
+		if listed[sa.Name] {
+			continue
+		}
+		objects := []client.Object{
+			&corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: pipelineSecretName(sa.Name), Namespace: namespace}},
+			pipelineRoleBinding(namespace, sa.Name),
+			sa,
+		}
+		for _, obj := range objects {
+			if err := r.Delete(ctx, obj); err != nil && !errors.IsNotFound(err) {
+				return err
+			}
+		}
+		ctrl.LoggerFrom(ctx).Info("Removed pipeline ServiceAccount", "namespace", namespace, "serviceAccount", sa.Name)
+	}
+	return nil
+}
+
+// validateServiceAccounts rejects pipeline ServiceAccounts with invalid or
+// duplicate names, names clashing with the namespace's default
+// ServiceAccount, and token TTLs below minTokenTTL
+func validateServiceAccounts(specs []platformv1alpha1.TenantServiceAccount) error {
+	seen := make(map[string]bool, len(specs))
+	for _, spec := range specs {
+		if errs := validation.IsDNS1123Subdomain(spec.Name); len(errs) > 0 {
+			return fmt.Errorf("serviceAccounts name %q is invalid: %s", spec.Name, strings.Join(errs, ", "))
+		}
+		if spec.Name == "default" {
+			return fmt.Errorf("serviceAccounts name %q is reserved", spec.Name)
+		}
+		if seen[spec.Name] {
+			return fmt.Errorf("serviceAccounts name %q is listed twice", spec.Name)
+		}
+		seen[spec.Name] = true
+		if spec.TokenTTL != nil && spec.TokenTTL.Duration != 0 && spec.TokenTTL.Duration < minTokenTTL {
+			return fmt.Errorf("serviceAccounts %q tokenTTL must be at least %s, got %s", spec.Name, minTokenTTL, spec.TokenTTL.Duration)
+		}
+	}
+	return nil
+}
+
+// tokenTTL returns the effective TokenTTL of spec
+func tokenTTL(spec platformv1alpha1.TenantServiceAccount) time.Duration {
+	if spec.TokenTTL == nil || spec.TokenTTL.Duration == 0 {
+		return defaultTokenTTL
+	}
+	return spec.TokenTTL.Duration
+}
+
+// pipelineSecretName returns the name of a pipeline ServiceAccount's kubeconfig Secret
+func pipelineSecretName(serviceAccount string) string {
+	return serviceAccount + "-kubeconfig"
+}
+
+// tenantPipelineRole returns the Role pipelines deploy with: workloads,
+// Services, ConfigMaps and Ingresses, but no Secrets, RBAC or platform
+// guardrails
+func tenantPipelineRole(namespace string) *rbacv1.Role {
+	write := []string{"get", "list", "watch", "create", "update", "patch", "delete"}
+	return &rbacv1.Role{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      pipelineRole,
+			Namespace: namespace,
+		},
+		Rules: []rbacv1.PolicyRule{
+			{APIGroups: []string{""}, Resources: []string{"configmaps", "services"}, Verbs: write},
+			{APIGroups: []string{""}, Resources: []string{"pods", "pods/log", "events"}, Verbs: []string{"get", "list", "watch"}},
+			{APIGroups: []string{"apps"}, Resources: []string{"deployments", "statefulsets", "replicasets"}, Verbs: write},
+			{APIGroups: []string{"batch"}, Resources: []string{"jobs", "cronjobs"}, Verbs: write},
+			{APIGroups: []string{"autoscaling"}, Resources: []string{"horizontalpodautoscalers"}, Verbs: write},
+			{APIGroups: []string{"networking.k8s.io"}, Resources: []string{"ingresses"}, Verbs: write},
+		},
+	}
+}
+
+// pipelineRoleBinding binds a pipeline ServiceAccount to the pipeline Role
+func pipelineRoleBinding(namespace, serviceAccount string) *rbacv1.RoleBinding {
+	return &rbacv1.RoleBinding{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      serviceAccount + "-pipeline",
+			Namespace: namespace,
+		},
+		Subjects: []rbacv1.Subject{
+			{Kind: rbacv1.ServiceAccountKind, Name: serviceAccount, Namespace: namespace},
+		},
+		RoleRef: rbacv1.RoleRef{
+			Kind:     "Role",
+			Name:     pipelineRole,
+			APIGroup: rbacv1.GroupName,
+		},
+	}
+}
+
+// pipelineKubeconfig returns a kubeconfig authenticating with token against
+// server, defaulting to namespace
+func pipelineKubeconfig(server string, ca []byte, namespace, user, token string) ([]byte, error) {
+	config := clientcmdapi.NewConfig()
+	config.Clusters[namespace] = &clientcmdapi.Cluster{Server: server, CertificateAuthorityData: ca}
+	config.AuthInfos[user] = &clientcmdapi.AuthInfo{Token: token}
+	config.Contexts[namespace] = &clientcmdapi.Context{Cluster: namespace, AuthInfo: user, Namespace: namespace}
+	config.CurrentContext = namespace
+	return clientcmd.Write(*config)
+}
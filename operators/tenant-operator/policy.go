@@ -0,0 +1,402 @@
+// Policy constraints
+// --policy-engine gives every tenant guardrails enforced by a policy engine.
+// With gatekeeper it gets a Gatekeeper constraint, tenant-<name>, of each of
+// the gatekeeper-library templates below, matching only its namespaces:
+//
+//	K8sRequiredLabels          Deployments, StatefulSets and DaemonSets
+//	                           carry the required labels
+//	K8sContainerLimits         containers set cpu and memory limits, none
+//	                           above the tenant's whole quota
+//	K8sPSPHostNetworkingPorts  pods don't use the host network or host ports
+//
+// The operator installs none of the templates; a constraint whose template
+// is missing is skipped. With kyverno each tenant namespace gets a Kyverno
+// Policy, tenant-guardrails, of these rules instead:
+//
+//	require-labels         Deployments, StatefulSets and DaemonSets carry
+//	                       the required labels
+//	disallow-capabilities  containers add no capabilities beyond
+//	                       Spec.Policy.AllowedCapabilities
+//	verify-images          images from the tenant's allowed registries are
+//	                       signed with --image-verification-key
+//
+// The required labels are --policy-required-labels and
+// Spec.Policy.RequiredLabels. The violations the engine's audit finds are
+// reported in Status.Policy, polled as constraints and reports aren't
+// watched.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	platformv1alpha1 "github.com/xyz-company/tenant-operator/api/v1alpha1"
+)
+
+// Policy engines of --policy-engine
+const (
+	PolicyEngineGatekeeper = "gatekeeper"
+	PolicyEngineKyverno    = "kyverno"
+)
+
+// Gatekeeper enforcement actions of --policy-enforcement
+const (
+	PolicyEnforcementDeny   = "deny"
+	PolicyEnforcementWarn   = "warn"
+	PolicyEnforcementDryRun = "dryrun"
+)
+
+var (
+	requiredLabelsGVK  = schema.GroupVersionKind{Group: "constraints.gatekeeper.sh", Version: "v1beta1", Kind: "K8sRequiredLabels"}
+	containerLimitsGVK = schema.GroupVersionKind{Group: "constraints.gatekeeper.sh", Version: "v1beta1", Kind: "K8sContainerLimits"}
+	hostNetworkingGVK  = schema.GroupVersionKind{Group: "constraints.gatekeeper.sh", Version: "v1beta1", Kind: "K8sPSPHostNetworkingPorts"}
+	kyvernoPolicyGVK   = schema.GroupVersionKind{Group: "kyverno.io", Version: "v1", Kind: "Policy"}
+	policyReportGVK    = schema.GroupVersionKind{Group: "wgpolicyk8s.io", Version: "v1alpha2", Kind: "PolicyReport"}
+)
+
+// tenantGuardrailsPolicy names the Kyverno Policy in each tenant namespace
+const tenantGuardrailsPolicy = "tenant-guardrails"
+
+// baselineCapabilities are the capabilities the Pod Security baseline
+// standard lets containers add, allowed to tenants without
+// Spec.Policy.AllowedCapabilities
+var baselineCapabilities = []string{
+	"AUDIT_WRITE", "CHOWN", "DAC_OVERRIDE", "FOWNER", "FSETID", "KILL", "MKNOD",
+	"NET_BIND_SERVICE", "SETFCAP", "SETGID", "SETPCAP", "SETUID", "SYS_CHROOT",
+}
+
+// capabilityName matches a Linux capability as Kubernetes spells it
+var capabilityName = regexp.MustCompile(`^[A-Z][A-Z_]*$`)
+
+// policyStatusInterval is how often the violations of a tenant's
+// constraints are looked up
+const policyStatusInterval = 10 * time.Minute
+
+// PolicyConfig configures the policy constraints of every tenant
+type PolicyConfig struct {
+	// Engine is empty, gatekeeper or kyverno
+	Engine string
+	// Enforcement is deny, warn or dryrun: the constraints'
+	// enforcementAction, or for Kyverno Enforce for deny and Audit
+	// otherwise
+	Enforcement string
+	// RequiredLabels are the labels every tenant's workloads must carry
+	RequiredLabels []string
+	// ImageKey is the PEM cosign public key the Kyverno Policies verify
+	// images against (--image-verification-key). Empty verifies none.
+	ImageKey string
+}
+
+// validatePolicy rejects required labels that aren't label keys and
+// capabilities that aren't capability names
+func validatePolicy(policy *platformv1alpha1.TenantPolicy) error {
+	if policy == nil {
+		return nil
+	}
+	for _, key := range policy.RequiredLabels {
+		if errs := validation.IsQualifiedName(key); len(errs) > 0 {
+			return fmt.Errorf("policy.requiredLabels: %q is not a label key: %s", key, strings.Join(errs, "; "))
+		}
+	}
+	for _, capability := range policy.AllowedCapabilities {
+		if !capabilityName.MatchString(capability) {
+			return fmt.Errorf("policy.allowedCapabilities: %q is not a capability such as NET_ADMIN", capability)
+		}
+	}
+	return nil
+}
+
+// requiredLabels returns the labels tenant's workloads must carry
+func (c *PolicyConfig) requiredLabels(tenant *platformv1alpha1.Tenant) []string {
+	labels := append([]string(nil), c.RequiredLabels...)
+	if tenant.Spec.Policy != nil {
+		for _, key := range tenant.Spec.Policy.RequiredLabels {
+			if !slices.Contains(labels, key) {
+				labels = append(labels, key)
+			}
+		}
+	}
+	return labels
+}
+
+// policyConstraints returns tenant's constraints for namespaces
+func (c *PolicyConfig) policyConstraints(tenant *platformv1alpha1.Tenant, namespaces []string) []*unstructured.Unstructured {
+	quota := effectiveQuota(tenant.Spec.Quota)
+	required := c.requiredLabels(tenant)
+	labels := make([]interface{}, 0, len(required))
+	for _, key := range required {
+		labels = append(labels, map[string]interface{}{"key": key})
+	}
+	pods := []interface{}{map[string]interface{}{"apiGroups": []interface{}{""}, "kinds": []interface{}{"Pod"}}}
+	workloads := []interface{}{map[string]interface{}{"apiGroups": []interface{}{"apps"}, "kinds": []interface{}{"Deployment", "StatefulSet", "DaemonSet"}}}
+
+	constraint := func(gvk schema.GroupVersionKind, kinds []interface{}, parameters map[string]interface{}) *unstructured.Unstructured {
+		scope := make([]interface{}, 0, len(namespaces))
+		for _, namespace := range namespaces {
+			scope = append(scope, namespace)
+		}
+		obj := &unstructured.Unstructured{Object: map[string]interface{}{
+			"spec": map[string]interface{}{
+				"enforcementAction": c.Enforcement,
+				"match":             map[string]interface{}{"kinds": kinds, "namespaces": scope},
+				"parameters":        parameters,
+			},
+		}}
+		obj.SetGroupVersionKind(gvk)
+		obj.SetName("tenant-" + tenant.Name)
+		return obj
+	}
+	return []*unstructured.Unstructured{
+		constraint(requiredLabelsGVK, workloads, map[string]interface{}{"labels": labels}),
+		constraint(containerLimitsGVK, pods, map[string]interface{}{"cpu": quota.CPU, "memory": quota.Memory}),
+		constraint(hostNetworkingGVK, pods, map[string]interface{}{"hostNetwork": false}),
+	}
+}
+
+// kyvernoPolicy returns tenant's Kyverno Policy for namespace
+func (c *PolicyConfig) kyvernoPolicy(tenant *platformv1alpha1.Tenant, namespace string) *unstructured.Unstructured {
+	action := "Audit"
+	if c.Enforcement == PolicyEnforcementDeny {
+		action = "Enforce"
+	}
+	kinds := func(kinds ...interface{}) map[string]interface{} {
+		return map[string]interface{}{"any": []interface{}{map[string]interface{}{"resources": map[string]interface{}{"kinds": kinds}}}}
+	}
+
+	var rules []interface{}
+	if required := c.requiredLabels(tenant); len(required) > 0 {
+		labels := map[string]interface{}{}
+		for _, key := range required {
+			labels[key] = "?*"
+		}
+		rules = append(rules, map[string]interface{}{
+			"name":  "require-labels",
+			"match": kinds("Deployment", "StatefulSet", "DaemonSet"),
+			"validate": map[string]interface{}{
+				"message": fmt.Sprintf("Tenant %s workloads must carry the labels %s.", tenant.Name, strings.Join(required, ", ")),
+				"pattern": map[string]interface{}{"metadata": map[string]interface{}{"labels": labels}},
+			},
+		})
+	}
+
+	allowed := baselineCapabilities
+	if tenant.Spec.Policy != nil && len(tenant.Spec.Policy.AllowedCapabilities) > 0 {
+		allowed = tenant.Spec.Policy.AllowedCapabilities
+	}
+	capabilities := make([]interface{}, 0, len(allowed))
+	for _, capability := range allowed {
+		capabilities = append(capabilities, capability)
+	}
+	rules = append(rules, map[string]interface{}{
+		"name":  "disallow-capabilities",
+		"match": kinds("Pod"),
+		"preconditions": map[string]interface{}{"all": []interface{}{map[string]interface{}{
+			"key": "{{ request.operation || 'BACKGROUND' }}", "operator": "NotEquals", "value": "DELETE",
+		}}},
+		"validate": map[string]interface{}{
+			"message": fmt.Sprintf("Tenant %s containers may only add the capabilities %s.", tenant.Name, strings.Join(allowed, ", ")),
+			"deny": map[string]interface{}{"conditions": map[string]interface{}{"all": []interface{}{map[string]interface{}{
+				"key":      "{{ request.object.spec.[ephemeralContainers, initContainers, containers][].securityContext.capabilities.add[] }}",
+				"operator": "AnyNotIn",
+				"value":    capabilities,
+			}}}},
+		},
+	})
+
+	if c.ImageKey != "" {
+		references := []interface{}{"*"}
+		if tenant.Spec.ImagePolicy != nil && len(tenant.Spec.ImagePolicy.AllowedRegistries) > 0 {
+			references = nil
+			for _, registry := range tenant.Spec.ImagePolicy.AllowedRegistries {
+				references = append(references, strings.TrimSuffix(registry, "/")+"/*")
+			}
+		}
+		rules = append(rules, map[string]interface{}{
+			"name":  "verify-images",
+			"match": kinds("Pod"),
+			"verifyImages": []interface{}{map[string]interface{}{
+				"imageReferences": references,
+				"attestors": []interface{}{map[string]interface{}{
+					"entries": []interface{}{map[string]interface{}{"keys": map[string]interface{}{"publicKeys": c.ImageKey}}},
+				}},
+			}},
+		})
+	}
+
+	obj := &unstructured.Unstructured{Object: map[string]interface{}{
+		"spec": map[string]interface{}{
+			"validationFailureAction": action,
+			"background":              true,
+			"rules":                   rules,
+		},
+	}}
+	obj.SetGroupVersionKind(kyvernoPolicyGVK)
+	obj.SetNamespace(namespace)
+	obj.SetName(tenantGuardrailsPolicy)
+	return obj
+}
+
+// reconcilePolicy applies tenant's constraints for namespaces and records
+// their violations. It returns when to look at the violations again.
+func (r *TenantReconciler) reconcilePolicy(ctx context.Context, tenant *platformv1alpha1.Tenant, namespaces []string) (time.Duration, error) {
+	var status *platformv1alpha1.PolicyStatus
+	var err error
+	switch {
+	// An empty match.namespaces would match every namespace
+	case r.Policy.Engine == "" || len(namespaces) == 0:
+		tenant.Status.Policy = nil
+		return 0, nil
+	case r.Policy.Engine == PolicyEngineKyverno:
+		status, err = r.reconcileKyverno(ctx, tenant, namespaces)
+	default:
+		status, err = r.reconcileGatekeeper(ctx, tenant, namespaces)
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	previous := tenant.Status.Policy
+	if status.Violations > 0 && (previous == nil || status.Violations > previous.Violations) {
+		var counts []string
+		for _, constraint := range status.Constraints {
+			switch {
+			case constraint.Violations == 0:
+			case constraint.Namespace != "":
+				counts = append(counts, fmt.Sprintf("%s %s/%s %d", constraint.Kind, constraint.Namespace, constraint.Name, constraint.Violations))
+			default:
+				counts = append(counts, fmt.Sprintf("%s %d", constraint.Kind, constraint.Violations))
+			}
+		}
+		r.Recorder.Eventf(tenant, corev1.EventTypeWarning, "PolicyViolations", "%d policy violations in the tenant namespaces: %s", status.Violations, strings.Join(counts, ", "))
+	}
+	tenant.Status.Policy = status
+	return policyStatusInterval, nil
+}
+
+// reconcileGatekeeper applies tenant's Gatekeeper constraints and returns
+// their violations
+func (r *TenantReconciler) reconcileGatekeeper(ctx context.Context, tenant *platformv1alpha1.Tenant, namespaces []string) (*platformv1alpha1.PolicyStatus, error) {
+	status := &platformv1alpha1.PolicyStatus{}
+	for _, constraint := range r.Policy.policyConstraints(tenant, namespaces) {
+		gvk := constraint.GroupVersionKind()
+		installed, err := r.kindInstalled(gvk)
+		if err != nil {
+			return nil, err
+		}
+		if !installed {
+			ctrl.LoggerFrom(ctx).Info("ConstraintTemplate not installed, skipping constraint", "kind", gvk.Kind)
+			continue
+		}
+		// The apply returns the constraint with the status of its last audit
+		if err := r.applyOrAdopt(ctx, tenant, constraint); err != nil {
+			return nil, err
+		}
+		violations, _, _ := unstructured.NestedInt64(constraint.Object, "status", "totalViolations")
+		constraintStatus := platformv1alpha1.PolicyConstraintStatus{Kind: gvk.Kind, Name: constraint.GetName(), Violations: int32(violations)}
+		if audited, _, _ := unstructured.NestedString(constraint.Object, "status", "auditTimestamp"); audited != "" {
+			if t, err := time.Parse(time.RFC3339, audited); err == nil {
+				constraintStatus.LastAudit = &metav1.Time{Time: t}
+			}
+		}
+		status.Constraints = append(status.Constraints, constraintStatus)
+		status.Violations += constraintStatus.Violations
+	}
+	return status, nil
+}
+
+// reconcileKyverno applies the Kyverno Policy of each of namespaces and
+// returns the failures their PolicyReports record
+func (r *TenantReconciler) reconcileKyverno(ctx context.Context, tenant *platformv1alpha1.Tenant, namespaces []string) (*platformv1alpha1.PolicyStatus, error) {
+	status := &platformv1alpha1.PolicyStatus{}
+	installed, err := r.kindInstalled(kyvernoPolicyGVK)
+	if err != nil {
+		return nil, err
+	}
+	if !installed {
+		ctrl.LoggerFrom(ctx).Info("Kyverno CRDs not installed, skipping tenant policies")
+		return status, nil
+	}
+	reports, err := r.kindInstalled(policyReportGVK)
+	if err != nil {
+		return nil, err
+	}
+
+	var desired []*unstructured.Unstructured
+	for _, namespace := range namespaces {
+		desired = append(desired, r.Policy.kyvernoPolicy(tenant, namespace))
+	}
+	stale, err := staleObjects(ctx, r.Client, tenant, namespaces, kyvernoPolicyGVK, desired)
+	if err != nil {
+		return nil, err
+	}
+	for _, obj := range stale {
+		if err := r.deleteIfControlled(ctx, tenant, obj); err != nil {
+			return nil, err
+		}
+	}
+	for _, policy := range desired {
+		if err := r.applyOrAdopt(ctx, tenant, policy); err != nil {
+			return nil, err
+		}
+		policyStatus := platformv1alpha1.PolicyConstraintStatus{Kind: kyvernoPolicyGVK.Kind, Namespace: policy.GetNamespace(), Name: policy.GetName()}
+		if reports {
+			if err := r.countPolicyFailures(ctx, &policyStatus); err != nil {
+				return nil, err
+			}
+		}
+		status.Constraints = append(status.Constraints, policyStatus)
+		status.Violations += policyStatus.Violations
+	}
+	return status, nil
+}
+
+// countPolicyFailures counts the failed results of the Kyverno Policy of
+// policy in the PolicyReports of its namespace, and records when the
+// latest was reported. Reports aren't cached, so they are listed from the
+// API server.
+func (r *TenantReconciler) countPolicyFailures(ctx context.Context, policy *platformv1alpha1.PolicyConstraintStatus) error {
+	reports := &unstructured.UnstructuredList{}
+	reports.SetGroupVersionKind(policyReportGVK.GroupVersion().WithKind(policyReportGVK.Kind + "List"))
+	if err := r.APIReader.List(ctx, reports, client.InNamespace(policy.Namespace)); err != nil {
+		return err
+	}
+	var latest int64
+	for _, report := range reports.Items {
+		results, _, _ := unstructured.NestedSlice(report.Object, "results")
+		for _, result := range results {
+			fields, ok := result.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			// Kyverno names namespaced policies with or without their
+			// namespace, depending on its version
+			name, _ := fields["policy"].(string)
+			if name != policy.Name && name != policy.Namespace+"/"+policy.Name {
+				continue
+			}
+			if seconds, _, _ := unstructured.NestedInt64(fields, "timestamp", "seconds"); seconds > latest {
+				latest = seconds
+			}
+			if fields["result"] == "fail" {
+				policy.Violations++
+			}
+		}
+	}
+	if latest > 0 {
+		policy.LastAudit = &metav1.Time{Time: time.Unix(latest, 0)}
+	}
+	return nil
+}
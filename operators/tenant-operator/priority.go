@@ -0,0 +1,258 @@
+// Tenant priority classes
+// Spec.PriorityClasses lists the PriorityClasses a tenant's pods may use:
+// platform classes by name, or classes of its own, named <tenant>-<name>,
+// that the operator creates. Each class can be capped with a ResourceQuota
+// scoped to it, and pods asking for any unlisted class are refused, so no
+// tenant can preempt the others by picking a high priority.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	schedulingv1 "k8s.io/api/scheduling/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	platformv1alpha1 "github.com/xyz-company/tenant-operator/api/v1alpha1"
+)
+
+// priorityQuotaLabel marks the ResourceQuotas selecting pods by priority
+// class, so those dropped from the spec can be found and deleted
+const priorityQuotaLabel = "platform.xyz.com/priority-quota"
+
+// deniedPriorityQuotaName is the ResourceQuota refusing pods of unlisted
+// priority classes
+const deniedPriorityQuotaName = "tenant-quota-priority-denied"
+
+// priorityClassName returns the PriorityClass pods of tenant use for class:
+// the tenant's own class when it sets a value, the platform one otherwise
+func priorityClassName(tenant *platformv1alpha1.Tenant, class platformv1alpha1.TenantPriorityClass) string {
+	if class.Value != nil {
+		return tenant.Name + "-" + class.Name
+	}
+	return class.Name
+}
+
+// validatePriorityClasses rejects Spec.PriorityClasses entries that are
+// listed twice, don't make valid PriorityClass names or have invalid caps
+func validatePriorityClasses(tenant *platformv1alpha1.Tenant) error {
+	seen := make(map[string]bool, len(tenant.Spec.PriorityClasses))
+	for _, class := range tenant.Spec.PriorityClasses {
+		name := priorityClassName(tenant, class)
+		if errs := validation.IsDNS1123Subdomain(name); len(errs) > 0 {
+			return fmt.Errorf("priorityClasses %q is invalid: %s", name, strings.Join(errs, ", "))
+		}
+		if class.Value != nil && strings.HasPrefix(class.Name, "system-") {
+			return fmt.Errorf("priorityClasses %q: the system- prefix is reserved", class.Name)
+		}
+		if seen[name] {
+			return fmt.Errorf("priorityClasses lists %q twice", name)
+		}
+		seen[name] = true
+		if class.Pods < 0 {
+			return fmt.Errorf("priorityClasses %q pods must not be negative, got %d", class.Name, class.Pods)
+		}
+		for _, q := range []struct{ field, value string }{{"cpu", class.CPU}, {"memory", class.Memory}} {
+			if q.value == "" {
+				continue
+			}
+			if _, err := parseQuotaQuantity("priorityClasses."+class.Name+"."+q.field, q.value); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// tenantPriorityClass returns the PriorityClass the operator creates for
+// class, which must set a value
+func tenantPriorityClass(tenant *platformv1alpha1.Tenant, class platformv1alpha1.TenantPriorityClass) *schedulingv1.PriorityClass {
+	return &schedulingv1.PriorityClass{
+		ObjectMeta: metav1.ObjectMeta{Name: priorityClassName(tenant, class)},
+		Value:      *class.Value,
+		Description: fmt.Sprintf("Priority class %s of tenant %s, managed by tenant-operator",
+			class.Name, tenant.Name),
+	}
+}
+
+// reconcilePriorityClasses applies the tenant's own PriorityClasses and
+// deletes those no longer in the spec. They are cluster-scoped, so this
+// runs once per Tenant rather than per namespace.
+func (r *TenantReconciler) reconcilePriorityClasses(ctx context.Context, tenant *platformv1alpha1.Tenant) error {
+	wanted := map[string]bool{}
+	for _, class := range tenant.Spec.PriorityClasses {
+		if class.Value == nil {
+			continue
+		}
+		pc := tenantPriorityClass(tenant, class)
+		existing := &schedulingv1.PriorityClass{}
+		err := r.Get(ctx, client.ObjectKeyFromObject(pc), existing)
+		switch {
+		case err == nil && existing.Value != pc.Value:
+			// The value of a PriorityClass is immutable
+			if err := r.Delete(ctx, existing); err != nil && !errors.IsNotFound(err) {
+				return err
+			}
+			ctrl.LoggerFrom(ctx).Info("Recreating PriorityClass with a new value", "priorityClass", pc.Name, "value", pc.Value)
+		case err != nil && !errors.IsNotFound(err):
+			return err
+		}
+		if err := r.applyOrAdopt(ctx, tenant, pc); err != nil {
+			return err
+		}
+		wanted[pc.Name] = true
+	}
+
+	owned := &schedulingv1.PriorityClassList{}
+	if err := r.List(ctx, owned, client.MatchingLabels{tenantLabel: tenant.Name}); err != nil {
+		return err
+	}
+	for i := range owned.Items {
+		pc := &owned.Items[i]
+		if wanted[pc.Name] {
+			continue
+		}
+		if err := r.Delete(ctx, pc); err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+		ctrl.LoggerFrom(ctx).Info("Removed PriorityClass", "priorityClass", pc.Name)
+	}
+	return nil
+}
+
+// priorityQuotas returns the ResourceQuotas for namespace capping each
+// listed priority class, and the one refusing pods of any other class.
+// Without Spec.PriorityClasses pods may use any class and none are returned.
+func priorityQuotas(tenant *platformv1alpha1.Tenant, namespace string) ([]*corev1.ResourceQuota, error) {
+	if len(tenant.Spec.PriorityClasses) == 0 {
+		return nil, nil
+	}
+	quotas := make([]*corev1.ResourceQuota, 0, len(tenant.Spec.PriorityClasses)+1)
+	allowed := make([]string, 0, len(tenant.Spec.PriorityClasses))
+	for _, class := range tenant.Spec.PriorityClasses {
+		name := priorityClassName(tenant, class)
+		allowed = append(allowed, name)
+
+		hard := corev1.ResourceList{}
+		if class.CPU != "" {
+			cpu, err := parseQuotaQuantity("priorityClasses."+class.Name+".cpu", class.CPU)
+			if err != nil {
+				return nil, err
+			}
+			hard[corev1.ResourceRequestsCPU] = cpu
+			hard[corev1.ResourceLimitsCPU] = doubled(cpu)
+		}
+		if class.Memory != "" {
+			memory, err := parseQuotaQuantity("priorityClasses."+class.Name+".memory", class.Memory)
+			if err != nil {
+				return nil, err
+			}
+			hard[corev1.ResourceRequestsMemory] = memory
+			hard[corev1.ResourceLimitsMemory] = doubled(memory)
+		}
+		if class.Pods > 0 {
+			hard[corev1.ResourcePods] = *resource.NewQuantity(int64(class.Pods), resource.DecimalSI)
+		}
+		if len(hard) == 0 {
+			// Allowed but only bounded by the tenant quota
+			continue
+		}
+		quotas = append(quotas, priorityQuota(namespace, "tenant-quota-priority-"+name, hard,
+			corev1.ScopedResourceSelectorRequirement{
+				ScopeName: corev1.ResourceQuotaScopePriorityClass,
+				Operator:  corev1.ScopeSelectorOpIn,
+				Values:    []string{name},
+			}))
+	}
+
+	// Pods with a priority class outside the list match both expressions
+	// and can't be created; pods without one don't match Exists
+	quotas = append(quotas, priorityQuota(namespace, deniedPriorityQuotaName,
+		corev1.ResourceList{corev1.ResourcePods: resource.MustParse("0")},
+		corev1.ScopedResourceSelectorRequirement{
+			ScopeName: corev1.ResourceQuotaScopePriorityClass,
+			Operator:  corev1.ScopeSelectorOpExists,
+		},
+		corev1.ScopedResourceSelectorRequirement{
+			ScopeName: corev1.ResourceQuotaScopePriorityClass,
+			Operator:  corev1.ScopeSelectorOpNotIn,
+			Values:    allowed,
+		}))
+	return quotas, nil
+}
+
+// priorityQuota returns a ResourceQuota selecting pods by priority class
+func priorityQuota(namespace, name string, hard corev1.ResourceList, selector ...corev1.ScopedResourceSelectorRequirement) *corev1.ResourceQuota {
+	return &corev1.ResourceQuota{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+			Labels:    map[string]string{priorityQuotaLabel: "true"},
+		},
+		Spec: corev1.ResourceQuotaSpec{
+			Hard:          hard,
+			ScopeSelector: &corev1.ScopeSelector{MatchExpressions: selector},
+		},
+	}
+}
+
+// reconcilePriorityQuotas applies the priority ResourceQuotas of namespace
+// and deletes those no longer wanted
+func (r *TenantReconciler) reconcilePriorityQuotas(ctx context.Context, tenant *platformv1alpha1.Tenant, namespace string) error {
+	quotas, err := priorityQuotas(tenant, namespace)
+	if err != nil {
+		return err
+	}
+	wanted := make(map[string]bool, len(quotas))
+	for _, quota := range quotas {
+		if err := r.applyOrAdopt(ctx, tenant, quota); err != nil {
+			return err
+		}
+		wanted[quota.Name] = true
+	}
+
+	existing := &corev1.ResourceQuotaList{}
+	if err := r.List(ctx, existing, client.InNamespace(namespace), client.MatchingLabels{tenantLabel: tenant.Name}, client.HasLabels{priorityQuotaLabel}); err != nil {
+		return err
+	}
+	for i := range existing.Items {
+		quota := &existing.Items[i]
+		if wanted[quota.Name] {
+			continue
+		}
+		if err := r.Delete(ctx, quota); err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+		ctrl.LoggerFrom(ctx).Info("Removed priority ResourceQuota", "namespace", namespace, "quota", quota.Name)
+	}
+	return nil
+}
+
+// validatePriorityClassRefs rejects tenant PriorityClasses above
+// MaxTenantPriority and platform PriorityClasses that don't exist
+func (v *TenantValidator) validatePriorityClassRefs(ctx context.Context, tenant *platformv1alpha1.Tenant) error {
+	for _, class := range tenant.Spec.PriorityClasses {
+		if class.Value != nil {
+			if *class.Value > v.MaxTenantPriority {
+				return fmt.Errorf("priorityClasses %q value %d exceeds the maximum tenant priority of %d", class.Name, *class.Value, v.MaxTenantPriority)
+			}
+			continue
+		}
+		pc := &schedulingv1.PriorityClass{}
+		if err := v.Reader.Get(ctx, client.ObjectKey{Name: class.Name}, pc); err != nil {
+			if errors.IsNotFound(err) {
+				return fmt.Errorf("priorityClasses %q names no PriorityClass; set a value to create a tenant one", class.Name)
+			}
+			return err
+		}
+	}
+	return nil
+}
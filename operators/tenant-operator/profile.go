@@ -0,0 +1,177 @@
+// Tenant profiles
+// A Tenant can name a TenantProfile in Spec.Profile instead of sizing its
+// quota, LimitRange and policies itself. The profile is resolved on every
+// reconcile, so editing it updates all of its tenants.
+
+package main
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	platformv1alpha1 "github.com/xyz-company/tenant-operator/api/v1alpha1"
+)
+
+// resolveProfile merges tenant's profile into tenant.Spec and returns the
+// LimitRange defaults the tenant gets. The spec is only changed in memory;
+// the reconciler never writes it back.
+func (r *TenantReconciler) resolveProfile(ctx context.Context, tenant *platformv1alpha1.Tenant) (LimitRangeDefaults, error) {
+	if tenant.Spec.Profile == "" {
+		return r.LimitRange, nil
+	}
+
+	profile := &platformv1alpha1.TenantProfile{}
+	if err := r.Get(ctx, types.NamespacedName{Name: tenant.Spec.Profile}, profile); err != nil {
+		if !errors.IsNotFound(err) {
+			return LimitRangeDefaults{}, err
+		}
+		err = fmt.Errorf("TenantProfile %q not found", tenant.Spec.Profile)
+		r.Recorder.Event(tenant, corev1.EventTypeWarning, "UnknownProfile", err.Error())
+		// Creating the profile triggers a new reconcile
+		return LimitRangeDefaults{}, reconcile.TerminalError(err)
+	}
+
+	defaults, err := profileLimitRange(r.LimitRange, profile.Spec.LimitRange)
+	if err != nil {
+		err = fmt.Errorf("TenantProfile %q: %w", profile.Name, err)
+		r.Recorder.Event(tenant, corev1.EventTypeWarning, "InvalidProfile", err.Error())
+		return LimitRangeDefaults{}, reconcile.TerminalError(err)
+	}
+	applyProfile(&tenant.Spec, &profile.Spec)
+	return defaults, nil
+}
+
+// applyProfile fills the fields spec leaves unset from profile. Boolean
+// policies are turned on if the profile turns them on.
+func applyProfile(spec *platformv1alpha1.TenantSpec, profile *platformv1alpha1.TenantProfileSpec) {
+	quota := &spec.Quota
+	if quota.CPU == "" {
+		quota.CPU = profile.Quota.CPU
+	}
+	if quota.Memory == "" {
+		quota.Memory = profile.Quota.Memory
+	}
+	if quota.Pods == 0 {
+		quota.Pods = profile.Quota.Pods
+	}
+	if quota.PVCs == 0 {
+		quota.PVCs = profile.Quota.PVCs
+	}
+	if quota.Services == 0 {
+		quota.Services = profile.Quota.Services
+	}
+	if quota.Secrets == 0 {
+		quota.Secrets = profile.Quota.Secrets
+	}
+	if quota.ConfigMaps == 0 {
+		quota.ConfigMaps = profile.Quota.ConfigMaps
+	}
+	if quota.Jobs == 0 {
+		quota.Jobs = profile.Quota.Jobs
+	}
+	if quota.LoadBalancers == 0 {
+		quota.LoadBalancers = profile.Quota.LoadBalancers
+	}
+	if quota.SoftThresholdPercent == 0 {
+		quota.SoftThresholdPercent = profile.Quota.SoftThresholdPercent
+	}
+	if len(quota.Scopes) == 0 {
+		quota.Scopes = append([]platformv1alpha1.TenantQuotaScope(nil), profile.Quota.Scopes...)
+	}
+	for name, value := range profile.Quota.ExtendedResources {
+		if _, ok := quota.ExtendedResources[name]; ok {
+			continue
+		}
+		if quota.ExtendedResources == nil {
+			quota.ExtendedResources = map[string]string{}
+		}
+		quota.ExtendedResources[name] = value
+	}
+
+	if spec.AllowIntraNamespace == nil && profile.AllowIntraNamespace != nil {
+		allow := *profile.AllowIntraNamespace
+		spec.AllowIntraNamespace = &allow
+	}
+	spec.NetworkIsolation = spec.NetworkIsolation || profile.NetworkIsolation
+	spec.MeshDefaultDeny = spec.MeshDefaultDeny || profile.MeshDefaultDeny
+	spec.RequireSeccomp = spec.RequireSeccomp || profile.RequireSeccomp
+	spec.DisableDefaultSATokenMount = spec.DisableDefaultSATokenMount || profile.DisableDefaultSATokenMount
+	if spec.MaxReplicasCeiling == 0 {
+		spec.MaxReplicasCeiling = profile.MaxReplicasCeiling
+	}
+	if spec.DefaultRequestRateLimit == "" {
+		spec.DefaultRequestRateLimit = profile.DefaultRequestRateLimit
+	}
+}
+
+// profileLimitRange returns defaults with the fields limits sets replaced
+func profileLimitRange(defaults LimitRangeDefaults, limits platformv1alpha1.TenantLimitRange) (LimitRangeDefaults, error) {
+	if p := limits.MaxContainerPercent; p != 0 {
+		if p < 1 || p > 100 {
+			return LimitRangeDefaults{}, fmt.Errorf("limitRange.maxContainerPercent must be between 1 and 100, got %d", p)
+		}
+		defaults.MaxContainerPercent = p
+	}
+
+	values := []struct {
+		field string
+		value string
+		into  *resource.Quantity
+	}{
+		{"defaultRequestCPU", limits.DefaultRequestCPU, &defaults.DefaultRequestCPU},
+		{"defaultRequestMemory", limits.DefaultRequestMemory, &defaults.DefaultRequestMemory},
+		{"defaultLimitCPU", limits.DefaultLimitCPU, &defaults.DefaultLimitCPU},
+		{"defaultLimitMemory", limits.DefaultLimitMemory, &defaults.DefaultLimitMemory},
+	}
+	for _, v := range values {
+		if v.value == "" {
+			continue
+		}
+		q, err := resource.ParseQuantity(v.value)
+		if err != nil || q.Sign() <= 0 {
+			return LimitRangeDefaults{}, fmt.Errorf("limitRange.%s must be a positive quantity, got %q", v.field, v.value)
+		}
+		*v.into = q
+	}
+	return defaults, nil
+}
+
+// withProfile merges tenant's profile into tenant.Spec for the webhooks. A
+// missing profile is left to the reconciler to report.
+func withProfile(ctx context.Context, reader client.Reader, tenant *platformv1alpha1.Tenant) error {
+	if tenant.Spec.Profile == "" {
+		return nil
+	}
+	profile := &platformv1alpha1.TenantProfile{}
+	if err := reader.Get(ctx, types.NamespacedName{Name: tenant.Spec.Profile}, profile); err != nil {
+		return client.IgnoreNotFound(err)
+	}
+	applyProfile(&tenant.Spec, &profile.Spec)
+	return nil
+}
+
+// profileToTenants maps a TenantProfile change to a reconcile of every
+// Tenant using it
+func (r *TenantReconciler) profileToTenants(ctx context.Context, obj client.Object) []reconcile.Request {
+	tenants, err := listTenants(ctx, r.Client)
+	if err != nil {
+		ctrl.LoggerFrom(ctx).Error(err, "Failed to list Tenants for profile", "profile", obj.GetName())
+		return nil
+	}
+
+	var requests []reconcile.Request
+	for _, tenant := range tenants {
+		if tenant.Spec.Profile == obj.GetName() {
+			requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Name: tenant.Name}})
+		}
+	}
+	return requests
+}
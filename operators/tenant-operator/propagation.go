@@ -0,0 +1,280 @@
+// Propagation backends
+// --propagation-backend chooses how tenants reach their target clusters.
+// direct, the default, applies the remote objects through each cluster's
+// kubeconfig. karmada and ocm leave the delivery to a multi-cluster control
+// plane this cluster is the hub of:
+//   - karmada: a ClusterPropagationPolicy per tenant propagates its
+//     namespaces and their quota, LimitRange, NetworkPolicies and
+//     RoleBindings, the resource templates in this cluster, to the selected
+//     member clusters
+//   - ocm: a ManifestWork per tenant in each selected managed cluster's
+//     namespace carries the same objects as a direct push
+//
+// Target clusters are still registered as Clusters or kubeconfig Secrets,
+// named like the member or managed clusters, for placement to select from.
+// The hub backends don't scale failover workloads; that needs direct access.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+
+	platformv1alpha1 "github.com/xyz-company/tenant-operator/api/v1alpha1"
+)
+
+// PropagationBackend selects how tenants are delivered to target clusters
+type PropagationBackend string
+
+const (
+	// PropagationDirect applies the objects in each target cluster
+	PropagationDirect PropagationBackend = "direct"
+	// PropagationKarmada writes Karmada ClusterPropagationPolicies
+	PropagationKarmada PropagationBackend = "karmada"
+	// PropagationOCM writes Open Cluster Management ManifestWorks
+	PropagationOCM PropagationBackend = "ocm"
+)
+
+var (
+	clusterPropagationPolicyGVK = schema.GroupVersionKind{Group: "policy.karmada.io", Version: "v1alpha1", Kind: "ClusterPropagationPolicy"}
+	manifestWorkGVK             = schema.GroupVersionKind{Group: "work.open-cluster-management.io", Version: "v1", Kind: "ManifestWork"}
+)
+
+// karmadaPropagatedKinds are the resource templates of a tenant Karmada
+// propagates
+var karmadaPropagatedKinds = []schema.GroupVersionKind{
+	{Version: "v1", Kind: "Namespace"},
+	{Version: "v1", Kind: "ResourceQuota"},
+	{Version: "v1", Kind: "LimitRange"},
+	{Group: "networking.k8s.io", Version: "v1", Kind: "NetworkPolicy"},
+	{Group: "rbac.authorization.k8s.io", Version: "v1", Kind: "RoleBinding"},
+}
+
+// propagationName names a tenant's ClusterPropagationPolicy and
+// ManifestWorks
+func propagationName(tenant *platformv1alpha1.Tenant) string {
+	return "tenant-" + tenant.Name
+}
+
+// reconcileClusters delivers tenant to the target clusters its placement
+// selects through the configured backend, and returns their status. Only
+// the direct backend reaches the clusters itself; it also returns those it
+// reconciled.
+func (r *TenantReconciler) reconcileClusters(ctx context.Context, tenant *platformv1alpha1.Tenant, limitRange LimitRangeDefaults) ([]platformv1alpha1.ClusterStatus, []targetCluster) {
+	if r.Clusters.Backend != PropagationKarmada && r.Clusters.Backend != PropagationOCM {
+		return r.Clusters.Reconcile(ctx, tenant, limitRange)
+	}
+
+	log := ctrl.LoggerFrom(ctx)
+	targets, unknown, err := r.Clusters.placed(ctx, tenant)
+	if err != nil {
+		log.Error(err, "Failed to list target clusters")
+		return tenant.Status.Clusters, nil
+	}
+	names := make([]string, 0, len(targets))
+	for _, target := range targets {
+		names = append(names, target.name)
+	}
+
+	var statuses []platformv1alpha1.ClusterStatus
+	if r.Clusters.Backend == PropagationKarmada {
+		statuses = r.propagateKarmada(ctx, tenant, names)
+	} else {
+		statuses = r.propagateOCM(ctx, tenant, names, limitRange)
+	}
+	for i := range statuses {
+		if !statuses[i].Ready {
+			log.Info("Tenant not propagated to target cluster yet", "cluster", statuses[i].Name, "reason", statuses[i].Reason, "message", statuses[i].Message)
+		}
+		statuses[i] = withTransitionTime(statuses[i], tenant.Status.Clusters)
+	}
+	return append(statuses, unknown...), nil
+}
+
+// failedStatuses reports err for every one of clusters
+func failedStatuses(clusters []string, reason string, err error) []platformv1alpha1.ClusterStatus {
+	statuses := make([]platformv1alpha1.ClusterStatus, 0, len(clusters))
+	for _, name := range clusters {
+		statuses = append(statuses, platformv1alpha1.ClusterStatus{Name: name, Reason: reason, Message: err.Error()})
+	}
+	return statuses
+}
+
+// propagateKarmada applies the tenant's ClusterPropagationPolicy, placing
+// its resource templates on clusters. Karmada removes them from member
+// clusters that drop out of the list.
+func (r *TenantReconciler) propagateKarmada(ctx context.Context, tenant *platformv1alpha1.Tenant, clusters []string) []platformv1alpha1.ClusterStatus {
+	installed, err := r.kindInstalled(clusterPropagationPolicyGVK)
+	if err == nil && !installed {
+		err = fmt.Errorf("the Karmada ClusterPropagationPolicy CRD is not installed")
+	}
+	if err != nil {
+		return failedStatuses(clusters, "PropagationFailed", err)
+	}
+
+	selectors := make([]interface{}, 0, len(karmadaPropagatedKinds))
+	for _, gvk := range karmadaPropagatedKinds {
+		selectors = append(selectors, map[string]interface{}{
+			"apiVersion": gvk.GroupVersion().String(),
+			"kind":       gvk.Kind,
+			"labelSelector": map[string]interface{}{
+				"matchLabels": map[string]interface{}{tenantLabel: tenant.Name},
+			},
+		})
+	}
+	clusterNames := make([]interface{}, 0, len(clusters))
+	for _, name := range clusters {
+		clusterNames = append(clusterNames, name)
+	}
+	policy := &unstructured.Unstructured{Object: map[string]interface{}{
+		"spec": map[string]interface{}{
+			"resourceSelectors": selectors,
+			"placement": map[string]interface{}{
+				"clusterAffinity": map[string]interface{}{"clusterNames": clusterNames},
+			},
+		},
+	}}
+	policy.SetGroupVersionKind(clusterPropagationPolicyGVK)
+	policy.SetName(propagationName(tenant))
+	if err := r.applyOrAdopt(ctx, tenant, policy); err != nil {
+		return failedStatuses(clusters, "PropagationFailed", err)
+	}
+
+	statuses := make([]platformv1alpha1.ClusterStatus, 0, len(clusters))
+	for _, name := range clusters {
+		statuses = append(statuses, platformv1alpha1.ClusterStatus{Name: name, Ready: true, Reason: "Propagated",
+			Message: "ClusterPropagationPolicy " + policy.GetName() + " places the tenant here"})
+	}
+	return statuses
+}
+
+// propagateOCM applies a ManifestWork with the tenant's remote objects in
+// the namespace of each of clusters, reporting them ready once the work
+// agent has applied it, and deletes the tenant's ManifestWorks in other
+// managed clusters
+func (r *TenantReconciler) propagateOCM(ctx context.Context, tenant *platformv1alpha1.Tenant, clusters []string, limitRange LimitRangeDefaults) []platformv1alpha1.ClusterStatus {
+	installed, err := r.kindInstalled(manifestWorkGVK)
+	if err == nil && !installed {
+		err = fmt.Errorf("the Open Cluster Management ManifestWork CRD is not installed")
+	}
+	if err != nil {
+		return failedStatuses(clusters, "PropagationFailed", err)
+	}
+
+	objects, err := remoteObjects(tenant, limitRange, "")
+	if err != nil {
+		return failedStatuses(clusters, "PropagationFailed", err)
+	}
+	manifests := make([]interface{}, 0, len(objects))
+	for _, obj := range objects {
+		manifest, err := r.manifest(tenant, obj)
+		if err != nil {
+			return failedStatuses(clusters, "PropagationFailed", err)
+		}
+		manifests = append(manifests, manifest)
+	}
+
+	statuses := make([]platformv1alpha1.ClusterStatus, 0, len(clusters))
+	for _, name := range clusters {
+		work := &unstructured.Unstructured{Object: map[string]interface{}{
+			"spec": map[string]interface{}{
+				"workload": map[string]interface{}{"manifests": manifests},
+			},
+		}}
+		work.SetGroupVersionKind(manifestWorkGVK)
+		work.SetNamespace(name)
+		work.SetName(propagationName(tenant))
+		if err := r.applyOrAdopt(ctx, tenant, work); err != nil {
+			statuses = append(statuses, platformv1alpha1.ClusterStatus{Name: name, Reason: "PropagationFailed", Message: err.Error()})
+			continue
+		}
+		statuses = append(statuses, manifestWorkStatus(name, work))
+	}
+
+	if err := r.deleteStaleManifestWorks(ctx, tenant, clusters); err != nil {
+		ctrl.LoggerFrom(ctx).Error(err, "Failed to delete ManifestWorks of deselected clusters")
+	}
+	return statuses
+}
+
+// manifest returns obj as a ManifestWork manifest: typed, and labelled for
+// the tenant without owner references, like a directly applied object
+func (r *TenantReconciler) manifest(tenant *platformv1alpha1.Tenant, obj client.Object) (map[string]interface{}, error) {
+	gvk, err := apiutil.GVKForObject(obj, r.Scheme)
+	if err != nil {
+		return nil, err
+	}
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+	if err != nil {
+		return nil, err
+	}
+	manifest := &unstructured.Unstructured{Object: content}
+	manifest.SetGroupVersionKind(gvk)
+	labels := manifest.GetLabels()
+	if labels == nil {
+		labels = map[string]string{}
+	}
+	labels[tenantLabel] = tenant.Name
+	manifest.SetLabels(labels)
+	// Not meaningful in a manifest, and rejected by some validators
+	unstructured.RemoveNestedField(manifest.Object, "metadata", "creationTimestamp")
+	unstructured.RemoveNestedField(manifest.Object, "status")
+	return manifest.Object, nil
+}
+
+// manifestWorkStatus reports the managed cluster of work ready once its
+// Applied condition is True
+func manifestWorkStatus(cluster string, work *unstructured.Unstructured) platformv1alpha1.ClusterStatus {
+	status := platformv1alpha1.ClusterStatus{Name: cluster, Reason: "Pending", Message: "ManifestWork not applied by the work agent yet"}
+	raw, _, _ := unstructured.NestedSlice(work.Object, "status", "conditions")
+	var conditions []metav1.Condition
+	for _, entry := range raw {
+		fields, _ := entry.(map[string]interface{})
+		var condition metav1.Condition
+		if runtime.DefaultUnstructuredConverter.FromUnstructured(fields, &condition) == nil {
+			conditions = append(conditions, condition)
+		}
+	}
+	applied := meta.FindStatusCondition(conditions, "Applied")
+	if applied == nil {
+		return status
+	}
+	if applied.Status != metav1.ConditionTrue {
+		status.Message = applied.Message
+		return status
+	}
+	return platformv1alpha1.ClusterStatus{Name: cluster, Ready: true, Reason: "Propagated", Message: "ManifestWork applied"}
+}
+
+// deleteStaleManifestWorks deletes the tenant's ManifestWorks in managed
+// clusters other than clusters. The work agent then removes their objects,
+// unlike a direct push, which leaves deselected clusters alone.
+func (r *TenantReconciler) deleteStaleManifestWorks(ctx context.Context, tenant *platformv1alpha1.Tenant, clusters []string) error {
+	works := &unstructured.UnstructuredList{}
+	works.SetGroupVersionKind(manifestWorkGVK.GroupVersion().WithKind(manifestWorkGVK.Kind + "List"))
+	if err := r.List(ctx, works, client.MatchingLabels{tenantLabel: tenant.Name}); err != nil {
+		return err
+	}
+	for i := range works.Items {
+		work := &works.Items[i]
+		if slices.Contains(clusters, work.GetNamespace()) || !metav1.IsControlledBy(work, tenant) {
+			continue
+		}
+		if err := client.IgnoreNotFound(r.Delete(ctx, work)); err != nil {
+			return err
+		}
+		r.Recorder.Eventf(tenant, corev1.EventTypeNormal, "ManifestWorkDeleted", "Deleted ManifestWork %s, the tenant is no longer placed in cluster %s", work.GetName(), work.GetNamespace())
+	}
+	return nil
+}
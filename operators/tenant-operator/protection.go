@@ -0,0 +1,45 @@
+// Tenant deletion protection
+// Tenants annotated platform.xyz.com/deletion-protection: "true" can't be
+// deleted until the annotation is removed. The validating webhook rejects the
+// DELETE and records who removes the annotation; the finalizer holds Tenants
+// deleted while the webhook is off.
+
+package main
+
+import (
+	"fmt"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	platformv1alpha1 "github.com/xyz-company/tenant-operator/api/v1alpha1"
+)
+
+// deletionProtectionAnnotation opts a Tenant into deletion protection
+const deletionProtectionAnnotation = "platform.xyz.com/deletion-protection"
+
+// deletionProtected reports whether obj has deletion protection on
+func deletionProtected(obj metav1.Object) bool {
+	return obj.GetAnnotations()[deletionProtectionAnnotation] == "true"
+}
+
+// validateDelete rejects deleting a protected Tenant
+func (v *TenantValidator) validateDelete(old *platformv1alpha1.Tenant) admission.Response {
+	if deletionProtected(old) {
+		return admission.Denied(fmt.Sprintf("tenant %q has deletion protection; remove the %s annotation first", old.Name, deletionProtectionAnnotation))
+	}
+	return admission.Allowed("")
+}
+
+// recordProtectionRemoved emits an event naming the user who removed
+// deletion protection from tenant. Dry runs record nothing.
+func (v *TenantValidator) recordProtectionRemoved(req admission.Request, old, tenant *platformv1alpha1.Tenant) {
+	if req.Operation != admissionv1.Update || v.Recorder == nil || (req.DryRun != nil && *req.DryRun) {
+		return
+	}
+	if deletionProtected(old) && !deletionProtected(tenant) {
+		v.Recorder.Eventf(tenant, corev1.EventTypeNormal, "DeletionProtectionRemoved", "Deletion protection removed by %s", req.UserInfo.Username)
+	}
+}
@@ -0,0 +1,167 @@
+// Image pull secret distribution
+// The registry credentials named by --image-pull-secrets are kept in the
+// operator namespace by the platform team. The operator copies them into
+// every tenant namespace and adds them to the namespace's default
+// ServiceAccount, so pods can pull from the platform registries without
+// each tenant managing credentials. Copies follow rotations of the source.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	platformv1alpha1 "github.com/xyz-company/tenant-operator/api/v1alpha1"
+)
+
+// pullSecretLabel marks the image pull secrets copied into tenant
+// namespaces, so those no longer distributed can be found and deleted
+const pullSecretLabel = "platform.xyz.com/image-pull-secret"
+
+// reconcileImagePullSecrets copies the distributed pull secrets into
+// namespace, deletes copies of secrets no longer distributed, and keeps the
+// default ServiceAccount's imagePullSecrets in line. Entries the tenant
+// added itself are left alone.
+func (r *TenantReconciler) reconcileImagePullSecrets(ctx context.Context, tenant *platformv1alpha1.Tenant, namespace string) error {
+	log := ctrl.LoggerFrom(ctx)
+
+	wanted := map[string]bool{}
+	for _, name := range r.ImagePullSecrets {
+		// The cache holds Secrets of the source namespace only
+		source := &corev1.Secret{}
+		err := r.Get(ctx, types.NamespacedName{Namespace: r.SecretNamespace, Name: name}, source)
+		if errors.IsNotFound(err) {
+			log.Info("Image pull secret to distribute not found", "secret", r.SecretNamespace+"/"+name)
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if err := r.applyPullSecret(ctx, tenant, namespace, source); err != nil {
+			return err
+		}
+		wanted[name] = true
+	}
+
+	// Copies are read uncached like the pipeline Secrets
+	copies := &corev1.SecretList{}
+	if err := r.APIReader.List(ctx, copies, client.InNamespace(namespace), client.MatchingLabels{tenantLabel: tenant.Name}, client.HasLabels{pullSecretLabel}); err != nil {
+		return err
+	}
+	stale := map[string]bool{}
+	for i := range copies.Items {
+		secret := &copies.Items[i]
+		if wanted[secret.Name] {
+			continue
+		}
+		if err := r.Delete(ctx, secret); err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+		stale[secret.Name] = true
+		log.Info("Removed image pull secret", "namespace", namespace, "secret", secret.Name)
+	}
+
+	return r.patchDefaultPullSecrets(ctx, namespace, wanted, stale)
+}
+
+// applyPullSecret writes a copy of source into namespace, refusing to
+// overwrite a Secret of the same name the tenant created itself
+func (r *TenantReconciler) applyPullSecret(ctx context.Context, tenant *platformv1alpha1.Tenant, namespace string, source *corev1.Secret) error {
+	existing := &corev1.Secret{}
+	err := r.APIReader.Get(ctx, types.NamespacedName{Namespace: namespace, Name: source.Name}, existing)
+	if err != nil && !errors.IsNotFound(err) {
+		return err
+	}
+	if err == nil {
+		if ref := metav1.GetControllerOf(existing); ref == nil || ref.UID != tenant.UID {
+			return fmt.Errorf("%s/%s is not managed by tenant %q, refusing to overwrite", namespace, source.Name, tenant.Name)
+		}
+	}
+
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      source.Name,
+			Namespace: namespace,
+			Labels:    map[string]string{tenantLabel: tenant.Name, pullSecretLabel: "true"},
+		},
+		Type: source.Type,
+		Data: source.Data,
+	}
+	if err := controllerutil.SetControllerReference(tenant, secret, r.Scheme); err != nil {
+		return err
+	}
+	return r.apply(ctx, secret)
+}
+
+// patchDefaultPullSecrets adds the wanted pull secrets to the
+// imagePullSecrets of namespace's default ServiceAccount and drops the
+// stale ones
+func (r *TenantReconciler) patchDefaultPullSecrets(ctx context.Context, namespace string, wanted, stale map[string]bool) error {
+	if len(wanted) == 0 && len(stale) == 0 {
+		return nil
+	}
+	sa := &corev1.ServiceAccount{}
+	if err := r.Get(ctx, types.NamespacedName{Namespace: namespace, Name: "default"}, sa); err != nil {
+		// Its creation triggers another reconcile, see
+		// reconcileDefaultServiceAccount
+		return client.IgnoreNotFound(err)
+	}
+
+	refs := make([]corev1.LocalObjectReference, 0, len(sa.ImagePullSecrets)+len(wanted))
+	listed := map[string]bool{}
+	for _, ref := range sa.ImagePullSecrets {
+		if stale[ref.Name] {
+			continue
+		}
+		refs = append(refs, ref)
+		listed[ref.Name] = true
+	}
+	// In flag order, so the list doesn't change between passes
+	for _, name := range r.ImagePullSecrets {
+		if wanted[name] && !listed[name] {
+			refs = append(refs, corev1.LocalObjectReference{Name: name})
+		}
+	}
+	if equality.Semantic.DeepEqual(refs, sa.ImagePullSecrets) {
+		return nil
+	}
+
+	patch := client.MergeFrom(sa.DeepCopy())
+	sa.ImagePullSecrets = refs
+	return r.Patch(ctx, sa, patch)
+}
+
+// sourceSecretToTenants maps changes to a Secret mirrored into tenant
+// namespaces, such as a credential rotation, to a reconcile of the Tenants
+// it is mirrored to: all of them for a pull secret, those listing it in
+// Spec.SharedSecrets for a shared one
+func (r *TenantReconciler) sourceSecretToTenants(ctx context.Context, obj client.Object) []reconcile.Request {
+	if obj.GetNamespace() != r.SecretNamespace {
+		return nil
+	}
+	pullSecret := slices.Contains(r.ImagePullSecrets, obj.GetName())
+
+	tenants, err := listTenants(ctx, r.Client)
+	if err != nil {
+		ctrl.LoggerFrom(ctx).Error(err, "Failed to list Tenants for Secret", "secret", obj.GetName())
+		return nil
+	}
+	var requests []reconcile.Request
+	for _, tenant := range tenants {
+		if pullSecret || slices.Contains(tenant.Spec.SharedSecrets, obj.GetName()) {
+			requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Name: tenant.Name}})
+		}
+	}
+	return requests
+}
@@ -0,0 +1,117 @@
+// Quota usage
+// Warns tenants before they exhaust their ResourceQuota, so a failed pod
+// schedule isn't the first sign they need more capacity
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	platformv1alpha1 "github.com/xyz-company/tenant-operator/api/v1alpha1"
+)
+
+// defaultSoftThresholdPercent applies when TenantQuota.SoftThresholdPercent is unset
+const defaultSoftThresholdPercent = 80
+
+// softThresholdPercent returns the effective soft threshold for quota
+func softThresholdPercent(quota platformv1alpha1.TenantQuota) int {
+	if quota.SoftThresholdPercent == 0 {
+		return defaultSoftThresholdPercent
+	}
+	return quota.SoftThresholdPercent
+}
+
+// reconcileQuotaUsage emits a QuotaNearLimit Warning event on each tenant
+// ResourceQuota, and sets the QuotaNearLimit condition, while usage of any
+// hard dimension is at or above the soft threshold. Repeated events are
+// aggregated by the API server.
+func (r *TenantReconciler) reconcileQuotaUsage(ctx context.Context, tenant *platformv1alpha1.Tenant, namespaces []string) error {
+	percent := softThresholdPercent(tenant.Spec.Quota)
+	var near []string
+	for _, namespace := range namespaces {
+		quota := &corev1.ResourceQuota{}
+		if err := r.Get(ctx, types.NamespacedName{Namespace: namespace, Name: "tenant-quota"}, quota); err != nil {
+			if errors.IsNotFound(err) {
+				continue
+			}
+			return err
+		}
+
+		dimensions := quotaDimensionsNearLimit(quota, percent)
+		if len(dimensions) == 0 {
+			continue
+		}
+		message := fmt.Sprintf("Usage is at or above %d%% of the hard limit for %s", percent, strings.Join(dimensions, ", "))
+		ctrl.LoggerFrom(ctx).Info("Quota near limit", "namespace", namespace, "resources", dimensions)
+		r.Recorder.Event(quota, corev1.EventTypeWarning, "QuotaNearLimit", message)
+
+		// Name the namespace when the tenant has several
+		if len(namespaces) > 1 {
+			for i := range dimensions {
+				dimensions[i] = namespace + " " + dimensions[i]
+			}
+		}
+		near = append(near, dimensions...)
+	}
+
+	if len(near) == 0 {
+		meta.SetStatusCondition(&tenant.Status.Conditions, metav1.Condition{
+			Type:               ConditionQuotaNearLimit,
+			Status:             metav1.ConditionFalse,
+			Reason:             ReasonBelowThreshold,
+			Message:            fmt.Sprintf("Usage is below %d%% of every hard limit", percent),
+			ObservedGeneration: tenant.Generation,
+		})
+		return nil
+	}
+
+	meta.SetStatusCondition(&tenant.Status.Conditions, metav1.Condition{
+		Type:               ConditionQuotaNearLimit,
+		Status:             metav1.ConditionTrue,
+		Reason:             ReasonAboveThreshold,
+		Message:            fmt.Sprintf("Usage is at or above %d%% of the hard limit for %s", percent, strings.Join(near, ", ")),
+		ObservedGeneration: tenant.Generation,
+	})
+	return nil
+}
+
+// quotaDimensionsNearLimit lists, as "resource (used/hard)", every hard
+// dimension whose usage is at or above percent of its limit
+func quotaDimensionsNearLimit(quota *corev1.ResourceQuota, percent int) []string {
+	var near []string
+	for name, hard := range quota.Status.Hard {
+		if hard.IsZero() {
+			continue
+		}
+		used, ok := quota.Status.Used[name]
+		if !ok {
+			continue
+		}
+		if used.MilliValue()*100 >= hard.MilliValue()*int64(percent) {
+			near = append(near, fmt.Sprintf("%s (%s/%s)", name, used.String(), hard.String()))
+		}
+	}
+	sort.Strings(near)
+	return near
+}
+
+// quotaToTenant maps tenant-quota usage changes to a reconcile of its tenant
+func quotaToTenant(ctx context.Context, obj client.Object) []reconcile.Request {
+	name, ok := obj.GetLabels()[tenantLabel]
+	if obj.GetName() != "tenant-quota" || !ok {
+		return nil
+	}
+	return []reconcile.Request{{NamespacedName: types.NamespacedName{Name: name}}}
+}
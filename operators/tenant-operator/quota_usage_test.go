@@ -0,0 +1,186 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// setQuotaUsage records, as the quota controller would, pods used of the
+// hard limit on the tenant-quota of namespace
+func setQuotaUsage(t *testing.T, c client.Client, namespace string, used, hard int) {
+	t.Helper()
+	quota := &corev1.ResourceQuota{}
+	if err := c.Get(context.Background(), client.ObjectKey{Namespace: namespace, Name: "tenant-quota"}, quota); err != nil {
+		t.Fatal(err)
+	}
+	quota.Status = corev1.ResourceQuotaStatus{
+		Hard: corev1.ResourceList{corev1.ResourcePods: resource.MustParse(fmt.Sprint(hard))},
+		Used: corev1.ResourceList{corev1.ResourcePods: resource.MustParse(fmt.Sprint(used))},
+	}
+	// ResourceQuota status isn't a subresource in the fake client
+	if err := c.Update(context.Background(), quota); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// nearLimitEvents returns the QuotaNearLimit Warning events among events
+func nearLimitEvents(events []string) []string {
+	var near []string
+	for _, event := range events {
+		if strings.HasPrefix(event, corev1.EventTypeWarning+" QuotaNearLimit ") {
+			near = append(near, event)
+		}
+	}
+	return near
+}
+
+func TestQuotaNearLimit(t *testing.T) {
+	tenant := newTenant("search", "search-team")
+	tenant.Spec.Quota.Pods = 10
+	c := newReconcilerClient(tenant)
+	r := newTestReconciler(c)
+	reconcileTenant(t, r, "search")
+
+	// Below the default 80%
+	setQuotaUsage(t, c, "search", 7, 10)
+	recordedEvents(r)
+	reconcileTenant(t, r, "search")
+	if events := nearLimitEvents(recordedEvents(r)); len(events) != 0 {
+		t.Fatalf("events below the threshold: %q", events)
+	}
+	if meta.IsStatusConditionTrue(storedTenant(t, c, "search").Status.Conditions, ConditionQuotaNearLimit) {
+		t.Fatal("QuotaNearLimit true at 70%")
+	}
+
+	// At the threshold
+	setQuotaUsage(t, c, "search", 8, 10)
+	reconcileTenant(t, r, "search")
+	want := "Warning QuotaNearLimit Usage is at or above 80% of the hard limit for pods (8/10)"
+	if events := nearLimitEvents(recordedEvents(r)); len(events) != 1 || events[0] != want {
+		t.Fatalf("events = %q, want [%q]", events, want)
+	}
+	near := meta.FindStatusCondition(storedTenant(t, c, "search").Status.Conditions, ConditionQuotaNearLimit)
+	if near == nil || near.Status != metav1.ConditionTrue || near.Reason != ReasonAboveThreshold || !strings.Contains(near.Message, "pods (8/10)") {
+		t.Fatalf("QuotaNearLimit = %+v, want True for pods (8/10)", near)
+	}
+
+	// Cleared once usage drops
+	setQuotaUsage(t, c, "search", 2, 10)
+	reconcileTenant(t, r, "search")
+	if events := nearLimitEvents(recordedEvents(r)); len(events) != 0 {
+		t.Fatalf("events after usage dropped: %q", events)
+	}
+	near = meta.FindStatusCondition(storedTenant(t, c, "search").Status.Conditions, ConditionQuotaNearLimit)
+	if near == nil || near.Status != metav1.ConditionFalse || near.Reason != ReasonBelowThreshold {
+		t.Fatalf("QuotaNearLimit = %+v, want False once usage dropped", near)
+	}
+}
+
+func TestQuotaNearLimitCustomThreshold(t *testing.T) {
+	tenant := newTenant("search", "search-team")
+	tenant.Spec.Quota.Pods = 10
+	tenant.Spec.Quota.SoftThresholdPercent = 50
+	c := newReconcilerClient(tenant)
+	r := newTestReconciler(c)
+	reconcileTenant(t, r, "search")
+
+	setQuotaUsage(t, c, "search", 5, 10)
+	reconcileTenant(t, r, "search")
+	if events := nearLimitEvents(recordedEvents(r)); len(events) != 1 || !strings.Contains(events[0], "at or above 50%") {
+		t.Fatalf("events = %q, want one at 50%%", events)
+	}
+	if !meta.IsStatusConditionTrue(storedTenant(t, c, "search").Status.Conditions, ConditionQuotaNearLimit) {
+		t.Fatal("QuotaNearLimit not set at a 50% threshold")
+	}
+}
+
+func TestQuotaDimensionsNearLimit(t *testing.T) {
+	quota := &corev1.ResourceQuota{Status: corev1.ResourceQuotaStatus{
+		Hard: corev1.ResourceList{
+			corev1.ResourceRequestsCPU:    resource.MustParse("4"),
+			corev1.ResourceRequestsMemory: resource.MustParse("8Gi"),
+			corev1.ResourcePods:           resource.MustParse("10"),
+			corev1.ResourceServices:       resource.MustParse("0"),
+		},
+		Used: corev1.ResourceList{
+			corev1.ResourceRequestsCPU:    resource.MustParse("3500m"),
+			corev1.ResourceRequestsMemory: resource.MustParse("4Gi"),
+			corev1.ResourceServices:       resource.MustParse("0"),
+		},
+	}}
+	got := quotaDimensionsNearLimit(quota, 80)
+	if len(got) != 1 || got[0] != "requests.cpu (3500m/4)" {
+		t.Fatalf("quotaDimensionsNearLimit() = %q, want only requests.cpu", got)
+	}
+	if got := quotaDimensionsNearLimit(quota, 50); len(got) != 2 {
+		t.Fatalf("quotaDimensionsNearLimit(50) = %q, want cpu and memory", got)
+	}
+}
+
+func TestWebhookSoftThresholdRange(t *testing.T) {
+	c := newFakeClient()
+	v := &TenantValidator{Client: c, Reader: c}
+
+	for _, percent := range []int{0, 1, 80, 100} {
+		tenant := newTenant("search", "search-team")
+		tenant.Spec.Quota.SoftThresholdPercent = percent
+		wantAllowed(t, v.Handle(context.Background(), admissionRequest(t, admissionv1.Create, tenant, nil)))
+	}
+	for _, percent := range []int{-5, 101} {
+		tenant := newTenant("search", "search-team")
+		tenant.Spec.Quota.SoftThresholdPercent = percent
+		resp := v.Handle(context.Background(), admissionRequest(t, admissionv1.Create, tenant, nil))
+		wantDenied(t, resp, fmt.Sprintf("quota.softThresholdPercent must be between 1 and 100, got %d", percent))
+	}
+}
+
+func TestEnvtestQuotaNearLimit(t *testing.T) {
+	ctx := context.Background()
+	c := envtestClient(t)
+	tenant := newTenant("quota-usage", "search-team")
+	tenant.Spec.Quota.Pods = 10
+	r := createEnvtestTenant(t, c, tenant)
+	reconcileTenant(t, r, "quota-usage")
+
+	// Nothing runs the quota controller, so record its usage by hand through
+	// the status subresource
+	quota := &corev1.ResourceQuota{}
+	if err := c.Get(ctx, client.ObjectKey{Namespace: "quota-usage", Name: "tenant-quota"}, quota); err != nil {
+		t.Fatal(err)
+	}
+	quota.Status = corev1.ResourceQuotaStatus{
+		Hard: corev1.ResourceList{corev1.ResourcePods: resource.MustParse("10")},
+		Used: corev1.ResourceList{corev1.ResourcePods: resource.MustParse("9")},
+	}
+	if err := c.Status().Update(ctx, quota); err != nil {
+		t.Fatal(err)
+	}
+	recordedEvents(r)
+	reconcileTenant(t, r, "quota-usage")
+
+	want := "Warning QuotaNearLimit Usage is at or above 80% of the hard limit for pods (9/10)"
+	if events := nearLimitEvents(recordedEvents(r)); len(events) != 1 || events[0] != want {
+		t.Fatalf("events = %q, want %q", events, want)
+	}
+	near := meta.FindStatusCondition(storedTenant(t, c, "quota-usage").Status.Conditions, ConditionQuotaNearLimit)
+	if near == nil || near.Status != metav1.ConditionTrue || near.Reason != ReasonAboveThreshold {
+		t.Fatalf("QuotaNearLimit = %+v, want True %s in the stored status", near, ReasonAboveThreshold)
+	}
+
+	// The next quota apply leaves the usage, which it doesn't own, alone
+	if err := c.Get(ctx, client.ObjectKeyFromObject(quota), quota); err != nil {
+		t.Fatal(err)
+	}
+	if used := quota.Status.Used[corev1.ResourcePods]; used.String() != "9" {
+		t.Errorf("used pods = %s after reconcile, want 9", used.String())
+	}
+}
@@ -0,0 +1,185 @@
+// Scoped quotas
+// Spec.Quota.Scopes adds ResourceQuotas limited to one quota scope, such as
+// best-effort pods or long-running (NotTerminating) workloads, on top of
+// tenant-quota. Each is named tenant-quota-<scope>, e.g.
+// tenant-quota-besteffort.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	platformv1alpha1 "github.com/xyz-company/tenant-operator/api/v1alpha1"
+)
+
+// quotaScopeLabel marks the scoped ResourceQuotas with their scope, so
+// those dropped from the spec can be found and deleted
+const quotaScopeLabel = "platform.xyz.com/quota-scope"
+
+// quotaScopes are the scopes Spec.Quota.Scopes may use
+var quotaScopes = map[corev1.ResourceQuotaScope]bool{
+	corev1.ResourceQuotaScopeBestEffort:     true,
+	corev1.ResourceQuotaScopeNotBestEffort:  true,
+	corev1.ResourceQuotaScopeTerminating:    true,
+	corev1.ResourceQuotaScopeNotTerminating: true,
+}
+
+// scopedQuotaName returns the name of the ResourceQuota for scope
+func scopedQuotaName(scope corev1.ResourceQuotaScope) string {
+	return "tenant-quota-" + strings.ToLower(string(scope))
+}
+
+// validateQuotaScopes rejects unknown or repeated scopes, scopes that cap
+// nothing, invalid values, and cpu or memory caps on BestEffort, whose pods
+// request neither
+func validateQuotaScopes(scopes []platformv1alpha1.TenantQuotaScope) error {
+	seen := make(map[corev1.ResourceQuotaScope]bool, len(scopes))
+	for _, scope := range scopes {
+		switch {
+		case !quotaScopes[scope.Scope]:
+			return fmt.Errorf("quota.scopes scope %q must be BestEffort, NotBestEffort, Terminating or NotTerminating", scope.Scope)
+		case seen[scope.Scope]:
+			return fmt.Errorf("quota.scopes lists %s twice", scope.Scope)
+		case scope.CPU == "" && scope.Memory == "" && scope.Pods == 0:
+			return fmt.Errorf("quota.scopes %s caps nothing; set cpu, memory or pods", scope.Scope)
+		case scope.Scope == corev1.ResourceQuotaScopeBestEffort && (scope.CPU != "" || scope.Memory != ""):
+			return fmt.Errorf("quota.scopes BestEffort can only cap pods")
+		case scope.Pods < 0:
+			return fmt.Errorf("quota.scopes %s pods must not be negative, got %d", scope.Scope, scope.Pods)
+		}
+		field := "scopes." + string(scope.Scope)
+		if scope.CPU != "" {
+			if _, err := parseQuotaQuantity(field+".cpu", scope.CPU); err != nil {
+				return err
+			}
+		}
+		if scope.Memory != "" {
+			if _, err := parseQuotaQuantity(field+".memory", scope.Memory); err != nil {
+				return err
+			}
+		}
+		seen[scope.Scope] = true
+	}
+	return nil
+}
+
+// scopedQuotas returns the scoped ResourceQuotas for one of tenant's
+// namespaces, built from its share of Spec.Quota.Scopes. Like tenant-quota,
+// cpu and memory cap requests and limits are allowed twice that.
+func scopedQuotas(tenant *platformv1alpha1.Tenant, namespace string) ([]*corev1.ResourceQuota, error) {
+	spec, err := namespaceQuota(tenant, namespace)
+	if err != nil {
+		return nil, err
+	}
+	if err := validateQuotaScopes(spec.Scopes); err != nil {
+		return nil, err
+	}
+
+	quotas := make([]*corev1.ResourceQuota, 0, len(spec.Scopes))
+	for _, scope := range spec.Scopes {
+		field := "scopes." + string(scope.Scope)
+		hard := corev1.ResourceList{}
+		if scope.CPU != "" {
+			cpu, err := parseQuotaQuantity(field+".cpu", scope.CPU)
+			if err != nil {
+				return nil, err
+			}
+			hard[corev1.ResourceRequestsCPU] = cpu
+			hard[corev1.ResourceLimitsCPU] = doubled(cpu)
+		}
+		if scope.Memory != "" {
+			memory, err := parseQuotaQuantity(field+".memory", scope.Memory)
+			if err != nil {
+				return nil, err
+			}
+			hard[corev1.ResourceRequestsMemory] = memory
+			hard[corev1.ResourceLimitsMemory] = doubled(memory)
+		}
+		if scope.Pods > 0 {
+			hard[corev1.ResourcePods] = *resource.NewQuantity(int64(scope.Pods), resource.DecimalSI)
+		}
+
+		quotas = append(quotas, &corev1.ResourceQuota{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      scopedQuotaName(scope.Scope),
+				Namespace: namespace,
+				Labels:    map[string]string{quotaScopeLabel: string(scope.Scope)},
+			},
+			Spec: corev1.ResourceQuotaSpec{
+				Hard:   hard,
+				Scopes: []corev1.ResourceQuotaScope{scope.Scope},
+			},
+		})
+	}
+	return quotas, nil
+}
+
+// reconcileScopedQuotas applies the scoped ResourceQuotas of namespace and
+// deletes those whose scope is no longer in the spec
+func (r *TenantReconciler) reconcileScopedQuotas(ctx context.Context, tenant *platformv1alpha1.Tenant, namespace string) error {
+	quotas, err := scopedQuotas(tenant, namespace)
+	if err != nil {
+		return err
+	}
+	wanted := make(map[string]bool, len(quotas))
+	for _, quota := range quotas {
+		if err := r.applyOrAdopt(ctx, tenant, quota); err != nil {
+			return err
+		}
+		wanted[quota.Name] = true
+	}
+
+	existing := &corev1.ResourceQuotaList{}
+	if err := r.List(ctx, existing, client.InNamespace(namespace), client.MatchingLabels{tenantLabel: tenant.Name}, client.HasLabels{quotaScopeLabel}); err != nil {
+		return err
+	}
+	for i := range existing.Items {
+		quota := &existing.Items[i]
+		if wanted[quota.Name] {
+			continue
+		}
+		if err := r.Delete(ctx, quota); err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+		ctrl.LoggerFrom(ctx).Info("Removed scoped ResourceQuota", "namespace", namespace, "quota", quota.Name)
+	}
+	return nil
+}
+
+// shareQuotaScopes returns copies of scopes cut to percent, rounded like
+// the other quota shares
+func shareQuotaScopes(scopes []platformv1alpha1.TenantQuotaScope, percent int, share func(int) int) ([]platformv1alpha1.TenantQuotaScope, error) {
+	if len(scopes) == 0 {
+		return nil, nil
+	}
+	shared := make([]platformv1alpha1.TenantQuotaScope, len(scopes))
+	for i, scope := range scopes {
+		field := "scopes." + string(scope.Scope)
+		if scope.CPU != "" {
+			cpu, err := parseQuotaQuantity(field+".cpu", scope.CPU)
+			if err != nil {
+				return nil, err
+			}
+			scope.CPU = resource.NewMilliQuantity(cpu.MilliValue()*int64(percent)/100, resource.DecimalSI).String()
+		}
+		if scope.Memory != "" {
+			memory, err := parseQuotaQuantity(field+".memory", scope.Memory)
+			if err != nil {
+				return nil, err
+			}
+			scope.Memory = resource.NewQuantity(memory.Value()*int64(percent)/100, resource.BinarySI).String()
+		}
+		scope.Pods = share(scope.Pods)
+		shared[i] = scope
+	}
+	return shared, nil
+}
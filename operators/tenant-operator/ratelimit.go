@@ -0,0 +1,77 @@
+// Default request rate limit
+// Tenants can set a default request rate for their HTTPRoutes; the operator
+// publishes it as a namespace annotation that our Gateway controller applies
+// to routes without a limit of their own
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	platformv1alpha1 "github.com/xyz-company/tenant-operator/api/v1alpha1"
+)
+
+// requestRateLimitAnnotation carries Spec.DefaultRequestRateLimit on the tenant namespace
+const requestRateLimitAnnotation = "platform.xyz.com/default-request-rate-limit"
+
+var httpRouteGVK = schema.GroupVersionKind{Group: "gateway.networking.k8s.io", Version: "v1", Kind: "HTTPRoute"}
+
+// validateRequestRate accepts rates of the form "<n>r/s" or "<n>r/m" with n > 0
+func validateRequestRate(rate string) error {
+	count, unit, ok := strings.Cut(rate, "r/")
+	if !ok || (unit != "s" && unit != "m") {
+		return fmt.Errorf("defaultRequestRateLimit %q must look like 100r/s or 6000r/m", rate)
+	}
+	if n, err := strconv.Atoi(count); err != nil || n < 1 {
+		return fmt.Errorf("defaultRequestRateLimit %q must have a positive request count", rate)
+	}
+	return nil
+}
+
+// reconcileRequestRateLimit sets the rate limit annotation from the spec, or
+// removes it when the field is unset. Nothing is done without the Gateway API.
+func (r *TenantReconciler) reconcileRequestRateLimit(ctx context.Context, tenant *platformv1alpha1.Tenant, namespace string) error {
+	log := ctrl.LoggerFrom(ctx)
+	rate := tenant.Spec.DefaultRequestRateLimit
+
+	installed, err := r.kindInstalled(httpRouteGVK)
+	if err != nil {
+		return err
+	}
+	if !installed {
+		if rate != "" {
+			log.Info("Gateway API CRDs not installed, skipping default request rate limit", "namespace", namespace)
+		}
+		return nil
+	}
+
+	if rate == "" {
+		return r.removeNamespaceAnnotation(ctx, namespace, requestRateLimitAnnotation)
+	}
+	if err := validateRequestRate(rate); err != nil {
+		return err
+	}
+	return r.setNamespaceAnnotation(ctx, namespace, requestRateLimitAnnotation, rate)
+}
+
+// removeNamespaceAnnotation deletes key from the namespace if present
+func (r *TenantReconciler) removeNamespaceAnnotation(ctx context.Context, namespace, key string) error {
+	ns := &corev1.Namespace{}
+	if err := r.Get(ctx, types.NamespacedName{Name: namespace}, ns); err != nil {
+		return err
+	}
+	if _, ok := ns.Annotations[key]; !ok {
+		return nil
+	}
+
+	delete(ns.Annotations, key)
+	return r.Update(ctx, ns)
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// namespaceAnnotation returns the annotation key of namespace, and whether it is set
+func namespaceAnnotation(t *testing.T, c client.Client, namespace, key string) (string, bool) {
+	t.Helper()
+	ns := &corev1.Namespace{}
+	if err := c.Get(context.Background(), client.ObjectKey{Name: namespace}, ns); err != nil {
+		t.Fatal(err)
+	}
+	value, ok := ns.Annotations[key]
+	return value, ok
+}
+
+func TestRequestRateLimitAnnotation(t *testing.T) {
+	tenant := newTenant("search", "search-team")
+	tenant.Spec.DefaultRequestRateLimit = "100r/s"
+	c := reconcilerClientBuilder(tenant).WithRESTMapper(installedKinds(httpRouteGVK)).Build()
+	r := newTestReconciler(c)
+	reconcileTenant(t, r, "search")
+
+	if got, _ := namespaceAnnotation(t, c, "search", requestRateLimitAnnotation); got != "100r/s" {
+		t.Fatalf("%s = %q, want 100r/s", requestRateLimitAnnotation, got)
+	}
+
+	// Spec changes are followed
+	stored := storedTenant(t, c, "search")
+	stored.Spec.DefaultRequestRateLimit = "6000r/m"
+	if err := c.Update(context.Background(), stored); err != nil {
+		t.Fatal(err)
+	}
+	reconcileTenant(t, r, "search")
+	if got, _ := namespaceAnnotation(t, c, "search", requestRateLimitAnnotation); got != "6000r/m" {
+		t.Fatalf("%s = %q after a spec change, want 6000r/m", requestRateLimitAnnotation, got)
+	}
+
+	// Unsetting the field removes the annotation
+	stored = storedTenant(t, c, "search")
+	stored.Spec.DefaultRequestRateLimit = ""
+	if err := c.Update(context.Background(), stored); err != nil {
+		t.Fatal(err)
+	}
+	reconcileTenant(t, r, "search")
+	if got, ok := namespaceAnnotation(t, c, "search", requestRateLimitAnnotation); ok {
+		t.Fatalf("%s = %q with the field unset, want it removed", requestRateLimitAnnotation, got)
+	}
+}
+
+func TestRequestRateLimitUnset(t *testing.T) {
+	c := reconcilerClientBuilder(newTenant("search", "search-team")).WithRESTMapper(installedKinds(httpRouteGVK)).Build()
+	reconcileTenant(t, newTestReconciler(c), "search")
+
+	if got, ok := namespaceAnnotation(t, c, "search", requestRateLimitAnnotation); ok {
+		t.Fatalf("%s = %q without a rate, want none", requestRateLimitAnnotation, got)
+	}
+}
+
+func TestRequestRateLimitNeedsGatewayAPI(t *testing.T) {
+	tenant := newTenant("search", "search-team")
+	tenant.Spec.DefaultRequestRateLimit = "100r/s"
+	c := newReconcilerClient(tenant)
+	reconcileTenant(t, newTestReconciler(c), "search")
+
+	if got, ok := namespaceAnnotation(t, c, "search", requestRateLimitAnnotation); ok {
+		t.Fatalf("%s = %q without the Gateway API, want none", requestRateLimitAnnotation, got)
+	}
+	if phase := storedTenant(t, c, "search").Status.Phase; phase != "Ready" {
+		t.Fatalf("phase = %s, want Ready", phase)
+	}
+}
+
+func TestRequestRateLimitRejectsInvalidRateAtReconcile(t *testing.T) {
+	tenant := newTenant("search", "search-team")
+	tenant.Spec.DefaultRequestRateLimit = "fast"
+	c := reconcilerClientBuilder(tenant).WithRESTMapper(installedKinds(httpRouteGVK)).Build()
+	r := newTestReconciler(c)
+
+	_, err := r.Reconcile(context.Background(), ctrl.Request{NamespacedName: client.ObjectKey{Name: "search"}})
+	if err == nil || !strings.Contains(err.Error(), `defaultRequestRateLimit "fast"`) {
+		t.Fatalf("Reconcile() = %v, want the invalid rate reported", err)
+	}
+	if got, ok := namespaceAnnotation(t, c, "search", requestRateLimitAnnotation); ok {
+		t.Fatalf("%s = %q for an invalid rate, want none", requestRateLimitAnnotation, got)
+	}
+}
+
+func TestValidateRequestRate(t *testing.T) {
+	tests := []struct {
+		rate  string
+		valid bool
+	}{
+		{"1r/s", true},
+		{"100r/s", true},
+		{"6000r/m", true},
+		{"0r/s", false},
+		{"-5r/s", false},
+		{"100r/h", false},
+		{"100/s", false},
+		{"r/s", false},
+		{"fast", false},
+		{"1.5r/s", false},
+	}
+	for _, tt := range tests {
+		if err := validateRequestRate(tt.rate); (err == nil) != tt.valid {
+			t.Errorf("validateRequestRate(%q) = %v, want valid %v", tt.rate, err, tt.valid)
+		}
+	}
+}
+
+func TestWebhookValidatesRequestRate(t *testing.T) {
+	c := newFakeClient()
+	v := &TenantValidator{Client: c, Reader: c}
+
+	tenant := newTenant("search", "search-team")
+	tenant.Spec.DefaultRequestRateLimit = "100r/s"
+	wantAllowed(t, v.Handle(context.Background(), admissionRequest(t, admissionv1.Create, tenant, nil)))
+
+	tenant.Spec.DefaultRequestRateLimit = "100r/h"
+	wantDenied(t, v.Handle(context.Background(), admissionRequest(t, admissionv1.Create, tenant, nil)), `defaultRequestRateLimit "100r/h" must look like 100r/s or 6000r/m`)
+}
+
+func TestEnvtestRequestRateLimit(t *testing.T) {
+	ctx := context.Background()
+	c := envtestClient(t)
+
+	// The CRD schema refuses malformed rates before the webhook sees them
+	invalid := newTenant("rate-limit-invalid", "search-team")
+	invalid.Spec.DefaultRequestRateLimit = "100 per second"
+	if err := c.Create(ctx, invalid); !apierrors.IsInvalid(err) {
+		t.Fatalf("creating a tenant with rate %q = %v, want Invalid", invalid.Spec.DefaultRequestRateLimit, err)
+	}
+
+	tenant := newTenant("rate-limit", "search-team")
+	tenant.Spec.DefaultRequestRateLimit = "100r/s"
+	r := createEnvtestTenant(t, c, tenant)
+	reconcileTenant(t, r, "rate-limit")
+	if got, _ := namespaceAnnotation(t, c, "rate-limit", requestRateLimitAnnotation); got != "100r/s" {
+		t.Fatalf("%s = %q, want 100r/s", requestRateLimitAnnotation, got)
+	}
+
+	stored := storedTenant(t, c, "rate-limit")
+	stored.Spec.DefaultRequestRateLimit = ""
+	if err := c.Update(ctx, stored); err != nil {
+		t.Fatal(err)
+	}
+	reconcileTenant(t, r, "rate-limit")
+	if got, ok := namespaceAnnotation(t, c, "rate-limit", requestRateLimitAnnotation); ok {
+		t.Fatalf("%s = %q with the field unset, want it removed", requestRateLimitAnnotation, got)
+	}
+}
@@ -0,0 +1,100 @@
+// Tenant access
+// Spec.Access grants groups and users one of three roles in the tenant
+// namespace, each bound through its own RoleBinding. The admin role uses the
+// platform's tenant-admin ClusterRole (k8s/tenant-admin.yaml).
+
+package main
+
+import (
+	"context"
+
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	platformv1alpha1 "github.com/xyz-company/tenant-operator/api/v1alpha1"
+)
+
+// tenantRoles lists the roles in binding order, with the ClusterRole each
+// is bound to and the suffix of its RoleBinding name
+var tenantRoles = []struct {
+	role        platformv1alpha1.TenantRole
+	clusterRole string
+	suffix      string
+}{
+	{platformv1alpha1.TenantRoleViewer, "view", "-viewers"},
+	{platformv1alpha1.TenantRoleDeveloper, "edit", "-developers"},
+	{platformv1alpha1.TenantRoleAdmin, "tenant-admin", "-admins"},
+}
+
+// tenantAccess returns Spec.Access, or the default grant of the developer
+// role to the <name>-team group when it is empty
+func tenantAccess(tenant *platformv1alpha1.Tenant) []platformv1alpha1.TenantAccess {
+	if len(tenant.Spec.Access) > 0 {
+		return tenant.Spec.Access
+	}
+	return []platformv1alpha1.TenantAccess{
+		{Role: platformv1alpha1.TenantRoleDeveloper, Groups: []string{tenant.Name + "-team"}},
+	}
+}
+
+// tenantRoleBindings returns one RoleBinding per role for namespace, in
+// tenantRoles order.
+// Roles granted to nobody get a RoleBinding without subjects, for the
+// caller to delete.
+func tenantRoleBindings(tenant *platformv1alpha1.Tenant, namespace string) []*rbacv1.RoleBinding {
+	subjects := map[platformv1alpha1.TenantRole][]rbacv1.Subject{}
+	// Grants inherited from a parent Tenant may repeat the tenant's own
+	seen := map[platformv1alpha1.TenantRole]map[rbacv1.Subject]bool{}
+	add := func(role platformv1alpha1.TenantRole, subject rbacv1.Subject) {
+		if seen[role] == nil {
+			seen[role] = map[rbacv1.Subject]bool{}
+		}
+		if !seen[role][subject] {
+			seen[role][subject] = true
+			subjects[role] = append(subjects[role], subject)
+		}
+	}
+	for _, access := range tenantAccess(tenant) {
+		for _, group := range access.Groups {
+			add(access.Role, rbacv1.Subject{Kind: rbacv1.GroupKind, Name: group, APIGroup: rbacv1.GroupName})
+		}
+		for _, user := range access.Users {
+			add(access.Role, rbacv1.Subject{Kind: rbacv1.UserKind, Name: user, APIGroup: rbacv1.GroupName})
+		}
+	}
+
+	bindings := make([]*rbacv1.RoleBinding, 0, len(tenantRoles))
+	for _, role := range tenantRoles {
+		bindings = append(bindings, &rbacv1.RoleBinding{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      tenant.Name + role.suffix,
+				Namespace: namespace,
+			},
+			Subjects: subjects[role.role],
+			RoleRef: rbacv1.RoleRef{
+				Kind:     "ClusterRole",
+				Name:     role.clusterRole,
+				APIGroup: rbacv1.GroupName,
+			},
+		})
+	}
+	return bindings
+}
+
+// reconcileAccess applies the RoleBindings of the granted roles in
+// namespace and deletes those of roles no longer granted
+func (r *TenantReconciler) reconcileAccess(ctx context.Context, tenant *platformv1alpha1.Tenant, namespace string) error {
+	for _, binding := range tenantRoleBindings(tenant, namespace) {
+		if len(binding.Subjects) == 0 {
+			if err := r.Delete(ctx, binding); err != nil && !errors.IsNotFound(err) {
+				return err
+			}
+			continue
+		}
+		if err := r.applyOrAdopt(ctx, tenant, binding); err != nil {
+			return err
+		}
+	}
+	return nil
+}
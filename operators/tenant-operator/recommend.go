@@ -0,0 +1,128 @@
+// Quota recommendations
+// Usage of the tenant ResourceQuota is sampled over time and turned into an
+// advisory CPU/memory limit suggestion; it is never applied automatically
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	platformv1alpha1 "github.com/xyz-company/tenant-operator/api/v1alpha1"
+)
+
+const (
+	// usageSamplesAnnotation holds the rolling usage samples on the tenant ResourceQuota
+	usageSamplesAnnotation = "platform.xyz.com/usage-samples"
+	// usageSampleInterval is the minimum time between two samples
+	usageSampleInterval = 15 * time.Minute
+	// maxUsageSamples keeps a week of samples at usageSampleInterval
+	maxUsageSamples = 7 * 24 * 4
+)
+
+// usageSample is one observation of the quota's used limits
+type usageSample struct {
+	Time        int64 `json:"t"`
+	MilliCPU    int64 `json:"cpu"`
+	MemoryBytes int64 `json:"mem"`
+}
+
+// reconcileQuotaRecommendation records a usage sample on the namespace's
+// tenant quota when the last one is older than usageSampleInterval, and
+// returns the quota recommended from the samples kept
+func (r *TenantReconciler) reconcileQuotaRecommendation(ctx context.Context, namespace string) (*platformv1alpha1.RecommendedQuota, error) {
+	quota := &corev1.ResourceQuota{}
+	if err := r.Get(ctx, types.NamespacedName{Namespace: namespace, Name: "tenant-quota"}, quota); err != nil {
+		if errors.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var samples []usageSample
+	if raw := quota.Annotations[usageSamplesAnnotation]; raw != "" {
+		// Start over rather than fail if the annotation was mangled
+		if err := json.Unmarshal([]byte(raw), &samples); err != nil {
+			samples = nil
+		}
+	}
+
+	now := time.Now()
+	if len(samples) == 0 || now.Sub(time.Unix(samples[len(samples)-1].Time, 0)) >= usageSampleInterval {
+		samples = append(samples, sampleQuotaUsage(quota, now))
+		if len(samples) > maxUsageSamples {
+			samples = samples[len(samples)-maxUsageSamples:]
+		}
+
+		raw, err := json.Marshal(samples)
+		if err != nil {
+			return nil, err
+		}
+		patch := client.MergeFrom(quota.DeepCopy())
+		if quota.Annotations == nil {
+			quota.Annotations = map[string]string{}
+		}
+		quota.Annotations[usageSamplesAnnotation] = string(raw)
+		if err := r.Patch(ctx, quota, patch); err != nil {
+			return nil, err
+		}
+	}
+
+	return recommendQuota(samples, r.QuotaHeadroomPercent), nil
+}
+
+// sampleQuotaUsage reads the used CPU and memory limits from the quota status,
+// falling back to requests for quotas that only cap requests
+func sampleQuotaUsage(quota *corev1.ResourceQuota, now time.Time) usageSample {
+	used := func(limit, request corev1.ResourceName) resource.Quantity {
+		if q, ok := quota.Status.Used[limit]; ok {
+			return q
+		}
+		return quota.Status.Used[request]
+	}
+
+	cpu := used(corev1.ResourceLimitsCPU, corev1.ResourceRequestsCPU)
+	memory := used(corev1.ResourceLimitsMemory, corev1.ResourceRequestsMemory)
+	return usageSample{Time: now.Unix(), MilliCPU: cpu.MilliValue(), MemoryBytes: memory.Value()}
+}
+
+// recommendQuota suggests the peak sampled usage plus headroomPercent,
+// rounded up to 100m CPU and 64Mi memory. It returns nil without samples.
+func recommendQuota(samples []usageSample, headroomPercent int) *platformv1alpha1.RecommendedQuota {
+	if len(samples) == 0 {
+		return nil
+	}
+
+	var peakCPU, peakMemory int64
+	for _, s := range samples {
+		if s.MilliCPU > peakCPU {
+			peakCPU = s.MilliCPU
+		}
+		if s.MemoryBytes > peakMemory {
+			peakMemory = s.MemoryBytes
+		}
+	}
+
+	cpu := roundUp(peakCPU*int64(100+headroomPercent)/100, 100)
+	memory := roundUp(peakMemory*int64(100+headroomPercent)/100, 64<<20)
+	return &platformv1alpha1.RecommendedQuota{
+		CPU:     resource.NewMilliQuantity(cpu, resource.DecimalSI).String(),
+		Memory:  resource.NewQuantity(memory, resource.BinarySI).String(),
+		Samples: len(samples),
+	}
+}
+
+// roundUp rounds n up to a multiple of step, with a minimum of one step
+func roundUp(n, step int64) int64 {
+	if n <= 0 {
+		return step
+	}
+	return (n + step - 1) / step * step
+}
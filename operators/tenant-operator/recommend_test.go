@@ -0,0 +1,218 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	platformv1alpha1 "github.com/xyz-company/tenant-operator/api/v1alpha1"
+)
+
+// mi is n mebibytes in bytes
+func mi(n int64) int64 { return n << 20 }
+
+func TestRecommendQuota(t *testing.T) {
+	tests := []struct {
+		name     string
+		samples  []usageSample
+		headroom int
+		want     *platformv1alpha1.RecommendedQuota
+	}{
+		{name: "no samples"},
+		{
+			name: "peak plus headroom, rounded up",
+			samples: []usageSample{
+				{MilliCPU: 500, MemoryBytes: mi(256)},
+				{MilliCPU: 1200, MemoryBytes: mi(700)},
+				{MilliCPU: 800, MemoryBytes: mi(1000)},
+			},
+			headroom: 20,
+			// 1200m * 1.2 = 1440m, rounded to 1500m; 1000Mi * 1.2 = 1200Mi,
+			// rounded to 19 * 64Mi
+			want: &platformv1alpha1.RecommendedQuota{CPU: "1500m", Memory: "1216Mi", Samples: 3},
+		},
+		{
+			name:     "peaks of different samples",
+			samples:  []usageSample{{MilliCPU: 2000, MemoryBytes: mi(64)}, {MilliCPU: 100, MemoryBytes: mi(512)}},
+			headroom: 0,
+			want:     &platformv1alpha1.RecommendedQuota{CPU: "2", Memory: "512Mi", Samples: 2},
+		},
+		{
+			name:     "large headroom",
+			samples:  []usageSample{{MilliCPU: 1000, MemoryBytes: mi(1024)}},
+			headroom: 100,
+			want:     &platformv1alpha1.RecommendedQuota{CPU: "2", Memory: "2Gi", Samples: 1},
+		},
+		{
+			name:     "idle tenant gets the minimum",
+			samples:  []usageSample{{}, {}},
+			headroom: 20,
+			want:     &platformv1alpha1.RecommendedQuota{CPU: "100m", Memory: "64Mi", Samples: 2},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := recommendQuota(tt.samples, tt.headroom)
+			if (got == nil) != (tt.want == nil) || got != nil && *got != *tt.want {
+				t.Fatalf("recommendQuota() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRoundUp(t *testing.T) {
+	tests := []struct{ n, step, want int64 }{
+		{0, 100, 100},
+		{-5, 100, 100},
+		{1, 100, 100},
+		{100, 100, 100},
+		{101, 100, 200},
+		{mi(65), mi(64), mi(128)},
+	}
+	for _, tt := range tests {
+		if got := roundUp(tt.n, tt.step); got != tt.want {
+			t.Errorf("roundUp(%d, %d) = %d, want %d", tt.n, tt.step, got, tt.want)
+		}
+	}
+}
+
+func TestSampleQuotaUsage(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	quota := &corev1.ResourceQuota{Status: corev1.ResourceQuotaStatus{Used: corev1.ResourceList{
+		corev1.ResourceLimitsCPU:      resource.MustParse("1500m"),
+		corev1.ResourceRequestsCPU:    resource.MustParse("500m"),
+		corev1.ResourceRequestsMemory: resource.MustParse("1Gi"),
+	}}}
+	// Limits are preferred, and requests used where the quota caps no limits
+	want := usageSample{Time: now.Unix(), MilliCPU: 1500, MemoryBytes: mi(1024)}
+	if got := sampleQuotaUsage(quota, now); got != want {
+		t.Fatalf("sampleQuotaUsage() = %+v, want %+v", got, want)
+	}
+}
+
+// setQuotaSamples stores samples, and used as the current usage, on the
+// tenant-quota of namespace
+func setQuotaSamples(t *testing.T, c client.Client, namespace string, samples []usageSample, used corev1.ResourceList) {
+	t.Helper()
+	quota := &corev1.ResourceQuota{}
+	if err := c.Get(context.Background(), client.ObjectKey{Namespace: namespace, Name: "tenant-quota"}, quota); err != nil {
+		t.Fatal(err)
+	}
+	if quota.Annotations == nil {
+		quota.Annotations = map[string]string{}
+	}
+	quota.Annotations[usageSamplesAnnotation] = string(mustMarshal(t, samples))
+	quota.Status.Used = used
+	// ResourceQuota status isn't a subresource in the fake client
+	if err := c.Update(context.Background(), quota); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// quotaSamples returns the usage samples stored on the tenant-quota of namespace
+func quotaSamples(t *testing.T, c client.Client, namespace string) []usageSample {
+	t.Helper()
+	quota := &corev1.ResourceQuota{}
+	if err := c.Get(context.Background(), client.ObjectKey{Namespace: namespace, Name: "tenant-quota"}, quota); err != nil {
+		t.Fatal(err)
+	}
+	var samples []usageSample
+	if err := json.Unmarshal([]byte(quota.Annotations[usageSamplesAnnotation]), &samples); err != nil {
+		t.Fatal(err)
+	}
+	return samples
+}
+
+func TestReconcileQuotaRecommendation(t *testing.T) {
+	c := newReconcilerClient(newTenant("search", "search-team"))
+	r := newTestReconciler(c)
+	r.QuotaHeadroomPercent = 20
+	reconcileTenant(t, r, "search")
+
+	// A day of synthetic samples, the last one due for a successor
+	start := time.Now().Add(-24 * time.Hour)
+	var samples []usageSample
+	for i := 0; i < 24; i++ {
+		samples = append(samples, usageSample{
+			Time:        start.Add(time.Duration(i) * time.Hour).Unix(),
+			MilliCPU:    int64(200 + 50*i),
+			MemoryBytes: mi(int64(100 + 10*i)),
+		})
+	}
+	used := corev1.ResourceList{
+		corev1.ResourceLimitsCPU:    resource.MustParse("3"),
+		corev1.ResourceLimitsMemory: resource.MustParse("200Mi"),
+	}
+	setQuotaSamples(t, c, "search", samples, used)
+	reconcileTenant(t, r, "search")
+
+	if got := quotaSamples(t, c, "search"); len(got) != 25 || got[24].MilliCPU != 3000 || got[24].MemoryBytes != mi(200) {
+		t.Fatalf("%d samples stored, last %+v, want the current usage appended", len(got), got[len(got)-1])
+	}
+	// Peak CPU is the new sample, 3000m, peak memory the last old one, 330Mi
+	want := platformv1alpha1.RecommendedQuota{CPU: "3600m", Memory: "448Mi", Samples: 25}
+	if got := storedTenant(t, c, "search").Status.RecommendedQuota; got == nil || *got != want {
+		t.Fatalf("status.recommendedQuota = %+v, want %+v", got, want)
+	}
+
+	// No new sample within usageSampleInterval of the last
+	reconcileTenant(t, r, "search")
+	if got := quotaSamples(t, c, "search"); len(got) != 25 {
+		t.Fatalf("%d samples after an immediate reconcile, want 25", len(got))
+	}
+
+	// The recommendation reaches the inventory
+	s := &InventoryServer{Reader: c}
+	w, inventory := getInventory(t, s, "")
+	if w.Code != http.StatusOK || len(inventory.Items) != 1 {
+		t.Fatalf("inventory = %d %+v", w.Code, inventory)
+	}
+	if got := inventory.Items[0].RecommendedQuota; got == nil || *got != want {
+		t.Fatalf("inventory recommendedQuota = %+v, want %+v", got, want)
+	}
+}
+
+func TestQuotaRecommendationKeepsAWeek(t *testing.T) {
+	c := newReconcilerClient(newTenant("search", "search-team"))
+	r := newTestReconciler(c)
+	reconcileTenant(t, r, "search")
+
+	old := time.Now().Add(-time.Hour).Unix()
+	samples := make([]usageSample, maxUsageSamples)
+	for i := range samples {
+		samples[i] = usageSample{Time: old, MilliCPU: int64(i)}
+	}
+	setQuotaSamples(t, c, "search", samples, nil)
+	reconcileTenant(t, r, "search")
+
+	got := quotaSamples(t, c, "search")
+	if len(got) != maxUsageSamples || got[0].MilliCPU != 1 {
+		t.Fatalf("%d samples, first %+v, want the oldest dropped", len(got), got[0])
+	}
+}
+
+func TestQuotaRecommendationRecoversFromMangledSamples(t *testing.T) {
+	c := newReconcilerClient(newTenant("search", "search-team"))
+	r := newTestReconciler(c)
+	reconcileTenant(t, r, "search")
+
+	quota := &corev1.ResourceQuota{}
+	if err := c.Get(context.Background(), client.ObjectKey{Namespace: "search", Name: "tenant-quota"}, quota); err != nil {
+		t.Fatal(err)
+	}
+	quota.Annotations[usageSamplesAnnotation] = "not json"
+	if err := c.Update(context.Background(), quota); err != nil {
+		t.Fatal(err)
+	}
+	reconcileTenant(t, r, "search")
+
+	if got := quotaSamples(t, c, "search"); len(got) != 1 {
+		t.Fatalf("samples after a mangled annotation = %+v, want a fresh one", got)
+	}
+}
@@ -0,0 +1,91 @@
+// Data residency
+// Spec.Placement.RequiredRegion and Cloud keep a tenant's data in one region
+// or on one provider. They filter the clusters the placement selects by the
+// platform.xyz.com/region and platform.xyz.com/provider labels, set from a
+// Cluster's spec or carried by a legacy kubeconfig Secret, so a cluster that
+// doesn't say where it runs never qualifies. Clusters named in the placement
+// that violate the constraints are refused, and the tenant's
+// PlacementViolation condition reports them, or that no cluster qualifies.
+
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+
+	platformv1alpha1 "github.com/xyz-company/tenant-operator/api/v1alpha1"
+)
+
+// ConditionPlacementViolation is True while the tenant's residency
+// constraints refuse a cluster it names, or leave it no cluster at all
+const ConditionPlacementViolation = "PlacementViolation"
+
+// PlacementViolation condition reasons
+const (
+	ReasonResidencySatisfied   = "ResidencySatisfied"
+	ReasonResidencyViolation   = "ResidencyViolation"
+	ReasonNoQualifyingClusters = "NoQualifyingClusters"
+)
+
+// hasResidency reports whether placement constrains where the tenant runs
+func hasResidency(placement *platformv1alpha1.TenantPlacement) bool {
+	return placement != nil && (placement.RequiredRegion != "" || placement.Cloud != "")
+}
+
+// residencyViolation describes how a cluster labelled clusterLabels
+// violates placement's residency constraints, "" if it meets them
+func residencyViolation(placement *platformv1alpha1.TenantPlacement, clusterLabels labels.Set) string {
+	if region := placement.RequiredRegion; region != "" && clusterLabels[clusterRegionLabel] != region {
+		return fmt.Sprintf("not in region %s", region)
+	}
+	if cloud := placement.Cloud; cloud != "" && clusterLabels[clusterProviderLabel] != cloud {
+		return fmt.Sprintf("not on %s", cloud)
+	}
+	return ""
+}
+
+// residencyDescription returns the constraints of placement, for messages
+func residencyDescription(placement *platformv1alpha1.TenantPlacement) string {
+	var constraints []string
+	if placement.RequiredRegion != "" {
+		constraints = append(constraints, "region "+placement.RequiredRegion)
+	}
+	if placement.Cloud != "" {
+		constraints = append(constraints, "cloud "+placement.Cloud)
+	}
+	return strings.Join(constraints, " and ")
+}
+
+// setPlacementCondition sets PlacementViolation from the number of clusters
+// the tenant is placed in and the named clusters its residency constraints
+// refused, each with the reason. Tenants without constraints have no such condition.
+func setPlacementCondition(tenant *platformv1alpha1.Tenant, placed int, refused []string) {
+	placement := tenant.Spec.Placement
+	if !hasResidency(placement) {
+		meta.RemoveStatusCondition(&tenant.Status.Conditions, ConditionPlacementViolation)
+		return
+	}
+
+	condition := metav1.Condition{
+		Type:               ConditionPlacementViolation,
+		Status:             metav1.ConditionFalse,
+		Reason:             ReasonResidencySatisfied,
+		Message:            fmt.Sprintf("Placed in %d clusters meeting %s", placed, residencyDescription(placement)),
+		ObservedGeneration: tenant.Generation,
+	}
+	switch {
+	case len(refused) > 0:
+		condition.Status = metav1.ConditionTrue
+		condition.Reason = ReasonResidencyViolation
+		condition.Message = "Refused clusters violating data residency: " + strings.Join(refused, ", ")
+	case placed == 0:
+		condition.Status = metav1.ConditionTrue
+		condition.Reason = ReasonNoQualifyingClusters
+		condition.Message = fmt.Sprintf("No target cluster selected by the placement meets %s", residencyDescription(placement))
+	}
+	meta.SetStatusCondition(&tenant.Status.Conditions, condition)
+}
@@ -0,0 +1,92 @@
+// RoleBinding escalation guard
+// Tenants allowed to manage RoleBindings could bind powerful ClusterRoles,
+// such as cluster-admin, within their namespaces. The RoleBinding
+// validating webhook only admits RoleBindings in tenant namespaces that
+// bind a Role, a ClusterRole the operator itself binds for Spec.Access, or
+// one the platform lists in the --bindable-cluster-roles ConfigMap.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+// RoleBindingValidator validates RoleBinding admission requests in tenant
+// namespaces
+type RoleBindingValidator struct {
+	Decoder *admission.Decoder
+	// Reader reads the BindableClusterRoles ConfigMap straight from the API
+	// server, as the operator only caches ConfigMaps of the monitoring
+	// namespace
+	Reader client.Reader
+	// BindableClusterRoles names the ConfigMap whose keys are the further
+	// ClusterRoles tenant RoleBindings may bind
+	BindableClusterRoles types.NamespacedName
+}
+
+// Handle rejects RoleBindings binding a ClusterRole outside the allowlist.
+// The role of a RoleBinding can't change, so only creates are checked.
+func (v *RoleBindingValidator) Handle(ctx context.Context, req admission.Request) admission.Response {
+	if req.Operation != admissionv1.Create {
+		return admission.Allowed("")
+	}
+	binding := &rbacv1.RoleBinding{}
+	if err := v.Decoder.Decode(req, binding); err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+	if binding.RoleRef.Kind != "ClusterRole" {
+		return admission.Allowed("")
+	}
+
+	allowed, err := v.bindableClusterRoles(ctx)
+	if err != nil {
+		return admission.Errored(http.StatusInternalServerError, err)
+	}
+	if allowed[binding.RoleRef.Name] {
+		return admission.Allowed("")
+	}
+	names := make([]string, 0, len(allowed))
+	for name := range allowed {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return admission.Denied(fmt.Sprintf("RoleBindings in tenant namespaces may only bind the ClusterRoles %s, not %q; ask the platform team to add it to ConfigMap %s",
+		strings.Join(names, ", "), binding.RoleRef.Name, v.BindableClusterRoles))
+}
+
+// bindableClusterRoles returns the ClusterRoles tenant RoleBindings may
+// bind: those of the tenant roles and the keys of the BindableClusterRoles
+// ConfigMap, if it exists
+func (v *RoleBindingValidator) bindableClusterRoles(ctx context.Context) (map[string]bool, error) {
+	allowed := map[string]bool{}
+	for _, role := range tenantRoles {
+		allowed[role.clusterRole] = true
+	}
+	if v.BindableClusterRoles.Name == "" {
+		return allowed, nil
+	}
+
+	cm := &corev1.ConfigMap{}
+	if err := v.Reader.Get(ctx, v.BindableClusterRoles, cm); err != nil {
+		if errors.IsNotFound(err) {
+			return allowed, nil
+		}
+		return nil, err
+	}
+	for name := range cm.Data {
+		allowed[name] = true
+	}
+	return allowed, nil
+}
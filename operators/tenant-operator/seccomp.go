@@ -0,0 +1,76 @@
+// Seccomp defaulting
+// The pod mutating webhook gives pods in tenant namespaces a RuntimeDefault
+// seccomp profile when their Tenant sets requireSeccomp
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+// PodSeccompDefaulter mutates pod admission requests in tenant namespaces
+type PodSeccompDefaulter struct {
+	Client  client.Client
+	Decoder *admission.Decoder
+}
+
+// Handle sets a pod-level RuntimeDefault seccomp profile on new pods whose
+// Tenant requires one and that don't already set a pod-level profile
+func (d *PodSeccompDefaulter) Handle(ctx context.Context, req admission.Request) admission.Response {
+	if req.Operation != admissionv1.Create {
+		return admission.Allowed("")
+	}
+
+	required, err := d.seccompRequired(ctx, req.Namespace)
+	if err != nil {
+		return admission.Errored(http.StatusInternalServerError, err)
+	}
+	if !required {
+		return admission.Allowed("")
+	}
+
+	pod := &corev1.Pod{}
+	if err := d.Decoder.Decode(req, pod); err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+	if !defaultSeccompProfile(pod) {
+		return admission.Allowed("")
+	}
+
+	raw, err := json.Marshal(pod)
+	if err != nil {
+		return admission.Errored(http.StatusInternalServerError, err)
+	}
+	return admission.PatchResponseFromRaw(req.Object.Raw, raw)
+}
+
+// seccompRequired reports whether the Tenant owning namespace sets requireSeccomp
+func (d *PodSeccompDefaulter) seccompRequired(ctx context.Context, namespace string) (bool, error) {
+	tenant, err := tenantForNamespace(ctx, d.Client, namespace)
+	if err != nil || tenant == nil {
+		return false, err
+	}
+	return tenant.Spec.RequireSeccomp, nil
+}
+
+// defaultSeccompProfile sets a RuntimeDefault pod-level seccomp profile if
+// none is set, and reports whether the pod changed. Container-level profiles
+// are left alone and still take precedence.
+func defaultSeccompProfile(pod *corev1.Pod) bool {
+	if pod.Spec.SecurityContext != nil && pod.Spec.SecurityContext.SeccompProfile != nil {
+		return false
+	}
+
+	if pod.Spec.SecurityContext == nil {
+		pod.Spec.SecurityContext = &corev1.PodSecurityContext{}
+	}
+	pod.Spec.SecurityContext.SeccompProfile = &corev1.SeccompProfile{Type: corev1.SeccompProfileTypeRuntimeDefault}
+	return true
+}
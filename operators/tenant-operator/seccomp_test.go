@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+// labelledNamespace returns the namespace name labelled as belonging to tenant
+func labelledNamespace(name, tenant string) *corev1.Namespace {
+	return &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+		Name:   name,
+		Labels: map[string]string{tenantLabel: tenant},
+	}}
+}
+
+// seccompDefaulter returns a PodSeccompDefaulter for a "search" Tenant
+// owning the namespace "search" and setting requireSeccomp to required
+func seccompDefaulter(required bool) *PodSeccompDefaulter {
+	tenant := newTenant("search", "search-team")
+	tenant.Spec.RequireSeccomp = required
+	return &PodSeccompDefaulter{
+		Client:  newFakeClient(tenant, labelledNamespace("search", "search")),
+		Decoder: admission.NewDecoder(scheme),
+	}
+}
+
+// newPod returns a pod in namespace with securityContext
+func newPod(namespace string, securityContext *corev1.PodSecurityContext) *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: "web"},
+		Spec: corev1.PodSpec{
+			SecurityContext: securityContext,
+			Containers:      []corev1.Container{{Name: "web", Image: "nginx"}},
+		},
+	}
+}
+
+func TestSeccompDefaulterInjectsRuntimeDefault(t *testing.T) {
+	tests := []struct {
+		name            string
+		securityContext *corev1.PodSecurityContext
+		wantPatch       string
+		wantValue       string
+	}{
+		{
+			name:      "no security context",
+			wantPatch: "/spec/securityContext",
+			wantValue: `{"seccompProfile":{"type":"RuntimeDefault"}}`,
+		},
+		{
+			name:            "security context without a profile",
+			securityContext: &corev1.PodSecurityContext{RunAsNonRoot: boolPtr(true)},
+			wantPatch:       "/spec/securityContext/seccompProfile",
+			wantValue:       `{"type":"RuntimeDefault"}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pod := newPod("search", tt.securityContext)
+			resp := seccompDefaulter(true).Handle(context.Background(), admissionRequest(t, admissionv1.Create, pod, nil))
+			wantAllowed(t, resp)
+			if len(resp.Patches) != 1 || resp.Patches[0].Operation != "add" || resp.Patches[0].Path != tt.wantPatch {
+				t.Fatalf("patches = %+v, want one add of %s", resp.Patches, tt.wantPatch)
+			}
+			if got := string(mustMarshal(t, resp.Patches[0].Value)); got != tt.wantValue {
+				t.Fatalf("patch value = %s, want %s", got, tt.wantValue)
+			}
+		})
+	}
+}
+
+func TestSeccompDefaulterNoOp(t *testing.T) {
+	localhost := "profiles/audit.json"
+	tests := []struct {
+		name     string
+		required bool
+		op       admissionv1.Operation
+		pod      *corev1.Pod
+	}{
+		{
+			name:     "profile already set",
+			required: true,
+			op:       admissionv1.Create,
+			pod: newPod("search", &corev1.PodSecurityContext{SeccompProfile: &corev1.SeccompProfile{
+				Type:             corev1.SeccompProfileTypeLocalhost,
+				LocalhostProfile: &localhost,
+			}}),
+		},
+		{name: "not required", op: admissionv1.Create, pod: newPod("search", nil)},
+		{name: "update", required: true, op: admissionv1.Update, pod: newPod("search", nil)},
+		{name: "not a tenant namespace", required: true, op: admissionv1.Create, pod: newPod("kube-system", nil)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := admissionRequest(t, tt.op, tt.pod, nil)
+			resp := seccompDefaulter(tt.required).Handle(context.Background(), req)
+			wantAllowed(t, resp)
+			if len(resp.Patches) != 0 {
+				t.Fatalf("patches = %+v, want none", resp.Patches)
+			}
+		})
+	}
+}
+
+func TestDefaultSeccompProfileKeepsContainerProfiles(t *testing.T) {
+	pod := newPod("search", nil)
+	pod.Spec.Containers[0].SecurityContext = &corev1.SecurityContext{
+		SeccompProfile: &corev1.SeccompProfile{Type: corev1.SeccompProfileTypeUnconfined},
+	}
+	if !defaultSeccompProfile(pod) {
+		t.Fatal("pod-level profile not set")
+	}
+	if got := pod.Spec.Containers[0].SecurityContext.SeccompProfile.Type; got != corev1.SeccompProfileTypeUnconfined {
+		t.Fatalf("container profile = %s, want it left Unconfined", got)
+	}
+}
@@ -0,0 +1,290 @@
+// Secrets backend
+// Spec.SecretsBackend gives each tenant namespace an External Secrets
+// SecretStore backed by the platform Vault. The store logs in through
+// Vault's Kubernetes auth as a ServiceAccount of the namespace, under a
+// Vault role whose policy only lets it read the tenant's path prefix, so
+// tenants pull their own secrets with ExternalSecrets and nobody else's.
+// With a Vault token (--vault-token-file) the operator writes the role and
+// policy itself; otherwise the platform team provisions them.
+// External Secrets is optional, so the SecretStore is skipped when its CRD
+// is missing.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	platformv1alpha1 "github.com/xyz-company/tenant-operator/api/v1alpha1"
+)
+
+var secretStoreGVK = schema.GroupVersionKind{Group: "external-secrets.io", Version: "v1beta1", Kind: "SecretStore"}
+
+// secretsBackendName names the SecretStore in each tenant namespace and the
+// ServiceAccount it logs in to Vault as
+const secretsBackendName = "platform-vault"
+
+// VaultClient provisions the Vault Kubernetes auth role and policy of each
+// tenant with a secrets backend
+type VaultClient struct {
+	// Address is the Vault server, for the operator and the SecretStores
+	Address string
+	// TokenFile holds the operator's Vault token. It is re-read on every
+	// request, so a Vault agent can renew it. Empty leaves roles and
+	// policies to the platform team.
+	TokenFile string
+	// AuthMount is the path of the Kubernetes auth method
+	AuthMount string
+	// KVMount is the path of the KV version 2 secrets engine
+	KVMount string
+	// PathPrefix is where the tenant trees live in KVMount
+	PathPrefix string
+	Client     *http.Client
+}
+
+// vaultRole returns the name of tenant's Vault role and policy
+func vaultRole(tenant *platformv1alpha1.Tenant) string {
+	return "tenant-" + tenant.Name
+}
+
+// tenantTree returns the root of tenant's tree in the KV mount
+func (v *VaultClient) tenantTree(tenant *platformv1alpha1.Tenant) string {
+	return path.Join(v.PathPrefix, tenant.Name)
+}
+
+// secretsPathPrefix returns the path prefix tenant's namespaces may read:
+// Spec.SecretsBackend.PathPrefix, or the whole tenant tree
+func (v *VaultClient) secretsPathPrefix(tenant *platformv1alpha1.Tenant) string {
+	if prefix := tenant.Spec.SecretsBackend.PathPrefix; prefix != "" {
+		return prefix
+	}
+	return v.tenantTree(tenant)
+}
+
+// validateSecretsBackend rejects path prefixes outside the tenant's own
+// tree, which would expose other tenants' secrets
+func (v *VaultClient) validateSecretsBackend(tenant *platformv1alpha1.Tenant) error {
+	backend := tenant.Spec.SecretsBackend
+	if backend == nil {
+		return nil
+	}
+	if v == nil {
+		return fmt.Errorf("secretsBackend can't be used: the operator runs without --vault-addr")
+	}
+	if backend.PathPrefix == "" {
+		return nil
+	}
+	tree := v.tenantTree(tenant)
+	if backend.PathPrefix != path.Clean(backend.PathPrefix) || strings.HasPrefix(backend.PathPrefix, "/") {
+		return fmt.Errorf("secretsBackend.pathPrefix %q must be a clean relative path such as %s/app", backend.PathPrefix, tree)
+	}
+	if backend.PathPrefix != tree && !strings.HasPrefix(backend.PathPrefix, tree+"/") {
+		return fmt.Errorf("secretsBackend.pathPrefix %q must lie within the tenant's tree %s", backend.PathPrefix, tree)
+	}
+	return nil
+}
+
+// vaultPolicy returns the HCL policy letting its holder read the secrets
+// below prefix in the KV mount
+func (v *VaultClient) vaultPolicy(prefix string) string {
+	return fmt.Sprintf(`path "%[1]s/data/%[2]s/*" {
+  capabilities = ["read"]
+}
+path "%[1]s/metadata/%[2]s/*" {
+  capabilities = ["read", "list"]
+}
+`, v.KVMount, prefix)
+}
+
+// WriteRole writes tenant's policy and the Kubernetes auth role granting it
+// to the secrets backend ServiceAccount of namespaces
+func (v *VaultClient) WriteRole(ctx context.Context, tenant *platformv1alpha1.Tenant, namespaces []string) error {
+	name := vaultRole(tenant)
+	if err := v.do(ctx, http.MethodPut, "sys/policies/acl/"+name, map[string]interface{}{
+		"policy": v.vaultPolicy(v.secretsPathPrefix(tenant)),
+	}); err != nil {
+		return err
+	}
+	return v.do(ctx, http.MethodPost, "auth/"+v.AuthMount+"/role/"+name, map[string]interface{}{
+		"bound_service_account_names":      []string{secretsBackendName},
+		"bound_service_account_namespaces": namespaces,
+		"token_policies":                   []string{name},
+	})
+}
+
+// DeleteRole deletes tenant's Kubernetes auth role and policy. Vault
+// answers deletes of missing ones with success.
+func (v *VaultClient) DeleteRole(ctx context.Context, tenant *platformv1alpha1.Tenant) error {
+	name := vaultRole(tenant)
+	if err := v.do(ctx, http.MethodDelete, "auth/"+v.AuthMount+"/role/"+name, nil); err != nil {
+		return err
+	}
+	return v.do(ctx, http.MethodDelete, "sys/policies/acl/"+name, nil)
+}
+
+// do sends a request with body, if any, to the Vault API at apiPath
+func (v *VaultClient) do(ctx context.Context, method, apiPath string, body interface{}) error {
+	token, err := os.ReadFile(v.TokenFile)
+	if err != nil {
+		return fmt.Errorf("reading Vault token: %w", err)
+	}
+
+	var payload []byte
+	if body != nil {
+		if payload, err = json.Marshal(body); err != nil {
+			return err
+		}
+	}
+	req, err := http.NewRequestWithContext(ctx, method, strings.TrimSuffix(v.Address, "/")+"/v1/"+apiPath, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("X-Vault-Token", string(bytes.TrimSpace(token)))
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := v.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("vault %s %s returned %s", method, apiPath, resp.Status)
+	}
+	return nil
+}
+
+// reconcileSecretsBackend applies the SecretStore of namespace and the
+// ServiceAccount it logs in as, or deletes both once the tenant no longer
+// has a secrets backend
+func (r *TenantReconciler) reconcileSecretsBackend(ctx context.Context, tenant *platformv1alpha1.Tenant, namespace string) error {
+	log := ctrl.LoggerFrom(ctx)
+	installed, err := r.kindInstalled(secretStoreGVK)
+	if err != nil {
+		return err
+	}
+
+	store := r.secretStore(tenant, namespace)
+	sa := &corev1.ServiceAccount{
+		ObjectMeta: metav1.ObjectMeta{Name: secretsBackendName, Namespace: namespace},
+	}
+	if tenant.Spec.SecretsBackend == nil {
+		objects := []client.Object{sa}
+		if installed {
+			objects = append(objects, store)
+		}
+		for _, object := range objects {
+			if err := r.deleteIfControlled(ctx, tenant, object); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if !installed {
+		log.Info("External Secrets CRD not installed, skipping SecretStore", "namespace", namespace)
+		return nil
+	}
+	if err := r.applyOrAdopt(ctx, tenant, sa); err != nil {
+		return err
+	}
+	if err := r.applyOrAdopt(ctx, tenant, store); err != nil {
+		return err
+	}
+	log.Info("SecretStore applied", "namespace", namespace, "secretStore", store.GetName())
+	return nil
+}
+
+// secretStore returns the SecretStore of namespace, reading the tenant's
+// Vault tree as the secrets backend ServiceAccount under its Vault role
+func (r *TenantReconciler) secretStore(tenant *platformv1alpha1.Tenant, namespace string) *unstructured.Unstructured {
+	var vault map[string]interface{}
+	if r.Vault != nil {
+		vault = map[string]interface{}{
+			"server":  r.Vault.Address,
+			"path":    r.Vault.KVMount,
+			"version": "v2",
+			"auth": map[string]interface{}{
+				"kubernetes": map[string]interface{}{
+					"mountPath": r.Vault.AuthMount,
+					"role":      vaultRole(tenant),
+					"serviceAccountRef": map[string]interface{}{
+						"name": secretsBackendName,
+					},
+				},
+			},
+		}
+	}
+
+	store := &unstructured.Unstructured{Object: map[string]interface{}{
+		"spec": map[string]interface{}{
+			"provider": map[string]interface{}{"vault": vault},
+		},
+	}}
+	store.SetGroupVersionKind(secretStoreGVK)
+	store.SetNamespace(namespace)
+	store.SetName(secretsBackendName)
+	return store
+}
+
+// deleteIfControlled deletes object unless it is missing or the tenant
+// didn't create it
+func (r *TenantReconciler) deleteIfControlled(ctx context.Context, tenant *platformv1alpha1.Tenant, object client.Object) error {
+	err := r.Get(ctx, types.NamespacedName{Namespace: object.GetNamespace(), Name: object.GetName()}, object)
+	switch {
+	case errors.IsNotFound(err):
+		return nil
+	case err != nil:
+		return err
+	case !metav1.IsControlledBy(object, tenant):
+		return nil
+	}
+	return client.IgnoreNotFound(r.Delete(ctx, object))
+}
+
+// reconcileVaultRole writes tenant's Vault role and policy for namespaces,
+// or deletes them once the tenant no longer has a secrets backend.
+// Status.VaultRole records the role written, so only tenants that had one
+// call Vault to delete it.
+func (r *TenantReconciler) reconcileVaultRole(ctx context.Context, tenant *platformv1alpha1.Tenant, namespaces []string) error {
+	if r.Vault == nil || r.Vault.TokenFile == "" {
+		return nil
+	}
+	if tenant.Spec.SecretsBackend == nil {
+		if tenant.Status.VaultRole == "" {
+			return nil
+		}
+		if err := r.Vault.DeleteRole(ctx, tenant); err != nil {
+			return err
+		}
+		ctrl.LoggerFrom(ctx).Info("Deleted Vault role", "role", tenant.Status.VaultRole)
+		tenant.Status.VaultRole = ""
+		return nil
+	}
+
+	if err := r.Vault.WriteRole(ctx, tenant, namespaces); err != nil {
+		return err
+	}
+	if tenant.Status.VaultRole == "" {
+		r.Recorder.Eventf(tenant, corev1.EventTypeNormal, "VaultRoleCreated", "Created Vault role %s reading %s/%s",
+			vaultRole(tenant), r.Vault.KVMount, r.Vault.secretsPathPrefix(tenant))
+	}
+	tenant.Status.VaultRole = vaultRole(tenant)
+	return nil
+}
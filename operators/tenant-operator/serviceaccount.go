@@ -0,0 +1,57 @@
+// Default ServiceAccount
+// Tenants can stop the namespace's default ServiceAccount token from being
+// mounted into every pod
+
+package main
+
+import (
+	"context"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	platformv1alpha1 "github.com/xyz-company/tenant-operator/api/v1alpha1"
+)
+
+// reconcileDefaultServiceAccount sets automountServiceAccountToken: false on
+// the namespace's default ServiceAccount when Spec.DisableDefaultSATokenMount
+// is set, reverting any later change. Otherwise the ServiceAccount is left alone.
+func (r *TenantReconciler) reconcileDefaultServiceAccount(ctx context.Context, tenant *platformv1alpha1.Tenant, namespace string) error {
+	if !tenant.Spec.DisableDefaultSATokenMount {
+		return nil
+	}
+
+	sa := &corev1.ServiceAccount{}
+	if err := r.Get(ctx, types.NamespacedName{Namespace: namespace, Name: "default"}, sa); err != nil {
+		// The default ServiceAccount is created asynchronously after the
+		// namespace; its creation triggers another reconcile
+		if errors.IsNotFound(err) {
+			return nil
+		}
+		return err
+	}
+	if sa.AutomountServiceAccountToken != nil && !*sa.AutomountServiceAccountToken {
+		return nil
+	}
+
+	patch := client.MergeFrom(sa.DeepCopy())
+	automount := false
+	sa.AutomountServiceAccountToken = &automount
+	return r.Patch(ctx, sa, patch)
+}
+
+// serviceAccountToTenant maps changes to a namespace's default ServiceAccount
+// to a reconcile of the tenant the namespace belongs to
+func (r *TenantReconciler) serviceAccountToTenant(ctx context.Context, obj client.Object) []reconcile.Request {
+	if obj.GetName() != "default" {
+		return nil
+	}
+	ns := &corev1.Namespace{}
+	if err := r.Get(ctx, types.NamespacedName{Name: obj.GetNamespace()}, ns); err != nil {
+		return nil
+	}
+	return namespaceToTenant(ctx, ns)
+}
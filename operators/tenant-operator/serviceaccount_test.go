@@ -0,0 +1,132 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// defaultServiceAccount is the ServiceAccount the API server creates in
+// namespace, with automount as given
+func defaultServiceAccount(namespace string, automount *bool) *corev1.ServiceAccount {
+	return &corev1.ServiceAccount{
+		ObjectMeta:                   metav1.ObjectMeta{Namespace: namespace, Name: "default"},
+		AutomountServiceAccountToken: automount,
+	}
+}
+
+// storedAutomount returns automountServiceAccountToken of the default
+// ServiceAccount in namespace
+func storedAutomount(t *testing.T, c client.Client, namespace string) *bool {
+	t.Helper()
+	sa := &corev1.ServiceAccount{}
+	if err := c.Get(context.Background(), client.ObjectKey{Namespace: namespace, Name: "default"}, sa); err != nil {
+		t.Fatal(err)
+	}
+	return sa.AutomountServiceAccountToken
+}
+
+func TestDisableDefaultSATokenMount(t *testing.T) {
+	tenant := newTenant("search", "search-team")
+	tenant.Spec.DisableDefaultSATokenMount = true
+	c := newReconcilerClient(tenant, defaultServiceAccount("search", nil))
+	r := newTestReconciler(c)
+	reconcileTenant(t, r, "search")
+
+	if automount := storedAutomount(t, c, "search"); automount == nil || *automount {
+		t.Fatalf("automountServiceAccountToken = %v, want false", automount)
+	}
+
+	// A manual change is reverted on the next reconcile
+	sa := &corev1.ServiceAccount{}
+	if err := c.Get(context.Background(), client.ObjectKey{Namespace: "search", Name: "default"}, sa); err != nil {
+		t.Fatal(err)
+	}
+	enabled := true
+	sa.AutomountServiceAccountToken = &enabled
+	if err := c.Update(context.Background(), sa); err != nil {
+		t.Fatal(err)
+	}
+	reconcileTenant(t, r, "search")
+	if automount := storedAutomount(t, c, "search"); automount == nil || *automount {
+		t.Fatalf("automountServiceAccountToken after a manual change = %v, want false", automount)
+	}
+}
+
+func TestDefaultSATokenMountLeftAlone(t *testing.T) {
+	enabled := true
+	for _, automount := range []*bool{nil, &enabled} {
+		c := newReconcilerClient(newTenant("search", "search-team"), defaultServiceAccount("search", automount))
+		reconcileTenant(t, newTestReconciler(c), "search")
+
+		if got := storedAutomount(t, c, "search"); (got == nil) != (automount == nil) || (got != nil && !*got) {
+			t.Fatalf("automountServiceAccountToken = %v, want it left at %v", got, automount)
+		}
+	}
+}
+
+func TestDisableDefaultSATokenMountBeforeServiceAccount(t *testing.T) {
+	tenant := newTenant("search", "search-team")
+	tenant.Spec.DisableDefaultSATokenMount = true
+	c := newReconcilerClient(tenant)
+
+	// The ServiceAccount's creation triggers the next reconcile
+	reconcileTenant(t, newTestReconciler(c), "search")
+}
+
+func TestServiceAccountToTenant(t *testing.T) {
+	c := newFakeClient(&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+		Name:   "search",
+		Labels: map[string]string{tenantLabel: "search"},
+	}})
+	r := newTestReconciler(c)
+
+	requests := r.serviceAccountToTenant(context.Background(), defaultServiceAccount("search", nil))
+	if len(requests) != 1 || requests[0].Name != "search" {
+		t.Fatalf("default ServiceAccount mapped to %v, want tenant search", requests)
+	}
+	other := defaultServiceAccount("search", nil)
+	other.Name = "builder"
+	if requests := r.serviceAccountToTenant(context.Background(), other); len(requests) != 0 {
+		t.Fatalf("other ServiceAccount mapped to %v, want nothing", requests)
+	}
+	if requests := r.serviceAccountToTenant(context.Background(), defaultServiceAccount("kube-system", nil)); len(requests) != 0 {
+		t.Fatalf("ServiceAccount outside a tenant mapped to %v, want nothing", requests)
+	}
+}
+
+func TestEnvtestDisableDefaultSATokenMount(t *testing.T) {
+	ctx := context.Background()
+	c := envtestClient(t)
+	tenant := newTenant("sa-token", "search-team")
+	tenant.Spec.DisableDefaultSATokenMount = true
+	r := createEnvtestTenant(t, c, tenant)
+	// Nothing runs the ServiceAccount controller, so the first pass finds no
+	// default ServiceAccount and leaves it to the next
+	reconcileTenant(t, r, "sa-token")
+
+	enabled := true
+	if err := c.Create(ctx, defaultServiceAccount("sa-token", &enabled)); err != nil {
+		t.Fatal(err)
+	}
+	reconcileTenant(t, r, "sa-token")
+	if automount := storedAutomount(t, c, "sa-token"); automount == nil || *automount {
+		t.Fatalf("automountServiceAccountToken = %v, want false", automount)
+	}
+
+	sa := &corev1.ServiceAccount{}
+	if err := c.Get(ctx, client.ObjectKey{Namespace: "sa-token", Name: "default"}, sa); err != nil {
+		t.Fatal(err)
+	}
+	sa.AutomountServiceAccountToken = &enabled
+	if err := c.Update(ctx, sa, client.FieldOwner("kubectl-edit")); err != nil {
+		t.Fatal(err)
+	}
+	reconcileTenant(t, r, "sa-token")
+	if automount := storedAutomount(t, c, "sa-token"); automount == nil || *automount {
+		t.Fatalf("automountServiceAccountToken after a manual change = %v, want false", automount)
+	}
+}
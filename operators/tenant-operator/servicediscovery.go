@@ -0,0 +1,359 @@
+// Cross-cluster service discovery
+// Spec.Placement.ServiceDiscovery makes the Services of a tenant spanning
+// clusters resolvable from all of them as
+// <service>.<namespace>.svc.clusterset.local:
+//   - MCS: every Service in the tenant namespaces, here and in the target
+//     clusters, gets a ServiceExport. The MCS implementation imports it
+//     into the same namespaces of the other clusters, so only the tenant's
+//     own namespaces get its ServiceImports.
+//   - Istio: every Service exposed through a LoadBalancer gets a
+//     ServiceEntry in each of the tenant's clusters pointing at its
+//     addresses in all of them, exported to the tenant's namespaces and
+//     those of the tenants integrating with it only.
+//
+// Services annotated platform.xyz.com/export=false stay in their cluster.
+// Target clusters are only reached by the direct propagation backend, and
+// their Services are picked up every serviceDiscoveryResyncInterval.
+
+package main
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	platformv1alpha1 "github.com/xyz-company/tenant-operator/api/v1alpha1"
+)
+
+var (
+	serviceExportGVK = schema.GroupVersionKind{Group: "multicluster.x-k8s.io", Version: "v1alpha1", Kind: "ServiceExport"}
+	serviceEntryGVK  = schema.GroupVersionKind{Group: "networking.istio.io", Version: "v1beta1", Kind: "ServiceEntry"}
+)
+
+// discoveryKinds are the kinds service discovery writes
+var discoveryKinds = []schema.GroupVersionKind{serviceExportGVK, serviceEntryGVK}
+
+const (
+	// serviceExportAnnotation set to "false" keeps a Service out of service
+	// discovery
+	serviceExportAnnotation = "platform.xyz.com/export"
+	// clustersetDomain is the MCS domain Services resolve under across
+	// clusters
+	clustersetDomain = "svc.clusterset.local"
+	// serviceDiscoveryResyncInterval is how often a tenant with service
+	// discovery looks for new Services in its target clusters, which aren't
+	// watched
+	serviceDiscoveryResyncInterval = 5 * time.Minute
+)
+
+// istioProtocols are the app protocols a ServiceEntry port can carry;
+// others are proxied as TCP
+var istioProtocols = map[string]bool{"http": true, "https": true, "http2": true, "grpc": true, "tls": true, "tcp": true}
+
+// localClusterName keys this cluster's Services among the target clusters'
+const localClusterName = ""
+
+// tenantServiceDiscovery returns the tenant's service discovery mode, ""
+// without one
+func tenantServiceDiscovery(tenant *platformv1alpha1.Tenant) platformv1alpha1.ServiceDiscoveryMode {
+	if tenant.Spec.Placement == nil {
+		return ""
+	}
+	return tenant.Spec.Placement.ServiceDiscovery
+}
+
+// reconcileServiceDiscovery publishes the Services in the tenant's
+// namespaces across this cluster and the target clusters reached, and
+// removes what it published once they are gone or the tenant no longer has
+// service discovery. A target cluster that fails is marked not ready in
+// statuses; errors in this cluster are returned. It returns when to look
+// for new Services in the target clusters, 0 without service discovery.
+func (r *TenantReconciler) reconcileServiceDiscovery(ctx context.Context, tenant *platformv1alpha1.Tenant, namespaces []string, reached []targetCluster, statuses []platformv1alpha1.ClusterStatus) (time.Duration, error) {
+	log := ctrl.LoggerFrom(ctx)
+	mode := tenantServiceDiscovery(tenant)
+
+	services := map[string][]corev1.Service{}
+	failed := map[string]error{}
+	var exportTo []string
+	if mode != "" {
+		local, err := exportedServices(ctx, r.APIReader, namespaces)
+		if err != nil {
+			return 0, err
+		}
+		services[localClusterName] = local
+		for _, target := range reached {
+			if services[target.name], err = exportedServices(ctx, target.client, namespaces); err != nil {
+				failed[target.name] = err
+			}
+		}
+		integrating, err := r.integratingNamespaces(ctx, tenant)
+		if err != nil {
+			return 0, err
+		}
+		exportTo = append(knownNamespaces(tenant), integrating...)
+	}
+
+	local := discoveryObjects(mode, localClusterName, services, exportTo)
+	if err := r.syncLocalDiscovery(ctx, tenant, namespaces, local); err != nil {
+		return 0, err
+	}
+	for _, target := range reached {
+		if failed[target.name] != nil {
+			continue
+		}
+		desired := discoveryObjects(mode, target.name, services, exportTo)
+		if err := syncRemoteDiscovery(ctx, target.client, tenant, namespaces, desired); err != nil {
+			failed[target.name] = err
+		}
+	}
+
+	for i := range statuses {
+		if err := failed[statuses[i].Name]; err != nil {
+			log.Error(err, "Failed to publish tenant Services in target cluster", "cluster", statuses[i].Name)
+			statuses[i] = withTransitionTime(platformv1alpha1.ClusterStatus{
+				Name:    statuses[i].Name,
+				Reason:  "ServiceDiscoveryFailed",
+				Message: err.Error(),
+			}, tenant.Status.Clusters)
+		}
+	}
+	if mode == "" {
+		return 0, nil
+	}
+	return serviceDiscoveryResyncInterval, nil
+}
+
+// exportedServices returns the Services in namespaces not opted out of
+// service discovery
+func exportedServices(ctx context.Context, reader client.Reader, namespaces []string) ([]corev1.Service, error) {
+	var services []corev1.Service
+	for _, namespace := range namespaces {
+		list := &corev1.ServiceList{}
+		if err := reader.List(ctx, list, client.InNamespace(namespace)); err != nil {
+			return nil, err
+		}
+		for _, service := range list.Items {
+			if service.Annotations[serviceExportAnnotation] != "false" {
+				services = append(services, service)
+			}
+		}
+	}
+	return services, nil
+}
+
+// discoveryObjects returns what cluster needs for the tenant's Services in
+// mode, given the Services of each of the tenant's clusters: a
+// ServiceExport per Service of its own for MCS, or a ServiceEntry per
+// Service any of them exposes for Istio, visible to exportTo
+func discoveryObjects(mode platformv1alpha1.ServiceDiscoveryMode, cluster string, services map[string][]corev1.Service, exportTo []string) []*unstructured.Unstructured {
+	var objects []*unstructured.Unstructured
+	switch mode {
+	case platformv1alpha1.ServiceDiscoveryMCS:
+		for _, service := range services[cluster] {
+			export := &unstructured.Unstructured{Object: map[string]interface{}{}}
+			export.SetGroupVersionKind(serviceExportGVK)
+			export.SetNamespace(service.Namespace)
+			export.SetName(service.Name)
+			objects = append(objects, export)
+		}
+
+	case platformv1alpha1.ServiceDiscoveryIstio:
+		// The same Service in several clusters gets one ServiceEntry with
+		// the addresses of all of them
+		clusters := make([]string, 0, len(services))
+		for name := range services {
+			clusters = append(clusters, name)
+		}
+		sort.Strings(clusters)
+		exposed := map[types.NamespacedName]*corev1.Service{}
+		addresses := map[types.NamespacedName][]string{}
+		for _, name := range clusters {
+			for i := range services[name] {
+				service := &services[name][i]
+				key := types.NamespacedName{Namespace: service.Namespace, Name: service.Name}
+				for _, ingress := range service.Status.LoadBalancer.Ingress {
+					address := ingress.IP
+					if address == "" {
+						address = ingress.Hostname
+					}
+					if address == "" {
+						continue
+					}
+					if exposed[key] == nil {
+						exposed[key] = service
+					}
+					addresses[key] = append(addresses[key], address)
+				}
+			}
+		}
+		keys := make([]types.NamespacedName, 0, len(exposed))
+		for key := range exposed {
+			keys = append(keys, key)
+		}
+		sort.Slice(keys, func(i, j int) bool { return keys[i].String() < keys[j].String() })
+		for _, key := range keys {
+			if entry := serviceEntry(exposed[key], addresses[key], exportTo); entry != nil {
+				objects = append(objects, entry)
+			}
+		}
+	}
+	return objects
+}
+
+// serviceEntry returns the ServiceEntry resolving service's clusterset
+// name to addresses, for its TCP ports and only in the namespaces of
+// exportTo. It returns nil for Services without a TCP port.
+func serviceEntry(service *corev1.Service, addresses []string, exportTo []string) *unstructured.Unstructured {
+	var ports []interface{}
+	for _, port := range service.Spec.Ports {
+		if port.Protocol != "" && port.Protocol != corev1.ProtocolTCP {
+			continue
+		}
+		name := port.Name
+		if name == "" {
+			name = "tcp-" + strconv.Itoa(int(port.Port))
+		}
+		protocol := "TCP"
+		if port.AppProtocol != nil && istioProtocols[strings.ToLower(*port.AppProtocol)] {
+			protocol = strings.ToUpper(*port.AppProtocol)
+		}
+		ports = append(ports, map[string]interface{}{
+			"number":   int64(port.Port),
+			"name":     name,
+			"protocol": protocol,
+		})
+	}
+	if len(ports) == 0 {
+		return nil
+	}
+
+	endpoints := make([]interface{}, 0, len(addresses))
+	for _, address := range addresses {
+		endpoints = append(endpoints, map[string]interface{}{"address": address})
+	}
+	seen := map[string]bool{}
+	namespaces := []interface{}{}
+	for _, namespace := range exportTo {
+		if !seen[namespace] {
+			seen[namespace] = true
+			namespaces = append(namespaces, namespace)
+		}
+	}
+
+	entry := &unstructured.Unstructured{Object: map[string]interface{}{
+		"spec": map[string]interface{}{
+			"hosts":      []interface{}{service.Name + "." + service.Namespace + "." + clustersetDomain},
+			"location":   "MESH_INTERNAL",
+			"resolution": "DNS",
+			"ports":      ports,
+			"endpoints":  endpoints,
+			"exportTo":   namespaces,
+		},
+	}}
+	entry.SetGroupVersionKind(serviceEntryGVK)
+	entry.SetNamespace(service.Namespace)
+	entry.SetName("clusterset-" + service.Name)
+	return entry
+}
+
+// syncLocalDiscovery applies desired in this cluster and deletes the
+// tenant's ServiceExports and ServiceEntries in namespaces that aren't.
+// Kinds whose CRD isn't installed are skipped.
+func (r *TenantReconciler) syncLocalDiscovery(ctx context.Context, tenant *platformv1alpha1.Tenant, namespaces []string, desired []*unstructured.Unstructured) error {
+	log := ctrl.LoggerFrom(ctx)
+	for _, gvk := range discoveryKinds {
+		installed, err := r.kindInstalled(gvk)
+		if err != nil {
+			return err
+		}
+		if !installed {
+			if len(ofKind(desired, gvk)) > 0 {
+				log.Info("CRD not installed, skipping service discovery", "kind", gvk.Kind)
+			}
+			continue
+		}
+		stale, err := staleObjects(ctx, r.Client, tenant, namespaces, gvk, desired)
+		if err != nil {
+			return err
+		}
+		for _, obj := range stale {
+			if err := r.deleteIfControlled(ctx, tenant, obj); err != nil {
+				return err
+			}
+		}
+		for _, obj := range ofKind(desired, gvk) {
+			if err := r.applyOrAdopt(ctx, tenant, obj); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// syncRemoteDiscovery applies desired in a target cluster and deletes the
+// tenant's ServiceExports and ServiceEntries in namespaces that aren't.
+// A missing CRD only fails the cluster when desired needs it.
+func syncRemoteDiscovery(ctx context.Context, c client.Client, tenant *platformv1alpha1.Tenant, namespaces []string, desired []*unstructured.Unstructured) error {
+	for _, gvk := range discoveryKinds {
+		stale, err := staleObjects(ctx, c, tenant, namespaces, gvk, desired)
+		if err != nil && !meta.IsNoMatchError(err) {
+			return err
+		}
+		for _, obj := range stale {
+			if err := client.IgnoreNotFound(c.Delete(ctx, obj)); err != nil {
+				return err
+			}
+		}
+		for _, obj := range ofKind(desired, gvk) {
+			if err := applyRemote(ctx, c, tenant, obj); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// staleObjects returns the objects of kind gvk labelled for the
+// tenant in namespaces that desired doesn't have
+func staleObjects(ctx context.Context, reader client.Reader, tenant *platformv1alpha1.Tenant, namespaces []string, gvk schema.GroupVersionKind, desired []*unstructured.Unstructured) ([]*unstructured.Unstructured, error) {
+	keep := map[types.NamespacedName]bool{}
+	for _, obj := range ofKind(desired, gvk) {
+		keep[types.NamespacedName{Namespace: obj.GetNamespace(), Name: obj.GetName()}] = true
+	}
+	var stale []*unstructured.Unstructured
+	for _, namespace := range namespaces {
+		list := &unstructured.UnstructuredList{}
+		list.SetGroupVersionKind(gvk.GroupVersion().WithKind(gvk.Kind + "List"))
+		if err := reader.List(ctx, list, client.InNamespace(namespace), client.MatchingLabels{tenantLabel: tenant.Name}); err != nil {
+			return nil, err
+		}
+		for i := range list.Items {
+			obj := &list.Items[i]
+			if !keep[types.NamespacedName{Namespace: obj.GetNamespace(), Name: obj.GetName()}] {
+				stale = append(stale, obj)
+			}
+		}
+	}
+	return stale, nil
+}
+
+// ofKind returns the objects of kind gvk
+func ofKind(objects []*unstructured.Unstructured, gvk schema.GroupVersionKind) []*unstructured.Unstructured {
+	var matching []*unstructured.Unstructured
+	for _, obj := range objects {
+		if obj.GroupVersionKind() == gvk {
+			matching = append(matching, obj)
+		}
+	}
+	return matching
+}
@@ -0,0 +1,188 @@
+// Shared secrets
+// Spec.SharedSecrets mirrors platform Secrets, such as CA bundles or API keys
+// of platform services, from the operator namespace into the tenant
+// namespaces. Only Secrets the platform team labelled shareable can be
+// listed. Copies carry a hash of their source and are only rewritten when
+// it changes; they are deleted once the tenant stops listing them.
+
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	platformv1alpha1 "github.com/xyz-company/tenant-operator/api/v1alpha1"
+)
+
+// shareableLabel marks the Secrets in the operator namespace Tenants may
+// list in Spec.SharedSecrets
+const shareableLabel = "platform.xyz.com/shareable"
+
+// sharedSecretLabel marks the copies of shared Secrets in tenant namespaces
+const sharedSecretLabel = "platform.xyz.com/shared-secret"
+
+// secretHashAnnotation holds the hash of the source a copy was written from
+const secretHashAnnotation = "platform.xyz.com/secret-hash"
+
+// validateSharedSecrets rejects Spec.SharedSecrets entries that are listed
+// twice or aren't valid Secret names
+func validateSharedSecrets(names []string) error {
+	seen := make(map[string]bool, len(names))
+	for _, name := range names {
+		if errs := validation.IsDNS1123Subdomain(name); len(errs) > 0 {
+			return fmt.Errorf("sharedSecrets %q is invalid: %s", name, strings.Join(errs, ", "))
+		}
+		if seen[name] {
+			return fmt.Errorf("sharedSecrets lists %q twice", name)
+		}
+		seen[name] = true
+	}
+	return nil
+}
+
+// secretHash returns a short, stable hash of secret's type and data
+func secretHash(secret *corev1.Secret) string {
+	keys := make([]string, 0, len(secret.Data))
+	for key := range secret.Data {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	h := sha256.New()
+	h.Write([]byte(secret.Type))
+	for _, key := range keys {
+		// Lengths keep key/value boundaries unambiguous
+		fmt.Fprintf(h, "\x00%d:%s%d:", len(key), key, len(secret.Data[key]))
+		h.Write(secret.Data[key])
+	}
+	return hex.EncodeToString(h.Sum(nil)[:8])
+}
+
+// reconcileSharedSecrets mirrors the tenant's shared Secrets into namespace
+// and deletes the copies it no longer lists. A listed Secret that is
+// missing or not shareable is skipped with a Warning event, its copies
+// kept.
+func (r *TenantReconciler) reconcileSharedSecrets(ctx context.Context, tenant *platformv1alpha1.Tenant, namespace string) error {
+	log := ctrl.LoggerFrom(ctx)
+	if err := validateSharedSecrets(tenant.Spec.SharedSecrets); err != nil {
+		return err
+	}
+	if len(tenant.Spec.SharedSecrets) > 0 && r.SecretNamespace == "" {
+		return fmt.Errorf("sharedSecrets needs the operator to run with --operator-namespace")
+	}
+
+	wanted := map[string]bool{}
+	for _, name := range tenant.Spec.SharedSecrets {
+		source := &corev1.Secret{}
+		err := r.Get(ctx, types.NamespacedName{Namespace: r.SecretNamespace, Name: name}, source)
+		if err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+		// Still listed, so existing copies are kept rather than deleted
+		wanted[name] = true
+		if errors.IsNotFound(err) || source.Labels[shareableLabel] != "true" {
+			r.Recorder.Eventf(tenant, corev1.EventTypeWarning, "SharedSecretUnavailable",
+				"Secret %s/%s does not exist or is not labelled %s=true", r.SecretNamespace, name, shareableLabel)
+			continue
+		}
+		if err := r.applySharedSecret(ctx, tenant, namespace, source); err != nil {
+			return err
+		}
+	}
+
+	copies := &corev1.SecretList{}
+	if err := r.APIReader.List(ctx, copies, client.InNamespace(namespace), client.MatchingLabels{tenantLabel: tenant.Name}, client.HasLabels{sharedSecretLabel}); err != nil {
+		return err
+	}
+	for i := range copies.Items {
+		secret := &copies.Items[i]
+		// A pull secret of the same name is reconciled by reconcileImagePullSecrets
+		if wanted[secret.Name] || slices.Contains(r.ImagePullSecrets, secret.Name) {
+			continue
+		}
+		if err := r.Delete(ctx, secret); err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+		log.Info("Removed shared Secret", "namespace", namespace, "secret", secret.Name)
+	}
+	return nil
+}
+
+// applySharedSecret writes a copy of source into namespace unless the copy
+// is already up to date, refusing to overwrite a Secret of the same name
+// the tenant created itself
+func (r *TenantReconciler) applySharedSecret(ctx context.Context, tenant *platformv1alpha1.Tenant, namespace string, source *corev1.Secret) error {
+	hash := secretHash(source)
+	existing := &corev1.Secret{}
+	err := r.APIReader.Get(ctx, types.NamespacedName{Namespace: namespace, Name: source.Name}, existing)
+	if err != nil && !errors.IsNotFound(err) {
+		return err
+	}
+	if err == nil {
+		if ref := metav1.GetControllerOf(existing); ref == nil || ref.UID != tenant.UID {
+			return fmt.Errorf("%s/%s is not managed by tenant %q, refusing to overwrite", namespace, source.Name, tenant.Name)
+		}
+		// Edited copies no longer match their hash and are rewritten too
+		if existing.Annotations[secretHashAnnotation] == hash && secretHash(existing) == hash {
+			return nil
+		}
+	}
+
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        source.Name,
+			Namespace:   namespace,
+			Labels:      map[string]string{tenantLabel: tenant.Name, sharedSecretLabel: "true"},
+			Annotations: map[string]string{secretHashAnnotation: hash},
+		},
+		Type: source.Type,
+		Data: source.Data,
+	}
+	if err := controllerutil.SetControllerReference(tenant, secret, r.Scheme); err != nil {
+		return err
+	}
+	if err := r.apply(ctx, secret); err != nil {
+		return err
+	}
+	ctrl.LoggerFrom(ctx).Info("Mirrored shared Secret", "namespace", namespace, "secret", source.Name, "hash", hash)
+	return nil
+}
+
+// validateSharedSecretRefs rejects shared Secrets that don't exist in the
+// operator namespace or aren't labelled shareable
+func (v *TenantValidator) validateSharedSecretRefs(ctx context.Context, tenant *platformv1alpha1.Tenant) error {
+	if len(tenant.Spec.SharedSecrets) == 0 {
+		return nil
+	}
+	namespace := v.Exclusion.OperatorNamespace
+	if namespace == "" {
+		return fmt.Errorf("sharedSecrets can't be used: the operator runs without --operator-namespace")
+	}
+	for _, name := range tenant.Spec.SharedSecrets {
+		secret := &corev1.Secret{}
+		if err := v.Reader.Get(ctx, types.NamespacedName{Namespace: namespace, Name: name}, secret); err != nil {
+			if errors.IsNotFound(err) {
+				return fmt.Errorf("sharedSecrets %q names no Secret in %s", name, namespace)
+			}
+			return err
+		}
+		if secret.Labels[shareableLabel] != "true" {
+			return fmt.Errorf("sharedSecrets %q is not shareable; the platform team must label it %s=true", name, shareableLabel)
+		}
+	}
+	return nil
+}
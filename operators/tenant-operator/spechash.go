@@ -0,0 +1,87 @@
+// Spec hash
+// The namespace carries a hash of the effective Tenant spec it was last
+// reconciled from, so tooling can spot stale namespaces without diffing
+
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	platformv1alpha1 "github.com/xyz-company/tenant-operator/api/v1alpha1"
+)
+
+// specHashAnnotation holds the hash of the effective spec on the tenant namespace
+const specHashAnnotation = "platform.xyz.com/spec-hash"
+
+// effectiveSpec returns spec with platform defaults filled in, so that
+// leaving a field unset and setting it to its default hash the same
+func effectiveSpec(spec platformv1alpha1.TenantSpec) platformv1alpha1.TenantSpec {
+	// Shallow copy; pointer fields are replaced, never written through
+	effective := spec
+
+	allow := spec.AllowIntraNamespace == nil || *spec.AllowIntraNamespace
+	effective.AllowIntraNamespace = &allow
+
+	mesh := meshEnabled(&spec)
+	effective.ServiceMesh = &platformv1alpha1.ServiceMeshSpec{Enabled: &mesh}
+
+	effective.Quota = effectiveQuota(effective.Quota)
+	effective.DeletionPolicy = deletionPolicy(&spec)
+
+	return effective
+}
+
+// specHash returns a short, stable hash of the effective spec
+func specHash(spec platformv1alpha1.TenantSpec) (string, error) {
+	// encoding/json emits struct fields in declaration order and sorts map
+	// keys, so equal specs always serialize identically
+	raw, err := json.Marshal(effectiveSpec(spec))
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(raw)
+	return hex.EncodeToString(sum[:8]), nil
+}
+
+// reconcileSpecHash writes the spec hash to the tenant namespaces and
+// records it, with the observed generation, in the Tenant status
+func (r *TenantReconciler) reconcileSpecHash(ctx context.Context, tenant *platformv1alpha1.Tenant, namespaces []string) error {
+	hash, err := specHash(tenant.Spec)
+	if err != nil {
+		return err
+	}
+
+	for _, namespace := range namespaces {
+		if err := r.setNamespaceAnnotation(ctx, namespace, specHashAnnotation, hash); err != nil {
+			return err
+		}
+	}
+
+	tenant.Status.SpecHash = hash
+	tenant.Status.ObservedGeneration = tenant.Generation
+	return nil
+}
+
+// setNamespaceAnnotation sets key=value on the namespace, updating it only if
+// the annotation changed
+func (r *TenantReconciler) setNamespaceAnnotation(ctx context.Context, namespace, key, value string) error {
+	ns := &corev1.Namespace{}
+	if err := r.Get(ctx, types.NamespacedName{Name: namespace}, ns); err != nil {
+		return err
+	}
+	if current, ok := ns.Annotations[key]; ok && current == value {
+		return nil
+	}
+
+	if ns.Annotations == nil {
+		ns.Annotations = map[string]string{}
+	}
+	ns.Annotations[key] = value
+	return r.Update(ctx, ns)
+}
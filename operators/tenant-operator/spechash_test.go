@@ -0,0 +1,134 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	platformv1alpha1 "github.com/xyz-company/tenant-operator/api/v1alpha1"
+)
+
+// boolPtr returns a pointer to b
+func boolPtr(b bool) *bool { return &b }
+
+// mustSpecHash returns the spec hash of spec and fails t on error
+func mustSpecHash(t *testing.T, spec platformv1alpha1.TenantSpec) string {
+	t.Helper()
+	hash, err := specHash(spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return hash
+}
+
+func TestSpecHashIgnoresDefaults(t *testing.T) {
+	unset := platformv1alpha1.TenantSpec{Owner: "search-team"}
+	want := mustSpecHash(t, unset)
+
+	tests := []struct {
+		name string
+		spec platformv1alpha1.TenantSpec
+	}{
+		{"allowIntraNamespace true", platformv1alpha1.TenantSpec{Owner: "search-team", AllowIntraNamespace: boolPtr(true)}},
+		{"empty serviceMesh", platformv1alpha1.TenantSpec{Owner: "search-team", ServiceMesh: &platformv1alpha1.ServiceMeshSpec{}}},
+		{"serviceMesh enabled", platformv1alpha1.TenantSpec{Owner: "search-team", ServiceMesh: &platformv1alpha1.ServiceMeshSpec{Enabled: boolPtr(true)}}},
+		{"default quota", platformv1alpha1.TenantSpec{Owner: "search-team", Quota: platformv1alpha1.TenantQuota{CPU: defaultQuotaCPU, Pods: defaultQuotaPods}}},
+		{"deletionPolicy Delete", platformv1alpha1.TenantSpec{Owner: "search-team", DeletionPolicy: platformv1alpha1.DeletionPolicyDelete}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := mustSpecHash(t, tt.spec); got != want {
+				t.Fatalf("specHash() = %s, want %s as with the field unset", got, want)
+			}
+		})
+	}
+}
+
+func TestSpecHashChangesWithSpec(t *testing.T) {
+	base := platformv1alpha1.TenantSpec{Owner: "search-team"}
+	want := mustSpecHash(t, base)
+	if len(want) != 16 {
+		t.Fatalf("specHash() = %q, want 16 hex characters", want)
+	}
+
+	tests := []struct {
+		name string
+		spec platformv1alpha1.TenantSpec
+	}{
+		{"owner", platformv1alpha1.TenantSpec{Owner: "ads-team"}},
+		{"allowIntraNamespace false", platformv1alpha1.TenantSpec{Owner: "search-team", AllowIntraNamespace: boolPtr(false)}},
+		{"serviceMesh disabled", platformv1alpha1.TenantSpec{Owner: "search-team", ServiceMesh: &platformv1alpha1.ServiceMeshSpec{Enabled: boolPtr(false)}}},
+		{"quota", platformv1alpha1.TenantSpec{Owner: "search-team", Quota: platformv1alpha1.TenantQuota{Pods: defaultQuotaPods + 1}}},
+		{"deletionPolicy", platformv1alpha1.TenantSpec{Owner: "search-team", DeletionPolicy: platformv1alpha1.DeletionPolicyOrphan}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := mustSpecHash(t, tt.spec); got == want {
+				t.Fatalf("specHash() unchanged (%s) after changing %s", got, tt.name)
+			}
+		})
+	}
+}
+
+func TestEffectiveSpecLeavesSpecUnchanged(t *testing.T) {
+	spec := platformv1alpha1.TenantSpec{Owner: "search-team"}
+	effectiveSpec(spec)
+	if spec.AllowIntraNamespace != nil || spec.ServiceMesh != nil || spec.Quota.CPU != "" || spec.DeletionPolicy != "" {
+		t.Fatalf("effectiveSpec() wrote defaults into its argument: %+v", spec)
+	}
+}
+
+// namespaceSpecHash returns the spec hash annotation of namespace
+func namespaceSpecHash(t *testing.T, c client.Client, namespace string) string {
+	t.Helper()
+	ns := &corev1.Namespace{}
+	if err := c.Get(context.Background(), client.ObjectKey{Name: namespace}, ns); err != nil {
+		t.Fatal(err)
+	}
+	return ns.Annotations[specHashAnnotation]
+}
+
+func TestReconcileSpecHash(t *testing.T) {
+	tenant := newTenant("search", "search-team")
+	tenant.Generation = 3
+	c := newReconcilerClient(tenant)
+	r := newTestReconciler(c)
+	reconcileTenant(t, r, "search")
+
+	stored := storedTenant(t, c, "search")
+	want := mustSpecHash(t, stored.Spec)
+	if stored.Status.SpecHash != want {
+		t.Fatalf("status.specHash = %q, want %q", stored.Status.SpecHash, want)
+	}
+	if stored.Status.ObservedGeneration != 3 {
+		t.Fatalf("status.observedGeneration = %d, want 3", stored.Status.ObservedGeneration)
+	}
+	if got := namespaceSpecHash(t, c, "search"); got != want {
+		t.Fatalf("namespace %s = %q, want %q", specHashAnnotation, got, want)
+	}
+
+	// Spelling out a default leaves the hash alone
+	stored.Spec.AllowIntraNamespace = boolPtr(true)
+	if err := c.Update(context.Background(), stored); err != nil {
+		t.Fatal(err)
+	}
+	reconcileTenant(t, r, "search")
+	if got := namespaceSpecHash(t, c, "search"); got != want {
+		t.Fatalf("namespace %s = %q after setting a default, want %q", specHashAnnotation, got, want)
+	}
+
+	// A real change is picked up on the namespace and in the status
+	stored = storedTenant(t, c, "search")
+	stored.Spec.CostCenter = "CC-SEARCH-001"
+	if err := c.Update(context.Background(), stored); err != nil {
+		t.Fatal(err)
+	}
+	reconcileTenant(t, r, "search")
+	stored = storedTenant(t, c, "search")
+	changed := namespaceSpecHash(t, c, "search")
+	if changed == want || changed != stored.Status.SpecHash {
+		t.Fatalf("after a spec change: namespace hash %q, status %q, previous %q", changed, stored.Status.SpecHash, want)
+	}
+}
@@ -0,0 +1,269 @@
+// Spot capacity
+// With --spot-node-label, the pod mutating webhook decides which pods in
+// tenant namespaces may run on spot (preemptible) nodes. Batch pods, those
+// of Jobs, of tenants with Spec.Compute.SpotAllowed are steered to spot
+// nodes, tolerating --spot-taint if set. Every other pod is kept off them;
+// stateful pods, of StatefulSets or mounting PersistentVolumeClaims, always
+// are, their volumes being bound to a zone and their replicas not meant to
+// be preempted. Tenants with dedicated nodes run on their pool instead.
+//
+// The share of each spot tenant's CPU requests running on spot nodes is
+// exported at scrape time.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	admissionv1 "k8s.io/api/admission/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	platformv1alpha1 "github.com/xyz-company/tenant-operator/api/v1alpha1"
+)
+
+// spotComputeDesc is the share of a tenant's compute on spot nodes
+var spotComputeDesc = prometheus.NewDesc("tenant_operator_tenant_spot_compute_percent",
+	"Percent of the CPU requested by the Tenant's running pods that runs on spot nodes.", []string{"tenant"}, nil)
+
+// SpotConfig identifies the cluster's spot nodes
+type SpotConfig struct {
+	// NodeLabel and NodeValue select the spot nodes (--spot-node-label)
+	NodeLabel string
+	NodeValue string
+	// Taint, when set, is the taint of the spot nodes (--spot-taint)
+	Taint *corev1.Taint
+}
+
+// parseSpotConfig reads --spot-node-label, key=value, and --spot-taint,
+// key[=value]:effect. An empty label disables spot scheduling.
+func parseSpotConfig(nodeLabel, taint string) (*SpotConfig, error) {
+	if nodeLabel == "" {
+		if taint != "" {
+			return nil, fmt.Errorf("--spot-taint needs --spot-node-label")
+		}
+		return nil, nil
+	}
+	key, value, ok := strings.Cut(nodeLabel, "=")
+	if !ok {
+		return nil, fmt.Errorf("--spot-node-label %q must be key=value", nodeLabel)
+	}
+	if errs := validation.IsQualifiedName(key); len(errs) > 0 {
+		return nil, fmt.Errorf("--spot-node-label key %q: %s", key, errs[0])
+	}
+	if errs := validation.IsValidLabelValue(value); len(errs) > 0 {
+		return nil, fmt.Errorf("--spot-node-label value %q: %s", value, errs[0])
+	}
+	config := &SpotConfig{NodeLabel: key, NodeValue: value}
+	if taint == "" {
+		return config, nil
+	}
+
+	keyValue, effect, ok := strings.Cut(taint, ":")
+	if !ok {
+		return nil, fmt.Errorf("--spot-taint %q must be key[=value]:effect", taint)
+	}
+	t := &corev1.Taint{Effect: corev1.TaintEffect(effect)}
+	t.Key, t.Value, _ = strings.Cut(keyValue, "=")
+	if errs := validation.IsQualifiedName(t.Key); len(errs) > 0 {
+		return nil, fmt.Errorf("--spot-taint key %q: %s", t.Key, errs[0])
+	}
+	switch t.Effect {
+	case corev1.TaintEffectNoSchedule, corev1.TaintEffectPreferNoSchedule, corev1.TaintEffectNoExecute:
+	default:
+		return nil, fmt.Errorf("--spot-taint effect must be NoSchedule, PreferNoSchedule or NoExecute, got %q", effect)
+	}
+	config.Taint = t
+	return config, nil
+}
+
+// validateCompute rejects spot capacity when the operator runs without
+// --spot-node-label
+func (c *SpotConfig) validateCompute(compute *platformv1alpha1.TenantCompute) error {
+	if compute == nil || !compute.SpotAllowed || c != nil {
+		return nil
+	}
+	return fmt.Errorf("compute.spotAllowed needs the operator to run with --spot-node-label")
+}
+
+// spotAllowed reports whether tenant's batch pods go to spot nodes
+func spotAllowed(tenant *platformv1alpha1.Tenant) bool {
+	return tenant.Spec.Compute != nil && tenant.Spec.Compute.SpotAllowed
+}
+
+// batchPod reports whether pod belongs to a Job, directly or through a
+// CronJob
+func batchPod(pod *corev1.Pod) bool {
+	owner := metav1.GetControllerOf(pod)
+	return owner != nil && owner.Kind == "Job" && owner.APIVersion == batchv1.SchemeGroupVersion.String()
+}
+
+// statefulPod reports whether pod belongs to a StatefulSet or mounts a
+// PersistentVolumeClaim
+func statefulPod(pod *corev1.Pod) bool {
+	if owner := metav1.GetControllerOf(pod); owner != nil && owner.Kind == "StatefulSet" {
+		return true
+	}
+	for _, volume := range pod.Spec.Volumes {
+		if volume.PersistentVolumeClaim != nil || volume.Ephemeral != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// PodSpotDefaulter mutates pod admission requests in tenant namespaces
+type PodSpotDefaulter struct {
+	Client  client.Client
+	Decoder *admission.Decoder
+	// Spot identifies the spot nodes; nil admits pods unmodified
+	Spot *SpotConfig
+}
+
+// Handle steers new batch pods of tenants allowing spot capacity to spot
+// nodes and keeps all other pods off them
+func (d *PodSpotDefaulter) Handle(ctx context.Context, req admission.Request) admission.Response {
+	if req.Operation != admissionv1.Create || d.Spot == nil {
+		return admission.Allowed("")
+	}
+
+	tenant, err := tenantForNamespace(ctx, d.Client, req.Namespace)
+	if err != nil {
+		return admission.Errored(http.StatusInternalServerError, err)
+	}
+	if tenant == nil || tenant.Spec.DedicatedNodes != nil {
+		return admission.Allowed("")
+	}
+
+	pod := &corev1.Pod{}
+	if err := d.Decoder.Decode(req, pod); err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+	if !d.Spot.schedule(pod, spotAllowed(tenant)) {
+		return admission.Allowed("")
+	}
+
+	raw, err := json.Marshal(pod)
+	if err != nil {
+		return admission.Errored(http.StatusInternalServerError, err)
+	}
+	return admission.PatchResponseFromRaw(req.Object.Raw, raw)
+}
+
+// schedule gives pod a node selector and toleration for the spot nodes if
+// it is a batch pod allowed on them, or else a node affinity excluding
+// them, and reports whether the pod changed. Batch pods selecting on the
+// spot label themselves are left alone.
+func (c *SpotConfig) schedule(pod *corev1.Pod, allowed bool) bool {
+	if allowed && batchPod(pod) && !statefulPod(pod) {
+		if _, ok := pod.Spec.NodeSelector[c.NodeLabel]; ok {
+			return false
+		}
+		if pod.Spec.NodeSelector == nil {
+			pod.Spec.NodeSelector = map[string]string{}
+		}
+		pod.Spec.NodeSelector[c.NodeLabel] = c.NodeValue
+		if c.Taint != nil {
+			pod.Spec.Tolerations = append(pod.Spec.Tolerations, corev1.Toleration{
+				Key:      c.Taint.Key,
+				Operator: corev1.TolerationOpEqual,
+				Value:    c.Taint.Value,
+				Effect:   c.Taint.Effect,
+			})
+		}
+		return true
+	}
+
+	offSpot := corev1.NodeSelectorRequirement{Key: c.NodeLabel, Operator: corev1.NodeSelectorOpNotIn, Values: []string{c.NodeValue}}
+	if pod.Spec.Affinity == nil {
+		pod.Spec.Affinity = &corev1.Affinity{}
+	}
+	if pod.Spec.Affinity.NodeAffinity == nil {
+		pod.Spec.Affinity.NodeAffinity = &corev1.NodeAffinity{}
+	}
+	required := pod.Spec.Affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution
+	if required == nil || len(required.NodeSelectorTerms) == 0 {
+		required = &corev1.NodeSelector{NodeSelectorTerms: []corev1.NodeSelectorTerm{{}}}
+		pod.Spec.Affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution = required
+	}
+	// The terms are alternatives, so each must exclude the spot nodes
+	for i := range required.NodeSelectorTerms {
+		term := &required.NodeSelectorTerms[i]
+		term.MatchExpressions = append(term.MatchExpressions, offSpot)
+	}
+	return true
+}
+
+// spotCollector reports the share of spot tenants' compute on spot nodes
+// at scrape time. Nodes come from the cache; pods, which the operator
+// doesn't cache, are listed from the API server.
+type spotCollector struct {
+	reader    client.Reader
+	apiReader client.Reader
+	spot      *SpotConfig
+}
+
+// Describe implements prometheus.Collector
+func (c *spotCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- spotComputeDesc
+}
+
+// Collect implements prometheus.Collector
+func (c *spotCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), collectTimeout)
+	defer cancel()
+
+	tenants, err := listTenants(ctx, c.reader)
+	if err != nil {
+		ch <- prometheus.NewInvalidMetric(spotComputeDesc, err)
+		return
+	}
+	nodes := &corev1.NodeList{}
+	if err := c.reader.List(ctx, nodes, client.MatchingLabels{c.spot.NodeLabel: c.spot.NodeValue}); err != nil {
+		ch <- prometheus.NewInvalidMetric(spotComputeDesc, err)
+		return
+	}
+	spotNodes := make(map[string]bool, len(nodes.Items))
+	for _, node := range nodes.Items {
+		spotNodes[node.Name] = true
+	}
+
+	for i := range tenants {
+		tenant := &tenants[i]
+		if !spotAllowed(tenant) {
+			continue
+		}
+		var total, onSpot resource.Quantity
+		for _, namespace := range knownNamespaces(tenant) {
+			pods := &corev1.PodList{}
+			if err := c.apiReader.List(ctx, pods, client.InNamespace(namespace), client.MatchingFields{"status.phase": string(corev1.PodRunning)}); err != nil {
+				ch <- prometheus.NewInvalidMetric(spotComputeDesc, err)
+				return
+			}
+			for _, pod := range pods.Items {
+				for _, container := range pod.Spec.Containers {
+					cpu := container.Resources.Requests[corev1.ResourceCPU]
+					total.Add(cpu)
+					if spotNodes[pod.Spec.NodeName] {
+						onSpot.Add(cpu)
+					}
+				}
+			}
+		}
+		var percent float64
+		if !total.IsZero() {
+			percent = float64(onSpot.MilliValue()) / float64(total.MilliValue()) * 100
+		}
+		ch <- prometheus.MustNewConstMetric(spotComputeDesc, prometheus.GaugeValue, percent, tenant.Name)
+	}
+}
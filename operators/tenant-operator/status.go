@@ -0,0 +1,83 @@
+// Tenant status
+// Every reconcile records which child resources were applied and a summary
+// phase through the status subresource, so `kubectl get tenants` shows
+// real state
+
+package main
+
+import (
+	"context"
+	"errors"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	platformv1alpha1 "github.com/xyz-company/tenant-operator/api/v1alpha1"
+)
+
+// Tenant phases
+const (
+	// TenantPhaseProvisioning means some child resources aren't applied yet
+	TenantPhaseProvisioning = "Provisioning"
+	// TenantPhaseReady means every child resource is applied
+	TenantPhaseReady = "Ready"
+	// TenantPhaseError means the last reconcile failed; Message says why
+	TenantPhaseError = "Error"
+	// TenantPhaseSuspended means every child resource is applied and the
+	// tenant's workloads are scaled to zero
+	TenantPhaseSuspended = "Suspended"
+	// TenantPhaseExpired means the tenant's expiry has passed and it is
+	// being deleted
+	TenantPhaseExpired = "Expired"
+)
+
+// resetProgress clears the per-resource flags before a reconcile sets them
+// again, so they describe the latest attempt rather than any earlier one
+func resetProgress(status *platformv1alpha1.TenantStatus) {
+	status.NamespaceCreated = false
+	status.QuotaApplied = false
+	status.NetworkPolicyApplied = false
+	status.RBACApplied = false
+}
+
+// tenantPhase derives the phase from the reconcile outcome, the flags and
+// the tenant's state
+func tenantPhase(tenant *platformv1alpha1.Tenant, reconcileErr error) string {
+	status := &tenant.Status
+	switch {
+	case reconcileErr != nil:
+		return TenantPhaseError
+	case status.NamespaceCreated && status.QuotaApplied && status.NetworkPolicyApplied && status.RBACApplied:
+		if tenantSuspended(&tenant.Spec) {
+			return TenantPhaseSuspended
+		}
+		return TenantPhaseReady
+	default:
+		return TenantPhaseProvisioning
+	}
+}
+
+// updateStatus sets the phase, message and conditions for reconcileErr and
+// writes the status if it differs from previous
+func (r *TenantReconciler) updateStatus(ctx context.Context, tenant *platformv1alpha1.Tenant, previous *platformv1alpha1.TenantStatus, reconcileErr error) error {
+	tenant.Status.Phase = tenantPhase(tenant, reconcileErr)
+	tenant.Status.Message = ""
+	if reconcileErr != nil {
+		// Report the cause, not controller-runtime's "terminal error:" prefix
+		if errors.Is(reconcileErr, reconcile.TerminalError(nil)) {
+			reconcileErr = errors.Unwrap(reconcileErr)
+		}
+		tenant.Status.Message = reconcileErr.Error()
+	}
+	setConditions(tenant)
+
+	if tenant.Status.Phase == TenantPhaseReady && previous.Phase != TenantPhaseReady {
+		r.Recorder.Event(tenant, corev1.EventTypeNormal, "Ready", "All tenant resources are applied")
+	}
+
+	if equality.Semantic.DeepEqual(previous, &tenant.Status) {
+		return nil
+	}
+	return r.Status().Update(ctx, tenant)
+}
@@ -0,0 +1,232 @@
+// StorageClass allowlist
+// Spec.Storage.AllowedClasses limits the StorageClasses the tenant's PVCs
+// may use. The PVC webhook rejects claims for other classes, and the
+// tenant-quota-storage ResourceQuota backs it up with a zero quota for every
+// other class in the cluster, while capping the allowed ones.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	platformv1alpha1 "github.com/xyz-company/tenant-operator/api/v1alpha1"
+)
+
+// storageQuotaName is the ResourceQuota holding the per-class storage limits
+const storageQuotaName = "tenant-quota-storage"
+
+// storageClassResource returns the ResourceQuota resource name limiting
+// resource, requests.storage or persistentvolumeclaims, for class
+func storageClassResource(class string, resource corev1.ResourceName) corev1.ResourceName {
+	return corev1.ResourceName(class + ".storageclass.storage.k8s.io/" + string(resource))
+}
+
+// validateStorage rejects allowedClasses entries that are listed twice,
+// aren't valid StorageClass names or have invalid caps
+func validateStorage(storage *platformv1alpha1.TenantStorage) error {
+	if storage == nil {
+		return nil
+	}
+	seen := make(map[string]bool, len(storage.AllowedClasses))
+	for _, class := range storage.AllowedClasses {
+		if errs := validation.IsDNS1123Subdomain(class.Name); len(errs) > 0 {
+			return fmt.Errorf("storage.allowedClasses %q is invalid: %s", class.Name, strings.Join(errs, ", "))
+		}
+		if seen[class.Name] {
+			return fmt.Errorf("storage.allowedClasses lists %q twice", class.Name)
+		}
+		seen[class.Name] = true
+		if class.PVCs < 0 {
+			return fmt.Errorf("storage.allowedClasses %q pvcs must not be negative, got %d", class.Name, class.PVCs)
+		}
+		if class.Storage != "" {
+			if _, err := parseStorageQuantity(class); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// parseStorageQuantity parses the storage cap of class, rejecting negatives
+func parseStorageQuantity(class platformv1alpha1.TenantStorageClass) (resource.Quantity, error) {
+	q, err := resource.ParseQuantity(class.Storage)
+	if err != nil {
+		return resource.Quantity{}, fmt.Errorf("storage.allowedClasses %q storage %q is not a valid quantity", class.Name, class.Storage)
+	}
+	if q.Sign() < 0 {
+		return resource.Quantity{}, fmt.Errorf("storage.allowedClasses %q storage must not be negative, got %s", class.Name, class.Storage)
+	}
+	return q, nil
+}
+
+// allowedStorageClass reports whether storage lets PVCs use class. Without
+// an allowlist every class is allowed.
+func allowedStorageClass(storage *platformv1alpha1.TenantStorage, class string) bool {
+	if storage == nil || len(storage.AllowedClasses) == 0 {
+		return true
+	}
+	for _, allowed := range storage.AllowedClasses {
+		if allowed.Name == class {
+			return true
+		}
+	}
+	return false
+}
+
+// storageQuota returns the tenant-quota-storage ResourceQuota for namespace:
+// the caps of the allowed classes, and zero claims of each of classes, the
+// cluster's StorageClasses, that isn't allowed. It returns nil without an
+// allowlist.
+func storageQuota(tenant *platformv1alpha1.Tenant, namespace string, classes []storagev1.StorageClass) (*corev1.ResourceQuota, error) {
+	storage := tenant.Spec.Storage
+	if storage == nil || len(storage.AllowedClasses) == 0 {
+		return nil, nil
+	}
+	if err := validateStorage(storage); err != nil {
+		return nil, err
+	}
+
+	hard := corev1.ResourceList{}
+	for _, class := range storage.AllowedClasses {
+		if class.Storage != "" {
+			q, err := parseStorageQuantity(class)
+			if err != nil {
+				return nil, err
+			}
+			hard[storageClassResource(class.Name, corev1.ResourceRequestsStorage)] = q
+		}
+		if class.PVCs > 0 {
+			hard[storageClassResource(class.Name, corev1.ResourcePersistentVolumeClaims)] = *resource.NewQuantity(int64(class.PVCs), resource.DecimalSI)
+		}
+	}
+	for _, class := range classes {
+		if !allowedStorageClass(storage, class.Name) {
+			hard[storageClassResource(class.Name, corev1.ResourcePersistentVolumeClaims)] = resource.MustParse("0")
+		}
+	}
+	if len(hard) == 0 {
+		// Only uncapped classes, and no others in the cluster yet
+		return nil, nil
+	}
+
+	return &corev1.ResourceQuota{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      storageQuotaName,
+			Namespace: namespace,
+		},
+		Spec: corev1.ResourceQuotaSpec{Hard: hard},
+	}, nil
+}
+
+// reconcileStorageQuota applies tenant-quota-storage in namespace, or
+// deletes it once the tenant no longer needs one
+func (r *TenantReconciler) reconcileStorageQuota(ctx context.Context, tenant *platformv1alpha1.Tenant, namespace string) error {
+	classes := &storagev1.StorageClassList{}
+	if err := r.List(ctx, classes); err != nil {
+		return err
+	}
+	quota, err := storageQuota(tenant, namespace, classes.Items)
+	if err != nil {
+		return err
+	}
+	if quota != nil {
+		return r.applyOrAdopt(ctx, tenant, quota)
+	}
+
+	existing := &corev1.ResourceQuota{}
+	err = r.Get(ctx, types.NamespacedName{Namespace: namespace, Name: storageQuotaName}, existing)
+	switch {
+	case errors.IsNotFound(err):
+		return nil
+	case err != nil:
+		return err
+	case !metav1.IsControlledBy(existing, tenant):
+		return nil
+	}
+	if err := r.Delete(ctx, existing); err != nil && !errors.IsNotFound(err) {
+		return err
+	}
+	ctrl.LoggerFrom(ctx).Info("Removed storage ResourceQuota", "namespace", namespace)
+	return nil
+}
+
+// storageClassToTenants maps StorageClasses being added or removed to a
+// reconcile of every Tenant with an allowlist, whose storage quota names
+// the classes it doesn't allow
+func (r *TenantReconciler) storageClassToTenants(ctx context.Context, obj client.Object) []reconcile.Request {
+	tenants, err := listTenants(ctx, r.Client)
+	if err != nil {
+		ctrl.LoggerFrom(ctx).Error(err, "Failed to list Tenants for StorageClass", "storageClass", obj.GetName())
+		return nil
+	}
+
+	var requests []reconcile.Request
+	for _, tenant := range tenants {
+		if tenant.Spec.Storage != nil && len(tenant.Spec.Storage.AllowedClasses) > 0 {
+			requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Name: tenant.Name}})
+		}
+	}
+	return requests
+}
+
+// PVCStorageClassValidator validates PersistentVolumeClaim admission
+// requests in tenant namespaces
+type PVCStorageClassValidator struct {
+	Client  client.Client
+	Decoder *admission.Decoder
+}
+
+// Handle rejects new PVCs for a StorageClass the Tenant owning their
+// namespace doesn't allow. With an allowlist, PVCs must name a class; the
+// DefaultStorageClass admission plugin has already filled in the default.
+func (v *PVCStorageClassValidator) Handle(ctx context.Context, req admission.Request) admission.Response {
+	if req.Operation != admissionv1.Create {
+		return admission.Allowed("")
+	}
+
+	tenant, err := tenantForNamespace(ctx, v.Client, req.Namespace)
+	if err != nil {
+		return admission.Errored(http.StatusInternalServerError, err)
+	}
+	if tenant == nil || tenant.Spec.Storage == nil || len(tenant.Spec.Storage.AllowedClasses) == 0 {
+		return admission.Allowed("")
+	}
+
+	pvc := &corev1.PersistentVolumeClaim{}
+	if err := v.Decoder.Decode(req, pvc); err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+	class := ""
+	if pvc.Spec.StorageClassName != nil {
+		class = *pvc.Spec.StorageClassName
+	}
+	if class != "" && allowedStorageClass(tenant.Spec.Storage, class) {
+		return admission.Allowed("")
+	}
+
+	allowed := make([]string, 0, len(tenant.Spec.Storage.AllowedClasses))
+	for _, c := range tenant.Spec.Storage.AllowedClasses {
+		allowed = append(allowed, c.Name)
+	}
+	if class == "" {
+		return admission.Denied(fmt.Sprintf("tenant %q requires a storageClassName, one of %s", tenant.Name, strings.Join(allowed, ", ")))
+	}
+	return admission.Denied(fmt.Sprintf("storage class %q is not allowed for tenant %q, use one of %s", class, tenant.Name, strings.Join(allowed, ", ")))
+}
@@ -0,0 +1,133 @@
+// Tenant suspension
+// A Suspended tenant keeps its namespaces and configuration but runs
+// nothing: its Deployments and StatefulSets are scaled to zero and its pod
+// quota is zeroed. Setting it Active again restores the recorded replicas.
+
+package main
+
+import (
+	"context"
+	"strconv"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	platformv1alpha1 "github.com/xyz-company/tenant-operator/api/v1alpha1"
+)
+
+// suspendedReplicasAnnotation records a workload's replicas from before its
+// tenant was suspended
+const suspendedReplicasAnnotation = "platform.xyz.com/suspended-replicas"
+
+// tenantSuspended reports whether spec suspends the tenant
+func tenantSuspended(spec *platformv1alpha1.TenantSpec) bool {
+	return spec.State == platformv1alpha1.TenantStateSuspended
+}
+
+// reconcileSuspension scales the Deployments and StatefulSets in namespace
+// to zero while tenant is suspended, and back to their recorded replicas
+// once it is active
+func (r *TenantReconciler) reconcileSuspension(ctx context.Context, tenant *platformv1alpha1.Tenant, namespace string) error {
+	deployments := &appsv1.DeploymentList{}
+	if err := r.APIReader.List(ctx, deployments, client.InNamespace(namespace)); err != nil {
+		return err
+	}
+	statefulSets := &appsv1.StatefulSetList{}
+	if err := r.APIReader.List(ctx, statefulSets, client.InNamespace(namespace)); err != nil {
+		return err
+	}
+
+	var workloads []client.Object
+	for i := range deployments.Items {
+		workloads = append(workloads, &deployments.Items[i])
+	}
+	for i := range statefulSets.Items {
+		workloads = append(workloads, &statefulSets.Items[i])
+	}
+
+	suspended := tenantSuspended(&tenant.Spec)
+	changed := 0
+	for _, workload := range workloads {
+		ok, err := r.suspendWorkload(ctx, workload, suspended)
+		if err != nil {
+			return err
+		}
+		if ok {
+			changed++
+		}
+	}
+	if changed == 0 {
+		return nil
+	}
+
+	if suspended {
+		ctrl.LoggerFrom(ctx).Info("Scaled workloads to zero", "namespace", namespace, "workloads", changed)
+		r.Recorder.Eventf(tenant, corev1.EventTypeNormal, "Suspended", "Scaled %d workloads in namespace %s to zero", changed, namespace)
+	} else {
+		ctrl.LoggerFrom(ctx).Info("Restored workload replicas", "namespace", namespace, "workloads", changed)
+		r.Recorder.Eventf(tenant, corev1.EventTypeNormal, "Resumed", "Restored the replicas of %d workloads in namespace %s", changed, namespace)
+	}
+	return nil
+}
+
+// workloadReplicas returns where workload, a Deployment or StatefulSet,
+// keeps its replicas
+func workloadReplicas(workload client.Object) **int32 {
+	switch w := workload.(type) {
+	case *appsv1.Deployment:
+		return &w.Spec.Replicas
+	case *appsv1.StatefulSet:
+		return &w.Spec.Replicas
+	}
+	return nil
+}
+
+// suspendWorkload scales workload to zero when suspend is set, recording
+// its replicas first, or restores the recorded replicas otherwise. It
+// reports whether the workload was patched.
+func (r *TenantReconciler) suspendWorkload(ctx context.Context, workload client.Object, suspend bool) (bool, error) {
+	replicas := workloadReplicas(workload)
+	recorded, ok := workload.GetAnnotations()[suspendedReplicasAnnotation]
+	patch := client.MergeFrom(workload.DeepCopyObject().(client.Object))
+
+	if suspend {
+		current := int32(1)
+		if *replicas != nil {
+			current = **replicas
+		}
+		if current == 0 && ok {
+			return false, nil
+		}
+		// A workload scaled up while suspended keeps the replicas recorded
+		// before the suspension
+		if !ok {
+			annotations := workload.GetAnnotations()
+			if annotations == nil {
+				annotations = map[string]string{}
+			}
+			annotations[suspendedReplicasAnnotation] = strconv.Itoa(int(current))
+			workload.SetAnnotations(annotations)
+		}
+		zero := int32(0)
+		*replicas = &zero
+		return true, r.Patch(ctx, workload, patch)
+	}
+
+	if !ok {
+		return false, nil
+	}
+	restored, err := strconv.ParseInt(recorded, 10, 32)
+	if err != nil {
+		// Not ours to guess; leave the workload at zero
+		ctrl.LoggerFrom(ctx).Info("Ignoring invalid suspended replicas annotation", "namespace", workload.GetNamespace(), "name", workload.GetName(), "value", recorded)
+		restored = 0
+	}
+	count := int32(restored)
+	*replicas = &count
+	annotations := workload.GetAnnotations()
+	delete(annotations, suspendedReplicasAnnotation)
+	workload.SetAnnotations(annotations)
+	return true, r.Patch(ctx, workload, patch)
+}
@@ -0,0 +1,366 @@
+// Tenant requests
+// Teams ask for a Tenant with a TenantRequest of the same name. New
+// requests are Pending until a platform admin sets them Approved or
+// Rejected through the status subresource, which only they are granted;
+// approval creates the Tenant as the operator. Requests within the
+// --auto-approve-* limits, such as sandboxes, are approved without review.
+// The webhooks record who requested and who reviewed, refuse requests
+// whose Tenant would be rejected, and keep decisions final.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	platformv1alpha1 "github.com/xyz-company/tenant-operator/api/v1alpha1"
+)
+
+const (
+	// tenantRequestLabel names the TenantRequest a Tenant was created for
+	tenantRequestLabel = "platform.xyz.com/tenant-request"
+	// requestedByAnnotation and approvedByAnnotation record who asked for a
+	// Tenant and who approved it, as the Tenant itself is created by the
+	// operator
+	requestedByAnnotation = "platform.xyz.com/requested-by"
+	approvedByAnnotation  = "platform.xyz.com/approved-by"
+)
+
+// AutoApprovePolicy approves sandbox-sized TenantRequests without review
+type AutoApprovePolicy struct {
+	// MaxQuota is the largest cpu and memory quota approved automatically,
+	// summed over the tenant's namespaces
+	MaxQuota corev1.ResourceList
+	// MaxTTL, if set, also requires a ttl no longer than it
+	MaxTTL time.Duration
+}
+
+// parseAutoApprovePolicy builds the AutoApprovePolicy of the
+// --auto-approve-* flags, nil if --auto-approve-max-cpu and
+// --auto-approve-max-memory are unset
+func parseAutoApprovePolicy(cpu, memory string, maxTTL time.Duration) (*AutoApprovePolicy, error) {
+	if cpu == "" && memory == "" {
+		if maxTTL != 0 {
+			return nil, fmt.Errorf("--auto-approve-max-ttl needs --auto-approve-max-cpu and --auto-approve-max-memory")
+		}
+		return nil, nil
+	}
+	if cpu == "" || memory == "" {
+		return nil, fmt.Errorf("--auto-approve-max-cpu and --auto-approve-max-memory must be set together")
+	}
+	if maxTTL < 0 {
+		return nil, fmt.Errorf("--auto-approve-max-ttl must not be negative")
+	}
+	policy := &AutoApprovePolicy{MaxQuota: corev1.ResourceList{}, MaxTTL: maxTTL}
+	for _, max := range []struct {
+		flag  string
+		name  corev1.ResourceName
+		value string
+	}{
+		{"--auto-approve-max-cpu", corev1.ResourceRequestsCPU, cpu},
+		{"--auto-approve-max-memory", corev1.ResourceRequestsMemory, memory},
+	} {
+		q, err := resource.ParseQuantity(max.value)
+		if err != nil || q.Sign() <= 0 {
+			return nil, fmt.Errorf("%s %q is not a positive quantity", max.flag, max.value)
+		}
+		policy.MaxQuota[max.name] = q
+	}
+	return policy, nil
+}
+
+// allows reports whether tenant, as requested, is within the policy. Its
+// quota is checked with the profile and platform defaults applied; a
+// quota that can't be determined isn't approved.
+func (p *AutoApprovePolicy) allows(ctx context.Context, reader client.Reader, tenant *platformv1alpha1.Tenant) bool {
+	if p == nil {
+		return false
+	}
+	if p.MaxTTL > 0 && (tenant.Spec.TTL == nil || tenant.Spec.TTL.Duration > p.MaxTTL) {
+		return false
+	}
+	effective := tenant.DeepCopy()
+	if err := withProfile(ctx, reader, effective); err != nil {
+		return false
+	}
+	total, err := tenantQuotaTotal(effective)
+	if err != nil {
+		return false
+	}
+	for name, max := range p.MaxQuota {
+		if got := total[name]; got.Cmp(max) > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// requestedTenant returns the Tenant request asks for
+func requestedTenant(request *platformv1alpha1.TenantRequest) *platformv1alpha1.Tenant {
+	tenant := &platformv1alpha1.Tenant{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        request.Name,
+			Labels:      map[string]string{tenantRequestLabel: request.Name},
+			Annotations: map[string]string{},
+		},
+		Spec: platformv1alpha1.TenantSpec{
+			Owner:      request.Spec.Owner,
+			CostCenter: request.Spec.CostCenter,
+			Profile:    request.Spec.Profile,
+			Parent:     request.Spec.Parent,
+			Namespaces: request.Spec.Namespaces,
+			Contacts:   request.Spec.Contacts,
+			Quota:      *request.Spec.Quota.DeepCopy(),
+			TTL:        request.Spec.TTL,
+		},
+	}
+	if request.Spec.RequestedBy != "" {
+		tenant.Annotations[requestedByAnnotation] = request.Spec.RequestedBy
+	}
+	if request.Status.ReviewedBy != "" {
+		tenant.Annotations[approvedByAnnotation] = request.Status.ReviewedBy
+	}
+	return tenant
+}
+
+// TenantRequestReconciler moves TenantRequests through their review and
+// creates the Tenants of approved ones
+type TenantRequestReconciler struct {
+	client.Client
+	Recorder record.EventRecorder
+	// AutoApprove approves sandbox-sized requests without review; nil
+	// sends every request to review
+	AutoApprove *AutoApprovePolicy
+}
+
+// Reconcile sets new requests Pending, or Approved within the
+// AutoApprove policy, and creates the Tenant of approved ones
+func (r *TenantRequestReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := ctrl.LoggerFrom(ctx)
+
+	request := &platformv1alpha1.TenantRequest{}
+	if err := r.Get(ctx, req.NamespacedName, request); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+
+	switch request.Status.Phase {
+	case "":
+		// Locked, so a spec edited since it was checked against the
+		// policy isn't approved unreviewed
+		patch := client.MergeFromWithOptions(request.DeepCopy(), client.MergeFromWithOptimisticLock{})
+		request.Status.Phase = platformv1alpha1.TenantRequestPending
+		if r.AutoApprove.allows(ctx, r.Client, requestedTenant(request)) {
+			now := metav1.Now()
+			request.Status.Phase = platformv1alpha1.TenantRequestApproved
+			request.Status.AutoApproved = true
+			request.Status.ReviewedAt = &now
+			request.Status.Comment = "Within the auto-approve limits"
+		}
+		if err := r.Status().Patch(ctx, request, patch); err != nil {
+			if errors.IsConflict(err) {
+				log.V(1).Info("TenantRequest changed while being evaluated; requeueing")
+				return ctrl.Result{Requeue: true}, nil
+			}
+			log.Error(err, "Failed to update TenantRequest status")
+			return ctrl.Result{}, err
+		}
+		if request.Status.AutoApproved {
+			r.Recorder.Event(request, corev1.EventTypeNormal, "AutoApproved", "Approved without review, being within the auto-approve limits")
+		} else {
+			r.Recorder.Event(request, corev1.EventTypeNormal, "Pending", "Awaiting review by a platform admin")
+		}
+		// The status update triggers the next step
+		return ctrl.Result{}, nil
+	case platformv1alpha1.TenantRequestApproved:
+		if request.Status.Tenant != "" {
+			return ctrl.Result{}, nil
+		}
+		return ctrl.Result{}, r.createTenant(ctx, request)
+	}
+	return ctrl.Result{}, nil
+}
+
+// createTenant creates the Tenant of an approved request and records it in
+// the request's status. A Tenant the webhooks reject is reported in Message
+// and not retried; the request has to be submitted again, corrected.
+func (r *TenantRequestReconciler) createTenant(ctx context.Context, request *platformv1alpha1.TenantRequest) error {
+	log := ctrl.LoggerFrom(ctx)
+
+	tenant := requestedTenant(request)
+	err := r.Create(ctx, tenant)
+	switch {
+	case err == nil:
+		log.Info("Created Tenant for approved TenantRequest", "tenant", tenant.Name)
+		r.Recorder.Eventf(request, corev1.EventTypeNormal, "TenantCreated", "Created Tenant %s", tenant.Name)
+	case errors.IsAlreadyExists(err):
+		existing := &platformv1alpha1.Tenant{}
+		if err := r.Get(ctx, client.ObjectKeyFromObject(tenant), existing); err != nil {
+			return err
+		}
+		// Created by an earlier reconcile whose status update failed
+		if existing.Labels[tenantRequestLabel] != request.Name {
+			return r.failed(ctx, request, fmt.Errorf("tenant %s exists already", tenant.Name))
+		}
+	case errors.IsInvalid(err) || errors.IsForbidden(err) || errors.IsBadRequest(err):
+		return r.failed(ctx, request, err)
+	default:
+		return err
+	}
+
+	patch := client.MergeFrom(request.DeepCopy())
+	request.Status.Tenant = tenant.Name
+	request.Status.Message = ""
+	if err := r.Status().Patch(ctx, request, patch); err != nil {
+		log.Error(err, "Failed to update TenantRequest status")
+		return err
+	}
+	return nil
+}
+
+// failed reports why the Tenant of an approved request couldn't be created
+func (r *TenantRequestReconciler) failed(ctx context.Context, request *platformv1alpha1.TenantRequest, cause error) error {
+	r.Recorder.Event(request, corev1.EventTypeWarning, "TenantCreationFailed", cause.Error())
+	if request.Status.Message != cause.Error() {
+		patch := client.MergeFrom(request.DeepCopy())
+		request.Status.Message = cause.Error()
+		if err := r.Status().Patch(ctx, request, patch); err != nil {
+			return err
+		}
+	}
+	return reconcile.TerminalError(cause)
+}
+
+// SetupWithManager sets up the controller with the Manager. Status updates
+// trigger reconciles too, as that is how requests are approved.
+func (r *TenantRequestReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&platformv1alpha1.TenantRequest{}).
+		Named("tenantrequest").
+		Complete(r)
+}
+
+// TenantRequestDefaulter mutates TenantRequest admission requests
+type TenantRequestDefaulter struct{}
+
+// Handle records the requesting user on CREATE, keeps it on spec updates,
+// and records the reviewer when a status update decides the request
+func (d *TenantRequestDefaulter) Handle(ctx context.Context, req admission.Request) admission.Response {
+	if req.Operation != admissionv1.Create && req.Operation != admissionv1.Update {
+		return admission.Allowed("")
+	}
+	request := &platformv1alpha1.TenantRequest{}
+	if err := json.Unmarshal(req.Object.Raw, request); err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+
+	switch {
+	case req.Operation == admissionv1.Create:
+		request.Spec.RequestedBy = req.UserInfo.Username
+	case req.SubResource == "":
+		old := &platformv1alpha1.TenantRequest{}
+		if err := json.Unmarshal(req.OldObject.Raw, old); err != nil {
+			return admission.Errored(http.StatusBadRequest, err)
+		}
+		request.Spec.RequestedBy = old.Spec.RequestedBy
+	case req.SubResource == "status":
+		old := &platformv1alpha1.TenantRequest{}
+		if err := json.Unmarshal(req.OldObject.Raw, old); err != nil {
+			return admission.Errored(http.StatusBadRequest, err)
+		}
+		if decided(old.Status.Phase) || !decided(request.Status.Phase) {
+			return admission.Allowed("")
+		}
+		now := metav1.Now()
+		request.Status.ReviewedBy = req.UserInfo.Username
+		request.Status.ReviewedAt = &now
+	default:
+		return admission.Allowed("")
+	}
+
+	raw, err := json.Marshal(request)
+	if err != nil {
+		return admission.Errored(http.StatusInternalServerError, err)
+	}
+	return admission.PatchResponseFromRaw(req.Object.Raw, raw)
+}
+
+// decided reports whether phase is a reviewer's decision
+func decided(phase platformv1alpha1.TenantRequestPhase) bool {
+	return phase == platformv1alpha1.TenantRequestApproved || phase == platformv1alpha1.TenantRequestRejected
+}
+
+// TenantRequestValidator validates TenantRequest admission requests
+type TenantRequestValidator struct {
+	Client client.Client
+}
+
+// Handle rejects requests whose Tenant exists or would be rejected, spec
+// changes to decided requests, and status updates reverting a decision
+func (v *TenantRequestValidator) Handle(ctx context.Context, req admission.Request) admission.Response {
+	if req.Operation != admissionv1.Create && req.Operation != admissionv1.Update {
+		return admission.Allowed("")
+	}
+	request := &platformv1alpha1.TenantRequest{}
+	if err := json.Unmarshal(req.Object.Raw, request); err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+	if req.Operation == admissionv1.Create {
+		return v.validateTenant(ctx, request)
+	}
+
+	old := &platformv1alpha1.TenantRequest{}
+	if err := json.Unmarshal(req.OldObject.Raw, old); err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+	switch req.SubResource {
+	case "":
+		if equality.Semantic.DeepEqual(old.Spec, request.Spec) {
+			return admission.Allowed("")
+		}
+		if decided(old.Status.Phase) {
+			return admission.Denied(fmt.Sprintf("TenantRequest %s is %s; its spec can't change any more, submit a new request instead", request.Name, old.Status.Phase))
+		}
+		return v.validateTenant(ctx, request)
+	case "status":
+		if decided(old.Status.Phase) && request.Status.Phase != old.Status.Phase {
+			return admission.Denied(fmt.Sprintf("TenantRequest %s is %s already; the decision is final", request.Name, old.Status.Phase))
+		}
+		if old.Status.Phase != "" && request.Status.Phase == "" {
+			return admission.Denied("status.phase can't be unset")
+		}
+	}
+	return admission.Allowed("")
+}
+
+// validateTenant rejects requests for an existing Tenant, and those whose
+// Tenant the Tenant webhooks would reject, by creating it in a dry run
+func (v *TenantRequestValidator) validateTenant(ctx context.Context, request *platformv1alpha1.TenantRequest) admission.Response {
+	if request.Spec.Owner == "" {
+		return admission.Denied("owner is required")
+	}
+	err := v.Client.Create(ctx, requestedTenant(request), client.DryRunAll)
+	switch {
+	case err == nil:
+		return admission.Allowed("")
+	case errors.IsAlreadyExists(err):
+		return admission.Denied(fmt.Sprintf("Tenant %s exists already", request.Name))
+	case errors.IsInvalid(err) || errors.IsForbidden(err) || errors.IsBadRequest(err):
+		return admission.Denied(fmt.Sprintf("the requested Tenant would be rejected: %v", err))
+	}
+	return admission.Errored(http.StatusInternalServerError, err)
+}
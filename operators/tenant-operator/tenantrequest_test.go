@@ -0,0 +1,503 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	platformv1alpha1 "github.com/xyz-company/tenant-operator/api/v1alpha1"
+)
+
+// newTenantRequest returns a TenantRequest for a sandbox called name
+func newTenantRequest(name, owner string) *platformv1alpha1.TenantRequest {
+	return &platformv1alpha1.TenantRequest{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Spec: platformv1alpha1.TenantRequestSpec{
+			Owner:       owner,
+			Quota:       platformv1alpha1.TenantQuota{CPU: "1", Memory: "2Gi"},
+			TTL:         &metav1.Duration{Duration: 72 * time.Hour},
+			Reason:      "Trying out the search index",
+			RequestedBy: "jane@xyz.com",
+		},
+	}
+}
+
+func newTestRequestReconciler(c client.Client, policy *AutoApprovePolicy) *TenantRequestReconciler {
+	return &TenantRequestReconciler{Client: c, Recorder: record.NewFakeRecorder(100), AutoApprove: policy}
+}
+
+// reconcileRequest reconciles the TenantRequest name and returns the error
+func reconcileRequest(r *TenantRequestReconciler, name string) error {
+	_, err := r.Reconcile(context.Background(), ctrl.Request{NamespacedName: client.ObjectKey{Name: name}})
+	return err
+}
+
+func storedRequest(t *testing.T, c client.Client, name string) *platformv1alpha1.TenantRequest {
+	t.Helper()
+	request := &platformv1alpha1.TenantRequest{}
+	if err := c.Get(context.Background(), client.ObjectKey{Name: name}, request); err != nil {
+		t.Fatal(err)
+	}
+	return request
+}
+
+// review sets the request name's phase through the status subresource, as
+// a reviewer would
+func review(t *testing.T, c client.Client, name string, phase platformv1alpha1.TenantRequestPhase) {
+	t.Helper()
+	request := storedRequest(t, c, name)
+	now := metav1.Now()
+	request.Status.Phase = phase
+	request.Status.ReviewedBy = "admin@xyz.com"
+	request.Status.ReviewedAt = &now
+	if err := c.Status().Update(context.Background(), request); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func requestEvents(r *TenantRequestReconciler) []string {
+	var events []string
+	recorder := r.Recorder.(*record.FakeRecorder)
+	for {
+		select {
+		case event := <-recorder.Events:
+			events = append(events, event)
+		default:
+			return events
+		}
+	}
+}
+
+func TestTenantRequestApproved(t *testing.T) {
+	ctx := context.Background()
+	request := newTenantRequest("sandbox", "search-team")
+	request.Spec.Contacts = map[string]string{"slack": "#search"}
+	c := newReconcilerClient(request)
+	r := newTestRequestReconciler(c, nil)
+
+	if err := reconcileRequest(r, "sandbox"); err != nil {
+		t.Fatal(err)
+	}
+	if phase := storedRequest(t, c, "sandbox").Status.Phase; phase != platformv1alpha1.TenantRequestPending {
+		t.Fatalf("phase = %q, want Pending", phase)
+	}
+	if events := eventsWithReason(requestEvents(r), "Pending"); len(events) != 1 {
+		t.Fatalf("Pending events = %v, want one", events)
+	}
+	// Nothing is created while the request awaits review
+	if err := reconcileRequest(r, "sandbox"); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Get(ctx, client.ObjectKey{Name: "sandbox"}, &platformv1alpha1.Tenant{}); !apierrors.IsNotFound(err) {
+		t.Fatalf("Tenant of a Pending request: %v, want none", err)
+	}
+
+	review(t, c, "sandbox", platformv1alpha1.TenantRequestApproved)
+	if err := reconcileRequest(r, "sandbox"); err != nil {
+		t.Fatal(err)
+	}
+	tenant := storedTenant(t, c, "sandbox")
+	if tenant.Spec.Owner != "search-team" || tenant.Spec.Quota.CPU != "1" || tenant.Spec.TTL.Duration != 72*time.Hour || tenant.Spec.Contacts["slack"] != "#search" {
+		t.Errorf("Tenant spec = %+v, want the requested one", tenant.Spec)
+	}
+	if tenant.Labels[tenantRequestLabel] != "sandbox" ||
+		tenant.Annotations[requestedByAnnotation] != "jane@xyz.com" ||
+		tenant.Annotations[approvedByAnnotation] != "admin@xyz.com" {
+		t.Errorf("Tenant labels %v, annotations %v, want the request, requester and approver recorded", tenant.Labels, tenant.Annotations)
+	}
+	if status := storedRequest(t, c, "sandbox").Status; status.Tenant != "sandbox" || status.Message != "" || status.AutoApproved {
+		t.Errorf("status = %+v, want the Tenant recorded", status)
+	}
+	if events := eventsWithReason(requestEvents(r), "TenantCreated"); len(events) != 1 {
+		t.Fatalf("TenantCreated events = %v, want one", events)
+	}
+
+	// Done; later reconciles leave the Tenant alone
+	if err := reconcileRequest(r, "sandbox"); err != nil {
+		t.Fatal(err)
+	}
+	if events := requestEvents(r); len(events) != 0 {
+		t.Fatalf("events of a reconcile after the Tenant was created: %v", events)
+	}
+}
+
+func TestTenantRequestRejected(t *testing.T) {
+	ctx := context.Background()
+	c := newReconcilerClient(newTenantRequest("sandbox", "search-team"))
+	r := newTestRequestReconciler(c, nil)
+	if err := reconcileRequest(r, "sandbox"); err != nil {
+		t.Fatal(err)
+	}
+
+	review(t, c, "sandbox", platformv1alpha1.TenantRequestRejected)
+	for i := 0; i < 2; i++ {
+		if err := reconcileRequest(r, "sandbox"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := c.Get(ctx, client.ObjectKey{Name: "sandbox"}, &platformv1alpha1.Tenant{}); !apierrors.IsNotFound(err) {
+		t.Fatalf("Tenant of a Rejected request: %v, want none", err)
+	}
+	if status := storedRequest(t, c, "sandbox").Status; status.Phase != platformv1alpha1.TenantRequestRejected || status.Tenant != "" {
+		t.Fatalf("status = %+v, want Rejected without a Tenant", status)
+	}
+}
+
+func TestTenantRequestAutoApprove(t *testing.T) {
+	policy, err := parseAutoApprovePolicy("2", "4Gi", 7*24*time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name   string
+		policy *AutoApprovePolicy
+		modify func(*platformv1alpha1.TenantRequest)
+		want   bool
+	}{
+		{name: "within the limits", policy: policy, want: true},
+		{name: "at the limits", policy: policy, modify: func(r *platformv1alpha1.TenantRequest) {
+			r.Spec.Quota = platformv1alpha1.TenantQuota{CPU: "2", Memory: "4Gi"}
+			r.Spec.TTL.Duration = 7 * 24 * time.Hour
+		}, want: true},
+		{name: "no policy", modify: func(*platformv1alpha1.TenantRequest) {}},
+		{name: "too much cpu", policy: policy, modify: func(r *platformv1alpha1.TenantRequest) { r.Spec.Quota.CPU = "2500m" }},
+		{name: "too much memory", policy: policy, modify: func(r *platformv1alpha1.TenantRequest) { r.Spec.Quota.Memory = "8Gi" }},
+		{name: "too much over its namespaces", policy: policy, modify: func(r *platformv1alpha1.TenantRequest) {
+			r.Spec.Namespaces = []string{"sandbox", "sandbox-staging", "sandbox-dev"}
+		}},
+		{name: "ttl too long", policy: policy, modify: func(r *platformv1alpha1.TenantRequest) { r.Spec.TTL.Duration = 30 * 24 * time.Hour }},
+		{name: "no ttl", policy: policy, modify: func(r *platformv1alpha1.TenantRequest) { r.Spec.TTL = nil }},
+		{name: "invalid quota", policy: policy, modify: func(r *platformv1alpha1.TenantRequest) { r.Spec.Quota.CPU = "lots" }},
+		{name: "no ttl needed", policy: &AutoApprovePolicy{MaxQuota: policy.MaxQuota}, modify: func(r *platformv1alpha1.TenantRequest) { r.Spec.TTL = nil }, want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request := newTenantRequest("sandbox", "search-team")
+			if tt.modify != nil {
+				tt.modify(request)
+			}
+			c := newReconcilerClient(request)
+			r := newTestRequestReconciler(c, tt.policy)
+			if err := reconcileRequest(r, "sandbox"); err != nil {
+				t.Fatal(err)
+			}
+
+			status := storedRequest(t, c, "sandbox").Status
+			if !tt.want {
+				if status.Phase != platformv1alpha1.TenantRequestPending || status.AutoApproved {
+					t.Fatalf("status = %+v, want Pending for review", status)
+				}
+				return
+			}
+			if status.Phase != platformv1alpha1.TenantRequestApproved || !status.AutoApproved || status.ReviewedAt == nil {
+				t.Fatalf("status = %+v, want approved automatically", status)
+			}
+			if events := eventsWithReason(requestEvents(r), "AutoApproved"); len(events) != 1 {
+				t.Fatalf("AutoApproved events = %v, want one", events)
+			}
+			if err := reconcileRequest(r, "sandbox"); err != nil {
+				t.Fatal(err)
+			}
+			if tenant := storedTenant(t, c, "sandbox"); tenant.Annotations[approvedByAnnotation] != "" {
+				t.Fatalf("Tenant approved by %q, want no reviewer", tenant.Annotations[approvedByAnnotation])
+			}
+		})
+	}
+}
+
+func TestTenantRequestAutoApproveEditedSpec(t *testing.T) {
+	policy, err := parseAutoApprovePolicy("2", "4Gi", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The spec grows past the limits between the read and the status patch,
+	// which the lock refuses rather than approving the bigger request
+	edited := false
+	c := reconcilerClientBuilder(newTenantRequest("sandbox", "search-team")).
+		WithInterceptorFuncs(interceptor.Funcs{
+			SubResourcePatch: func(ctx context.Context, c client.Client, subResource string, obj client.Object, patch client.Patch, opts ...client.SubResourcePatchOption) error {
+				if !edited {
+					edited = true
+					request := &platformv1alpha1.TenantRequest{}
+					if err := c.Get(ctx, client.ObjectKeyFromObject(obj), request); err != nil {
+						return err
+					}
+					request.Spec.Quota.CPU = "64"
+					if err := c.Update(ctx, request); err != nil {
+						return err
+					}
+				}
+				return c.SubResource(subResource).Patch(ctx, obj, patch, opts...)
+			},
+		}).Build()
+	r := newTestRequestReconciler(c, policy)
+
+	result, err := r.Reconcile(context.Background(), ctrl.Request{NamespacedName: client.ObjectKey{Name: "sandbox"}})
+	if err != nil || !result.Requeue {
+		t.Fatalf("Reconcile() = %+v, %v, want a requeue", result, err)
+	}
+	if err := reconcileRequest(r, "sandbox"); err != nil {
+		t.Fatal(err)
+	}
+	if status := storedRequest(t, c, "sandbox").Status; status.Phase != platformv1alpha1.TenantRequestPending || status.AutoApproved {
+		t.Fatalf("status = %+v, want the edited request Pending", status)
+	}
+}
+
+func TestParseAutoApprovePolicy(t *testing.T) {
+	tests := []struct {
+		cpu, memory string
+		ttl         time.Duration
+		wantNil     bool
+		wantErr     bool
+	}{
+		{wantNil: true},
+		{cpu: "2", memory: "4Gi"},
+		{cpu: "2", memory: "4Gi", ttl: time.Hour},
+		{ttl: time.Hour, wantErr: true},
+		{cpu: "2", wantErr: true},
+		{memory: "4Gi", wantErr: true},
+		{cpu: "0", memory: "4Gi", wantErr: true},
+		{cpu: "2", memory: "lots", wantErr: true},
+		{cpu: "2", memory: "4Gi", ttl: -time.Hour, wantErr: true},
+	}
+	for _, tt := range tests {
+		policy, err := parseAutoApprovePolicy(tt.cpu, tt.memory, tt.ttl)
+		if (err != nil) != tt.wantErr || (policy == nil) != (tt.wantNil || tt.wantErr) {
+			t.Errorf("parseAutoApprovePolicy(%q, %q, %v) = %+v, %v", tt.cpu, tt.memory, tt.ttl, policy, err)
+		}
+	}
+}
+
+func TestTenantRequestTenantRejected(t *testing.T) {
+	request := newTenantRequest("sandbox", "search-team")
+	request.Status.Phase = platformv1alpha1.TenantRequestApproved
+	rejection := apierrors.NewInvalid(platformv1alpha1.GroupVersion.WithKind("Tenant").GroupKind(), "sandbox",
+		field.ErrorList{field.Invalid(field.NewPath("spec", "parent"), "search", "parent tenant search does not exist")})
+	c := reconcilerClientBuilder(request).
+		WithInterceptorFuncs(interceptor.Funcs{
+			Create: func(ctx context.Context, c client.WithWatch, obj client.Object, opts ...client.CreateOption) error {
+				if _, ok := obj.(*platformv1alpha1.Tenant); ok {
+					return rejection
+				}
+				return c.Create(ctx, obj, opts...)
+			},
+		}).Build()
+	r := newTestRequestReconciler(c, nil)
+
+	err := reconcileRequest(r, "sandbox")
+	if !errors.Is(err, reconcile.TerminalError(nil)) {
+		t.Fatalf("Reconcile() = %v, want a terminal error", err)
+	}
+	status := storedRequest(t, c, "sandbox").Status
+	if status.Tenant != "" || !strings.Contains(status.Message, "parent tenant search does not exist") {
+		t.Fatalf("status = %+v, want the webhook's rejection in the message", status)
+	}
+	if events := eventsWithReason(requestEvents(r), "TenantCreationFailed"); len(events) != 1 {
+		t.Fatalf("TenantCreationFailed events = %v, want one", events)
+	}
+}
+
+func TestTenantRequestTenantExists(t *testing.T) {
+	request := newTenantRequest("sandbox", "search-team")
+	request.Status.Phase = platformv1alpha1.TenantRequestApproved
+
+	// Someone else's Tenant of the same name
+	c := newReconcilerClient(request, newTenant("sandbox", "other-team"))
+	r := newTestRequestReconciler(c, nil)
+	if err := reconcileRequest(r, "sandbox"); !errors.Is(err, reconcile.TerminalError(nil)) {
+		t.Fatalf("Reconcile() = %v, want a terminal error", err)
+	}
+	if status := storedRequest(t, c, "sandbox").Status; status.Tenant != "" || status.Message != "tenant sandbox exists already" {
+		t.Fatalf("status = %+v, want the existing Tenant reported", status)
+	}
+	if owner := storedTenant(t, c, "sandbox").Spec.Owner; owner != "other-team" {
+		t.Fatalf("existing Tenant's owner = %q, want it left alone", owner)
+	}
+
+	// The request's own, created by a reconcile whose status patch failed
+	own := requestedTenant(request)
+	c = newReconcilerClient(request, own)
+	r = newTestRequestReconciler(c, nil)
+	if err := reconcileRequest(r, "sandbox"); err != nil {
+		t.Fatal(err)
+	}
+	if status := storedRequest(t, c, "sandbox").Status; status.Tenant != "sandbox" || status.Message != "" {
+		t.Fatalf("status = %+v, want the Tenant recorded", status)
+	}
+}
+
+// statusRequest is admissionRequest for an update of obj's status
+func statusRequest(t *testing.T, obj, old *platformv1alpha1.TenantRequest) admission.Request {
+	t.Helper()
+	req := admissionRequest(t, admissionv1.Update, obj, old)
+	req.SubResource = "status"
+	return req
+}
+
+func withPhase(request *platformv1alpha1.TenantRequest, phase platformv1alpha1.TenantRequestPhase) *platformv1alpha1.TenantRequest {
+	request = request.DeepCopy()
+	request.Status.Phase = phase
+	return request
+}
+
+func TestTenantRequestDecisionsAreFinal(t *testing.T) {
+	v := &TenantRequestValidator{Client: newFakeClient()}
+	request := newTenantRequest("sandbox", "search-team")
+	pending := withPhase(request, platformv1alpha1.TenantRequestPending)
+	approved := withPhase(request, platformv1alpha1.TenantRequestApproved)
+	rejected := withPhase(request, platformv1alpha1.TenantRequestRejected)
+
+	tests := []struct {
+		name     string
+		obj, old *platformv1alpha1.TenantRequest
+		denied   string
+	}{
+		{name: "approve", obj: approved, old: pending},
+		{name: "reject", obj: rejected, old: pending},
+		{name: "set pending", obj: pending, old: withPhase(request, "")},
+		{name: "record the tenant", obj: func() *platformv1alpha1.TenantRequest {
+			r := approved.DeepCopy()
+			r.Status.Tenant = "sandbox"
+			return r
+		}(), old: approved},
+		{name: "unapprove", obj: pending, old: approved, denied: "is Approved already; the decision is final"},
+		{name: "approve a rejected", obj: approved, old: rejected, denied: "is Rejected already; the decision is final"},
+		{name: "reject an approved", obj: rejected, old: approved, denied: "is Approved already; the decision is final"},
+		{name: "unset the phase", obj: withPhase(request, ""), old: pending, denied: "status.phase can't be unset"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := v.Handle(context.Background(), statusRequest(t, tt.obj, tt.old))
+			if tt.denied == "" {
+				wantAllowed(t, resp)
+			} else {
+				wantDenied(t, resp, tt.denied)
+			}
+		})
+	}
+
+	// The spec of a decided request is frozen, but not its metadata
+	for _, old := range []*platformv1alpha1.TenantRequest{approved, rejected} {
+		grown := old.DeepCopy()
+		grown.Spec.Quota.CPU = "64"
+		wantDenied(t, v.Handle(context.Background(), admissionRequest(t, admissionv1.Update, grown, old)), "its spec can't change any more")
+
+		labelled := old.DeepCopy()
+		labelled.Labels = map[string]string{"team": "search"}
+		wantAllowed(t, v.Handle(context.Background(), admissionRequest(t, admissionv1.Update, labelled, old)))
+	}
+}
+
+// dryRunCreate answers dry-run creates of existing Tenants as the API
+// server does; the fake client accepts them without looking
+func dryRunCreate(ctx context.Context, c client.WithWatch, obj client.Object, opts ...client.CreateOption) error {
+	createOpts := &client.CreateOptions{}
+	createOpts.ApplyOptions(opts)
+	if _, ok := obj.(*platformv1alpha1.Tenant); ok && len(createOpts.DryRun) > 0 {
+		err := c.Get(ctx, client.ObjectKeyFromObject(obj), &platformv1alpha1.Tenant{})
+		if err == nil {
+			return apierrors.NewAlreadyExists(platformv1alpha1.GroupVersion.WithResource("tenants").GroupResource(), obj.GetName())
+		}
+		if !apierrors.IsNotFound(err) {
+			return err
+		}
+	}
+	return c.Create(ctx, obj, opts...)
+}
+
+func TestTenantRequestValidatorChecksTenant(t *testing.T) {
+	c := reconcilerClientBuilder(newTenant("search", "search-team")).
+		WithInterceptorFuncs(interceptor.Funcs{Create: dryRunCreate}).
+		Build()
+	v := &TenantRequestValidator{Client: c}
+	ctx := context.Background()
+
+	wantAllowed(t, v.Handle(ctx, admissionRequest(t, admissionv1.Create, newTenantRequest("sandbox", "search-team"), nil)))
+	// A dry run; nothing is created
+	if err := c.Get(ctx, client.ObjectKey{Name: "sandbox"}, &platformv1alpha1.Tenant{}); !apierrors.IsNotFound(err) {
+		t.Fatalf("Tenant after validating its request: %v, want none", err)
+	}
+	wantDenied(t, v.Handle(ctx, admissionRequest(t, admissionv1.Create, newTenantRequest("search", "search-team"), nil)), "Tenant search exists already")
+	wantDenied(t, v.Handle(ctx, admissionRequest(t, admissionv1.Create, newTenantRequest("sandbox", ""), nil)), "owner is required")
+
+	// What the Tenant webhooks refuse, the request's is refused for
+	rejecting := reconcilerClientBuilder().
+		WithInterceptorFuncs(interceptor.Funcs{
+			Create: func(ctx context.Context, c client.WithWatch, obj client.Object, opts ...client.CreateOption) error {
+				return apierrors.NewForbidden(platformv1alpha1.GroupVersion.WithResource("tenants").GroupResource(), obj.GetName(), errors.New("owner search-team has 5 tenants already"))
+			},
+		}).Build()
+	v = &TenantRequestValidator{Client: rejecting}
+	wantDenied(t, v.Handle(ctx, admissionRequest(t, admissionv1.Create, newTenantRequest("sandbox", "search-team"), nil)), "the requested Tenant would be rejected")
+
+	// So is a Pending request's spec edit, but not a decided one's
+	// unchanged spec
+	pending := withPhase(newTenantRequest("sandbox", "search-team"), platformv1alpha1.TenantRequestPending)
+	grown := pending.DeepCopy()
+	grown.Spec.Quota.CPU = "64"
+	wantDenied(t, v.Handle(ctx, admissionRequest(t, admissionv1.Update, grown, pending)), "the requested Tenant would be rejected")
+	approved := withPhase(pending, platformv1alpha1.TenantRequestApproved)
+	wantAllowed(t, v.Handle(ctx, admissionRequest(t, admissionv1.Update, approved, approved)))
+}
+
+func TestTenantRequestDefaulter(t *testing.T) {
+	d := &TenantRequestDefaulter{}
+	ctx := context.Background()
+	request := newTenantRequest("sandbox", "search-team")
+	request.Spec.RequestedBy = "mallory@xyz.com"
+
+	// The requester is whoever creates the request, and stays so
+	resp := d.Handle(ctx, admissionRequest(t, admissionv1.Create, request, nil))
+	wantPatch(t, resp, "/spec/requestedBy", "jane@xyz.com")
+	old := request.DeepCopy()
+	old.Spec.RequestedBy = "john@xyz.com"
+	resp = d.Handle(ctx, admissionRequest(t, admissionv1.Update, request, old))
+	wantPatch(t, resp, "/spec/requestedBy", "john@xyz.com")
+
+	// The reviewer is whoever decides, not anyone the status names
+	pending := withPhase(old, platformv1alpha1.TenantRequestPending)
+	approved := withPhase(old, platformv1alpha1.TenantRequestApproved)
+	approved.Status.ReviewedBy = "mallory@xyz.com"
+	resp = d.Handle(ctx, statusRequest(t, approved, pending))
+	wantPatch(t, resp, "/status/reviewedBy", "jane@xyz.com")
+
+	// Later status updates, such as the operator's, keep the reviewer
+	later := approved.DeepCopy()
+	later.Status.ReviewedBy = "admin@xyz.com"
+	later.Status.Tenant = "sandbox"
+	resp = d.Handle(ctx, statusRequest(t, later, withPhase(later, platformv1alpha1.TenantRequestApproved)))
+	if !resp.Allowed || len(resp.Patches) != 0 {
+		t.Fatalf("status update of a decided request: allowed %v, patches %v, want it left alone", resp.Allowed, resp.Patches)
+	}
+}
+
+// wantPatch fails t unless resp patches path to value
+func wantPatch(t *testing.T, resp admission.Response, path string, value interface{}) {
+	t.Helper()
+	if !resp.Allowed {
+		t.Fatalf("denied: %v", resp.Result)
+	}
+	for _, patch := range resp.Patches {
+		if patch.Path == path {
+			if patch.Value != value {
+				t.Fatalf("%s patched to %v, want %v", path, patch.Value, value)
+			}
+			return
+		}
+	}
+	t.Fatalf("patches %v, want %s set to %v", resp.Patches, path, value)
+}
@@ -0,0 +1,62 @@
+// Reconcile throughput
+// Large clusters run several reconcile workers. The work queue hands them
+// Tenants no faster than a token bucket allows, and failed Tenants are
+// retried with exponential backoff, so more workers don't mean more load on
+// the API server than configured.
+
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"golang.org/x/time/rate"
+	"k8s.io/client-go/util/workqueue"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+)
+
+// ReconcileLimits configures how many Tenants are reconciled at once and
+// how fast they are taken off the work queue
+type ReconcileLimits struct {
+	// MaxConcurrentReconciles is the number of reconcile workers
+	// (--max-concurrent-reconciles)
+	MaxConcurrentReconciles int
+	// QPS and Burst size the token bucket shared by all requeues
+	// (--reconcile-qps, --reconcile-burst)
+	QPS   float64
+	Burst int
+	// BackoffBase is the first retry delay of a failing Tenant, doubled on
+	// every further failure up to BackoffMax (--reconcile-backoff-base,
+	// --reconcile-backoff-max)
+	BackoffBase time.Duration
+	BackoffMax  time.Duration
+}
+
+// validate rejects limits the work queue can't run with
+func (l ReconcileLimits) validate() error {
+	switch {
+	case l.MaxConcurrentReconciles < 1:
+		return fmt.Errorf("--max-concurrent-reconciles must be at least 1, got %d", l.MaxConcurrentReconciles)
+	case l.QPS <= 0:
+		return fmt.Errorf("--reconcile-qps must be positive, got %v", l.QPS)
+	case l.Burst < 1:
+		return fmt.Errorf("--reconcile-burst must be at least 1, got %d", l.Burst)
+	case l.BackoffBase <= 0:
+		return fmt.Errorf("--reconcile-backoff-base must be positive, got %s", l.BackoffBase)
+	case l.BackoffMax < l.BackoffBase:
+		return fmt.Errorf("--reconcile-backoff-max %s is below --reconcile-backoff-base %s", l.BackoffMax, l.BackoffBase)
+	}
+	return nil
+}
+
+// options returns the controller options for l. A Tenant waits for the
+// longer of its backoff and the next token.
+func (l ReconcileLimits) options() controller.Options {
+	return controller.Options{
+		MaxConcurrentReconciles: l.MaxConcurrentReconciles,
+		RateLimiter: workqueue.NewMaxOfRateLimiter(
+			workqueue.NewItemExponentialFailureRateLimiter(l.BackoffBase, l.BackoffMax),
+			&workqueue.BucketRateLimiter{Limiter: rate.NewLimiter(rate.Limit(l.QPS), l.Burst)},
+		),
+	}
+}
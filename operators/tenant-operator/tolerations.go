@@ -0,0 +1,76 @@
+// Tenant default tolerations
+// Pods in a tenant namespace get the tenant's default tolerations through the
+// PodTolerationRestriction admission plugin, which reads them from a
+// namespace annotation
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+
+	platformv1alpha1 "github.com/xyz-company/tenant-operator/api/v1alpha1"
+)
+
+// defaultTolerationsAnnotation is read by the PodTolerationRestriction admission plugin
+const defaultTolerationsAnnotation = "scheduler.alpha.kubernetes.io/defaultTolerations"
+
+// reconcileDefaultTolerations writes Spec.DefaultTolerations to the tenant
+// namespace annotation. An empty spec leaves the annotation untouched so
+// manually configured namespaces keep working.
+func (r *TenantReconciler) reconcileDefaultTolerations(ctx context.Context, tenant *platformv1alpha1.Tenant, namespace string) error {
+	tolerations := tenant.Spec.DefaultTolerations
+	if len(tolerations) == 0 {
+		return nil
+	}
+	if err := validateTolerations(tolerations); err != nil {
+		return err
+	}
+
+	raw, err := json.Marshal(tolerations)
+	if err != nil {
+		return err
+	}
+	return r.setNamespaceAnnotation(ctx, namespace, defaultTolerationsAnnotation, string(raw))
+}
+
+// validateTolerations applies the same rules the API server uses for pod tolerations
+func validateTolerations(tolerations []corev1.Toleration) error {
+	for i, t := range tolerations {
+		if t.Key != "" {
+			if errs := validation.IsQualifiedName(t.Key); len(errs) > 0 {
+				return fmt.Errorf("defaultTolerations[%d].key %q: %s", i, t.Key, errs[0])
+			}
+		}
+
+		switch t.Operator {
+		case corev1.TolerationOpEqual, "":
+			if t.Key == "" {
+				return fmt.Errorf("defaultTolerations[%d]: operator must be Exists when key is empty", i)
+			}
+			if errs := validation.IsValidLabelValue(t.Value); len(errs) > 0 {
+				return fmt.Errorf("defaultTolerations[%d].value %q: %s", i, t.Value, errs[0])
+			}
+		case corev1.TolerationOpExists:
+			if t.Value != "" {
+				return fmt.Errorf("defaultTolerations[%d]: value must be empty when operator is Exists", i)
+			}
+		default:
+			return fmt.Errorf("defaultTolerations[%d]: unsupported operator %q", i, t.Operator)
+		}
+
+		switch t.Effect {
+		case "", corev1.TaintEffectNoSchedule, corev1.TaintEffectPreferNoSchedule, corev1.TaintEffectNoExecute:
+		default:
+			return fmt.Errorf("defaultTolerations[%d]: unsupported effect %q", i, t.Effect)
+		}
+		if t.TolerationSeconds != nil && t.Effect != corev1.TaintEffectNoExecute {
+			return fmt.Errorf("defaultTolerations[%d]: tolerationSeconds requires effect NoExecute", i)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func TestDefaultTolerationsAnnotation(t *testing.T) {
+	seconds := int64(300)
+	tenant := newTenant("search", "search-team")
+	tenant.Spec.DefaultTolerations = []corev1.Toleration{
+		{Key: "dedicated", Operator: corev1.TolerationOpEqual, Value: "search", Effect: corev1.TaintEffectNoSchedule},
+		{Key: "node.kubernetes.io/unreachable", Operator: corev1.TolerationOpExists, Effect: corev1.TaintEffectNoExecute, TolerationSeconds: &seconds},
+	}
+	c := newReconcilerClient(tenant)
+	reconcileTenant(t, newTestReconciler(c), "search")
+
+	ns := &corev1.Namespace{}
+	if err := c.Get(context.Background(), client.ObjectKey{Name: "search"}, ns); err != nil {
+		t.Fatal(err)
+	}
+	want := `[{"key":"dedicated","operator":"Equal","value":"search","effect":"NoSchedule"},` +
+		`{"key":"node.kubernetes.io/unreachable","operator":"Exists","effect":"NoExecute","tolerationSeconds":300}]`
+	if got := ns.Annotations[defaultTolerationsAnnotation]; got != want {
+		t.Fatalf("annotation = %s, want %s", got, want)
+	}
+}
+
+func TestDefaultTolerationsLeavesManualAnnotation(t *testing.T) {
+	manual := `[{"key":"gpu","operator":"Exists"}]`
+	c := newReconcilerClient(newTenant("search", "search-team"), &corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "search",
+			Annotations: map[string]string{defaultTolerationsAnnotation: manual},
+		},
+	})
+	reconcileTenant(t, newTestReconciler(c), "search")
+
+	ns := &corev1.Namespace{}
+	if err := c.Get(context.Background(), client.ObjectKey{Name: "search"}, ns); err != nil {
+		t.Fatal(err)
+	}
+	if got := ns.Annotations[defaultTolerationsAnnotation]; got != manual {
+		t.Fatalf("annotation = %s, want the manual %s", got, manual)
+	}
+}
+
+func TestValidateTolerations(t *testing.T) {
+	seconds := int64(60)
+	tests := []struct {
+		name       string
+		toleration corev1.Toleration
+		wantErr    bool
+	}{
+		{"equal", corev1.Toleration{Key: "dedicated", Value: "search"}, false},
+		{"exists without key", corev1.Toleration{Operator: corev1.TolerationOpExists}, false},
+		{"equal without key", corev1.Toleration{Operator: corev1.TolerationOpEqual, Value: "search"}, true},
+		{"invalid key", corev1.Toleration{Key: "dedicated pool", Operator: corev1.TolerationOpExists}, true},
+		{"invalid value", corev1.Toleration{Key: "dedicated", Value: "search team"}, true},
+		{"exists with value", corev1.Toleration{Key: "dedicated", Operator: corev1.TolerationOpExists, Value: "search"}, true},
+		{"unknown operator", corev1.Toleration{Key: "dedicated", Operator: "In"}, true},
+		{"unknown effect", corev1.Toleration{Key: "dedicated", Operator: corev1.TolerationOpExists, Effect: "NoRun"}, true},
+		{"seconds with NoExecute", corev1.Toleration{Key: "dedicated", Operator: corev1.TolerationOpExists, Effect: corev1.TaintEffectNoExecute, TolerationSeconds: &seconds}, false},
+		{"seconds with NoSchedule", corev1.Toleration{Key: "dedicated", Operator: corev1.TolerationOpExists, Effect: corev1.TaintEffectNoSchedule, TolerationSeconds: &seconds}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateTolerations([]corev1.Toleration{tt.toleration})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("validateTolerations() = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestWebhookRejectsInvalidTolerations(t *testing.T) {
+	tenant := newTenant("search", "search-team")
+	tenant.Spec.DefaultTolerations = []corev1.Toleration{{Operator: corev1.TolerationOpEqual, Value: "search"}}
+	c := newFakeClient()
+	v := &TenantValidator{Client: c, Reader: c}
+
+	resp := v.Handle(context.Background(), admissionRequest(t, admissionv1.Create, tenant, nil))
+	wantDenied(t, resp, "defaultTolerations[0]: operator must be Exists when key is empty")
+}
+
+func TestEnvtestDefaultTolerations(t *testing.T) {
+	ctx := context.Background()
+	c := envtestClient(t)
+	tenant := newTenant("tolerations", "search-team")
+	tenant.Spec.DefaultTolerations = []corev1.Toleration{
+		{Key: "dedicated", Operator: corev1.TolerationOpEqual, Value: "search", Effect: corev1.TaintEffectNoSchedule},
+	}
+	r := createEnvtestTenant(t, c, tenant)
+	reconcileTenant(t, r, "tolerations")
+
+	if stored := storedTenant(t, c, "tolerations"); len(stored.Spec.DefaultTolerations) != 1 {
+		t.Fatalf("stored defaultTolerations = %+v, want the one in the spec kept by the CRD schema", stored.Spec.DefaultTolerations)
+	}
+	want := `[{"key":"dedicated","operator":"Equal","value":"search","effect":"NoSchedule"}]`
+	ns := &corev1.Namespace{}
+	if err := c.Get(ctx, client.ObjectKey{Name: "tolerations"}, ns); err != nil {
+		t.Fatal(err)
+	}
+	if got := ns.Annotations[defaultTolerationsAnnotation]; got != want {
+		t.Fatalf("annotation = %s, want %s", got, want)
+	}
+
+	// A manual edit is reverted, and the namespace apply of the next pass
+	// leaves the annotation, which it doesn't own, alone
+	ns.Annotations[defaultTolerationsAnnotation] = `[{"key":"gpu","operator":"Exists"}]`
+	if err := c.Update(ctx, ns); err != nil {
+		t.Fatal(err)
+	}
+	reconcileTenant(t, r, "tolerations")
+	if err := c.Get(ctx, client.ObjectKey{Name: "tolerations"}, ns); err != nil {
+		t.Fatal(err)
+	}
+	if got := ns.Annotations[defaultTolerationsAnnotation]; got != want {
+		t.Fatalf("annotation after a manual edit = %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,97 @@
+// Topology spread defaulting
+// The pod mutating webhook spreads pods in tenant namespaces over zones and
+// nodes when their Tenant sets disruption.topologySpread, so a node drain
+// never takes all the replicas of a workload at once
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+// PodTopologySpreadDefaulter mutates pod admission requests in tenant namespaces
+type PodTopologySpreadDefaulter struct {
+	Client  client.Client
+	Decoder *admission.Decoder
+}
+
+// Handle gives new pods whose Tenant sets disruption.topologySpread a
+// topology spread constraint per topology key, unless they set their own
+func (d *PodTopologySpreadDefaulter) Handle(ctx context.Context, req admission.Request) admission.Response {
+	if req.Operation != admissionv1.Create {
+		return admission.Allowed("")
+	}
+
+	tenant, err := tenantForNamespace(ctx, d.Client, req.Namespace)
+	if err != nil {
+		return admission.Errored(http.StatusInternalServerError, err)
+	}
+	if tenant == nil || tenant.Spec.Disruption == nil || !tenant.Spec.Disruption.TopologySpread {
+		return admission.Allowed("")
+	}
+	keys := tenant.Spec.Disruption.TopologyKeys
+	if len(keys) == 0 {
+		keys = defaultTopologyKeys
+	}
+
+	pod := &corev1.Pod{}
+	if err := d.Decoder.Decode(req, pod); err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+	if !defaultTopologySpread(pod, keys) {
+		return admission.Allowed("")
+	}
+
+	raw, err := json.Marshal(pod)
+	if err != nil {
+		return admission.Errored(http.StatusInternalServerError, err)
+	}
+	return admission.PatchResponseFromRaw(req.Object.Raw, raw)
+}
+
+// defaultTopologySpread adds a constraint spreading pod's siblings, the
+// pods sharing its labels, over each of keys, and reports whether the pod
+// changed. Pods with constraints of their own are left alone, as are pods
+// without labels, which have no siblings to spread from. The constraints
+// prefer rather than require spreading, so pods still schedule on clusters
+// with a single zone.
+func defaultTopologySpread(pod *corev1.Pod, keys []string) bool {
+	if len(pod.Spec.TopologySpreadConstraints) > 0 {
+		return false
+	}
+	selector := map[string]string{}
+	for key, value := range pod.Labels {
+		// Differs per ReplicaSet; matchLabelKeys counts each rollout apart
+		if key == appsv1.DefaultDeploymentUniqueLabelKey {
+			continue
+		}
+		selector[key] = value
+	}
+	if len(selector) == 0 {
+		return false
+	}
+
+	var matchLabelKeys []string
+	if _, ok := pod.Labels[appsv1.DefaultDeploymentUniqueLabelKey]; ok {
+		matchLabelKeys = []string{appsv1.DefaultDeploymentUniqueLabelKey}
+	}
+	for _, key := range keys {
+		pod.Spec.TopologySpreadConstraints = append(pod.Spec.TopologySpreadConstraints, corev1.TopologySpreadConstraint{
+			MaxSkew:           1,
+			TopologyKey:       key,
+			WhenUnsatisfiable: corev1.ScheduleAnyway,
+			LabelSelector:     &metav1.LabelSelector{MatchLabels: selector},
+			MatchLabelKeys:    matchLabelKeys,
+		})
+	}
+	return true
+}
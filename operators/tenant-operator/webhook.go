@@ -0,0 +1,455 @@
+// Tenant admission webhooks
+// The validating webhook rejects Tenants that would break platform limits
+// before they are ever persisted, and deletes of protected Tenants
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	platformv1alpha1 "github.com/xyz-company/tenant-operator/api/v1alpha1"
+)
+
+// TenantValidator validates Tenant admission requests
+type TenantValidator struct {
+	Client client.Client
+	// Reader reads straight from the API server so the operator doesn't
+	// need to cache every ConfigMap in the cluster
+	Reader client.Reader
+
+	// MaxTenantsPerOwner caps how many Tenants share a Spec.Owner. 0 means unlimited.
+	MaxTenantsPerOwner int
+	// OwnerLimitsConfigMap maps owner names to a limit overriding MaxTenantsPerOwner
+	OwnerLimitsConfigMap types.NamespacedName
+
+	// AllowUnknownIntegrations admits AllowedIntegrations naming tenants
+	// that don't exist yet, with a warning, instead of rejecting them
+	AllowUnknownIntegrations bool
+
+	// MaxQuota caps the cpu and memory quota of a single Tenant. Resources
+	// missing from the list are uncapped.
+	MaxQuota corev1.ResourceList
+
+	// Exclusion rejects Tenants whose namespaces the operator won't manage
+	Exclusion NamespaceExclusion
+
+	// MaxTenantPriority caps the value of the PriorityClasses a Tenant
+	// creates for itself, so none can preempt platform workloads
+	MaxTenantPriority int32
+
+	// Vault checks that secrets backends stay within their tenant's tree.
+	// Nil rejects them, as the operator can't provision them.
+	Vault *VaultClient
+
+	// Ingress resolves the tenant ingress domains, checked for overlaps
+	// with the other Tenants' domains
+	Ingress IngressConfig
+
+	// CloudIdentity checks tenant cloud identities against the provider.
+	// Nil rejects them.
+	CloudIdentity *CloudIdentityConfig
+
+	// Spot identifies the spot nodes. Nil rejects tenants with
+	// compute.spotAllowed.
+	Spot *SpotConfig
+
+	// Logging holds the log stores tenant log destinations may use
+	Logging LoggingConfig
+
+	// Recorder records who removes deletion protection from a Tenant
+	Recorder record.EventRecorder
+
+	// Audit records the changes admitted in the Tenant's TenantAudit. Nil
+	// disables the audit trail.
+	Audit *TenantAuditor
+}
+
+// reservedNamespaces can never be claimed by a Tenant, since the tenant
+// namespace is named after it. Names starting with "kube-" are reserved too.
+var reservedNamespaces = map[string]bool{
+	"default":         true,
+	"kube-system":     true,
+	"kube-public":     true,
+	"kube-node-lease": true,
+	"istio-system":    true,
+	"platform-system": true,
+	"cert-manager":    true,
+}
+
+// Handle validates a single Tenant admission request
+func (v *TenantValidator) Handle(ctx context.Context, req admission.Request) admission.Response {
+	if req.Operation == admissionv1.Delete {
+		old := &platformv1alpha1.Tenant{}
+		if err := json.Unmarshal(req.OldObject.Raw, old); err != nil {
+			return admission.Errored(http.StatusBadRequest, err)
+		}
+		if resp := v.validateDelete(old); !resp.Allowed {
+			return resp
+		}
+		if err := v.validateNoChildren(ctx, old); err != nil {
+			return admission.Denied(err.Error())
+		}
+		if resp := v.recordAudit(ctx, req); !resp.Allowed {
+			return resp
+		}
+		return admission.Allowed("")
+	}
+	if req.Operation != admissionv1.Create && req.Operation != admissionv1.Update {
+		return admission.Allowed("")
+	}
+
+	tenant := &platformv1alpha1.Tenant{}
+	if err := json.Unmarshal(req.Object.Raw, tenant); err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+	checkRefs, err := checkExternalRefs(req, tenant)
+	if err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+
+	if reservedNamespaces[tenant.Name] || strings.HasPrefix(tenant.Name, "kube-") {
+		return admission.Denied(fmt.Sprintf("tenant name %q is reserved for a system namespace", tenant.Name))
+	}
+	if strings.TrimSpace(tenant.Spec.Owner) == "" {
+		return admission.Denied("owner is required")
+	}
+	if err := validateTolerations(tenant.Spec.DefaultTolerations); err != nil {
+		return admission.Denied(err.Error())
+	}
+	// The quota cap applies to what the Tenant gets, profile included
+	effective := tenant.DeepCopy()
+	if err := withProfile(ctx, v.Client, effective); err != nil {
+		return admission.Errored(http.StatusInternalServerError, err)
+	}
+	// Unset takes the default; anything set must be a usable percentage
+	if p := softThresholdPercent(effective.Spec.Quota); p < 1 || p > 100 {
+		return admission.Denied(fmt.Sprintf("quota.softThresholdPercent must be between 1 and 100, got %d", p))
+	}
+	if err := v.validateQuota(effective); err != nil {
+		return admission.Denied(err.Error())
+	}
+	if err := v.validateHierarchy(ctx, effective); err != nil {
+		return admission.Denied(err.Error())
+	}
+	if err := validateNamespaces(tenant); err != nil {
+		return admission.Denied(err.Error())
+	}
+	for _, namespace := range tenantNamespaces(tenant) {
+		if v.Exclusion.Excluded(namespace) {
+			return admission.Denied(fmt.Sprintf("namespace %s is excluded from management by the operator", namespace))
+		}
+	}
+	if err := v.validateNamespaceClaims(ctx, tenant); err != nil {
+		return admission.Denied(err.Error())
+	}
+	if err := validateExpiry(&tenant.Spec); err != nil {
+		return admission.Denied(err.Error())
+	}
+	if err := validateServiceAccounts(tenant.Spec.ServiceAccounts); err != nil {
+		return admission.Denied(err.Error())
+	}
+	if err := validatePriorityClasses(tenant); err != nil {
+		return admission.Denied(err.Error())
+	}
+	if checkRefs {
+		if err := v.validatePriorityClassRefs(ctx, tenant); err != nil {
+			return admission.Denied(err.Error())
+		}
+	}
+	if err := validateDisruption(tenant.Spec.Disruption); err != nil {
+		return admission.Denied(err.Error())
+	}
+	if err := validateStorage(tenant.Spec.Storage); err != nil {
+		return admission.Denied(err.Error())
+	}
+	if err := validateImagePolicy(tenant.Spec.ImagePolicy); err != nil {
+		return admission.Denied(err.Error())
+	}
+	if err := validateSharedSecrets(tenant.Spec.SharedSecrets); err != nil {
+		return admission.Denied(err.Error())
+	}
+	if checkRefs {
+		if err := v.validateSharedSecretRefs(ctx, tenant); err != nil {
+			return admission.Denied(err.Error())
+		}
+	}
+	if err := v.Vault.validateSecretsBackend(tenant); err != nil {
+		return admission.Denied(err.Error())
+	}
+	if err := validateCertificates(tenant.Spec.Certificates); err != nil {
+		return admission.Denied(err.Error())
+	}
+	if err := v.Ingress.validateIngress(tenant); err != nil {
+		return admission.Denied(err.Error())
+	}
+	if err := v.validateZoneClaims(ctx, tenant); err != nil {
+		return admission.Denied(err.Error())
+	}
+	if err := validateArgoCD(tenant.Spec.GitOps); err != nil {
+		return admission.Denied(err.Error())
+	}
+	if err := validatePlacement(tenant.Spec.Placement); err != nil {
+		return admission.Denied(err.Error())
+	}
+	if err := validateBudget(tenant.Spec.Budget); err != nil {
+		return admission.Denied(err.Error())
+	}
+	if err := v.CloudIdentity.validateCloudIdentity(tenant); err != nil {
+		return admission.Denied(err.Error())
+	}
+	if err := validateDedicatedNodes(tenant.Spec.DedicatedNodes); err != nil {
+		return admission.Denied(err.Error())
+	}
+	if err := v.Spot.validateCompute(tenant.Spec.Compute); err != nil {
+		return admission.Denied(err.Error())
+	}
+	if err := validateBackup(tenant.Spec.Backup); err != nil {
+		return admission.Denied(err.Error())
+	}
+	if err := v.Logging.validateLogDestination(tenant); err != nil {
+		return admission.Denied(err.Error())
+	}
+	if err := validateMetrics(tenant.Spec.Observability); err != nil {
+		return admission.Denied(err.Error())
+	}
+	if err := validateContacts(tenant.Spec.Contacts); err != nil {
+		return admission.Denied(err.Error())
+	}
+	if err := validatePolicy(tenant.Spec.Policy); err != nil {
+		return admission.Denied(err.Error())
+	}
+	if rate := tenant.Spec.DefaultRequestRateLimit; rate != "" {
+		if err := validateRequestRate(rate); err != nil {
+			return admission.Denied(err.Error())
+		}
+	}
+
+	integrations := v.validateIntegrations(ctx, req, tenant)
+	if !integrations.Allowed {
+		return integrations
+	}
+
+	if req.Operation == admissionv1.Create {
+		if resp := v.validateOwnerLimit(ctx, tenant); !resp.Allowed {
+			return resp
+		}
+	}
+	if req.Operation == admissionv1.Update {
+		old := &platformv1alpha1.Tenant{}
+		if err := json.Unmarshal(req.OldObject.Raw, old); err != nil {
+			return admission.Errored(http.StatusBadRequest, err)
+		}
+		v.recordProtectionRemoved(req, old, tenant)
+	}
+	if resp := v.recordAudit(ctx, req); !resp.Allowed {
+		return resp
+	}
+
+	warnings := append(integrations.Warnings, v.profileWarnings(ctx, tenant)...)
+	return admission.Allowed("").WithWarnings(warnings...)
+}
+
+// profileWarnings warns about a Spec.Profile that doesn't exist. It isn't
+// rejected, so a profile and its Tenants can be applied in any order.
+func (v *TenantValidator) profileWarnings(ctx context.Context, tenant *platformv1alpha1.Tenant) []string {
+	if tenant.Spec.Profile == "" {
+		return nil
+	}
+	err := v.Client.Get(ctx, types.NamespacedName{Name: tenant.Spec.Profile}, &platformv1alpha1.TenantProfile{})
+	if !errors.IsNotFound(err) {
+		return nil
+	}
+	return []string{fmt.Sprintf("TenantProfile %q does not exist; the Tenant stays in the Error phase until it is created", tenant.Spec.Profile)}
+}
+
+// validateNamespaceClaims rejects Tenants whose namespaces another Tenant
+// already has, whether as the namespace named after it or through
+// Spec.Namespaces
+func (v *TenantValidator) validateNamespaceClaims(ctx context.Context, tenant *platformv1alpha1.Tenant) error {
+	tenants, err := listTenants(ctx, v.Client)
+	if err != nil {
+		return err
+	}
+	claimed := map[string]string{}
+	for i := range tenants {
+		if tenants[i].Name == tenant.Name {
+			continue
+		}
+		for _, namespace := range knownNamespaces(&tenants[i]) {
+			claimed[namespace] = tenants[i].Name
+		}
+	}
+	for _, namespace := range tenantNamespaces(tenant) {
+		if owner, ok := claimed[namespace]; ok {
+			return fmt.Errorf("namespace %s already belongs to tenant %q", namespace, owner)
+		}
+	}
+	return nil
+}
+
+// validateQuota rejects quotas the reconciler couldn't apply and quotas
+// above MaxQuota, summed over the tenant's namespaces. Omitted fields are
+// checked at their defaults.
+func (v *TenantValidator) validateQuota(tenant *platformv1alpha1.Tenant) error {
+	total := corev1.ResourceList{}
+	namespaces := tenantNamespaces(tenant)
+	for _, namespace := range namespaces {
+		quota, err := tenantQuota(tenant, namespace)
+		if err != nil {
+			return err
+		}
+		for _, name := range []corev1.ResourceName{corev1.ResourceRequestsCPU, corev1.ResourceRequestsMemory} {
+			sum := total[name]
+			sum.Add(quota.Spec.Hard[name])
+			total[name] = sum
+		}
+	}
+
+	for _, c := range []struct {
+		field string
+		name  corev1.ResourceName
+	}{
+		{"cpu", corev1.ResourceRequestsCPU},
+		{"memory", corev1.ResourceRequestsMemory},
+	} {
+		max, ok := v.MaxQuota[corev1.ResourceName(c.field)]
+		if !ok {
+			continue
+		}
+		got := total[c.name]
+		if got.Cmp(max) <= 0 {
+			continue
+		}
+		if len(namespaces) > 1 {
+			return fmt.Errorf("quota.%s adds up to %s over %d namespaces, exceeding the cluster-wide maximum of %s", c.field, got.String(), len(namespaces), max.String())
+		}
+		return fmt.Errorf("quota.%s %s exceeds the cluster-wide maximum of %s", c.field, got.String(), max.String())
+	}
+	return nil
+}
+
+// checkExternalRefs reports whether the objects outside the Tenant that its
+// spec refers to, such as platform PriorityClasses and shared Secrets, are
+// to be checked. They are on create and when the spec changes, but not on
+// updates that leave it alone or while the Tenant is being deleted: the
+// platform team removing one mustn't reject the finalizer removal, leaving
+// the Tenant undeletable.
+func checkExternalRefs(req admission.Request, tenant *platformv1alpha1.Tenant) (bool, error) {
+	if tenant.DeletionTimestamp != nil {
+		return false, nil
+	}
+	if req.Operation != admissionv1.Update {
+		return true, nil
+	}
+	old := &platformv1alpha1.Tenant{}
+	if err := json.Unmarshal(req.OldObject.Raw, old); err != nil {
+		return false, err
+	}
+	return !equality.Semantic.DeepEqual(old.Spec, tenant.Spec), nil
+}
+
+// validateIntegrations rejects AllowedIntegrations entries that name neither
+// a Tenant nor a namespace. On UPDATE only newly added entries are checked,
+// so deleting a tenant doesn't block edits to the tenants integrating with it.
+func (v *TenantValidator) validateIntegrations(ctx context.Context, req admission.Request, tenant *platformv1alpha1.Tenant) admission.Response {
+	names := tenant.Spec.AllowedIntegrations
+	if req.Operation == admissionv1.Update {
+		old := &platformv1alpha1.Tenant{}
+		if err := json.Unmarshal(req.OldObject.Raw, old); err != nil {
+			return admission.Errored(http.StatusBadRequest, err)
+		}
+		names = addedIntegrations(old.Spec.AllowedIntegrations, names)
+	}
+
+	unknown, err := unknownIntegrations(ctx, v.Client, names)
+	if err != nil {
+		return admission.Errored(http.StatusInternalServerError, err)
+	}
+	if len(unknown) == 0 {
+		return admission.Allowed("")
+	}
+
+	msg := fmt.Sprintf("allowedIntegrations references unknown tenants: %s", strings.Join(unknown, ", "))
+	if v.AllowUnknownIntegrations {
+		return admission.Allowed("").WithWarnings(msg)
+	}
+	return admission.Denied(msg)
+}
+
+// validateOwnerLimit rejects the Tenant if its owner already holds as many
+// Tenants as their limit allows
+func (v *TenantValidator) validateOwnerLimit(ctx context.Context, tenant *platformv1alpha1.Tenant) admission.Response {
+	limit, err := v.ownerLimit(ctx, tenant.Spec.Owner)
+	if err != nil {
+		return admission.Errored(http.StatusInternalServerError, err)
+	}
+	if limit == 0 {
+		return admission.Allowed("")
+	}
+
+	count, err := v.countTenantsByOwner(ctx, tenant.Spec.Owner)
+	if err != nil {
+		return admission.Errored(http.StatusInternalServerError, err)
+	}
+	if count >= limit {
+		return admission.Denied(fmt.Sprintf("owner %q already has %d tenants, limit is %d", tenant.Spec.Owner, count, limit))
+	}
+
+	return admission.Allowed("")
+}
+
+// ownerLimit returns the Tenant limit for owner, preferring an entry in the
+// exception ConfigMap over the operator-wide default
+func (v *TenantValidator) ownerLimit(ctx context.Context, owner string) (int, error) {
+	if v.OwnerLimitsConfigMap.Name == "" {
+		return v.MaxTenantsPerOwner, nil
+	}
+
+	cm := &corev1.ConfigMap{}
+	if err := v.Reader.Get(ctx, v.OwnerLimitsConfigMap, cm); err != nil {
+		if errors.IsNotFound(err) {
+			return v.MaxTenantsPerOwner, nil
+		}
+		return 0, err
+	}
+
+	value, ok := cm.Data[owner]
+	if !ok {
+		return v.MaxTenantsPerOwner, nil
+	}
+	limit, err := strconv.Atoi(value)
+	if err != nil || limit < 0 {
+		return 0, fmt.Errorf("invalid tenant limit %q for owner %q in ConfigMap %s", value, owner, v.OwnerLimitsConfigMap)
+	}
+	return limit, nil
+}
+
+// countTenantsByOwner counts existing Tenants whose Spec.Owner matches owner
+func (v *TenantValidator) countTenantsByOwner(ctx context.Context, owner string) (int, error) {
+	tenants, err := listTenants(ctx, v.Client)
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for _, t := range tenants {
+		if t.Spec.Owner == owner {
+			count++
+		}
+	}
+	return count, nil
+}
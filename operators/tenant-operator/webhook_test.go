@@ -0,0 +1,193 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	platformv1alpha1 "github.com/xyz-company/tenant-operator/api/v1alpha1"
+)
+
+// newFakeClient returns a client of an API server holding objs. Status is
+// a subresource of the operator's own kinds, as in the CRDs.
+func newFakeClient(objs ...client.Object) client.WithWatch {
+	return fake.NewClientBuilder().
+		WithScheme(scheme).
+		WithObjects(objs...).
+		WithStatusSubresource(&platformv1alpha1.Tenant{}, &platformv1alpha1.TenantRequest{}).
+		Build()
+}
+
+// newTenant returns a Tenant called name owned by owner
+func newTenant(name, owner string) *platformv1alpha1.Tenant {
+	return &platformv1alpha1.Tenant{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Spec:       platformv1alpha1.TenantSpec{Owner: owner},
+	}
+}
+
+// admissionRequest returns the admission request of op on obj; old is the
+// object being replaced or deleted, if any
+func admissionRequest(t *testing.T, op admissionv1.Operation, obj, old runtime.Object) admission.Request {
+	t.Helper()
+	req := admission.Request{AdmissionRequest: admissionv1.AdmissionRequest{
+		Operation: op,
+		UserInfo:  authenticationv1.UserInfo{Username: "jane@xyz.com"},
+	}}
+	if obj != nil {
+		req.Object = runtime.RawExtension{Raw: mustMarshal(t, obj)}
+		if o, ok := obj.(client.Object); ok {
+			req.Name, req.Namespace = o.GetName(), o.GetNamespace()
+		}
+	}
+	if old != nil {
+		req.OldObject = runtime.RawExtension{Raw: mustMarshal(t, old)}
+		if o, ok := old.(client.Object); ok && obj == nil {
+			req.Name, req.Namespace = o.GetName(), o.GetNamespace()
+		}
+	}
+	return req
+}
+
+func mustMarshal(t *testing.T, obj interface{}) []byte {
+	t.Helper()
+	raw, err := json.Marshal(obj)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return raw
+}
+
+// wantAllowed fails t unless resp admits the request
+func wantAllowed(t *testing.T, resp admission.Response) {
+	t.Helper()
+	if !resp.Allowed {
+		t.Fatalf("denied: %s", resp.Result.Message)
+	}
+}
+
+// wantDenied fails t unless resp rejects the request with a message
+// containing msg
+func wantDenied(t *testing.T, resp admission.Response, msg string) {
+	t.Helper()
+	if resp.Allowed {
+		t.Fatalf("allowed, want denied with %q", msg)
+	}
+	if !strings.Contains(resp.Result.Message, msg) {
+		t.Fatalf("denied with %q, want a message containing %q", resp.Result.Message, msg)
+	}
+}
+
+func TestOwnerLimit(t *testing.T) {
+	limits := types.NamespacedName{Namespace: "platform-system", Name: "tenant-owner-limits"}
+	existing := []client.Object{
+		newTenant("search", "search-team"),
+		newTenant("search-staging", "search-team"),
+		newTenant("ads", "ads-team"),
+	}
+
+	tests := []struct {
+		name      string
+		max       int
+		overrides map[string]string
+		tenant    *platformv1alpha1.Tenant
+		denied    string
+	}{
+		{
+			name:   "unlimited by default",
+			tenant: newTenant("search-dev", "search-team"),
+		},
+		{
+			name:   "below the limit",
+			max:    2,
+			tenant: newTenant("ads-dev", "ads-team"),
+		},
+		{
+			name:   "at the limit",
+			max:    2,
+			tenant: newTenant("search-dev", "search-team"),
+			denied: `owner "search-team" already has 2 tenants, limit is 2`,
+		},
+		{
+			name:      "raised for the owner",
+			max:       2,
+			overrides: map[string]string{"search-team": "5"},
+			tenant:    newTenant("search-dev", "search-team"),
+		},
+		{
+			name:      "lowered for the owner",
+			max:       2,
+			overrides: map[string]string{"ads-team": "1"},
+			tenant:    newTenant("ads-dev", "ads-team"),
+			denied:    `owner "ads-team" already has 1 tenants, limit is 1`,
+		},
+		{
+			name:      "unlimited for the owner",
+			max:       2,
+			overrides: map[string]string{"search-team": "0"},
+			tenant:    newTenant("search-dev", "search-team"),
+		},
+		{
+			name:      "other owners keep the default",
+			max:       2,
+			overrides: map[string]string{"ads-team": "5"},
+			tenant:    newTenant("search-dev", "search-team"),
+			denied:    "limit is 2",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			objs := append([]client.Object{}, existing...)
+			if tt.overrides != nil {
+				objs = append(objs, &corev1.ConfigMap{
+					ObjectMeta: metav1.ObjectMeta{Namespace: limits.Namespace, Name: limits.Name},
+					Data:       tt.overrides,
+				})
+			}
+			c := newFakeClient(objs...)
+			v := &TenantValidator{Client: c, Reader: c, MaxTenantsPerOwner: tt.max, OwnerLimitsConfigMap: limits}
+
+			resp := v.Handle(context.Background(), admissionRequest(t, admissionv1.Create, tt.tenant, nil))
+			if tt.denied == "" {
+				wantAllowed(t, resp)
+			} else {
+				wantDenied(t, resp, tt.denied)
+			}
+		})
+	}
+}
+
+func TestOwnerLimitSkipsUpdates(t *testing.T) {
+	tenant := newTenant("search", "search-team")
+	c := newFakeClient(tenant)
+	v := &TenantValidator{Client: c, Reader: c, MaxTenantsPerOwner: 1}
+
+	updated := tenant.DeepCopy()
+	updated.Spec.CostCenter = "CC-SEARCH-001"
+	wantAllowed(t, v.Handle(context.Background(), admissionRequest(t, admissionv1.Update, updated, tenant)))
+}
+
+func TestOwnerLimitRejectsInvalidOverride(t *testing.T) {
+	limits := types.NamespacedName{Namespace: "platform-system", Name: "tenant-owner-limits"}
+	c := newFakeClient(&corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Namespace: limits.Namespace, Name: limits.Name},
+		Data:       map[string]string{"search-team": "lots"},
+	})
+	v := &TenantValidator{Client: c, Reader: c, MaxTenantsPerOwner: 2, OwnerLimitsConfigMap: limits}
+
+	resp := v.Handle(context.Background(), admissionRequest(t, admissionv1.Create, newTenant("search", "search-team"), nil))
+	if resp.Allowed || resp.Result.Code != 500 {
+		t.Fatalf("got allowed=%v code=%d, want a 500 for the invalid override", resp.Allowed, resp.Result.Code)
+	}
+}
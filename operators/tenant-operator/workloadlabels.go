@@ -0,0 +1,132 @@
+// Workload ownership labels
+// Deployments and pods in tenant namespaces are labelled with the tenant
+// owning the namespace and its cost center, so their usage is attributed
+// to it whatever the manifests say. The mutating webhook adds the labels a
+// workload lacks; the validating webhook, which sees the result, rejects
+// workloads claiming another tenant or cost center.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	platformv1alpha1 "github.com/xyz-company/tenant-operator/api/v1alpha1"
+)
+
+// costCenterLabel carries the cost center of the tenant owning a workload
+const costCenterLabel = "platform.xyz.com/cost-center"
+
+// ownershipLabels returns the labels every workload of tenant carries. The
+// cost center is left out if the tenant has none or it isn't a valid label
+// value.
+func ownershipLabels(tenant *platformv1alpha1.Tenant) map[string]string {
+	labels := map[string]string{tenantLabel: tenant.Name}
+	if costCenter := tenant.Spec.CostCenter; costCenter != "" && len(validation.IsValidLabelValue(costCenter)) == 0 {
+		labels[costCenterLabel] = costCenter
+	}
+	return labels
+}
+
+// WorkloadLabelDefaulter mutates Deployment and pod admission requests in
+// tenant namespaces
+type WorkloadLabelDefaulter struct {
+	Client client.Client
+}
+
+// Handle adds the ownership labels a new or updated workload lacks. Labels
+// it already has are left for WorkloadLabelValidator to check.
+func (d *WorkloadLabelDefaulter) Handle(ctx context.Context, req admission.Request) admission.Response {
+	if req.Operation != admissionv1.Create && req.Operation != admissionv1.Update {
+		return admission.Allowed("")
+	}
+	tenant, err := tenantForNamespace(ctx, d.Client, req.Namespace)
+	if err != nil {
+		return admission.Errored(http.StatusInternalServerError, err)
+	}
+	if tenant == nil {
+		return admission.Allowed("")
+	}
+
+	// Decoded generically, so every kind is patched the same way and no
+	// field the operator's API types don't know of is dropped
+	obj := map[string]interface{}{}
+	if err := json.Unmarshal(req.Object.Raw, &obj); err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+	metadata, _ := obj["metadata"].(map[string]interface{})
+	if metadata == nil {
+		metadata = map[string]interface{}{}
+		obj["metadata"] = metadata
+	}
+	labels, _ := metadata["labels"].(map[string]interface{})
+	if labels == nil {
+		labels = map[string]interface{}{}
+	}
+	changed := false
+	for key, value := range ownershipLabels(tenant) {
+		if _, ok := labels[key]; !ok {
+			labels[key] = value
+			changed = true
+		}
+	}
+	if !changed {
+		return admission.Allowed("")
+	}
+	metadata["labels"] = labels
+
+	raw, err := json.Marshal(obj)
+	if err != nil {
+		return admission.Errored(http.StatusInternalServerError, err)
+	}
+	return admission.PatchResponseFromRaw(req.Object.Raw, raw)
+}
+
+// WorkloadLabelValidator validates Deployment and pod admission requests
+// in tenant namespaces
+type WorkloadLabelValidator struct {
+	Client client.Client
+}
+
+// Handle rejects workloads whose ownership labels differ from the tenant
+// owning their namespace
+func (v *WorkloadLabelValidator) Handle(ctx context.Context, req admission.Request) admission.Response {
+	if req.Operation != admissionv1.Create && req.Operation != admissionv1.Update {
+		return admission.Allowed("")
+	}
+	tenant, err := tenantForNamespace(ctx, v.Client, req.Namespace)
+	if err != nil {
+		return admission.Errored(http.StatusInternalServerError, err)
+	}
+	if tenant == nil {
+		return admission.Allowed("")
+	}
+
+	var obj struct {
+		Metadata struct {
+			Labels map[string]string `json:"labels"`
+		} `json:"metadata"`
+	}
+	if err := json.Unmarshal(req.Object.Raw, &obj); err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+	want := ownershipLabels(tenant)
+	for _, key := range []string{tenantLabel, costCenterLabel} {
+		value, ok := obj.Metadata.Labels[key]
+		switch {
+		case !ok || value == want[key]:
+		case want[key] == "":
+			return admission.Denied(fmt.Sprintf("namespace %s belongs to tenant %q, which has no %s label; remove it", req.Namespace, tenant.Name, key))
+		default:
+			return admission.Denied(fmt.Sprintf("namespace %s belongs to tenant %q: label %s must be %q, not %q", req.Namespace, tenant.Name, key, want[key], value))
+		}
+	}
+	return admission.Allowed("")
+}